@@ -0,0 +1,61 @@
+package main
+
+import (
+	"crypto/sha256"
+	"io/ioutil"
+	"time"
+
+	"github.com/atlassian/escalator/pkg/errorkind"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// nodeGroupsConfigHash returns the hash of the content of the nodegroups config file
+func nodeGroupsConfigHash() ([sha256.Size]byte, error) {
+	content, err := ioutil.ReadFile(*nodegroupConfigFile)
+	if err != nil {
+		return [sha256.Size]byte{}, errors.Wrap(err, "failed to read configFile")
+	}
+	return sha256.Sum256(content), nil
+}
+
+// watchNodeGroupsConfig reloads the nodegroups config every time the content of the file changes, checking it every
+// interval. The content is compared rather than the modification time, as a ConfigMap volume swaps the file through a
+// symlink. A reload that failed to read the file or to apply it is retried every interval until it succeeds. Content
+// that is invalid is logged once and not retried until the file changes again
+func watchNodeGroupsConfig(reload func() error, interval time.Duration, stop <-chan struct{}) {
+	last, err := nodeGroupsConfigHash()
+	if err != nil {
+		log.WithError(err).Warn("Failed to read the nodegroups config to watch it")
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			hash, err := nodeGroupsConfigHash()
+			if err != nil {
+				log.WithError(err).Warn("Failed to read the nodegroups config to check it for changes")
+				continue
+			}
+			if hash == last {
+				continue
+			}
+			log.Infof("%v changed, reloading node groups", *nodegroupConfigFile)
+			if err := reload(); err != nil {
+				if errorkind.Is(err, errorkind.Validation) {
+					// retrying can't fix the content, so the file isn't reloaded until it changes again
+					log.WithError(err).Errorf("Invalid nodegroups config. Keeping the current node groups until %v changes", *nodegroupConfigFile)
+					last = hash
+					continue
+				}
+				log.WithError(err).Error("Failed to reload node groups. Keeping the current node groups and retrying")
+				continue
+			}
+			last = hash
+		case <-stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/atlassian/escalator/pkg/errorkind"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchNodeGroupsConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "escalator")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "nodegroups.yaml")
+	require.NoError(t, ioutil.WriteFile(file, []byte("node_groups: []"), 0644))
+
+	previous := *nodegroupConfigFile
+	*nodegroupConfigFile = file
+	defer func() { *nodegroupConfigFile = previous }()
+
+	// each reload returns the result sent on the channel
+	reloads := make(chan error)
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	interval := 10 * time.Millisecond
+	go func() {
+		watchNodeGroupsConfig(func() error { return <-reloads }, interval, stop)
+		close(done)
+	}()
+	defer func() {
+		close(stop)
+		<-done
+	}()
+
+	reloaded := func(result error) bool {
+		select {
+		case reloads <- result:
+			return true
+		case <-time.After(20 * interval):
+			return false
+		}
+	}
+
+	// the unchanged file isn't reloaded
+	require.False(t, reloaded(nil))
+
+	// a reload that failed to read or apply the file is retried until it succeeds, without the file changing again
+	require.NoError(t, ioutil.WriteFile(file, []byte("node_groups: [{name: default}]"), 0644))
+	require.True(t, reloaded(errors.New("failed to open configFile")))
+	require.True(t, reloaded(nil))
+	require.False(t, reloaded(nil))
+
+	// invalid content isn't retried until the file changes again
+	require.NoError(t, ioutil.WriteFile(file, []byte("node_groups: [{name: shared}]"), 0644))
+	require.True(t, reloaded(errorkind.New(errorkind.Validation, "invalid config")))
+	require.False(t, reloaded(nil))
+	require.NoError(t, ioutil.WriteFile(file, []byte("node_groups: [{name: shared, max_nodes: 10}]"), 0644))
+	require.True(t, reloaded(nil))
+	require.False(t, reloaded(nil))
+}
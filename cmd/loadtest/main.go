@@ -0,0 +1,279 @@
+// Command loadtest benchmarks full escalator runs against synthetic clusters of increasing size, to measure how the
+// run time of the decision engine grows with the number of nodes and pods.
+//
+// The nodes and pods are generated in memory and listed from an indexer, the same as from the informer caches. The
+// cloud provider is the test cloud provider. By default the node groups run in dry mode, so the runs only decide.
+// With --api=fake the nodes are tainted and untainted through a fake API server, and the indexers are refreshed from
+// it between runs.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/atlassian/escalator/pkg/cloudprovider"
+	"github.com/atlassian/escalator/pkg/controller"
+	"github.com/atlassian/escalator/pkg/test"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/alecthomas/kingpin.v2"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	v1lister "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// the label the synthetic node groups select their nodes and pods by
+	nodeGroupLabelKey = "loadtest.escalator/nodegroup"
+
+	// the allocatable of each synthetic node
+	nodeCPUMillis = 4000
+	nodeMemBytes  = 16 << 30
+
+	apiMemory = "memory"
+	apiFake   = "fake"
+)
+
+var (
+	loglevel    = kingpin.Flag("loglevel", "Logging level passed into logrus. 4 for info, 5 for debug.").Short('v').Default(fmt.Sprintf("%d", log.WarnLevel)).Int()
+	clusterSize = kingpin.Flag("nodes", "Number of nodes of a synthetic cluster. Can be repeated to benchmark clusters of each size").Default("100", "500", "1000", "5000").Ints()
+	podsPerNode = kingpin.Flag("pods-per-node", "Number of pods scheduled on each node").Default("10").Int()
+	nodeGroups  = kingpin.Flag("node-groups", "Number of node groups the nodes and pods are spread over").Default("4").Int()
+	utilisation = kingpin.Flag("utilisation", "Percentage of the allocatable of the nodes requested by the pods. Above 70 the node groups scale up, below 30 they scale down").Default("50").Int()
+	runs        = kingpin.Flag("runs", "Number of runs benchmarked for each cluster size").Default("5").Int()
+	api         = kingpin.Flag("api", "Where the scaling actions go. (memory, fake)").Default(apiMemory).Enum(apiMemory, apiFake)
+	output      = kingpin.Flag("output", "Format of the report. (text, json)").Default("text").Enum("text", "json")
+)
+
+// Result is the run times of a synthetic cluster
+type Result struct {
+	Nodes      int           `json:"nodes"`
+	Pods       int           `json:"pods"`
+	NodeGroups int           `json:"node_groups"`
+	Runs       int           `json:"runs"`
+	Min        time.Duration `json:"min_ns"`
+	Mean       time.Duration `json:"mean_ns"`
+	P95        time.Duration `json:"p95_ns"`
+	Max        time.Duration `json:"max_ns"`
+	// PerNode is the mean run time divided by the number of nodes
+	PerNode time.Duration `json:"per_node_ns"`
+}
+
+// cluster is a synthetic cluster listed from indexers, as the controller lists the informer caches
+type cluster struct {
+	client      kubernetes.Interface
+	nodeIndexer cache.Indexer
+	podIndexer  cache.Indexer
+	nodeGroups  []controller.NodeGroupOptions
+	provider    *test.CloudProvider
+}
+
+// cloudProviderBuilder builds the test cloud provider of the synthetic cluster
+type cloudProviderBuilder struct {
+	provider *test.CloudProvider
+}
+
+// Build returns the test cloud provider
+func (b cloudProviderBuilder) Build() (cloudprovider.CloudProvider, error) {
+	return b.provider, nil
+}
+
+// buildCluster generates the nodes and pods of a synthetic cluster, spread evenly over the node groups
+func buildCluster(nodes int) (*cluster, error) {
+	c := &cluster{
+		nodeIndexer: cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}),
+		podIndexer:  cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}),
+		provider:    test.NewCloudProvider(*nodeGroups),
+	}
+	podCPU := int64(nodeCPUMillis * *utilisation / 100 / *podsPerNode)
+	podMem := int64(nodeMemBytes / 100 * *utilisation / *podsPerNode)
+
+	var objects []runtime.Object
+	created := time.Now().Add(-time.Hour)
+	for g := 0; g < *nodeGroups; g++ {
+		name := fmt.Sprintf("loadtest-%d", g)
+		// the remainder of the nodes goes to the first node group
+		groupNodes := nodes / *nodeGroups
+		if g == 0 {
+			groupNodes += nodes % *nodeGroups
+		}
+		opts := controller.NodeGroupOptions{
+			Name:                               name,
+			LabelKey:                           nodeGroupLabelKey,
+			LabelValue:                         name,
+			CloudProviderGroupName:             name,
+			MinNodes:                           1,
+			MaxNodes:                           2*groupNodes + 1,
+			TaintLowerCapacityThresholdPercent: 30,
+			TaintUpperCapacityThresholdPercent: 40,
+			ScaleUpThresholdPercent:            70,
+			SlowNodeRemovalRate:                1,
+			FastNodeRemovalRate:                2,
+			SoftDeleteGracePeriod:              "10m",
+			HardDeleteGracePeriod:              "1h",
+			ScaleUpCoolDownPeriod:              "1m",
+			DryMode:                            *api == apiMemory,
+		}
+		if errs := controller.ValidateNodeGroup(opts); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid synthetic node group %v: %v", name, errs)
+		}
+		c.nodeGroups = append(c.nodeGroups, opts)
+		c.provider.RegisterNodeGroup(test.NewNodeGroup(name, int64(opts.MinNodes), int64(opts.MaxNodes), int64(groupNodes)))
+
+		for n := 0; n < groupNodes; n++ {
+			node := test.BuildTestNode(test.NodeOpts{
+				Name:       fmt.Sprintf("%v-node-%d", name, n),
+				CPU:        nodeCPUMillis,
+				Mem:        nodeMemBytes,
+				LabelKey:   nodeGroupLabelKey,
+				LabelValue: name,
+				Creation:   created,
+			})
+			node.Status.Conditions = []v1.NodeCondition{{Type: v1.NodeReady, Status: v1.ConditionTrue}}
+			c.nodeIndexer.Add(node)
+			objects = append(objects, node)
+
+			for p := 0; p < *podsPerNode; p++ {
+				pod := test.BuildTestPod(test.PodOpts{
+					Name:              fmt.Sprintf("%v-pod-%d", node.Name, p),
+					Namespace:         "loadtest",
+					CPU:               []int64{podCPU},
+					Mem:               []int64{podMem},
+					NodeSelectorKey:   nodeGroupLabelKey,
+					NodeSelectorValue: name,
+					NodeName:          node.Name,
+					Owner:             "ReplicaSet",
+				})
+				pod.Status.Phase = v1.PodRunning
+				c.podIndexer.Add(pod)
+				objects = append(objects, pod)
+			}
+		}
+	}
+	c.client = fake.NewSimpleClientset(objects...)
+	return c, nil
+}
+
+// refresh replaces the nodes in the indexer with the nodes of the fake API server, as the informer would
+func (c *cluster) refresh() error {
+	nodes, err := c.client.CoreV1().Nodes().List(metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	for i := range nodes.Items {
+		if err := c.nodeIndexer.Update(&nodes.Items[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// benchmark times the runs of the controller over the synthetic cluster
+func benchmark(nodes int) (Result, error) {
+	c, err := buildCluster(nodes)
+	if err != nil {
+		return Result{}, err
+	}
+	opts := controller.Opts{
+		K8SClient:            c.client,
+		NodeGroups:           c.nodeGroups,
+		CloudProviderBuilder: cloudProviderBuilder{c.provider},
+		// the scan interval is the deadline of each run
+		ScanInterval: time.Hour,
+	}
+	client := controller.NewClientWithListers(c.client, c.nodeGroups, v1lister.NewPodLister(c.podIndexer), v1lister.NewNodeLister(c.nodeIndexer))
+	stopChan := make(chan struct{})
+	defer close(stopChan)
+	ctrl, err := controller.NewControllerWithClient(opts, client, stopChan)
+	if err != nil {
+		return Result{}, err
+	}
+
+	durations := make([]time.Duration, 0, *runs)
+	for i := 0; i < *runs; i++ {
+		start := time.Now()
+		if err := ctrl.RunOnce(); err != nil {
+			return Result{}, err
+		}
+		durations = append(durations, time.Since(start))
+		if *api == apiFake {
+			if err := c.refresh(); err != nil {
+				return Result{}, err
+			}
+		}
+	}
+	return summarise(nodes, nodes*(*podsPerNode), durations), nil
+}
+
+// summarise works out the result from the run times
+func summarise(nodes int, pods int, durations []time.Duration) Result {
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	var total time.Duration
+	for _, d := range durations {
+		total += d
+	}
+	mean := total / time.Duration(len(durations))
+	p95 := durations[int(math.Ceil(0.95*float64(len(durations))))-1]
+	return Result{
+		Nodes:      nodes,
+		Pods:       pods,
+		NodeGroups: *nodeGroups,
+		Runs:       len(durations),
+		Min:        durations[0],
+		Mean:       mean,
+		P95:        p95,
+		Max:        durations[len(durations)-1],
+		PerNode:    mean / time.Duration(nodes),
+	}
+}
+
+// printResults writes the report of the results in the --output format
+func printResults(results []Result) error {
+	if *output == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(results)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "NODES\tPODS\tNODE GROUPS\tRUNS\tMIN\tMEAN\tP95\tMAX\tPER NODE")
+	for _, r := range results {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\n", r.Nodes, r.Pods, r.NodeGroups, r.Runs, r.Min, r.Mean, r.P95, r.Max, r.PerNode)
+	}
+	return w.Flush()
+}
+
+func main() {
+	kingpin.Parse()
+	log.SetLevel(log.Level(*loglevel))
+
+	if *nodeGroups < 1 || *podsPerNode < 1 || *runs < 1 {
+		log.Fatal("--node-groups, --pods-per-node and --runs must be at least 1")
+	}
+	if *utilisation < 1 || *utilisation > 100 {
+		log.Fatal("--utilisation must be between 1 and 100")
+	}
+
+	var results []Result
+	for _, nodes := range *clusterSize {
+		if nodes < *nodeGroups {
+			log.Fatalf("--nodes %v must be at least the number of node groups", nodes)
+		}
+		log.Infof("Benchmarking %v runs of %v nodes and %v pods", *runs, nodes, nodes*(*podsPerNode))
+		result, err := benchmark(nodes)
+		if err != nil {
+			log.WithError(err).Fatalf("Failed to benchmark %v nodes", nodes)
+		}
+		results = append(results, result)
+	}
+	if err := printResults(results); err != nil {
+		log.Fatal(err)
+	}
+}
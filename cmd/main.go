@@ -4,15 +4,23 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"math/rand"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
+	"github.com/atlassian/escalator/pkg/api"
 	"github.com/atlassian/escalator/pkg/cloudprovider"
 	"github.com/atlassian/escalator/pkg/cloudprovider/aws"
+	"github.com/atlassian/escalator/pkg/cloudprovider/azure"
+	"github.com/atlassian/escalator/pkg/cloudprovider/gce"
 	"github.com/atlassian/escalator/pkg/controller"
+	"github.com/atlassian/escalator/pkg/errorkind"
 	"github.com/atlassian/escalator/pkg/k8s"
 	"github.com/atlassian/escalator/pkg/metrics"
+	"github.com/atlassian/escalator/pkg/profiling"
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
@@ -21,79 +29,193 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes"
 	clientcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/record"
 )
 
+// version is the version of escalator, set at build time with -ldflags "-X main.version=<version>"
+var version = "dev"
+
+var (
+	runCommand             = kingpin.Command("run", "Run the autoscaler").Default()
+	runOnce                = runCommand.Flag("once", "Run every node group a single time, print a json summary of the run and exit. Exits non zero if the run or any node group failed").Bool()
+	runOnceMode            = runCommand.Flag("once-mode", "What the --once run does. full scales as normal, reap only deletes the tainted nodes that can be removed and untaint only untaints every tainted node. (full, reap, untaint)").Default(controller.RunModeFull).Enum(controller.RunModes...)
+	validateCommand        = kingpin.Command("validate", "Validate the node group config and exit. Exits non zero if any check fails")
+	validateAgainstCluster = validateCommand.Flag("against-cluster", "Also check the node groups against the cluster and cloud provider").Bool()
+	validateOutput         = validateCommand.Flag("output", "Format of the validation results. (text, json)").Default("text").Enum("text", "json")
+	migrateTaintsCommand   = kingpin.Command("migrate-taints", "Rewrite the escalator taints on every node in the cluster with the current taint scheme and exit")
+	migrateTaintsDryRun    = migrateTaintsCommand.Flag("dry-run", "Only print the nodes that would be migrated").Bool()
+	selftestCommand        = kingpin.Command("selftest", "Exercise the permissions escalator needs against the cluster and cloud provider and exit. Exits non zero if any check fails")
+	selftestNode           = selftestCommand.Flag("node", "Node to add and remove a no-op taint on. Without it a node of the first node group is only updated in dry run").String()
+	selftestOutput         = selftestCommand.Flag("output", "Format of the selftest results. (text, json)").Default("text").Enum("text", "json")
+	rbacCommand            = kingpin.Command("rbac", "Print the minimal RBAC manifests for the features escalator is run with and exit")
+	rbacNamespace          = rbacCommand.Flag("namespace", "Namespace escalator runs in").Default("kube-system").String()
+	rbacServiceAccount     = rbacCommand.Flag("service-account", "Name of the service account, roles and bindings").Default("escalator").String()
+	supportBundleCommand   = kingpin.Command("support-bundle", "Download a support bundle of the config, node group states, latest runs and metrics of a running escalator and exit")
+	supportBundleURL       = supportBundleCommand.Flag("url", "Base URL of the --address of the running escalator").Default("http://localhost:8080").String()
+	supportBundleOutput    = supportBundleCommand.Flag("output", "File the support bundle is written to, - for stdout. Defaults to escalator-support-bundle-<time>.tar.gz").String()
+)
+
 var (
 	loglevel                   = kingpin.Flag("loglevel", "Logging level passed into logrus. 4 for info, 5 for debug.").Short('v').Default(fmt.Sprintf("%d", log.InfoLevel)).Int()
 	logfmt                     = kingpin.Flag("logfmt", "Set the format of logging output. (json, ascii)").Default("ascii").Enum("ascii", "json")
-	addr                       = kingpin.Flag("address", "Address to listen to for /metrics").Default(":8080").String()
+	addr                       = kingpin.Flag("address", "Address to listen to for /metrics, /healthz, /report, /cycles and /api").Default(":8080").String()
 	scanInterval               = kingpin.Flag("scaninterval", "How often cluster is reevaluated for scale up or down").Default("60s").Duration()
+	scanIntervalMin            = kingpin.Flag("scaninterval-min", "Shortest the scan interval is tuned to while a node group is scaling up or waiting for nodes. 0 disables shortening").Default("0s").Duration()
+	scanIntervalMax            = kingpin.Flag("scaninterval-max", "Longest the scan interval is tuned to while every node group has nothing to do. 0 disables lengthening").Default("0s").Duration()
+	scanJitter                 = kingpin.Flag("scanjitter", "Maximum random delay added to each scan interval").Default("0s").Duration()
+	scanAlign                  = kingpin.Flag("scanalign", "Align scans to multiples of the scan interval on the wall clock").Bool()
+	shutdownDrainTimeout       = kingpin.Flag("shutdown-drain-timeout", "How long the cloud provider calls in flight when stopping get to finish before they are cancelled").Default("10s").Duration()
 	kubeConfigFile             = kingpin.Flag("kubeconfig", "Kubeconfig file location").String()
-	nodegroupConfigFile        = kingpin.Flag("nodegroups", "Config file for nodegroups").Required().String()
+	kubeContext                = kingpin.Flag("context", "Kubeconfig context to use. Implies out of cluster config").String()
+	impersonateUser            = kingpin.Flag("as", "Username to impersonate for Kubernetes API calls").String()
+	impersonateGroups          = kingpin.Flag("as-group", "Group to impersonate for Kubernetes API calls. Can be repeated to specify multiple groups").Strings()
+	nodegroupConfigFile        = kingpin.Flag("nodegroups", "Config file for nodegroups. Required by the run and validate commands").String()
+	nodegroupsWatchInterval    = kingpin.Flag("nodegroups-watch-interval", "How often the nodegroups config file is checked for changes to reload it. 0 disables watching").Default("0s").Duration()
 	drymode                    = kingpin.Flag("drymode", "master drymode argument. If true, forces drymode on all nodegroups").Bool()
-	cloudProviderID            = kingpin.Flag("cloud-provider", "Cloud provider to use. Available options: (aws)").Default("aws").Enum("aws")
+	auditMode                  = kingpin.Flag("audit", "master audit mode argument. If true, nodes are tainted for real but cloud provider deletions are only dry run on all nodegroups").Bool()
+	cloudProviderID            = kingpin.Flag("cloud-provider", "Cloud provider of the node groups without a cloud_provider. Available options: (aws, gce, azure)").Default("aws").Enum("aws", "gce", "azure")
 	awsAssumeRoleARN           = kingpin.Flag("aws-assume-role-arn", "AWS role arn to assume. Only usable when using the aws cloud provider. Example: arn:aws:iam::111111111111:role/escalator").String()
+	awsMaxRetries              = kingpin.Flag("aws-max-retries", "Maximum number of times a failed AWS API call is retried").Default("3").Int()
+	awsAPITimeout              = kingpin.Flag("aws-api-timeout", "Timeout for a single AWS API call attempt").Default("30s").Duration()
+	awsRetryMode               = kingpin.Flag("aws-retry-mode", "AWS API retry mode. adaptive additionally rate limits calls when the API is throttling. (standard, adaptive)").Default(aws.RetryModeStandard).Enum(aws.RetryModes...)
+	awsVCPUQuota               = kingpin.Flag("aws-vcpu-quota", "On-demand vCPU limit of the AWS account. Scale ups that don't fit in the vCPUs left are reduced or blocked. 0 disables the check").Default("0").Int64()
+	gceAPITimeout              = kingpin.Flag("gce-api-timeout", "Timeout for a single GCE compute API call").Default("30s").Duration()
+	azureAPITimeout            = kingpin.Flag("azure-api-timeout", "Timeout for a single Azure Resource Manager API call").Default("30s").Duration()
+	azureIdentityClientID      = kingpin.Flag("azure-identity-client-id", "Client id of the user assigned managed identity to use, e.g. the kubelet identity of AKS. The system assigned identity is used if empty").String()
+	providerWriteQPS           = kingpin.Flag("provider-write-qps", "Rate of the cloud provider node group resizes and instance terminations shared by all of the node groups. 0 disables the limit").Default("0").Float64()
+	providerWriteBurst         = kingpin.Flag("provider-write-burst", "Most cloud provider node group resizes and instance terminations made at once").Default("10").Int()
+	providerWriteGroupShare    = kingpin.Flag("provider-write-group-share", "Percentage of the provider write rate and burst a single node group can take").Default("50").Int()
+	nodeLabelDomain            = kingpin.Flag("node-label-domain", "Segregates this escalator from others in the cluster. Node group label keys must be in the domain, and the taints, annotations, leader election and heartbeat names and metrics are specific to it").String()
 	leaderElect                = kingpin.Flag("leader-elect", "Enable leader election").Default("false").Bool()
 	leaderElectLeaseDuration   = kingpin.Flag("leader-elect-lease-duration", "Leader election lease duration").Default("15s").Duration()
 	leaderElectRenewDeadline   = kingpin.Flag("leader-elect-renew-deadline", "Leader election renew deadline").Default("10s").Duration()
 	leaderElectRetryPeriod     = kingpin.Flag("leader-elect-retry-period", "Leader election retry period").Default("2s").Duration()
 	leaderElectConfigNamespace = kingpin.Flag("leader-elect-config-namespace", "Leader election config map namespace").Default("kube-system").String()
 	leaderElectConfigName      = kingpin.Flag("leader-elect-config-name", "Leader election config map name").Default("escalator-leader-elect").String()
+	heartbeatLeaseName         = kingpin.Flag("heartbeat-lease-name", "Name of the lease renewed at the end of every successful run. Disabled if empty").String()
+	heartbeatLeaseNamespace    = kingpin.Flag("heartbeat-lease-namespace", "Heartbeat lease namespace").Default("kube-system").String()
+	eventVerbosity             = kingpin.Flag("event-verbosity", "Events emitted for the scaling actions. (none, summary, node)").Default(controller.EventVerbositySummary).Enum(controller.EventVerbosities...)
+	eventDedupWindow           = kingpin.Flag("event-dedup-window", "How long an event with the same reason isn't emitted again on the same node").Default("10m").Duration()
+	maxNodeEventsPerRun        = kingpin.Flag("max-node-events-per-run", "Maximum number of events emitted on nodes in a run. 0 is unlimited").Default("20").Int()
+	schedulingFailures         = kingpin.Flag("scheduling-failures", "Watch the FailedScheduling events of pods and count them for each node group").Bool()
+	podDisruptionBudgets       = kingpin.Flag("pod-disruption-budgets", "Watch PodDisruptionBudgets and defer the hard deletions of tainted nodes that would violate them").Bool()
+	slowCycleProfileThreshold  = kingpin.Flag("slow-cycle-profile-threshold", "Duration after which a run is profiled until it finishes. 0 disables profiling").Default("0s").Duration()
+	slowCycleProfileSink       = kingpin.Flag("slow-cycle-profile-sink", "Where the profiles of slow runs are written. A directory path, file:///path or s3://bucket/prefix").Default("/tmp/escalator-profiles").String()
+	slowCycleProfileInterval   = kingpin.Flag("slow-cycle-profile-min-interval", "Minimum time between two profiles of slow runs").Default("1h").Duration()
+	configHistorySize          = kingpin.Flag("config-history", "Number of the latest applied node group configs kept for /api/v1/config/history. 0 disables the history").Default("10").Int()
+	configRollback             = kingpin.Flag("config-rollback", "Enable rolling back to a config in the history with a POST to /api/v1/config/rollback").Bool()
 )
 
-// cloudProviderBuilder builds the requested cloud provider. aws, gce, etc
-type cloudProviderBuilder struct {
-	ProviderOpts cloudprovider.BuildOpts
-}
-
-// Build builds the requested CloudProvider
-func (b cloudProviderBuilder) Build() (cloudprovider.CloudProvider, error) {
-	switch b.ProviderOpts.ProviderID {
-	case aws.ProviderName:
+// cloudProviders builds the cloud providers node groups can select with cloud_provider. aws, gce, azure, etc
+var cloudProviders = cloudprovider.Registry{
+	aws.ProviderName: func(opts cloudprovider.BuildOpts) cloudprovider.Builder {
 		return aws.Builder{
-			ProviderOpts: b.ProviderOpts,
+			ProviderOpts: opts,
 			Opts: aws.Opts{
 				AssumeRoleARN: *awsAssumeRoleARN,
+				MaxRetries:    *awsMaxRetries,
+				APITimeout:    *awsAPITimeout,
+				RetryMode:     *awsRetryMode,
+				VCPUQuota:     *awsVCPUQuota,
 			},
-		}.Build()
-	default:
-		return nil, errors.Errorf("provider %v does not exist", b.ProviderOpts.ProviderID)
-	}
+		}
+	},
+	gce.ProviderName: func(opts cloudprovider.BuildOpts) cloudprovider.Builder {
+		return gce.Builder{
+			ProviderOpts: opts,
+			Opts: gce.Opts{
+				APITimeout: *gceAPITimeout,
+			},
+		}
+	},
+	azure.ProviderName: func(opts cloudprovider.BuildOpts) cloudprovider.Builder {
+		return azure.Builder{
+			ProviderOpts: opts,
+			Opts: azure.Opts{
+				APITimeout:       *azureAPITimeout,
+				IdentityClientID: *azureIdentityClientID,
+			},
+		}
+	},
 }
 
 // setupCloudProvider creates the cloudprovider builder with the nodegroup opts
 func setupCloudProvider(nodegroups []controller.NodeGroupOptions) cloudprovider.Builder {
 	var nodeGroupConfigs []cloudprovider.NodeGroupConfig
 	for _, n := range nodegroups {
-		nodeGroupConfigs = append(nodeGroupConfigs, cloudprovider.NodeGroupConfig{
-			GroupID: n.CloudProviderGroupName,
-			AWSConfig: cloudprovider.AWSNodeGroupConfig{
-				LaunchTemplateID:          n.AWS.LaunchTemplateID,
-				LaunchTemplateVersion:     n.AWS.LaunchTemplateVersion,
-				FleetInstanceReadyTimeout: n.AWS.FleetInstanceReadyTimeoutDuration(),
-			},
-		})
+		// a node group backed by several groups registers each of them. GCE and Azure take the bounds of their groups from
+		// the config, so each of the groups can shrink to 0 and grow to max_nodes while escalator keeps their total
+		// within min_nodes and max_nodes
+		minSize := int64(n.MinNodes)
+		if len(n.CloudProviderGroupIDs()) > 1 {
+			minSize = 0
+		}
+		for _, groupID := range n.CloudProviderGroupIDs() {
+			nodeGroupConfigs = append(nodeGroupConfigs, setupNodeGroupConfig(n, groupID, minSize))
+		}
 	}
-	cloudBuilder := cloudProviderBuilder{
-		ProviderOpts: cloudprovider.BuildOpts{
-			ProviderID:       *cloudProviderID,
-			NodeGroupConfigs: nodeGroupConfigs,
+	return cloudProviders.Builder(cloudprovider.BuildOpts{
+		ProviderID:       *cloudProviderID,
+		NodeGroupConfigs: nodeGroupConfigs,
+	})
+}
+
+// setupNodeGroupConfig creates the cloud provider config of one of the groups backing the node group
+func setupNodeGroupConfig(n controller.NodeGroupOptions, groupID string, minSize int64) cloudprovider.NodeGroupConfig {
+	return cloudprovider.NodeGroupConfig{
+		GroupID:    groupID,
+		ProviderID: n.CloudProvider,
+		AWSConfig: cloudprovider.AWSNodeGroupConfig{
+			LaunchTemplateID:          n.AWS.LaunchTemplateID,
+			LaunchTemplateVersion:     n.AWS.LaunchTemplateVersion,
+			FleetInstanceReadyTimeout: n.AWS.FleetInstanceReadyTimeoutDuration(),
+			EKSClusterName:            n.AWS.EKSClusterName,
+			EKSNodegroupName:          n.AWS.EKSNodegroupName,
+		},
+		GCEConfig: cloudprovider.GCENodeGroupConfig{
+			MinSize: minSize,
+			MaxSize: int64(n.MaxNodes),
+		},
+		AzureConfig: cloudprovider.AzureNodeGroupConfig{
+			MinSize: minSize,
+			MaxSize: int64(n.MaxNodes),
 		},
 	}
-	return cloudBuilder
 }
 
-// setupNodeGroups reads and validates the nodegroupoptions
-func setupNodeGroups() ([]controller.NodeGroupOptions, error) {
-	// nodegroupConfigFile is required by kingpin. Won't get to here if it's not defined
+// loadConfig reads the nodegroupoptions and cluster options
+func loadConfig() (controller.Config, error) {
+	if len(*nodegroupConfigFile) == 0 {
+		return controller.Config{}, errors.New("--nodegroups is required")
+	}
 	configFile, err := os.Open(*nodegroupConfigFile)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to open configFile")
+		return controller.Config{}, errors.Wrap(err, "failed to open configFile")
 	}
-	nodegroups, err := controller.UnmarshalNodeGroupOptions(configFile)
+	defer configFile.Close()
+
+	config, err := controller.UnmarshalConfig(configFile)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to decode configFile")
+		return controller.Config{}, errorkind.Wrap(errorkind.Validation, err, "failed to decode configFile")
+	}
+	return config, nil
+}
+
+// setupConfig reads and validates the nodegroupoptions and cluster options
+func setupConfig() (controller.Config, error) {
+	config, err := loadConfig()
+	if err != nil {
+		return controller.Config{}, err
+	}
+	nodegroups := config.NodeGroups
+
+	// Validate the cluster options
+	if errs := controller.ValidateClusterOptions(config.ClusterOptions); len(errs) > 0 {
+		for _, err := range errs {
+			log.WithError(err).Error("failed check")
+		}
+		log.Fatalf("There are %v problems when validating the cluster options. Please check %v", len(errs), *nodegroupConfigFile)
 	}
 
 	// Validate each nodegroup options
@@ -108,23 +230,68 @@ func setupNodeGroups() ([]controller.NodeGroupOptions, error) {
 		}
 		log.WithField("nodegroup", nodegroup.Name).Info("Validating options: [PASS]")
 		log.WithField("nodegroup", nodegroup.Name).Infof("Registered with drymode %v", nodegroup.DryMode || *drymode)
+		if !nodegroup.DryMode && !*drymode && (nodegroup.DryTaint || nodegroup.DryDelete || nodegroup.DryScaleUp) {
+			log.WithField("nodegroup", nodegroup.Name).Infof(
+				"Registered with dry taint %v, dry delete %v, dry scale up %v",
+				nodegroup.DryTaint,
+				nodegroup.DryDelete,
+				nodegroup.DryScaleUp,
+			)
+		}
+		log.WithField("nodegroup", nodegroup.Name).Infof("Registered with audit mode %v", nodegroup.AuditMode || *auditMode)
+	}
+
+	// Validate the dependencies between the nodegroups
+	if errs := controller.ValidateNodeGroupDependencies(nodegroups); len(errs) > 0 {
+		for _, err := range errs {
+			log.WithError(err).Error("failed check")
+		}
+		log.Fatalf("There are %v problems when validating the node group dependencies. Please check %v", len(errs), *nodegroupConfigFile)
+	}
+
+	// Validate the pools of the nodegroups
+	if errs := controller.ValidateNodeGroupPools(nodegroups); len(errs) > 0 {
+		for _, err := range errs {
+			log.WithError(err).Error("failed check")
+		}
+		log.Fatalf("There are %v problems when validating the node group pools. Please check %v", len(errs), *nodegroupConfigFile)
+	}
+
+	// Validate the options that depend on the cloud provider of the nodegroups
+	if errs := controller.ValidateNodeGroupCloudProviders(nodegroups, *cloudProviderID); len(errs) > 0 {
+		for _, err := range errs {
+			log.WithError(err).Error("failed check")
+		}
+		log.Fatalf("There are %v problems when validating the node group cloud providers. Please check %v", len(errs), *nodegroupConfigFile)
 	}
 
-	return nodegroups, nil
+	return config, nil
+}
+
+// impersonationConfig returns the user and groups to impersonate from the --as and --as-group flags
+func impersonationConfig() rest.ImpersonationConfig {
+	return rest.ImpersonationConfig{
+		UserName: *impersonateUser,
+		Groups:   *impersonateGroups,
+	}
 }
 
 // setupK8SClient creates the incluster or out of cluster kubernetes config
-func setupK8SClient(kubeConfigFile *string, leaderElect *bool) (kubernetes.Interface, error) {
-	// if the kubeConfigFile is in the cmdline args then use the out of cluster config
-	if kubeConfigFile != nil && len(*kubeConfigFile) > 0 {
-		log.Info("Using out of cluster config")
+func setupK8SClient(kubeConfigFile *string, kubeContext *string, impersonate rest.ImpersonationConfig, leaderElect *bool) (kubernetes.Interface, error) {
+	if len(impersonate.UserName) > 0 || len(impersonate.Groups) > 0 {
+		log.WithField("user", impersonate.UserName).WithField("groups", impersonate.Groups).Info("Impersonating Kubernetes user")
+	}
+
+	// if the kubeConfigFile or context is in the cmdline args then use the out of cluster config
+	if len(*kubeConfigFile) > 0 || len(*kubeContext) > 0 {
+		log.WithField("context", *kubeContext).Info("Using out of cluster config")
 		if *leaderElect {
 			log.Warn("Doing leader election out of cluster is not recommended.")
 		}
-		return k8s.NewOutOfClusterClient(*kubeConfigFile)
+		return k8s.NewOutOfClusterClient(*kubeConfigFile, *kubeContext, impersonate)
 	}
 	log.Info("Using in cluster config")
-	return k8s.NewInClusterClient()
+	return k8s.NewInClusterClient(impersonate)
 }
 
 // awaitStopSignal awaits termination signals and shutdown gracefully
@@ -138,6 +305,62 @@ func awaitStopSignal(stopChan chan struct{}) {
 	close(stopChan)
 }
 
+// heartbeatIdentity is the holder identity written to the heartbeat lease. The pod name, falling back to the hostname
+func heartbeatIdentity() string {
+	if podName, ok := os.LookupEnv("POD_NAME"); ok {
+		return podName
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "escalator"
+	}
+	return hostname
+}
+
+// awaitControlSignals reloads the nodegroups config on SIGHUP and logs the controller diagnostics on SIGUSR1
+func awaitControlSignals(c *controller.Controller) {
+	signalChan := make(chan os.Signal, 1)
+	signal.Notify(signalChan, syscall.SIGHUP, syscall.SIGUSR1)
+	for sig := range signalChan {
+		log.Infof("Signal received: %v", sig)
+		switch sig {
+		case syscall.SIGHUP:
+			if err := reloadNodeGroups(c); err != nil {
+				log.WithError(err).Error("Failed to reload node groups. Keeping the current node groups")
+			}
+		case syscall.SIGUSR1:
+			c.RequestDiagnostics()
+		}
+	}
+}
+
+// reloadNodeGroups reads and validates the nodegroups config file and reloads the controller with it
+func reloadNodeGroups(c *controller.Controller) error {
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	nodegroups := config.NodeGroups
+
+	errs := controller.ValidateClusterOptions(config.ClusterOptions)
+	for _, nodegroup := range nodegroups {
+		for _, err := range controller.ValidateNodeGroup(nodegroup) {
+			errs = append(errs, errors.Wrapf(err, "nodegroup %v", nodegroup.Name))
+		}
+	}
+	errs = append(errs, controller.ValidateNodeGroupDependencies(nodegroups)...)
+	errs = append(errs, controller.ValidateNodeGroupPools(nodegroups)...)
+	errs = append(errs, controller.ValidateNodeGroupCloudProviders(nodegroups, *cloudProviderID)...)
+	if len(errs) > 0 {
+		for _, err := range errs {
+			log.WithError(err).Error("failed check")
+		}
+		return errorkind.New(errorkind.Validation, "there are %v problems when validating the options. Please check %v", len(errs), *nodegroupConfigFile)
+	}
+
+	return c.Reload(config, setupCloudProvider(nodegroups))
+}
+
 func awaitLeaderDeposed(leaderContext context.Context) {
 	// If the leader Context is finished, that's because we stopped leading.
 	// so we will crash.
@@ -147,8 +370,8 @@ func awaitLeaderDeposed(leaderContext context.Context) {
 
 }
 
-// startLeaderElection creates and starts the leader election
-func startLeaderElection(client kubernetes.Interface, resourceLockID string, config k8s.LeaderElectConfig) (context.Context, error) {
+// newEventRecorder starts an events recorder that logs and records events as the escalator component
+func newEventRecorder(client kubernetes.Interface) (record.EventRecorder, error) {
 	eventsScheme := runtime.NewScheme()
 	if err := coreV1.AddToScheme(eventsScheme); err != nil {
 		return nil, err
@@ -158,7 +381,26 @@ func startLeaderElection(client kubernetes.Interface, resourceLockID string, con
 	eventBroadcaster := record.NewBroadcaster()
 	eventBroadcaster.StartLogging(log.Infof)
 	eventBroadcaster.StartRecordingToSink(&clientcorev1.EventSinkImpl{Interface: clientcorev1.New(client.CoreV1().RESTClient()).Events("")})
-	recorder := eventBroadcaster.NewRecorder(eventsScheme, coreV1.EventSource{Component: "escalator"})
+	return eventBroadcaster.NewRecorder(eventsScheme, coreV1.EventSource{Component: "escalator"}), nil
+}
+
+// eventObject is the escalator pod that events are emitted on, from the POD_NAME and POD_NAMESPACE environment
+// variables. nil if either isn't set
+func eventObject() *coreV1.ObjectReference {
+	podName, podNameSet := os.LookupEnv("POD_NAME")
+	podNamespace, podNamespaceSet := os.LookupEnv("POD_NAMESPACE")
+	if !podNameSet || !podNamespaceSet {
+		return nil
+	}
+	return &coreV1.ObjectReference{APIVersion: "v1", Kind: "Pod", Namespace: podNamespace, Name: podName}
+}
+
+// startLeaderElection creates and starts the leader election
+func startLeaderElection(client kubernetes.Interface, resourceLockID string, config k8s.LeaderElectConfig) (context.Context, error) {
+	recorder, err := newEventRecorder(client)
+	if err != nil {
+		return nil, err
+	}
 
 	// Create leader elector
 	leaderElector, ctx, startedLeading, err := k8s.GetLeaderElector(context.Background(), config, client.CoreV1(), recorder, resourceLockID)
@@ -177,7 +419,7 @@ func startLeaderElection(client kubernetes.Interface, resourceLockID string, con
 
 func main() {
 
-	kingpin.Parse()
+	command := kingpin.Parse()
 
 	// setup logging
 	if *loglevel < 0 || *loglevel > 5 {
@@ -190,13 +432,64 @@ func main() {
 		log.SetFormatter(&log.JSONFormatter{})
 	}
 
+	// the node label domain applies to every command, e.g. migrate-taints only rewrites the taints of this instance
+	if err := k8s.SetNodeLabelDomain(*nodeLabelDomain); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	metrics.SetNodeLabelDomain(*nodeLabelDomain)
+
+	// kubernetes only impersonates groups on behalf of an impersonated user, for every command
+	if len(*impersonateGroups) > 0 && len(*impersonateUser) == 0 {
+		fmt.Fprintln(os.Stderr, "--as-group is only supported with --as")
+		os.Exit(1)
+	}
+
+	switch command {
+	case validateCommand.FullCommand():
+		os.Exit(runValidate())
+	case migrateTaintsCommand.FullCommand():
+		os.Exit(runMigrateTaints())
+	case selftestCommand.FullCommand():
+		os.Exit(runSelfTest())
+	case rbacCommand.FullCommand():
+		os.Exit(runRBAC())
+	case supportBundleCommand.FullCommand():
+		os.Exit(runSupportBundle())
+	}
+
 	log.Info("Starting with log level", log.GetLevel())
+	if len(k8s.NodeLabelDomain()) > 0 {
+		log.Infof("Segregated by node label domain %v. Tainting nodes with %v", k8s.NodeLabelDomain(), k8s.ToBeRemovedByAutoscalerKey)
+	}
+
+	if *scanJitter < 0 {
+		log.Fatalf("Invalid scan jitter %v provided. Must not be negative", *scanJitter)
+	}
+	if *scanIntervalMin > *scanInterval {
+		log.Fatalf("Invalid minimum scan interval %v provided. Must not be longer than the scan interval %v", *scanIntervalMin, *scanInterval)
+	}
+	if *scanIntervalMax > 0 && *scanIntervalMax < *scanInterval {
+		log.Fatalf("Invalid maximum scan interval %v provided. Must not be shorter than the scan interval %v", *scanIntervalMax, *scanInterval)
+	}
+	if *providerWriteQPS < 0 || *providerWriteBurst < 1 {
+		log.Fatalf("Invalid provider write limits %v qps and %v burst provided. The rate must not be negative and the burst must be at least 1", *providerWriteQPS, *providerWriteBurst)
+	}
+	if *providerWriteGroupShare < 1 || *providerWriteGroupShare > 100 {
+		log.Fatalf("Invalid provider write group share %v provided. Must be between 1 and 100", *providerWriteGroupShare)
+	}
+	if *runOnceMode != controller.RunModeFull && !*runOnce {
+		log.Fatalf("--once-mode %v is only supported with --once", *runOnceMode)
+	}
+	// seed the jitter so instances started at the same time don't share the same delays
+	rand.Seed(time.Now().UnixNano())
 
-	nodegroups, err := setupNodeGroups()
+	config, err := setupConfig()
 	if err != nil {
 		log.Fatal(err)
 	}
-	k8sClient, err := setupK8SClient(kubeConfigFile, leaderElect)
+	nodegroups := config.NodeGroups
+	k8sClient, err := setupK8SClient(kubeConfigFile, kubeContext, impersonationConfig(), leaderElect)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -213,8 +506,10 @@ func main() {
 	flag.Parse()
 	os.Args = tempArgs
 
-	// start serving metrics endpoint
-	metrics.Start(*addr)
+	// start serving metrics endpoint. a --once run exits before anything would scrape it
+	if !*runOnce {
+		metrics.Start(*addr)
+	}
 
 	// If leader election is enabled, do leader election or die
 	if *leaderElect {
@@ -231,7 +526,7 @@ func main() {
 			RenewDeadline: *leaderElectRenewDeadline,
 			RetryPeriod:   *leaderElectRetryPeriod,
 			Namespace:     *leaderElectConfigNamespace,
-			Name:          *leaderElectConfigName,
+			Name:          k8s.InstanceResourceName(*leaderElectConfigName),
 		})
 		if err != nil {
 			log.WithError(err).Fatal("Leader election returned an error")
@@ -246,14 +541,80 @@ func main() {
 	// create the controller and run in a loop until the stop signal
 	opts := controller.Opts{
 		ScanInterval:         *scanInterval,
+		MinScanInterval:      *scanIntervalMin,
+		MaxScanInterval:      *scanIntervalMax,
+		ScanJitter:           *scanJitter,
+		AlignScanInterval:    *scanAlign,
+		ShutdownDrainTimeout: *shutdownDrainTimeout,
 		K8SClient:            k8sClient,
 		NodeGroups:           nodegroups,
 		DryMode:              *drymode,
+		AuditMode:            *auditMode,
+		Cluster:              config.ClusterOptions,
 		CloudProviderBuilder: cloudBuilder,
+
+		HeartbeatLeaseName:      k8s.InstanceResourceName(*heartbeatLeaseName),
+		HeartbeatLeaseNamespace: *heartbeatLeaseNamespace,
+		HeartbeatIdentity:       heartbeatIdentity(),
+
+		ConfigHistorySize:         *configHistorySize,
+		WatchSchedulingFailures:   *schedulingFailures,
+		WatchPodDisruptionBudgets: *podDisruptionBudgets,
+
+		ProviderWriteLimits: controller.ProviderWriteLimits{
+			QPS:               *providerWriteQPS,
+			Burst:             *providerWriteBurst,
+			GroupSharePercent: *providerWriteGroupShare,
+		},
+	}
+	if *runOnce {
+		opts.RunMode = *runOnceMode
+	}
+	// guardrail activations and the scaling actions are emitted as events on the escalator pod
+	if object := eventObject(); object != nil {
+		recorder, err := newEventRecorder(k8sClient)
+		if err != nil {
+			log.Fatal(err)
+		}
+		opts.EventRecorder = recorder
+		opts.EventObject = object
+		opts.EventVerbosity = *eventVerbosity
+		opts.EventDedupWindow = *eventDedupWindow
+		opts.MaxNodeEventsPerRun = *maxNodeEventsPerRun
+	} else {
+		log.Info("POD_NAME or POD_NAMESPACE isn't set. Not emitting guardrail or scaling action events")
+	}
+	if *slowCycleProfileThreshold > 0 {
+		sink, err := profiling.NewSink(*slowCycleProfileSink)
+		if err != nil {
+			log.Fatal(err)
+		}
+		opts.SlowCycleProfiler = &profiling.SlowCycleProfiler{
+			Threshold:   *slowCycleProfileThreshold,
+			MinInterval: *slowCycleProfileInterval,
+			Sink:        sink,
+		}
 	}
 	c, err := controller.NewController(opts, stopChan)
 	if err != nil {
 		log.Fatal(err)
 	}
+	if *runOnce {
+		os.Exit(writeOnceSummary(c.RunOnceSummary()))
+	}
+	http.Handle("/healthz", c.HealthzHandler())
+	http.Handle("/report", c.ReportHandler())
+	http.Handle("/cycles", c.CyclesHandler())
+	http.Handle("/api/v1/config/history", c.ConfigHistoryHandler())
+	http.Handle("/api/v1/simulate", c.SimulateHandler())
+	if *configRollback {
+		http.Handle("/api/v1/config/rollback", c.ConfigRollbackHandler())
+	}
+	http.Handle(api.OpenAPIPath, api.OpenAPIHandler())
+	http.Handle(api.SupportBundlePath, c.SupportBundleHandler(metrics.Gatherer, version))
+	go awaitControlSignals(c)
+	if *nodegroupsWatchInterval > 0 {
+		go watchNodeGroupsConfig(func() error { return reloadNodeGroups(c) }, *nodegroupsWatchInterval, stopChan)
+	}
 	log.Fatal(c.RunForever(true))
 }
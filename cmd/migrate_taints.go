@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/atlassian/escalator/pkg/k8s"
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// runMigrateTaints rewrites the escalator taints on every node in the cluster with the current taint scheme.
+// returns the exit code of the command: 0 if every node was migrated, 1 otherwise
+func runMigrateTaints() int {
+	leaderElect := false
+	k8sClient, err := setupK8SClient(kubeConfigFile, kubeContext, impersonationConfig(), &leaderElect)
+	if err != nil {
+		log.WithError(err).Error("Failed to create kubernetes client")
+		return 1
+	}
+
+	nodes, err := k8sClient.CoreV1().Nodes().List(metav1.ListOptions{})
+	if err != nil {
+		log.WithError(err).Error("Failed to list nodes")
+		return 1
+	}
+
+	var migrated, failed int
+	for i := range nodes.Items {
+		node := &nodes.Items[i]
+		if !k8s.TaintNeedsMigration(node) {
+			continue
+		}
+		if *migrateTaintsDryRun {
+			fmt.Printf("would migrate %v\n", node.Name)
+			migrated++
+			continue
+		}
+		if _, _, err := k8s.MigrateToBeRemovedTaint(node, k8sClient); err != nil {
+			log.WithError(err).WithField("node", node.Name).Error("Failed to migrate taint")
+			failed++
+			continue
+		}
+		fmt.Printf("migrated %v\n", node.Name)
+		migrated++
+	}
+
+	if *migrateTaintsDryRun {
+		fmt.Printf("%v of %v nodes need migrating to taint schema version %v\n", migrated, len(nodes.Items), k8s.TaintSchemaVersion)
+		return 0
+	}
+	fmt.Printf("%v of %v nodes migrated to taint schema version %v, %v failed\n", migrated, len(nodes.Items), k8s.TaintSchemaVersion, failed)
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
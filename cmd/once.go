@@ -0,0 +1,24 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/atlassian/escalator/pkg/controller"
+	log "github.com/sirupsen/logrus"
+)
+
+// writeOnceSummary prints the summary of a --once run as json.
+// returns the exit code of the run: 0 if the run and every node group passed, 1 otherwise
+func writeOnceSummary(summary controller.OnceSummary) int {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(summary); err != nil {
+		log.WithError(err).Error("failed to write run summary")
+		return 1
+	}
+	if !summary.Passed {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/atlassian/escalator/pkg/controller"
+	"github.com/atlassian/escalator/pkg/k8s"
+	log "github.com/sirupsen/logrus"
+	coreV1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// rbacLabels are set on every generated object
+var rbacLabels = map[string]string{
+	"k8s-addon": "escalator.addons.k8s.io",
+	"k8s-app":   "escalator",
+}
+
+// rbacFeatures returns the features that need permissions from the flags and, when --nodegroups is set, the node
+// group config. Without a config every node group feature is assumed to be used
+func rbacFeatures() (k8s.RBACFeatures, error) {
+	features := k8s.RBACFeatures{
+		Namespace:            *rbacNamespace,
+		NodeEvents:           *eventVerbosity == controller.EventVerbosityNode,
+		SchedulingFailures:   *schedulingFailures,
+		PodDisruptionBudgets: *podDisruptionBudgets,
+	}
+	if *leaderElect {
+		features.LeaderElectionNamespace = *leaderElectConfigNamespace
+		features.LeaderElectionName = k8s.InstanceResourceName(*leaderElectConfigName)
+	}
+	if len(*heartbeatLeaseName) > 0 {
+		features.HeartbeatLeaseNamespace = *heartbeatLeaseNamespace
+		features.HeartbeatLeaseName = k8s.InstanceResourceName(*heartbeatLeaseName)
+	}
+
+	if len(*nodegroupConfigFile) == 0 {
+		log.Warn("--nodegroups isn't set, including the permissions of every node group feature")
+		features.Kueue = true
+		features.Volcano = true
+		features.DaemonSetDrainNamespaces = []string{""}
+		return features, nil
+	}
+	config, err := loadConfig()
+	if err != nil {
+		return features, err
+	}
+	if len(config.PriorityExpander.Name) > 0 {
+		features.PriorityExpanderNamespace = config.PriorityExpander.ConfigMapNamespace()
+		features.PriorityExpanderName = config.PriorityExpander.Name
+	}
+	for _, nodegroup := range config.NodeGroups {
+		switch nodegroup.QueueDemand.Provider {
+		case controller.QueueDemandProviderKueue:
+			features.Kueue = true
+		case controller.QueueDemandProviderVolcano:
+			features.Volcano = true
+		}
+		for _, daemonSet := range nodegroup.DaemonSetDrain.DaemonSets {
+			features.DaemonSetDrainNamespaces = append(features.DaemonSetDrainNamespaces, strings.SplitN(daemonSet, "/", 2)[0])
+		}
+	}
+	return features, nil
+}
+
+// rbacObjects returns the service account, roles and bindings that grant the rules
+func rbacObjects(rules k8s.RBACRules) []interface{} {
+	name := *rbacServiceAccount
+	subjects := []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: name, Namespace: *rbacNamespace}}
+
+	objects := []interface{}{
+		coreV1.ServiceAccount{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ServiceAccount"},
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: *rbacNamespace, Labels: rbacLabels},
+		},
+		rbacv1.ClusterRole{
+			TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "ClusterRole"},
+			ObjectMeta: metav1.ObjectMeta{Name: name, Labels: rbacLabels},
+			Rules:      rules.Cluster,
+		},
+		rbacv1.ClusterRoleBinding{
+			TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "ClusterRoleBinding"},
+			ObjectMeta: metav1.ObjectMeta{Name: name, Labels: rbacLabels},
+			RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: name},
+			Subjects:   subjects,
+		},
+	}
+	for _, namespace := range rules.Namespaces() {
+		objects = append(objects,
+			rbacv1.Role{
+				TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "Role"},
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: rbacLabels},
+				Rules:      rules.Namespaced[namespace],
+			},
+			rbacv1.RoleBinding{
+				TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "RoleBinding"},
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: rbacLabels},
+				RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: name},
+				Subjects:   subjects,
+			},
+		)
+	}
+	return objects
+}
+
+// runRBAC prints the minimal RBAC manifests for the features escalator is run with.
+// returns the exit code of the command
+func runRBAC() int {
+	features, err := rbacFeatures()
+	if err != nil {
+		log.WithError(err).Error("Failed to read the node group config")
+		return 1
+	}
+
+	for i, object := range rbacObjects(k8s.BuildRBACRules(features)) {
+		manifest, err := yaml.Marshal(object)
+		if err != nil {
+			log.WithError(err).Error("Failed to encode the RBAC manifests")
+			return 1
+		}
+		if i > 0 {
+			fmt.Println("---")
+		}
+		fmt.Print(string(manifest))
+	}
+	return 0
+}
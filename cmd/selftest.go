@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/atlassian/escalator/pkg/controller"
+	"github.com/atlassian/escalator/pkg/k8s"
+	coreV1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+)
+
+// runSelfTest exercises the permissions escalator needs against the cluster and cloud provider, without scaling
+// anything. returns the exit code of the command: 0 if every check passed, 1 otherwise
+func runSelfTest() int {
+	report := validationReport{Passed: true, Results: []validationResult{}}
+
+	config, err := loadConfig()
+	report.add("", "config", err)
+	if err != nil {
+		return report.write(*selftestOutput)
+	}
+
+	// the node groups match nodes, and the cloud provider node groups can be described and managed
+	validateCluster(&report, config.NodeGroups)
+
+	leaderElect := false
+	k8sClient, err := setupK8SClient(kubeConfigFile, kubeContext, impersonationConfig(), &leaderElect)
+	// the client error is already reported by validateCluster
+	if err == nil {
+		selfTestNodes(&report, k8sClient, config.NodeGroups)
+	}
+	return report.write(*selftestOutput)
+}
+
+// selfTestNodes checks pods can be listed and nodes can be tainted and deleted. The test node is tainted for real with
+// a no-op taint when --node is set, otherwise a node of the first node group is only updated and deleted in dry run
+func selfTestNodes(report *validationReport, client kubernetes.Interface, nodegroups []controller.NodeGroupOptions) {
+	_, err := client.CoreV1().Pods("").List(metav1.ListOptions{Limit: 1})
+	report.add("", "list pods", err)
+
+	node, err := selfTestNode(client, nodegroups)
+	report.add("", "find test node", err)
+	if err != nil {
+		return
+	}
+
+	if len(*selftestNode) > 0 {
+		node, err = k8s.AddSelfTestTaint(node, client)
+		report.add("", fmt.Sprintf("taint node %v", node.Name), err)
+		if err == nil {
+			_, err = k8s.DeleteSelfTestTaint(node, client)
+			report.add("", fmt.Sprintf("untaint node %v", node.Name), err)
+		}
+	} else {
+		report.add("", fmt.Sprintf("update node %v (dry run)", node.Name), k8s.DryRunPatchNode(node, client))
+	}
+	report.add("", fmt.Sprintf("delete node %v (dry run)", node.Name), k8s.DryRunDeleteNode(node, client))
+}
+
+// selfTestNode returns the node given by --node, or else a node of the first node group
+func selfTestNode(client kubernetes.Interface, nodegroups []controller.NodeGroupOptions) (*coreV1.Node, error) {
+	if len(*selftestNode) > 0 {
+		return client.CoreV1().Nodes().Get(*selftestNode, metav1.GetOptions{})
+	}
+	if len(nodegroups) == 0 {
+		return nil, fmt.Errorf("no node groups to pick a test node from")
+	}
+	selector := labels.SelectorFromSet(labels.Set{nodegroups[0].LabelKey: nodegroups[0].LabelValue})
+	nodes, err := client.CoreV1().Nodes().List(metav1.ListOptions{LabelSelector: selector.String(), Limit: 1})
+	if err != nil {
+		return nil, err
+	}
+	if len(nodes.Items) == 0 {
+		return nil, fmt.Errorf("no nodes match %v", selector)
+	}
+	return &nodes.Items[0], nil
+}
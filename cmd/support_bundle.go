@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/atlassian/escalator/pkg/api"
+	log "github.com/sirupsen/logrus"
+)
+
+// runSupportBundle downloads the support bundle of a running escalator to the --output file, or stdout with -.
+// returns the exit code of the command
+func runSupportBundle() int {
+	output := *supportBundleOutput
+	if len(output) == 0 {
+		output = api.SupportBundleFileName(time.Now())
+	}
+
+	var w io.Writer = os.Stdout
+	if output != "-" {
+		file, err := os.Create(output)
+		if err != nil {
+			log.WithError(err).Error("Failed to create the support bundle file")
+			return 1
+		}
+		defer file.Close()
+		w = file
+	}
+
+	client := api.NewClient(*supportBundleURL, nil)
+	if err := client.SupportBundle(w); err != nil {
+		log.WithError(err).Errorf("Failed to download the support bundle from %v", *supportBundleURL)
+		if output != "-" {
+			os.Remove(output)
+		}
+		return 1
+	}
+	if output != "-" {
+		fmt.Printf("Support bundle written to %v\n", output)
+	}
+	return 0
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/atlassian/escalator/pkg/cloudprovider"
+	"github.com/atlassian/escalator/pkg/controller"
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+)
+
+// validationResult is the result of a single validation check
+type validationResult struct {
+	NodeGroup string `json:"node_group,omitempty"`
+	Check     string `json:"check"`
+	Passed    bool   `json:"passed"`
+	Message   string `json:"message,omitempty"`
+}
+
+// validationReport is the machine readable output of the validate command
+type validationReport struct {
+	Passed  bool               `json:"passed"`
+	Results []validationResult `json:"results"`
+}
+
+func (r *validationReport) add(nodeGroup string, check string, err error) {
+	result := validationResult{
+		NodeGroup: nodeGroup,
+		Check:     check,
+		Passed:    err == nil,
+	}
+	if err != nil {
+		result.Message = err.Error()
+		r.Passed = false
+	}
+	r.Results = append(r.Results, result)
+}
+
+// runValidate validates the node group config, and optionally the cluster and cloud provider it is used against.
+// returns the exit code of the command: 0 if every check passed, 1 otherwise
+func runValidate() int {
+	report := validationReport{Passed: true, Results: []validationResult{}}
+
+	config, err := loadConfig()
+	report.add("", "config", err)
+	if err == nil {
+		nodegroups := config.NodeGroups
+		errs := controller.ValidateClusterOptions(config.ClusterOptions)
+		if len(errs) == 0 {
+			report.add("", "cluster options", nil)
+		}
+		for _, err := range errs {
+			report.add("", "cluster options", err)
+		}
+
+		for _, nodegroup := range nodegroups {
+			errs := controller.ValidateNodeGroup(nodegroup)
+			if len(errs) == 0 {
+				report.add(nodegroup.Name, "options", nil)
+			}
+			for _, err := range errs {
+				report.add(nodegroup.Name, "options", err)
+			}
+		}
+		errs = controller.ValidateNodeGroupDependencies(nodegroups)
+		if len(errs) == 0 {
+			report.add("", "dependencies", nil)
+		}
+		for _, err := range errs {
+			report.add("", "dependencies", err)
+		}
+
+		errs = controller.ValidateNodeGroupPools(nodegroups)
+		if len(errs) == 0 {
+			report.add("", "pools", nil)
+		}
+		for _, err := range errs {
+			report.add("", "pools", err)
+		}
+
+		errs = controller.ValidateNodeGroupCloudProviders(nodegroups, *cloudProviderID)
+		if len(errs) == 0 {
+			report.add("", "cloud providers", nil)
+		}
+		for _, err := range errs {
+			report.add("", "cloud providers", err)
+		}
+
+		if *validateAgainstCluster {
+			validateCluster(&report, nodegroups)
+		}
+	}
+
+	return report.write(*validateOutput)
+}
+
+// write prints the report as text or json.
+// returns the exit code of the command: 0 if every check passed, 1 otherwise
+func (r *validationReport) write(output string) int {
+	if output == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(r); err != nil {
+			log.WithError(err).Error("failed to write validation report")
+			return 1
+		}
+	} else {
+		for _, result := range r.Results {
+			status := "PASS"
+			if !result.Passed {
+				status = "FAIL"
+			}
+			fmt.Printf("[%v] %v %v %v\n", status, result.NodeGroup, result.Check, result.Message)
+		}
+	}
+
+	if !r.Passed {
+		return 1
+	}
+	return 0
+}
+
+// validateCluster checks that the node groups match nodes in the cluster and that the cloud provider node groups
+// exist and can be managed
+func validateCluster(report *validationReport, nodegroups []controller.NodeGroupOptions) {
+	leaderElect := false
+	k8sClient, err := setupK8SClient(kubeConfigFile, kubeContext, impersonationConfig(), &leaderElect)
+	report.add("", "kubernetes client", err)
+	if err == nil {
+		for _, nodegroup := range nodegroups {
+			report.add(nodegroup.Name, "label selector matches nodes", checkNodesMatch(k8sClient, nodegroup))
+		}
+	}
+
+	cloud, err := setupCloudProvider(nodegroups).Build()
+	report.add("", "cloud provider", err)
+	if err != nil {
+		return
+	}
+	for _, nodegroup := range nodegroups {
+		for _, groupID := range nodegroup.CloudProviderGroupIDs() {
+			var err error
+			if _, ok := cloud.GetNodeGroup(groupID); !ok {
+				err = fmt.Errorf("could not find node group %q on cloud provider", groupID)
+			}
+			report.add(nodegroup.Name, "cloud provider node group exists", err)
+		}
+	}
+
+	checker, ok := cloud.(cloudprovider.PermissionChecker)
+	if !ok {
+		return
+	}
+	errs := checker.CheckPermissions(context.Background())
+	if len(errs) == 0 {
+		report.add("", "cloud provider permissions", nil)
+	}
+	for _, err := range errs {
+		report.add("", "cloud provider permissions", err)
+	}
+}
+
+// checkNodesMatch returns an error if the label selector of the node group doesn't match any nodes
+func checkNodesMatch(client kubernetes.Interface, nodegroup controller.NodeGroupOptions) error {
+	selector := labels.SelectorFromSet(labels.Set{nodegroup.LabelKey: nodegroup.LabelValue})
+	nodes, err := client.CoreV1().Nodes().List(metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return err
+	}
+	if len(nodes.Items) == 0 {
+		return fmt.Errorf("no nodes match %v", selector)
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// StatusError is a response of the admin API with an unexpected status code
+type StatusError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("escalator responded with %v: %v", e.StatusCode, e.Message)
+}
+
+// Client calls the admin API of escalator, following the operations of OpenAPISpec
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewClient creates a client of the admin API served at the base url, e.g. http://escalator:8080. The default http
+// client is used when httpClient is nil
+func NewClient(baseURL string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{baseURL: strings.TrimRight(baseURL, "/"), httpClient: httpClient}
+}
+
+// Healthz returns nil while the last successful run of escalator is recent, and a *StatusError otherwise
+func (c *Client) Healthz() error {
+	return c.get("/healthz", nil, nil)
+}
+
+// Report returns the latest report of escalator
+func (c *Client) Report() (Report, error) {
+	var report Report
+	err := c.get("/report", nil, &report)
+	return report, err
+}
+
+// Cycles returns the latest cycle summaries of each node group, oldest first
+func (c *Client) Cycles() (map[string][]CycleSummary, error) {
+	var cycles map[string][]CycleSummary
+	err := c.get("/cycles", nil, &cycles)
+	return cycles, err
+}
+
+// NodeGroupCycles returns the latest cycle summaries of the node group, oldest first. A *StatusError with
+// http.StatusNotFound is returned when the node group isn't configured
+func (c *Client) NodeGroupCycles(nodeGroup string) ([]CycleSummary, error) {
+	var cycles map[string][]CycleSummary
+	if err := c.get("/cycles", url.Values{"nodegroup": {nodeGroup}}, &cycles); err != nil {
+		return nil, err
+	}
+	return cycles[nodeGroup], nil
+}
+
+// ConfigHistory returns the latest node group configs applied by escalator, oldest first
+func (c *Client) ConfigHistory() ([]ConfigRevision, error) {
+	var history []ConfigRevision
+	err := c.get("/api/v1/config/history", nil, &history)
+	return history, err
+}
+
+// Rollback applies the config of the revision in the history again, and returns the revision created by the
+// rollback. Escalator must be run with --config-rollback
+func (c *Client) Rollback(revision int) (ConfigRevision, error) {
+	var rolledBack ConfigRevision
+	err := c.do(http.MethodPost, "/api/v1/config/rollback", url.Values{"revision": {strconv.Itoa(revision)}}, &rolledBack)
+	return rolledBack, err
+}
+
+// Simulate returns the outcome of a run of each node group of the request under its changed options, next to the
+// outcome under its current options. Nothing is changed by a simulation. A *StatusError with http.StatusBadRequest is
+// returned for invalid options, and with http.StatusNotFound when a node group isn't configured
+func (c *Client) Simulate(request SimulationRequest) (SimulationResult, error) {
+	var result SimulationResult
+	body, err := json.Marshal(request)
+	if err != nil {
+		return result, errors.Wrap(err, "failed to encode simulation request")
+	}
+	err = c.doWithBody(http.MethodPost, "/api/v1/simulate", nil, body, &result)
+	return result, err
+}
+
+// SupportBundle writes the support bundle of escalator, a gzipped tarball, to w
+func (c *Client) SupportBundle(w io.Writer) error {
+	resp, err := c.send(http.MethodGet, SupportBundlePath, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, err = io.Copy(w, resp.Body)
+	return errors.Wrapf(err, "failed to read %v", SupportBundlePath)
+}
+
+// get requests the path and decodes the json response into out, unless out is nil
+func (c *Client) get(path string, query url.Values, out interface{}) error {
+	return c.do(http.MethodGet, path, query, out)
+}
+
+// do sends a request without a body to the path and decodes the json response into out, unless out is nil
+func (c *Client) do(method string, path string, query url.Values, out interface{}) error {
+	return c.doWithBody(method, path, query, nil, out)
+}
+
+// doWithBody sends a request with the json body to the path, or without a body when it is nil, and decodes the json
+// response into out, unless out is nil
+func (c *Client) doWithBody(method string, path string, query url.Values, body []byte, out interface{}) error {
+	resp, err := c.send(method, path, query, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return errors.Wrapf(err, "failed to decode %v", path)
+	}
+	return nil
+}
+
+// send sends a request with the json body to the path, or without a body when it is nil. The response is only
+// returned with a 200 status code, the caller must close its body
+func (c *Client) send(method string, path string, query url.Values, body []byte) (*http.Response, error) {
+	u := c.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(method, u, reader)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create request for %v", path)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to %v %v", method, path)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		return nil, &StatusError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(body))}
+	}
+	return resp, nil
+}
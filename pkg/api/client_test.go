@@ -0,0 +1,145 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient(t *testing.T) {
+	lastRun := time.Date(2019, 3, 1, 3, 12, 0, 0, time.UTC)
+	report := Report{
+		Discovery:         DiscoveryReport{UnmatchedNodes: []string{"n1"}, EmptyNodeGroups: []string{}},
+		LastSuccessfulRun: lastRun,
+		StuckDeletions:    []StuckDeletion{{NodeGroup: "shared", Node: "n2", Attempts: 10, LastError: "stuck"}},
+	}
+	cycles := map[string][]CycleSummary{
+		"shared": {{ID: "20190301T031200Z", Time: lastRun, Nodes: 10, Decision: "scale_down", NodesDelta: -2}},
+		"gpu":    {},
+	}
+	config := json.RawMessage(`{"node_groups":[{"name":"shared","max_nodes":20}]}`)
+	history := []ConfigRevision{
+		{Revision: 1, Time: lastRun, Source: "startup", Config: config, Changes: []ConfigChange{}},
+		{
+			Revision: 2,
+			Time:     lastRun,
+			Source:   "reload",
+			Config:   config,
+			Changes:  []ConfigChange{{Path: "node_groups.shared.max_nodes", Old: "10", New: "20"}},
+		},
+	}
+	healthy := true
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		if !healthy {
+			http.Error(w, "last successful run was at 2019-03-01", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	})
+	mux.HandleFunc("/report", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(report)
+	})
+	mux.HandleFunc("/cycles", func(w http.ResponseWriter, r *http.Request) {
+		if name := r.URL.Query().Get("nodegroup"); len(name) > 0 {
+			nodeGroupCycles, ok := cycles[name]
+			if !ok {
+				http.Error(w, "node group not found", http.StatusNotFound)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string][]CycleSummary{name: nodeGroupCycles})
+			return
+		}
+		json.NewEncoder(w).Encode(cycles)
+	})
+	mux.HandleFunc("/api/v1/config/history", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(history)
+	})
+	mux.HandleFunc("/api/v1/config/rollback", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Query().Get("revision") != "1" {
+			http.Error(w, "revision not found", http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(ConfigRevision{Revision: 3, Source: "rollback", RollbackOf: 1})
+	})
+	mux.HandleFunc(SupportBundlePath, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/gzip")
+		w.Write([]byte("bundle"))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := NewClient(server.URL+"/", nil)
+
+	t.Run("healthz", func(t *testing.T) {
+		assert.NoError(t, client.Healthz())
+
+		healthy = false
+		defer func() { healthy = true }()
+		err := client.Healthz()
+		require.Error(t, err)
+		statusErr, ok := err.(*StatusError)
+		require.True(t, ok)
+		assert.Equal(t, http.StatusServiceUnavailable, statusErr.StatusCode)
+		assert.Equal(t, "last successful run was at 2019-03-01", statusErr.Message)
+	})
+
+	t.Run("report", func(t *testing.T) {
+		got, err := client.Report()
+		require.NoError(t, err)
+		assert.Equal(t, report, got)
+	})
+
+	t.Run("cycles", func(t *testing.T) {
+		got, err := client.Cycles()
+		require.NoError(t, err)
+		assert.Equal(t, cycles, got)
+	})
+
+	t.Run("node group cycles", func(t *testing.T) {
+		got, err := client.NodeGroupCycles("shared")
+		require.NoError(t, err)
+		assert.Equal(t, cycles["shared"], got)
+
+		_, err = client.NodeGroupCycles("missing")
+		require.Error(t, err)
+		statusErr, ok := err.(*StatusError)
+		require.True(t, ok)
+		assert.Equal(t, http.StatusNotFound, statusErr.StatusCode)
+	})
+
+	t.Run("config history", func(t *testing.T) {
+		got, err := client.ConfigHistory()
+		require.NoError(t, err)
+		assert.Equal(t, history, got)
+	})
+
+	t.Run("rollback", func(t *testing.T) {
+		got, err := client.Rollback(1)
+		require.NoError(t, err)
+		assert.Equal(t, 3, got.Revision)
+		assert.Equal(t, 1, got.RollbackOf)
+
+		_, err = client.Rollback(5)
+		require.Error(t, err)
+		assert.Equal(t, http.StatusNotFound, err.(*StatusError).StatusCode)
+	})
+
+	t.Run("support bundle", func(t *testing.T) {
+		var bundle bytes.Buffer
+		require.NoError(t, client.SupportBundle(&bundle))
+		assert.Equal(t, "bundle", bundle.String())
+	})
+
+	t.Run("unreachable", func(t *testing.T) {
+		_, err := NewClient("http://127.0.0.1:1", nil).Report()
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,355 @@
+// Package api describes the admin API escalator serves next to its metrics, i.e. /healthz, /report, /cycles, the
+// config history and the support bundle, with an OpenAPI document and a client for tooling that integrates with it
+package api
+
+import (
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// OpenAPIPath is the path the OpenAPI document is served at
+const OpenAPIPath = "/api/openapi.json"
+
+// OpenAPISpec is the OpenAPI 3 document of the admin API. The schemas mirror the json of the response types of this
+// package and of the controller types they are served from, which is checked by the tests of this package
+const OpenAPISpec = `{
+  "openapi": "3.0.0",
+  "info": {
+    "title": "Escalator admin API",
+    "description": "The state of escalator and the latest runs of its node groups, served on the --address of the metrics",
+    "version": "1.0.0"
+  },
+  "paths": {
+    "/healthz": {
+      "get": {
+        "operationId": "healthz",
+        "summary": "Whether the last successful run is recent",
+        "responses": {
+          "200": {
+            "description": "The last successful run was less than three scan intervals ago",
+            "content": {"text/plain": {"schema": {"type": "string"}}}
+          },
+          "503": {
+            "description": "No run has finished successfully for more than three scan intervals",
+            "content": {"text/plain": {"schema": {"type": "string"}}}
+          }
+        }
+      }
+    },
+    "/report": {
+      "get": {
+        "operationId": "report",
+        "summary": "The state of escalator, updated at the end of every run",
+        "responses": {
+          "200": {
+            "description": "The latest report",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Report"}}}
+          }
+        }
+      }
+    },
+    "/cycles": {
+      "get": {
+        "operationId": "cycles",
+        "summary": "The summaries of the latest runs of each node group, oldest first",
+        "parameters": [
+          {
+            "name": "nodegroup",
+            "in": "query",
+            "description": "Only return the runs of this node group",
+            "required": false,
+            "schema": {"type": "string"}
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "The cycle summaries by node group",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "object",
+                  "additionalProperties": {"type": "array", "items": {"$ref": "#/components/schemas/CycleSummary"}}
+                }
+              }
+            }
+          },
+          "404": {
+            "description": "The node group isn't configured",
+            "content": {"text/plain": {"schema": {"type": "string"}}}
+          }
+        }
+      }
+    },
+    "/api/v1/config/history": {
+      "get": {
+        "operationId": "configHistory",
+        "summary": "The latest node group configs applied, oldest first",
+        "responses": {
+          "200": {
+            "description": "The config revisions",
+            "content": {
+              "application/json": {
+                "schema": {"type": "array", "items": {"$ref": "#/components/schemas/ConfigRevision"}}
+              }
+            }
+          }
+        }
+      }
+    },
+    "/api/v1/config/rollback": {
+      "post": {
+        "operationId": "configRollback",
+        "summary": "Apply the config of a revision in the history again. Only served with --config-rollback",
+        "parameters": [
+          {
+            "name": "revision",
+            "in": "query",
+            "description": "The revision to roll back to",
+            "required": true,
+            "schema": {"type": "integer"}
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "The revision created by the rollback",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/ConfigRevision"}}}
+          },
+          "400": {
+            "description": "The revision isn't a number",
+            "content": {"text/plain": {"schema": {"type": "string"}}}
+          },
+          "404": {
+            "description": "The revision isn't in the history, or rollbacks aren't enabled",
+            "content": {"text/plain": {"schema": {"type": "string"}}}
+          },
+          "500": {
+            "description": "The config failed to be applied, the current config is kept",
+            "content": {"text/plain": {"schema": {"type": "string"}}}
+          }
+        }
+      }
+    },
+    "/api/v1/simulate": {
+      "post": {
+        "operationId": "simulate",
+        "summary": "The decisions the current state of the node groups would produce under changed node group options, next to those under the current options. Nothing is changed",
+        "requestBody": {
+          "required": true,
+          "content": {"application/json": {"schema": {"$ref": "#/components/schemas/SimulationRequest"}}}
+        },
+        "responses": {
+          "200": {
+            "description": "The simulated outcomes, taken once the current run has finished",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/SimulationResult"}}}
+          },
+          "400": {
+            "description": "The request or the changed options are invalid",
+            "content": {"text/plain": {"schema": {"type": "string"}}}
+          },
+          "404": {
+            "description": "A node group isn't configured",
+            "content": {"text/plain": {"schema": {"type": "string"}}}
+          },
+          "500": {
+            "description": "The simulation failed",
+            "content": {"text/plain": {"schema": {"type": "string"}}}
+          }
+        }
+      }
+    },
+    "/api/v1/support-bundle": {
+      "get": {
+        "operationId": "supportBundle",
+        "summary": "A gzipped tarball of the redacted config, config history, node group states, latest cycles, metrics and version, for bug reports",
+        "responses": {
+          "200": {
+            "description": "The support bundle, taken once the current run has finished",
+            "content": {"application/gzip": {"schema": {"type": "string", "format": "binary"}}}
+          },
+          "503": {
+            "description": "Escalator is stopping",
+            "content": {"text/plain": {"schema": {"type": "string"}}}
+          }
+        }
+      }
+    }
+  },
+  "components": {
+    "schemas": {
+      "Report": {
+        "type": "object",
+        "properties": {
+          "discovery": {"$ref": "#/components/schemas/DiscoveryReport"},
+          "last_successful_run": {"type": "string", "format": "date-time"},
+          "stuck_deletions": {"type": "array", "items": {"$ref": "#/components/schemas/StuckDeletion"}},
+          "disruption_scores": {"type": "array", "items": {"$ref": "#/components/schemas/NodeDisruptionScore"}},
+          "scheduling_failures": {"type": "array", "items": {"$ref": "#/components/schemas/SchedulingFailure"}},
+          "reservations": {"type": "array", "items": {"$ref": "#/components/schemas/ActiveReservation"}}
+        }
+      },
+      "DiscoveryReport": {
+        "type": "object",
+        "properties": {
+          "unmatched_nodes": {"type": "array", "items": {"type": "string"}},
+          "empty_node_groups": {"type": "array", "items": {"type": "string"}}
+        }
+      },
+      "StuckDeletion": {
+        "type": "object",
+        "properties": {
+          "node_group": {"type": "string"},
+          "node": {"type": "string"},
+          "attempts": {"type": "integer"},
+          "last_error": {"type": "string"}
+        }
+      },
+      "NodeDisruptionScore": {
+        "type": "object",
+        "properties": {
+          "node_group": {"type": "string"},
+          "node": {"type": "string"},
+          "score": {"type": "number"},
+          "pods": {"type": "integer"}
+        }
+      },
+      "SchedulingFailure": {
+        "type": "object",
+        "properties": {
+          "node_group": {"type": "string"},
+          "namespace": {"type": "string"},
+          "pod": {"type": "string"},
+          "message": {"type": "string"},
+          "count": {"type": "integer"},
+          "time": {"type": "string", "format": "date-time"}
+        }
+      },
+      "ActiveReservation": {
+        "type": "object",
+        "properties": {
+          "node_group": {"type": "string"},
+          "name": {"type": "string"},
+          "tenant": {"type": "string"},
+          "nodes": {"type": "integer"},
+          "from": {"type": "string", "format": "date-time"},
+          "until": {"type": "string", "format": "date-time"}
+        }
+      },
+      "ConfigRevision": {
+        "type": "object",
+        "properties": {
+          "revision": {"type": "integer"},
+          "time": {"type": "string", "format": "date-time"},
+          "source": {"type": "string", "enum": ["startup", "reload", "rollback"]},
+          "rollback_of": {"type": "integer"},
+          "config": {
+            "type": "object",
+            "description": "The cluster options and node groups, as in the nodegroups config file"
+          },
+          "changes": {"type": "array", "items": {"$ref": "#/components/schemas/ConfigChange"}}
+        }
+      },
+      "ConfigChange": {
+        "type": "object",
+        "properties": {
+          "path": {"type": "string"},
+          "old": {"type": "string"},
+          "new": {"type": "string"}
+        }
+      },
+      "SimulationRequest": {
+        "type": "object",
+        "properties": {
+          "node_groups": {
+            "type": "object",
+            "description": "The options that change by node group name, applied over the current options of the node group",
+            "additionalProperties": {"type": "object"}
+          }
+        }
+      },
+      "SimulationResult": {
+        "type": "object",
+        "properties": {
+          "time": {"type": "string", "format": "date-time"},
+          "node_groups": {"type": "array", "items": {"$ref": "#/components/schemas/NodeGroupSimulation"}}
+        }
+      },
+      "NodeGroupSimulation": {
+        "type": "object",
+        "properties": {
+          "node_group": {"type": "string"},
+          "changes": {"type": "array", "items": {"$ref": "#/components/schemas/ConfigChange"}},
+          "current": {"$ref": "#/components/schemas/SimulatedOutcome"},
+          "simulated": {"$ref": "#/components/schemas/SimulatedOutcome"}
+        }
+      },
+      "SimulatedOutcome": {
+        "type": "object",
+        "properties": {
+          "decision": {
+            "type": "string",
+            "enum": ["none", "scale_up", "scale_down", "scale_to_minimum", "locked", "skipped"]
+          },
+          "nodes_delta": {"type": "integer"},
+          "cpu_percent": {"type": "number"},
+          "mem_percent": {"type": "number"},
+          "pods": {"type": "integer"},
+          "nodes": {"type": "integer"},
+          "untainted_nodes": {"type": "integer"},
+          "tainted_nodes": {"type": "integer"},
+          "candidates": {"type": "array", "items": {"type": "string"}},
+          "nodes_to_add": {"type": "integer"},
+          "limits": {"type": "array", "items": {"type": "string"}},
+          "error": {"type": "string"}
+        }
+      },
+      "CycleSummary": {
+        "type": "object",
+        "properties": {
+          "id": {"type": "string"},
+          "time": {"type": "string", "format": "date-time"},
+          "pods": {"type": "integer"},
+          "nodes": {"type": "integer"},
+          "untainted_nodes": {"type": "integer"},
+          "tainted_nodes": {"type": "integer"},
+          "cordoned_nodes": {"type": "integer"},
+          "shutting_down_nodes": {"type": "integer"},
+          "starting_nodes": {"type": "integer"},
+          "cpu_percent": {"type": "number"},
+          "mem_percent": {"type": "number"},
+          "heterogeneous_allocatable": {"type": "boolean"},
+          "decision": {
+            "type": "string",
+            "enum": ["none", "scale_up", "scale_down", "scale_to_minimum", "locked", "quota_blocked", "spot_interruption", "skipped"]
+          },
+          "nodes_delta": {"type": "integer"},
+          "scale_up_rate_triggered": {"type": "boolean"},
+          "observe_only": {"type": "boolean"},
+          "maintenance_window": {"type": "string"},
+          "nodes_delta_result": {"type": "integer"},
+          "nodes_deleted": {"type": "integer"},
+          "error": {"type": "string"},
+          "error_kind": {
+            "type": "string",
+            "enum": ["unknown", "throttled", "not_found", "conflict", "validation", "limit", "quota_blocked", "cancelled"]
+          },
+          "state": {
+            "type": "string",
+            "enum": ["ok", "scaling_up", "draining", "degraded", "paused", "quota_blocked"]
+          }
+        }
+      }
+    }
+  }
+}
+`
+
+// OpenAPIHandler serves the OpenAPI document of the admin API
+func OpenAPIHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if _, err := w.Write([]byte(OpenAPISpec)); err != nil {
+			log.WithError(err).Warn("Failed to write openapi document")
+		}
+	})
+}
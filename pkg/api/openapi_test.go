@@ -0,0 +1,110 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/atlassian/escalator/pkg/api"
+	"github.com/atlassian/escalator/pkg/controller"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// jsonFields returns the json names of the fields of the struct type
+func jsonFields(t reflect.Type) []string {
+	var fields []string
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if len(name) > 0 && name != "-" {
+			fields = append(fields, name)
+		}
+	}
+	return fields
+}
+
+func TestOpenAPISpecMatchesTypes(t *testing.T) {
+	var spec struct {
+		Paths      map[string]interface{} `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]interface{} `json:"properties"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(api.OpenAPISpec), &spec))
+
+	for _, path := range []string{"/healthz", "/report", "/cycles", "/api/v1/config/history", "/api/v1/config/rollback", "/api/v1/simulate", api.SupportBundlePath} {
+		assert.Contains(t, spec.Paths, path)
+	}
+
+	// each schema is checked against the type of the controller that is served and the type of this package the
+	// client decodes it into
+	types := map[string][]interface{}{
+		"Report":              {controller.Report{}, api.Report{}},
+		"DiscoveryReport":     {controller.DiscoveryReport{}, api.DiscoveryReport{}},
+		"StuckDeletion":       {controller.StuckDeletion{}, api.StuckDeletion{}},
+		"NodeDisruptionScore": {controller.NodeDisruptionScore{}, api.NodeDisruptionScore{}},
+		"SchedulingFailure":   {controller.SchedulingFailure{}, api.SchedulingFailure{}},
+		"ActiveReservation":   {controller.ActiveReservation{}, api.ActiveReservation{}},
+		"CycleSummary":        {controller.CycleSummary{}, api.CycleSummary{}},
+		"ConfigRevision":      {controller.ConfigRevision{}, api.ConfigRevision{}},
+		"ConfigChange":        {controller.ConfigChange{}, api.ConfigChange{}},
+		"SimulationRequest":   {controller.SimulationRequest{}, api.SimulationRequest{}},
+		"SimulationResult":    {controller.SimulationResult{}, api.SimulationResult{}},
+		"NodeGroupSimulation": {controller.NodeGroupSimulation{}, api.NodeGroupSimulation{}},
+		"SimulatedOutcome":    {controller.SimulatedOutcome{}, api.SimulatedOutcome{}},
+	}
+	for name, values := range types {
+		t.Run(name, func(t *testing.T) {
+			schema, ok := spec.Components.Schemas[name]
+			require.True(t, ok, "schema %v is missing", name)
+			for _, value := range values {
+				fields := jsonFields(reflect.TypeOf(value))
+				assert.Len(t, schema.Properties, len(fields), "%T", value)
+				for _, field := range fields {
+					assert.Contains(t, schema.Properties, field, "%T", value)
+				}
+			}
+		})
+	}
+}
+
+func TestOpenAPISpecCycleDecisions(t *testing.T) {
+	var spec struct {
+		Components struct {
+			Schemas map[string]struct {
+				Properties struct {
+					Decision struct {
+						Enum []string `json:"enum"`
+					} `json:"decision"`
+				} `json:"properties"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(api.OpenAPISpec), &spec))
+
+	decisions := []string{
+		controller.CycleDecisionNone,
+		controller.CycleDecisionScaleUp,
+		controller.CycleDecisionScaleDown,
+		controller.CycleDecisionScaleToMinimum,
+		controller.CycleDecisionLocked,
+		controller.CycleDecisionQuotaBlocked,
+		controller.CycleDecisionSpotInterruption,
+		controller.CycleDecisionSkipped,
+	}
+	assert.ElementsMatch(t, decisions, spec.Components.Schemas["CycleSummary"].Properties.Decision.Enum)
+	// a simulation only decides from the options, so it is never blocked by a quota or interrupted
+	assert.Subset(t, decisions, spec.Components.Schemas["SimulatedOutcome"].Properties.Decision.Enum)
+}
+
+func TestOpenAPIHandler(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	api.OpenAPIHandler().ServeHTTP(recorder, httptest.NewRequest("GET", api.OpenAPIPath, nil))
+	assert.Equal(t, 200, recorder.Code)
+	assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+	assert.JSONEq(t, api.OpenAPISpec, recorder.Body.String())
+}
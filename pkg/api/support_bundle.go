@@ -0,0 +1,33 @@
+package api
+
+import (
+	"fmt"
+	"runtime"
+	"time"
+)
+
+// SupportBundlePath is the path the support bundle is served at
+const SupportBundlePath = "/api/v1/support-bundle"
+
+// VersionInfo is the build of escalator that produced a support bundle
+type VersionInfo struct {
+	Version   string    `json:"version"`
+	GoVersion string    `json:"go_version"`
+	Platform  string    `json:"platform"`
+	Time      time.Time `json:"time"`
+}
+
+// NewVersionInfo returns the version info of the running binary at the time
+func NewVersionInfo(version string, now time.Time) VersionInfo {
+	return VersionInfo{
+		Version:   version,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+		Time:      now,
+	}
+}
+
+// SupportBundleFileName is the default name of the support bundle produced at the time
+func SupportBundleFileName(now time.Time) string {
+	return fmt.Sprintf("escalator-support-bundle-%v.tar.gz", now.UTC().Format("20060102T150405Z"))
+}
@@ -0,0 +1,13 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSupportBundleFileName(t *testing.T) {
+	now := time.Date(2019, 3, 1, 14, 12, 5, 0, time.FixedZone("AEDT", 11*60*60))
+	assert.Equal(t, "escalator-support-bundle-20190301T031205Z.tar.gz", SupportBundleFileName(now))
+}
@@ -0,0 +1,159 @@
+package api
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// The types below are the json responses of the admin API, as described by OpenAPISpec. They mirror the types the
+// controller serves, which is checked by the tests of this package, so the client doesn't depend on the controller
+
+// Report is the state of escalator served by /report
+type Report struct {
+	Discovery         DiscoveryReport `json:"discovery"`
+	LastSuccessfulRun time.Time       `json:"last_successful_run"`
+	// StuckDeletions are the tainted nodes that failed to be deleted too many times and need attention
+	StuckDeletions []StuckDeletion `json:"stuck_deletions"`
+	// DisruptionScores are the disruption scores of the untainted nodes, lowest first by node group
+	DisruptionScores []NodeDisruptionScore `json:"disruption_scores"`
+	// SchedulingFailures are the latest FailedScheduling events of the pods of each node group, newest first by node
+	// group. Only set with --scheduling-failures
+	SchedulingFailures []SchedulingFailure `json:"scheduling_failures"`
+	// Reservations are the reservations of the node groups active in the last run
+	Reservations []ActiveReservation `json:"reservations"`
+}
+
+// DiscoveryReport is the nodes that match no node group and the node groups that match no nodes
+type DiscoveryReport struct {
+	UnmatchedNodes  []string `json:"unmatched_nodes"`
+	EmptyNodeGroups []string `json:"empty_node_groups"`
+}
+
+// StuckDeletion is a tainted node that failed to be deleted too many times and is no longer retried
+type StuckDeletion struct {
+	NodeGroup string `json:"node_group"`
+	Node      string `json:"node"`
+	Attempts  int    `json:"attempts"`
+	LastError string `json:"last_error"`
+}
+
+// NodeDisruptionScore is the disruption score of an untainted node from the last run
+type NodeDisruptionScore struct {
+	NodeGroup string  `json:"node_group"`
+	Node      string  `json:"node"`
+	Score     float64 `json:"score"`
+	Pods      int     `json:"pods"`
+}
+
+// SchedulingFailure is a FailedScheduling event of a pod of a node group
+type SchedulingFailure struct {
+	NodeGroup string    `json:"node_group"`
+	Namespace string    `json:"namespace"`
+	Pod       string    `json:"pod"`
+	Message   string    `json:"message"`
+	Count     int32     `json:"count"`
+	Time      time.Time `json:"time"`
+}
+
+// ActiveReservation is a reservation of a node group active in the last run
+type ActiveReservation struct {
+	NodeGroup string    `json:"node_group"`
+	Name      string    `json:"name"`
+	Tenant    string    `json:"tenant"`
+	Nodes     int       `json:"nodes"`
+	From      time.Time `json:"from"`
+	Until     time.Time `json:"until"`
+}
+
+// CycleSummary is the inputs, decision, actions and error of a single run for a node group
+type CycleSummary struct {
+	ID   string    `json:"id"`
+	Time time.Time `json:"time"`
+
+	// inputs
+	Pods                     int     `json:"pods"`
+	Nodes                    int     `json:"nodes"`
+	UntaintedNodes           int     `json:"untainted_nodes"`
+	TaintedNodes             int     `json:"tainted_nodes"`
+	CordonedNodes            int     `json:"cordoned_nodes"`
+	ShuttingDownNodes        int     `json:"shutting_down_nodes"`
+	StartingNodes            int     `json:"starting_nodes"`
+	CPUPercent               float64 `json:"cpu_percent"`
+	MemPercent               float64 `json:"mem_percent"`
+	HeterogeneousAllocatable bool    `json:"heterogeneous_allocatable,omitempty"`
+
+	// decision, one of the decisions in the enum of OpenAPISpec, e.g. scale_up
+	Decision             string `json:"decision"`
+	NodesDelta           int    `json:"nodes_delta"`
+	ScaleUpRateTriggered bool   `json:"scale_up_rate_triggered,omitempty"`
+	ObserveOnly          bool   `json:"observe_only,omitempty"`
+	MaintenanceWindow    string `json:"maintenance_window,omitempty"`
+
+	// actions
+	NodesDeltaResult int `json:"nodes_delta_result"`
+	NodesDeleted     int `json:"nodes_deleted"`
+
+	Error string `json:"error,omitempty"`
+	// ErrorKind is the category of the error, e.g. throttled or not_found
+	ErrorKind string `json:"error_kind,omitempty"`
+
+	State string `json:"state,omitempty"`
+}
+
+// ConfigRevision is a config applied by escalator, and what changed from the config applied before it
+type ConfigRevision struct {
+	Revision int       `json:"revision"`
+	Time     time.Time `json:"time"`
+	// Source is how the config was applied: startup, reload or rollback
+	Source     string `json:"source"`
+	RollbackOf int    `json:"rollback_of,omitempty"`
+	// Config is the json of the config, with its secrets redacted
+	Config  json.RawMessage `json:"config"`
+	Changes []ConfigChange  `json:"changes"`
+}
+
+// ConfigChange is a setting that changed between two configs. The values are json, and empty when the setting is
+// unset
+type ConfigChange struct {
+	Path string `json:"path"`
+	Old  string `json:"old,omitempty"`
+	New  string `json:"new,omitempty"`
+}
+
+// SimulationRequest is the changes to the options of the node groups to simulate. Each node group is given as the
+// json of the options that change, e.g. {"node_groups": {"shared": {"scale_up_threshold_percent": 80}}}
+type SimulationRequest struct {
+	NodeGroups map[string]json.RawMessage `json:"node_groups"`
+}
+
+// SimulationResult is what the current state of the node groups would produce under their current options and under
+// the changed options
+type SimulationResult struct {
+	Time       time.Time             `json:"time"`
+	NodeGroups []NodeGroupSimulation `json:"node_groups"`
+}
+
+// NodeGroupSimulation compares the outcome of a run of the node group under its current options with the outcome
+// under the changed options
+type NodeGroupSimulation struct {
+	NodeGroup string           `json:"node_group"`
+	Changes   []ConfigChange   `json:"changes"`
+	Current   SimulatedOutcome `json:"current"`
+	Simulated SimulatedOutcome `json:"simulated"`
+}
+
+// SimulatedOutcome is the scaling decision a run would make on the current state of a node group
+type SimulatedOutcome struct {
+	Decision       string   `json:"decision"`
+	NodesDelta     int      `json:"nodes_delta"`
+	CPUPercent     float64  `json:"cpu_percent"`
+	MemPercent     float64  `json:"mem_percent"`
+	Pods           int      `json:"pods"`
+	Nodes          int      `json:"nodes"`
+	UntaintedNodes int      `json:"untainted_nodes"`
+	TaintedNodes   int      `json:"tainted_nodes"`
+	Candidates     []string `json:"candidates"`
+	NodesToAdd     int      `json:"nodes_to_add,omitempty"`
+	Limits         []string `json:"limits,omitempty"`
+	Error          string   `json:"error,omitempty"`
+}
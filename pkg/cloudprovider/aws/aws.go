@@ -1,18 +1,22 @@
 package aws
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"strings"
+	"sort"
 	"time"
 
 	"github.com/atlassian/escalator/pkg/cloudprovider"
+	"github.com/atlassian/escalator/pkg/errorkind"
 	"github.com/atlassian/escalator/pkg/metrics"
 	awsapi "github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/autoscaling"
 	"github.com/aws/aws-sdk-go/service/autoscaling/autoscalingiface"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/aws/aws-sdk-go/service/eks"
+	"github.com/aws/aws-sdk-go/service/eks/eksiface"
 	log "github.com/sirupsen/logrus"
 	v1 "k8s.io/api/core/v1"
 )
@@ -24,15 +28,27 @@ func instanceToProviderID(instance *autoscaling.Instance) string {
 	return fmt.Sprintf("aws:///%s/%s", *instance.AvailabilityZone, *instance.InstanceId)
 }
 
-func providerIDToInstanceID(providerId string) string {
-	return strings.Split(providerId, "/")[4]
+// nodeInstanceID returns the id of the EC2 instance backing the node, from its provider id
+func nodeInstanceID(node *v1.Node) (string, error) {
+	providerID, err := cloudprovider.NodeProviderID(node)
+	if err != nil {
+		return "", err
+	}
+	if providerID.Provider != ProviderName {
+		return "", errorkind.New(errorkind.Validation, "node %v has provider id %v from a different cloud provider than %v", node.Name, node.Spec.ProviderID, ProviderName)
+	}
+	return providerID.InstanceID, nil
 }
 
 // CloudProvider providers an aws cloud provider implementation
 type CloudProvider struct {
 	service     autoscalingiface.AutoScalingAPI
 	ec2_service ec2iface.EC2API
+	// eks_service resizes the EKS managed node groups, nil when the EKS api isn't set up
+	eks_service eksiface.EKSAPI
 	nodeGroups  map[string]*NodeGroup
+	// vcpuQuota is the on-demand vCPU limit of the account, zero when scale ups aren't checked against it
+	vcpuQuota int64
 }
 
 // Name returns name of the cloud provider.
@@ -57,7 +73,7 @@ func (c *CloudProvider) GetNodeGroup(id string) (cloudprovider.NodeGroup, bool)
 }
 
 // RegisterNodeGroups adds the nodegroup to the list of nodes groups
-func (c *CloudProvider) RegisterNodeGroups(groups ...cloudprovider.NodeGroupConfig) error {
+func (c *CloudProvider) RegisterNodeGroups(ctx context.Context, groups ...cloudprovider.NodeGroupConfig) error {
 	configs := make(map[string]*cloudprovider.NodeGroupConfig, len(groups))
 	strs := make([]*string, len(groups))
 	for i, s := range groups {
@@ -70,7 +86,7 @@ func (c *CloudProvider) RegisterNodeGroups(groups ...cloudprovider.NodeGroupConf
 		AutoScalingGroupNames: strs,
 	}
 
-	result, err := c.service.DescribeAutoScalingGroups(input)
+	result, err := c.service.DescribeAutoScalingGroupsWithContext(ctx, input)
 	if err != nil {
 		log.Errorf("failed to describe asgs %v. err: %v", groups, err)
 		return err
@@ -87,6 +103,20 @@ func (c *CloudProvider) RegisterNodeGroups(groups ...cloudprovider.NodeGroupConf
 		c.nodeGroups[id] = NewNodeGroup(configs[id], group, c)
 	}
 
+	// the size of EKS managed node groups is read from EKS, which owns it
+	for id, config := range configs {
+		ng, ok := c.nodeGroups[id]
+		if !ok || !ng.managed() {
+			continue
+		}
+		eksNodegroup, err := c.describeEKSNodegroup(ctx, config)
+		if err != nil {
+			log.Error(err)
+			return err
+		}
+		ng.eksNodegroup = eksNodegroup
+	}
+
 	// Update metrics for each node group
 	for _, nodeGroup := range c.nodeGroups {
 		metrics.CloudProviderMinSize.WithLabelValues(c.Name(), nodeGroup.ID()).Set(float64(nodeGroup.MinSize()))
@@ -99,13 +129,13 @@ func (c *CloudProvider) RegisterNodeGroups(groups ...cloudprovider.NodeGroupConf
 }
 
 // Refresh is called before every main loop and can be used to dynamically update cloud provider state.
-func (c *CloudProvider) Refresh() error {
+func (c *CloudProvider) Refresh(ctx context.Context) error {
 	configs := make([]cloudprovider.NodeGroupConfig, 0, len(c.nodeGroups))
 	for _, ng := range c.nodeGroups {
 		configs = append(configs, *ng.config)
 	}
 
-	return c.RegisterNodeGroups(configs...)
+	return c.RegisterNodeGroups(ctx, configs...)
 }
 
 // Instance includes base EC2 instance information
@@ -115,16 +145,19 @@ type Instance struct {
 }
 
 // GetInstance creates an Instance object through k8s Node object
-func (c *CloudProvider) GetInstance(node *v1.Node) (cloudprovider.Instance, error) {
+func (c *CloudProvider) GetInstance(ctx context.Context, node *v1.Node) (cloudprovider.Instance, error) {
 	var instance *Instance
 
-	id := providerIDToInstanceID(node.Spec.ProviderID)
+	id, err := nodeInstanceID(node)
+	if err != nil {
+		return nil, err
+	}
 
 	input := &ec2.DescribeInstancesInput{
 		InstanceIds: []*string{&id},
 	}
 
-	result, err := c.ec2_service.DescribeInstances(input)
+	result, err := c.ec2_service.DescribeInstancesWithContext(ctx, input)
 
 	if err != nil {
 		log.Error("Error describing instance - ", err)
@@ -153,10 +186,21 @@ func (i *Instance) ID() string {
 	return i.id
 }
 
+// ScaleUpMetadata returns the metadata of the scale up that created the instance, from the instance tags
+func (i *Instance) ScaleUpMetadata() (cloudprovider.ScaleUpMetadata, bool) {
+	tags := make(map[string]string, len(i.ec2Instance.Tags))
+	for _, tag := range i.ec2Instance.Tags {
+		tags[awsapi.StringValue(tag.Key)] = awsapi.StringValue(tag.Value)
+	}
+	return cloudprovider.ScaleUpMetadataFromTags(tags)
+}
+
 // NodeGroup implements a aws nodegroup
 type NodeGroup struct {
 	id  string
 	asg *autoscaling.Group
+	// eksNodegroup is the EKS managed node group backed by the asg, nil for self managed node groups
+	eksNodegroup *eks.Nodegroup
 
 	provider *CloudProvider
 	config   *cloudprovider.NodeGroupConfig
@@ -183,11 +227,17 @@ func (n *NodeGroup) ID() string {
 
 // MinSize returns minimum size of the node group.
 func (n *NodeGroup) MinSize() int64 {
+	if scalingConfig := n.eksScalingConfig(); scalingConfig != nil {
+		return awsapi.Int64Value(scalingConfig.MinSize)
+	}
 	return awsapi.Int64Value(n.asg.MinSize)
 }
 
 // MaxSize returns maximum size of the node group.
 func (n *NodeGroup) MaxSize() int64 {
+	if scalingConfig := n.eksScalingConfig(); scalingConfig != nil {
+		return awsapi.Int64Value(scalingConfig.MaxSize)
+	}
 	return awsapi.Int64Value(n.asg.MaxSize)
 }
 
@@ -196,6 +246,9 @@ func (n *NodeGroup) MaxSize() int64 {
 // to Size() once everything stabilizes (new nodes finish startup and registration or
 // removed nodes are deleted completely).
 func (n *NodeGroup) TargetSize() int64 {
+	if scalingConfig := n.eksScalingConfig(); scalingConfig != nil {
+		return awsapi.Int64Value(scalingConfig.DesiredSize)
+	}
 	return awsapi.Int64Value(n.asg.DesiredCapacity)
 }
 
@@ -213,7 +266,14 @@ func (n *NodeGroup) canScaleInOneShot() bool {
 // IncreaseSize increases the size of the node group. To delete a node you need
 // to explicitly name it and use DeleteNode. This function should wait until
 // node group size is updated.
-func (n *NodeGroup) IncreaseSize(delta int64) error {
+func (n *NodeGroup) IncreaseSize(ctx context.Context, delta int64) error {
+	return n.IncreaseSizeWithMetadata(ctx, delta, cloudprovider.ScaleUpMetadata{})
+}
+
+// IncreaseSizeWithMetadata increases the size of the node group and tags the new instances with the metadata.
+// Without the fleet API the tags are set on the auto scaling group and propagated to the instances it launches.
+// A failure to tag the auto scaling group is logged and doesn't stop the scale up
+func (n *NodeGroup) IncreaseSizeWithMetadata(ctx context.Context, delta int64, metadata cloudprovider.ScaleUpMetadata) error {
 	if delta <= 0 {
 		return fmt.Errorf("size increase must be positive")
 	}
@@ -224,19 +284,31 @@ func (n *NodeGroup) IncreaseSize(delta int64) error {
 
 	log.WithField("asg", n.id).Debugf("IncreaseSize: %v", delta)
 
+	if n.managed() {
+		// EKS owns the tags of the asg, so the metadata isn't propagated to the new instances
+		log.WithField("asg", n.id).Infof("Scaling with UpdateNodegroupConfig strategy")
+		return n.setEKSDesiredSize(ctx, n.TargetSize()+delta)
+	}
+
+	tags := metadata.Tags()
 	if n.canScaleInOneShot() {
 		log.WithField("asg", n.id).Infof("Scaling with CreateFleet strategy")
-		return n.setASGDesiredSizeOneShot(delta)
-	} else {
-		log.WithField("asg", n.id).Infof("Scaling with SetDesiredCapacity trategy")
-		return n.setASGDesiredSize(n.TargetSize() + delta)
+		return n.setASGDesiredSizeOneShot(ctx, delta, tags)
 	}
+
+	if len(tags) > 0 {
+		if err := n.setASGPropagatedTags(ctx, tags); err != nil {
+			log.WithField("asg", n.id).WithError(err).Warn("Failed to tag the auto scaling group with the scale up metadata")
+		}
+	}
+	log.WithField("asg", n.id).Infof("Scaling with SetDesiredCapacity trategy")
+	return n.setASGDesiredSize(ctx, n.TargetSize()+delta)
 }
 
 // DeleteNodes deletes nodes from this node group. Error is returned either on
 // failure or if the given node doesn't belong to this node group. This function
 // should wait until node group size is updated.
-func (n *NodeGroup) DeleteNodes(nodes ...*v1.Node) error {
+func (n *NodeGroup) DeleteNodes(ctx context.Context, nodes ...*v1.Node) error {
 	if n.TargetSize() <= n.MinSize() {
 		return fmt.Errorf("min sized reached, nodes will not be deleted")
 	}
@@ -245,42 +317,69 @@ func (n *NodeGroup) DeleteNodes(nodes ...*v1.Node) error {
 		return fmt.Errorf("terminating nodes will breach minimum node size")
 	}
 
+	// the desired size of an EKS managed node group is lowered after its instances are terminated, so they aren't
+	// terminated while EKS would refuse that. Otherwise EKS would restore the old size and replace the instances
+	if n.managed() {
+		if err := n.eksResizable(); err != nil {
+			return err
+		}
+	}
+
+	// every node is checked before any instance is terminated, so a node from another node group stops the whole deletion
+	instanceIDs := make([]string, 0, len(nodes))
 	for _, node := range nodes {
+		// a node without a provider id can't be mapped to an instance, which doesn't mean it's in another node group
+		instanceID, err := nodeInstanceID(node)
+		if err != nil {
+			return err
+		}
 		if !n.Belongs(node) {
 			log.Debugf("instances in ASG: %v", n.Nodes())
 			return &cloudprovider.NodeNotInNodeGroup{NodeName: node.Name, ProviderID: node.Spec.ProviderID, NodeGroup: n.ID()}
 		}
+		instanceIDs = append(instanceIDs, instanceID)
+	}
 
-		// find which instance this is
-		var instanceID *string
-		for _, instance := range n.asg.Instances {
-			if node.Spec.ProviderID == instanceToProviderID(instance) {
-				instanceID = instance.InstanceId
-				break
-			}
-		}
-
+	// the instances are terminated one at a time, a failed termination doesn't stop the others
+	failed := make(map[string]error)
+	for i, node := range nodes {
 		input := &autoscaling.TerminateInstanceInAutoScalingGroupInput{
-			InstanceId:                     instanceID,
+			InstanceId:                     awsapi.String(instanceIDs[i]),
 			ShouldDecrementDesiredCapacity: awsapi.Bool(true),
 		}
 
-		result, err := n.provider.service.TerminateInstanceInAutoScalingGroup(input)
+		result, err := n.provider.service.TerminateInstanceInAutoScalingGroupWithContext(ctx, input)
 		if err != nil {
-			return fmt.Errorf("failed to terminate instance. err: %v", err)
+			failed[node.Name] = fmt.Errorf("failed to terminate instance. err: %v", err)
+			continue
 		}
 		log.Debug(*result.Activity.Description)
 	}
+	terminated := len(nodes) - len(failed)
+
+	if n.managed() && terminated > 0 {
+		// the asg was decremented with the terminations, EKS is told the same so it doesn't restore the old size
+		if err := n.setEKSDesiredSize(ctx, n.TargetSize()-int64(terminated)); err != nil {
+			log.WithField("asg", n.id).WithError(err).Warn("Failed to update the desired size of the EKS managed node group after terminating its instances")
+		}
+	}
 
+	if len(failed) > 0 {
+		return &cloudprovider.NodesNotDeleted{Failed: failed}
+	}
 	return nil
 }
 
-// Belongs determines if the node belongs in the current node group
+// Belongs determines if the node belongs in the current node group. Nodes are matched to the instances of the auto
+// scaling group by the instance id in their provider id, never by their name
 func (n *NodeGroup) Belongs(node *v1.Node) bool {
-	nodeProviderID := node.Spec.ProviderID
+	instanceID, err := nodeInstanceID(node)
+	if err != nil {
+		return false
+	}
 
-	for _, id := range n.Nodes() {
-		if id == nodeProviderID {
+	for _, instance := range n.asg.Instances {
+		if awsapi.StringValue(instance.InstanceId) == instanceID {
 			return true
 		}
 	}
@@ -293,7 +392,7 @@ func (n *NodeGroup) Belongs(node *v1.Node) bool {
 // request for new nodes that have not been yet fulfilled. Delta should be negative.
 // It is assumed that cloud provider will not delete the existing nodes when there
 // is an option to just decrease the target.
-func (n *NodeGroup) DecreaseTargetSize(delta int64) error {
+func (n *NodeGroup) DecreaseTargetSize(ctx context.Context, delta int64) error {
 	if delta >= 0 {
 		return fmt.Errorf("size decrease delta must be negative")
 	}
@@ -303,7 +402,10 @@ func (n *NodeGroup) DecreaseTargetSize(delta int64) error {
 	}
 
 	log.WithField("asg", n.id).Debugf("DecreaseTargetSize: %v", delta)
-	return n.setASGDesiredSize(n.TargetSize() + delta)
+	if n.managed() {
+		return n.setEKSDesiredSize(ctx, n.TargetSize()+delta)
+	}
+	return n.setASGDesiredSize(ctx, n.TargetSize()+delta)
 }
 
 // Nodes returns a list of all nodes that belong to this node group.
@@ -316,9 +418,95 @@ func (n *NodeGroup) Nodes() []string {
 	return result
 }
 
+// scalingActivitiesPageSize is how many of the latest scaling activities of the auto scaling group are described
+const scalingActivitiesPageSize = 20
+
+// ScalingActivities returns the latest scaling activities of the auto scaling group that started at or after since,
+// newest first. Failed and cancelled activities are failed, e.g. an instance that couldn't be launched because of
+// insufficient capacity
+func (n *NodeGroup) ScalingActivities(ctx context.Context, since time.Time) ([]cloudprovider.ScalingActivity, error) {
+	input := &autoscaling.DescribeScalingActivitiesInput{
+		AutoScalingGroupName: awsapi.String(n.id),
+		MaxRecords:           awsapi.Int64(scalingActivitiesPageSize),
+	}
+	output, err := n.provider.service.DescribeScalingActivitiesWithContext(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	activities := make([]cloudprovider.ScalingActivity, 0, len(output.Activities))
+	for _, activity := range output.Activities {
+		startTime := awsapi.TimeValue(activity.StartTime)
+		if startTime.Before(since) {
+			continue
+		}
+		status := awsapi.StringValue(activity.StatusCode)
+		failed := status == autoscaling.ScalingActivityStatusCodeFailed || status == autoscaling.ScalingActivityStatusCodeCancelled
+		activities = append(activities, cloudprovider.ScalingActivity{
+			ID:            awsapi.StringValue(activity.ActivityId),
+			Description:   awsapi.StringValue(activity.Description),
+			StatusMessage: awsapi.StringValue(activity.StatusMessage),
+			StartTime:     startTime,
+			Done:          failed || status == autoscaling.ScalingActivityStatusCodeSuccessful,
+			Failed:        failed,
+		})
+	}
+	return activities, nil
+}
+
+// TagInstances sets the tags on the ec2 instances of the nodes
+func (n *NodeGroup) TagInstances(ctx context.Context, tags map[string]string, nodes ...*v1.Node) error {
+	instanceIDs, err := n.nodeInstanceIDs(nodes)
+	if err != nil {
+		return err
+	}
+	ec2Tags := make([]*ec2.Tag, 0, len(tags))
+	for _, key := range sortedTagKeys(tags) {
+		ec2Tags = append(ec2Tags, &ec2.Tag{Key: awsapi.String(key), Value: awsapi.String(tags[key])})
+	}
+	_, err = n.provider.ec2_service.CreateTagsWithContext(ctx, &ec2.CreateTagsInput{
+		Resources: instanceIDs,
+		Tags:      ec2Tags,
+	})
+	return err
+}
+
+// UntagInstances removes the tags with the keys from the ec2 instances of the nodes
+func (n *NodeGroup) UntagInstances(ctx context.Context, keys []string, nodes ...*v1.Node) error {
+	instanceIDs, err := n.nodeInstanceIDs(nodes)
+	if err != nil {
+		return err
+	}
+	ec2Tags := make([]*ec2.Tag, 0, len(keys))
+	for _, key := range keys {
+		ec2Tags = append(ec2Tags, &ec2.Tag{Key: awsapi.String(key)})
+	}
+	_, err = n.provider.ec2_service.DeleteTagsWithContext(ctx, &ec2.DeleteTagsInput{
+		Resources: instanceIDs,
+		Tags:      ec2Tags,
+	})
+	return err
+}
+
+// nodeInstanceIDs returns the instance ids of the nodes, which must belong to the node group
+func (n *NodeGroup) nodeInstanceIDs(nodes []*v1.Node) ([]*string, error) {
+	instanceIDs := make([]*string, 0, len(nodes))
+	for _, node := range nodes {
+		instanceID, err := nodeInstanceID(node)
+		if err != nil {
+			return nil, err
+		}
+		if !n.Belongs(node) {
+			return nil, &cloudprovider.NodeNotInNodeGroup{NodeName: node.Name, ProviderID: node.Spec.ProviderID, NodeGroup: n.ID()}
+		}
+		instanceIDs = append(instanceIDs, awsapi.String(instanceID))
+	}
+	return instanceIDs, nil
+}
+
 // setASGDesiredSize sets the asg desired size to the new size
 // user must make sure that newSize is not out of bounds of the asg
-func (n *NodeGroup) setASGDesiredSize(newSize int64) error {
+func (n *NodeGroup) setASGDesiredSize(ctx context.Context, newSize int64) error {
 	input := &autoscaling.SetDesiredCapacityInput{
 		AutoScalingGroupName: awsapi.String(n.id),
 		DesiredCapacity:      awsapi.Int64(newSize),
@@ -328,14 +516,62 @@ func (n *NodeGroup) setASGDesiredSize(newSize int64) error {
 	log.WithField("asg", n.id).Debugf("SetDesiredCapacity: %v", newSize)
 	log.WithField("asg", n.id).Debugf("CurrentSize: %v", n.Size())
 	log.WithField("asg", n.id).Debugf("CurrentTargetSize: %v", n.TargetSize())
-	_, err := n.provider.service.SetDesiredCapacity(input)
+	_, err := n.provider.service.SetDesiredCapacityWithContext(ctx, input)
+	return err
+}
+
+// setASGPropagatedTags sets the tags on the asg, propagated to the instances it launches from now on
+func (n *NodeGroup) setASGPropagatedTags(ctx context.Context, tags map[string]string) error {
+	asgTags := make([]*autoscaling.Tag, 0, len(tags))
+	for _, key := range sortedTagKeys(tags) {
+		asgTags = append(asgTags, &autoscaling.Tag{
+			Key:               awsapi.String(key),
+			Value:             awsapi.String(tags[key]),
+			PropagateAtLaunch: awsapi.Bool(true),
+			ResourceId:        awsapi.String(n.id),
+			ResourceType:      awsapi.String("auto-scaling-group"),
+		})
+	}
+	_, err := n.provider.service.CreateOrUpdateTagsWithContext(ctx, &autoscaling.CreateOrUpdateTagsInput{Tags: asgTags})
 	return err
 }
 
+// fleetTagSpecifications returns the tag specifications that tag the instances created by a fleet
+func fleetTagSpecifications(tags map[string]string) []*ec2.TagSpecification {
+	if len(tags) == 0 {
+		return nil
+	}
+	ec2Tags := make([]*ec2.Tag, 0, len(tags))
+	for _, key := range sortedTagKeys(tags) {
+		ec2Tags = append(ec2Tags, &ec2.Tag{
+			Key:   awsapi.String(key),
+			Value: awsapi.String(tags[key]),
+		})
+	}
+	return []*ec2.TagSpecification{
+		{
+			ResourceType: awsapi.String(ec2.ResourceTypeInstance),
+			Tags:         ec2Tags,
+		},
+	}
+}
+
+// sortedTagKeys returns the keys of the tags in order, so api calls are deterministic
+func sortedTagKeys(tags map[string]string) []string {
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 // setASGDesiredSizeOneShot uses the AWS fleet API to acquire all desired
 // capacity in one step and then add it to the existing auto-scaling group.
-func (n *NodeGroup) setASGDesiredSizeOneShot(addCount int64) error {
-	fleet, err := n.provider.ec2_service.CreateFleet(&ec2.CreateFleetInput{
+// The instances are tagged with the tags when they are created
+func (n *NodeGroup) setASGDesiredSizeOneShot(ctx context.Context, addCount int64, tags map[string]string) error {
+	fleet, err := n.provider.ec2_service.CreateFleetWithContext(ctx, &ec2.CreateFleetInput{
+		TagSpecifications:                fleetTagSpecifications(tags),
 		Type:                             awsapi.String("instant"),
 		TerminateInstancesWithExpiration: awsapi.Bool(false),
 		OnDemandOptions: &ec2.OnDemandOptionsRequest{
@@ -386,11 +622,13 @@ InstanceReadyLoop:
 	for {
 		select {
 		case <-ticker.C:
-			if n.allInstancesReady(instances) {
+			if n.allInstancesReady(ctx, instances) {
 				break InstanceReadyLoop
 			}
 		case <-deadline.C:
 			return errors.New("Not all instances could be started")
+		case <-ctx.Done():
+			return fmt.Errorf("stopped waiting for the instances to start: %v", ctx.Err())
 		}
 	}
 
@@ -400,7 +638,7 @@ InstanceReadyLoop:
 	for batchSize < len(instances) {
 		instances, batch = instances[batchSize:], instances[0:batchSize:batchSize]
 
-		_, err = n.provider.service.AttachInstances(&autoscaling.AttachInstancesInput{
+		_, err = n.provider.service.AttachInstancesWithContext(ctx, &autoscaling.AttachInstancesInput{
 			AutoScalingGroupName: awsapi.String(n.id),
 			InstanceIds:          batch,
 		})
@@ -411,7 +649,7 @@ InstanceReadyLoop:
 
 	// Attach the remainder for instance sets that are not evenly divisible by
 	// batchSize
-	_, err = n.provider.service.AttachInstances(&autoscaling.AttachInstancesInput{
+	_, err = n.provider.service.AttachInstancesWithContext(ctx, &autoscaling.AttachInstancesInput{
 		AutoScalingGroupName: awsapi.String(n.id),
 		InstanceIds:          instances,
 	})
@@ -421,10 +659,10 @@ InstanceReadyLoop:
 	return err
 }
 
-func (n *NodeGroup) allInstancesReady(ids []*string) bool {
+func (n *NodeGroup) allInstancesReady(ctx context.Context, ids []*string) bool {
 	ready := false
 
-	n.provider.ec2_service.DescribeInstanceStatusPages(&ec2.DescribeInstanceStatusInput{
+	n.provider.ec2_service.DescribeInstanceStatusPagesWithContext(ctx, &ec2.DescribeInstanceStatusInput{
 		InstanceIds:         ids,
 		IncludeAllInstances: awsapi.Bool(true),
 	}, func(r *ec2.DescribeInstanceStatusOutput, lastPage bool) bool {
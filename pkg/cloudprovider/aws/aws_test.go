@@ -1,12 +1,14 @@
 package aws
 
 import (
+	"context"
 	"testing"
 
 	"github.com/atlassian/escalator/pkg/cloudprovider"
 	"github.com/atlassian/escalator/pkg/test"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -19,8 +21,52 @@ func TestInstanceToProviderId(t *testing.T) {
 	assert.Equal(t, "aws:///us-east-1b/abc123", res)
 }
 
-func TestProviderIdToInstanceId(t *testing.T) {
-	assert.Equal(t, "abc123", providerIDToInstanceID("aws:///us-east-1b/abc123"))
+func TestNodeInstanceID(t *testing.T) {
+	tests := []struct {
+		name       string
+		providerID string
+		want       string
+		wantErr    bool
+	}{
+		{"with zone", "aws:///us-east-1b/abc123", "abc123", false},
+		{"without zone", "aws:///abc123", "abc123", false},
+		{"not initialised", "", "", true},
+		{"other cloud provider", "gce://project/us-central1-a/abc123", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := test.BuildTestNode(test.NodeOpts{Name: "node-1"})
+			node.Spec.ProviderID = tt.providerID
+			got, err := nodeInstanceID(node)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantErr, err != nil)
+		})
+	}
+}
+
+func TestInstance_ScaleUpMetadata(t *testing.T) {
+	instance := &Instance{
+		id: "i-123",
+		ec2Instance: &ec2.Instance{
+			Tags: []*ec2.Tag{
+				{Key: aws.String("Name"), Value: aws.String("shared")},
+				{Key: aws.String(cloudprovider.ScaleUpNodeGroupTagKey), Value: aws.String("shared")},
+				{Key: aws.String(cloudprovider.ScaleUpCycleTagKey), Value: aws.String("20190101T000000Z")},
+				{Key: aws.String(cloudprovider.ScaleUpReasonTagKey), Value: aws.String("scale_up")},
+			},
+		},
+	}
+	metadata, ok := instance.ScaleUpMetadata()
+	assert.True(t, ok)
+	assert.Equal(t, cloudprovider.ScaleUpMetadata{NodeGroup: "shared", CycleID: "20190101T000000Z", Reason: "scale_up"}, metadata)
+	assert.Equal(t, map[string]string{
+		cloudprovider.ScaleUpNodeGroupTagKey: "shared",
+		cloudprovider.ScaleUpCycleTagKey:     "20190101T000000Z",
+		cloudprovider.ScaleUpReasonTagKey:    "scale_up",
+	}, metadata.Tags())
+
+	_, ok = (&Instance{id: "i-456", ec2Instance: &ec2.Instance{}}).ScaleUpMetadata()
+	assert.False(t, ok)
 }
 
 func newMockCloudProvider(nodeGroups []string, service *test.MockAutoscalingService, ec2_service *test.MockEc2Service) (*CloudProvider, error) {
@@ -38,7 +84,7 @@ func newMockCloudProvider(nodeGroups []string, service *test.MockAutoscalingServ
 	}
 
 	if service != nil {
-		err = cloudProvider.RegisterNodeGroups(configs...)
+		err = cloudProvider.RegisterNodeGroups(context.Background(), configs...)
 	}
 
 	return cloudProvider, err
@@ -1,16 +1,20 @@
 package aws
 
 import (
+	"context"
 	"fmt"
+	"net/http"
 	"time"
 
 	"github.com/atlassian/escalator/pkg/cloudprovider"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/autoscaling"
 	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/eks"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -18,6 +22,9 @@ import (
 type Builder struct {
 	ProviderOpts cloudprovider.BuildOpts
 	Opts         Opts
+
+	// transport sends the api requests instead of the default http transport, e.g. to record them
+	transport http.RoundTripper
 }
 
 // Build the cloud provider
@@ -34,21 +41,27 @@ func (b Builder) Build() (cloudprovider.CloudProvider, error) {
 		creds = stscreds.NewCredentials(sess, b.Opts.AssumeRoleARN, setAssumeRoleName)
 	}
 
-	// Create the autoscaling service
-	service := autoscaling.New(sess, &aws.Config{
-		Credentials: creds,
-	})
-	ec2_service := ec2.New(sess, &aws.Config{
+	// Use our own retryer so throttling is visible in metrics and optionally rate limited client side
+	retryer := newThrottleAwareRetryer(b.Opts.MaxRetries, b.Opts.RetryMode)
+	retryer.addHandlers(&sess.Handlers)
+	config := request.WithRetryer(&aws.Config{
 		Credentials: creds,
-	})
+		HTTPClient:  &http.Client{Timeout: b.Opts.APITimeout, Transport: b.transport},
+	}, retryer)
+
+	// Create the autoscaling service
+	service := autoscaling.New(sess, config)
+	ec2_service := ec2.New(sess, config)
 	cloud := &CloudProvider{
 		service:     service,
 		ec2_service: ec2_service,
+		eks_service: eks.New(sess, config),
 		nodeGroups:  make(map[string]*NodeGroup, len(b.ProviderOpts.NodeGroupConfigs)),
+		vcpuQuota:   b.Opts.VCPUQuota,
 	}
 
 	// Register the node groups
-	err = cloud.RegisterNodeGroups(b.ProviderOpts.NodeGroupConfigs...)
+	err = cloud.RegisterNodeGroups(context.Background(), b.ProviderOpts.NodeGroupConfigs...)
 	if err != nil {
 		return nil, err
 	}
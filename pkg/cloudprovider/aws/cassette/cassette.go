@@ -0,0 +1,164 @@
+// Package cassette records the http interactions of the aws sdk with the AWS APIs into cassettes, and replays them
+// so that the behaviour of the aws cloud provider can be tested without credentials.
+// Recording is only built with the recorder build tag
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sync"
+)
+
+// Cassette is a list of recorded interactions, in the order they happened
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is a single request and the response it received
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Request is the recorded request. Headers aren't recorded as they contain the credentials and signatures
+type Request struct {
+	Method string `json:"method"`
+	Host   string `json:"host"`
+	Path   string `json:"path"`
+	// Form is the form encoded body of the AWS query protocol, e.g. Action=DescribeAutoScalingGroups&...
+	Form string `json:"form"`
+}
+
+// Response is the recorded response
+type Response struct {
+	StatusCode  int    `json:"status_code"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        string `json:"body"`
+}
+
+// Load reads a cassette from the json file
+func Load(path string) (*Cassette, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cassette := &Cassette{}
+	if err := json.Unmarshal(data, cassette); err != nil {
+		return nil, fmt.Errorf("failed to decode cassette %v: %v", path, err)
+	}
+	return cassette, nil
+}
+
+// Save writes the cassette to the json file
+func (c *Cassette) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// matches returns whether the recorded request is the same as the form encoded request
+func (r Request) matches(method, host, path string, form url.Values) bool {
+	if r.Method != method || r.Host != host || r.Path != path {
+		return false
+	}
+	recorded, err := url.ParseQuery(r.Form)
+	if err != nil {
+		return false
+	}
+	return reflect.DeepEqual(recorded, form)
+}
+
+// Replayer is a http.RoundTripper that answers requests with the responses of a cassette instead of calling AWS.
+// Each interaction is replayed once, and the first unused interaction that matches the request is used. Repeated
+// requests, e.g. retries after throttling or the same page requested again, get the recorded responses in order
+type Replayer struct {
+	cassette *Cassette
+	used     []bool
+	lock     sync.Mutex
+}
+
+// NewReplayer loads the cassette from the json file and creates a replayer for it
+func NewReplayer(path string) (*Replayer, error) {
+	cassette, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Replayer{cassette: cassette, used: make([]bool, len(cassette.Interactions))}, nil
+}
+
+// RoundTrip replays the response of the first unused interaction that matches the request.
+// It returns an error if there isn't one, which the aws sdk reports as a failed request
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readForm(req)
+	if err != nil {
+		return nil, err
+	}
+	form, err := url.ParseQuery(body)
+	if err != nil {
+		return nil, fmt.Errorf("cassette: failed to parse the request form: %v", err)
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for i, interaction := range r.cassette.Interactions {
+		if r.used[i] || !interaction.Request.matches(req.Method, req.URL.Host, req.URL.Path, form) {
+			continue
+		}
+		r.used[i] = true
+		return interaction.Response.httpResponse(req), nil
+	}
+	return nil, fmt.Errorf("cassette: no recorded interaction left for %v %v%v %v", req.Method, req.URL.Host, req.URL.Path, body)
+}
+
+// Unused returns the number of interactions that haven't been replayed yet
+func (r *Replayer) Unused() int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	unused := 0
+	for _, used := range r.used {
+		if !used {
+			unused++
+		}
+	}
+	return unused
+}
+
+// readForm reads the body of the request and replaces it, so it can be read again
+func readForm(req *http.Request) (string, error) {
+	if req.Body == nil {
+		return "", nil
+	}
+	data, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return "", err
+	}
+	req.Body.Close()
+	req.Body = ioutil.NopCloser(bytes.NewReader(data))
+	return string(data), nil
+}
+
+// httpResponse builds the http response of the recorded response
+func (r Response) httpResponse(req *http.Request) *http.Response {
+	header := http.Header{}
+	if len(r.ContentType) > 0 {
+		header.Set("Content-Type", r.ContentType)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", r.StatusCode, http.StatusText(r.StatusCode)),
+		StatusCode:    r.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewBufferString(r.Body)),
+		ContentLength: int64(len(r.Body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,49 @@
+package cassette
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func buildRequest(t *testing.T, form string) *http.Request {
+	req, err := http.NewRequest("POST", "https://autoscaling.us-east-1.amazonaws.com/", strings.NewReader(form))
+	require.NoError(t, err)
+	return req
+}
+
+func TestReplayer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cassette")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	request := Request{Method: "POST", Host: "autoscaling.us-east-1.amazonaws.com", Path: "/", Form: "Action=Describe&Name=a"}
+	path := filepath.Join(dir, "cassette.json")
+	cassette := &Cassette{Interactions: []Interaction{
+		{Request: request, Response: Response{StatusCode: 400, Body: "throttled"}},
+		{Request: request, Response: Response{StatusCode: 200, Body: "ok"}},
+	}}
+	require.NoError(t, cassette.Save(path))
+
+	replayer, err := NewReplayer(path)
+	require.NoError(t, err)
+	assert.Equal(t, 2, replayer.Unused())
+
+	// the form is matched regardless of the order of the values
+	for _, want := range []string{"throttled", "ok"} {
+		resp, err := replayer.RoundTrip(buildRequest(t, "Name=a&Action=Describe"))
+		require.NoError(t, err)
+		body, _ := ioutil.ReadAll(resp.Body)
+		assert.Equal(t, want, string(body))
+	}
+	assert.Equal(t, 0, replayer.Unused())
+
+	_, err = replayer.RoundTrip(buildRequest(t, "Action=Describe&Name=a"))
+	assert.Error(t, err)
+}
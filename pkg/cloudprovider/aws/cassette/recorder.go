@@ -0,0 +1,70 @@
+//go:build recorder
+// +build recorder
+
+package cassette
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"sync"
+)
+
+// Recorder is a http.RoundTripper that sends the requests to AWS and records the interactions into a cassette.
+// It is only built with the recorder build tag, as it needs real credentials
+type Recorder struct {
+	transport http.RoundTripper
+	cassette  Cassette
+	lock      sync.Mutex
+}
+
+// NewRecorder creates a recorder that sends the requests with the transport, or the default transport if it is nil
+func NewRecorder(transport http.RoundTripper) *Recorder {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return &Recorder{transport: transport}
+}
+
+// RoundTrip sends the request and records it along with the response
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	form, err := readForm(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := r.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.cassette.Interactions = append(r.cassette.Interactions, Interaction{
+		Request: Request{
+			Method: req.Method,
+			Host:   req.URL.Host,
+			Path:   req.URL.Path,
+			Form:   form,
+		},
+		Response: Response{
+			StatusCode:  resp.StatusCode,
+			ContentType: resp.Header.Get("Content-Type"),
+			Body:        string(body),
+		},
+	})
+	return resp, nil
+}
+
+// Save writes the interactions recorded so far to the json file
+func (r *Recorder) Save(path string) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.cassette.Save(path)
+}
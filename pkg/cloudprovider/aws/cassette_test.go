@@ -0,0 +1,101 @@
+package aws
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/atlassian/escalator/pkg/cloudprovider"
+	"github.com/atlassian/escalator/pkg/cloudprovider/aws/cassette"
+	"github.com/atlassian/escalator/pkg/test"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const cassetteGroupID = "escalator-integration"
+
+// replayCloudProvider creates a cloud provider whose api calls are answered from the cassette in testdata/cassettes
+func replayCloudProvider(t *testing.T, name string) (*CloudProvider, *cassette.Replayer) {
+	replayer, err := cassette.NewReplayer(filepath.Join("testdata", "cassettes", name+".json"))
+	require.NoError(t, err)
+
+	// the replayer isn't an *http.Transport, so a CA bundle set in the environment can't be loaded into it
+	if bundle, ok := os.LookupEnv("AWS_CA_BUNDLE"); ok {
+		os.Unsetenv("AWS_CA_BUNDLE")
+		defer os.Setenv("AWS_CA_BUNDLE", bundle)
+	}
+	sess, err := session.NewSession(&aws.Config{
+		Region:      aws.String("us-east-1"),
+		Credentials: credentials.NewStaticCredentials("AKIDEXAMPLE", "secret", ""),
+		HTTPClient:  &http.Client{Transport: replayer},
+	})
+	require.NoError(t, err)
+
+	config := request.WithRetryer(aws.NewConfig(), newThrottleAwareRetryer(3, RetryModeStandard))
+	return &CloudProvider{
+		service:     autoscaling.New(sess, config),
+		ec2_service: ec2.New(sess, config),
+		nodeGroups:  make(map[string]*NodeGroup),
+	}, replayer
+}
+
+func TestCassette_RegisterNodeGroupsRetriesThrottling(t *testing.T) {
+	cloud, replayer := replayCloudProvider(t, "register_node_groups_throttled")
+
+	require.NoError(t, cloud.RegisterNodeGroups(context.Background(), cloudprovider.NodeGroupConfig{GroupID: cassetteGroupID}))
+	nodeGroup, ok := cloud.GetNodeGroup(cassetteGroupID)
+	require.True(t, ok)
+	assert.Equal(t, int64(3), nodeGroup.TargetSize())
+	assert.Equal(t, int64(3), nodeGroup.Size())
+	assert.Equal(t, 0, replayer.Unused())
+}
+
+func TestCassette_AllInstancesReadyPaginates(t *testing.T) {
+	ids := aws.StringSlice([]string{"i-0a1b2c3d4e5f00001", "i-0a1b2c3d4e5f00002", "i-0a1b2c3d4e5f00003"})
+	tests := []struct {
+		cassette string
+		want     bool
+	}{
+		{"instance_status_pages_running", true},
+		{"instance_status_pages_pending", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.cassette, func(t *testing.T) {
+			cloud, replayer := replayCloudProvider(t, tt.cassette)
+			nodeGroup := NewNodeGroup(&cloudprovider.NodeGroupConfig{GroupID: cassetteGroupID}, &autoscaling.Group{}, cloud)
+
+			assert.Equal(t, tt.want, nodeGroup.allInstancesReady(context.Background(), ids))
+			assert.Equal(t, 0, replayer.Unused())
+		})
+	}
+}
+
+func TestCassette_DeleteNodesPartialFailure(t *testing.T) {
+	cloud, replayer := replayCloudProvider(t, "delete_nodes_partial_failure")
+	require.NoError(t, cloud.RegisterNodeGroups(context.Background(), cloudprovider.NodeGroupConfig{GroupID: cassetteGroupID}))
+	nodeGroup, ok := cloud.GetNodeGroup(cassetteGroupID)
+	require.True(t, ok)
+
+	terminated := test.BuildTestNode(test.NodeOpts{Name: "n1"})
+	terminated.Spec.ProviderID = "aws:///us-east-1a/i-0a1b2c3d4e5f00001"
+	missing := test.BuildTestNode(test.NodeOpts{Name: "n2"})
+	missing.Spec.ProviderID = "aws:///us-east-1a/i-0a1b2c3d4e5f00002"
+
+	// the first instance is terminated before the second fails, the error isn't retried and names only the second node
+	err := nodeGroup.DeleteNodes(context.Background(), terminated, missing)
+	require.Error(t, err)
+	notDeleted, ok := err.(*cloudprovider.NodesNotDeleted)
+	require.True(t, ok)
+	if assert.Len(t, notDeleted.Failed, 1) {
+		assert.Contains(t, notDeleted.Failed["n2"].Error(), "ValidationError")
+	}
+	assert.Equal(t, 0, replayer.Unused())
+}
@@ -1,6 +1,7 @@
 package aws
 
 import (
+	"context"
 	"fmt"
 	"testing"
 
@@ -211,7 +212,7 @@ func TestCloudProvider_Refresh(t *testing.T) {
 	}
 
 	// Refresh the cloud provider
-	err = awsCloudProvider.Refresh()
+	err = awsCloudProvider.Refresh(context.Background())
 	assert.Nil(t, err)
 
 	// Ensure the node group has been refreshed
@@ -270,7 +271,7 @@ func TestCloudProvider_GetInstance(t *testing.T) {
 
 			assert.Nil(t, err)
 
-			instance, err := awsCloudProvider.GetInstance(node)
+			instance, err := awsCloudProvider.GetInstance(context.Background(), node)
 			assert.Equal(t, tt.err, err)
 			if tt.err != nil {
 				assert.Nil(t, instance)
@@ -0,0 +1,99 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/atlassian/escalator/pkg/cloudprovider"
+	"github.com/atlassian/escalator/pkg/errorkind"
+	awsapi "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/eks"
+	log "github.com/sirupsen/logrus"
+)
+
+// managed returns whether the node group is an EKS managed node group. The size of a managed node group is owned by
+// EKS, so it's changed through the EKS api instead of the auto scaling group
+func (n *NodeGroup) managed() bool {
+	return len(n.config.AWSConfig.EKSNodegroupName) > 0
+}
+
+// describeEKSNodegroup describes the EKS managed node group of the config, which must be backed by the auto scaling
+// group of the config
+func (c *CloudProvider) describeEKSNodegroup(ctx context.Context, config *cloudprovider.NodeGroupConfig) (*eks.Nodegroup, error) {
+	if c.eks_service == nil {
+		return nil, fmt.Errorf("node group %v is an EKS managed node group, but the EKS api isn't set up", config.GroupID)
+	}
+	output, err := c.eks_service.DescribeNodegroupWithContext(ctx, &eks.DescribeNodegroupInput{
+		ClusterName:   awsapi.String(config.AWSConfig.EKSClusterName),
+		NodegroupName: awsapi.String(config.AWSConfig.EKSNodegroupName),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe EKS managed node group %v of cluster %v: %v", config.AWSConfig.EKSNodegroupName, config.AWSConfig.EKSClusterName, err)
+	}
+
+	nodegroup := output.Nodegroup
+	var asgs []string
+	if nodegroup.Resources != nil {
+		for _, asg := range nodegroup.Resources.AutoScalingGroups {
+			if awsapi.StringValue(asg.Name) == config.GroupID {
+				return nodegroup, nil
+			}
+			asgs = append(asgs, awsapi.StringValue(asg.Name))
+		}
+	}
+	return nil, errorkind.New(
+		errorkind.Validation,
+		"EKS managed node group %v of cluster %v is backed by the auto scaling groups %v, not %v",
+		config.AWSConfig.EKSNodegroupName, config.AWSConfig.EKSClusterName, asgs, config.GroupID,
+	)
+}
+
+// eksScalingConfig returns the scaling config of the EKS managed node group, nil if it isn't known
+func (n *NodeGroup) eksScalingConfig() *eks.NodegroupScalingConfig {
+	if n.eksNodegroup == nil {
+		return nil
+	}
+	return n.eksNodegroup.ScalingConfig
+}
+
+// eksResizable returns an error if the EKS managed node group can't be resized now, i.e. it isn't active or degraded
+func (n *NodeGroup) eksResizable() error {
+	if n.eksNodegroup == nil {
+		return fmt.Errorf("EKS managed node group %v hasn't been described", n.config.AWSConfig.EKSNodegroupName)
+	}
+	status := awsapi.StringValue(n.eksNodegroup.Status)
+	if status != eks.NodegroupStatusActive && status != eks.NodegroupStatusDegraded {
+		return errorkind.New(errorkind.Conflict, "EKS managed node group %v can't be resized while it is %v", n.config.AWSConfig.EKSNodegroupName, status)
+	}
+	return nil
+}
+
+// setEKSDesiredSize sets the desired size of the EKS managed node group through the EKS api. EKS applies the update
+// asynchronously and only one update of a node group can run at a time, so the node group isn't resized again until
+// the next refresh shows the update finished.
+// user must make sure that newSize is not out of bounds of the node group
+func (n *NodeGroup) setEKSDesiredSize(ctx context.Context, newSize int64) error {
+	if err := n.eksResizable(); err != nil {
+		return err
+	}
+
+	log.WithField("asg", n.id).Debugf("UpdateNodegroupConfig: desired size %v", newSize)
+	output, err := n.provider.eks_service.UpdateNodegroupConfigWithContext(ctx, &eks.UpdateNodegroupConfigInput{
+		ClusterName:   awsapi.String(n.config.AWSConfig.EKSClusterName),
+		NodegroupName: awsapi.String(n.config.AWSConfig.EKSNodegroupName),
+		ScalingConfig: &eks.NodegroupScalingConfig{DesiredSize: awsapi.Int64(newSize)},
+	})
+	if err != nil {
+		return err
+	}
+	if output.Update != nil {
+		log.WithField("asg", n.id).Debugf("EKS update %v is %v", awsapi.StringValue(output.Update.Id), awsapi.StringValue(output.Update.Status))
+	}
+
+	if n.eksNodegroup.ScalingConfig == nil {
+		n.eksNodegroup.ScalingConfig = &eks.NodegroupScalingConfig{}
+	}
+	n.eksNodegroup.ScalingConfig.DesiredSize = awsapi.Int64(newSize)
+	n.eksNodegroup.Status = awsapi.String(eks.NodegroupStatusUpdating)
+	return nil
+}
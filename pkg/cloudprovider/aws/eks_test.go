@@ -0,0 +1,173 @@
+package aws
+
+import (
+	"context"
+	"testing"
+
+	"github.com/atlassian/escalator/pkg/cloudprovider"
+	"github.com/atlassian/escalator/pkg/errorkind"
+	"github.com/atlassian/escalator/pkg/test"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/eks"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+)
+
+func buildEKSTestNodegroup(status string, asgName string) *eks.Nodegroup {
+	return &eks.Nodegroup{
+		ClusterName:   aws.String("prod"),
+		NodegroupName: aws.String("workers"),
+		Status:        aws.String(status),
+		ScalingConfig: &eks.NodegroupScalingConfig{
+			MinSize:     aws.Int64(1),
+			MaxSize:     aws.Int64(10),
+			DesiredSize: aws.Int64(3),
+		},
+		Resources: &eks.NodegroupResources{
+			AutoScalingGroups: []*eks.AutoScalingGroup{{Name: aws.String(asgName)}},
+		},
+	}
+}
+
+func newEKSMockCloudProvider(nodegroup *eks.Nodegroup, eksService test.MockEKSService, terminateErr error) (*CloudProvider, error) {
+	eksService.DescribeNodegroupOutput = &eks.DescribeNodegroupOutput{Nodegroup: nodegroup}
+	cloudProvider := &CloudProvider{
+		service: &test.MockAutoscalingService{
+			DescribeAutoScalingGroupsOutput: &autoscaling.DescribeAutoScalingGroupsOutput{
+				AutoScalingGroups: []*autoscaling.Group{
+					{
+						AutoScalingGroupName: aws.String("eks-workers"),
+						MinSize:              aws.Int64(0),
+						MaxSize:              aws.Int64(100),
+						DesiredCapacity:      aws.Int64(3),
+						Instances: []*autoscaling.Instance{
+							{InstanceId: aws.String("instance-1"), AvailabilityZone: aws.String("us-east-1a")},
+							{InstanceId: aws.String("instance-2"), AvailabilityZone: aws.String("us-east-1a")},
+							{InstanceId: aws.String("instance-3"), AvailabilityZone: aws.String("us-east-1a")},
+						},
+					},
+				},
+			},
+			TerminateInstanceInAutoScalingGroupOutput: &autoscaling.TerminateInstanceInAutoScalingGroupOutput{
+				Activity: &autoscaling.Activity{Description: aws.String("successfully terminated instance")},
+			},
+			TerminateInstanceInAutoScalingGroupErr: terminateErr,
+		},
+		ec2_service: &test.MockEc2Service{},
+		eks_service: eksService,
+		nodeGroups:  make(map[string]*NodeGroup),
+	}
+	err := cloudProvider.RegisterNodeGroups(context.Background(), cloudprovider.NodeGroupConfig{
+		GroupID:   "eks-workers",
+		AWSConfig: cloudprovider.AWSNodeGroupConfig{EKSClusterName: "prod", EKSNodegroupName: "workers"},
+	})
+	return cloudProvider, err
+}
+
+func TestCloudProvider_RegisterEKSNodegroup(t *testing.T) {
+	cloudProvider, err := newEKSMockCloudProvider(buildEKSTestNodegroup(eks.NodegroupStatusActive, "eks-workers"), test.MockEKSService{}, nil)
+	require.NoError(t, err)
+
+	nodeGroup, ok := cloudProvider.GetNodeGroup("eks-workers")
+	require.True(t, ok)
+	// the size is read from EKS, not the asg
+	assert.Equal(t, int64(1), nodeGroup.MinSize())
+	assert.Equal(t, int64(10), nodeGroup.MaxSize())
+	assert.Equal(t, int64(3), nodeGroup.TargetSize())
+	assert.Equal(t, int64(3), nodeGroup.Size())
+
+	_, err = newEKSMockCloudProvider(buildEKSTestNodegroup(eks.NodegroupStatusActive, "eks-other"), test.MockEKSService{}, nil)
+	assert.True(t, errorkind.Is(err, errorkind.Validation))
+
+	_, err = newEKSMockCloudProvider(nil, test.MockEKSService{DescribeNodegroupErr: errors.New("AccessDenied")}, nil)
+	assert.Error(t, err)
+}
+
+func TestNodeGroup_EKSIncreaseSize(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     string
+		updateErr  error
+		delta      int64
+		wantErr    bool
+		wantTarget int64
+	}{
+		{"active", eks.NodegroupStatusActive, nil, 2, false, 5},
+		{"degraded", eks.NodegroupStatusDegraded, nil, 2, false, 5},
+		{"updating", eks.NodegroupStatusUpdating, nil, 2, true, 3},
+		{"update fails", eks.NodegroupStatusActive, errors.New("ResourceInUseException"), 2, true, 3},
+		{"breach maximum size", eks.NodegroupStatusActive, nil, 8, true, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			eksService := test.MockEKSService{
+				UpdateNodegroupConfigOutput: &eks.UpdateNodegroupConfigOutput{Update: &eks.Update{Id: aws.String("update-1"), Status: aws.String("InProgress")}},
+				UpdateNodegroupConfigErr:    tt.updateErr,
+			}
+			cloudProvider, err := newEKSMockCloudProvider(buildEKSTestNodegroup(tt.status, "eks-workers"), eksService, nil)
+			require.NoError(t, err)
+			nodeGroup, _ := cloudProvider.GetNodeGroup("eks-workers")
+
+			err = nodeGroup.IncreaseSize(context.Background(), tt.delta)
+			assert.Equal(t, tt.wantErr, err != nil, "error: %v", err)
+			assert.Equal(t, tt.wantTarget, nodeGroup.TargetSize())
+		})
+	}
+
+	// the node group isn't resized again until EKS finished the update
+	cloudProvider, err := newEKSMockCloudProvider(buildEKSTestNodegroup(eks.NodegroupStatusActive, "eks-workers"), test.MockEKSService{UpdateNodegroupConfigOutput: &eks.UpdateNodegroupConfigOutput{}}, nil)
+	require.NoError(t, err)
+	nodeGroup, _ := cloudProvider.GetNodeGroup("eks-workers")
+	require.NoError(t, nodeGroup.IncreaseSize(context.Background(), 1))
+	err = nodeGroup.IncreaseSize(context.Background(), 1)
+	assert.True(t, errorkind.Is(err, errorkind.Conflict))
+}
+
+func TestNodeGroup_EKSDecreaseTargetSize(t *testing.T) {
+	cloudProvider, err := newEKSMockCloudProvider(buildEKSTestNodegroup(eks.NodegroupStatusActive, "eks-workers"), test.MockEKSService{UpdateNodegroupConfigOutput: &eks.UpdateNodegroupConfigOutput{}}, nil)
+	require.NoError(t, err)
+	nodeGroup, _ := cloudProvider.GetNodeGroup("eks-workers")
+
+	require.NoError(t, nodeGroup.DecreaseTargetSize(context.Background(), -1))
+	assert.Equal(t, int64(2), nodeGroup.TargetSize())
+}
+
+func TestNodeGroup_EKSDeleteNodes(t *testing.T) {
+	nodes := []*v1.Node{
+		{Spec: v1.NodeSpec{ProviderID: "aws:///us-east-1a/instance-2"}},
+	}
+
+	tests := []struct {
+		name         string
+		status       string
+		updateErr    error
+		terminateErr error
+		wantErr      bool
+		wantTarget   int64
+	}{
+		{"terminated and resized", eks.NodegroupStatusActive, nil, nil, false, 2},
+		// the instance is already terminating, so failing to resize the EKS node group isn't a failed deletion
+		{"resize fails", eks.NodegroupStatusActive, errors.New("ResourceInUseException"), nil, false, 3},
+		{"terminate fails", eks.NodegroupStatusActive, nil, errors.New("ValidationError"), true, 3},
+		// nothing is terminated while the EKS node group can't be resized after it, the deletion is retried
+		{"node group updating", eks.NodegroupStatusUpdating, nil, nil, true, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			eksService := test.MockEKSService{
+				UpdateNodegroupConfigOutput: &eks.UpdateNodegroupConfigOutput{},
+				UpdateNodegroupConfigErr:    tt.updateErr,
+			}
+			cloudProvider, err := newEKSMockCloudProvider(buildEKSTestNodegroup(tt.status, "eks-workers"), eksService, tt.terminateErr)
+			require.NoError(t, err)
+			nodeGroup, _ := cloudProvider.GetNodeGroup("eks-workers")
+
+			err = nodeGroup.DeleteNodes(context.Background(), nodes...)
+			assert.Equal(t, tt.wantErr, err != nil, "error: %v", err)
+			assert.Equal(t, tt.wantTarget, nodeGroup.TargetSize())
+		})
+	}
+}
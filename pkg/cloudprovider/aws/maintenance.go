@@ -0,0 +1,76 @@
+package aws
+
+import (
+	"context"
+	"strings"
+
+	"github.com/atlassian/escalator/pkg/cloudprovider"
+	awsapi "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	v1 "k8s.io/api/core/v1"
+)
+
+// instanceStatusBatchSize is the most instance ids DescribeInstanceStatus accepts in a call
+const instanceStatusBatchSize = 100
+
+// the description of a scheduled event is prefixed with its state once it is no longer going to happen
+var finishedEventPrefixes = []string{"[Completed]", "[Canceled]"}
+
+// MaintenanceEvents returns the scheduled events of the ec2 instances of the nodes, e.g. a system-reboot for host
+// maintenance or an instance-retirement, that haven't completed or been cancelled. Nodes that don't belong to the node
+// group are left out
+func (n *NodeGroup) MaintenanceEvents(ctx context.Context, nodes ...*v1.Node) ([]cloudprovider.MaintenanceEvent, error) {
+	nodeNames := make(map[string]string, len(nodes))
+	instanceIDs := make([]*string, 0, len(nodes))
+	for _, node := range nodes {
+		instanceID, err := nodeInstanceID(node)
+		if err != nil || !n.Belongs(node) {
+			continue
+		}
+		nodeNames[instanceID] = node.Name
+		instanceIDs = append(instanceIDs, awsapi.String(instanceID))
+	}
+
+	var events []cloudprovider.MaintenanceEvent
+	for len(instanceIDs) > 0 {
+		batch := instanceIDs
+		if len(batch) > instanceStatusBatchSize {
+			batch = instanceIDs[:instanceStatusBatchSize]
+		}
+		instanceIDs = instanceIDs[len(batch):]
+
+		err := n.provider.ec2_service.DescribeInstanceStatusPagesWithContext(ctx, &ec2.DescribeInstanceStatusInput{
+			InstanceIds: batch,
+		}, func(output *ec2.DescribeInstanceStatusOutput, lastPage bool) bool {
+			for _, status := range output.InstanceStatuses {
+				for _, event := range status.Events {
+					if scheduledEventFinished(event) {
+						continue
+					}
+					events = append(events, cloudprovider.MaintenanceEvent{
+						NodeName:    nodeNames[awsapi.StringValue(status.InstanceId)],
+						Code:        awsapi.StringValue(event.Code),
+						Description: awsapi.StringValue(event.Description),
+						NotBefore:   awsapi.TimeValue(event.NotBefore),
+					})
+				}
+			}
+			return true
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return events, nil
+}
+
+// scheduledEventFinished returns whether the scheduled event completed or was cancelled
+func scheduledEventFinished(event *ec2.InstanceStatusEvent) bool {
+	description := awsapi.StringValue(event.Description)
+	for _, prefix := range finishedEventPrefixes {
+		if strings.HasPrefix(description, prefix) {
+			return true
+		}
+	}
+	return false
+}
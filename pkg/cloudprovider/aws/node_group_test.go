@@ -1,13 +1,16 @@
 package aws
 
 import (
+	"context"
 	"math/rand"
 	"testing"
+	"time"
 
 	"github.com/atlassian/escalator/pkg/cloudprovider"
 	"github.com/atlassian/escalator/pkg/test"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -171,7 +174,7 @@ func TestNodeGroup_IncreaseSize(t *testing.T) {
 			assert.Nil(t, err)
 
 			for _, nodeGroup := range awsCloudProvider.NodeGroups() {
-				err = nodeGroup.IncreaseSize(tt.increaseSize)
+				err = nodeGroup.IncreaseSize(context.Background(), tt.increaseSize)
 				if tt.err == nil {
 					require.NoError(t, err)
 				} else {
@@ -182,6 +185,164 @@ func TestNodeGroup_IncreaseSize(t *testing.T) {
 	}
 }
 
+func TestNodeGroup_IncreaseSizeWithMetadata(t *testing.T) {
+	metadata := cloudprovider.ScaleUpMetadata{NodeGroup: "shared", CycleID: "20190101T000000Z", Reason: "scale_up"}
+	for _, tagErr := range []error{nil, errors.New("not authorized")} {
+		asg := &autoscaling.Group{
+			AutoScalingGroupName: aws.String("asg-1"),
+			MaxSize:              aws.Int64(int64(10)),
+			DesiredCapacity:      aws.Int64(int64(1)),
+		}
+		awsCloudProvider, err := newMockCloudProvider([]string{"asg-1"}, &test.MockAutoscalingService{
+			DescribeAutoScalingGroupsOutput: &autoscaling.DescribeAutoScalingGroupsOutput{
+				AutoScalingGroups: []*autoscaling.Group{asg},
+			},
+			CreateOrUpdateTagsErr: tagErr,
+		}, nil)
+		require.NoError(t, err)
+
+		nodeGroup, ok := awsCloudProvider.GetNodeGroup("asg-1")
+		require.True(t, ok)
+		increaser, ok := nodeGroup.(cloudprovider.MetadataIncreaser)
+		require.True(t, ok)
+		// failing to tag the asg doesn't stop the scale up
+		assert.NoError(t, increaser.IncreaseSizeWithMetadata(context.Background(), 2, metadata))
+		assert.EqualError(t, increaser.IncreaseSizeWithMetadata(context.Background(), 20, metadata), "increasing size will breach maximum node size")
+	}
+}
+
+func TestNodeGroup_ScalingActivities(t *testing.T) {
+	now := time.Now()
+	activity := func(id string, status string, start time.Time) *autoscaling.Activity {
+		return &autoscaling.Activity{
+			ActivityId:    aws.String(id),
+			Description:   aws.String("Launching a new EC2 instance"),
+			StatusCode:    aws.String(status),
+			StatusMessage: aws.String(status + " message"),
+			StartTime:     aws.Time(start),
+		}
+	}
+	awsCloudProvider, err := newMockCloudProvider([]string{"asg-1"}, &test.MockAutoscalingService{
+		DescribeAutoScalingGroupsOutput: &autoscaling.DescribeAutoScalingGroupsOutput{
+			AutoScalingGroups: []*autoscaling.Group{{AutoScalingGroupName: aws.String("asg-1")}},
+		},
+		DescribeScalingActivitiesOutput: &autoscaling.DescribeScalingActivitiesOutput{
+			Activities: []*autoscaling.Activity{
+				activity("in-progress", autoscaling.ScalingActivityStatusCodeInProgress, now),
+				activity("failed", autoscaling.ScalingActivityStatusCodeFailed, now),
+				activity("cancelled", autoscaling.ScalingActivityStatusCodeCancelled, now),
+				activity("successful", autoscaling.ScalingActivityStatusCodeSuccessful, now),
+				activity("old", autoscaling.ScalingActivityStatusCodeFailed, now.Add(-time.Hour)),
+			},
+		},
+	}, nil)
+	require.NoError(t, err)
+
+	nodeGroup, ok := awsCloudProvider.GetNodeGroup("asg-1")
+	require.True(t, ok)
+	reader, ok := nodeGroup.(cloudprovider.ScalingActivityReader)
+	require.True(t, ok)
+	activities, err := reader.ScalingActivities(context.Background(), now.Add(-time.Minute))
+	require.NoError(t, err)
+
+	require.Len(t, activities, 4)
+	assert.Equal(t, cloudprovider.ScalingActivity{
+		ID:            "failed",
+		Description:   "Launching a new EC2 instance",
+		StatusMessage: "Failed message",
+		StartTime:     now,
+		Done:          true,
+		Failed:        true,
+	}, activities[1])
+	assert.False(t, activities[0].Done)
+	assert.True(t, activities[2].Failed)
+	assert.True(t, activities[3].Done)
+	assert.False(t, activities[3].Failed)
+}
+
+func TestFleetTagSpecifications(t *testing.T) {
+	assert.Nil(t, fleetTagSpecifications(nil))
+
+	specs := fleetTagSpecifications(map[string]string{
+		cloudprovider.ScaleUpReasonTagKey:    "scale_up",
+		cloudprovider.ScaleUpNodeGroupTagKey: "shared",
+	})
+	require.Len(t, specs, 1)
+	assert.Equal(t, "instance", aws.StringValue(specs[0].ResourceType))
+	require.Len(t, specs[0].Tags, 2)
+	assert.Equal(t, cloudprovider.ScaleUpNodeGroupTagKey, aws.StringValue(specs[0].Tags[0].Key))
+	assert.Equal(t, "shared", aws.StringValue(specs[0].Tags[0].Value))
+	assert.Equal(t, cloudprovider.ScaleUpReasonTagKey, aws.StringValue(specs[0].Tags[1].Key))
+}
+
+func TestNodeGroup_TagInstances(t *testing.T) {
+	asg := &autoscaling.Group{
+		Instances: []*autoscaling.Instance{
+			{InstanceId: aws.String("i-1"), AvailabilityZone: aws.String("us-east-1a")},
+		},
+	}
+	node := &v1.Node{ObjectMeta: metaV1.ObjectMeta{Name: "n1"}, Spec: v1.NodeSpec{ProviderID: "aws:///us-east-1a/i-1"}}
+	otherNode := &v1.Node{ObjectMeta: metaV1.ObjectMeta{Name: "n2"}, Spec: v1.NodeSpec{ProviderID: "aws:///us-east-1a/i-2"}}
+	tags := map[string]string{cloudprovider.ToBeRemovedTagKey: "2019-03-01T03:12:00Z"}
+
+	t.Run("tagged", func(t *testing.T) {
+		nodeGroup := NewNodeGroup(&cloudprovider.NodeGroupConfig{GroupID: "nodegroup"}, asg, &CloudProvider{
+			ec2_service: &test.MockEc2Service{},
+		})
+		assert.NoError(t, nodeGroup.TagInstances(context.Background(), tags, node))
+		assert.NoError(t, nodeGroup.UntagInstances(context.Background(), []string{cloudprovider.ToBeRemovedTagKey}, node))
+	})
+
+	t.Run("node of another node group", func(t *testing.T) {
+		nodeGroup := NewNodeGroup(&cloudprovider.NodeGroupConfig{GroupID: "nodegroup"}, asg, &CloudProvider{
+			ec2_service: &test.MockEc2Service{},
+		})
+		err := nodeGroup.TagInstances(context.Background(), tags, node, otherNode)
+		require.Error(t, err)
+		assert.IsType(t, &cloudprovider.NodeNotInNodeGroup{}, err)
+		assert.Error(t, nodeGroup.UntagInstances(context.Background(), []string{cloudprovider.ToBeRemovedTagKey}, otherNode))
+	})
+
+	t.Run("api error", func(t *testing.T) {
+		nodeGroup := NewNodeGroup(&cloudprovider.NodeGroupConfig{GroupID: "nodegroup"}, asg, &CloudProvider{
+			ec2_service: &test.MockEc2Service{
+				CreateTagsErr: errors.New("UnauthorizedOperation"),
+				DeleteTagsErr: errors.New("UnauthorizedOperation"),
+			},
+		})
+		assert.Error(t, nodeGroup.TagInstances(context.Background(), tags, node))
+		assert.Error(t, nodeGroup.UntagInstances(context.Background(), []string{cloudprovider.ToBeRemovedTagKey}, node))
+	})
+}
+
+func TestNodeGroup_Belongs(t *testing.T) {
+	asg := &autoscaling.Group{
+		Instances: []*autoscaling.Instance{
+			{InstanceId: aws.String("i-1"), AvailabilityZone: aws.String("us-east-1a")},
+		},
+	}
+	nodeGroup := NewNodeGroup(&cloudprovider.NodeGroupConfig{GroupID: "nodegroup"}, asg, &CloudProvider{})
+
+	tests := []struct {
+		name       string
+		providerID string
+		want       bool
+	}{
+		{"provider id with zone", "aws:///us-east-1a/i-1", true},
+		{"provider id without zone", "aws:///i-1", true},
+		{"other instance", "aws:///us-east-1a/i-2", false},
+		{"no provider id", "", false},
+		{"other cloud provider", "gce://project/us-central1-a/i-1", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the node name doesn't follow any hostname convention, only the provider id is used
+			node := &v1.Node{ObjectMeta: metaV1.ObjectMeta{Name: "custom-hostname"}, Spec: v1.NodeSpec{ProviderID: tt.providerID}}
+			assert.Equal(t, tt.want, nodeGroup.Belongs(node))
+		})
+	}
+}
+
 func TestNodeGroup_DeleteNodes(t *testing.T) {
 	type group struct {
 		asg                                       *autoscaling.Group
@@ -348,7 +509,7 @@ func TestNodeGroup_DeleteNodes(t *testing.T) {
 					},
 					&autoscaling.TerminateInstanceInAutoScalingGroupOutput{},
 					errors.New("unable to terminate instance"),
-					errors.New("failed to terminate instance. err: unable to terminate instance"),
+					errors.New("failed to delete 1 nodes: instance-2: failed to terminate instance. err: unable to terminate instance"),
 				},
 			},
 		},
@@ -384,7 +545,7 @@ func TestNodeGroup_DeleteNodes(t *testing.T) {
 				// Terminate the instances
 				mockAutoScalingService.TerminateInstanceInAutoScalingGroupOutput = group.terminateInstanceInAutoScalingGroupOutput
 				mockAutoScalingService.TerminateInstanceInAutoScalingGroupErr = group.terminateInstanceInAutoScalingGroupErr
-				err := nodeGroup.DeleteNodes(group.nodesToDelete...)
+				err := nodeGroup.DeleteNodes(context.Background(), group.nodesToDelete...)
 				if group.err == nil {
 					require.NoError(t, err)
 				} else {
@@ -468,7 +629,7 @@ func TestNodeGroup_DecreaseSize(t *testing.T) {
 			assert.Nil(t, err)
 
 			for _, nodeGroup := range awsCloudProvider.NodeGroups() {
-				err = nodeGroup.DecreaseTargetSize(tt.decreaseSize)
+				err = nodeGroup.DecreaseTargetSize(context.Background(), tt.decreaseSize)
 				if tt.err == nil {
 					require.NoError(t, err)
 				} else {
@@ -478,3 +639,56 @@ func TestNodeGroup_DecreaseSize(t *testing.T) {
 		})
 	}
 }
+
+func TestNodeGroup_MaintenanceEvents(t *testing.T) {
+	notBefore := time.Date(2020, 3, 1, 3, 0, 0, 0, time.UTC)
+	asg := &autoscaling.Group{
+		Instances: []*autoscaling.Instance{
+			{InstanceId: aws.String("i-1"), AvailabilityZone: aws.String("us-east-1a")},
+			{InstanceId: aws.String("i-2"), AvailabilityZone: aws.String("us-east-1a")},
+		},
+	}
+	nodes := []*v1.Node{
+		{ObjectMeta: metaV1.ObjectMeta{Name: "n1"}, Spec: v1.NodeSpec{ProviderID: "aws:///us-east-1a/i-1"}},
+		{ObjectMeta: metaV1.ObjectMeta{Name: "n2"}, Spec: v1.NodeSpec{ProviderID: "aws:///us-east-1a/i-2"}},
+		// not in the node group
+		{ObjectMeta: metaV1.ObjectMeta{Name: "n3"}, Spec: v1.NodeSpec{ProviderID: "aws:///us-east-1a/i-3"}},
+	}
+	event := func(code string, description string) *ec2.InstanceStatusEvent {
+		return &ec2.InstanceStatusEvent{Code: aws.String(code), Description: aws.String(description), NotBefore: aws.Time(notBefore)}
+	}
+
+	t.Run("scheduled events", func(t *testing.T) {
+		nodeGroup := NewNodeGroup(&cloudprovider.NodeGroupConfig{GroupID: "nodegroup"}, asg, &CloudProvider{
+			ec2_service: &test.MockEc2Service{
+				DescribeInstanceStatusOutput: &ec2.DescribeInstanceStatusOutput{
+					InstanceStatuses: []*ec2.InstanceStatus{
+						{InstanceId: aws.String("i-1"), Events: []*ec2.InstanceStatusEvent{
+							event(ec2.EventCodeInstanceRetirement, "The instance is running on degraded hardware"),
+						}},
+						{InstanceId: aws.String("i-2"), Events: []*ec2.InstanceStatusEvent{
+							event(ec2.EventCodeSystemReboot, "[Completed] Scheduled reboot"),
+							event(ec2.EventCodeSystemMaintenance, "[Canceled] Scheduled maintenance"),
+						}},
+					},
+				},
+			},
+		})
+		events, err := nodeGroup.MaintenanceEvents(context.Background(), nodes...)
+		require.NoError(t, err)
+		assert.Equal(t, []cloudprovider.MaintenanceEvent{{
+			NodeName:    "n1",
+			Code:        ec2.EventCodeInstanceRetirement,
+			Description: "The instance is running on degraded hardware",
+			NotBefore:   notBefore,
+		}}, events)
+	})
+
+	t.Run("api error", func(t *testing.T) {
+		nodeGroup := NewNodeGroup(&cloudprovider.NodeGroupConfig{GroupID: "nodegroup"}, asg, &CloudProvider{
+			ec2_service: &test.MockEc2Service{DescribeInstanceStatusErr: errors.New("UnauthorizedOperation")},
+		})
+		_, err := nodeGroup.MaintenanceEvents(context.Background(), nodes...)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,81 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/atlassian/escalator/pkg/cloudprovider"
+	awsapi "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	v1 "k8s.io/api/core/v1"
+)
+
+// dryRunOperationCode is the error code returned by EC2 when a dry run request would have succeeded
+const dryRunOperationCode = "DryRunOperation"
+
+// CheckPermissions verifies the ec2 permissions needed to manage the node groups using the EC2 DryRun flag.
+// Describing the auto scaling groups is already verified when they are registered. The auto scaling write
+// permissions can't be verified as the auto scaling api has no dry run support
+func (c *CloudProvider) CheckPermissions(ctx context.Context) []error {
+	var errs []error
+
+	_, err := c.ec2_service.DescribeInstancesWithContext(ctx, &ec2.DescribeInstancesInput{DryRun: awsapi.Bool(true)})
+	if err := dryRunError("ec2:DescribeInstances", err); err != nil {
+		errs = append(errs, err)
+	}
+
+	for _, nodeGroup := range c.nodeGroups {
+		if len(nodeGroup.asg.Instances) == 0 {
+			continue
+		}
+		_, err := c.ec2_service.TerminateInstancesWithContext(ctx, &ec2.TerminateInstancesInput{
+			DryRun:      awsapi.Bool(true),
+			InstanceIds: []*string{nodeGroup.asg.Instances[0].InstanceId},
+		})
+		if err := dryRunError(fmt.Sprintf("ec2:TerminateInstances on %v", nodeGroup.id), err); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errs
+}
+
+// dryRunError interprets the error of an ec2 dry run request
+// returns nil if the request would have succeeded
+func dryRunError(action string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == dryRunOperationCode {
+		return nil
+	}
+	return fmt.Errorf("missing permission for %v: %v", action, err)
+}
+
+// DryRunDeleteNodes checks the nodes can be deleted from the node group using the EC2 DryRun flag.
+// Nothing is terminated. The instances are terminated through the auto scaling api when they are really deleted,
+// which has no dry run support, so the equivalent ec2:TerminateInstances permission is checked instead
+func (n *NodeGroup) DryRunDeleteNodes(ctx context.Context, nodes ...*v1.Node) error {
+	if n.TargetSize()-int64(len(nodes)) < n.MinSize() {
+		return fmt.Errorf("terminating nodes will breach minimum node size")
+	}
+
+	instanceIDs := make([]*string, 0, len(nodes))
+	for _, node := range nodes {
+		instanceID, err := nodeInstanceID(node)
+		if err != nil {
+			return err
+		}
+		if !n.Belongs(node) {
+			return &cloudprovider.NodeNotInNodeGroup{NodeName: node.Name, ProviderID: node.Spec.ProviderID, NodeGroup: n.ID()}
+		}
+		instanceIDs = append(instanceIDs, awsapi.String(instanceID))
+	}
+
+	_, err := n.provider.ec2_service.TerminateInstancesWithContext(ctx, &ec2.TerminateInstancesInput{
+		DryRun:      awsapi.Bool(true),
+		InstanceIds: instanceIDs,
+	})
+	return dryRunError("ec2:TerminateInstances", err)
+}
@@ -0,0 +1,132 @@
+package aws
+
+import (
+	"context"
+	"testing"
+
+	"github.com/atlassian/escalator/pkg/test"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestCloudProvider_CheckPermissions(t *testing.T) {
+	service := &test.MockAutoscalingService{
+		DescribeAutoScalingGroupsOutput: &autoscaling.DescribeAutoScalingGroupsOutput{
+			AutoScalingGroups: []*autoscaling.Group{
+				{
+					AutoScalingGroupName: aws.String("asg"),
+					Instances: []*autoscaling.Instance{
+						{InstanceId: aws.String("i-123"), AvailabilityZone: aws.String("us-east-1a")},
+					},
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		name         string
+		describeErr  error
+		terminateErr error
+		wantErrs     int
+	}{
+		{
+			"all permissions",
+			awserr.New(dryRunOperationCode, "Request would have succeeded", nil),
+			awserr.New(dryRunOperationCode, "Request would have succeeded", nil),
+			0,
+		},
+		{
+			"missing terminate permission",
+			awserr.New(dryRunOperationCode, "Request would have succeeded", nil),
+			awserr.New("UnauthorizedOperation", "You are not authorized to perform this operation", nil),
+			1,
+		},
+		{
+			"missing all permissions",
+			awserr.New("UnauthorizedOperation", "You are not authorized to perform this operation", nil),
+			awserr.New("UnauthorizedOperation", "You are not authorized to perform this operation", nil),
+			2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ec2Service := &test.MockEc2Service{
+				DescribeInstancesErr:  tt.describeErr,
+				TerminateInstancesErr: tt.terminateErr,
+			}
+			cloudProvider, err := newMockCloudProvider([]string{"asg"}, service, ec2Service)
+			assert.NoError(t, err)
+			assert.Len(t, cloudProvider.CheckPermissions(context.Background()), tt.wantErrs)
+		})
+	}
+}
+
+func TestNodeGroup_DryRunDeleteNodes(t *testing.T) {
+	service := &test.MockAutoscalingService{
+		DescribeAutoScalingGroupsOutput: &autoscaling.DescribeAutoScalingGroupsOutput{
+			AutoScalingGroups: []*autoscaling.Group{
+				{
+					AutoScalingGroupName: aws.String("asg"),
+					DesiredCapacity:      aws.Int64(2),
+					MinSize:              aws.Int64(1),
+					MaxSize:              aws.Int64(3),
+					Instances: []*autoscaling.Instance{
+						{InstanceId: aws.String("i-123"), AvailabilityZone: aws.String("us-east-1a")},
+						{InstanceId: aws.String("i-456"), AvailabilityZone: aws.String("us-east-1a")},
+					},
+				},
+			},
+		},
+	}
+	node := test.BuildTestNode(test.NodeOpts{Name: "n1"})
+	node.Spec.ProviderID = "aws:///us-east-1a/i-123"
+	other := test.BuildTestNode(test.NodeOpts{Name: "n2"})
+	other.Spec.ProviderID = "aws:///us-east-1a/i-789"
+
+	tests := []struct {
+		name         string
+		nodes        []*v1.Node
+		terminateErr error
+		wantErr      bool
+	}{
+		{
+			"dry run succeeds",
+			[]*v1.Node{node},
+			awserr.New(dryRunOperationCode, "Request would have succeeded", nil),
+			false,
+		},
+		{
+			"missing permission",
+			[]*v1.Node{node},
+			awserr.New("UnauthorizedOperation", "You are not authorized to perform this operation", nil),
+			true,
+		},
+		{
+			"node not in node group",
+			[]*v1.Node{other},
+			awserr.New(dryRunOperationCode, "Request would have succeeded", nil),
+			true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ec2Service := &test.MockEc2Service{TerminateInstancesErr: tt.terminateErr}
+			cloudProvider, err := newMockCloudProvider([]string{"asg"}, service, ec2Service)
+			assert.NoError(t, err)
+			nodeGroup, ok := cloudProvider.GetNodeGroup("asg")
+			assert.True(t, ok)
+
+			err = nodeGroup.(*NodeGroup).DryRunDeleteNodes(context.Background(), tt.nodes...)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package aws
+
+import (
+	"context"
+
+	"github.com/atlassian/escalator/pkg/cloudprovider"
+	awsapi "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+// vcpuQuotaName names the quota that scale ups are checked against
+const vcpuQuotaName = "ec2 on-demand vcpus"
+
+// RemainingQuota returns how many more nodes of the node group fit in the on-demand vCPU quota of the account, set
+// with --aws-vcpu-quota. The vCPUs of all running and pending on-demand instances of the region count towards the
+// quota, and the size of a node is the size of an instance already in the auto scaling group
+func (n *NodeGroup) RemainingQuota(ctx context.Context) (cloudprovider.Quota, bool, error) {
+	if n.provider.vcpuQuota <= 0 || len(n.asg.Instances) == 0 {
+		return cloudprovider.Quota{}, false, nil
+	}
+
+	nodeVCPUs, err := n.provider.instanceVCPUs(ctx, n.asg.Instances[0].InstanceId)
+	if err != nil || nodeVCPUs == 0 {
+		return cloudprovider.Quota{}, false, err
+	}
+
+	usedVCPUs, err := n.provider.onDemandVCPUs(ctx)
+	if err != nil {
+		return cloudprovider.Quota{}, false, err
+	}
+
+	remainingVCPUs := n.provider.vcpuQuota - usedVCPUs
+	if remainingVCPUs < 0 {
+		remainingVCPUs = 0
+	}
+	return cloudprovider.Quota{
+		Name:           vcpuQuotaName,
+		RemainingNodes: remainingVCPUs / nodeVCPUs,
+		Remaining:      remainingVCPUs,
+		PerNode:        nodeVCPUs,
+	}, true, nil
+}
+
+// instanceVCPUs returns the number of vCPUs of the instance, or 0 if the instance isn't found
+func (c *CloudProvider) instanceVCPUs(ctx context.Context, instanceID *string) (int64, error) {
+	result, err := c.ec2_service.DescribeInstancesWithContext(ctx, &ec2.DescribeInstancesInput{
+		InstanceIds: []*string{instanceID},
+	})
+	if err != nil {
+		return 0, err
+	}
+	for _, reservation := range result.Reservations {
+		for _, instance := range reservation.Instances {
+			return vcpus(instance), nil
+		}
+	}
+	return 0, nil
+}
+
+// onDemandVCPUs returns the number of vCPUs of the running and pending on-demand instances of the region
+func (c *CloudProvider) onDemandVCPUs(ctx context.Context) (int64, error) {
+	var used int64
+	err := c.ec2_service.DescribeInstancesPagesWithContext(ctx, &ec2.DescribeInstancesInput{
+		Filters: []*ec2.Filter{{
+			Name:   awsapi.String("instance-state-name"),
+			Values: awsapi.StringSlice([]string{ec2.InstanceStateNamePending, ec2.InstanceStateNameRunning}),
+		}},
+	}, func(page *ec2.DescribeInstancesOutput, lastPage bool) bool {
+		for _, reservation := range page.Reservations {
+			for _, instance := range reservation.Instances {
+				// spot and scheduled instances have their own quotas
+				if instance.InstanceLifecycle == nil {
+					used += vcpus(instance)
+				}
+			}
+		}
+		return true
+	})
+	return used, err
+}
+
+// vcpus returns the number of vCPUs of the instance
+func vcpus(instance *ec2.Instance) int64 {
+	if instance.CpuOptions == nil {
+		return 0
+	}
+	return awsapi.Int64Value(instance.CpuOptions.CoreCount) * awsapi.Int64Value(instance.CpuOptions.ThreadsPerCore)
+}
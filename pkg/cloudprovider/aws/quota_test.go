@@ -0,0 +1,55 @@
+package aws
+
+import (
+	"context"
+	"testing"
+
+	"github.com/atlassian/escalator/pkg/cloudprovider"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNodeGroup_RemainingQuota(t *testing.T) {
+	cloud, replayer := replayCloudProvider(t, "remaining_quota")
+	cloud.vcpuQuota = 64
+	require.NoError(t, cloud.RegisterNodeGroups(context.Background(), cloudprovider.NodeGroupConfig{GroupID: cassetteGroupID}))
+	nodeGroup, ok := cloud.GetNodeGroup(cassetteGroupID)
+	require.True(t, ok)
+
+	// 12 vCPUs of the node group and 16 vCPUs of another on-demand instance are used, the spot instance doesn't count
+	quota, ok, err := nodeGroup.(cloudprovider.QuotaChecker).RemainingQuota(context.Background())
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, cloudprovider.Quota{Name: vcpuQuotaName, RemainingNodes: 9, Remaining: 36, PerNode: 4}, quota)
+	assert.Equal(t, 0, replayer.Unused())
+}
+
+func TestNodeGroup_RemainingQuota_Unknown(t *testing.T) {
+	instances := []*autoscaling.Instance{{InstanceId: aws.String("i-0a1b2c3d4e5f00001")}}
+	tests := []struct {
+		name      string
+		vcpuQuota int64
+		instances []*autoscaling.Instance
+	}{
+		{"quota disabled", 0, instances},
+		{"no instances", 64, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cloud := &CloudProvider{vcpuQuota: tt.vcpuQuota}
+			nodeGroup := NewNodeGroup(&cloudprovider.NodeGroupConfig{GroupID: "1"}, &autoscaling.Group{Instances: tt.instances}, cloud)
+
+			_, ok, err := nodeGroup.RemainingQuota(context.Background())
+			assert.NoError(t, err)
+			assert.False(t, ok)
+		})
+	}
+}
+
+func TestVCPUs(t *testing.T) {
+	assert.Equal(t, int64(0), vcpus(&ec2.Instance{}))
+	assert.Equal(t, int64(16), vcpus(&ec2.Instance{CpuOptions: &ec2.CpuOptions{CoreCount: aws.Int64(8), ThreadsPerCore: aws.Int64(2)}}))
+}
@@ -0,0 +1,37 @@
+//go:build recorder
+// +build recorder
+
+package aws
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/atlassian/escalator/pkg/cloudprovider"
+	"github.com/atlassian/escalator/pkg/cloudprovider/aws/cassette"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRecordRegisterNodeGroups records registering and refreshing a node group against AWS into a cassette.
+// It needs credentials and an existing auto scaling group, e.g.
+//
+//	ESCALATOR_CASSETTE_ASG=my-asg go test -tags recorder ./pkg/cloudprovider/aws/ -run TestRecord
+func TestRecordRegisterNodeGroups(t *testing.T) {
+	groupID := os.Getenv("ESCALATOR_CASSETTE_ASG")
+	if len(groupID) == 0 {
+		t.Skip("ESCALATOR_CASSETTE_ASG is not set")
+	}
+
+	recorder := cassette.NewRecorder(nil)
+	builder := Builder{
+		ProviderOpts: cloudprovider.BuildOpts{NodeGroupConfigs: []cloudprovider.NodeGroupConfig{{GroupID: groupID}}},
+		transport:    recorder,
+	}
+	cloud, err := builder.Build()
+	require.NoError(t, err)
+	require.NoError(t, cloud.Refresh(context.Background()))
+
+	require.NoError(t, recorder.Save(filepath.Join("testdata", "cassettes", "recorded_register_node_groups.json")))
+}
@@ -0,0 +1,139 @@
+package aws
+
+import (
+	"sync"
+	"time"
+
+	"github.com/atlassian/escalator/pkg/metrics"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/request"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// RetryModeStandard retries failed calls with the default sdk exponential backoff
+	RetryModeStandard = "standard"
+	// RetryModeAdaptive retries failed calls with the default sdk exponential backoff and additionally
+	// rate limits all calls on the client side when the AWS API starts throttling
+	RetryModeAdaptive = "adaptive"
+
+	// adaptiveMinDelay is the smallest spacing enforced between calls once throttling has been observed
+	adaptiveMinDelay = 50 * time.Millisecond
+	// adaptiveMaxDelay is the largest spacing enforced between calls
+	adaptiveMaxDelay = 10 * time.Second
+)
+
+// RetryModes are the valid values for the retry mode option
+var RetryModes = []string{RetryModeStandard, RetryModeAdaptive}
+
+// throttleAwareRetryer wraps the sdk default retryer to record retry and throttle metrics
+// and to feed throttling events into the adaptive rate limiter when it is enabled
+type throttleAwareRetryer struct {
+	client.DefaultRetryer
+	limiter *adaptiveRateLimiter
+}
+
+// newThrottleAwareRetryer creates a retryer with the max retries and retry mode
+func newThrottleAwareRetryer(maxRetries int, mode string) *throttleAwareRetryer {
+	r := &throttleAwareRetryer{
+		DefaultRetryer: client.DefaultRetryer{NumMaxRetries: maxRetries},
+	}
+	if mode == RetryModeAdaptive {
+		r.limiter = &adaptiveRateLimiter{}
+	}
+	return r
+}
+
+// RetryRules returns the delay before the next retry and records the retry
+func (r *throttleAwareRetryer) RetryRules(req *request.Request) time.Duration {
+	service, operation := requestLabels(req)
+	metrics.CloudProviderAPIRetries.WithLabelValues(ProviderName, service, operation).Add(1.0)
+
+	if req.IsErrorThrottle() {
+		log.WithField("operation", operation).Debugf("aws api call was throttled. retry count = %v", req.RetryCount)
+		metrics.CloudProviderAPIThrottles.WithLabelValues(ProviderName, service, operation).Add(1.0)
+		if r.limiter != nil {
+			r.limiter.throttled()
+		}
+	}
+
+	return r.DefaultRetryer.RetryRules(req)
+}
+
+// addHandlers registers the adaptive rate limiter with the request handlers
+// it is a noop when the retryer isn't in adaptive mode
+func (r *throttleAwareRetryer) addHandlers(handlers *request.Handlers) {
+	if r.limiter == nil {
+		return
+	}
+	handlers.Send.PushFront(func(req *request.Request) {
+		r.limiter.wait()
+	})
+	handlers.Complete.PushBack(func(req *request.Request) {
+		if req.Error == nil {
+			r.limiter.succeeded()
+		}
+	})
+}
+
+// requestLabels returns the service and operation names of a request for metric labels
+func requestLabels(req *request.Request) (string, string) {
+	var operation string
+	if req.Operation != nil {
+		operation = req.Operation.Name
+	}
+	return req.ClientInfo.ServiceName, operation
+}
+
+// adaptiveRateLimiter spaces out calls to the AWS API when it is throttling us.
+// The spacing doubles every time a call is throttled and halves every time a call succeeds,
+// dropping back to no spacing at all once the API has recovered
+type adaptiveRateLimiter struct {
+	mu       sync.Mutex
+	delay    time.Duration
+	lastSend time.Time
+}
+
+// wait blocks until the next call is allowed to be sent
+func (l *adaptiveRateLimiter) wait() {
+	l.mu.Lock()
+	next := l.lastSend.Add(l.delay)
+	now := time.Now()
+	if next.Before(now) {
+		next = now
+	}
+	l.lastSend = next
+	l.mu.Unlock()
+
+	time.Sleep(next.Sub(now))
+}
+
+// throttled increases the spacing between calls
+func (l *adaptiveRateLimiter) throttled() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.delay *= 2
+	if l.delay < adaptiveMinDelay {
+		l.delay = adaptiveMinDelay
+	}
+	if l.delay > adaptiveMaxDelay {
+		l.delay = adaptiveMaxDelay
+	}
+}
+
+// succeeded decreases the spacing between calls
+func (l *adaptiveRateLimiter) succeeded() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.delay /= 2
+	if l.delay < adaptiveMinDelay {
+		l.delay = 0
+	}
+}
+
+// currentDelay returns the current spacing between calls
+func (l *adaptiveRateLimiter) currentDelay() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.delay
+}
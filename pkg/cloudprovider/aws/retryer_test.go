@@ -0,0 +1,63 @@
+package aws
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewThrottleAwareRetryer(t *testing.T) {
+	standard := newThrottleAwareRetryer(5, RetryModeStandard)
+	assert.Equal(t, 5, standard.MaxRetries())
+	assert.Nil(t, standard.limiter)
+
+	adaptive := newThrottleAwareRetryer(2, RetryModeAdaptive)
+	assert.Equal(t, 2, adaptive.MaxRetries())
+	assert.NotNil(t, adaptive.limiter)
+}
+
+func TestThrottleAwareRetryer_RetryRules(t *testing.T) {
+	retryer := newThrottleAwareRetryer(3, RetryModeAdaptive)
+
+	throttled := &request.Request{
+		Operation:    &request.Operation{Name: "SetDesiredCapacity"},
+		HTTPResponse: &http.Response{StatusCode: 400},
+		Error:        awserr.New("Throttling", "Rate exceeded", nil),
+	}
+	assert.True(t, retryer.RetryRules(throttled) > 0)
+	assert.Equal(t, adaptiveMinDelay, retryer.limiter.currentDelay())
+
+	failed := &request.Request{
+		Operation:    &request.Operation{Name: "SetDesiredCapacity"},
+		HTTPResponse: &http.Response{StatusCode: 500},
+		Error:        awserr.New("InternalFailure", "", nil),
+	}
+	assert.True(t, retryer.RetryRules(failed) > 0)
+	// non throttling errors don't change the adaptive delay
+	assert.Equal(t, adaptiveMinDelay, retryer.limiter.currentDelay())
+}
+
+func TestAdaptiveRateLimiter(t *testing.T) {
+	limiter := &adaptiveRateLimiter{}
+	assert.Equal(t, 0*adaptiveMinDelay, limiter.currentDelay())
+
+	limiter.throttled()
+	assert.Equal(t, adaptiveMinDelay, limiter.currentDelay())
+	limiter.throttled()
+	assert.Equal(t, 2*adaptiveMinDelay, limiter.currentDelay())
+
+	// never grows past the maximum
+	for i := 0; i < 20; i++ {
+		limiter.throttled()
+	}
+	assert.Equal(t, adaptiveMaxDelay, limiter.currentDelay())
+
+	// recovers back to no delay
+	for i := 0; i < 20; i++ {
+		limiter.succeeded()
+	}
+	assert.Equal(t, 0*adaptiveMinDelay, limiter.currentDelay())
+}
@@ -1,9 +1,20 @@
 package aws
 
+import "time"
+
 // AssumeRoleNamePrefix is the assume role session name prefix
 const AssumeRoleNamePrefix = "atlassian-escalator"
 
 // Opts includes options for AWS cloud provider
 type Opts struct {
 	AssumeRoleARN string
+
+	// MaxRetries is the maximum number of times a failed AWS API call is retried
+	MaxRetries int
+	// APITimeout is the timeout of a single AWS API call attempt. zero means no timeout
+	APITimeout time.Duration
+	// RetryMode is either RetryModeStandard or RetryModeAdaptive
+	RetryMode string
+	// VCPUQuota is the on-demand vCPU limit of the account that scale ups are checked against. zero disables the check
+	VCPUQuota int64
 }
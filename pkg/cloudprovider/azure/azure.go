@@ -0,0 +1,320 @@
+package azure
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/atlassian/escalator/pkg/cloudprovider"
+	"github.com/atlassian/escalator/pkg/errorkind"
+	"github.com/atlassian/escalator/pkg/metrics"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+)
+
+// ProviderName identifies this module as azure
+const ProviderName = "azure"
+
+// nodeInstanceRef returns the virtual machine backing the node, from its provider id
+func nodeInstanceRef(node *v1.Node) (instanceRef, error) {
+	if len(node.Spec.ProviderID) == 0 {
+		return instanceRef{}, errorkind.New(errorkind.Validation, "node %v has no provider id", node.Name)
+	}
+	return parseProviderID(node.Spec.ProviderID)
+}
+
+// CloudProvider providers an azure cloud provider implementation, for node groups backed by virtual machine scale
+// sets, e.g. the node pools of AKS clusters
+type CloudProvider struct {
+	client     *computeClient
+	nodeGroups map[string]*NodeGroup
+}
+
+// Name returns name of the cloud provider.
+func (c *CloudProvider) Name() string {
+	return ProviderName
+}
+
+// NodeGroups returns all node groups configured for this cloud provider.
+func (c *CloudProvider) NodeGroups() []cloudprovider.NodeGroup {
+	ngs := make([]cloudprovider.NodeGroup, 0, len(c.nodeGroups))
+	for _, ng := range c.nodeGroups {
+		ngs = append(ngs, ng)
+	}
+	return ngs
+}
+
+// GetNodeGroup gets the node group from the cloud provider. Returns if it exists or not
+func (c *CloudProvider) GetNodeGroup(id string) (cloudprovider.NodeGroup, bool) {
+	ng, ok := c.nodeGroups[id]
+	return ng, ok
+}
+
+// RegisterNodeGroups adds the nodegroup to the list of nodes groups. Virtual machine scale sets have no size limits
+// of their own, so the limits come from the Azure config of the node group
+func (c *CloudProvider) RegisterNodeGroups(ctx context.Context, groups ...cloudprovider.NodeGroupConfig) error {
+	for _, group := range groups {
+		config := group
+		if config.AzureConfig.MaxSize <= 0 {
+			return errorkind.New(errorkind.Validation, "virtual machine scale set %v needs a max size, it can't be read from Azure", config.GroupID)
+		}
+		if config.AzureConfig.MinSize > config.AzureConfig.MaxSize {
+			return errorkind.New(errorkind.Validation, "virtual machine scale set %v has a min size %v above its max size %v", config.GroupID, config.AzureConfig.MinSize, config.AzureConfig.MaxSize)
+		}
+		ref, err := parseScaleSetRef(config.GroupID)
+		if err != nil {
+			return err
+		}
+
+		set, err := c.client.getScaleSet(ctx, ref)
+		if err != nil {
+			log.Errorf("failed to get virtual machine scale set %v. err: %v", config.GroupID, err)
+			return err
+		}
+		vms, err := c.client.listScaleSetVMs(ctx, ref)
+		if err != nil {
+			log.Errorf("failed to list the virtual machines of virtual machine scale set %v. err: %v", config.GroupID, err)
+			return err
+		}
+
+		if ng, ok := c.nodeGroups[config.GroupID]; ok {
+			// just update the group if it already exists
+			ng.config = &config
+			ng.scaleSet = set
+			ng.vms = vms
+			continue
+		}
+		c.nodeGroups[config.GroupID] = NewNodeGroup(&config, ref, set, vms, c)
+	}
+
+	// Update metrics for each node group
+	for _, nodeGroup := range c.nodeGroups {
+		metrics.CloudProviderMinSize.WithLabelValues(c.Name(), nodeGroup.ID()).Set(float64(nodeGroup.MinSize()))
+		metrics.CloudProviderMaxSize.WithLabelValues(c.Name(), nodeGroup.ID()).Set(float64(nodeGroup.MaxSize()))
+		metrics.CloudProviderTargetSize.WithLabelValues(c.Name(), nodeGroup.ID()).Set(float64(nodeGroup.TargetSize()))
+		metrics.CloudProviderSize.WithLabelValues(c.Name(), nodeGroup.ID()).Set(float64(nodeGroup.Size()))
+	}
+
+	return nil
+}
+
+// Refresh is called before every main loop and can be used to dynamically update cloud provider state.
+func (c *CloudProvider) Refresh(ctx context.Context) error {
+	configs := make([]cloudprovider.NodeGroupConfig, 0, len(c.nodeGroups))
+	for _, ng := range c.nodeGroups {
+		configs = append(configs, *ng.config)
+	}
+
+	return c.RegisterNodeGroups(ctx, configs...)
+}
+
+// Instance includes base virtual machine information
+type Instance struct {
+	id           string
+	creationTime time.Time
+}
+
+// GetInstance creates an Instance object through k8s Node object
+func (c *CloudProvider) GetInstance(ctx context.Context, node *v1.Node) (cloudprovider.Instance, error) {
+	ref, err := nodeInstanceRef(node)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := c.client.getScaleSetVM(ctx, ref)
+	if err != nil {
+		log.Error("Error getting instance - ", err)
+		return nil, err
+	}
+	creationTime, err := time.Parse(time.RFC3339Nano, result.Properties.TimeCreated)
+	if err != nil {
+		return nil, fmt.Errorf("virtual machine %v has an invalid creation time %q: %v", result.Name, result.Properties.TimeCreated, err)
+	}
+
+	return &Instance{id: result.Properties.VMID, creationTime: creationTime}, nil
+}
+
+// InstantiationTime returns the virtual machine creation time
+func (i *Instance) InstantiationTime() time.Time {
+	return i.creationTime
+}
+
+// ID return the virtual machine ID
+func (i *Instance) ID() string {
+	return i.id
+}
+
+// NodeGroup implements an azure nodegroup backed by a virtual machine scale set
+type NodeGroup struct {
+	id       string
+	ref      scaleSetRef
+	scaleSet *scaleSet
+	vms      []scaleSetVM
+
+	provider *CloudProvider
+	config   *cloudprovider.NodeGroupConfig
+}
+
+// NewNodeGroup creates a new nodegroup from the virtual machine scale set backing
+func NewNodeGroup(config *cloudprovider.NodeGroupConfig, ref scaleSetRef, set *scaleSet, vms []scaleSetVM, provider *CloudProvider) *NodeGroup {
+	return &NodeGroup{
+		id:       config.GroupID,
+		ref:      ref,
+		scaleSet: set,
+		vms:      vms,
+		provider: provider,
+		config:   config,
+	}
+}
+
+func (n *NodeGroup) String() string {
+	return fmt.Sprintf("%v: capacity %v, %v virtual machines", n.ref.path(), n.scaleSet.Sku.Capacity, len(n.vms))
+}
+
+// ID returns an unique identifier of the node group.
+func (n *NodeGroup) ID() string {
+	return n.id
+}
+
+// MinSize returns minimum size of the node group.
+func (n *NodeGroup) MinSize() int64 {
+	return n.config.AzureConfig.MinSize
+}
+
+// MaxSize returns maximum size of the node group.
+func (n *NodeGroup) MaxSize() int64 {
+	return n.config.AzureConfig.MaxSize
+}
+
+// TargetSize returns the current target size of the node group. It is possible that the
+// number of nodes in Kubernetes is different at the moment but should be equal
+// to Size() once everything stabilizes (new nodes finish startup and registration or
+// removed nodes are deleted completely).
+func (n *NodeGroup) TargetSize() int64 {
+	return n.scaleSet.Sku.Capacity
+}
+
+// Size is the number of instances in the nodegroup at the current time
+func (n *NodeGroup) Size() int64 {
+	return int64(len(n.vms))
+}
+
+// IncreaseSize increases the size of the node group. To delete a node you need
+// to explicitly name it and use DeleteNode. This function should wait until
+// node group size is updated.
+func (n *NodeGroup) IncreaseSize(ctx context.Context, delta int64) error {
+	if delta <= 0 {
+		return fmt.Errorf("size increase must be positive")
+	}
+
+	if n.TargetSize()+delta > n.MaxSize() {
+		return fmt.Errorf("increasing size will breach maximum node size")
+	}
+
+	log.WithField("vmss", n.id).Debugf("IncreaseSize: %v", delta)
+	return n.setCapacity(ctx, n.TargetSize()+delta)
+}
+
+// DeleteNodes deletes nodes from this node group. Error is returned either on
+// failure or if the given node doesn't belong to this node group. This function
+// should wait until node group size is updated.
+func (n *NodeGroup) DeleteNodes(ctx context.Context, nodes ...*v1.Node) error {
+	if n.TargetSize() <= n.MinSize() {
+		return fmt.Errorf("min sized reached, nodes will not be deleted")
+	}
+
+	if n.TargetSize()-int64(len(nodes)) < n.MinSize() {
+		return fmt.Errorf("terminating nodes will breach minimum node size")
+	}
+
+	instanceIDs := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		// a node without a provider id can't be mapped to a virtual machine, which doesn't mean it's in another node
+		// group
+		ref, err := nodeInstanceRef(node)
+		if err != nil {
+			return err
+		}
+		if !n.contains(ref) {
+			log.Debugf("virtual machines in VMSS: %v", n.Nodes())
+			return &cloudprovider.NodeNotInNodeGroup{NodeName: node.Name, ProviderID: node.Spec.ProviderID, NodeGroup: n.ID()}
+		}
+		instanceIDs = append(instanceIDs, ref.InstanceID)
+	}
+
+	// deleting the virtual machines through the scale set reduces its capacity, so they aren't replaced
+	if err := n.provider.client.deleteInstances(ctx, n.ref, instanceIDs); err != nil {
+		return fmt.Errorf("failed to delete instances. err: %v", err)
+	}
+	n.scaleSet.Sku.Capacity -= int64(len(instanceIDs))
+	return nil
+}
+
+// Belongs determines if the node belongs in the current node group. Nodes are matched to the virtual machines of the
+// scale set by the resource id in their provider id
+func (n *NodeGroup) Belongs(node *v1.Node) bool {
+	ref, err := nodeInstanceRef(node)
+	if err != nil {
+		return false
+	}
+	return n.contains(ref)
+}
+
+// DecreaseTargetSize decreases the target size of the node group. This function
+// doesn't permit to delete any existing node and can be used only to reduce the
+// request for new nodes that have not been yet fulfilled. Delta should be negative.
+// It is assumed that cloud provider will not delete the existing nodes when there
+// is an option to just decrease the target.
+func (n *NodeGroup) DecreaseTargetSize(ctx context.Context, delta int64) error {
+	if delta >= 0 {
+		return fmt.Errorf("size decrease delta must be negative")
+	}
+
+	if n.TargetSize()+delta < n.MinSize() {
+		return fmt.Errorf("decreasing target size will breach minimum node size")
+	}
+
+	log.WithField("vmss", n.id).Debugf("DecreaseTargetSize: %v", delta)
+	return n.setCapacity(ctx, n.TargetSize()+delta)
+}
+
+// Nodes returns a list of all nodes that belong to this node group.
+func (n *NodeGroup) Nodes() []string {
+	result := make([]string, 0, len(n.vms))
+	for _, vm := range n.vms {
+		ref, err := parseResourceID(vm.ID)
+		if err != nil {
+			log.WithField("vmss", n.id).Warn(err)
+			continue
+		}
+		result = append(result, ref.providerID())
+	}
+
+	return result
+}
+
+// contains returns whether the virtual machine is in the scale set
+func (n *NodeGroup) contains(ref instanceRef) bool {
+	if !n.ref.equal(ref.ScaleSet) {
+		return false
+	}
+	for _, vm := range n.vms {
+		if vm.InstanceID == ref.InstanceID {
+			return true
+		}
+	}
+	return false
+}
+
+// setCapacity sets the capacity of the virtual machine scale set to the new size
+// user must make sure that newSize is not out of bounds of the node group
+func (n *NodeGroup) setCapacity(ctx context.Context, newSize int64) error {
+	log.WithField("vmss", n.id).Debugf("Resize: %v", newSize)
+	log.WithField("vmss", n.id).Debugf("CurrentSize: %v", n.Size())
+	log.WithField("vmss", n.id).Debugf("CurrentTargetSize: %v", n.TargetSize())
+	if err := n.provider.client.setCapacity(ctx, n.ref, newSize); err != nil {
+		return err
+	}
+	n.scaleSet.Sku.Capacity = newSize
+	return nil
+}
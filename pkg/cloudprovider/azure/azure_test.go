@@ -0,0 +1,308 @@
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/atlassian/escalator/pkg/cloudprovider"
+	"github.com/atlassian/escalator/pkg/errorkind"
+	"github.com/atlassian/escalator/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	testSubscription  = "00000000-0000-0000-0000-000000000000"
+	testResourceGroup = "MC_cluster_cluster_eastus"
+	testScaleSet      = "aks-nodepool1-12345678-vmss"
+)
+
+var testScaleSetRef = scaleSetRef{Subscription: testSubscription, ResourceGroup: testResourceGroup, Name: testScaleSet}
+
+// fakeResourceManager is a resource manager api and instance metadata service with a single virtual machine scale
+// set
+type fakeResourceManager struct {
+	sync.Mutex
+	capacity    int64
+	instanceIDs []string
+	// status is returned instead of the response when set
+	status int
+
+	capacities []int64
+	deletions  [][]string
+}
+
+func newFakeResourceManager(capacity int64, instanceIDs ...string) *fakeResourceManager {
+	return &fakeResourceManager{capacity: capacity, instanceIDs: instanceIDs}
+}
+
+func (f *fakeResourceManager) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+
+	if strings.HasSuffix(r.URL.Path, "/identity/oauth2/token") {
+		json.NewEncoder(w).Encode(map[string]interface{}{"access_token": "token", "expires_in": "3600"})
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if r.URL.Query().Get("api-version") != computeAPIVersion {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"error": {"code": "InvalidApiVersionParameter", "message": "invalid api version"}}`)
+		return
+	}
+	if f.status != 0 {
+		w.WriteHeader(f.status)
+		fmt.Fprint(w, `{"error": {"code": "TooManyRequests", "message": "rate limited"}}`)
+		return
+	}
+
+	// resource ids are case insensitive
+	path := strings.ToLower(r.URL.Path)
+	scaleSetPath := strings.ToLower("/" + testScaleSetRef.path())
+	switch {
+	case r.Method == http.MethodGet && path == scaleSetPath:
+		var set scaleSet
+		set.Name = testScaleSet
+		set.Sku.Capacity = f.capacity
+		json.NewEncoder(w).Encode(set)
+	case r.Method == http.MethodGet && path == scaleSetPath+"/virtualmachines":
+		var page listScaleSetVMsResponse
+		for _, id := range f.instanceIDs {
+			page.Value = append(page.Value, f.vm(id))
+		}
+		json.NewEncoder(w).Encode(page)
+	case r.Method == http.MethodPatch && path == scaleSetPath:
+		var body struct {
+			Sku struct {
+				Capacity int64 `json:"capacity"`
+			} `json:"sku"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		f.capacities = append(f.capacities, body.Sku.Capacity)
+		f.capacity = body.Sku.Capacity
+		w.WriteHeader(http.StatusAccepted)
+	case r.Method == http.MethodPost && path == scaleSetPath+"/delete":
+		var body struct {
+			InstanceIDs []string `json:"instanceIds"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		f.deletions = append(f.deletions, body.InstanceIDs)
+		f.capacity -= int64(len(body.InstanceIDs))
+		w.WriteHeader(http.StatusAccepted)
+	case r.Method == http.MethodGet && strings.HasPrefix(path, scaleSetPath+"/virtualmachines/"):
+		json.NewEncoder(w).Encode(f.vm(r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"error": {"code": "ResourceNotFound", "message": "not found"}}`)
+	}
+}
+
+func (f *fakeResourceManager) vm(instanceID string) scaleSetVM {
+	vm := scaleSetVM{
+		ID:         "/" + instanceRef{ScaleSet: testScaleSetRef, InstanceID: instanceID}.path(),
+		InstanceID: instanceID,
+		Name:       testScaleSet + "_" + instanceID,
+	}
+	vm.Properties.VMID = "vm-" + instanceID
+	vm.Properties.TimeCreated = "2019-02-01T10:00:00.1234567+00:00"
+	return vm
+}
+
+func buildTestCloudProvider(t *testing.T, fake *fakeResourceManager, minSize int64, maxSize int64) (*CloudProvider, *httptest.Server) {
+	server := httptest.NewServer(fake)
+	builder := Builder{
+		ProviderOpts: cloudprovider.BuildOpts{
+			ProviderID: ProviderName,
+			NodeGroupConfigs: []cloudprovider.NodeGroupConfig{{
+				GroupID:     fmt.Sprintf("%v/%v/%v", testSubscription, testResourceGroup, testScaleSet),
+				AzureConfig: cloudprovider.AzureNodeGroupConfig{MinSize: minSize, MaxSize: maxSize},
+			}},
+		},
+		Opts: Opts{
+			ResourceManagerEndpoint: server.URL + "/",
+			MetadataEndpoint:        server.URL + "/metadata/",
+		},
+	}
+	cloud, err := builder.Build()
+	require.NoError(t, err)
+	return cloud.(*CloudProvider), server
+}
+
+// buildTestAzureNode builds a node of the scale set with the provider id written by AKS, with the resource group in
+// lower case
+func buildTestAzureNode(instanceID string) *v1.Node {
+	node := test.BuildTestNode(test.NodeOpts{Name: testScaleSet + instanceID})
+	ref := instanceRef{
+		ScaleSet:   scaleSetRef{Subscription: testSubscription, ResourceGroup: strings.ToLower(testResourceGroup), Name: testScaleSet},
+		InstanceID: instanceID,
+	}
+	node.Spec.ProviderID = ref.providerID()
+	return node
+}
+
+func testNodeGroup(t *testing.T, cloud *CloudProvider) *NodeGroup {
+	nodeGroup, ok := cloud.GetNodeGroup(fmt.Sprintf("%v/%v/%v", testSubscription, testResourceGroup, testScaleSet))
+	require.True(t, ok)
+	return nodeGroup.(*NodeGroup)
+}
+
+func TestCloudProvider_RegisterNodeGroups(t *testing.T) {
+	fake := newFakeResourceManager(2, "0", "1")
+	cloud, server := buildTestCloudProvider(t, fake, 1, 5)
+	defer server.Close()
+
+	assert.Equal(t, ProviderName, cloud.Name())
+	require.Len(t, cloud.NodeGroups(), 1)
+	nodeGroup := testNodeGroup(t, cloud)
+	assert.Equal(t, int64(1), nodeGroup.MinSize())
+	assert.Equal(t, int64(5), nodeGroup.MaxSize())
+	assert.Equal(t, int64(2), nodeGroup.TargetSize())
+	assert.Equal(t, int64(2), nodeGroup.Size())
+	assert.Equal(t, []string{
+		"azure:///" + instanceRef{ScaleSet: testScaleSetRef, InstanceID: "0"}.path(),
+		"azure:///" + instanceRef{ScaleSet: testScaleSetRef, InstanceID: "1"}.path(),
+	}, nodeGroup.Nodes())
+
+	// refresh picks up the changes made outside of escalator
+	fake.Lock()
+	fake.capacity = 3
+	fake.instanceIDs = append(fake.instanceIDs, "2")
+	fake.Unlock()
+	require.NoError(t, cloud.Refresh(context.Background()))
+	assert.Equal(t, int64(3), nodeGroup.TargetSize())
+	assert.Equal(t, int64(3), nodeGroup.Size())
+}
+
+func TestCloudProvider_RegisterNodeGroupsInvalid(t *testing.T) {
+	fake := newFakeResourceManager(2, "0", "1")
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	cloud := &CloudProvider{
+		client:     &computeClient{http: server.Client(), endpoint: server.URL + "/", token: metadataTokenSource(server.Client(), server.URL+"/metadata/", DefaultResourceManagerEndpoint, "")},
+		nodeGroups: make(map[string]*NodeGroup),
+	}
+
+	groupID := fmt.Sprintf("%v/%v/%v", testSubscription, testResourceGroup, testScaleSet)
+	tests := []struct {
+		name   string
+		config cloudprovider.NodeGroupConfig
+		kind   errorkind.Kind
+	}{
+		{"no max size", cloudprovider.NodeGroupConfig{GroupID: groupID}, errorkind.Validation},
+		{"min above max", cloudprovider.NodeGroupConfig{GroupID: groupID, AzureConfig: cloudprovider.AzureNodeGroupConfig{MinSize: 5, MaxSize: 2}}, errorkind.Validation},
+		{"invalid name", cloudprovider.NodeGroupConfig{GroupID: testScaleSet, AzureConfig: cloudprovider.AzureNodeGroupConfig{MaxSize: 2}}, errorkind.Validation},
+		{"missing scale set", cloudprovider.NodeGroupConfig{GroupID: testSubscription + "/" + testResourceGroup + "/missing", AzureConfig: cloudprovider.AzureNodeGroupConfig{MaxSize: 2}}, errorkind.NotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := cloud.RegisterNodeGroups(context.Background(), tt.config)
+			require.Error(t, err)
+			assert.Equal(t, tt.kind, errorkind.Of(err))
+		})
+	}
+	assert.Empty(t, cloud.NodeGroups())
+}
+
+func TestNodeGroup_Belongs(t *testing.T) {
+	cloud, server := buildTestCloudProvider(t, newFakeResourceManager(2, "0", "1"), 0, 5)
+	defer server.Close()
+	nodeGroup := testNodeGroup(t, cloud)
+
+	assert.True(t, nodeGroup.Belongs(buildTestAzureNode("0")))
+	assert.False(t, nodeGroup.Belongs(buildTestAzureNode("2")))
+
+	// the instance id isn't enough, the virtual machine is matched by its scale set too
+	node := buildTestAzureNode("0")
+	node.Spec.ProviderID = "azure:///" + instanceRef{ScaleSet: scaleSetRef{testSubscription, testResourceGroup, "other-vmss"}, InstanceID: "0"}.path()
+	assert.False(t, nodeGroup.Belongs(node))
+	node.Spec.ProviderID = ""
+	assert.False(t, nodeGroup.Belongs(node))
+}
+
+func TestNodeGroup_IncreaseSize(t *testing.T) {
+	fake := newFakeResourceManager(2, "0", "1")
+	cloud, server := buildTestCloudProvider(t, fake, 1, 5)
+	defer server.Close()
+	nodeGroup := testNodeGroup(t, cloud)
+
+	assert.Error(t, nodeGroup.IncreaseSize(context.Background(), 0))
+	assert.Error(t, nodeGroup.IncreaseSize(context.Background(), 4))
+	require.NoError(t, nodeGroup.IncreaseSize(context.Background(), 3))
+	assert.Equal(t, []int64{5}, fake.capacities)
+	assert.Equal(t, int64(5), nodeGroup.TargetSize())
+}
+
+func TestNodeGroup_DecreaseTargetSize(t *testing.T) {
+	fake := newFakeResourceManager(4, "0", "1")
+	cloud, server := buildTestCloudProvider(t, fake, 1, 5)
+	defer server.Close()
+	nodeGroup := testNodeGroup(t, cloud)
+
+	assert.Error(t, nodeGroup.DecreaseTargetSize(context.Background(), 1))
+	assert.Error(t, nodeGroup.DecreaseTargetSize(context.Background(), -4))
+	require.NoError(t, nodeGroup.DecreaseTargetSize(context.Background(), -2))
+	assert.Equal(t, []int64{2}, fake.capacities)
+	assert.Equal(t, int64(2), nodeGroup.TargetSize())
+}
+
+func TestNodeGroup_DeleteNodes(t *testing.T) {
+	fake := newFakeResourceManager(3, "0", "1", "2")
+	cloud, server := buildTestCloudProvider(t, fake, 1, 5)
+	defer server.Close()
+	nodeGroup := testNodeGroup(t, cloud)
+
+	// nodes of other node groups aren't deleted
+	err := nodeGroup.DeleteNodes(context.Background(), buildTestAzureNode("0"), buildTestAzureNode("3"))
+	require.Error(t, err)
+	assert.IsType(t, &cloudprovider.NodeNotInNodeGroup{}, err)
+	assert.Empty(t, fake.deletions)
+
+	// the min size is respected
+	assert.Error(t, nodeGroup.DeleteNodes(context.Background(), buildTestAzureNode("0"), buildTestAzureNode("1"), buildTestAzureNode("2")))
+
+	require.NoError(t, nodeGroup.DeleteNodes(context.Background(), buildTestAzureNode("0"), buildTestAzureNode("2")))
+	assert.Equal(t, [][]string{{"0", "2"}}, fake.deletions)
+	assert.Equal(t, int64(1), nodeGroup.TargetSize())
+	assert.Empty(t, fake.capacities)
+}
+
+func TestCloudProvider_GetInstance(t *testing.T) {
+	cloud, server := buildTestCloudProvider(t, newFakeResourceManager(1, "0"), 0, 5)
+	defer server.Close()
+
+	instance, err := cloud.GetInstance(context.Background(), buildTestAzureNode("0"))
+	require.NoError(t, err)
+	assert.Equal(t, "vm-0", instance.ID())
+	assert.True(t, time.Date(2019, 2, 1, 10, 0, 0, 123456700, time.UTC).Equal(instance.InstantiationTime()))
+
+	node := buildTestAzureNode("0")
+	node.Spec.ProviderID = "aws:///us-east-1a/i-123"
+	_, err = cloud.GetInstance(context.Background(), node)
+	assert.Error(t, err)
+}
+
+func TestCloudProvider_Throttled(t *testing.T) {
+	fake := newFakeResourceManager(2, "0", "1")
+	cloud, server := buildTestCloudProvider(t, fake, 1, 5)
+	defer server.Close()
+	nodeGroup := testNodeGroup(t, cloud)
+
+	fake.Lock()
+	fake.status = http.StatusTooManyRequests
+	fake.Unlock()
+	err := nodeGroup.IncreaseSize(context.Background(), 1)
+	require.Error(t, err)
+	assert.Equal(t, errorkind.Throttled, errorkind.Of(err))
+	assert.Equal(t, int64(2), nodeGroup.TargetSize())
+}
@@ -0,0 +1,51 @@
+package azure
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/atlassian/escalator/pkg/cloudprovider"
+	log "github.com/sirupsen/logrus"
+)
+
+// Builder builds the azure cloud provider
+type Builder struct {
+	ProviderOpts cloudprovider.BuildOpts
+	Opts         Opts
+
+	// transport sends the api requests instead of the default http transport, e.g. to record them
+	transport http.RoundTripper
+}
+
+// Build the cloud provider
+func (b Builder) Build() (cloudprovider.CloudProvider, error) {
+	httpClient := &http.Client{Timeout: b.Opts.APITimeout, Transport: b.transport}
+
+	resourceManagerEndpoint := b.Opts.ResourceManagerEndpoint
+	if len(resourceManagerEndpoint) == 0 {
+		resourceManagerEndpoint = DefaultResourceManagerEndpoint
+	}
+	metadataEndpoint := b.Opts.MetadataEndpoint
+	if len(metadataEndpoint) == 0 {
+		metadataEndpoint = DefaultMetadataEndpoint
+	}
+
+	// the access tokens of the managed identity of the node are read from the instance metadata service, for the
+	// resource manager the requests are sent to
+	cloud := &CloudProvider{
+		client: &computeClient{
+			http:     httpClient,
+			endpoint: resourceManagerEndpoint,
+			token:    metadataTokenSource(httpClient, metadataEndpoint, resourceManagerEndpoint, b.Opts.IdentityClientID),
+		},
+		nodeGroups: make(map[string]*NodeGroup, len(b.ProviderOpts.NodeGroupConfigs)),
+	}
+
+	// Register the node groups
+	if err := cloud.RegisterNodeGroups(context.Background(), b.ProviderOpts.NodeGroupConfigs...); err != nil {
+		return nil, err
+	}
+
+	log.Infof("azure cloud provider created successfully, using resource manager endpoint %v", resourceManagerEndpoint)
+	return cloud, nil
+}
@@ -0,0 +1,289 @@
+package azure
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/atlassian/escalator/pkg/errorkind"
+	"github.com/atlassian/escalator/pkg/metrics"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultResourceManagerEndpoint is the base url of the Azure Resource Manager api of the public cloud
+	DefaultResourceManagerEndpoint = "https://management.azure.com/"
+	// DefaultMetadataEndpoint is the base url of the instance metadata service that issues the access tokens of the
+	// managed identity of the virtual machine
+	DefaultMetadataEndpoint = "http://169.254.169.254/metadata/"
+
+	// computeAPIVersion is the version of the Microsoft.Compute api. 2021-11-01 is the first version with the creation
+	// time of the virtual machines
+	computeAPIVersion = "2021-11-01"
+	// metadataAPIVersion is the version of the instance metadata service identity api
+	metadataAPIVersion = "2018-02-01"
+	// computeService is the service label of the api metrics
+	computeService = "compute"
+	// tokenExpiryMargin is how long before its expiry an access token is refreshed
+	tokenExpiryMargin = time.Minute
+)
+
+// scaleSet is the part of a virtual machine scale set escalator reads
+type scaleSet struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Location string `json:"location"`
+	Sku      struct {
+		Name     string `json:"name"`
+		Capacity int64  `json:"capacity"`
+	} `json:"sku"`
+	Properties struct {
+		ProvisioningState string `json:"provisioningState"`
+	} `json:"properties"`
+}
+
+// scaleSetVM is a virtual machine of a virtual machine scale set
+type scaleSetVM struct {
+	// ID is the resource id of the virtual machine
+	ID         string `json:"id"`
+	InstanceID string `json:"instanceId"`
+	Name       string `json:"name"`
+	Properties struct {
+		VMID              string `json:"vmId"`
+		ProvisioningState string `json:"provisioningState"`
+		TimeCreated       string `json:"timeCreated"`
+	} `json:"properties"`
+}
+
+// listScaleSetVMsResponse is a page of the virtual machines of a virtual machine scale set
+type listScaleSetVMsResponse struct {
+	Value    []scaleSetVM `json:"value"`
+	NextLink string       `json:"nextLink"`
+}
+
+// apiError is the error body of the resource manager api
+type apiError struct {
+	Error struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+		Details []struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"details"`
+	} `json:"error"`
+}
+
+// quotaExceeded returns whether the request failed as it would exceed a quota of the subscription, e.g. the vCPUs of
+// the vm family in the region
+func (e apiError) quotaExceeded() bool {
+	if e.Error.Code == "QuotaExceeded" {
+		return true
+	}
+	for _, detail := range e.Error.Details {
+		if detail.Code == "QuotaExceeded" {
+			return true
+		}
+	}
+	return e.Error.Code == "OperationNotAllowed" && strings.Contains(strings.ToLower(e.Error.Message), "quota")
+}
+
+// tokenSource returns an access token for the resource manager api
+type tokenSource func(ctx context.Context) (string, error)
+
+// metadataTokenSource returns the access tokens of the managed identity of the virtual machine from the instance
+// metadata service, cached until shortly before they expire. clientID selects a user assigned identity, e.g. the
+// kubelet identity of AKS, and is empty for the system assigned identity
+func metadataTokenSource(client *http.Client, endpoint string, resource string, clientID string) tokenSource {
+	var lock sync.Mutex
+	var token string
+	var expiry time.Time
+	return func(ctx context.Context) (string, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		if len(token) > 0 && time.Now().Before(expiry) {
+			return token, nil
+		}
+
+		query := url.Values{"api-version": {metadataAPIVersion}, "resource": {resource}}
+		if len(clientID) > 0 {
+			query.Set("client_id", clientID)
+		}
+		req, err := http.NewRequest(http.MethodGet, endpoint+"identity/oauth2/token?"+query.Encode(), nil)
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("Metadata", "true")
+		resp, err := client.Do(req.WithContext(ctx))
+		if err != nil {
+			return "", errors.Wrap(err, "failed to get an access token from the instance metadata service")
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return "", fmt.Errorf("failed to get an access token from the instance metadata service: %v", resp.Status)
+		}
+		var body struct {
+			AccessToken string `json:"access_token"`
+			// the instance metadata service returns the number of seconds as a string
+			ExpiresIn json.RawMessage `json:"expires_in"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			return "", err
+		}
+		expiresIn, err := strconv.ParseInt(strings.Trim(string(body.ExpiresIn), `"`), 10, 64)
+		if err != nil {
+			return "", fmt.Errorf("access token has an invalid expires_in %v: %v", string(body.ExpiresIn), err)
+		}
+		token = body.AccessToken
+		expiry = time.Now().Add(time.Duration(expiresIn)*time.Second - tokenExpiryMargin)
+		return token, nil
+	}
+}
+
+// computeClient calls the resource manager api for the virtual machine scale sets and their virtual machines
+type computeClient struct {
+	http     *http.Client
+	endpoint string
+	token    tokenSource
+}
+
+// do sends a request for the operation to the path under the endpoint, and decodes the response into out. The
+// api-version is added to the query of the path
+func (c *computeClient) do(ctx context.Context, operationName string, method string, path string, in interface{}, out interface{}) error {
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+	return c.doURL(ctx, operationName, method, c.endpoint+path+separator+"api-version="+computeAPIVersion, in, out)
+}
+
+// doURL sends a request for the operation to the url, e.g. the next link of a list
+func (c *computeClient) doURL(ctx context.Context, operationName string, method string, requestURL string, in interface{}, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		encoded, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(encoded)
+	}
+	req, err := http.NewRequest(method, requestURL, body)
+	if err != nil {
+		return err
+	}
+	token, err := c.token(ctx)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req.WithContext(ctx))
+	if err != nil {
+		if ctx.Err() != nil {
+			return errorkind.Wrap(errorkind.Cancelled, err, operationName+" was cancelled")
+		}
+		return errors.Wrap(err, operationName+" failed")
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		return c.error(operationName, resp.StatusCode, data)
+	}
+	// long running operations are accepted without a body
+	if out == nil || len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
+
+// error returns the error of a failed api call, counting the calls that were throttled
+func (c *computeClient) error(operationName string, status int, data []byte) error {
+	var body apiError
+	message := strings.TrimSpace(string(data))
+	if err := json.Unmarshal(data, &body); err == nil && len(body.Error.Message) > 0 {
+		message = fmt.Sprintf("%v: %v", body.Error.Code, body.Error.Message)
+	}
+
+	switch {
+	case status == http.StatusTooManyRequests:
+		metrics.CloudProviderAPIThrottles.WithLabelValues(ProviderName, computeService, operationName).Add(1.0)
+		return errorkind.New(errorkind.Throttled, "%v was throttled: %v", operationName, message)
+	case body.quotaExceeded():
+		return errorkind.New(errorkind.QuotaBlocked, "%v: %v", operationName, message)
+	case status == http.StatusNotFound:
+		return errorkind.New(errorkind.NotFound, "%v: %v", operationName, message)
+	case status == http.StatusConflict:
+		return errorkind.New(errorkind.Conflict, "%v: %v", operationName, message)
+	case status == http.StatusBadRequest:
+		return errorkind.New(errorkind.Validation, "%v: %v", operationName, message)
+	default:
+		return fmt.Errorf("%v failed with status %v: %v", operationName, status, message)
+	}
+}
+
+// getScaleSet returns the virtual machine scale set
+func (c *computeClient) getScaleSet(ctx context.Context, ref scaleSetRef) (*scaleSet, error) {
+	var set scaleSet
+	if err := c.do(ctx, "GetScaleSet", http.MethodGet, ref.path(), nil, &set); err != nil {
+		return nil, err
+	}
+	return &set, nil
+}
+
+// listScaleSetVMs returns all of the virtual machines of the virtual machine scale set
+func (c *computeClient) listScaleSetVMs(ctx context.Context, ref scaleSetRef) ([]scaleSetVM, error) {
+	var vms []scaleSetVM
+	var page listScaleSetVMsResponse
+	if err := c.do(ctx, "ListScaleSetVMs", http.MethodGet, ref.path()+"/virtualMachines", nil, &page); err != nil {
+		return nil, err
+	}
+	for {
+		vms = append(vms, page.Value...)
+		if len(page.NextLink) == 0 {
+			return vms, nil
+		}
+		next := page.NextLink
+		page = listScaleSetVMsResponse{}
+		if err := c.doURL(ctx, "ListScaleSetVMs", http.MethodGet, next, nil, &page); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// setCapacity sets the capacity of the virtual machine scale set. The update runs asynchronously once accepted
+func (c *computeClient) setCapacity(ctx context.Context, ref scaleSetRef, capacity int64) error {
+	in := map[string]interface{}{"sku": map[string]int64{"capacity": capacity}}
+	return c.do(ctx, "SetCapacity", http.MethodPatch, ref.path(), in, nil)
+}
+
+// deleteInstances deletes the virtual machines from the virtual machine scale set, which reduces its capacity by the
+// number of virtual machines. The deletion runs asynchronously once accepted
+func (c *computeClient) deleteInstances(ctx context.Context, ref scaleSetRef, instanceIDs []string) error {
+	in := struct {
+		InstanceIDs []string `json:"instanceIds"`
+	}{instanceIDs}
+	return c.do(ctx, "DeleteInstances", http.MethodPost, ref.path()+"/delete", in, nil)
+}
+
+// getScaleSetVM returns the virtual machine of the scale set
+func (c *computeClient) getScaleSetVM(ctx context.Context, ref instanceRef) (*scaleSetVM, error) {
+	var vm scaleSetVM
+	if err := c.do(ctx, "GetScaleSetVM", http.MethodGet, ref.path(), nil, &vm); err != nil {
+		return nil, err
+	}
+	return &vm, nil
+}
@@ -0,0 +1,141 @@
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/atlassian/escalator/pkg/errorkind"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetadataTokenSource(t *testing.T) {
+	var requests int
+	expiresIn := "3600"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		assert.Equal(t, "true", r.Header.Get("Metadata"))
+		assert.Equal(t, DefaultResourceManagerEndpoint, r.URL.Query().Get("resource"))
+		assert.Equal(t, "client-id", r.URL.Query().Get("client_id"))
+		json.NewEncoder(w).Encode(map[string]interface{}{"access_token": fmt.Sprint("token-", requests), "expires_in": expiresIn})
+	}))
+	defer server.Close()
+
+	// the token is cached until shortly before it expires
+	token := metadataTokenSource(server.Client(), server.URL+"/metadata/", DefaultResourceManagerEndpoint, "client-id")
+	for i := 0; i < 3; i++ {
+		value, err := token(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "token-1", value)
+	}
+	assert.Equal(t, 1, requests)
+
+	// a token that expires within the margin is fetched again
+	expiresIn = "30"
+	token = metadataTokenSource(server.Client(), server.URL+"/metadata/", DefaultResourceManagerEndpoint, "client-id")
+	for i := 0; i < 2; i++ {
+		_, err := token(context.Background())
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 3, requests)
+}
+
+func TestComputeClient_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		kind   errorkind.Kind
+	}{
+		{"too many requests", http.StatusTooManyRequests, `{"error": {"code": "TooManyRequests", "message": "slow down"}}`, errorkind.Throttled},
+		{"not found", http.StatusNotFound, `{"error": {"code": "ResourceNotFound", "message": "not found"}}`, errorkind.NotFound},
+		{"conflict", http.StatusConflict, `{"error": {"code": "Conflict", "message": "busy"}}`, errorkind.Conflict},
+		{"bad request", http.StatusBadRequest, `{"error": {"code": "InvalidParameter", "message": "invalid capacity"}}`, errorkind.Validation},
+		{"forbidden", http.StatusForbidden, `{"error": {"code": "AuthorizationFailed", "message": "denied"}}`, errorkind.Unknown},
+		{"quota exceeded", http.StatusConflict, `{"error": {"code": "OperationNotAllowed", "message": "Operation could not be completed as it results in exceeding approved standardDSv3Family Cores quota"}}`, errorkind.QuotaBlocked},
+		{"quota exceeded detail", http.StatusBadRequest, `{"error": {"code": "InvalidTemplateDeployment", "message": "failed", "details": [{"code": "QuotaExceeded", "message": "cores"}]}}`, errorkind.QuotaBlocked},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			}))
+			defer server.Close()
+			client := &computeClient{
+				http:     server.Client(),
+				endpoint: server.URL + "/",
+				token:    func(context.Context) (string, error) { return "token", nil },
+			}
+
+			err := client.setCapacity(context.Background(), testScaleSetRef, 3)
+			require.Error(t, err)
+			assert.Equal(t, tt.kind, errorkind.Of(err))
+		})
+	}
+}
+
+func TestComputeClient_ListScaleSetVMsPages(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page := listScaleSetVMsResponse{}
+		if r.URL.Query().Get("$skiptoken") == "" {
+			page.Value = []scaleSetVM{{InstanceID: "0"}, {InstanceID: "1"}}
+			page.NextLink = server.URL + "/" + testScaleSetRef.path() + "/virtualMachines?api-version=" + computeAPIVersion + "&$skiptoken=next"
+		} else {
+			page.Value = []scaleSetVM{{InstanceID: "2"}}
+		}
+		json.NewEncoder(w).Encode(page)
+	}))
+	defer server.Close()
+	client := &computeClient{
+		http:     server.Client(),
+		endpoint: server.URL + "/",
+		token:    func(context.Context) (string, error) { return "token", nil },
+	}
+
+	vms, err := client.listScaleSetVMs(context.Background(), testScaleSetRef)
+	require.NoError(t, err)
+	require.Len(t, vms, 3)
+	assert.Equal(t, "2", vms[2].InstanceID)
+}
+
+func TestParseScaleSetRef(t *testing.T) {
+	want := scaleSetRef{Subscription: "sub", ResourceGroup: "rg", Name: "vmss"}
+	for _, name := range []string{
+		"sub/rg/vmss",
+		"/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Compute/virtualMachineScaleSets/vmss",
+		"/subscriptions/sub/resourcegroups/rg/providers/microsoft.compute/virtualmachinescalesets/vmss",
+	} {
+		ref, err := parseScaleSetRef(name)
+		require.NoError(t, err, name)
+		assert.Equal(t, want, ref)
+	}
+
+	for _, name := range []string{"", "vmss", "sub//vmss", "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Compute/virtualMachines/vm"} {
+		_, err := parseScaleSetRef(name)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestParseProviderID(t *testing.T) {
+	ref, err := parseProviderID("azure:///subscriptions/sub/resourceGroups/mc_rg/providers/Microsoft.Compute/virtualMachineScaleSets/vmss/virtualMachines/3")
+	require.NoError(t, err)
+	assert.Equal(t, instanceRef{ScaleSet: scaleSetRef{Subscription: "sub", ResourceGroup: "mc_rg", Name: "vmss"}, InstanceID: "3"}, ref)
+	assert.Equal(t, "azure:///subscriptions/sub/resourceGroups/mc_rg/providers/Microsoft.Compute/virtualMachineScaleSets/vmss/virtualMachines/3", ref.providerID())
+	assert.True(t, ref.equal(instanceRef{ScaleSet: scaleSetRef{Subscription: "sub", ResourceGroup: "MC_RG", Name: "vmss"}, InstanceID: "3"}))
+
+	for _, providerID := range []string{
+		"",
+		"aws:///us-east-1a/i-123",
+		"azure:///subscriptions/sub/resourceGroups/rg/providers/Microsoft.Compute/virtualMachines/vm",
+		"azure:///subscriptions/sub/resourceGroups/rg/providers/Microsoft.Compute/virtualMachineScaleSets/vmss/virtualMachines/",
+	} {
+		_, err := parseProviderID(providerID)
+		assert.Error(t, err, providerID)
+	}
+}
@@ -0,0 +1,106 @@
+package azure
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/atlassian/escalator/pkg/errorkind"
+)
+
+// Opts includes options for the Azure cloud provider
+type Opts struct {
+	// APITimeout is the timeout of a single resource manager api call. zero means no timeout
+	APITimeout time.Duration
+	// IdentityClientID is the client id of the user assigned managed identity the access tokens are issued for, e.g.
+	// the kubelet identity of an AKS cluster. Empty means the system assigned identity
+	IdentityClientID string
+	// ResourceManagerEndpoint and MetadataEndpoint override the base urls of the resource manager api and the instance
+	// metadata service, e.g. for sovereign clouds or testing. Empty means the defaults
+	ResourceManagerEndpoint string
+	MetadataEndpoint        string
+}
+
+// scaleSetRef identifies a virtual machine scale set
+type scaleSetRef struct {
+	Subscription  string
+	ResourceGroup string
+	Name          string
+}
+
+// parseScaleSetRef parses the cloud_provider_group_name of a node group, either the resource id of the virtual
+// machine scale set or <subscription>/<resource group>/<name>
+func parseScaleSetRef(name string) (scaleSetRef, error) {
+	parts := strings.Split(strings.Trim(name, "/"), "/")
+	var ref scaleSetRef
+	switch {
+	case len(parts) == 8 && strings.EqualFold(parts[0], "subscriptions") && strings.EqualFold(parts[2], "resourceGroups") &&
+		strings.EqualFold(parts[4], "providers") && strings.EqualFold(parts[5], "Microsoft.Compute") &&
+		strings.EqualFold(parts[6], "virtualMachineScaleSets"):
+		ref = scaleSetRef{Subscription: parts[1], ResourceGroup: parts[3], Name: parts[7]}
+	case len(parts) == 3:
+		ref = scaleSetRef{Subscription: parts[0], ResourceGroup: parts[1], Name: parts[2]}
+	default:
+		return scaleSetRef{}, errorkind.New(errorkind.Validation, "virtual machine scale set %q is not in the form /subscriptions/<subscription>/resourceGroups/<resource group>/providers/Microsoft.Compute/virtualMachineScaleSets/<name> or <subscription>/<resource group>/<name>", name)
+	}
+	if len(ref.Subscription) == 0 || len(ref.ResourceGroup) == 0 || len(ref.Name) == 0 {
+		return scaleSetRef{}, errorkind.New(errorkind.Validation, "virtual machine scale set %q has an empty subscription, resource group or name", name)
+	}
+	return ref, nil
+}
+
+// path returns the path of the virtual machine scale set under the resource manager api
+func (r scaleSetRef) path() string {
+	return fmt.Sprintf("subscriptions/%v/resourceGroups/%v/providers/Microsoft.Compute/virtualMachineScaleSets/%v", r.Subscription, r.ResourceGroup, r.Name)
+}
+
+// equal returns whether the refs identify the same virtual machine scale set. Resource ids are case insensitive, e.g.
+// AKS writes the resource group of the provider ids of its nodes in lower case
+func (r scaleSetRef) equal(other scaleSetRef) bool {
+	return strings.EqualFold(r.Subscription, other.Subscription) && strings.EqualFold(r.ResourceGroup, other.ResourceGroup) &&
+		strings.EqualFold(r.Name, other.Name)
+}
+
+// instanceRef identifies a virtual machine of a virtual machine scale set
+type instanceRef struct {
+	ScaleSet   scaleSetRef
+	InstanceID string
+}
+
+// parseResourceID parses the resource id of a virtual machine of a virtual machine scale set,
+// /subscriptions/<subscription>/resourceGroups/<resource group>/providers/Microsoft.Compute/virtualMachineScaleSets/<name>/virtualMachines/<instance id>
+func parseResourceID(id string) (instanceRef, error) {
+	parts := strings.Split(strings.Trim(id, "/"), "/")
+	if len(parts) != 10 || !strings.EqualFold(parts[8], "virtualMachines") || len(parts[9]) == 0 {
+		return instanceRef{}, errorkind.New(errorkind.Validation, "resource id %q is not a virtual machine of a virtual machine scale set", id)
+	}
+	scaleSet, err := parseScaleSetRef(strings.Join(parts[:8], "/"))
+	if err != nil {
+		return instanceRef{}, errorkind.New(errorkind.Validation, "resource id %q is not a virtual machine of a virtual machine scale set", id)
+	}
+	return instanceRef{ScaleSet: scaleSet, InstanceID: parts[9]}, nil
+}
+
+// parseProviderID parses the provider id of a node of a virtual machine scale set, azure://<resource id>
+func parseProviderID(providerID string) (instanceRef, error) {
+	prefix := ProviderName + "://"
+	if !strings.HasPrefix(providerID, prefix) {
+		return instanceRef{}, errorkind.New(errorkind.Validation, "provider id %q is not from the %v cloud provider", providerID, ProviderName)
+	}
+	return parseResourceID(strings.TrimPrefix(providerID, prefix))
+}
+
+// equal returns whether the refs identify the same virtual machine
+func (r instanceRef) equal(other instanceRef) bool {
+	return r.ScaleSet.equal(other.ScaleSet) && r.InstanceID == other.InstanceID
+}
+
+// path returns the path of the virtual machine under the resource manager api
+func (r instanceRef) path() string {
+	return fmt.Sprintf("%v/virtualMachines/%v", r.ScaleSet.path(), r.InstanceID)
+}
+
+// providerID returns the provider id of the node of the virtual machine
+func (r instanceRef) providerID() string {
+	return fmt.Sprintf("%v:///%v", ProviderName, r.path())
+}
@@ -0,0 +1,51 @@
+package gce
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/atlassian/escalator/pkg/cloudprovider"
+	log "github.com/sirupsen/logrus"
+)
+
+// Builder builds the gce cloud provider
+type Builder struct {
+	ProviderOpts cloudprovider.BuildOpts
+	Opts         Opts
+
+	// transport sends the api requests instead of the default http transport, e.g. to record them
+	transport http.RoundTripper
+}
+
+// Build the cloud provider
+func (b Builder) Build() (cloudprovider.CloudProvider, error) {
+	httpClient := &http.Client{Timeout: b.Opts.APITimeout, Transport: b.transport}
+
+	computeEndpoint := b.Opts.ComputeEndpoint
+	if len(computeEndpoint) == 0 {
+		computeEndpoint = DefaultComputeEndpoint
+	}
+	metadataEndpoint := b.Opts.MetadataEndpoint
+	if len(metadataEndpoint) == 0 {
+		metadataEndpoint = DefaultMetadataEndpoint
+	}
+
+	// the access tokens of the service account of the node, or of the Kubernetes service account with Workload
+	// Identity, are read from the metadata server
+	cloud := &CloudProvider{
+		client: &computeClient{
+			http:     httpClient,
+			endpoint: computeEndpoint,
+			token:    metadataTokenSource(httpClient, metadataEndpoint),
+		},
+		nodeGroups: make(map[string]*NodeGroup, len(b.ProviderOpts.NodeGroupConfigs)),
+	}
+
+	// Register the node groups
+	if err := cloud.RegisterNodeGroups(context.Background(), b.ProviderOpts.NodeGroupConfigs...); err != nil {
+		return nil, err
+	}
+
+	log.Infof("gce cloud provider created successfully, using compute endpoint %v", computeEndpoint)
+	return cloud, nil
+}
@@ -0,0 +1,287 @@
+package gce
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/atlassian/escalator/pkg/errorkind"
+	"github.com/atlassian/escalator/pkg/metrics"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultComputeEndpoint is the base url of the compute engine v1 api
+	DefaultComputeEndpoint = "https://compute.googleapis.com/compute/v1/"
+	// DefaultMetadataEndpoint is the base url of the metadata server that issues the access tokens of the service
+	// account of the instance, or of the Kubernetes service account with GKE Workload Identity
+	DefaultMetadataEndpoint = "http://metadata.google.internal/computeMetadata/v1/"
+
+	// computeService is the service label of the api metrics
+	computeService = "compute"
+	// managedInstancesPageSize is how many managed instances are listed per page
+	managedInstancesPageSize = 500
+	// tokenExpiryMargin is how long before its expiry an access token is refreshed
+	tokenExpiryMargin = time.Minute
+)
+
+// instanceGroupManager is the part of a managed instance group escalator reads
+type instanceGroupManager struct {
+	Name             string `json:"name"`
+	Zone             string `json:"zone"`
+	InstanceTemplate string `json:"instanceTemplate"`
+	TargetSize       int64  `json:"targetSize"`
+}
+
+// managedInstance is an instance of a managed instance group
+type managedInstance struct {
+	// Instance is the url of the instance
+	Instance       string `json:"instance"`
+	InstanceStatus string `json:"instanceStatus"`
+	CurrentAction  string `json:"currentAction"`
+}
+
+// listManagedInstancesResponse is a page of the managed instances of a managed instance group
+type listManagedInstancesResponse struct {
+	ManagedInstances []managedInstance `json:"managedInstances"`
+	NextPageToken    string            `json:"nextPageToken"`
+}
+
+// instance is the part of a compute engine instance escalator reads
+type instance struct {
+	ID                string            `json:"id"`
+	Name              string            `json:"name"`
+	CreationTimestamp string            `json:"creationTimestamp"`
+	Labels            map[string]string `json:"labels"`
+}
+
+// operation is a compute engine operation. Operations run asynchronously, an operation that failed to start has an
+// error straight away
+type operation struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Error  *struct {
+		Errors []struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"errors"`
+	} `json:"error"`
+}
+
+// err returns the errors of the operation, nil if it hasn't failed
+func (o *operation) err() error {
+	if o == nil || o.Error == nil || len(o.Error.Errors) == 0 {
+		return nil
+	}
+	kind := errorkind.Unknown
+	messages := make([]string, 0, len(o.Error.Errors))
+	for _, e := range o.Error.Errors {
+		if e.Code == "QUOTA_EXCEEDED" {
+			kind = errorkind.QuotaBlocked
+		}
+		messages = append(messages, fmt.Sprintf("%v: %v", e.Code, e.Message))
+	}
+	return errorkind.New(kind, "operation %v failed: %v", o.Name, strings.Join(messages, ", "))
+}
+
+// apiError is the error body of the compute engine api
+type apiError struct {
+	Error struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Errors  []struct {
+			Reason string `json:"reason"`
+		} `json:"errors"`
+	} `json:"error"`
+}
+
+// tokenSource returns an access token for the compute engine api
+type tokenSource func(ctx context.Context) (string, error)
+
+// metadataTokenSource returns the access tokens of the service account from the metadata server, cached until
+// shortly before they expire
+func metadataTokenSource(client *http.Client, endpoint string) tokenSource {
+	var lock sync.Mutex
+	var token string
+	var expiry time.Time
+	return func(ctx context.Context) (string, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		if len(token) > 0 && time.Now().Before(expiry) {
+			return token, nil
+		}
+
+		req, err := http.NewRequest(http.MethodGet, endpoint+"instance/service-accounts/default/token", nil)
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("Metadata-Flavor", "Google")
+		resp, err := client.Do(req.WithContext(ctx))
+		if err != nil {
+			return "", errors.Wrap(err, "failed to get an access token from the metadata server")
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return "", fmt.Errorf("failed to get an access token from the metadata server: %v", resp.Status)
+		}
+		var body struct {
+			AccessToken string `json:"access_token"`
+			ExpiresIn   int64  `json:"expires_in"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			return "", err
+		}
+		token = body.AccessToken
+		expiry = time.Now().Add(time.Duration(body.ExpiresIn)*time.Second - tokenExpiryMargin)
+		return token, nil
+	}
+}
+
+// computeClient calls the compute engine api for the managed instance groups and their instances
+type computeClient struct {
+	http     *http.Client
+	endpoint string
+	token    tokenSource
+}
+
+// do sends a request for the operation to the path under the endpoint, and decodes the response into out
+func (c *computeClient) do(ctx context.Context, operationName string, method string, path string, in interface{}, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		encoded, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(encoded)
+	}
+	req, err := http.NewRequest(method, c.endpoint+path, body)
+	if err != nil {
+		return err
+	}
+	token, err := c.token(ctx)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req.WithContext(ctx))
+	if err != nil {
+		if ctx.Err() != nil {
+			return errorkind.Wrap(errorkind.Cancelled, err, operationName+" was cancelled")
+		}
+		return errors.Wrap(err, operationName+" failed")
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		return c.error(operationName, resp.StatusCode, data)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
+
+// error returns the error of a failed api call, counting the calls that were throttled
+func (c *computeClient) error(operationName string, status int, data []byte) error {
+	var body apiError
+	message := strings.TrimSpace(string(data))
+	if err := json.Unmarshal(data, &body); err == nil && len(body.Error.Message) > 0 {
+		message = body.Error.Message
+	}
+
+	throttled := status == http.StatusTooManyRequests
+	for _, e := range body.Error.Errors {
+		if e.Reason == "rateLimitExceeded" || e.Reason == "userRateLimitExceeded" {
+			throttled = true
+		}
+	}
+	switch {
+	case throttled:
+		metrics.CloudProviderAPIThrottles.WithLabelValues(ProviderName, computeService, operationName).Add(1.0)
+		return errorkind.New(errorkind.Throttled, "%v was throttled: %v", operationName, message)
+	case status == http.StatusNotFound:
+		return errorkind.New(errorkind.NotFound, "%v: %v", operationName, message)
+	case status == http.StatusConflict:
+		return errorkind.New(errorkind.Conflict, "%v: %v", operationName, message)
+	case status == http.StatusBadRequest:
+		return errorkind.New(errorkind.Validation, "%v: %v", operationName, message)
+	default:
+		return fmt.Errorf("%v failed with status %v: %v", operationName, status, message)
+	}
+}
+
+// getInstanceGroupManager returns the managed instance group
+func (c *computeClient) getInstanceGroupManager(ctx context.Context, ref groupRef) (*instanceGroupManager, error) {
+	var group instanceGroupManager
+	if err := c.do(ctx, "GetInstanceGroupManager", http.MethodGet, ref.path(), nil, &group); err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+// listManagedInstances returns all of the instances of the managed instance group
+func (c *computeClient) listManagedInstances(ctx context.Context, ref groupRef) ([]managedInstance, error) {
+	var instances []managedInstance
+	pageToken := ""
+	for {
+		query := url.Values{"maxResults": {fmt.Sprint(managedInstancesPageSize)}}
+		if len(pageToken) > 0 {
+			query.Set("pageToken", pageToken)
+		}
+		var page listManagedInstancesResponse
+		if err := c.do(ctx, "ListManagedInstances", http.MethodPost, ref.path()+"/listManagedInstances?"+query.Encode(), nil, &page); err != nil {
+			return nil, err
+		}
+		instances = append(instances, page.ManagedInstances...)
+		if len(page.NextPageToken) == 0 {
+			return instances, nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
+
+// resize sets the target size of the managed instance group
+func (c *computeClient) resize(ctx context.Context, ref groupRef, size int64) error {
+	var op operation
+	if err := c.do(ctx, "Resize", http.MethodPost, ref.path()+"/resize?size="+fmt.Sprint(size), nil, &op); err != nil {
+		return err
+	}
+	return op.err()
+}
+
+// deleteInstances deletes the instances from the managed instance group, which reduces its target size by the
+// number of instances
+func (c *computeClient) deleteInstances(ctx context.Context, ref groupRef, instanceURLs []string) error {
+	in := struct {
+		Instances []string `json:"instances"`
+	}{instanceURLs}
+	var op operation
+	if err := c.do(ctx, "DeleteInstances", http.MethodPost, ref.path()+"/deleteInstances", in, &op); err != nil {
+		return err
+	}
+	return op.err()
+}
+
+// getInstance returns the instance
+func (c *computeClient) getInstance(ctx context.Context, ref instanceRef) (*instance, error) {
+	var i instance
+	if err := c.do(ctx, "GetInstance", http.MethodGet, ref.path(), nil, &i); err != nil {
+		return nil, err
+	}
+	return &i, nil
+}
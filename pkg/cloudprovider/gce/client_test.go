@@ -0,0 +1,112 @@
+package gce
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/atlassian/escalator/pkg/errorkind"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetadataTokenSource(t *testing.T) {
+	var requests int
+	expiresIn := 3600
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		assert.Equal(t, "Google", r.Header.Get("Metadata-Flavor"))
+		json.NewEncoder(w).Encode(map[string]interface{}{"access_token": fmt.Sprint("token-", requests), "expires_in": expiresIn})
+	}))
+	defer server.Close()
+
+	// the token is cached until shortly before it expires
+	token := metadataTokenSource(server.Client(), server.URL+"/")
+	for i := 0; i < 3; i++ {
+		value, err := token(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "token-1", value)
+	}
+	assert.Equal(t, 1, requests)
+
+	// a token that expires within the margin is fetched again
+	expiresIn = 30
+	token = metadataTokenSource(server.Client(), server.URL+"/")
+	for i := 0; i < 2; i++ {
+		_, err := token(context.Background())
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 3, requests)
+}
+
+func TestComputeClient_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		kind   errorkind.Kind
+	}{
+		{"too many requests", http.StatusTooManyRequests, `{"error": {"code": 429, "message": "slow down"}}`, errorkind.Throttled},
+		{"rate limit exceeded", http.StatusForbidden, `{"error": {"code": 403, "message": "quota", "errors": [{"reason": "rateLimitExceeded"}]}}`, errorkind.Throttled},
+		{"not found", http.StatusNotFound, `{"error": {"code": 404, "message": "not found"}}`, errorkind.NotFound},
+		{"conflict", http.StatusConflict, `{"error": {"code": 409, "message": "busy"}}`, errorkind.Conflict},
+		{"bad request", http.StatusBadRequest, `{"error": {"code": 400, "message": "invalid size"}}`, errorkind.Validation},
+		{"forbidden", http.StatusForbidden, `{"error": {"code": 403, "message": "denied"}}`, errorkind.Unknown},
+		{"failed operation", http.StatusOK, `{"name": "resize", "error": {"errors": [{"code": "QUOTA_EXCEEDED", "message": "CPUS"}]}}`, errorkind.QuotaBlocked},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			}))
+			defer server.Close()
+			client := &computeClient{
+				http:     server.Client(),
+				endpoint: server.URL + "/",
+				token:    func(context.Context) (string, error) { return "token", nil },
+			}
+
+			err := client.resize(context.Background(), groupRef{Project: testProject, Zone: testZone, Name: testMIG}, 3)
+			require.Error(t, err)
+			assert.Equal(t, tt.kind, errorkind.Of(err))
+		})
+	}
+}
+
+func TestParseGroupRef(t *testing.T) {
+	want := groupRef{Project: "my-project", Zone: "us-central1-a", Name: "my-mig"}
+	for _, name := range []string{
+		"my-project/us-central1-a/my-mig",
+		"projects/my-project/zones/us-central1-a/instanceGroupManagers/my-mig",
+		"https://www.googleapis.com/compute/v1/projects/my-project/zones/us-central1-a/instanceGroupManagers/my-mig",
+	} {
+		ref, err := parseGroupRef(name)
+		require.NoError(t, err, name)
+		assert.Equal(t, want, ref)
+	}
+
+	for _, name := range []string{"", "my-mig", "my-project//my-mig", "projects/my-project/regions/us-central1/instanceGroupManagers/my-mig"} {
+		_, err := parseGroupRef(name)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestParseProviderID(t *testing.T) {
+	ref, err := parseProviderID("gce://my-project/us-central1-a/gke-node-1")
+	require.NoError(t, err)
+	assert.Equal(t, instanceRef{Project: "my-project", Zone: "us-central1-a", Name: "gke-node-1"}, ref)
+	assert.Equal(t, "gce://my-project/us-central1-a/gke-node-1", ref.providerID())
+
+	instanceURLRef, err := parseInstanceURL("https://www.googleapis.com/compute/v1/projects/my-project/zones/us-central1-a/instances/gke-node-1")
+	require.NoError(t, err)
+	assert.Equal(t, ref, instanceURLRef)
+
+	for _, providerID := range []string{"", "aws:///us-east-1a/i-123", "gce://gke-node-1", "gce://my-project//gke-node-1"} {
+		_, err := parseProviderID(providerID)
+		assert.Error(t, err, providerID)
+	}
+}
@@ -0,0 +1,278 @@
+package gce
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/atlassian/escalator/pkg/cloudprovider"
+	"github.com/atlassian/escalator/pkg/errorkind"
+	"github.com/atlassian/escalator/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	testProject = "my-project"
+	testZone    = "us-central1-a"
+	testMIG     = "gke-cluster-default-pool-1234abcd-grp"
+)
+
+// fakeCompute is a compute engine api and metadata server with a single managed instance group
+type fakeCompute struct {
+	sync.Mutex
+	targetSize int64
+	instances  []string
+	// status is returned instead of the response when set
+	status int
+
+	resizes   []int64
+	deletions [][]string
+}
+
+func newFakeCompute(targetSize int64, instances ...string) *fakeCompute {
+	return &fakeCompute{targetSize: targetSize, instances: instances}
+}
+
+func (f *fakeCompute) instanceURL(name string) string {
+	return fmt.Sprintf("https://www.googleapis.com/compute/v1/projects/%v/zones/%v/instances/%v", testProject, testZone, name)
+}
+
+func (f *fakeCompute) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+
+	if strings.HasSuffix(r.URL.Path, "/service-accounts/default/token") {
+		json.NewEncoder(w).Encode(map[string]interface{}{"access_token": "token", "expires_in": 3600})
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if f.status != 0 {
+		w.WriteHeader(f.status)
+		fmt.Fprint(w, `{"error": {"code": 429, "message": "rate limited", "errors": [{"reason": "rateLimitExceeded"}]}}`)
+		return
+	}
+
+	migPath := "/compute/v1/" + groupRef{Project: testProject, Zone: testZone, Name: testMIG}.path()
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == migPath:
+		json.NewEncoder(w).Encode(instanceGroupManager{Name: testMIG, TargetSize: f.targetSize})
+	case r.Method == http.MethodPost && r.URL.Path == migPath+"/listManagedInstances":
+		var page listManagedInstancesResponse
+		for _, name := range f.instances {
+			page.ManagedInstances = append(page.ManagedInstances, managedInstance{Instance: f.instanceURL(name), InstanceStatus: "RUNNING", CurrentAction: "NONE"})
+		}
+		json.NewEncoder(w).Encode(page)
+	case r.Method == http.MethodPost && r.URL.Path == migPath+"/resize":
+		var size int64
+		fmt.Sscan(r.URL.Query().Get("size"), &size)
+		f.resizes = append(f.resizes, size)
+		f.targetSize = size
+		json.NewEncoder(w).Encode(operation{Name: "resize", Status: "RUNNING"})
+	case r.Method == http.MethodPost && r.URL.Path == migPath+"/deleteInstances":
+		var body struct {
+			Instances []string `json:"instances"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		f.deletions = append(f.deletions, body.Instances)
+		f.targetSize -= int64(len(body.Instances))
+		json.NewEncoder(w).Encode(operation{Name: "delete", Status: "RUNNING"})
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, fmt.Sprintf("/compute/v1/projects/%v/zones/%v/instances/", testProject, testZone)):
+		name := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		json.NewEncoder(w).Encode(instance{ID: "1234567890", Name: name, CreationTimestamp: "2019-02-01T10:00:00.000-08:00"})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"error": {"code": 404, "message": "not found"}}`)
+	}
+}
+
+func buildTestCloudProvider(t *testing.T, fake *fakeCompute, minSize int64, maxSize int64) (*CloudProvider, *httptest.Server) {
+	server := httptest.NewServer(fake)
+	builder := Builder{
+		ProviderOpts: cloudprovider.BuildOpts{
+			ProviderID: ProviderName,
+			NodeGroupConfigs: []cloudprovider.NodeGroupConfig{{
+				GroupID:   fmt.Sprintf("%v/%v/%v", testProject, testZone, testMIG),
+				GCEConfig: cloudprovider.GCENodeGroupConfig{MinSize: minSize, MaxSize: maxSize},
+			}},
+		},
+		Opts: Opts{
+			ComputeEndpoint:  server.URL + "/compute/v1/",
+			MetadataEndpoint: server.URL + "/computeMetadata/v1/",
+		},
+	}
+	cloud, err := builder.Build()
+	require.NoError(t, err)
+	return cloud.(*CloudProvider), server
+}
+
+func buildTestGCENode(name string) *v1.Node {
+	node := test.BuildTestNode(test.NodeOpts{Name: name})
+	node.Spec.ProviderID = fmt.Sprintf("gce://%v/%v/%v", testProject, testZone, name)
+	return node
+}
+
+func testNodeGroup(t *testing.T, cloud *CloudProvider) *NodeGroup {
+	nodeGroup, ok := cloud.GetNodeGroup(fmt.Sprintf("%v/%v/%v", testProject, testZone, testMIG))
+	require.True(t, ok)
+	return nodeGroup.(*NodeGroup)
+}
+
+func TestCloudProvider_RegisterNodeGroups(t *testing.T) {
+	fake := newFakeCompute(2, "n1", "n2")
+	cloud, server := buildTestCloudProvider(t, fake, 1, 5)
+	defer server.Close()
+
+	assert.Equal(t, ProviderName, cloud.Name())
+	require.Len(t, cloud.NodeGroups(), 1)
+	nodeGroup := testNodeGroup(t, cloud)
+	assert.Equal(t, int64(1), nodeGroup.MinSize())
+	assert.Equal(t, int64(5), nodeGroup.MaxSize())
+	assert.Equal(t, int64(2), nodeGroup.TargetSize())
+	assert.Equal(t, int64(2), nodeGroup.Size())
+	assert.Equal(t, []string{
+		fmt.Sprintf("gce://%v/%v/n1", testProject, testZone),
+		fmt.Sprintf("gce://%v/%v/n2", testProject, testZone),
+	}, nodeGroup.Nodes())
+
+	// refresh picks up the changes made outside of escalator
+	fake.Lock()
+	fake.targetSize = 3
+	fake.instances = append(fake.instances, "n3")
+	fake.Unlock()
+	require.NoError(t, cloud.Refresh(context.Background()))
+	assert.Equal(t, int64(3), nodeGroup.TargetSize())
+	assert.Equal(t, int64(3), nodeGroup.Size())
+}
+
+func TestCloudProvider_RegisterNodeGroupsInvalid(t *testing.T) {
+	fake := newFakeCompute(2, "n1", "n2")
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	cloud := &CloudProvider{
+		client:     &computeClient{http: server.Client(), endpoint: server.URL + "/compute/v1/", token: metadataTokenSource(server.Client(), server.URL+"/computeMetadata/v1/")},
+		nodeGroups: make(map[string]*NodeGroup),
+	}
+
+	tests := []struct {
+		name   string
+		config cloudprovider.NodeGroupConfig
+		kind   errorkind.Kind
+	}{
+		{"no max size", cloudprovider.NodeGroupConfig{GroupID: testProject + "/" + testZone + "/" + testMIG}, errorkind.Validation},
+		{"min above max", cloudprovider.NodeGroupConfig{GroupID: testProject + "/" + testZone + "/" + testMIG, GCEConfig: cloudprovider.GCENodeGroupConfig{MinSize: 5, MaxSize: 2}}, errorkind.Validation},
+		{"invalid name", cloudprovider.NodeGroupConfig{GroupID: testMIG, GCEConfig: cloudprovider.GCENodeGroupConfig{MaxSize: 2}}, errorkind.Validation},
+		{"missing group", cloudprovider.NodeGroupConfig{GroupID: testProject + "/" + testZone + "/missing", GCEConfig: cloudprovider.GCENodeGroupConfig{MaxSize: 2}}, errorkind.NotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := cloud.RegisterNodeGroups(context.Background(), tt.config)
+			require.Error(t, err)
+			assert.Equal(t, tt.kind, errorkind.Of(err))
+		})
+	}
+	assert.Empty(t, cloud.NodeGroups())
+}
+
+func TestNodeGroup_Belongs(t *testing.T) {
+	cloud, server := buildTestCloudProvider(t, newFakeCompute(2, "n1", "n2"), 0, 5)
+	defer server.Close()
+	nodeGroup := testNodeGroup(t, cloud)
+
+	assert.True(t, nodeGroup.Belongs(buildTestGCENode("n1")))
+	assert.False(t, nodeGroup.Belongs(buildTestGCENode("n3")))
+
+	// the name of the node isn't enough, the instance is matched by its provider id
+	node := buildTestGCENode("n1")
+	node.Spec.ProviderID = fmt.Sprintf("gce://other-project/%v/n1", testZone)
+	assert.False(t, nodeGroup.Belongs(node))
+	node.Spec.ProviderID = ""
+	assert.False(t, nodeGroup.Belongs(node))
+}
+
+func TestNodeGroup_IncreaseSize(t *testing.T) {
+	fake := newFakeCompute(2, "n1", "n2")
+	cloud, server := buildTestCloudProvider(t, fake, 1, 5)
+	defer server.Close()
+	nodeGroup := testNodeGroup(t, cloud)
+
+	assert.Error(t, nodeGroup.IncreaseSize(context.Background(), 0))
+	assert.Error(t, nodeGroup.IncreaseSize(context.Background(), 4))
+	require.NoError(t, nodeGroup.IncreaseSize(context.Background(), 3))
+	assert.Equal(t, []int64{5}, fake.resizes)
+	assert.Equal(t, int64(5), nodeGroup.TargetSize())
+}
+
+func TestNodeGroup_DecreaseTargetSize(t *testing.T) {
+	fake := newFakeCompute(4, "n1", "n2")
+	cloud, server := buildTestCloudProvider(t, fake, 1, 5)
+	defer server.Close()
+	nodeGroup := testNodeGroup(t, cloud)
+
+	assert.Error(t, nodeGroup.DecreaseTargetSize(context.Background(), 1))
+	assert.Error(t, nodeGroup.DecreaseTargetSize(context.Background(), -4))
+	require.NoError(t, nodeGroup.DecreaseTargetSize(context.Background(), -2))
+	assert.Equal(t, []int64{2}, fake.resizes)
+	assert.Equal(t, int64(2), nodeGroup.TargetSize())
+}
+
+func TestNodeGroup_DeleteNodes(t *testing.T) {
+	fake := newFakeCompute(3, "n1", "n2", "n3")
+	cloud, server := buildTestCloudProvider(t, fake, 1, 5)
+	defer server.Close()
+	nodeGroup := testNodeGroup(t, cloud)
+
+	// nodes of other node groups aren't deleted
+	err := nodeGroup.DeleteNodes(context.Background(), buildTestGCENode("n1"), buildTestGCENode("n4"))
+	require.Error(t, err)
+	assert.IsType(t, &cloudprovider.NodeNotInNodeGroup{}, err)
+	assert.Empty(t, fake.deletions)
+
+	// the min size is respected
+	assert.Error(t, nodeGroup.DeleteNodes(context.Background(), buildTestGCENode("n1"), buildTestGCENode("n2"), buildTestGCENode("n3")))
+
+	require.NoError(t, nodeGroup.DeleteNodes(context.Background(), buildTestGCENode("n1"), buildTestGCENode("n3")))
+	assert.Equal(t, [][]string{{fake.instanceURL("n1"), fake.instanceURL("n3")}}, fake.deletions)
+	assert.Equal(t, int64(1), nodeGroup.TargetSize())
+	assert.Empty(t, fake.resizes)
+}
+
+func TestCloudProvider_GetInstance(t *testing.T) {
+	cloud, server := buildTestCloudProvider(t, newFakeCompute(1, "n1"), 0, 5)
+	defer server.Close()
+
+	instance, err := cloud.GetInstance(context.Background(), buildTestGCENode("n1"))
+	require.NoError(t, err)
+	assert.Equal(t, "1234567890", instance.ID())
+	assert.True(t, time.Date(2019, 2, 1, 18, 0, 0, 0, time.UTC).Equal(instance.InstantiationTime()))
+
+	node := buildTestGCENode("n1")
+	node.Spec.ProviderID = "aws:///us-east-1a/i-123"
+	_, err = cloud.GetInstance(context.Background(), node)
+	assert.Error(t, err)
+}
+
+func TestCloudProvider_Throttled(t *testing.T) {
+	fake := newFakeCompute(2, "n1", "n2")
+	cloud, server := buildTestCloudProvider(t, fake, 1, 5)
+	defer server.Close()
+	nodeGroup := testNodeGroup(t, cloud)
+
+	fake.Lock()
+	fake.status = http.StatusTooManyRequests
+	fake.Unlock()
+	err := nodeGroup.IncreaseSize(context.Background(), 1)
+	require.Error(t, err)
+	assert.Equal(t, errorkind.Throttled, errorkind.Of(err))
+	assert.Equal(t, int64(2), nodeGroup.TargetSize())
+}
@@ -0,0 +1,97 @@
+package gce
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/atlassian/escalator/pkg/errorkind"
+)
+
+// Opts includes options for the GCE cloud provider
+type Opts struct {
+	// APITimeout is the timeout of a single compute engine api call. zero means no timeout
+	APITimeout time.Duration
+	// ComputeEndpoint and MetadataEndpoint override the base urls of the compute engine api and the metadata server,
+	// e.g. for testing. Empty means the defaults
+	ComputeEndpoint  string
+	MetadataEndpoint string
+}
+
+// groupRef identifies a zonal managed instance group
+type groupRef struct {
+	Project string
+	Zone    string
+	Name    string
+}
+
+// parseGroupRef parses the cloud_provider_group_name of a node group, either the self link of the managed instance
+// group, projects/<project>/zones/<zone>/instanceGroupManagers/<name> or <project>/<zone>/<name>
+func parseGroupRef(name string) (groupRef, error) {
+	trimmed := name
+	if i := strings.Index(trimmed, "projects/"); i >= 0 {
+		trimmed = trimmed[i:]
+	}
+	parts := strings.Split(strings.Trim(trimmed, "/"), "/")
+	var ref groupRef
+	switch {
+	case len(parts) == 6 && parts[0] == "projects" && parts[2] == "zones" && parts[4] == "instanceGroupManagers":
+		ref = groupRef{Project: parts[1], Zone: parts[3], Name: parts[5]}
+	case len(parts) == 3:
+		ref = groupRef{Project: parts[0], Zone: parts[1], Name: parts[2]}
+	default:
+		return groupRef{}, errorkind.New(errorkind.Validation, "managed instance group %q is not in the form projects/<project>/zones/<zone>/instanceGroupManagers/<name> or <project>/<zone>/<name>", name)
+	}
+	if len(ref.Project) == 0 || len(ref.Zone) == 0 || len(ref.Name) == 0 {
+		return groupRef{}, errorkind.New(errorkind.Validation, "managed instance group %q has an empty project, zone or name", name)
+	}
+	return ref, nil
+}
+
+// path returns the path of the managed instance group under the compute engine api
+func (r groupRef) path() string {
+	return fmt.Sprintf("projects/%v/zones/%v/instanceGroupManagers/%v", r.Project, r.Zone, r.Name)
+}
+
+// instanceRef identifies a compute engine instance
+type instanceRef struct {
+	Project string
+	Zone    string
+	Name    string
+}
+
+// parseInstanceURL parses the url of an instance, as listed in the managed instances of a managed instance group
+func parseInstanceURL(instanceURL string) (instanceRef, error) {
+	parts := strings.Split(strings.Trim(instanceURL, "/"), "/")
+	if len(parts) < 6 {
+		return instanceRef{}, fmt.Errorf("instance url %q is not in the form .../projects/<project>/zones/<zone>/instances/<name>", instanceURL)
+	}
+	parts = parts[len(parts)-6:]
+	if parts[0] != "projects" || parts[2] != "zones" || parts[4] != "instances" {
+		return instanceRef{}, fmt.Errorf("instance url %q is not in the form .../projects/<project>/zones/<zone>/instances/<name>", instanceURL)
+	}
+	return instanceRef{Project: parts[1], Zone: parts[3], Name: parts[5]}, nil
+}
+
+// parseProviderID parses the provider id of a node on GCE, gce://<project>/<zone>/<name>
+func parseProviderID(providerID string) (instanceRef, error) {
+	prefix := ProviderName + "://"
+	if !strings.HasPrefix(providerID, prefix) {
+		return instanceRef{}, errorkind.New(errorkind.Validation, "provider id %q is not from the %v cloud provider", providerID, ProviderName)
+	}
+	parts := strings.Split(strings.TrimPrefix(providerID, prefix), "/")
+	if len(parts) != 3 || len(parts[0]) == 0 || len(parts[1]) == 0 || len(parts[2]) == 0 {
+		return instanceRef{}, errorkind.New(errorkind.Validation, "provider id %q is not in the form %v<project>/<zone>/<name>", providerID, prefix)
+	}
+	return instanceRef{Project: parts[0], Zone: parts[1], Name: parts[2]}, nil
+}
+
+// path returns the path of the instance under the compute engine api
+func (r instanceRef) path() string {
+	return fmt.Sprintf("projects/%v/zones/%v/instances/%v", r.Project, r.Zone, r.Name)
+}
+
+// providerID returns the provider id of the node of the instance
+func (r instanceRef) providerID() string {
+	return fmt.Sprintf("%v://%v/%v/%v", ProviderName, r.Project, r.Zone, r.Name)
+}
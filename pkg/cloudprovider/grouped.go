@@ -0,0 +1,271 @@
+package cloudprovider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// GroupedNodeGroup is a node group backed by several node groups of the cloud provider, e.g. an auto scaling group per
+// availability zone or instance type. Its sizes are the sums of theirs. Increases are split between the groups in
+// proportion to their target sizes, and nodes are deleted from the group they belong to
+type GroupedNodeGroup struct {
+	groups []NodeGroup
+}
+
+// NewGroupedNodeGroup combines the node groups into one
+func NewGroupedNodeGroup(groups ...NodeGroup) *GroupedNodeGroup {
+	return &GroupedNodeGroup{groups: groups}
+}
+
+// Groups returns the node groups backing the grouped node group
+func (g *GroupedNodeGroup) Groups() []NodeGroup {
+	return g.groups
+}
+
+// GroupOf returns the node group backing the grouped node group that the node belongs to
+func (g *GroupedNodeGroup) GroupOf(node *v1.Node) (NodeGroup, bool) {
+	for _, group := range g.groups {
+		if group.Belongs(node) {
+			return group, true
+		}
+	}
+	return nil, false
+}
+
+func (g *GroupedNodeGroup) String() string {
+	groups := make([]string, 0, len(g.groups))
+	for _, group := range g.groups {
+		groups = append(groups, group.String())
+	}
+	return fmt.Sprintf("[%v]", strings.Join(groups, ", "))
+}
+
+// ID returns the ids of the node groups, comma separated
+func (g *GroupedNodeGroup) ID() string {
+	ids := make([]string, 0, len(g.groups))
+	for _, group := range g.groups {
+		ids = append(ids, group.ID())
+	}
+	return strings.Join(ids, ",")
+}
+
+// MinSize returns the sum of the minimum sizes of the node groups
+func (g *GroupedNodeGroup) MinSize() int64 {
+	return g.sum(NodeGroup.MinSize)
+}
+
+// MaxSize returns the sum of the maximum sizes of the node groups
+func (g *GroupedNodeGroup) MaxSize() int64 {
+	return g.sum(NodeGroup.MaxSize)
+}
+
+// TargetSize returns the sum of the target sizes of the node groups
+func (g *GroupedNodeGroup) TargetSize() int64 {
+	return g.sum(NodeGroup.TargetSize)
+}
+
+// Size returns the sum of the instances of the node groups
+func (g *GroupedNodeGroup) Size() int64 {
+	return g.sum(NodeGroup.Size)
+}
+
+func (g *GroupedNodeGroup) sum(size func(NodeGroup) int64) int64 {
+	var total int64
+	for _, group := range g.groups {
+		total += size(group)
+	}
+	return total
+}
+
+// IncreaseSize splits the increase between the node groups in proportion to their target sizes
+func (g *GroupedNodeGroup) IncreaseSize(ctx context.Context, delta int64) error {
+	return g.IncreaseSizeWithMetadata(ctx, delta, ScaleUpMetadata{})
+}
+
+// IncreaseSizeWithMetadata splits the increase between the node groups in proportion to their target sizes, and
+// passes the metadata to the node groups that can record it
+func (g *GroupedNodeGroup) IncreaseSizeWithMetadata(ctx context.Context, delta int64, metadata ScaleUpMetadata) error {
+	if delta <= 0 {
+		return fmt.Errorf("size increase must be positive")
+	}
+	if g.TargetSize()+delta > g.MaxSize() {
+		return fmt.Errorf("increasing size will breach maximum node size")
+	}
+
+	for i, increase := range splitIncrease(g.groups, delta) {
+		if increase == 0 {
+			continue
+		}
+		group := g.groups[i]
+		var err error
+		if increaser, ok := group.(MetadataIncreaser); ok {
+			err = increaser.IncreaseSizeWithMetadata(ctx, increase, metadata)
+		} else {
+			err = group.IncreaseSize(ctx, increase)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to increase the size of node group %v by %v: %v", group.ID(), increase, err)
+		}
+	}
+	return nil
+}
+
+// splitIncrease splits the increase between the node groups in proportion to their target sizes, so they keep the
+// same ratios. The units are handed out by smooth weighted round robin, skipping the node groups at their maximum size.
+// When every target size is 0 the increase is split evenly
+func splitIncrease(groups []NodeGroup, delta int64) []int64 {
+	increases := make([]int64, len(groups))
+	weights := make([]int64, len(groups))
+	var totalWeight int64
+	for i, group := range groups {
+		weights[i] = group.TargetSize()
+		totalWeight += weights[i]
+	}
+	if totalWeight == 0 {
+		for i := range weights {
+			weights[i] = 1
+		}
+	}
+
+	current := make([]int64, len(groups))
+	for ; delta > 0; delta-- {
+		chosen := -1
+		var total int64
+		for i, group := range groups {
+			if group.TargetSize()+increases[i] >= group.MaxSize() {
+				continue
+			}
+			current[i] += weights[i]
+			total += weights[i]
+			if chosen < 0 || current[i] > current[chosen] {
+				chosen = i
+			}
+		}
+		if chosen < 0 {
+			break
+		}
+		current[chosen] -= total
+		increases[chosen]++
+	}
+	return increases
+}
+
+// Belongs returns whether the node belongs to any of the node groups
+func (g *GroupedNodeGroup) Belongs(node *v1.Node) bool {
+	_, ok := g.GroupOf(node)
+	return ok
+}
+
+// DeleteNodes deletes each node from the node group it belongs to. Every node is checked before any is deleted. When
+// the deletion from some of the node groups fails, a *NodesNotDeleted says which nodes weren't deleted
+func (g *GroupedNodeGroup) DeleteNodes(ctx context.Context, nodes ...*v1.Node) error {
+	byGroup, err := g.nodesByGroup(nodes)
+	if err != nil {
+		return err
+	}
+	if len(byGroup) == 1 {
+		for i, groupNodes := range byGroup {
+			return g.groups[i].DeleteNodes(ctx, groupNodes...)
+		}
+	}
+
+	notDeleted := &NodesNotDeleted{Failed: make(map[string]error)}
+	for i, group := range g.groups {
+		groupNodes, ok := byGroup[i]
+		if !ok {
+			continue
+		}
+		err := group.DeleteNodes(ctx, groupNodes...)
+		if err == nil {
+			continue
+		}
+		if partial, ok := err.(*NodesNotDeleted); ok {
+			for name, nodeErr := range partial.Failed {
+				notDeleted.Failed[name] = nodeErr
+			}
+			continue
+		}
+		for _, node := range groupNodes {
+			notDeleted.Failed[node.Name] = err
+		}
+	}
+	if len(notDeleted.Failed) > 0 {
+		return notDeleted
+	}
+	return nil
+}
+
+// DryRunDeleteNodes checks the deletion of the nodes from each node group that can check it
+func (g *GroupedNodeGroup) DryRunDeleteNodes(ctx context.Context, nodes ...*v1.Node) error {
+	byGroup, err := g.nodesByGroup(nodes)
+	if err != nil {
+		return err
+	}
+	for i, group := range g.groups {
+		runner, ok := group.(DeletionDryRunner)
+		if !ok || len(byGroup[i]) == 0 {
+			continue
+		}
+		if err := runner.DryRunDeleteNodes(ctx, byGroup[i]...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nodesByGroup splits the nodes by the index of the node group they belong to
+func (g *GroupedNodeGroup) nodesByGroup(nodes []*v1.Node) (map[int][]*v1.Node, error) {
+	byGroup := make(map[int][]*v1.Node)
+	for _, node := range nodes {
+		found := false
+		for i, group := range g.groups {
+			if group.Belongs(node) {
+				byGroup[i] = append(byGroup[i], node)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, &NodeNotInNodeGroup{NodeName: node.Name, ProviderID: node.Spec.ProviderID, NodeGroup: g.ID()}
+		}
+	}
+	return byGroup, nil
+}
+
+// DecreaseTargetSize decreases the target sizes of the node groups that have instances that aren't fulfilled yet,
+// in order, by at most their unfulfilled instances. Delta should be negative
+func (g *GroupedNodeGroup) DecreaseTargetSize(ctx context.Context, delta int64) error {
+	if delta >= 0 {
+		return fmt.Errorf("size decrease must be negative")
+	}
+	remaining := -delta
+	for _, group := range g.groups {
+		unfulfilled := group.TargetSize() - group.Size()
+		if unfulfilled <= 0 {
+			continue
+		}
+		if unfulfilled > remaining {
+			unfulfilled = remaining
+		}
+		if err := group.DecreaseTargetSize(ctx, -unfulfilled); err != nil {
+			return err
+		}
+		remaining -= unfulfilled
+		if remaining == 0 {
+			return nil
+		}
+	}
+	return fmt.Errorf("attempt to delete existing nodes, %v more than the unfulfilled instances", remaining)
+}
+
+// Nodes returns the nodes of every node group
+func (g *GroupedNodeGroup) Nodes() []string {
+	var nodes []string
+	for _, group := range g.groups {
+		nodes = append(nodes, group.Nodes()...)
+	}
+	return nodes
+}
@@ -0,0 +1,186 @@
+package cloudprovider
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// sizedNodeGroup is a node group with sizes that records the changes made to it
+type sizedNodeGroup struct {
+	NodeGroup
+	id                 string
+	min, max           int64
+	target, size       int64
+	nodes              []string
+	deleted            []string
+	deleteErr          error
+	decreasedTargetBy  int64
+	increasedWithLabel string
+}
+
+func (n *sizedNodeGroup) String() string    { return n.id }
+func (n *sizedNodeGroup) ID() string        { return n.id }
+func (n *sizedNodeGroup) MinSize() int64    { return n.min }
+func (n *sizedNodeGroup) MaxSize() int64    { return n.max }
+func (n *sizedNodeGroup) TargetSize() int64 { return n.target }
+func (n *sizedNodeGroup) Size() int64       { return n.size }
+func (n *sizedNodeGroup) Nodes() []string   { return n.nodes }
+func (n *sizedNodeGroup) Belongs(node *v1.Node) bool {
+	for _, name := range n.nodes {
+		if name == node.Name {
+			return true
+		}
+	}
+	return false
+}
+
+func (n *sizedNodeGroup) IncreaseSize(ctx context.Context, delta int64) error {
+	n.target += delta
+	return nil
+}
+
+func (n *sizedNodeGroup) DeleteNodes(ctx context.Context, nodes ...*v1.Node) error {
+	if n.deleteErr != nil {
+		return n.deleteErr
+	}
+	for _, node := range nodes {
+		n.deleted = append(n.deleted, node.Name)
+	}
+	return nil
+}
+
+func (n *sizedNodeGroup) DecreaseTargetSize(ctx context.Context, delta int64) error {
+	n.target += delta
+	n.decreasedTargetBy -= delta
+	return nil
+}
+
+// metadataNodeGroup is a sizedNodeGroup that records the node group of the scale up metadata
+type metadataNodeGroup struct {
+	*sizedNodeGroup
+}
+
+func (n metadataNodeGroup) IncreaseSizeWithMetadata(ctx context.Context, delta int64, metadata ScaleUpMetadata) error {
+	n.increasedWithLabel = metadata.NodeGroup
+	return n.IncreaseSize(ctx, delta)
+}
+
+func groupNode(name string) *v1.Node {
+	return &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}}
+}
+
+func TestGroupedNodeGroup_Sizes(t *testing.T) {
+	grouped := NewGroupedNodeGroup(
+		&sizedNodeGroup{id: "a", min: 1, max: 10, target: 4, size: 3},
+		&sizedNodeGroup{id: "b", min: 0, max: 5, target: 2, size: 2},
+	)
+	assert.Equal(t, "a,b", grouped.ID())
+	assert.Equal(t, int64(1), grouped.MinSize())
+	assert.Equal(t, int64(15), grouped.MaxSize())
+	assert.Equal(t, int64(6), grouped.TargetSize())
+	assert.Equal(t, int64(5), grouped.Size())
+}
+
+func TestGroupedNodeGroup_IncreaseSize(t *testing.T) {
+	targets := func(groups ...*sizedNodeGroup) []int64 {
+		var sizes []int64
+		for _, group := range groups {
+			sizes = append(sizes, group.target)
+		}
+		return sizes
+	}
+
+	t.Run("in proportion to the target sizes", func(t *testing.T) {
+		a := &sizedNodeGroup{id: "a", max: 100, target: 6}
+		b := &sizedNodeGroup{id: "b", max: 100, target: 3}
+		c := &sizedNodeGroup{id: "c", max: 100}
+		require.NoError(t, NewGroupedNodeGroup(a, b, c).IncreaseSize(context.Background(), 9))
+		assert.Equal(t, []int64{12, 6, 0}, targets(a, b, c))
+	})
+
+	t.Run("evenly from 0", func(t *testing.T) {
+		a := &sizedNodeGroup{id: "a", max: 100}
+		b := &sizedNodeGroup{id: "b", max: 100}
+		require.NoError(t, NewGroupedNodeGroup(a, b).IncreaseSize(context.Background(), 3))
+		assert.Equal(t, []int64{2, 1}, targets(a, b))
+	})
+
+	t.Run("groups at their maximum size are skipped", func(t *testing.T) {
+		a := &sizedNodeGroup{id: "a", max: 5, target: 4}
+		b := &sizedNodeGroup{id: "b", max: 100, target: 4}
+		require.NoError(t, NewGroupedNodeGroup(a, b).IncreaseSize(context.Background(), 4))
+		assert.Equal(t, []int64{5, 7}, targets(a, b))
+	})
+
+	t.Run("over the maximum size", func(t *testing.T) {
+		a := &sizedNodeGroup{id: "a", max: 5, target: 4}
+		b := &sizedNodeGroup{id: "b", max: 5, target: 4}
+		assert.Error(t, NewGroupedNodeGroup(a, b).IncreaseSize(context.Background(), 3))
+		assert.Equal(t, []int64{4, 4}, targets(a, b))
+	})
+
+	t.Run("metadata", func(t *testing.T) {
+		a := metadataNodeGroup{&sizedNodeGroup{id: "a", max: 100, target: 1}}
+		b := &sizedNodeGroup{id: "b", max: 100, target: 1}
+		require.NoError(t, NewGroupedNodeGroup(a, b).IncreaseSizeWithMetadata(context.Background(), 2, ScaleUpMetadata{NodeGroup: "shared"}))
+		assert.Equal(t, "shared", a.increasedWithLabel)
+		assert.Equal(t, []int64{2, 2}, targets(a.sizedNodeGroup, b))
+	})
+}
+
+func TestGroupedNodeGroup_DeleteNodes(t *testing.T) {
+	t.Run("from the group of each node", func(t *testing.T) {
+		a := &sizedNodeGroup{id: "a", nodes: []string{"a-1", "a-2"}}
+		b := &sizedNodeGroup{id: "b", nodes: []string{"b-1"}}
+		grouped := NewGroupedNodeGroup(a, b)
+		assert.True(t, grouped.Belongs(groupNode("b-1")))
+		assert.Equal(t, []string{"a-1", "a-2", "b-1"}, grouped.Nodes())
+
+		require.NoError(t, grouped.DeleteNodes(context.Background(), groupNode("a-2"), groupNode("b-1")))
+		assert.Equal(t, []string{"a-2"}, a.deleted)
+		assert.Equal(t, []string{"b-1"}, b.deleted)
+	})
+
+	t.Run("a node of another group deletes nothing", func(t *testing.T) {
+		a := &sizedNodeGroup{id: "a", nodes: []string{"a-1"}}
+		err := NewGroupedNodeGroup(a).DeleteNodes(context.Background(), groupNode("a-1"), groupNode("other"))
+		assert.IsType(t, &NodeNotInNodeGroup{}, err)
+		assert.Empty(t, a.deleted)
+	})
+
+	t.Run("a failed group", func(t *testing.T) {
+		a := &sizedNodeGroup{id: "a", nodes: []string{"a-1"}, deleteErr: errors.New("throttled")}
+		b := &sizedNodeGroup{id: "b", nodes: []string{"b-1"}}
+		err := NewGroupedNodeGroup(a, b).DeleteNodes(context.Background(), groupNode("a-1"), groupNode("b-1"))
+		require.IsType(t, &NodesNotDeleted{}, err)
+		assert.Equal(t, map[string]error{"a-1": a.deleteErr}, err.(*NodesNotDeleted).Failed)
+		assert.Equal(t, []string{"b-1"}, b.deleted)
+	})
+
+	t.Run("a single group returns its error", func(t *testing.T) {
+		a := &sizedNodeGroup{id: "a", nodes: []string{"a-1"}, deleteErr: errors.New("throttled")}
+		b := &sizedNodeGroup{id: "b"}
+		err := NewGroupedNodeGroup(a, b).DeleteNodes(context.Background(), groupNode("a-1"))
+		assert.Equal(t, a.deleteErr, err)
+	})
+}
+
+func TestGroupedNodeGroup_DecreaseTargetSize(t *testing.T) {
+	a := &sizedNodeGroup{id: "a", target: 3, size: 3}
+	b := &sizedNodeGroup{id: "b", target: 5, size: 3}
+	c := &sizedNodeGroup{id: "c", target: 2, size: 1}
+	grouped := NewGroupedNodeGroup(a, b, c)
+
+	require.NoError(t, grouped.DecreaseTargetSize(context.Background(), -3))
+	assert.Equal(t, int64(0), a.decreasedTargetBy)
+	assert.Equal(t, int64(2), b.decreasedTargetBy)
+	assert.Equal(t, int64(1), c.decreasedTargetBy)
+
+	assert.Error(t, grouped.DecreaseTargetSize(context.Background(), -1))
+}
@@ -1,6 +1,7 @@
 package cloudprovider
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -8,7 +9,8 @@ import (
 )
 
 // CloudProvider contains configuration info and functions for interacting with
-// cloud provider (GCE, AWS, etc).
+// cloud provider (GCE, AWS, etc). The functions that call the cloud provider api
+// take a context that bounds the calls, e.g. to the deadline of the current run.
 type CloudProvider interface {
 	// Name returns name of the cloud provider.
 	Name() string
@@ -20,13 +22,13 @@ type CloudProvider interface {
 	GetNodeGroup(string) (NodeGroup, bool)
 
 	// RegisterNodeGroup adds the nodegroup to the list of nodes groups
-	RegisterNodeGroups(...NodeGroupConfig) error
+	RegisterNodeGroups(ctx context.Context, configs ...NodeGroupConfig) error
 
 	// Refresh is called before every main loop and can be used to dynamically update cloud provider state.
 	// In particular the list of node groups returned by NodeGroups can change as a result of CloudProvider.Refresh().
-	Refresh() error
+	Refresh(ctx context.Context) error
 
-	GetInstance(node *v1.Node) (Instance, error)
+	GetInstance(ctx context.Context, node *v1.Node) (Instance, error)
 }
 
 // Instance contains convenience functions for extracting common information from CP instances
@@ -65,27 +67,127 @@ type NodeGroup interface {
 	// IncreaseSize increases the size of the node group. To delete a node you need
 	// to explicitly name it and use DeleteNode. This function should wait until
 	// node group size is updated.
-	IncreaseSize(delta int64) error
+	IncreaseSize(ctx context.Context, delta int64) error
 
 	// Belongs determines if the node belongs in the current node group
 	Belongs(*v1.Node) bool
 
 	// DeleteNodes deletes nodes from this node group. Error is returned either on
 	// failure or if the given node doesn't belong to this node group. This function
-	// should wait until node group size is updated.
-	DeleteNodes(...*v1.Node) error
+	// should wait until node group size is updated. When only some of the nodes
+	// failed to be deleted, a *NodesNotDeleted says which.
+	DeleteNodes(ctx context.Context, nodes ...*v1.Node) error
 
 	// DecreaseTargetSize decreases the target size of the node group. This function
 	// doesn't permit to delete any existing node and can be used only to reduce the
 	// request for new nodes that have not been yet fulfilled. Delta should be negative.
 	// It is assumed that cloud provider will not delete the existing nodes when there
 	// is an option to just decrease the target.
-	DecreaseTargetSize(delta int64) error
+	DecreaseTargetSize(ctx context.Context, delta int64) error
 
 	// Nodes returns a list of all nodes that belong to this node group.
 	Nodes() []string
 }
 
+// PermissionChecker is optionally implemented by cloud providers that can verify they have the permissions
+// needed to scale their node groups without making any changes
+type PermissionChecker interface {
+	// CheckPermissions returns an error for each permission the cloud provider doesn't have
+	CheckPermissions(ctx context.Context) []error
+}
+
+// DeletionDryRunner is optionally implemented by node groups that can check a deletion of nodes would succeed,
+// including the permissions needed, without deleting anything
+type DeletionDryRunner interface {
+	// DryRunDeleteNodes returns an error if deleting the nodes from the node group would fail
+	DryRunDeleteNodes(ctx context.Context, nodes ...*v1.Node) error
+}
+
+// MetadataIncreaser is optionally implemented by node groups that can record the metadata of a scale up on the
+// instances it creates, e.g. as instance tags
+type MetadataIncreaser interface {
+	// IncreaseSizeWithMetadata increases the size of the node group the same as IncreaseSize
+	IncreaseSizeWithMetadata(ctx context.Context, delta int64, metadata ScaleUpMetadata) error
+}
+
+// ScaleUpMetadataReader is optionally implemented by instances that can return the metadata of the scale up that
+// created them
+type ScaleUpMetadataReader interface {
+	// ScaleUpMetadata returns the metadata of the scale up and whether the instance has any
+	ScaleUpMetadata() (ScaleUpMetadata, bool)
+}
+
+// InstanceTagger is optionally implemented by node groups that can tag the instances of their nodes, e.g. so tooling
+// without access to Kubernetes can see which instances are going to be removed
+type InstanceTagger interface {
+	// TagInstances sets the tags on the instances of the nodes
+	TagInstances(ctx context.Context, tags map[string]string, nodes ...*v1.Node) error
+
+	// UntagInstances removes the tags with the keys from the instances of the nodes
+	UntagInstances(ctx context.Context, keys []string, nodes ...*v1.Node) error
+}
+
+// QuotaChecker is optionally implemented by node groups that can check a scale up against the quotas of the cloud
+// provider account, e.g. the vCPU limit of EC2
+type QuotaChecker interface {
+	// RemainingQuota returns how many more nodes of the node group fit in the quota. ok is false when there isn't a
+	// quota to check, e.g. it isn't configured or the size of the nodes isn't known yet
+	RemainingQuota(ctx context.Context) (quota Quota, ok bool, err error)
+}
+
+// ScalingActivityReader is optionally implemented by node groups that can list the activities the cloud provider ran
+// to carry out their scaling, e.g. the scaling activities of an auto scaling group
+type ScalingActivityReader interface {
+	// ScalingActivities returns the latest scaling activities of the node group that started at or after since,
+	// newest first
+	ScalingActivities(ctx context.Context, since time.Time) ([]ScalingActivity, error)
+}
+
+// ScalingActivity is an activity the cloud provider ran to scale a node group, e.g. launching an instance
+type ScalingActivity struct {
+	ID          string
+	Description string
+	// StatusMessage explains why the activity failed, e.g. there wasn't enough capacity for the instance type
+	StatusMessage string
+	StartTime     time.Time
+	// Done is whether the activity finished, and Failed whether it finished without doing what it set out to
+	Done   bool
+	Failed bool
+}
+
+// MaintenanceEventReader is optionally implemented by node groups that can list the maintenance the cloud provider
+// scheduled for the instances of their nodes, e.g. the scheduled events of EC2 instances
+type MaintenanceEventReader interface {
+	// MaintenanceEvents returns the maintenance scheduled for the instances of the nodes that hasn't completed or been
+	// cancelled. Nodes without scheduled maintenance are left out
+	MaintenanceEvents(ctx context.Context, nodes ...*v1.Node) ([]MaintenanceEvent, error)
+}
+
+// MaintenanceEvent is disruptive maintenance the cloud provider scheduled for the instance of a node, e.g. a reboot
+// for host maintenance or the retirement of the instance
+type MaintenanceEvent struct {
+	// NodeName is the node whose instance the maintenance is scheduled for
+	NodeName string
+	// Code is the kind of maintenance, e.g. instance-retirement
+	Code        string
+	Description string
+	// NotBefore is the earliest time the cloud provider starts the maintenance
+	NotBefore time.Time
+}
+
+// Quota is the room left in a cloud provider quota for the nodes of a node group
+type Quota struct {
+	// Name of the quota, for logs and errors
+	Name string
+	// RemainingNodes is how many more nodes of the node group fit in the quota
+	RemainingNodes int64
+	// Remaining is the room left in the quota and PerNode how much of it a node of the node group uses, in the unit
+	// of the quota, e.g. vCPUs. They compare the room node groups with different node sizes need in a shared quota.
+	// A PerNode of 0 means the quota is counted in nodes
+	Remaining int64
+	PerNode   int64
+}
+
 // Builder interface provides a method to build a cloud provider
 type Builder interface {
 	Build() (CloudProvider, error)
@@ -93,14 +195,21 @@ type Builder interface {
 
 // BuildOpts providers all options to create your cloud provider
 type BuildOpts struct {
+	// ProviderID is the cloud provider to build, and the default of the node groups without a ProviderID when the
+	// cloud providers are built from a Registry
 	ProviderID       string
 	NodeGroupConfigs []NodeGroupConfig
 }
 
 // NodeGroupConfig contains the configuration for a node group
 type NodeGroupConfig struct {
-	GroupID   string
-	AWSConfig AWSNodeGroupConfig
+	GroupID string
+	// ProviderID is the cloud provider of the node group when the cloud providers are built from a Registry. Empty
+	// means the ProviderID of the BuildOpts
+	ProviderID  string
+	AWSConfig   AWSNodeGroupConfig
+	GCEConfig   GCENodeGroupConfig
+	AzureConfig AzureNodeGroupConfig
 }
 
 // AWSNodeGroupConfig contains the AWS cloud provider specific configuration
@@ -109,4 +218,8 @@ type AWSNodeGroupConfig struct {
 	LaunchTemplateID          string
 	LaunchTemplateVersion     string
 	FleetInstanceReadyTimeout time.Duration
+	// EKSClusterName and EKSNodegroupName are the EKS managed node group backed by the auto scaling group, whose
+	// size is changed through the EKS api. Empty for self managed node groups
+	EKSClusterName   string
+	EKSNodegroupName string
 }
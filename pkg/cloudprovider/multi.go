@@ -0,0 +1,120 @@
+package cloudprovider
+
+import (
+	"context"
+	"strings"
+
+	"github.com/atlassian/escalator/pkg/errorkind"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+)
+
+// MultiCloudProvider combines the cloud providers of node groups that don't all use the same one, e.g. auto scaling
+// groups next to on-prem node groups. Each node group is routed to the cloud provider it was registered with
+type MultiCloudProvider struct {
+	// names is the names of the cloud providers, in the order their first node group was configured
+	names     []string
+	providers map[string]CloudProvider
+	// groupProviders is the name of the cloud provider of each node group, by group id
+	groupProviders map[string]string
+	// defaultName is the cloud provider of the node groups without a ProviderID
+	defaultName string
+}
+
+// Name returns the names of the cloud providers, comma separated
+func (m *MultiCloudProvider) Name() string {
+	return strings.Join(m.names, ",")
+}
+
+// NodeGroups returns the node groups of every cloud provider
+func (m *MultiCloudProvider) NodeGroups() []NodeGroup {
+	var ngs []NodeGroup
+	for _, name := range m.names {
+		ngs = append(ngs, m.providers[name].NodeGroups()...)
+	}
+	return ngs
+}
+
+// GetNodeGroup gets the node group from the cloud provider it was registered with. Returns if it exists or not
+func (m *MultiCloudProvider) GetNodeGroup(id string) (NodeGroup, bool) {
+	name, ok := m.groupProviders[id]
+	if !ok {
+		return nil, false
+	}
+	return m.providers[name].GetNodeGroup(id)
+}
+
+// RegisterNodeGroups registers each node group with the cloud provider of its ProviderID. The cloud providers have to
+// have been built, so node groups can't be moved to a new cloud provider without building again
+func (m *MultiCloudProvider) RegisterNodeGroups(ctx context.Context, groups ...NodeGroupConfig) error {
+	configs := make(map[string][]NodeGroupConfig)
+	for _, group := range groups {
+		name := group.ProviderID
+		if len(name) == 0 {
+			name = m.defaultName
+		}
+		if _, ok := m.providers[name]; !ok {
+			return errorkind.New(errorkind.Validation, "node group %v uses cloud provider %v, which hasn't been built", group.GroupID, name)
+		}
+		if other, ok := m.groupProviders[group.GroupID]; ok && other != name {
+			return errorkind.New(errorkind.Validation, "node group %v is registered with both cloud provider %v and %v", group.GroupID, other, name)
+		}
+		configs[name] = append(configs[name], group)
+	}
+
+	for _, name := range m.names {
+		if len(configs[name]) == 0 {
+			continue
+		}
+		if err := m.providers[name].RegisterNodeGroups(ctx, configs[name]...); err != nil {
+			return err
+		}
+		for _, config := range configs[name] {
+			m.groupProviders[config.GroupID] = name
+		}
+	}
+	return nil
+}
+
+// Refresh refreshes every cloud provider, stopping at the first that fails
+func (m *MultiCloudProvider) Refresh(ctx context.Context) error {
+	for _, name := range m.names {
+		if err := m.providers[name].Refresh(ctx); err != nil {
+			return errors.Wrapf(err, "failed to refresh cloud provider %v", name)
+		}
+	}
+	return nil
+}
+
+// GetInstance gets the instance of the node from the cloud provider of the scheme of its provider id, e.g. aws, or
+// otherwise the cloud provider with a node group the node belongs to
+func (m *MultiCloudProvider) GetInstance(ctx context.Context, node *v1.Node) (Instance, error) {
+	if providerID, err := NodeProviderID(node); err == nil {
+		if provider, ok := m.providers[providerID.Provider]; ok {
+			return provider.GetInstance(ctx, node)
+		}
+	}
+	for _, name := range m.names {
+		for _, nodeGroup := range m.providers[name].NodeGroups() {
+			if nodeGroup.Belongs(node) {
+				return m.providers[name].GetInstance(ctx, node)
+			}
+		}
+	}
+	return nil, errorkind.New(errorkind.NotFound, "node %v doesn't belong to a node group of cloud providers %v", node.Name, m.Name())
+}
+
+// CheckPermissions checks the permissions of each cloud provider that can check them
+func (m *MultiCloudProvider) CheckPermissions(ctx context.Context) []error {
+	var errs []error
+	for _, name := range m.names {
+		checker, ok := m.providers[name].(PermissionChecker)
+		if !ok {
+			continue
+		}
+		for _, err := range checker.CheckPermissions(ctx) {
+			errs = append(errs, errors.Wrapf(err, "cloud provider %v", name))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,43 @@
+package cloudprovider
+
+import (
+	"strings"
+
+	"github.com/atlassian/escalator/pkg/errorkind"
+	v1 "k8s.io/api/core/v1"
+)
+
+// ProviderID identifies the cloud instance backing a node, parsed from the node's spec.providerID in the form
+// <provider>://<path>, e.g. aws:///us-east-1a/i-0123456789abcdef0
+type ProviderID struct {
+	// Provider is the scheme of the provider id, e.g. aws
+	Provider string
+	// InstanceID is the last segment of the path, which identifies the instance within the provider
+	InstanceID string
+}
+
+// ParseProviderID parses the provider id. Empty path segments are ignored, so the different forms written by
+// kubelets and cloud controller managers, e.g. aws:///us-east-1a/i-123 and aws://i-123, identify the same instance
+func ParseProviderID(providerID string) (ProviderID, error) {
+	parts := strings.SplitN(providerID, "://", 2)
+	if len(parts) != 2 || len(parts[0]) == 0 {
+		return ProviderID{}, errorkind.New(errorkind.Validation, "provider id %q is not in the form <provider>://<path>", providerID)
+	}
+
+	segments := strings.Split(parts[1], "/")
+	for i := len(segments) - 1; i >= 0; i-- {
+		if len(segments[i]) > 0 {
+			return ProviderID{Provider: parts[0], InstanceID: segments[i]}, nil
+		}
+	}
+	return ProviderID{}, errorkind.New(errorkind.Validation, "provider id %q has no instance id", providerID)
+}
+
+// NodeProviderID parses the provider id of the node. Nodes that haven't been initialised by the cloud provider yet
+// don't have one
+func NodeProviderID(node *v1.Node) (ProviderID, error) {
+	if len(node.Spec.ProviderID) == 0 {
+		return ProviderID{}, errorkind.New(errorkind.Validation, "node %v has no provider id", node.Name)
+	}
+	return ParseProviderID(node.Spec.ProviderID)
+}
@@ -0,0 +1,51 @@
+package cloudprovider
+
+import (
+	"testing"
+
+	"github.com/atlassian/escalator/pkg/errorkind"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParseProviderID(t *testing.T) {
+	tests := []struct {
+		name       string
+		providerID string
+		want       ProviderID
+		wantErr    bool
+	}{
+		{"aws", "aws:///us-east-1a/i-123", ProviderID{"aws", "i-123"}, false},
+		{"aws without zone", "aws:///i-123", ProviderID{"aws", "i-123"}, false},
+		{"aws with host", "aws://us-east-1a/i-123", ProviderID{"aws", "i-123"}, false},
+		{"trailing slash", "aws:///us-east-1a/i-123/", ProviderID{"aws", "i-123"}, false},
+		{"gce", "gce://project/us-central1-a/instance-1", ProviderID{"gce", "instance-1"}, false},
+		{"azure", "azure:///subscriptions/sub/resourceGroups/rg/providers/Microsoft.Compute/virtualMachineScaleSets/vmss/virtualMachines/3", ProviderID{"azure", "3"}, false},
+		{"empty", "", ProviderID{}, true},
+		{"no scheme", "i-123", ProviderID{}, true},
+		{"no instance", "aws:///", ProviderID{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseProviderID(tt.providerID)
+			assert.Equal(t, tt.want, got)
+			if tt.wantErr {
+				assert.Equal(t, errorkind.Validation, errorkind.Of(err))
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestNodeProviderID(t *testing.T) {
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}}
+	_, err := NodeProviderID(node)
+	assert.EqualError(t, err, "node node-1 has no provider id")
+
+	node.Spec.ProviderID = "aws:///us-east-1a/i-123"
+	got, err := NodeProviderID(node)
+	assert.NoError(t, err)
+	assert.Equal(t, ProviderID{"aws", "i-123"}, got)
+}
@@ -0,0 +1,85 @@
+package cloudprovider
+
+import (
+	"sort"
+
+	"github.com/atlassian/escalator/pkg/errorkind"
+)
+
+// BuilderFunc returns the builder of a cloud provider for the build opts
+type BuilderFunc func(opts BuildOpts) Builder
+
+// Registry is the cloud providers that can be built, by the name node groups select them with
+type Registry map[string]BuilderFunc
+
+// Names returns the names of the registered cloud providers, sorted
+func (r Registry) Names() []string {
+	names := make([]string, 0, len(r))
+	for name := range r {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Builder returns a builder of the cloud providers of the node groups in the opts. Each node group is registered with
+// the cloud provider of its ProviderID, or of the ProviderID of the opts if it has none
+func (r Registry) Builder(opts BuildOpts) Builder {
+	return registryBuilder{registry: r, opts: opts}
+}
+
+// registryBuilder builds the cloud providers of the node groups from a registry
+type registryBuilder struct {
+	registry Registry
+	opts     BuildOpts
+}
+
+// Build builds each cloud provider with its node groups. When every node group uses the same cloud provider it is
+// returned on its own, otherwise they are combined in a MultiCloudProvider
+func (b registryBuilder) Build() (CloudProvider, error) {
+	var names []string
+	configs := make(map[string][]NodeGroupConfig)
+	groupProviders := make(map[string]string, len(b.opts.NodeGroupConfigs))
+	for _, config := range b.opts.NodeGroupConfigs {
+		name := config.ProviderID
+		if len(name) == 0 {
+			name = b.opts.ProviderID
+		}
+		if other, ok := groupProviders[config.GroupID]; ok && other != name {
+			return nil, errorkind.New(errorkind.Validation, "node group %v is registered with both cloud provider %v and %v", config.GroupID, other, name)
+		}
+		groupProviders[config.GroupID] = name
+
+		if _, ok := configs[name]; !ok {
+			names = append(names, name)
+		}
+		configs[name] = append(configs[name], config)
+	}
+	// without node groups the default cloud provider is built on its own, as before any node group is registered
+	if len(names) == 0 {
+		names = append(names, b.opts.ProviderID)
+	}
+
+	providers := make(map[string]CloudProvider, len(names))
+	for _, name := range names {
+		builderFunc, ok := b.registry[name]
+		if !ok {
+			return nil, errorkind.New(errorkind.Validation, "provider %v does not exist. Available options: %v", name, b.registry.Names())
+		}
+		provider, err := builderFunc(BuildOpts{ProviderID: name, NodeGroupConfigs: configs[name]}).Build()
+		if err != nil {
+			return nil, err
+		}
+		providers[name] = provider
+	}
+
+	if len(names) == 1 {
+		return providers[names[0]], nil
+	}
+	return &MultiCloudProvider{
+		names:          names,
+		providers:      providers,
+		groupProviders: groupProviders,
+		defaultName:    b.opts.ProviderID,
+	}, nil
+}
@@ -0,0 +1,190 @@
+package cloudprovider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/atlassian/escalator/pkg/errorkind"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// fakeCloudProvider is a cloud provider whose node groups own the nodes with the provider id <name>:///<group id>/<n>
+type fakeCloudProvider struct {
+	name       string
+	nodeGroups map[string]*fakeNodeGroup
+	refreshErr error
+	ctx        context.Context
+}
+
+func (c *fakeCloudProvider) Name() string { return c.name }
+
+func (c *fakeCloudProvider) NodeGroups() []NodeGroup {
+	var ngs []NodeGroup
+	for _, ng := range c.nodeGroups {
+		ngs = append(ngs, ng)
+	}
+	return ngs
+}
+
+func (c *fakeCloudProvider) GetNodeGroup(id string) (NodeGroup, bool) {
+	ng, ok := c.nodeGroups[id]
+	return ng, ok
+}
+
+func (c *fakeCloudProvider) RegisterNodeGroups(ctx context.Context, groups ...NodeGroupConfig) error {
+	for _, group := range groups {
+		c.nodeGroups[group.GroupID] = &fakeNodeGroup{id: group.GroupID, provider: c.name}
+	}
+	return nil
+}
+
+func (c *fakeCloudProvider) Refresh(ctx context.Context) error {
+	c.ctx = ctx
+	return c.refreshErr
+}
+
+func (c *fakeCloudProvider) GetInstance(ctx context.Context, node *v1.Node) (Instance, error) {
+	return fakeInstance{id: c.name + "/" + node.Name}, nil
+}
+
+func (c *fakeCloudProvider) CheckPermissions(ctx context.Context) []error {
+	return []error{fmt.Errorf("missing permission")}
+}
+
+type fakeInstance struct{ id string }
+
+func (i fakeInstance) InstantiationTime() time.Time { return time.Time{} }
+func (i fakeInstance) ID() string                   { return i.id }
+
+type fakeNodeGroup struct {
+	NodeGroup
+	id       string
+	provider string
+}
+
+func (n *fakeNodeGroup) ID() string { return n.id }
+
+func (n *fakeNodeGroup) Belongs(node *v1.Node) bool {
+	providerID, err := NodeProviderID(node)
+	return err == nil && providerID.Provider == n.provider
+}
+
+// fakeRegistry builds a fakeCloudProvider for each name, recording the built providers
+func fakeRegistry(built map[string]*fakeCloudProvider, names ...string) Registry {
+	registry := make(Registry, len(names))
+	for _, name := range names {
+		name := name
+		registry[name] = func(opts BuildOpts) Builder {
+			return fakeBuilder(func() (CloudProvider, error) {
+				provider := &fakeCloudProvider{name: name, nodeGroups: make(map[string]*fakeNodeGroup)}
+				built[name] = provider
+				return provider, provider.RegisterNodeGroups(context.Background(), opts.NodeGroupConfigs...)
+			})
+		}
+	}
+	return registry
+}
+
+type fakeBuilder func() (CloudProvider, error)
+
+func (b fakeBuilder) Build() (CloudProvider, error) { return b() }
+
+func buildNode(name string, providerID string) *v1.Node {
+	return &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}, Spec: v1.NodeSpec{ProviderID: providerID}}
+}
+
+func TestRegistry_BuildSingleProvider(t *testing.T) {
+	built := make(map[string]*fakeCloudProvider)
+	registry := fakeRegistry(built, "aws", "onprem")
+	assert.Equal(t, []string{"aws", "onprem"}, registry.Names())
+
+	// node groups without a provider, or with the default one, are built as the default provider on its own
+	cloud, err := registry.Builder(BuildOpts{
+		ProviderID:       "aws",
+		NodeGroupConfigs: []NodeGroupConfig{{GroupID: "a"}, {GroupID: "b", ProviderID: "aws"}},
+	}).Build()
+	require.NoError(t, err)
+	assert.Equal(t, built["aws"], cloud)
+	assert.Len(t, cloud.NodeGroups(), 2)
+	assert.NotContains(t, built, "onprem")
+
+	// without node groups the default provider is still built
+	built = make(map[string]*fakeCloudProvider)
+	cloud, err = fakeRegistry(built, "aws").Builder(BuildOpts{ProviderID: "aws"}).Build()
+	require.NoError(t, err)
+	assert.Equal(t, "aws", cloud.Name())
+}
+
+func TestRegistry_BuildMultipleProviders(t *testing.T) {
+	built := make(map[string]*fakeCloudProvider)
+	cloud, err := fakeRegistry(built, "aws", "onprem").Builder(BuildOpts{
+		ProviderID: "aws",
+		NodeGroupConfigs: []NodeGroupConfig{
+			{GroupID: "asg-1"},
+			{GroupID: "rack-1", ProviderID: "onprem"},
+			{GroupID: "asg-2"},
+		},
+	}).Build()
+	require.NoError(t, err)
+	require.IsType(t, &MultiCloudProvider{}, cloud)
+	assert.Equal(t, "aws,onprem", cloud.Name())
+	assert.Len(t, cloud.NodeGroups(), 3)
+	assert.Len(t, built["aws"].nodeGroups, 2)
+	assert.Len(t, built["onprem"].nodeGroups, 1)
+
+	// node groups are routed to their provider
+	nodeGroup, ok := cloud.GetNodeGroup("rack-1")
+	require.True(t, ok)
+	assert.Equal(t, built["onprem"].nodeGroups["rack-1"], nodeGroup)
+	_, ok = cloud.GetNodeGroup("missing")
+	assert.False(t, ok)
+
+	// instances are got from the provider of the provider id
+	instance, err := cloud.GetInstance(context.Background(), buildNode("node-1", "onprem:///rack-1/1"))
+	require.NoError(t, err)
+	assert.Equal(t, "onprem/node-1", instance.ID())
+	_, err = cloud.GetInstance(context.Background(), buildNode("node-2", "kind://node-2"))
+	assert.Equal(t, errorkind.NotFound, errorkind.Of(err))
+
+	// new node groups are registered with their provider
+	require.NoError(t, cloud.RegisterNodeGroups(context.Background(), NodeGroupConfig{GroupID: "rack-2", ProviderID: "onprem"}))
+	assert.Len(t, built["onprem"].nodeGroups, 2)
+	err = cloud.RegisterNodeGroups(context.Background(), NodeGroupConfig{GroupID: "rack-3", ProviderID: "gce"})
+	assert.Equal(t, errorkind.Validation, errorkind.Of(err))
+
+	// the optional interfaces reach every provider
+	assert.Len(t, cloud.(PermissionChecker).CheckPermissions(context.Background()), 2)
+
+	// the context of a call is passed to every provider
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.NoError(t, cloud.Refresh(ctx))
+	assert.Equal(t, ctx, built["aws"].ctx)
+	assert.Equal(t, ctx, built["onprem"].ctx)
+
+	built["onprem"].refreshErr = errorkind.New(errorkind.Throttled, "slow down")
+	err = cloud.Refresh(context.Background())
+	assert.Equal(t, errorkind.Throttled, errorkind.Of(err))
+}
+
+func TestRegistry_BuildInvalid(t *testing.T) {
+	registry := fakeRegistry(make(map[string]*fakeCloudProvider), "aws", "onprem")
+
+	_, err := registry.Builder(BuildOpts{ProviderID: "aws", NodeGroupConfigs: []NodeGroupConfig{{GroupID: "a", ProviderID: "missing"}}}).Build()
+	assert.Equal(t, errorkind.Validation, errorkind.Of(err))
+
+	_, err = registry.Builder(BuildOpts{ProviderID: "aws", NodeGroupConfigs: []NodeGroupConfig{{GroupID: "a"}, {GroupID: "a", ProviderID: "onprem"}}}).Build()
+	assert.Equal(t, errorkind.Validation, errorkind.Of(err))
+
+	registry["broken"] = func(opts BuildOpts) Builder {
+		return fakeBuilder(func() (CloudProvider, error) { return nil, errors.New("no credentials") })
+	}
+	_, err = registry.Builder(BuildOpts{ProviderID: "aws", NodeGroupConfigs: []NodeGroupConfig{{GroupID: "a"}, {GroupID: "b", ProviderID: "broken"}}}).Build()
+	assert.EqualError(t, err, "no credentials")
+}
@@ -1,6 +1,12 @@
 package cloudprovider
 
-import "fmt"
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/atlassian/escalator/pkg/errorkind"
+)
 
 // NodeNotInNodeGroup is a special error type
 // this happens when a node is not inside a expected node group
@@ -13,3 +19,88 @@ type NodeNotInNodeGroup struct {
 func (ne *NodeNotInNodeGroup) Error() string {
 	return fmt.Sprintf("node %v, %v belongs in a different node group than %v", ne.NodeName, ne.ProviderID, ne.NodeGroup)
 }
+
+// ErrorKind is not found, as the node wasn't found in the node group
+func (ne *NodeNotInNodeGroup) ErrorKind() errorkind.Kind {
+	return errorkind.NotFound
+}
+
+// NodesNotDeleted is returned by DeleteNodes when some of the nodes failed to be deleted. The nodes that aren't in
+// Failed were deleted
+type NodesNotDeleted struct {
+	// Failed is the error deleting each node that wasn't deleted, by node name
+	Failed map[string]error
+}
+
+func (ne *NodesNotDeleted) Error() string {
+	names := make([]string, 0, len(ne.Failed))
+	for name := range ne.Failed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	failures := make([]string, 0, len(names))
+	for _, name := range names {
+		failures = append(failures, fmt.Sprintf("%v: %v", name, ne.Failed[name]))
+	}
+	return fmt.Sprintf("failed to delete %v nodes: %v", len(names), strings.Join(failures, "; "))
+}
+
+const (
+	// ScaleUpNodeGroupTagKey is the instance tag recording the node group that requested the instance
+	ScaleUpNodeGroupTagKey = "atlassian.com/escalator-scale-up-node-group"
+	// ScaleUpCycleTagKey is the instance tag recording the id of the run that requested the instance
+	ScaleUpCycleTagKey = "atlassian.com/escalator-scale-up-cycle"
+	// ScaleUpReasonTagKey is the instance tag recording why the instance was requested
+	ScaleUpReasonTagKey = "atlassian.com/escalator-scale-up-reason"
+	// ToBeRemovedTagKey is the instance tag recording when the node of the instance was tainted for removal
+	ToBeRemovedTagKey = "atlassian.com/escalator-to-be-removed"
+)
+
+// ScaleUpMetadata describes the decision that caused a scale up, so new instances can be traced back to it
+type ScaleUpMetadata struct {
+	NodeGroup string
+	CycleID   string
+	Reason    string
+}
+
+// Tags returns the metadata as instance tags. Empty values are left out
+func (m ScaleUpMetadata) Tags() map[string]string {
+	tags := make(map[string]string, 3)
+	for key, value := range map[string]string{
+		ScaleUpNodeGroupTagKey: m.NodeGroup,
+		ScaleUpCycleTagKey:     m.CycleID,
+		ScaleUpReasonTagKey:    m.Reason,
+	} {
+		if len(value) > 0 {
+			tags[key] = value
+		}
+	}
+	return tags
+}
+
+// ScaleUpMetadataFromTags reads the metadata from instance tags. Returns false if the instance has none of the tags
+func ScaleUpMetadataFromTags(tags map[string]string) (ScaleUpMetadata, bool) {
+	metadata := ScaleUpMetadata{
+		NodeGroup: tags[ScaleUpNodeGroupTagKey],
+		CycleID:   tags[ScaleUpCycleTagKey],
+		Reason:    tags[ScaleUpReasonTagKey],
+	}
+	return metadata, metadata != ScaleUpMetadata{}
+}
+
+// GCENodeGroupConfig contains the GCE cloud provider specific configuration
+// for a node group
+type GCENodeGroupConfig struct {
+	// MinSize and MaxSize bound the size of the managed instance group, which unlike an auto scaling group has no
+	// size limits of its own
+	MinSize int64
+	MaxSize int64
+}
+
+// AzureNodeGroupConfig contains the Azure cloud provider specific configuration
+// for a node group
+type AzureNodeGroupConfig struct {
+	// MinSize and MaxSize bound the capacity of the virtual machine scale set, which has no size limits of its own
+	MinSize int64
+	MaxSize int64
+}
@@ -0,0 +1,96 @@
+package controller
+
+import (
+	"github.com/atlassian/escalator/pkg/k8s"
+	"github.com/atlassian/escalator/pkg/metrics"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// allocatableHeterogeneityPercent is how far the allocatable cpu or memory of the nodes of a node group can be below
+// the largest, as a percentage of the largest, before the node group is flagged as heterogeneous. The scale up delta
+// assumes every node has the same allocatable
+const allocatableHeterogeneityPercent = 10
+
+// allocatableSpread is the smallest and largest allocatable cpu and memory of the nodes of a node group
+type allocatableSpread struct {
+	nodes  int
+	minCPU resource.Quantity
+	maxCPU resource.Quantity
+	minMem resource.Quantity
+	maxMem resource.Quantity
+}
+
+// nodesAllocatableSpread returns the spread of the allocatable of the nodes that finished starting up, as the
+// allocatable of a node isn't final until then. Nodes that don't report their allocatable yet are left out
+func nodesAllocatableSpread(nodes []*v1.Node, startupTaintKeys []string) allocatableSpread {
+	var spread allocatableSpread
+	for _, node := range nodes {
+		if _, starting := k8s.GetStartupTaint(node, startupTaintKeys); starting {
+			continue
+		}
+		cpu, mem := *node.Status.Allocatable.Cpu(), *node.Status.Allocatable.Memory()
+		if cpu.IsZero() || mem.IsZero() {
+			continue
+		}
+		if spread.nodes == 0 || cpu.Cmp(spread.minCPU) < 0 {
+			spread.minCPU = cpu
+		}
+		if spread.nodes == 0 || cpu.Cmp(spread.maxCPU) > 0 {
+			spread.maxCPU = cpu
+		}
+		if spread.nodes == 0 || mem.Cmp(spread.minMem) < 0 {
+			spread.minMem = mem
+		}
+		if spread.nodes == 0 || mem.Cmp(spread.maxMem) > 0 {
+			spread.maxMem = mem
+		}
+		spread.nodes++
+	}
+	return spread
+}
+
+// heterogeneous returns whether the smallest allocatable cpu or memory is materially below the largest
+func (s allocatableSpread) heterogeneous() bool {
+	if s.nodes < 2 {
+		return false
+	}
+	below := func(min, max resource.Quantity) bool {
+		return float64(max.MilliValue()-min.MilliValue())/float64(max.MilliValue())*100 > allocatableHeterogeneityPercent
+	}
+	return below(s.minCPU, s.maxCPU) || below(s.minMem, s.maxMem)
+}
+
+// updateAllocatable refreshes the node capacity the scale up delta is worked out with from the allocatable of the
+// nodes of the node group, every run, so a change to the allocatable such as a new kube-reserved is picked up. The
+// smallest allocatable is used so scale ups aren't short of capacity. The last capacity is kept while the node group
+// has no nodes, for scaling up from zero. Node groups whose nodes disagree materially on their allocatable are flagged
+func updateAllocatable(nodeGroup *NodeGroupState, nodes []*v1.Node) {
+	logger := log.WithField("nodegroup", nodeGroup.Opts.Name)
+	spread := nodesAllocatableSpread(nodes, nodeGroup.Opts.StartupTaintKeys())
+	if spread.nodes == 0 {
+		return
+	}
+
+	cached := !nodeGroup.cpuCapacity.IsZero() && !nodeGroup.memCapacity.IsZero()
+	if cached && (spread.minCPU.Cmp(nodeGroup.cpuCapacity) != 0 || spread.minMem.Cmp(nodeGroup.memCapacity) != 0) {
+		logger.Infof("Node allocatable changed from cpu %v, memory %v to cpu %v, memory %v",
+			nodeGroup.cpuCapacity.String(), nodeGroup.memCapacity.String(), spread.minCPU.String(), spread.minMem.String())
+	}
+	nodeGroup.cpuCapacity = spread.minCPU
+	nodeGroup.memCapacity = spread.minMem
+
+	heterogeneous := spread.heterogeneous()
+	if heterogeneous && !nodeGroup.allocatableHeterogeneous {
+		logger.Warnf("Nodes disagree on their allocatable, cpu from %v to %v and memory from %v to %v. The scale up delta assumes the smallest",
+			spread.minCPU.String(), spread.maxCPU.String(), spread.minMem.String(), spread.maxMem.String())
+	}
+	nodeGroup.allocatableHeterogeneous = heterogeneous
+	nodeGroup.cycle.HeterogeneousAllocatable = heterogeneous
+	heterogeneousValue := 0.0
+	if heterogeneous {
+		heterogeneousValue = 1
+	}
+	metrics.NodeGroupAllocatableHeterogeneous.WithLabelValues(nodeGroup.Opts.Name).Set(heterogeneousValue)
+}
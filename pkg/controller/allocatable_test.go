@@ -0,0 +1,59 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/atlassian/escalator/pkg/test"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestNodesAllocatableSpread(t *testing.T) {
+	starting := test.BuildTestNode(test.NodeOpts{Name: "starting", CPU: 500, Mem: 500})
+	starting.Spec.Taints = []v1.Taint{{Key: "node.example.com/starting", Effect: v1.TaintEffectNoSchedule}}
+	nodes := []*v1.Node{
+		test.BuildTestNode(test.NodeOpts{Name: "n1", CPU: 1000, Mem: 4000}),
+		test.BuildTestNode(test.NodeOpts{Name: "n2", CPU: 950, Mem: 4000}),
+		// doesn't report its allocatable yet
+		test.BuildTestNode(test.NodeOpts{Name: "n3", CPU: 0, Mem: 0}),
+		starting,
+	}
+
+	spread := nodesAllocatableSpread(nodes, []string{"node.example.com/starting"})
+	assert.Equal(t, 2, spread.nodes)
+	assert.Equal(t, int64(950), spread.minCPU.MilliValue())
+	assert.Equal(t, int64(1000), spread.maxCPU.MilliValue())
+	assert.Equal(t, int64(4000), spread.minMem.Value())
+	assert.Equal(t, int64(4000), spread.maxMem.Value())
+	// 5% apart isn't material
+	assert.False(t, spread.heterogeneous())
+
+	nodes = append(nodes, test.BuildTestNode(test.NodeOpts{Name: "n4", CPU: 1000, Mem: 3000}))
+	assert.True(t, nodesAllocatableSpread(nodes, nil).heterogeneous())
+	assert.False(t, nodesAllocatableSpread(nodes[:1], nil).heterogeneous())
+}
+
+func TestUpdateAllocatable(t *testing.T) {
+	nodeGroup := &NodeGroupState{Opts: NodeGroupOptions{Name: "default"}}
+	nodes := []*v1.Node{
+		test.BuildTestNode(test.NodeOpts{Name: "n1", CPU: 1000, Mem: 4000}),
+		test.BuildTestNode(test.NodeOpts{Name: "n2", CPU: 1000, Mem: 4000}),
+	}
+	updateAllocatable(nodeGroup, nodes)
+	assert.Equal(t, int64(1000), nodeGroup.cpuCapacity.MilliValue())
+	assert.Equal(t, int64(4000), nodeGroup.memCapacity.Value())
+	assert.False(t, nodeGroup.allocatableHeterogeneous)
+
+	// the allocatable of a node shrank, e.g. after kube-reserved was raised
+	nodes[1].Status.Allocatable[v1.ResourceMemory] = *resource.NewQuantity(3000, resource.DecimalSI)
+	updateAllocatable(nodeGroup, nodes)
+	assert.Equal(t, int64(3000), nodeGroup.memCapacity.Value())
+	assert.True(t, nodeGroup.allocatableHeterogeneous)
+	assert.True(t, nodeGroup.cycle.HeterogeneousAllocatable)
+
+	// the capacity is kept for scaling up from zero
+	updateAllocatable(nodeGroup, nil)
+	assert.Equal(t, int64(1000), nodeGroup.cpuCapacity.MilliValue())
+	assert.Equal(t, int64(3000), nodeGroup.memCapacity.Value())
+}
@@ -0,0 +1,138 @@
+package controller
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/atlassian/escalator/pkg/k8s"
+	"github.com/atlassian/escalator/pkg/metrics"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	// bootDurationSamples is how many of the latest boot durations the percentiles are taken from
+	bootDurationSamples = 50
+	// bootDurationMinSamples is how many boot durations auto_scale_up_cool_down needs before it sizes the cool down
+	bootDurationMinSamples = 5
+	// bootRequestWindow is how long after a scale up a new node is still accounted to it
+	bootRequestWindow = time.Hour
+	// autoScaleUpCoolDownPercentile is the percentile of the boot durations auto_scale_up_cool_down sizes the cool
+	// down to
+	autoScaleUpCoolDownPercentile = 90
+)
+
+// bootDurationPercentiles are the percentiles of the boot durations that are exported
+var bootDurationPercentiles = []int{50, 90, 99}
+
+// bootDurations tracks the time from a scale up request to the requested nodes becoming ready
+type bootDurations struct {
+	// the times of the latest scale ups, oldest first
+	requests []time.Time
+	// the nodes already measured, so each node is only measured once
+	measured map[string]bool
+	// the latest boot durations, oldest first
+	samples []time.Duration
+}
+
+// scaleUpRequested records a scale up request the nodes created after it are measured against
+func (b *bootDurations) scaleUpRequested(now time.Time) {
+	b.requests = append(b.requests, now)
+}
+
+// request returns the latest scale up requested before the node was created, false if there isn't one
+func (b *bootDurations) request(node *v1.Node) (time.Time, bool) {
+	created := node.CreationTimestamp.Time
+	for i := len(b.requests) - 1; i >= 0; i-- {
+		if !b.requests[i].After(created) {
+			return b.requests[i], true
+		}
+	}
+	return time.Time{}, false
+}
+
+// observe measures the nodes that became ready since the last call and returns their boot durations. Nodes that
+// weren't created by a scale up of escalator aren't measured
+func (b *bootDurations) observe(nodes []*v1.Node, now time.Time) []time.Duration {
+	// scale ups too old for their nodes to still be booting are forgotten
+	for len(b.requests) > 0 && now.Sub(b.requests[0]) > bootRequestWindow {
+		b.requests = b.requests[1:]
+	}
+
+	measured := make(map[string]bool, len(b.measured))
+	var observed []time.Duration
+	for _, node := range nodes {
+		if b.measured[node.Name] {
+			measured[node.Name] = true
+			continue
+		}
+		readyTime, ready := k8s.NodeReadyTime(node)
+		if !ready {
+			continue
+		}
+		requestTime, ok := b.request(node)
+		if !ok {
+			continue
+		}
+		measured[node.Name] = true
+		observed = append(observed, readyTime.Sub(requestTime))
+	}
+	b.measured = measured
+
+	b.samples = append(b.samples, observed...)
+	if len(b.samples) > bootDurationSamples {
+		b.samples = b.samples[len(b.samples)-bootDurationSamples:]
+	}
+	return observed
+}
+
+// percentile returns the nearest rank percentile of the latest boot durations, false if none were measured
+func (b *bootDurations) percentile(p int) (time.Duration, bool) {
+	if len(b.samples) == 0 {
+		return 0, false
+	}
+	sorted := make([]time.Duration, len(b.samples))
+	copy(sorted, b.samples)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	// the rank is rounded up
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1], true
+}
+
+// percentiles returns the exported percentiles of the latest boot durations by name, e.g. p90
+func (b *bootDurations) percentiles() map[string]string {
+	percentiles := make(map[string]string, len(bootDurationPercentiles))
+	for _, p := range bootDurationPercentiles {
+		if duration, ok := b.percentile(p); ok {
+			percentiles[fmt.Sprintf("p%v", p)] = duration.String()
+		}
+	}
+	return percentiles
+}
+
+// trackBootDurations measures the boot durations of the nodes of the node group that became ready and, with
+// auto_scale_up_cool_down, sizes the scale up cool down period to them
+func trackBootDurations(nodeGroup *NodeGroupState, nodes []*v1.Node, now time.Time) {
+	nodegroup := nodeGroup.Opts.Name
+	for _, duration := range nodeGroup.bootDurations.observe(nodes, now) {
+		metrics.NodeGroupNodeBootDuration.WithLabelValues(nodegroup).Observe(duration.Seconds())
+	}
+	for _, p := range bootDurationPercentiles {
+		if duration, ok := nodeGroup.bootDurations.percentile(p); ok {
+			metrics.NodeGroupNodeBootDurationPercentile.WithLabelValues(nodegroup, fmt.Sprint(p)).Set(duration.Seconds())
+		}
+	}
+
+	coolDown := nodeGroup.Opts.ScaleUpCoolDownPeriodDuration()
+	if nodeGroup.Opts.AutoScaleUpCoolDown && len(nodeGroup.bootDurations.samples) >= bootDurationMinSamples {
+		coolDown, _ = nodeGroup.bootDurations.percentile(autoScaleUpCoolDownPercentile)
+	}
+	if coolDown != nodeGroup.scaleUpLock.minimumLockDuration {
+		log.WithField("nodegroup", nodegroup).Infof("Scale up cool down period is now %v", coolDown)
+		nodeGroup.scaleUpLock.minimumLockDuration = coolDown
+	}
+}
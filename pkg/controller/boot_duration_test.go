@@ -0,0 +1,135 @@
+package controller
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/atlassian/escalator/pkg/test"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func buildBootedNode(name string, created time.Time, ready time.Time) *v1.Node {
+	node := test.BuildTestNode(test.NodeOpts{Name: name, Creation: created})
+	if !ready.IsZero() {
+		node.Status.Conditions = []v1.NodeCondition{
+			{Type: v1.NodeReady, Status: v1.ConditionTrue, LastTransitionTime: metav1.NewTime(ready)},
+		}
+	}
+	return node
+}
+
+func TestBootDurationsObserve(t *testing.T) {
+	requested := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	var b bootDurations
+	b.scaleUpRequested(requested)
+
+	nodes := []*v1.Node{
+		// existed before the scale up
+		buildBootedNode("old", requested.Add(-time.Hour), requested.Add(-time.Hour)),
+		buildBootedNode("n1", requested.Add(time.Minute), requested.Add(3*time.Minute)),
+		// registered, but not ready yet
+		buildBootedNode("n2", requested.Add(time.Minute), time.Time{}),
+	}
+	assert.Equal(t, []time.Duration{3 * time.Minute}, b.observe(nodes, requested.Add(4*time.Minute)))
+
+	// n1 is only measured once
+	nodes[2] = buildBootedNode("n2", requested.Add(time.Minute), requested.Add(5*time.Minute))
+	assert.Equal(t, []time.Duration{5 * time.Minute}, b.observe(nodes, requested.Add(6*time.Minute)))
+	assert.Equal(t, []time.Duration{3 * time.Minute, 5 * time.Minute}, b.samples)
+
+	// a node created after the scale up has been forgotten isn't measured
+	late := buildBootedNode("n3", requested.Add(2*time.Hour), requested.Add(2*time.Hour))
+	assert.Empty(t, b.observe(append(nodes, late), requested.Add(2*time.Hour)))
+	assert.Empty(t, b.requests)
+}
+
+func TestBootDurationsObserveRequests(t *testing.T) {
+	first := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	second := first.Add(10 * time.Minute)
+	var b bootDurations
+	b.scaleUpRequested(first)
+	b.scaleUpRequested(second)
+
+	nodes := []*v1.Node{
+		buildBootedNode("n1", first.Add(time.Minute), second.Add(time.Minute)),
+		buildBootedNode("n2", second.Add(time.Minute), second.Add(2*time.Minute)),
+	}
+	// each node is measured from the latest scale up before it was created
+	assert.Equal(t, []time.Duration{11 * time.Minute, 2 * time.Minute}, b.observe(nodes, second.Add(3*time.Minute)))
+}
+
+func TestBootDurationsPercentile(t *testing.T) {
+	var b bootDurations
+	_, ok := b.percentile(90)
+	assert.False(t, ok)
+	assert.Empty(t, b.percentiles())
+
+	for i := 10; i >= 1; i-- {
+		b.samples = append(b.samples, time.Duration(i)*time.Minute)
+	}
+	tests := []struct {
+		percentile int
+		want       time.Duration
+	}{
+		{0, time.Minute},
+		{50, 5 * time.Minute},
+		{90, 9 * time.Minute},
+		{99, 10 * time.Minute},
+		{100, 10 * time.Minute},
+	}
+	for _, tt := range tests {
+		got, ok := b.percentile(tt.percentile)
+		assert.True(t, ok)
+		assert.Equal(t, tt.want, got, "percentile %v", tt.percentile)
+	}
+	assert.Equal(t, map[string]string{"p50": "5m0s", "p90": "9m0s", "p99": "10m0s"}, b.percentiles())
+}
+
+func TestBootDurationsSamplesLimit(t *testing.T) {
+	requested := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	var b bootDurations
+	b.scaleUpRequested(requested)
+
+	nodes := make([]*v1.Node, 0, bootDurationSamples+10)
+	for i := 0; i < bootDurationSamples+10; i++ {
+		nodes = append(nodes, buildBootedNode(fmt.Sprintf("n%v", i), requested, requested.Add(time.Duration(i)*time.Second)))
+	}
+	b.observe(nodes, requested.Add(time.Minute))
+	assert.Len(t, b.samples, bootDurationSamples)
+	// the oldest are dropped
+	assert.Equal(t, 10*time.Second, b.samples[0])
+}
+
+func TestTrackBootDurations(t *testing.T) {
+	requested := time.Now().Add(-time.Hour / 2)
+	nodes := make([]*v1.Node, 0, bootDurationMinSamples)
+	for i := 0; i < bootDurationMinSamples; i++ {
+		nodes = append(nodes, buildBootedNode(fmt.Sprintf("n%v", i), requested, requested.Add(time.Duration(i+1)*time.Minute)))
+	}
+
+	tests := []struct {
+		name  string
+		auto  bool
+		nodes []*v1.Node
+		want  time.Duration
+	}{
+		{"disabled", false, nodes, 2 * time.Minute},
+		{"not enough boot durations", true, nodes[:bootDurationMinSamples-1], 2 * time.Minute},
+		{"enabled", true, nodes, 5 * time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodeGroup := &NodeGroupState{
+				Opts:        NodeGroupOptions{Name: "default", ScaleUpCoolDownPeriod: "2m", AutoScaleUpCoolDown: tt.auto},
+				scaleUpLock: scaleLock{minimumLockDuration: 2 * time.Minute, nodegroup: "default"},
+			}
+			nodeGroup.bootDurations.scaleUpRequested(requested)
+
+			trackBootDurations(nodeGroup, tt.nodes, time.Now())
+			assert.Equal(t, tt.want, nodeGroup.scaleUpLock.minimumLockDuration)
+		})
+	}
+}
@@ -1,13 +1,17 @@
 package controller
 
 import (
+	"sync/atomic"
 	"time"
 
 	"github.com/atlassian/escalator/pkg/k8s"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/kubernetes"
 	v1lister "k8s.io/client-go/listers/core/v1"
+	policylister "k8s.io/client-go/listers/policy/v1beta1"
+	"k8s.io/client-go/tools/cache"
 )
 
 // Client provides a wrapper around a k8s client that includes
@@ -19,8 +23,22 @@ type Client struct {
 	// Backing store for all listers used by the Client
 	allPodLister  v1lister.PodLister
 	allNodeLister v1lister.NodeLister
+	// allEventLister lists the FailedScheduling events of the pods, nil unless they are watched
+	allEventLister v1lister.EventLister
+	// allPodDisruptionBudgetLister lists the PodDisruptionBudgets, nil unless they are watched
+	allPodDisruptionBudgetLister policylister.PodDisruptionBudgetLister
+
+	// nodeAdded receives the nodes that registered after the cache synced
+	nodeAdded chan *v1.Node
 }
 
+// waitForSyncTries is the number of times the caches are waited on to sync before giving up
+const waitForSyncTries = 3
+
+// nodeAddedBufferSize is the number of registered nodes buffered for the controller. Nodes registering while the
+// buffer is full are only picked up by the next run
+const nodeAddedBufferSize = 100
+
 // NewClient creates a new client wrapper over the k8sclient with some pod and node listers
 // It will wait for the cache to sync before returning
 func NewClient(k8sClient kubernetes.Interface, nodegroups []NodeGroupOptions, stopCache <-chan struct{}) (*Client, error) {
@@ -28,8 +46,23 @@ func NewClient(k8sClient kubernetes.Interface, nodegroups []NodeGroupOptions, st
 	podStopChan := make(chan struct{})
 	nodeStopChan := make(chan struct{})
 
+	// nodes are only sent once the cache has synced, the initial list adds every node in the cluster
+	var cacheSynced int32
+	nodeAdded := make(chan *v1.Node, nodeAddedBufferSize)
 	allPodLister, podSync := k8s.NewCachePodWatcher(k8sClient, podStopChan)
-	allNodeLister, nodeSync := k8s.NewCacheNodeWatcher(k8sClient, nodeStopChan)
+	allNodeLister, nodeSync := k8s.NewCacheNodeWatcherWithHandler(k8sClient, nodeStopChan, cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			node, ok := obj.(*v1.Node)
+			if !ok || atomic.LoadInt32(&cacheSynced) == 0 {
+				return
+			}
+			select {
+			case nodeAdded <- node:
+			default:
+				log.Debugf("Dropped the registration of node %v, it will be picked up by the next run", node.Name)
+			}
+		},
+	})
 
 	// Spawn a routine to watch for the global stop signal
 	// once it's received, send the stop signal to the cache informers
@@ -43,31 +76,66 @@ func NewClient(k8sClient kubernetes.Interface, nodegroups []NodeGroupOptions, st
 	log.Info("Waiting for cache to sync...")
 	startTime := time.Now()
 
-	const waitForSyncTries = 3
 	synced := k8s.WaitForSync(waitForSyncTries, stopCache, podSync, nodeSync)
 	if !synced {
 		return nil, errors.Errorf("attempted to wait for caches to be synced %d times. Exiting", waitForSyncTries)
 	}
 
+	atomic.StoreInt32(&cacheSynced, 1)
+
 	endTime := time.Now()
 	log.Infof("Cache took %v to sync", endTime.Sub(startTime))
 
+	client := NewClientWithListers(k8sClient, nodegroups, allPodLister, allNodeLister)
+	client.nodeAdded = nodeAdded
+	return client, nil
+}
+
+// WatchSchedulingFailures watches the FailedScheduling events of the pods, for counting the scheduling failures of each
+// node group. It waits for the cache to sync before returning
+func (c *Client) WatchSchedulingFailures(stopCache <-chan struct{}) error {
+	log.Info("Waiting for the FailedScheduling event cache to sync...")
+	eventLister, eventSync := k8s.NewCacheEventWatcher(c.Interface, k8s.FailedSchedulingReason, stopCache)
+	if !k8s.WaitForSync(waitForSyncTries, stopCache, eventSync) {
+		return errors.Errorf("attempted to wait for the event cache to be synced %d times. Exiting", waitForSyncTries)
+	}
+	c.allEventLister = eventLister
+	return nil
+}
+
+// WatchPodDisruptionBudgets watches the PodDisruptionBudgets, for deferring the hard deletions of tainted nodes that
+// would violate them. It waits for the cache to sync before returning
+func (c *Client) WatchPodDisruptionBudgets(stopCache <-chan struct{}) error {
+	log.Info("Waiting for the PodDisruptionBudget cache to sync...")
+	pdbLister, pdbSync := k8s.NewCachePodDisruptionBudgetWatcher(c.Interface, stopCache)
+	if !k8s.WaitForSync(waitForSyncTries, stopCache, pdbSync) {
+		return errors.Errorf("attempted to wait for the PodDisruptionBudget cache to be synced %d times. Exiting", waitForSyncTries)
+	}
+	c.allPodDisruptionBudgetLister = pdbLister
+	return nil
+}
+
+// NewClientWithListers creates a new client wrapper over the k8sclient that lists the pods and nodes from the given
+// backing listers instead of watching the cluster, e.g. for load tests with synthetic nodes and pods
+func NewClientWithListers(k8sClient kubernetes.Interface, nodegroups []NodeGroupOptions, allPodLister v1lister.PodLister, allNodeLister v1lister.NodeLister) *Client {
 	// load in all our node group listers from our nodegroups
 	nodegroupMap := make(map[string]*NodeGroupLister)
 
 	for _, opts := range nodegroups {
-		if opts.Name == DefaultNodeGroup {
-			nodegroupMap[opts.Name] = NewDefaultNodeGroupLister(allPodLister, allNodeLister, opts)
-		} else {
-			nodegroupMap[opts.Name] = NewNodeGroupLister(allPodLister, allNodeLister, opts)
-		}
+		nodegroupMap[opts.Name] = newNodeGroupLister(allPodLister, allNodeLister, opts)
 	}
-	client := Client{
-		k8sClient,
-		nodegroupMap,
-		allPodLister,
-		allNodeLister,
+	return &Client{
+		Interface:     k8sClient,
+		Listers:       nodegroupMap,
+		allPodLister:  allPodLister,
+		allNodeLister: allNodeLister,
 	}
+}
 
-	return &client, nil
+// newNodeGroupLister creates the lister for the nodegroup, using the default filter for the default nodegroup
+func newNodeGroupLister(allPodLister v1lister.PodLister, allNodeLister v1lister.NodeLister, opts NodeGroupOptions) *NodeGroupLister {
+	if opts.Name == DefaultNodeGroup {
+		return NewDefaultNodeGroupLister(allPodLister, allNodeLister, opts)
+	}
+	return NewNodeGroupLister(allPodLister, allNodeLister, opts)
 }
@@ -0,0 +1,239 @@
+package controller
+
+import (
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/atlassian/escalator/pkg/errorkind"
+	"github.com/atlassian/escalator/pkg/k8s"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// Config is the nodegroups config file
+type Config struct {
+	ClusterOptions
+	NodeGroups []NodeGroupOptions `json:"node_groups" yaml:"node_groups"`
+}
+
+// ClusterOptions are constraints evaluated across all of the node groups
+type ClusterOptions struct {
+	// the untainted capacity summed across all node groups is never scaled down below these
+	MinCPU    string `json:"cluster_min_cpu,omitempty" yaml:"cluster_min_cpu,omitempty"`
+	MinMemory string `json:"cluster_min_memory,omitempty" yaml:"cluster_min_memory,omitempty"`
+
+	// node groups removed from the config on a reload untaint their nodes instead of leaving them tainted
+	DecommissionRemovedNodeGroups bool `json:"decommission_removed_node_groups,omitempty" yaml:"decommission_removed_node_groups,omitempty"`
+
+	// scale ups against a cloud provider quota with too little room for all of them are shared by quota_priority
+	// instead of going to the node groups evaluated first
+	QuotaCoordinator bool `json:"quota_coordinator,omitempty" yaml:"quota_coordinator,omitempty"`
+
+	// the overhead of the RuntimeClasses, added to the requests of the pods running with them
+	RuntimeClassOverheads []RuntimeClassOverhead `json:"runtime_class_overheads,omitempty" yaml:"runtime_class_overheads,omitempty"`
+
+	// recurring windows during which scale down of the node groups is frozen
+	MaintenanceWindows []MaintenanceWindow `json:"maintenance_windows,omitempty" yaml:"maintenance_windows,omitempty"`
+
+	// the cluster-autoscaler-priority-expander ConfigMap that orders the node groups of the pools with the priority
+	// pool_scale_policy
+	PriorityExpander PriorityExpanderOptions `json:"priority_expander,omitempty" yaml:"priority_expander,omitempty"`
+}
+
+// PriorityExpanderOptions is the ConfigMap of the cluster autoscaler priority expander read for the priorities of the
+// node groups
+type PriorityExpanderOptions struct {
+	// Namespace defaults to kube-system, as in the cluster autoscaler
+	Namespace string `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+	// Name of the ConfigMap, usually cluster-autoscaler-priority-expander. Disabled when empty
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+}
+
+// enabled returns whether the priorities are read from a ConfigMap
+func (o PriorityExpanderOptions) enabled() bool {
+	return len(o.Name) > 0
+}
+
+// ConfigMapNamespace returns the namespace of the ConfigMap, defaulting to kube-system
+func (o PriorityExpanderOptions) ConfigMapNamespace() string {
+	if len(o.Namespace) == 0 {
+		return "kube-system"
+	}
+	return o.Namespace
+}
+
+// RuntimeClassOverhead is the overhead.podFixed of a RuntimeClass, e.g. the sandbox of gVisor or Kata
+type RuntimeClassOverhead struct {
+	// Name is the name of the RuntimeClass, as set in the runtimeClassName of pods
+	Name   string `json:"name,omitempty" yaml:"name,omitempty"`
+	CPU    string `json:"cpu,omitempty" yaml:"cpu,omitempty"`
+	Memory string `json:"memory,omitempty" yaml:"memory,omitempty"`
+}
+
+// UnmarshalConfig decodes the yaml or json reader into a struct. The cron windows of the maintenance windows and
+// reservations are parsed once here, rather than every time they are checked
+func UnmarshalConfig(reader io.Reader) (Config, error) {
+	var config Config
+	if err := yaml.NewYAMLOrJSONDecoder(reader, 4096).Decode(&config); err != nil {
+		return Config{}, err
+	}
+	for i := range config.MaintenanceWindows {
+		window := &config.MaintenanceWindows[i]
+		window.parsed, _ = parseCronWindow(window.Start, window.Duration, window.TimeZone)
+	}
+	for i := range config.NodeGroups {
+		config.NodeGroups[i].parseReservations()
+	}
+	return config, nil
+}
+
+// ValidateClusterOptions is a safety check to validate that the cluster options are valid
+func ValidateClusterOptions(opts ClusterOptions) []error {
+	var problems []error
+
+	checkQuantity := func(name string, value string) {
+		if len(value) == 0 {
+			return
+		}
+		quantity, err := resource.ParseQuantity(value)
+		if err != nil {
+			problems = append(problems, errorkind.New(errorkind.Validation, "%v must be a valid quantity: %v", name, err))
+			return
+		}
+		if quantity.Sign() < 0 {
+			problems = append(problems, errorkind.New(errorkind.Validation, "%v must not be negative", name))
+		}
+	}
+
+	checkQuantity("cluster_min_cpu", opts.MinCPU)
+	checkQuantity("cluster_min_memory", opts.MinMemory)
+
+	runtimeClasses := make(map[string]bool, len(opts.RuntimeClassOverheads))
+	for i, overhead := range opts.RuntimeClassOverheads {
+		if len(overhead.Name) == 0 {
+			problems = append(problems, errorkind.New(errorkind.Validation, "runtime_class_overheads[%v].name must not be empty", i))
+		} else if runtimeClasses[overhead.Name] {
+			problems = append(problems, errorkind.New(errorkind.Validation, "runtime_class_overheads has %v more than once", overhead.Name))
+		}
+		runtimeClasses[overhead.Name] = true
+		checkQuantity(fmt.Sprintf("runtime_class_overheads[%v].cpu", i), overhead.CPU)
+		checkQuantity(fmt.Sprintf("runtime_class_overheads[%v].memory", i), overhead.Memory)
+	}
+
+	maintenanceWindows := make(map[string]bool, len(opts.MaintenanceWindows))
+	for i, window := range opts.MaintenanceWindows {
+		problems = append(problems, window.validate(i)...)
+		if len(window.Name) > 0 && maintenanceWindows[window.Name] {
+			problems = append(problems, errorkind.New(errorkind.Validation, "maintenance_windows has %v more than once", window.Name))
+		}
+		maintenanceWindows[window.Name] = true
+	}
+
+	if len(opts.PriorityExpander.Namespace) > 0 && !opts.PriorityExpander.enabled() {
+		problems = append(problems, errorkind.New(errorkind.Validation, "priority_expander.name must be set with priority_expander.namespace"))
+	}
+	return problems
+}
+
+// runtimeClassOverheads returns the runtime_class_overheads option by RuntimeClass name
+func (o ClusterOptions) runtimeClassOverheads() k8s.RuntimeClassOverheads {
+	if len(o.RuntimeClassOverheads) == 0 {
+		return nil
+	}
+	overheads := make(k8s.RuntimeClassOverheads, len(o.RuntimeClassOverheads))
+	for _, overhead := range o.RuntimeClassOverheads {
+		overheads[overhead.Name] = v1.ResourceList{
+			v1.ResourceCPU:    parseQuantityOrZero(overhead.CPU),
+			v1.ResourceMemory: parseQuantityOrZero(overhead.Memory),
+		}
+	}
+	return overheads
+}
+
+// MinCPUQuantity returns the cluster_min_cpu option as a quantity. Zero if it isn't set
+func (o ClusterOptions) MinCPUQuantity() resource.Quantity {
+	return parseQuantityOrZero(o.MinCPU)
+}
+
+// MinMemoryQuantity returns the cluster_min_memory option as a quantity. Zero if it isn't set
+func (o ClusterOptions) MinMemoryQuantity() resource.Quantity {
+	return parseQuantityOrZero(o.MinMemory)
+}
+
+func parseQuantityOrZero(value string) resource.Quantity {
+	quantity, err := resource.ParseQuantity(value)
+	if err != nil {
+		return resource.Quantity{}
+	}
+	return quantity
+}
+
+// clusterFloorScaleDownLimit returns how many nodes can be removed from the node group without the untainted capacity
+// of all node groups dropping below cluster_min_cpu or cluster_min_memory
+func (c *Controller) clusterFloorScaleDownLimit(nodeGroup *NodeGroupState) int {
+	limit := math.MaxInt32
+
+	var clusterCPU, clusterMem int64
+	for _, state := range c.nodeGroups {
+		clusterCPU += state.untaintedCPUCapacity.MilliValue()
+		clusterMem += state.untaintedMemCapacity.Value()
+	}
+
+	floorLimit := func(total int64, floor int64, perNode int64) {
+		if floor <= 0 {
+			return
+		}
+		if perNode <= 0 {
+			// without knowing the size of the nodes, only scale down while the cluster is above the floor
+			if total <= floor {
+				limit = 0
+			}
+			return
+		}
+		nodes := (total - floor) / perNode
+		if nodes < 0 {
+			nodes = 0
+		}
+		if nodes < int64(limit) {
+			limit = int(nodes)
+		}
+	}
+
+	minCPU := c.Opts.Cluster.MinCPUQuantity()
+	minMem := c.Opts.Cluster.MinMemoryQuantity()
+	floorLimit(clusterCPU, minCPU.MilliValue(), nodeGroup.cpuCapacity.MilliValue())
+	floorLimit(clusterMem, minMem.Value(), nodeGroup.memCapacity.Value())
+	return limit
+}
+
+// limitScaleDownToClusterFloor reduces the number of nodes removed from the node group so the cluster stays above
+// cluster_min_cpu and cluster_min_memory
+func (c *Controller) limitScaleDownToClusterFloor(nodeGroup *NodeGroupState, nodesDelta int) int {
+	if nodesDelta >= 0 {
+		return nodesDelta
+	}
+	limit := c.clusterFloorScaleDownLimit(nodeGroup)
+	if -nodesDelta > limit {
+		log.WithField("nodegroup", nodeGroup.Opts.Name).Infof(
+			"Limiting scale down from %v to %v nodes to keep the cluster above cluster_min_cpu %q and cluster_min_memory %q",
+			-nodesDelta,
+			limit,
+			c.Opts.Cluster.MinCPU,
+			c.Opts.Cluster.MinMemory,
+		)
+		return -limit
+	}
+	return nodesDelta
+}
+
+// removeUntaintedCapacity removes the capacity of the newly tainted nodes from the node group, so node groups evaluated
+// later in the same run see the reduced cluster capacity
+func removeUntaintedCapacity(nodeGroup *NodeGroupState, nodes int) {
+	for i := 0; i < nodes; i++ {
+		nodeGroup.untaintedCPUCapacity.Sub(nodeGroup.cpuCapacity)
+		nodeGroup.untaintedMemCapacity.Sub(nodeGroup.memCapacity)
+	}
+}
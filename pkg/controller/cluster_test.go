@@ -0,0 +1,117 @@
+package controller
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const yamlClusterOptions = `cluster_min_cpu: "100"
+cluster_min_memory: 400Gi
+node_groups:
+  - name: "default"
+    label_key: "customer"
+    label_value: "shared"
+`
+
+func TestUnmarshalConfig(t *testing.T) {
+	config, err := UnmarshalConfig(strings.NewReader(yamlClusterOptions))
+	require.NoError(t, err)
+	assert.Equal(t, "100", config.MinCPU)
+	assert.Equal(t, "400Gi", config.MinMemory)
+	require.Len(t, config.NodeGroups, 1)
+	assert.Equal(t, "default", config.NodeGroups[0].Name)
+
+	minCPU := config.MinCPUQuantity()
+	assert.Equal(t, int64(100000), minCPU.MilliValue())
+	minMem := config.MinMemoryQuantity()
+	assert.Equal(t, int64(400*1024*1024*1024), minMem.Value())
+
+	config, err = UnmarshalConfig(strings.NewReader(yamlBE))
+	require.NoError(t, err)
+	assert.Equal(t, ClusterOptions{}, config.ClusterOptions)
+	minCPU = config.MinCPUQuantity()
+	assert.True(t, minCPU.IsZero())
+}
+
+func TestValidateClusterOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		opts ClusterOptions
+		want int
+	}{
+		{"unset", ClusterOptions{}, 0},
+		{"valid", ClusterOptions{MinCPU: "500m", MinMemory: "1Ti"}, 0},
+		{"invalid cpu", ClusterOptions{MinCPU: "lots"}, 1},
+		{"negative memory", ClusterOptions{MinMemory: "-1Gi"}, 1},
+		{"both invalid", ClusterOptions{MinCPU: "-1", MinMemory: "lots"}, 2},
+		{"valid overheads", ClusterOptions{RuntimeClassOverheads: []RuntimeClassOverhead{{Name: "gvisor", CPU: "250m", Memory: "120Mi"}, {Name: "kata"}}}, 0},
+		{"overhead without name", ClusterOptions{RuntimeClassOverheads: []RuntimeClassOverhead{{CPU: "250m"}}}, 1},
+		{"duplicate overhead", ClusterOptions{RuntimeClassOverheads: []RuntimeClassOverhead{{Name: "kata"}, {Name: "kata"}}}, 1},
+		{"invalid overhead", ClusterOptions{RuntimeClassOverheads: []RuntimeClassOverhead{{Name: "kata", CPU: "lots", Memory: "-1"}}}, 2},
+		{"valid maintenance window", ClusterOptions{MaintenanceWindows: []MaintenanceWindow{{Name: "upgrade", Start: "0 2 * * 6", Duration: "4h", TimeZone: "UTC"}}}, 0},
+		{"invalid maintenance window", ClusterOptions{MaintenanceWindows: []MaintenanceWindow{{Start: "0 2 * *", Duration: "8d", TimeZone: "Mars/Olympus"}}}, 4},
+		{"long maintenance window", ClusterOptions{MaintenanceWindows: []MaintenanceWindow{{Name: "upgrade", Start: "0 2 * * 6", Duration: "200h"}}}, 1},
+		{"duplicate maintenance window", ClusterOptions{MaintenanceWindows: []MaintenanceWindow{{Name: "upgrade", Start: "0 2 * * 6", Duration: "1h"}, {Name: "upgrade", Start: "0 2 * * 0", Duration: "1h"}}}, 1},
+		{"priority expander", ClusterOptions{PriorityExpander: PriorityExpanderOptions{Name: "cluster-autoscaler-priority-expander"}}, 0},
+		{"priority expander without name", ClusterOptions{PriorityExpander: PriorityExpanderOptions{Namespace: "kube-system"}}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Len(t, ValidateClusterOptions(tt.opts), tt.want)
+		})
+	}
+}
+
+func TestControllerLimitScaleDownToClusterFloor(t *testing.T) {
+	// two node groups of 4 cpu, 16Gi nodes. 10 nodes in total
+	newState := func(name string, nodes int64) *NodeGroupState {
+		return &NodeGroupState{
+			Opts:                 NodeGroupOptions{Name: name},
+			cpuCapacity:          *resource.NewQuantity(4, resource.DecimalSI),
+			memCapacity:          *resource.NewQuantity(16<<30, resource.BinarySI),
+			untaintedCPUCapacity: *resource.NewQuantity(4*nodes, resource.DecimalSI),
+			untaintedMemCapacity: *resource.NewQuantity(16<<30*nodes, resource.BinarySI),
+		}
+	}
+
+	tests := []struct {
+		name       string
+		cluster    ClusterOptions
+		nodesDelta int
+		want       int
+	}{
+		{"no floor", ClusterOptions{}, -3, -3},
+		{"scale up unaffected", ClusterOptions{MinCPU: "1000"}, 2, 2},
+		{"above cpu floor", ClusterOptions{MinCPU: "20"}, -3, -3},
+		{"limited by cpu floor", ClusterOptions{MinCPU: "30"}, -5, -2},
+		{"limited by memory floor", ClusterOptions{MinCPU: "20", MinMemory: "144Gi"}, -5, -1},
+		{"at floor", ClusterOptions{MinMemory: "160Gi"}, -2, 0},
+		{"below floor", ClusterOptions{MinCPU: "100"}, -2, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			batch := newState("batch", 6)
+			c := &Controller{
+				Opts:       Opts{Cluster: tt.cluster},
+				nodeGroups: map[string]*NodeGroupState{"batch": batch, "default": newState("default", 4)},
+			}
+			assert.Equal(t, tt.want, c.limitScaleDownToClusterFloor(batch, tt.nodesDelta))
+		})
+	}
+
+	t.Run("later node groups see the removed capacity", func(t *testing.T) {
+		batch := newState("batch", 6)
+		shared := newState("default", 4)
+		c := &Controller{
+			Opts:       Opts{Cluster: ClusterOptions{MinCPU: "32"}},
+			nodeGroups: map[string]*NodeGroupState{"batch": batch, "default": shared},
+		}
+		assert.Equal(t, -2, c.limitScaleDownToClusterFloor(batch, -3))
+		removeUntaintedCapacity(batch, 2)
+		assert.Equal(t, 0, c.limitScaleDownToClusterFloor(shared, -1))
+	})
+}
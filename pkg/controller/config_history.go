@@ -0,0 +1,252 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/atlassian/escalator/pkg/cloudprovider"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// ConfigSourceStartup is the config escalator started with
+	ConfigSourceStartup = "startup"
+	// ConfigSourceReload is a config reloaded from the nodegroups config file
+	ConfigSourceReload = "reload"
+	// ConfigSourceRollback is an earlier config applied again by a rollback
+	ConfigSourceRollback = "rollback"
+)
+
+// ConfigRevision is a config applied by the controller, and what changed from the config applied before it
+type ConfigRevision struct {
+	Revision int       `json:"revision"`
+	Time     time.Time `json:"time"`
+	// Source is how the config was applied: startup, reload or rollback
+	Source string `json:"source"`
+	// RollbackOf is the revision a rollback applied again
+	RollbackOf int    `json:"rollback_of,omitempty"`
+	Config     Config `json:"config"`
+	// Changes are the settings that changed from the previous revision, empty for the first revision
+	Changes []ConfigChange `json:"changes"`
+
+	// the builder of the cloud provider the config was applied with, for rolling back to it
+	cloudProviderBuilder cloudprovider.Builder
+}
+
+// ConfigChange is a setting that changed between two configs. The values are json, and empty when the setting is
+// unset. A node group that was added or removed is a single change of the whole node group
+type ConfigChange struct {
+	Path string `json:"path"`
+	Old  string `json:"old,omitempty"`
+	New  string `json:"new,omitempty"`
+}
+
+// configHistory keeps the latest configs applied by the controller, oldest first
+type configHistory struct {
+	lock      sync.RWMutex
+	size      int
+	revisions []ConfigRevision
+	last      int
+}
+
+// add records the config as the next revision, dropping the oldest revision once the history is full. Nothing is
+// recorded when the history has no size
+func (h *configHistory) add(config Config, builder cloudprovider.Builder, source string, rollbackOf int, now time.Time) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if h.size <= 0 {
+		return
+	}
+
+	revision := ConfigRevision{
+		Revision:             h.last + 1,
+		Time:                 now,
+		Source:               source,
+		RollbackOf:           rollbackOf,
+		Config:               config,
+		Changes:              []ConfigChange{},
+		cloudProviderBuilder: builder,
+	}
+	if len(h.revisions) > 0 {
+		revision.Changes = diffConfigs(h.revisions[len(h.revisions)-1].Config, config)
+	}
+	h.last = revision.Revision
+	h.revisions = append(h.revisions, revision)
+	if len(h.revisions) > h.size {
+		h.revisions = h.revisions[len(h.revisions)-h.size:]
+	}
+}
+
+// list returns a copy of the revisions in the history, oldest first
+func (h *configHistory) list() []ConfigRevision {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	return append([]ConfigRevision{}, h.revisions...)
+}
+
+// get returns the revision if it is still in the history
+func (h *configHistory) get(revision int) (ConfigRevision, bool) {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	for _, r := range h.revisions {
+		if r.Revision == revision {
+			return r, true
+		}
+	}
+	return ConfigRevision{}, false
+}
+
+// diffConfigs returns the settings that changed between the configs, by path. Node groups are matched by
+// name, e.g. node_groups.shared.max_nodes. A node group only in one of the configs is a single change of the whole
+// node group
+func diffConfigs(from, to Config) []ConfigChange {
+	oldSettings := flattenConfig(from, to)
+	newSettings := flattenConfig(to, from)
+
+	changes := make([]ConfigChange, 0)
+	for path, oldValue := range oldSettings {
+		if newValue := newSettings[path]; newValue != oldValue {
+			changes = append(changes, ConfigChange{Path: path, Old: oldValue, New: newValue})
+		}
+	}
+	for path, newValue := range newSettings {
+		if _, ok := oldSettings[path]; !ok {
+			changes = append(changes, ConfigChange{Path: path, New: newValue})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
+
+// flattenConfig returns the json of each setting of the config by path. The node groups that aren't in the other
+// config are kept whole under their path
+func flattenConfig(config Config, other Config) map[string]string {
+	otherNames := make(map[string]bool, len(other.NodeGroups))
+	for _, nodeGroup := range other.NodeGroups {
+		otherNames[nodeGroup.Name] = true
+	}
+
+	settings := make(map[string]string)
+	flattenJSON(settings, "", config.ClusterOptions)
+	for _, nodeGroup := range config.NodeGroups {
+		path := "node_groups." + nodeGroup.Name
+		if !otherNames[nodeGroup.Name] {
+			encoded, _ := json.Marshal(nodeGroup)
+			settings[path] = string(encoded)
+			continue
+		}
+		flattenJSON(settings, path, nodeGroup)
+	}
+	return settings
+}
+
+// flattenJSON adds the json of the value to the settings, with an entry for each of the leaves of its objects
+func flattenJSON(settings map[string]string, path string, value interface{}) {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		settings[path] = fmt.Sprint(value)
+		return
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		settings[path] = string(encoded)
+		return
+	}
+	flattenDecoded(settings, path, decoded)
+}
+
+func flattenDecoded(settings map[string]string, path string, value interface{}) {
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		encoded, _ := json.Marshal(value)
+		settings[path] = string(encoded)
+		return
+	}
+	for key, child := range object {
+		childPath := key
+		if len(path) > 0 {
+			childPath = path + "." + key
+		}
+		flattenDecoded(settings, childPath, child)
+	}
+}
+
+// recordConfig records the config applied by the controller in its history
+func (c *Controller) recordConfig(config Config, builder cloudprovider.Builder, source string, rollbackOf int) {
+	c.configHistory.add(config, builder, source, rollbackOf, time.Now())
+}
+
+// ConfigHistory returns the latest configs applied by the controller, oldest first
+func (c *Controller) ConfigHistory() []ConfigRevision {
+	return c.configHistory.list()
+}
+
+// Rollback applies the config of the revision again, the same as a reload. The nodegroups config file isn't changed,
+// so the next reload applies the file again. Returns the revision created by the rollback
+func (c *Controller) Rollback(revision int) (ConfigRevision, error) {
+	target, ok := c.configHistory.get(revision)
+	if !ok {
+		return ConfigRevision{}, errors.Errorf("revision %v is not in the config history", revision)
+	}
+	err := c.requestReload(reloadRequest{
+		config:               target.Config,
+		cloudProviderBuilder: target.cloudProviderBuilder,
+		source:               ConfigSourceRollback,
+		rollbackOf:           revision,
+		result:               make(chan error, 1),
+	})
+	if err != nil {
+		return ConfigRevision{}, err
+	}
+	history := c.configHistory.list()
+	return history[len(history)-1], nil
+}
+
+// ConfigHistoryHandler serves the config history as json
+func (c *Controller) ConfigHistoryHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(c.ConfigHistory()); err != nil {
+			log.WithError(err).Warn("Failed to write config history")
+		}
+	})
+}
+
+// ConfigRollbackHandler rolls back to the revision given by the revision query parameter on a POST, and serves the
+// revision created by the rollback as json
+func (c *Controller) ConfigRollbackHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "rollback must be a POST", http.StatusMethodNotAllowed)
+			return
+		}
+		revision, err := strconv.Atoi(r.URL.Query().Get("revision"))
+		if err != nil {
+			http.Error(w, "revision must be a number", http.StatusBadRequest)
+			return
+		}
+		if _, ok := c.configHistory.get(revision); !ok {
+			http.Error(w, "revision not found", http.StatusNotFound)
+			return
+		}
+
+		log.Infof("Rolling back the config to revision %v", revision)
+		rolledBack, err := c.Rollback(revision)
+		if err != nil {
+			log.WithError(err).Errorf("Failed to roll back the config to revision %v. Keeping the current node groups", revision)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(rolledBack); err != nil {
+			log.WithError(err).Warn("Failed to write config revision")
+		}
+	})
+}
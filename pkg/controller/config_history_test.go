@@ -0,0 +1,144 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/atlassian/escalator/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestDiffConfigs(t *testing.T) {
+	old := Config{
+		ClusterOptions: ClusterOptions{MinCPU: "64"},
+		NodeGroups: []NodeGroupOptions{
+			{Name: "shared", MaxNodes: 10, ScaleUpThresholdPercent: 70, QueueDemand: QueueDemandOptions{Queue: "batch"}},
+			{Name: "old", MaxNodes: 5},
+		},
+	}
+	updated := Config{
+		ClusterOptions: ClusterOptions{MinCPU: "32", QuotaCoordinator: true},
+		NodeGroups: []NodeGroupOptions{
+			{Name: "shared", MaxNodes: 10, ScaleUpThresholdPercent: 95, QueueDemand: QueueDemandOptions{Queue: "other"}},
+			{Name: "gpu", MaxNodes: 2},
+		},
+	}
+	gpu, err := json.Marshal(updated.NodeGroups[1])
+	require.NoError(t, err)
+	oldGroup, err := json.Marshal(old.NodeGroups[1])
+	require.NoError(t, err)
+
+	assert.Equal(t, []ConfigChange{
+		{Path: "cluster_min_cpu", Old: `"64"`, New: `"32"`},
+		{Path: "node_groups.gpu", New: string(gpu)},
+		{Path: "node_groups.old", Old: string(oldGroup)},
+		{Path: "node_groups.shared.queue_demand.queue", Old: `"batch"`, New: `"other"`},
+		{Path: "node_groups.shared.scale_up_threshold_percent", Old: "70", New: "95"},
+		{Path: "quota_coordinator", New: "true"},
+	}, diffConfigs(old, updated))
+
+	assert.Empty(t, diffConfigs(old, old))
+}
+
+func TestConfigHistory(t *testing.T) {
+	now := time.Now()
+	history := configHistory{size: 2}
+	for i := 1; i <= 3; i++ {
+		config := Config{NodeGroups: []NodeGroupOptions{{Name: "shared", MaxNodes: i}}}
+		history.add(config, nil, ConfigSourceReload, 0, now)
+	}
+
+	revisions := history.list()
+	require.Len(t, revisions, 2)
+	assert.Equal(t, 2, revisions[0].Revision)
+	assert.Equal(t, 3, revisions[1].Revision)
+	assert.Equal(t, []ConfigChange{{Path: "node_groups.shared.max_nodes", Old: "2", New: "3"}}, revisions[1].Changes)
+	_, ok := history.get(1)
+	assert.False(t, ok, "the oldest revision is dropped")
+
+	// nothing is kept without a size
+	disabled := configHistory{}
+	disabled.add(Config{}, nil, ConfigSourceStartup, 0, now)
+	assert.Empty(t, disabled.list())
+}
+
+func TestConfigRollbackHandler(t *testing.T) {
+	nodes := []*v1.Node{test.BuildTestNode(test.NodeOpts{Name: "n1", LabelKey: "customer", LabelValue: "shared"})}
+	nodeGroups := []NodeGroupOptions{
+		{Name: "shared", LabelKey: "customer", LabelValue: "shared", CloudProviderGroupName: "shared", ScaleUpCoolDownPeriod: "1m", MaxNodes: 10},
+	}
+	client, opts := buildTestClient(nodes, nil, nodeGroups, ListerOptions{})
+	cloudProvider := test.NewCloudProvider(1)
+	cloudProvider.RegisterNodeGroup(test.NewNodeGroup("shared", 1, 10, 1))
+	builder := testCloudProviderBuilder{cloudProvider}
+
+	stopChan := make(chan struct{})
+	defer close(stopChan)
+	c := &Controller{
+		Client:        client,
+		Opts:          opts,
+		stopChan:      stopChan,
+		nodeGroups:    map[string]*NodeGroupState{"shared": {Opts: nodeGroups[0], NodeGroupLister: client.Listers["shared"]}},
+		reloadChan:    make(chan reloadRequest),
+		configHistory: configHistory{size: 10},
+	}
+	c.recordConfig(Config{NodeGroups: nodeGroups}, builder, ConfigSourceStartup, 0)
+	c.recordConfig(Config{NodeGroups: []NodeGroupOptions{{Name: "shared", MaxNodes: 50}}}, builder, ConfigSourceReload, 0)
+
+	// handles reloads the same as the main loop
+	go func() {
+		for {
+			select {
+			case request := <-c.reloadChan:
+				err := c.reload(request.config, request.cloudProviderBuilder)
+				if err == nil {
+					c.recordConfig(request.config, request.cloudProviderBuilder, request.source, request.rollbackOf)
+				}
+				request.result <- err
+			case <-stopChan:
+				return
+			}
+		}
+	}()
+
+	rollback := func(method string, revision string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		url := fmt.Sprintf("/api/v1/config/rollback?revision=%v", revision)
+		c.ConfigRollbackHandler().ServeHTTP(recorder, httptest.NewRequest(method, url, nil))
+		return recorder
+	}
+
+	assert.Equal(t, http.StatusMethodNotAllowed, rollback(http.MethodGet, "1").Code)
+	assert.Equal(t, http.StatusBadRequest, rollback(http.MethodPost, "latest").Code)
+	assert.Equal(t, http.StatusNotFound, rollback(http.MethodPost, "7").Code)
+
+	recorder := rollback(http.MethodPost, "1")
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	var rolledBack ConfigRevision
+	require.NoError(t, json.NewDecoder(recorder.Body).Decode(&rolledBack))
+	assert.Equal(t, 3, rolledBack.Revision)
+	assert.Equal(t, ConfigSourceRollback, rolledBack.Source)
+	assert.Equal(t, 1, rolledBack.RollbackOf)
+	assert.Equal(t, []ConfigChange{
+		{Path: "node_groups.shared.cloud_provider_group_name", New: `"shared"`},
+		{Path: "node_groups.shared.label_key", New: `"customer"`},
+		{Path: "node_groups.shared.label_value", New: `"shared"`},
+		{Path: "node_groups.shared.max_nodes", Old: "50", New: "10"},
+		{Path: "node_groups.shared.scale_up_cool_down_period", New: `"1m"`},
+	}, rolledBack.Changes)
+	assert.Equal(t, 10, c.nodeGroups["shared"].Opts.MaxNodes)
+
+	// the history is served oldest first
+	recorder = httptest.NewRecorder()
+	c.ConfigHistoryHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/v1/config/history", nil))
+	var history []ConfigRevision
+	require.NoError(t, json.NewDecoder(recorder.Body).Decode(&history))
+	require.Len(t, history, 3)
+	assert.Equal(t, ConfigSourceStartup, history[0].Source)
+}
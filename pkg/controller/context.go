@@ -0,0 +1,93 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// stopContext returns a context that is cancelled when the stop channel is closed
+func stopContext(stopChan <-chan struct{}) context.Context {
+	if stopChan == nil {
+		return context.Background()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-stopChan
+		cancel()
+	}()
+	return ctx
+}
+
+// drainContext returns a context that is cancelled the timeout after the stop channel is closed
+func drainContext(stopChan <-chan struct{}, timeout time.Duration) context.Context {
+	if stopChan == nil {
+		return context.Background()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-stopChan
+		if timeout > 0 {
+			time.Sleep(timeout)
+		}
+		cancel()
+	}()
+	return ctx
+}
+
+// rootContext returns the context that is cancelled when escalator stops
+func (c *Controller) rootContext() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+// cycleContext returns the context of a run. It is cancelled when escalator stops or when the run has taken a whole
+// scan interval, so a hung api call can't block the main loop
+func (c *Controller) cycleContext() (context.Context, context.CancelFunc) {
+	if c.Opts.ScanInterval <= 0 {
+		return context.WithCancel(c.rootContext())
+	}
+	return context.WithTimeout(c.rootContext(), c.Opts.ScanInterval)
+}
+
+// drainingContext returns the context for the api calls of a run, with the same deadline as the run. Unlike the run's
+// context it isn't cancelled as soon as escalator stops: the run doesn't start any further action, but the calls already
+// in flight get the shutdown drain timeout to finish, so a deletion isn't abandoned half way through
+func (c *Controller) drainingContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	parent := c.drainCtx
+	if parent == nil {
+		parent = c.rootContext()
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		return context.WithDeadline(parent, deadline)
+	}
+	return context.WithCancel(parent)
+}
+
+// callContextKey is the key of the context for the api calls of a run, carried by the context of the run
+type callContextKey struct{}
+
+// withCallContext returns the context of the run carrying the context for its api calls, see drainingContext
+func withCallContext(ctx context.Context, callCtx context.Context) context.Context {
+	return context.WithValue(ctx, callContextKey{}, callCtx)
+}
+
+// callContext returns the context for the api calls of the run, which is passed to each cloud provider call. It is the
+// context of the run itself when it doesn't carry one
+func callContext(ctx context.Context) context.Context {
+	if callCtx, ok := ctx.Value(callContextKey{}).(context.Context); ok {
+		return callCtx
+	}
+	return ctx
+}
+
+// contextErr returns an error if the context is done, so no further action of the run is started
+func contextErr(ctx context.Context, action string) error {
+	if ctx.Err() != nil {
+		return errors.Wrapf(ctx.Err(), "not %v", action)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/atlassian/escalator/pkg/errorkind"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStopContext(t *testing.T) {
+	stopChan := make(chan struct{})
+	ctx := stopContext(stopChan)
+	assert.NoError(t, ctx.Err())
+
+	close(stopChan)
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("context wasn't cancelled when the stop channel was closed")
+	}
+	assert.Equal(t, context.Canceled, ctx.Err())
+}
+
+func TestControllerCycleContext(t *testing.T) {
+	c := &Controller{Opts: Opts{ScanInterval: time.Minute}}
+	ctx, cancel := c.cycleContext()
+	defer cancel()
+	deadline, ok := ctx.Deadline()
+	require.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), deadline, time.Second)
+
+	// runs are cancelled when escalator stops
+	stopChan := make(chan struct{})
+	c.ctx = stopContext(stopChan)
+	ctx, cancel = c.cycleContext()
+	defer cancel()
+	close(stopChan)
+	<-ctx.Done()
+	err := contextErr(ctx, "deleting nodes")
+	assert.EqualError(t, err, "not deleting nodes: context canceled")
+	assert.Equal(t, errorkind.Cancelled, errorkind.Of(err))
+}
+
+func TestDrainContext(t *testing.T) {
+	stopChan := make(chan struct{})
+	ctx := drainContext(stopChan, 50*time.Millisecond)
+	assert.NoError(t, ctx.Err())
+
+	// calls in flight get the drain timeout to finish after escalator stops
+	close(stopChan)
+	time.Sleep(10 * time.Millisecond)
+	assert.NoError(t, ctx.Err())
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("context wasn't cancelled after the drain timeout")
+	}
+	assert.Equal(t, context.Canceled, ctx.Err())
+}
+
+func TestControllerDrainingContext(t *testing.T) {
+	stopChan := make(chan struct{})
+	c := &Controller{
+		Opts:     Opts{ScanInterval: time.Minute},
+		ctx:      stopContext(stopChan),
+		drainCtx: drainContext(stopChan, time.Hour),
+	}
+	ctx, cancel := c.cycleContext()
+	defer cancel()
+	callCtx, cancelCalls := c.drainingContext(ctx)
+	defer cancelCalls()
+
+	// the calls have the deadline of the run
+	deadline, ok := callCtx.Deadline()
+	require.True(t, ok)
+	runDeadline, _ := ctx.Deadline()
+	assert.Equal(t, runDeadline, deadline)
+
+	// stopping cancels the run, but not the calls in flight
+	close(stopChan)
+	<-ctx.Done()
+	assert.Error(t, contextErr(ctx, "deleting nodes"))
+	assert.NoError(t, callCtx.Err())
+}
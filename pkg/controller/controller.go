@@ -1,17 +1,23 @@
 package controller
 
 import (
+	"context"
 	"math"
+	"sync"
 	"time"
 
 	"github.com/atlassian/escalator/pkg/cloudprovider"
+	"github.com/atlassian/escalator/pkg/errorkind"
 	"github.com/atlassian/escalator/pkg/k8s"
 	"github.com/atlassian/escalator/pkg/metrics"
+	"github.com/atlassian/escalator/pkg/profiling"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
+	"github.com/stephanos/clock"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/kubernetes/pkg/scheduler/cache"
 )
 
@@ -22,6 +28,49 @@ type Controller struct {
 	stopChan      <-chan struct{}
 	cloudProvider cloudprovider.CloudProvider
 	nodeGroups    map[string]*NodeGroupState
+
+	// cancelled when stopChan is closed, so no further action is started
+	ctx context.Context
+	// cancelled the shutdown drain timeout after stopChan is closed, cancelling any api calls still in flight
+	drainCtx context.Context
+
+	// guards replacing nodeGroups on reload, for readers outside of the main loop
+	nodeGroupsLock sync.RWMutex
+
+	// requests handled between runs by the main loop
+	reloadChan      chan reloadRequest
+	diagnosticsChan chan struct{}
+	snapshotChan    chan chan Snapshot
+	simulationChan  chan simulationRequest
+
+	// the node group each node belonged to on the last run, for detecting label changes
+	nodeMembership map[string]string
+
+	// the scaling decision of each pool in the current run
+	poolPlans map[string]poolPlan
+	// the priorities of the priority_expander ConfigMap, ordering the pools with the priority pool_scale_policy
+	expanderPriorities k8s.ExpanderPriorities
+
+	// shares scarce cloud provider quotas between the node groups when quota_coordinator is set
+	quota *quotaCoordinator
+
+	// rate limits the writes to the cloud provider node groups, nil without limits
+	providerWrites *providerWriteLimiter
+
+	// the scan interval tuned to the state of the node groups after the latest run
+	scanInterval time.Duration
+
+	// when an event was last emitted on a node for each node and reason, and how many node events the current run
+	// emitted
+	nodeEventsEmitted map[string]time.Time
+	nodeEventsThisRun int
+
+	// the latest report, served by the report endpoint
+	reportLock sync.RWMutex
+	report     Report
+
+	// the latest configs applied, served by the config history endpoint
+	configHistory configHistory
 }
 
 // NodeGroupState contains everything about a node group in the current state of the application
@@ -31,16 +80,106 @@ type NodeGroupState struct {
 	NodeInfoMap map[string]*cache.NodeInfo
 	scaleUpLock scaleLock
 
-	// used for tracking which nodes are tainted. testing when in dry mode
-	taintTracker []string
+	// the time from the latest scale ups to their nodes becoming ready
+	bootDurations bootDurations
+
+	// used for tracking which nodes are tainted, and when, in dry mode
+	taintTracker []DryModeTaint
+	// the scale ups and deletions skipped in dry mode, for the simulated node count
+	simulated simulatedNodes
 
 	// used for tracking scale delta across runs, useful for reducing hysteresis
 	scaleDelta   int
 	lastScaleOut time.Time
 
-	// used for storing cached instance capacity
+	// used for tracking the replacement node brought up before recycling an expired node
+	replacementPending    bool
+	replacementReadyNodes int
+
+	// the peak reached by scale ups, for holding the minimum with high_water_mark_hold
+	highWaterMark highWaterMark
+	// the reservations active in the last run
+	activeReservations []ActiveReservation
+
+	// the time the DaemonSet pods of a tainted node were deleted, while waiting for them to flush
+	daemonSetDrains map[string]time.Time
+
+	// the time the pre_delete_hook allows the deletion of a tainted node to be retried, after it delayed it
+	preDeleteHookDelays map[string]time.Time
+
+	// the time the last tainted node that still ran pods was deleted, for eviction_limits.node_interval
+	lastEviction time.Time
+	// the time the last volume heavy tainted node was deleted, for volume_detach.heavy_node_interval
+	lastVolumeHeavyDeletion time.Time
+
+	// the maintenance the cloud provider scheduled for the nodes, for replace_on_maintenance
+	maintenance maintenanceEvents
+
+	// the outdated nodes prioritised for tainting in the last run by name, for version_skew
+	outdatedNodes map[string]bool
+
+	// the disruption scores of the untainted nodes from the last run, lowest first
+	disruptionScores []NodeDisruptionScore
+
+	// the FailedScheduling events of the pods, with --scheduling-failures
+	schedulingFailures schedulingFailures
+
+	// the static pods running on the nodes of the node group by node name, from the last run
+	staticPodNodes map[string][]string
+
+	// the time the hard deletion of tainted nodes was first deferred by a PodDisruptionBudget
+	podDisruptionBudgetDeferrals map[string]time.Time
+
+	// the time the hard deletion of tainted nodes was first deferred because their pods couldn't be rescheduled
+	rescheduleDeferrals map[string]time.Time
+
+	// the time tainted nodes were first seen empty, for soft_delete_grace_period_from empty
+	emptySince map[string]time.Time
+
+	// the failed deletions of tainted nodes, for backing off their next deletion
+	deletionAttempts map[string]*deletionAttempts
+
+	// the nodes whose instances the cloud provider was asked to terminate, until the instances leave the cloud
+	// provider node group
+	pendingTerminations map[string]pendingTermination
+
+	// the follow up run waiting for the result of the last scale action, nil if there isn't one
+	followUp *followUp
+
+	// the scale up whose scaling activities are watched for failures, nil if there isn't one
+	activityWatch *scalingActivityWatch
+
+	// the summary of the current run and the summaries of the latest runs
+	cycle  CycleSummary
+	cycles cycleHistory
+
+	// the max of the cpu and memory utilisation from the last run, for node groups that scale down after this one
+	utilisationPercent float64
+	utilisationKnown   bool
+	// the utilisation of the last runs, newest last, for scale_up_rate
+	utilisationHistory []float64
+
+	// used for storing cached instance capacity, the smallest allocatable of the nodes from the last run with nodes
 	cpuCapacity resource.Quantity
 	memCapacity resource.Quantity
+	// whether the nodes disagreed materially on their allocatable in the last run
+	allocatableHeterogeneous bool
+
+	// the name of the maintenance window open in the last run, empty if none was
+	maintenanceWindow string
+
+	// the capacity of the untainted nodes from the last run, for the cluster wide minimum capacity
+	untaintedCPUCapacity resource.Quantity
+	untaintedMemCapacity resource.Quantity
+
+	// the requests and node counts from the last run, for the utilisation of the pool of the node group
+	cpuRequest         resource.Quantity
+	memRequest         resource.Quantity
+	nodeCount          int
+	untaintedNodeCount int
+
+	// the names of the nodes acted on since the last summary event, by action
+	pendingActions map[string][]string
 }
 
 // Opts provide the Controller with config for runtime
@@ -49,7 +188,52 @@ type Opts struct {
 	NodeGroups           []NodeGroupOptions
 	CloudProviderBuilder cloudprovider.Builder
 	ScanInterval         time.Duration
+	ScanJitter           time.Duration
+	AlignScanInterval    bool
 	DryMode              bool
+	AuditMode            bool
+	Cluster              ClusterOptions
+	// ShutdownDrainTimeout is how long the api calls in flight when escalator stops get to finish before they are
+	// cancelled
+	ShutdownDrainTimeout time.Duration
+	// MinScanInterval and MaxScanInterval bound the scan interval when it is tuned to the state of the node groups.
+	// Either is disabled when 0
+	MinScanInterval time.Duration
+	MaxScanInterval time.Duration
+	// SlowCycleProfiler captures profiles of the runs slower than its threshold when set
+	SlowCycleProfiler *profiling.SlowCycleProfiler
+
+	// guardrail activations and summaries of the scaling actions are emitted as events on the object when both are
+	// set, e.g. the escalator pod
+	EventRecorder record.EventRecorder
+	EventObject   *v1.ObjectReference
+	// EventVerbosity is whether the scaling actions emit no events, a summary per node group or also an event per node
+	EventVerbosity string
+	// EventDedupWindow is how long an event with the same reason isn't emitted again on the same node
+	EventDedupWindow time.Duration
+	// MaxNodeEventsPerRun limits the events emitted on nodes in a run. 0 is unlimited
+	MaxNodeEventsPerRun int
+
+	// the heartbeat lease is renewed at the end of every successful run when the name is set
+	HeartbeatLeaseName      string
+	HeartbeatLeaseNamespace string
+	HeartbeatIdentity       string
+
+	// ConfigHistorySize is how many of the latest applied configs are kept for the config history and rollbacks
+	ConfigHistorySize int
+
+	// ProviderWriteLimits rate limits the resizes of the cloud provider node groups and the terminations of instances
+	ProviderWriteLimits ProviderWriteLimits
+
+	// WatchSchedulingFailures watches the FailedScheduling events of the pods and counts them for each node group
+	WatchSchedulingFailures bool
+	// WatchPodDisruptionBudgets watches the PodDisruptionBudgets and defers the hard deletions of tainted nodes that
+	// would violate them
+	WatchPodDisruptionBudgets bool
+
+	// RunMode restricts the runs to only reaping or only untainting the nodes of the node groups, for one-shot
+	// cleanups. Empty runs the scaling logic as normal
+	RunMode string
 }
 
 // scaleOpts provides options for a scale function
@@ -60,6 +244,12 @@ type scaleOpts struct {
 	untaintedNodes []*v1.Node
 	nodeGroup      *NodeGroupState
 	nodesDelta     int
+	// ctx is cancelled when the run reaches its deadline or escalator stops
+	ctx context.Context
+	// reason is recorded on the instances brought up by a scale up
+	reason string
+	// untaintNewestTainted untaints the most recently tainted nodes first, instead of the newest nodes
+	untaintNewestTainted bool
 }
 
 // NewController creates a new controller with the specified options
@@ -68,7 +258,22 @@ func NewController(opts Opts, stopChan <-chan struct{}) (*Controller, error) {
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to create controller client")
 	}
+	if opts.WatchSchedulingFailures {
+		if err := client.WatchSchedulingFailures(stopChan); err != nil {
+			return nil, errors.Wrap(err, "failed to watch the FailedScheduling events")
+		}
+	}
+	if opts.WatchPodDisruptionBudgets {
+		if err := client.WatchPodDisruptionBudgets(stopChan); err != nil {
+			return nil, errors.Wrap(err, "failed to watch the PodDisruptionBudgets")
+		}
+	}
+	return NewControllerWithClient(opts, client, stopChan)
+}
 
+// NewControllerWithClient creates a new controller with the specified options that lists the pods and nodes with the
+// client, instead of creating a client that watches the cluster
+func NewControllerWithClient(opts Opts, client *Client, stopChan <-chan struct{}) (*Controller, error) {
 	cloud, err := opts.CloudProviderBuilder.Build()
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to create cloudprovider")
@@ -77,17 +282,9 @@ func NewController(opts Opts, stopChan <-chan struct{}) (*Controller, error) {
 	// turn it into a map of name and nodegroupstate for O(1) lookup and data bundling
 	nodegroupMap := make(map[string]*NodeGroupState)
 	for _, nodeGroupOpts := range opts.NodeGroups {
-		cloudProviderNodeGroup, ok := cloud.GetNodeGroup(nodeGroupOpts.CloudProviderGroupName)
-		if !ok {
-			return nil, errors.Errorf("could not find node group \"%v\" on cloud provider", nodeGroupOpts.CloudProviderGroupName)
-		}
-
-		// Set the node group min_nodes and max_nodes options based on the values in the cloud provider
-		if nodeGroupOpts.autoDiscoverMinMaxNodeOptions() {
-			nodeGroupOpts.MinNodes = int(cloudProviderNodeGroup.MinSize())
-			log.Debugf("auto discovered min_nodes = %v for node group %v", nodeGroupOpts.MinNodes, nodeGroupOpts.Name)
-			nodeGroupOpts.MaxNodes = int(cloudProviderNodeGroup.MaxSize())
-			log.Debugf("auto discovered max_nodes = %v for node group %v", nodeGroupOpts.MaxNodes, nodeGroupOpts.Name)
+		nodeGroupOpts, err = discoverNodeGroupOptions(cloud, nodeGroupOpts)
+		if err != nil {
+			return nil, err
 		}
 
 		nodegroupMap[nodeGroupOpts.Name] = &NodeGroupState{
@@ -102,13 +299,58 @@ func NewController(opts Opts, stopChan <-chan struct{}) (*Controller, error) {
 		}
 	}
 
-	return &Controller{
-		Client:        client,
-		Opts:          opts,
-		stopChan:      stopChan,
-		cloudProvider: cloud,
-		nodeGroups:    nodegroupMap,
-	}, nil
+	c := &Controller{
+		Client:          client,
+		Opts:            opts,
+		stopChan:        stopChan,
+		ctx:             stopContext(stopChan),
+		drainCtx:        drainContext(stopChan, opts.ShutdownDrainTimeout),
+		cloudProvider:   cloud,
+		nodeGroups:      nodegroupMap,
+		reloadChan:      make(chan reloadRequest),
+		diagnosticsChan: make(chan struct{}, 1),
+		snapshotChan:    make(chan chan Snapshot),
+		simulationChan:  make(chan simulationRequest),
+		configHistory:   configHistory{size: opts.ConfigHistorySize},
+		providerWrites:  newProviderWriteLimiter(opts.ProviderWriteLimits),
+	}
+	c.recordConfig(Config{ClusterOptions: opts.Cluster, NodeGroups: opts.NodeGroups}, opts.CloudProviderBuilder, ConfigSourceStartup, 0)
+	return c, nil
+}
+
+// getCloudProviderNodeGroup gets the node group from the cloud provider, combining the node groups backing it when
+// there are several. Returns if they all exist or not
+func getCloudProviderNodeGroup(cloud cloudprovider.CloudProvider, nodeGroupOpts NodeGroupOptions) (cloudprovider.NodeGroup, bool) {
+	if len(nodeGroupOpts.CloudProviderGroupNames) == 0 {
+		return cloud.GetNodeGroup(nodeGroupOpts.CloudProviderGroupName)
+	}
+	groups := make([]cloudprovider.NodeGroup, 0, len(nodeGroupOpts.CloudProviderGroupNames))
+	for _, name := range nodeGroupOpts.CloudProviderGroupNames {
+		group, ok := cloud.GetNodeGroup(name)
+		if !ok {
+			return nil, false
+		}
+		groups = append(groups, group)
+	}
+	return cloudprovider.NewGroupedNodeGroup(groups...), true
+}
+
+// discoverNodeGroupOptions checks the node group exists in the cloud provider and sets the min_nodes and max_nodes
+// options from the cloud provider if they aren't configured
+func discoverNodeGroupOptions(cloud cloudprovider.CloudProvider, nodeGroupOpts NodeGroupOptions) (NodeGroupOptions, error) {
+	cloudProviderNodeGroup, ok := getCloudProviderNodeGroup(cloud, nodeGroupOpts)
+	if !ok {
+		return nodeGroupOpts, errors.Errorf("could not find node group \"%v\" on cloud provider", nodeGroupOpts.cloudProviderGroupID())
+	}
+
+	// Set the node group min_nodes and max_nodes options based on the values in the cloud provider
+	if nodeGroupOpts.autoDiscoverMinMaxNodeOptions() {
+		nodeGroupOpts.MinNodes = int(cloudProviderNodeGroup.MinSize())
+		log.Debugf("auto discovered min_nodes = %v for node group %v", nodeGroupOpts.MinNodes, nodeGroupOpts.Name)
+		nodeGroupOpts.MaxNodes = int(cloudProviderNodeGroup.MaxSize())
+		log.Debugf("auto discovered max_nodes = %v for node group %v", nodeGroupOpts.MaxNodes, nodeGroupOpts.Name)
+	}
+	return nodeGroupOpts, nil
 }
 
 // dryMode is a helper that returns the overall drymode result of the controller and nodegroup
@@ -116,33 +358,74 @@ func (c *Controller) dryMode(nodeGroup *NodeGroupState) bool {
 	return c.Opts.DryMode || nodeGroup.Opts.DryMode
 }
 
-// filterNodes separates nodes between tainted and untainted nodes
-func (c *Controller) filterNodes(nodeGroup *NodeGroupState, allNodes []*v1.Node) (untaintedNodes, taintedNodes, cordonedNodes []*v1.Node) {
+// dryTaint is a helper that returns whether tainting and untainting nodes is simulated for the nodegroup
+func (c *Controller) dryTaint(nodeGroup *NodeGroupState) bool {
+	return c.dryMode(nodeGroup) || nodeGroup.Opts.DryTaint
+}
+
+// dryDelete is a helper that returns whether deleting nodes is simulated for the nodegroup
+func (c *Controller) dryDelete(nodeGroup *NodeGroupState) bool {
+	return c.dryMode(nodeGroup) || nodeGroup.Opts.DryDelete
+}
+
+// dryScaleUp is a helper that returns whether increasing the cloud provider node group is simulated for the nodegroup
+func (c *Controller) dryScaleUp(nodeGroup *NodeGroupState) bool {
+	return c.dryMode(nodeGroup) || nodeGroup.Opts.DryScaleUp
+}
+
+// auditMode is a helper that returns the overall audit mode result of the controller and nodegroup
+// in audit mode kubernetes actions are performed but cloud provider deletions are only dry run
+func (c *Controller) auditMode(nodeGroup *NodeGroupState) bool {
+	return c.Opts.AuditMode || nodeGroup.Opts.AuditMode
+}
+
+// scaleDownBlocked returns the first node group in scale_down_after that is above its utilisation threshold.
+// Dependent node groups that haven't been evaluated yet block the scale down until they have been
+func (c *Controller) scaleDownBlocked(nodeGroup *NodeGroupState) (string, bool) {
+	for _, name := range nodeGroup.Opts.ScaleDownAfter {
+		dependent, ok := c.nodeGroups[name]
+		if !ok {
+			continue
+		}
+		threshold := nodeGroup.Opts.ScaleDownAfterThresholdPercent
+		if threshold == 0 {
+			threshold = dependent.Opts.TaintUpperCapacityThresholdPercent
+		}
+		if !dependent.utilisationKnown || dependent.utilisationPercent >= float64(threshold) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// filterNodes separates nodes between tainted and untainted nodes.
+// Nodes being shut down outside of Escalator, e.g. by cloud maintenance, are separated out so they are never counted,
+// tainted or deleted by Escalator
+func (c *Controller) filterNodes(nodeGroup *NodeGroupState, allNodes []*v1.Node) (untaintedNodes, taintedNodes, cordonedNodes, shuttingDownNodes []*v1.Node) {
 	untaintedNodes = make([]*v1.Node, 0, len(allNodes))
 	taintedNodes = make([]*v1.Node, 0, len(allNodes))
 	cordonedNodes = make([]*v1.Node, 0, len(allNodes))
+	shuttingDownNodes = make([]*v1.Node, 0, len(allNodes))
 
 	for _, node := range allNodes {
-		if c.dryMode(nodeGroup) {
-			var contains bool
-			for _, name := range nodeGroup.taintTracker {
-				if node.Name == name {
-					contains = true
-					break
-				}
-			}
-			if !contains {
+		if taint, ok := k8s.GetShutdownTaint(node); ok {
+			log.WithField("nodegroup", nodeGroup.Opts.Name).Debugf("node %v is being shut down with taint %v", node.Name, taint.Key)
+			shuttingDownNodes = append(shuttingDownNodes, node)
+			continue
+		}
+		if c.dryTaint(nodeGroup) {
+			if _, contains := nodeGroup.dryModeTaint(node); !contains {
 				untaintedNodes = append(untaintedNodes, node)
 			} else {
 				taintedNodes = append(taintedNodes, node)
 			}
 		} else {
-			// If the node is Unschedulable (cordoned), separate it out from the tainted/untainted
-			if node.Spec.Unschedulable {
+			// If the node is Unschedulable (cordoned) by someone else, separate it out from the tainted/untainted
+			if node.Spec.Unschedulable && !k8s.CordonedToBeRemoved(node) && !k8s.CordonedForDeletion(node) {
 				cordonedNodes = append(cordonedNodes, node)
 				continue
 			}
-			if _, tainted := k8s.GetToBeRemovedTaint(node); !tainted {
+			if !k8s.MarkedToBeRemoved(node) {
 				untaintedNodes = append(untaintedNodes, node)
 			} else {
 				taintedNodes = append(taintedNodes, node)
@@ -153,8 +436,32 @@ func (c *Controller) filterNodes(nodeGroup *NodeGroupState, allNodes []*v1.Node)
 	return
 }
 
+// filterStartingNodes separates out the nodes that still have one of the startup taints, or that aren't Ready yet and
+// registered less than the grace period ago.
+// All of the nodes are settled when there are no startup taints and the grace period is 0
+func filterStartingNodes(nodes []*v1.Node, startupTaints []string, gracePeriod time.Duration, now time.Time) (settledNodes, startingNodes []*v1.Node) {
+	if len(startupTaints) == 0 && gracePeriod <= 0 {
+		return nodes, nil
+	}
+
+	settledNodes = make([]*v1.Node, 0, len(nodes))
+	for _, node := range nodes {
+		// pods can't be scheduled on the node until its startup taint is removed, however long that takes
+		if _, ok := k8s.GetStartupTaint(node, startupTaints); ok {
+			startingNodes = append(startingNodes, node)
+			continue
+		}
+		if gracePeriod > 0 && !k8s.NodeReady(node) && now.Sub(node.CreationTimestamp.Time) < gracePeriod {
+			startingNodes = append(startingNodes, node)
+			continue
+		}
+		settledNodes = append(settledNodes, node)
+	}
+	return settledNodes, startingNodes
+}
+
 // calculateNewNodeMetrics checks if there are new nodes and calculates metrics
-func (c *Controller) calculateNewNodeMetrics(nodegroup string, nodeGroup *NodeGroupState) {
+func (c *Controller) calculateNewNodeMetrics(ctx context.Context, nodegroup string, nodeGroup *NodeGroupState) {
 	// If we are not locked, we're either init or after a scale event
 	// If the last scale event was a scale out; i.e. nodesDelta > 0
 	// Calculate the k8s registration latency from cloud provider
@@ -170,7 +477,7 @@ func (c *Controller) calculateNewNodeMetrics(nodegroup string, nodeGroup *NodeGr
 			// Check if node registration time newer than last scale out
 			if nodeRegTime.Sub(nodeGroup.lastScaleOut) > 0 {
 				node := nodeInfo.Node()
-				instance, err := c.cloudProvider.GetInstance(node)
+				instance, err := c.cloudProvider.GetInstance(ctx, node)
 				if err != nil {
 					log.Error("Unable to get instance from cloud provider to determine registration lag, skipping ", node.Spec.ProviderID)
 				} else {
@@ -178,6 +485,17 @@ func (c *Controller) calculateNewNodeMetrics(nodegroup string, nodeGroup *NodeGr
 					log.Debugf("Delta between node instantiation time and node registration: %v - %v", key, nodeRegistrationLag)
 					metrics.NodeGroupNodeRegistrationLag.WithLabelValues(nodegroup).Observe(nodeRegistrationLag.Seconds())
 					countNewNodes++
+					if reader, ok := instance.(cloudprovider.ScaleUpMetadataReader); ok {
+						if metadata, ok := reader.ScaleUpMetadata(); ok {
+							log.WithField("nodegroup", nodegroup).Infof(
+								"New node %v was requested by node group %v in run %v with reason %v",
+								key,
+								metadata.NodeGroup,
+								metadata.CycleID,
+								metadata.Reason,
+							)
+						}
+					}
 				}
 			}
 		}
@@ -188,8 +506,41 @@ func (c *Controller) calculateNewNodeMetrics(nodegroup string, nodeGroup *NodeGr
 	}
 }
 
+// filterBestEffortPods returns the pods that aren't in the BestEffort QoS class
+func filterBestEffortPods(pods []*v1.Pod) []*v1.Pod {
+	filtered := make([]*v1.Pod, 0, len(pods))
+	for _, pod := range pods {
+		if k8s.PodQOSClass(pod) != v1.PodQOSBestEffort {
+			filtered = append(filtered, pod)
+		}
+	}
+	return filtered
+}
+
+// updateQOSClassMetrics sets the pod count and requests metrics of the node group for each pod QoS class
+func updateQOSClassMetrics(nodegroup string, pods []*v1.Pod, overheads k8s.RuntimeClassOverheads) {
+	podsByQOSClass := make(map[v1.PodQOSClass][]*v1.Pod, len(k8s.PodQOSClasses))
+	for _, pod := range pods {
+		qosClass := k8s.PodQOSClass(pod)
+		podsByQOSClass[qosClass] = append(podsByQOSClass[qosClass], pod)
+	}
+
+	for _, qosClass := range k8s.PodQOSClasses {
+		memRequest, cpuRequest, _ := k8s.CalculatePodsRequestsTotalWithOverhead(podsByQOSClass[qosClass], overheads)
+		metrics.NodeGroupPodsByQOSClass.WithLabelValues(nodegroup, string(qosClass)).Set(float64(len(podsByQOSClass[qosClass])))
+		metrics.NodeGroupCPURequestByQOSClass.WithLabelValues(nodegroup, string(qosClass)).Set(float64(cpuRequest.MilliValue()))
+		metrics.NodeGroupMemRequestByQOSClass.WithLabelValues(nodegroup, string(qosClass)).Set(float64(memRequest.MilliValue() / 1000))
+	}
+}
+
 // scaleNodeGroup performs the core logic of calculating util and selecting a scaling action for a node group
-func (c *Controller) scaleNodeGroup(nodegroup string, nodeGroup *NodeGroupState) (int, error) {
+func (c *Controller) scaleNodeGroup(ctx context.Context, nodegroup string, nodeGroup *NodeGroupState) (int, error) {
+	now := time.Now()
+	nodeGroup.cycle = CycleSummary{ID: cycleID(now), Time: now, Decision: CycleDecisionSkipped}
+	nodeGroup.disruptionScores = nil
+	// any pending follow up is replaced by this run
+	nodeGroup.followUp = nil
+
 	// list all pods
 	pods, err := nodeGroup.Pods.List()
 	if err != nil {
@@ -197,6 +548,14 @@ func (c *Controller) scaleNodeGroup(nodegroup string, nodeGroup *NodeGroupState)
 		return 0, err
 	}
 
+	c.trackSchedulingFailures(nodeGroup, pods)
+
+	// the pods that count towards the utilisation of the node group
+	scalingPods := pods
+	if nodeGroup.Opts.ExcludeBestEffortPods {
+		scalingPods = filterBestEffortPods(pods)
+	}
+
 	// List all nodes
 	allNodes, err := nodeGroup.Nodes.List()
 	if err != nil {
@@ -204,39 +563,108 @@ func (c *Controller) scaleNodeGroup(nodegroup string, nodeGroup *NodeGroupState)
 		return 0, err
 	}
 
-	// store a cached version of node capacity
-	if len(allNodes) > 0 {
-		nodeGroup.cpuCapacity = *allNodes[0].Status.Allocatable.Cpu()
-		nodeGroup.memCapacity = *allNodes[0].Status.Allocatable.Memory()
+	// the demand waiting in a batch job queue, so capacity arrives before the workloads are admitted and their pods
+	// are created
+	var queueDemand k8s.QueueDemand
+	if len(nodeGroup.Opts.QueueDemand.Provider) > 0 {
+		var queueErr error
+		queueDemand, queueErr = c.queueDemand(ctx, nodeGroup)
+		if queueErr != nil {
+			log.WithField("nodegroup", nodegroup).WithError(queueErr).Warn("Failed to read the batch job queue demand. Scaling on the pods only")
+			queueDemand = k8s.QueueDemand{}
+		}
+		log.WithField("nodegroup", nodegroup).Infof("queued workloads: %v, cpu: %v, memory: %v", queueDemand.Workloads, queueDemand.CPU.String(), queueDemand.Memory.String())
+		metrics.NodeGroupQueuedWorkloads.WithLabelValues(nodegroup).Set(float64(queueDemand.Workloads))
+		metrics.NodeGroupQueuedCPURequest.WithLabelValues(nodegroup).Set(float64(queueDemand.CPU.MilliValue()))
+		metrics.NodeGroupQueuedMemRequest.WithLabelValues(nodegroup).Set(float64(queueDemand.Memory.MilliValue() / 1000))
 	}
 
+	// refresh the node capacity from the current allocatable of the nodes
+	updateAllocatable(nodeGroup, allNodes)
+
 	// Filter into untainted and tainted nodes
-	untaintedNodes, taintedNodes, cordonedNodes := c.filterNodes(nodeGroup, allNodes)
+	expireDryModeTaints(nodeGroup, allNodes)
+	untaintedNodes, taintedNodes, cordonedNodes, shuttingDownNodes := c.filterNodes(nodeGroup, allNodes)
+	// new nodes that are still starting up are neither capacity nor candidates for tainting, but count as registered
+	untaintedNodes, startingNodes := filterStartingNodes(untaintedNodes, nodeGroup.Opts.StartupTaintKeys(), nodeGroup.Opts.NewNodeGracePeriodDuration(), now)
+	// nodes tainted by other systems are treated by the third_party_taints policy of the node group
+	untaintedNodes, thirdPartyTaintedNodes := filterThirdPartyTaintedNodes(nodeGroup, untaintedNodes)
+	// nodes with a spot interruption notice are about to be reclaimed, so they are replaced before anything else
+	untaintedNodes, interruptedNodes := filterSpotInterruptedNodes(nodeGroup, untaintedNodes)
+	if err := c.detectStaticPodNodes(nodeGroup, allNodes); err != nil {
+		log.Errorf("Failed to list pods: %v", err)
+		return 0, err
+	}
+	trackBootDurations(nodeGroup, allNodes, now)
+	nodeGroup.cycle.Pods = len(pods)
+	nodeGroup.cycle.Nodes = len(allNodes)
+	nodeGroup.cycle.UntaintedNodes = len(untaintedNodes)
+	nodeGroup.cycle.TaintedNodes = len(taintedNodes)
+	nodeGroup.cycle.CordonedNodes = len(cordonedNodes)
+	nodeGroup.cycle.ShuttingDownNodes = len(shuttingDownNodes)
+	nodeGroup.cycle.StartingNodes = len(startingNodes)
 
 	// Metrics and Logs
 	log.WithField("nodegroup", nodegroup).Infof("pods total: %v", len(pods))
+	if len(scalingPods) < len(pods) {
+		log.WithField("nodegroup", nodegroup).Infof("best effort pods excluded: %v", len(pods)-len(scalingPods))
+	}
 	log.WithField("nodegroup", nodegroup).Infof("nodes remaining total: %v", len(allNodes))
 	log.WithField("nodegroup", nodegroup).Infof("cordoned nodes remaining total: %v", len(cordonedNodes))
+	log.WithField("nodegroup", nodegroup).Infof("nodes being shut down: %v", len(shuttingDownNodes))
+	log.WithField("nodegroup", nodegroup).Infof("new nodes not ready yet: %v", len(startingNodes))
+	if len(interruptedNodes) > 0 {
+		log.WithField("nodegroup", nodegroup).Infof("nodes with a spot interruption notice: %v", len(interruptedNodes))
+	}
+	if len(thirdPartyTaintedNodes) > 0 {
+		log.WithField("nodegroup", nodegroup).Infof("nodes with a third party taint: %v, policy: %v", len(thirdPartyTaintedNodes), nodeGroup.Opts.ThirdPartyTaints.policy())
+	}
 	log.WithField("nodegroup", nodegroup).Infof("nodes remaining untainted: %v", len(untaintedNodes))
 	log.WithField("nodegroup", nodegroup).Infof("nodes remaining tainted: %v", len(taintedNodes))
 	log.WithField("nodegroup", nodegroup).Infof("Minimum Node: %v", nodeGroup.Opts.MinNodes)
 	log.WithField("nodegroup", nodegroup).Infof("Maximum Node: %v", nodeGroup.Opts.MaxNodes)
+	heldMin := heldMinNodes(nodeGroup, clock.Now())
+	if heldMin > 0 {
+		log.WithField("nodegroup", nodegroup).Infof("Held Minimum Node: %v", heldMin)
+	}
+	metrics.NodeGroupHeldMinNodes.WithLabelValues(nodegroup).Set(float64(heldMin))
+	updateReservations(nodeGroup, clock.Now())
+	if reserved := reservedNodes(nodeGroup, clock.Now()); reserved > 0 {
+		log.WithField("nodegroup", nodegroup).Infof("Reserved Node: %v", reserved)
+	}
 	metrics.NodeGroupNodes.WithLabelValues(nodegroup).Set(float64(len(allNodes)))
+	c.resetSimulatedNodes(nodeGroup, allNodes)
 	metrics.NodeGroupNodesCordoned.WithLabelValues(nodegroup).Set(float64(len(cordonedNodes)))
+	metrics.NodeGroupNodesShuttingDown.WithLabelValues(nodegroup).Set(float64(len(shuttingDownNodes)))
+	metrics.NodeGroupNodesCordonedForDeletion.WithLabelValues(nodegroup).Set(float64(countCordonedForDeletion(allNodes)))
+	metrics.NodeGroupNodesStarting.WithLabelValues(nodegroup).Set(float64(len(startingNodes)))
+	metrics.NodeGroupNodesThirdPartyTainted.WithLabelValues(nodegroup).Set(float64(len(thirdPartyTaintedNodes)))
 	metrics.NodeGroupNodesUntainted.WithLabelValues(nodegroup).Set(float64(len(untaintedNodes)))
 	metrics.NodeGroupNodesTainted.WithLabelValues(nodegroup).Set(float64(len(taintedNodes)))
 	metrics.NodeGroupPods.WithLabelValues(nodegroup).Set(float64(len(pods)))
+	overheads := c.Opts.Cluster.runtimeClassOverheads()
+	updateQOSClassMetrics(nodegroup, pods, overheads)
 
 	// We want to be really simple right now so we don't do anything if we are outside the range of allowed nodes
 	// We assume it is a config error or something bad has gone wrong in the cluster
 
-	if len(allNodes) == 0 && len(pods) == 0 {
+	// pooled node groups carry on, as they may take a share of the scale up of their pool
+	if len(allNodes) == 0 && len(scalingPods) == 0 && queueDemand.Workloads == 0 && len(nodeGroup.Opts.Pool) == 0 {
 		log.WithField("nodegroup", nodegroup).Info("no pods requests and remain 0 node for node group")
+		nodeGroup.utilisationPercent = 0
+		nodeGroup.utilisationKnown = true
+		nodeGroup.untaintedCPUCapacity = resource.Quantity{}
+		nodeGroup.untaintedMemCapacity = resource.Quantity{}
+		nodeGroup.cpuRequest = resource.Quantity{}
+		nodeGroup.memRequest = resource.Quantity{}
+		nodeGroup.nodeCount = 0
+		nodeGroup.untaintedNodeCount = 0
+		nodeGroup.cycle.Decision = CycleDecisionNone
 		return 0, nil
 	}
 
 	if len(allNodes) < nodeGroup.Opts.MinNodes {
-		err = errors.New("node count less than the minimum")
+		err = errorkind.New(errorkind.Limit, "node count less than the minimum")
 		log.WithField("nodegroup", nodegroup).Warningf(
 			"Node count of %v less than minimum of %v",
 			len(allNodes),
@@ -245,7 +673,7 @@ func (c *Controller) scaleNodeGroup(nodegroup string, nodeGroup *NodeGroupState)
 		return 0, err
 	}
 	if len(allNodes) > nodeGroup.Opts.MaxNodes {
-		err = errors.New("node count larger than the maximum")
+		err = errorkind.New(errorkind.Limit, "node count larger than the maximum")
 		log.WithField("nodegroup", nodegroup).Warningf(
 			"Node count of %v larger than maximum of %v",
 			len(allNodes),
@@ -257,19 +685,53 @@ func (c *Controller) scaleNodeGroup(nodegroup string, nodeGroup *NodeGroupState)
 	// update the map of node to nodeinfo
 	// for working out which pods are on which nodes
 	nodeGroup.NodeInfoMap = k8s.CreateNodeNameToInfoMap(pods, allNodes)
+	nodeGroup.disruptionScores = nodeDisruptionScores(nodeGroup, untaintedNodes, now)
+	c.pollMaintenanceEvents(callContext(ctx), nodeGroup, untaintedNodes, now)
+	updateOutdatedNodes(nodeGroup, allNodes, untaintedNodes)
+
+	// runs restricted to a cleanup don't make a scaling decision
+	if c.cleanupOnly() {
+		return c.runCleanup(scaleOpts{
+			ctx:            ctx,
+			nodes:          allNodes,
+			taintedNodes:   taintedNodes,
+			untaintedNodes: untaintedNodes,
+			nodeGroup:      nodeGroup,
+		}, now)
+	}
+
+	// the interrupted nodes are tainted and replaced straight away, the capacity is recalculated the next run
+	if len(interruptedNodes) > 0 {
+		return c.handleSpotInterruptions(scaleOpts{
+			ctx:            ctx,
+			nodes:          allNodes,
+			taintedNodes:   taintedNodes,
+			untaintedNodes: untaintedNodes,
+			nodeGroup:      nodeGroup,
+		}, interruptedNodes)
+	}
 
 	// Calc capacity for untainted nodes
-	memRequest, cpuRequest, err := k8s.CalculatePodsRequestsTotal(pods)
+	memRequest, cpuRequest, err := k8s.CalculatePodsRequestsTotalWithOverhead(scalingPods, overheads)
 	if err != nil {
 		log.Errorf("Failed to calculate requests: %v", err)
 		return 0, err
 	}
 
+	cpuRequest.Add(queueDemand.CPU)
+	memRequest.Add(queueDemand.Memory)
+
 	memCapacity, cpuCapacity, err := k8s.CalculateNodesCapacityTotal(untaintedNodes)
 	if err != nil {
 		log.Errorf("Failed to calculate capacity: %v", err)
 		return 0, err
 	}
+	nodeGroup.untaintedCPUCapacity = cpuCapacity
+	nodeGroup.untaintedMemCapacity = memCapacity
+	nodeGroup.cpuRequest = cpuRequest
+	nodeGroup.memRequest = memRequest
+	nodeGroup.nodeCount = len(allNodes)
+	nodeGroup.untaintedNodeCount = len(untaintedNodes)
 
 	// Metrics
 	metrics.NodeGroupCPURequest.WithLabelValues(nodegroup).Set(float64(cpuRequest.MilliValue()))
@@ -278,16 +740,37 @@ func (c *Controller) scaleNodeGroup(nodegroup string, nodeGroup *NodeGroupState)
 	metrics.NodeGroupMemRequest.WithLabelValues(nodegroup).Set(float64(memRequest.MilliValue() / 1000))
 
 	// If we ever get into a state where we have less nodes than the minimum
-	if len(untaintedNodes) < nodeGroup.Opts.MinNodes {
+	// nodes that are still starting count towards the minimum, so they aren't replaced while they start
+	belowMinimum := len(untaintedNodes)+len(startingNodes) < nodeGroup.Opts.MinNodes
+	if belowMinimum && !nodeGroup.Opts.scaleUpEnabled() {
+		log.WithField("nodegroup", nodegroup).Warn("There are less untainted nodes than the minimum, but scale_up_enabled is false")
+	}
+	if belowMinimum && nodeGroup.Opts.scaleUpEnabled() {
 		log.WithField("nodegroup", nodegroup).Warn("There are less untainted nodes than the minimum")
+		if nodeGroup.Opts.ObserveOnly {
+			return observeDecision(nodeGroup, CycleDecisionScaleToMinimum, nodeGroup.Opts.MinNodes-len(untaintedNodes)-len(startingNodes)), nil
+		}
+		nodeGroup.cycle.Decision = CycleDecisionScaleToMinimum
+		// e.g. after min_nodes was raised, the tainted nodes are put back to use before the cloud provider is scaled up
+		if len(taintedNodes) > 0 {
+			log.WithField("nodegroup", nodegroup).Infof("Untainting the most recently tainted of %v tainted nodes to reach the minimum", len(taintedNodes))
+		}
 		result, err := c.ScaleUp(scaleOpts{
-			nodes:      allNodes,
-			nodesDelta: nodeGroup.Opts.MinNodes - len(untaintedNodes),
-			nodeGroup:  nodeGroup,
+			ctx:                  ctx,
+			nodes:                allNodes,
+			taintedNodes:         taintedNodes,
+			untaintedNodes:       untaintedNodes,
+			nodesDelta:           nodeGroup.Opts.MinNodes - len(untaintedNodes) - len(startingNodes),
+			nodeGroup:            nodeGroup,
+			reason:               CycleDecisionScaleToMinimum,
+			untaintNewestTainted: true,
 		})
 		if err != nil {
 			log.WithField("nodegroup", nodegroup).Error(err)
+		} else {
+			c.scheduleFollowUp(nodeGroup, len(untaintedNodes), len(taintedNodes), result)
 		}
+		nodeGroup.cycle.NodesDeltaResult = result
 		return result, err
 	}
 
@@ -299,9 +782,17 @@ func (c *Controller) scaleNodeGroup(nodegroup string, nodeGroup *NodeGroupState)
 		log.Errorf("Failed to calculate percentages: %v", err)
 		return 0, err
 	}
+	// scale on the highest utilisation of the node group and its resource profiles
+	cpuPercent, memPercent, err = applyResourceProfiles(nodeGroup, scalingPods, overheads, cpuCapacity, memCapacity, cpuPercent, memPercent)
+	if err != nil {
+		log.Errorf("Failed to calculate resource profile percentages: %v", err)
+		return 0, err
+	}
 
 	// Metrics
 	log.WithField("nodegroup", nodegroup).Infof("cpu: %v, memory: %v", cpuPercent, memPercent)
+	nodeGroup.cycle.CPUPercent = cpuPercent
+	nodeGroup.cycle.MemPercent = memPercent
 
 	// on the case that we're scaling up from 0, emit 0 as the metrics to keep metrics sane
 	if cpuPercent == math.MaxFloat64 || memPercent == math.MaxFloat64 {
@@ -312,29 +803,56 @@ func (c *Controller) scaleNodeGroup(nodegroup string, nodeGroup *NodeGroupState)
 		metrics.NodeGroupsMemPercent.WithLabelValues(nodegroup).Set(memPercent)
 	}
 
+	// the utilisation is kept current while locked, for the dependent node groups and the scan interval
+	maxPercent := math.Max(cpuPercent, memPercent)
+	nodeGroup.utilisationPercent = maxPercent
+	nodeGroup.utilisationKnown = true
+
 	locked := nodeGroup.scaleUpLock.locked()
 	if locked {
 		// don't do anything else until we're unlocked again
 		log.WithField("nodegroup", nodegroup).Info(nodeGroup.scaleUpLock)
 		log.WithField("nodegroup", nodegroup).Info("Waiting for scale to finish")
+		c.recordGuardrail(nodeGroup, GuardrailScaleUpCoolDown, "waiting for %v requested nodes and the scale up cool down period", nodeGroup.scaleUpLock.requestedNodes)
+		nodeGroup.cycle.Decision = CycleDecisionLocked
 		return nodeGroup.scaleUpLock.requestedNodes, nil
 	}
 
-	c.calculateNewNodeMetrics(nodegroup, nodeGroup)
+	c.calculateNewNodeMetrics(callContext(ctx), nodegroup, nodeGroup)
 
 	// Perform the scaling decision
-	maxPercent := math.Max(cpuPercent, memPercent)
+	utilisationRise := nodeGroup.utilisationRise(maxPercent)
+	nodeGroup.recordUtilisation(maxPercent)
 	nodesDelta := 0
 
 	// Determine if we want to scale up or down. Selects the first condition that is true
 	switch {
+	// --- Pooled node groups ---
+	// scale on the utilisation of the whole pool, taking the node group's share of the pool's delta
+	case len(nodeGroup.Opts.Pool) > 0:
+		nodesDelta, err = c.poolNodesDelta(nodeGroup)
+		if err != nil {
+			log.Errorf("Failed to calculate the pool delta: %v", err)
+			return 0, err
+		}
+	// --- Scale Up on the rate of change ---
+	// the utilisation is rising fast, e.g. a large batch was submitted, so scale up before the threshold is crossed
+	case nodeGroup.Opts.ScaleUpRate.enabled() && maxPercent <= float64(nodeGroup.Opts.ScaleUpThresholdPercent) &&
+		utilisationRise >= nodeGroup.Opts.ScaleUpRate.IncreasePercent:
+		nodesDelta = calcScaleUpRateDelta(len(untaintedNodes), maxPercent, utilisationRise, nodeGroup.Opts.ScaleUpThresholdPercent)
+		log.WithField("nodegroup", nodegroup).Infof("Utilisation rose by %.2f%% over the last %v runs, scaling up by %v nodes",
+			utilisationRise, nodeGroup.Opts.ScaleUpRate.cycles(), nodesDelta)
+		nodeGroup.cycle.ScaleUpRateTriggered = true
+		if nodesDelta < nodeGroup.Opts.ScaleUpCount {
+			nodesDelta = nodeGroup.Opts.ScaleUpCount
+		}
 	// --- Scale Down conditions ---
 	// reached very low %. aggressively remove nodes
 	case maxPercent < float64(nodeGroup.Opts.TaintLowerCapacityThresholdPercent):
-		nodesDelta = -nodeGroup.Opts.FastNodeRemovalRate
+		nodesDelta = -calcScaleDownDelta(nodeGroup.Opts.FastNodeRemovalRate, nodeGroup.Opts.ScaleDownCount, len(untaintedNodes))
 	// reached medium low %. slowly remove nodes
 	case maxPercent < float64(nodeGroup.Opts.TaintUpperCapacityThresholdPercent):
-		nodesDelta = -nodeGroup.Opts.SlowNodeRemovalRate
+		nodesDelta = -calcScaleDownDelta(nodeGroup.Opts.SlowNodeRemovalRate, nodeGroup.Opts.ScaleDownCount, len(untaintedNodes))
 	// --- Scale Up conditions ---
 	// Need to scale up so capacity can handle requests
 	case maxPercent > float64(nodeGroup.Opts.ScaleUpThresholdPercent):
@@ -346,11 +864,56 @@ func (c *Controller) scaleNodeGroup(nodegroup string, nodeGroup *NodeGroupState)
 			log.Errorf("Failed to calculate node delta: %v", err)
 			return nodesDelta, err
 		}
+		// small node groups round badly with percentages, so allow a minimum absolute delta
+		if nodesDelta < nodeGroup.Opts.ScaleUpCount {
+			log.WithField("nodegroup", nodegroup).Debugf("increasing delta from %v to scale_up_count %v", nodesDelta, nodeGroup.Opts.ScaleUpCount)
+			nodesDelta = nodeGroup.Opts.ScaleUpCount
+		}
+	}
+
+	// don't shrink the node group while the node groups that depend on it are still busy
+	blockingNodeGroup, scaleDownBlocked := c.scaleDownBlocked(nodeGroup)
+	if scaleDownBlocked && nodesDelta < 0 {
+		log.WithField("nodegroup", nodegroup).Infof("Not scaling down while dependent node group %v is busy", blockingNodeGroup)
+		nodesDelta = 0
+	}
+
+	// don't shrink the node group while a maintenance window is open
+	window, inMaintenanceWindow := c.Opts.Cluster.activeMaintenanceWindow(nodegroup, now)
+	updateMaintenanceWindow(nodeGroup, window, inMaintenanceWindow)
+	if inMaintenanceWindow && nodesDelta < 0 {
+		c.recordGuardrail(nodeGroup, GuardrailMaintenanceWindow, "not scaling down by %v nodes during maintenance window %v", -nodesDelta, window.Name)
+		nodesDelta = 0
+	}
+
+	// don't shrink the cluster below the cluster wide minimum capacity
+	nodesDelta = c.limitScaleDownToClusterFloor(nodeGroup, nodesDelta)
+
+	// node groups can be limited to only growing or only shrinking
+	if nodesDelta > 0 && !nodeGroup.Opts.scaleUpEnabled() {
+		log.WithField("nodegroup", nodegroup).Infof("Not scaling up by %v nodes as scale_up_enabled is false", nodesDelta)
+		nodesDelta = 0
+	}
+	if nodesDelta < 0 && !nodeGroup.Opts.scaleDownEnabled() {
+		log.WithField("nodegroup", nodegroup).Infof("Not scaling down by %v nodes as scale_down_enabled is false", -nodesDelta)
+		nodesDelta = 0
 	}
 
 	log.WithField("nodegroup", nodegroup).Debugf("Delta: %v", nodesDelta)
 
+	if nodeGroup.Opts.ObserveOnly {
+		switch {
+		case nodesDelta < 0:
+			return observeDecision(nodeGroup, CycleDecisionScaleDown, nodesDelta), nil
+		case nodesDelta > 0:
+			return observeDecision(nodeGroup, CycleDecisionScaleUp, nodesDelta), nil
+		default:
+			return observeDecision(nodeGroup, CycleDecisionNone, nodesDelta), nil
+		}
+	}
+
 	scaleOptions := scaleOpts{
+		ctx:            ctx,
 		nodes:          allNodes,
 		taintedNodes:   taintedNodes,
 		untaintedNodes: untaintedNodes,
@@ -366,21 +929,70 @@ func (c *Controller) scaleNodeGroup(nodegroup string, nodeGroup *NodeGroupState)
 	case nodesDelta < 0:
 		// Try to scale down
 		scaleOptions.nodesDelta = -nodesDelta
+		nodeGroup.cycle.Decision = CycleDecisionScaleDown
 		nodesDeltaResult, actionErr = c.ScaleDown(scaleOptions)
+		removeUntaintedCapacity(nodeGroup, nodesDeltaResult)
+		// scaling changes the nodes, so any replacement for recycling is no longer needed
+		nodeGroup.replacementPending = false
 	case nodesDelta > 0:
 		// Try to scale up
 		scaleOptions.nodesDelta = nodesDelta
+		scaleOptions.reason = CycleDecisionScaleUp
+		nodeGroup.cycle.Decision = CycleDecisionScaleUp
 		nodesDeltaResult, actionErr = c.ScaleUp(scaleOptions)
 		nodeGroup.lastScaleOut = time.Now()
+		if actionErr == nil && nodesDeltaResult > 0 {
+			recordHighWaterMark(nodeGroup, len(untaintedNodes)+nodesDeltaResult, clock.Now())
+			// the new nodes lower the utilisation, so its rise is measured from the next run
+			nodeGroup.utilisationHistory = nil
+		}
+		nodeGroup.replacementPending = false
 	default:
 		log.WithField("nodegroup", nodegroup).Info("No need to scale")
-		// reap any expired nodes
-		var removed int
-		removed, actionErr = c.TryRemoveTaintedNodes(scaleOptions)
-		log.WithField("nodegroup", nodegroup).Infof("Reaper: There were %v empty nodes deleted this round", removed)
+		nodeGroup.cycle.Decision = CycleDecisionNone
+		// reap any expired nodes, unless a dependent node group is blocking the node group from shrinking
+		if inMaintenanceWindow {
+			log.WithField("nodegroup", nodegroup).Infof("Reaper: not deleting nodes during maintenance window %v", window.Name)
+			if window.UntaintNodes && len(taintedNodes) > 0 {
+				nodesDeltaResult = c.untaintMaintenanceWindowNodes(scaleOptions, window)
+			}
+		} else if scaleDownBlocked {
+			log.WithField("nodegroup", nodegroup).Infof("Reaper: not deleting nodes while dependent node group %v is busy", blockingNodeGroup)
+		} else if !nodeGroup.Opts.scaleDownEnabled() {
+			log.WithField("nodegroup", nodegroup).Info("Reaper: not deleting nodes as scale_down_enabled is false")
+		} else {
+			var removed int
+			removed, actionErr = c.TryRemoveTaintedNodes(scaleOptions)
+			log.WithField("nodegroup", nodegroup).Infof("Reaper: There were %v empty nodes deleted this round", removed)
+			nodeGroup.cycle.NodesDeleted = removed
+		}
+
+		// replace any nodes with scheduled maintenance, then any nodes older than the max node age. Nodes aren't
+		// tainted for replacement until the maintenance window closes, nor while the node group can't scale down
+		if inMaintenanceWindow {
+			log.WithField("nodegroup", nodegroup).Debugf("Not replacing nodes during maintenance window %v", window.Name)
+		} else if scaleDownBlocked {
+			log.WithField("nodegroup", nodegroup).Debugf("Not replacing nodes while dependent node group %v is busy", blockingNodeGroup)
+		} else if !nodeGroup.Opts.scaleDownEnabled() {
+			log.WithField("nodegroup", nodegroup).Debug("Not replacing nodes as scale_down_enabled is false")
+		} else if actionErr == nil && len(maintenanceNodes(nodeGroup, untaintedNodes)) > 0 {
+			nodesDeltaResult, actionErr = c.replaceMaintenanceNodes(scaleOptions)
+		} else if actionErr == nil && nodeGroup.Opts.MaxNodeAgeDuration() > 0 {
+			nodesDeltaResult, actionErr = c.recycleExpiredNodes(scaleOptions)
+		}
 	}
 
+	nodeGroup.cycle.NodesDeltaResult = nodesDeltaResult
+	if actionErr == nil && nodesDelta != 0 {
+		if nodesDelta < 0 {
+			c.scheduleFollowUp(nodeGroup, len(untaintedNodes), len(taintedNodes), -nodesDeltaResult)
+		} else {
+			c.scheduleFollowUp(nodeGroup, len(untaintedNodes), len(taintedNodes), nodesDeltaResult)
+		}
+	}
 	if actionErr != nil {
+		nodeGroup.cycle.Error = actionErr.Error()
+		nodeGroup.cycle.ErrorKind = errorkind.Of(actionErr)
 		switch actionErr.(type) {
 		// early return when node is NOT in expected node group
 		case *cloudprovider.NodeNotInNodeGroup:
@@ -397,57 +1009,97 @@ func (c *Controller) scaleNodeGroup(nodegroup string, nodeGroup *NodeGroupState)
 // RunOnce performs the main autoscaler logic once
 func (c *Controller) RunOnce() error {
 	startTime := time.Now()
+	defer c.Opts.SlowCycleProfiler.Begin(startTime)()
+	ctx, cancel := c.cycleContext()
+	defer cancel()
+	callCtx, cancelCalls := c.drainingContext(ctx)
+	defer cancelCalls()
+	ctx = withCallContext(ctx, callCtx)
 
 	// try refresh cred a few times if they go stale
 	// rebuild will create a new session from the metadata on the box
-	err := c.cloudProvider.Refresh()
+	err := c.cloudProvider.Refresh(callCtx)
 	for i := 0; i < 2 && err != nil; i++ {
+		// new credentials don't help when the cloud provider is rate limiting. use the node groups from the last refresh
+		if errorkind.Is(err, errorkind.Throttled) {
+			log.WithError(err).Warn("cloud provider refresh was throttled. not re-fetching credentials")
+			break
+		}
 		log.Warnf("cloud provider failed to refresh. trying to re-fetch credentials. tries = %v", i+1)
 		time.Sleep(5 * time.Second) // sleep to allow kube2iam to fill node with metadata
 		c.cloudProvider, err = c.Opts.CloudProviderBuilder.Build()
 		if err != nil {
 			return err
 		}
-		err = c.cloudProvider.Refresh()
+		err = c.cloudProvider.Refresh(callCtx)
 	}
+	// Clean up nodes that have moved between node groups before they are evaluated by their new node group
+	c.updateNodeGroupMembership()
+
 	// Perform the ScaleUp/Taint logic
+	c.refreshExpanderPriorities()
+	c.resetPoolPlans()
+	c.resetNodeEvents(startTime)
+	c.quotaCoordinator().beginRun()
 	for _, nodeGroupOpts := range c.Opts.NodeGroups {
-		log.Debugf("**********[START NODEGROUP %v]**********", nodeGroupOpts.Name)
-		state := c.nodeGroups[nodeGroupOpts.Name]
-		// Double check if node group still exists from the cloud provider then retrieve the latest stat
-		cloudProviderNodeGroup, ok := c.cloudProvider.GetNodeGroup(nodeGroupOpts.CloudProviderGroupName)
-		if !ok {
-			err = errors.New("could not find node group")
-			return err
-		}
-		// Update the min_nodes and max_nodes based on the latest value from the cloud provider
-		if nodeGroupOpts.autoDiscoverMinMaxNodeOptions() {
-			state.Opts.MinNodes = int(cloudProviderNodeGroup.MinSize())
-			log.Debugf("auto discovered min_nodes = %v for node group %v", state.Opts.MinNodes, nodeGroupOpts.Name)
-			state.Opts.MaxNodes = int(cloudProviderNodeGroup.MaxSize())
-			log.Debugf("auto discovered max_nodes = %v for node group %v", state.Opts.MaxNodes, nodeGroupOpts.Name)
+		if err := contextErr(ctx, "running the remaining node groups"); err != nil {
+			log.WithError(err).Warn("Run was cancelled")
+			break
 		}
-		delta, err := c.scaleNodeGroup(nodeGroupOpts.Name, state)
-		metrics.NodeGroupScaleDelta.WithLabelValues(nodeGroupOpts.Name).Set(float64(delta))
-		state.scaleDelta = delta
-		if err != nil {
-			switch err.(type) {
-			// return error which will cause app erroring out
-			case *cloudprovider.NodeNotInNodeGroup:
-				return err
-			default:
-				log.Warn(err)
-			}
-
+		if err := c.runNodeGroup(ctx, nodeGroupOpts); err != nil {
+			return err
 		}
 	}
 
+	c.updateDiscoveryHealth()
+	c.updateStuckDeletions()
+	c.updateDisruptionScores()
+	c.updateSchedulingFailures()
+	c.updateActiveReservations()
+
 	metrics.RunCount.Add(1)
 	endTime := time.Now()
+	c.heartbeat(endTime)
 	log.Debugf("Scaling took a total of %v", endTime.Sub(startTime))
 	return nil
 }
 
+// runNodeGroup runs the scaling logic of the node group once. Only errors that should stop escalator are returned
+func (c *Controller) runNodeGroup(ctx context.Context, nodeGroupOpts NodeGroupOptions) error {
+	log.Debugf("**********[START NODEGROUP %v]**********", nodeGroupOpts.Name)
+	state := c.nodeGroups[nodeGroupOpts.Name]
+	// Double check if node group still exists from the cloud provider then retrieve the latest stat
+	cloudProviderNodeGroup, ok := getCloudProviderNodeGroup(c.cloudProvider, nodeGroupOpts)
+	if !ok {
+		return errorkind.New(errorkind.NotFound, "could not find node group")
+	}
+	// Update the min_nodes and max_nodes based on the latest value from the cloud provider
+	if nodeGroupOpts.autoDiscoverMinMaxNodeOptions() {
+		state.Opts.MinNodes = int(cloudProviderNodeGroup.MinSize())
+		log.Debugf("auto discovered min_nodes = %v for node group %v", state.Opts.MinNodes, nodeGroupOpts.Name)
+		state.Opts.MaxNodes = int(cloudProviderNodeGroup.MaxSize())
+		log.Debugf("auto discovered max_nodes = %v for node group %v", state.Opts.MaxNodes, nodeGroupOpts.Name)
+	}
+	// the demand of the node group is recorded again if it still scales up against a quota
+	c.quotaCoordinator().forget(nodeGroupOpts.Name)
+	delta, err := c.scaleNodeGroup(ctx, nodeGroupOpts.Name, state)
+	c.flushActionEvents(state)
+	c.checkScalingActivities(callContext(ctx), state, time.Now())
+	c.recordCycle(state, delta, err)
+	metrics.NodeGroupScaleDelta.WithLabelValues(nodeGroupOpts.Name).Set(float64(delta))
+	state.scaleDelta = delta
+	if err != nil {
+		switch err.(type) {
+		// return error which will cause app erroring out
+		case *cloudprovider.NodeNotInNodeGroup:
+			return err
+		default:
+			log.Warn(err)
+		}
+	}
+	return nil
+}
+
 // RunForever starts the autoscaler process and runs once every ScanInterval. blocks thread
 // it always returns a non-nil error
 func (c *Controller) RunForever(runImmediately bool) error {
@@ -459,19 +1111,50 @@ func (c *Controller) RunForever(runImmediately bool) error {
 		}
 	}
 
-	// Start the main loop
-	ticker := time.NewTicker(c.Opts.ScanInterval)
+	// Start the main loop. a timer is used instead of a ticker so each run gets its own jitter and the tuned interval.
+	// scheduled is when the timer was due to fire, which keeps the runs at a fixed rate without jitter or alignment
+	scheduled := time.Now()
+	delay := calcNextScanDelay(scheduled, time.Time{}, c.tuneScanInterval(), c.Opts.ScanJitter, c.Opts.AlignScanInterval)
+	scheduled = scheduled.Add(delay)
+	timer := time.NewTimer(delay)
+	followUpTicker := time.NewTicker(followUpPollInterval)
+	defer followUpTicker.Stop()
 	for {
 		select {
-		case <-ticker.C:
+		case <-timer.C:
 			log.Debug("**********[AUTOSCALER MAIN LOOP]**********")
 			err := c.RunOnce()
 			if err != nil {
 				return err
 			}
+			now := time.Now()
+			delay := calcNextScanDelay(now, scheduled, c.tuneScanInterval(), c.Opts.ScanJitter, c.Opts.AlignScanInterval)
+			scheduled = now.Add(delay)
+			timer.Reset(delay)
+		case <-followUpTicker.C:
+			if err := c.runFollowUps(time.Now()); err != nil {
+				return err
+			}
+		case node := <-c.nodeAddedEvents():
+			if err := c.handleNodeAdded(node, time.Now()); err != nil {
+				return err
+			}
+		case request := <-c.reloadChan:
+			err := c.reload(request.config, request.cloudProviderBuilder)
+			if err == nil {
+				c.recordConfig(request.config, request.cloudProviderBuilder, request.source, request.rollbackOf)
+			}
+			request.result <- err
+		case <-c.diagnosticsChan:
+			c.logDiagnostics()
+		case result := <-c.snapshotChan:
+			result <- c.snapshot()
+		case request := <-c.simulationChan:
+			result, err := c.simulate(request.request, time.Now())
+			request.result <- simulationResponse{result: result, err: err}
 		case <-c.stopChan:
 			log.Debugf("Stopping main loop")
-			ticker.Stop()
+			timer.Stop()
 			return errors.New("main loop stopped")
 		}
 	}
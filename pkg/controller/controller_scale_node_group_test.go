@@ -1,9 +1,12 @@
 package controller
 
 import (
+	"context"
+	"fmt"
 	"testing"
 	duration "time"
 
+	"github.com/atlassian/escalator/pkg/k8s"
 	"github.com/atlassian/escalator/pkg/test"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
@@ -12,6 +15,7 @@ import (
 	"github.com/stretchr/testify/require"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 type ListerOptions struct {
@@ -54,10 +58,10 @@ func buildTestClient(nodes []*v1.Node, pods []*v1.Pod, nodeGroups []NodeGroupOpt
 	}
 
 	client := &Client{
-		fakeClient,
-		nodeGroupListerMap,
-		allPodLister,
-		allNodeLister,
+		Interface:     fakeClient,
+		Listers:       nodeGroupListerMap,
+		allPodLister:  allPodLister,
+		allNodeLister: allNodeLister,
 	}
 
 	return client, opts
@@ -117,9 +121,9 @@ func TestUntaintNodeGroupMaxNodes(t *testing.T) {
 			cloudProvider: testCloudProvider,
 		}
 
-		controller.scaleNodeGroup(nodeGroupName, nodeGroupsState[nodeGroupName])
+		controller.scaleNodeGroup(context.Background(), nodeGroupName, nodeGroupsState[nodeGroupName])
 
-		untainted, tainted, _ := controller.filterNodes(nodeGroupsState[nodeGroupName], nodes)
+		untainted, tainted, _, _ := controller.filterNodes(nodeGroupsState[nodeGroupName], nodes)
 		// Ensure that the tainted nodes where untainted
 		assert.Equal(t, maxNodes, len(untainted))
 		assert.Equal(t, 0, len(tainted))
@@ -127,6 +131,79 @@ func TestUntaintNodeGroupMaxNodes(t *testing.T) {
 	})
 }
 
+// Test if when min_nodes is raised while some of the nodes are tainted
+// the most recently tainted nodes are untainted instead of scaling up the cloud provider
+func TestUntaintNodeGroupMinNodes(t *testing.T) {
+	nodeGroupName := "default"
+	minNodes := 5
+	maxNodes := 10
+	nodeGroups := []NodeGroupOptions{{
+		Name:                    nodeGroupName,
+		MinNodes:                minNodes,
+		MaxNodes:                maxNodes,
+		ScaleUpThresholdPercent: 70,
+	}}
+
+	nodes := test.BuildTestNodes(3, test.NodeOpts{
+		CPU: 1000,
+		Mem: 1000,
+	})
+	taintedAgo := map[string]duration.Duration{
+		"tainted-oldest": 30 * duration.Minute,
+		"tainted-middle": 20 * duration.Minute,
+		"tainted-newest": 10 * duration.Minute,
+	}
+	for name, ago := range taintedAgo {
+		node := test.BuildTestNode(test.NodeOpts{
+			Name:    name,
+			CPU:     1000,
+			Mem:     1000,
+			Tainted: true,
+		})
+		node.Spec.Taints[0].Value = fmt.Sprint(time.Now().Add(-ago).Unix())
+		nodes = append(nodes, node)
+	}
+
+	client, opts := buildTestClient(nodes, buildTestPods(1, 100, 100), nodeGroups, ListerOptions{})
+
+	testCloudProvider := test.NewCloudProvider(1)
+	testNodeGroup := test.NewNodeGroup(
+		nodeGroupName,
+		int64(minNodes),
+		int64(maxNodes),
+		int64(len(nodes)),
+	)
+	testCloudProvider.RegisterNodeGroup(testNodeGroup)
+
+	nodeGroupsState := BuildNodeGroupsState(nodeGroupsStateOpts{
+		nodeGroups: nodeGroups,
+		client:     *client,
+	})
+
+	controller := &Controller{
+		Client:        client,
+		Opts:          opts,
+		stopChan:      nil,
+		nodeGroups:    nodeGroupsState,
+		cloudProvider: testCloudProvider,
+	}
+
+	nodesDelta, err := controller.scaleNodeGroup(context.Background(), nodeGroupName, nodeGroupsState[nodeGroupName])
+	require.NoError(t, err)
+	assert.Equal(t, 2, nodesDelta)
+	assert.Equal(t, CycleDecisionScaleToMinimum, nodeGroupsState[nodeGroupName].cycle.Decision)
+
+	// the cloud provider wasn't scaled up
+	assert.Equal(t, int64(len(nodes)), testNodeGroup.TargetSize())
+
+	for name, wantTainted := range map[string]bool{"tainted-oldest": true, "tainted-middle": false, "tainted-newest": false} {
+		updated, err := client.Interface.CoreV1().Nodes().Get(name, metav1.GetOptions{})
+		require.NoError(t, err)
+		_, tainted := k8s.GetToBeRemovedTaint(updated)
+		assert.Equal(t, wantTainted, tainted, name)
+	}
+}
+
 func TestScaleNodeGroup(t *testing.T) {
 	type nodeArgs struct {
 		initialAmount int
@@ -415,7 +492,7 @@ func TestScaleNodeGroup(t *testing.T) {
 				cloudProvider: testCloudProvider,
 			}
 
-			nodesDelta, err := controller.scaleNodeGroup(ngName, nodeGroupsState[ngName])
+			nodesDelta, err := controller.scaleNodeGroup(context.Background(), ngName, nodeGroupsState[ngName])
 
 			// Ensure there were no errors
 			if tt.err == nil {
@@ -440,7 +517,7 @@ func TestScaleNodeGroup(t *testing.T) {
 			nodeGroupsState[ngName].NodeGroupLister = client.Listers[ngName]
 
 			// Re-run the scale, ensure the result is 0 as we shouldn't need to scale up again
-			newNodesDelta, _ := controller.scaleNodeGroup(ngName, nodeGroupsState[ngName])
+			newNodesDelta, _ := controller.scaleNodeGroup(context.Background(), ngName, nodeGroupsState[ngName])
 			assert.Equal(t, 0, newNodesDelta)
 
 		})
@@ -646,9 +723,11 @@ func TestScaleNodeGroup_MultipleRuns(t *testing.T) {
 			// Create a new mock clock
 			mockClock := time.NewMock()
 			time.Work = mockClock
+			// the tests that follow run against the real clock
+			defer func() { time.Work = time.New() }()
 
 			// Run the initial run of the scale
-			nodesDelta, err := controller.scaleNodeGroup(tt.args.nodeGroupOptions.Name, nodeGroupsState[tt.args.nodeGroupOptions.Name])
+			nodesDelta, err := controller.scaleNodeGroup(context.Background(), tt.args.nodeGroupOptions.Name, nodeGroupsState[tt.args.nodeGroupOptions.Name])
 
 			// Ensure the returned nodes delta is what we wanted
 			assert.Equal(t, tt.want, nodesDelta)
@@ -657,7 +736,7 @@ func TestScaleNodeGroup_MultipleRuns(t *testing.T) {
 			// Run subsequent runs of the scale to "simulate" the deletion of the tainted nodes when scaling down
 			for i := 0; i < tt.runs; i++ {
 				mockClock.Add(tt.runInterval)
-				_, err := controller.scaleNodeGroup(tt.args.nodeGroupOptions.Name, nodeGroupsState[tt.args.nodeGroupOptions.Name])
+				_, err := controller.scaleNodeGroup(context.Background(), tt.args.nodeGroupOptions.Name, nodeGroupsState[tt.args.nodeGroupOptions.Name])
 				assert.Nil(t, err)
 			}
 
@@ -670,3 +749,283 @@ func TestScaleNodeGroup_MultipleRuns(t *testing.T) {
 		})
 	}
 }
+
+func TestScaleNodeGroup_ScaleEnabled(t *testing.T) {
+	enabled, disabled := true, false
+	tests := []struct {
+		name             string
+		pods             []*v1.Pod
+		minNodes         int
+		scaleUpEnabled   *bool
+		scaleDownEnabled *bool
+		taintedNodes     int
+		wantDelta        int
+	}{
+		{"scale up", buildTestPods(40, 500, 1000), 5, &enabled, nil, 0, 10},
+		{"scale up disabled", buildTestPods(40, 500, 1000), 5, &disabled, nil, 0, 0},
+		{"scale up to minimum disabled", buildTestPods(40, 500, 1000), 10, &disabled, nil, 2, 0},
+		{"scale down", nil, 5, nil, nil, 0, -1},
+		{"scale down disabled", nil, 5, nil, &disabled, 0, 0},
+		{"scale up when scale down disabled", buildTestPods(40, 500, 1000), 5, nil, &disabled, 0, 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodeGroups := []NodeGroupOptions{{
+				Name:                               "default",
+				CloudProviderGroupName:             "default",
+				MinNodes:                           tt.minNodes,
+				MaxNodes:                           100,
+				ScaleUpThresholdPercent:            50,
+				TaintLowerCapacityThresholdPercent: 40,
+				TaintUpperCapacityThresholdPercent: 45,
+				FastNodeRemovalRate:                1,
+				SlowNodeRemovalRate:                1,
+				ScaleUpEnabled:                     tt.scaleUpEnabled,
+				ScaleDownEnabled:                   tt.scaleDownEnabled,
+			}}
+			nodes := buildTestNodes(10-tt.taintedNodes, 2000, 8000)
+			nodes = append(nodes, test.BuildTestNodes(tt.taintedNodes, test.NodeOpts{CPU: 2000, Mem: 8000, Tainted: true})...)
+			client, opts := buildTestClient(nodes, tt.pods, nodeGroups, ListerOptions{})
+
+			testCloudProvider := test.NewCloudProvider(1)
+			testNodeGroup := test.NewNodeGroup("default", int64(tt.minNodes), 100, int64(len(nodes)))
+			testCloudProvider.RegisterNodeGroup(testNodeGroup)
+
+			nodeGroupsState := BuildNodeGroupsState(nodeGroupsStateOpts{
+				nodeGroups: nodeGroups,
+				client:     *client,
+			})
+			controller := &Controller{
+				Client:        client,
+				Opts:          opts,
+				nodeGroups:    nodeGroupsState,
+				cloudProvider: testCloudProvider,
+			}
+
+			nodesDelta, err := controller.scaleNodeGroup(context.Background(), "default", nodeGroupsState["default"])
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantDelta, nodesDelta)
+			// the tainted nodes are empty, so they are reaped
+			if tt.wantDelta >= 0 {
+				assert.Equal(t, int64(len(nodes)-tt.taintedNodes+tt.wantDelta), testNodeGroup.TargetSize())
+			}
+		})
+	}
+}
+
+func TestScaleNodeGroup_RecycleScaleDownDisabled(t *testing.T) {
+	enabled, disabled := true, false
+	tests := []struct {
+		name             string
+		scaleDownEnabled *bool
+		wantRecycled     bool
+	}{
+		{"recycle", &enabled, true},
+		// the expired nodes aren't tainted, the same as the tainted nodes aren't reaped
+		{"scale down disabled", &disabled, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodeGroups := []NodeGroupOptions{{
+				Name:                               "default",
+				CloudProviderGroupName:             "default",
+				MinNodes:                           5,
+				MaxNodes:                           100,
+				ScaleUpThresholdPercent:            50,
+				TaintLowerCapacityThresholdPercent: 40,
+				TaintUpperCapacityThresholdPercent: 45,
+				FastNodeRemovalRate:                1,
+				SlowNodeRemovalRate:                1,
+				MaxNodeAge:                         "1h",
+				ScaleDownEnabled:                   tt.scaleDownEnabled,
+			}}
+			// 45% utilisation, no need to scale. The nodes are created at the zero time so they are all expired
+			nodes := buildTestNodes(10, 2000, 8000)
+			client, opts := buildTestClient(nodes, buildTestPods(18, 500, 1000), nodeGroups, ListerOptions{})
+
+			testCloudProvider := test.NewCloudProvider(1)
+			testCloudProvider.RegisterNodeGroup(test.NewNodeGroup("default", 5, 100, int64(len(nodes))))
+
+			nodeGroupsState := BuildNodeGroupsState(nodeGroupsStateOpts{
+				nodeGroups: nodeGroups,
+				client:     *client,
+			})
+			controller := &Controller{
+				Client:        client,
+				Opts:          opts,
+				nodeGroups:    nodeGroupsState,
+				cloudProvider: testCloudProvider,
+			}
+
+			_, err := controller.scaleNodeGroup(context.Background(), "default", nodeGroupsState["default"])
+			require.NoError(t, err)
+			assert.Equal(t, CycleDecisionNone, nodeGroupsState["default"].cycle.Decision)
+			assert.Equal(t, tt.wantRecycled, nodeGroupsState["default"].cycle.NodesDeltaResult != 0)
+		})
+	}
+}
+
+func TestScaleNodeGroup_LockedUtilisation(t *testing.T) {
+	nodeGroups := []NodeGroupOptions{{
+		Name:                               "default",
+		CloudProviderGroupName:             "default",
+		MinNodes:                           5,
+		MaxNodes:                           100,
+		ScaleUpThresholdPercent:            50,
+		TaintLowerCapacityThresholdPercent: 40,
+		TaintUpperCapacityThresholdPercent: 45,
+		FastNodeRemovalRate:                1,
+		SlowNodeRemovalRate:                1,
+		ScaleUpCoolDownPeriod:              "1h",
+	}}
+	nodes := buildTestNodes(10, 2000, 8000)
+	client, opts := buildTestClient(nodes, buildTestPods(18, 500, 1000), nodeGroups, ListerOptions{})
+
+	testCloudProvider := test.NewCloudProvider(1)
+	testCloudProvider.RegisterNodeGroup(test.NewNodeGroup("default", 5, 100, int64(len(nodes))))
+
+	nodeGroupsState := BuildNodeGroupsState(nodeGroupsStateOpts{
+		nodeGroups: nodeGroups,
+		client:     *client,
+	})
+	controller := &Controller{
+		Client:        client,
+		Opts:          opts,
+		nodeGroups:    nodeGroupsState,
+		cloudProvider: testCloudProvider,
+	}
+	nodeGroupsState["default"].scaleUpLock.lock(2)
+
+	nodesDelta, err := controller.scaleNodeGroup(context.Background(), "default", nodeGroupsState["default"])
+	require.NoError(t, err)
+	assert.Equal(t, 2, nodesDelta)
+	assert.Equal(t, CycleDecisionLocked, nodeGroupsState["default"].cycle.Decision)
+	// the utilisation is still recorded during the scale up cool down
+	assert.True(t, nodeGroupsState["default"].utilisationKnown)
+	assert.Equal(t, float64(45), nodeGroupsState["default"].utilisationPercent)
+}
+
+func TestScaleNodeGroup_ObserveOnly(t *testing.T) {
+	tests := []struct {
+		name         string
+		pods         []*v1.Pod
+		minNodes     int
+		taintedNodes int
+		wantDelta    int
+		wantDecision string
+	}{
+		{"scale up", buildTestPods(40, 500, 1000), 5, 0, 10, CycleDecisionScaleUp},
+		{"scale to minimum", nil, 10, 2, 2, CycleDecisionScaleToMinimum},
+		{"scale down", nil, 5, 0, -1, CycleDecisionScaleDown},
+		{"no need to scale", buildTestPods(18, 500, 1000), 5, 0, 0, CycleDecisionNone},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodeGroups := []NodeGroupOptions{{
+				Name:                               "default",
+				CloudProviderGroupName:             "default",
+				MinNodes:                           tt.minNodes,
+				MaxNodes:                           100,
+				ScaleUpThresholdPercent:            50,
+				TaintLowerCapacityThresholdPercent: 40,
+				TaintUpperCapacityThresholdPercent: 45,
+				FastNodeRemovalRate:                1,
+				SlowNodeRemovalRate:                1,
+				ObserveOnly:                        true,
+			}}
+			untaintedNodes := buildTestNodes(10-tt.taintedNodes, 2000, 8000)
+			nodes := append(untaintedNodes, test.BuildTestNodes(tt.taintedNodes, test.NodeOpts{CPU: 2000, Mem: 8000, Tainted: true})...)
+			client, opts := buildTestClient(nodes, tt.pods, nodeGroups, ListerOptions{})
+
+			testCloudProvider := test.NewCloudProvider(1)
+			testNodeGroup := test.NewNodeGroup("default", int64(tt.minNodes), 100, int64(len(nodes)))
+			testCloudProvider.RegisterNodeGroup(testNodeGroup)
+
+			nodeGroupsState := BuildNodeGroupsState(nodeGroupsStateOpts{
+				nodeGroups: nodeGroups,
+				client:     *client,
+			})
+			controller := &Controller{
+				Client:        client,
+				Opts:          opts,
+				nodeGroups:    nodeGroupsState,
+				cloudProvider: testCloudProvider,
+			}
+
+			nodesDelta, err := controller.scaleNodeGroup(context.Background(), "default", nodeGroupsState["default"])
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantDelta, nodesDelta)
+			assert.Equal(t, tt.wantDecision, nodeGroupsState["default"].cycle.Decision)
+			assert.True(t, nodeGroupsState["default"].cycle.ObserveOnly)
+
+			// nothing was acted on
+			assert.Equal(t, int64(len(nodes)), testNodeGroup.TargetSize())
+			assert.False(t, nodeGroupsState["default"].scaleUpLock.locked())
+			for _, node := range untaintedNodes {
+				updated, err := client.Interface.CoreV1().Nodes().Get(node.Name, metav1.GetOptions{})
+				require.NoError(t, err)
+				_, tainted := k8s.GetToBeRemovedTaint(updated)
+				assert.False(t, tainted)
+			}
+		})
+	}
+}
+
+func TestScaleNodeGroup_RuntimeClassOverheads(t *testing.T) {
+	// 18 pods of 500m on 10 nodes of 2000m is 45% cpu. Half of the pods run with gVisor, whose 250m overhead takes the
+	// node group to 56.25%, above its scale up threshold
+	gvisor := "gvisor"
+	pods := buildTestPods(18, 500, 1000)
+	for _, pod := range pods[:9] {
+		pod.Spec.RuntimeClassName = &gvisor
+	}
+	overheads := []RuntimeClassOverhead{{Name: gvisor, CPU: "250m", Memory: "0"}}
+
+	tests := []struct {
+		name         string
+		overheads    []RuntimeClassOverhead
+		wantCPU      float64
+		wantDecision string
+	}{
+		{"overhead ignored", nil, 45, CycleDecisionNone},
+		{"overhead counted", overheads, 56.25, CycleDecisionScaleUp},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodeGroups := []NodeGroupOptions{{
+				Name:                               "default",
+				CloudProviderGroupName:             "default",
+				MinNodes:                           5,
+				MaxNodes:                           100,
+				ScaleUpThresholdPercent:            50,
+				TaintLowerCapacityThresholdPercent: 40,
+				TaintUpperCapacityThresholdPercent: 45,
+				FastNodeRemovalRate:                1,
+				SlowNodeRemovalRate:                1,
+				ObserveOnly:                        true,
+			}}
+			nodes := buildTestNodes(10, 2000, 8000)
+			client, opts := buildTestClient(nodes, pods, nodeGroups, ListerOptions{})
+			opts.Cluster.RuntimeClassOverheads = tt.overheads
+
+			testCloudProvider := test.NewCloudProvider(1)
+			testCloudProvider.RegisterNodeGroup(test.NewNodeGroup("default", 5, 100, int64(len(nodes))))
+
+			nodeGroupsState := BuildNodeGroupsState(nodeGroupsStateOpts{
+				nodeGroups: nodeGroups,
+				client:     *client,
+			})
+			controller := &Controller{
+				Client:        client,
+				Opts:          opts,
+				nodeGroups:    nodeGroupsState,
+				cloudProvider: testCloudProvider,
+			}
+
+			_, err := controller.scaleNodeGroup(context.Background(), "default", nodeGroupsState["default"])
+			require.NoError(t, err)
+			assert.InDelta(t, tt.wantCPU, nodeGroupsState["default"].cycle.CPUPercent, 0.001)
+			assert.Equal(t, tt.wantDecision, nodeGroupsState["default"].cycle.Decision)
+		})
+	}
+}
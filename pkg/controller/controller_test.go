@@ -2,7 +2,9 @@ package controller
 
 import (
 	"testing"
+	"time"
 
+	"github.com/atlassian/escalator/pkg/k8s"
 	"github.com/atlassian/escalator/pkg/test"
 	"github.com/stretchr/testify/assert"
 	v1 "k8s.io/api/core/v1"
@@ -79,6 +81,76 @@ func TestControllerDryMode(t *testing.T) {
 	}
 }
 
+func TestControllerSegmentedDryMode(t *testing.T) {
+	tests := []struct {
+		name        string
+		master      bool
+		opts        NodeGroupOptions
+		wantTaint   bool
+		wantDelete  bool
+		wantScaleUp bool
+	}{
+		{"nothing dry", false, NodeGroupOptions{}, false, false, false},
+		{"master dry mode", true, NodeGroupOptions{}, true, true, true},
+		{"node group dry mode", false, NodeGroupOptions{DryMode: true}, true, true, true},
+		{"dry taint", false, NodeGroupOptions{DryTaint: true}, true, false, false},
+		{"dry delete", false, NodeGroupOptions{DryDelete: true}, false, true, false},
+		{"dry scale up", false, NodeGroupOptions{DryScaleUp: true}, false, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Controller{
+				Opts: Opts{
+					DryMode: tt.master,
+				},
+			}
+			nodeGroup := &NodeGroupState{Opts: tt.opts}
+			assert.Equal(t, tt.wantTaint, c.dryTaint(nodeGroup))
+			assert.Equal(t, tt.wantDelete, c.dryDelete(nodeGroup))
+			assert.Equal(t, tt.wantScaleUp, c.dryScaleUp(nodeGroup))
+		})
+	}
+}
+
+func TestControllerScaleDownBlocked(t *testing.T) {
+	tests := []struct {
+		name        string
+		threshold   int
+		utilisation float64
+		known       bool
+		want        bool
+	}{
+		{"dependent not evaluated", 0, 0, false, true},
+		{"dependent busy", 0, 50, true, true},
+		{"dependent idle", 0, 20, true, false},
+		{"custom threshold busy", 10, 20, true, true},
+		{"custom threshold idle", 60, 50, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dependent := &NodeGroupState{
+				Opts:               NodeGroupOptions{Name: "batch", TaintUpperCapacityThresholdPercent: 40},
+				utilisationPercent: tt.utilisation,
+				utilisationKnown:   tt.known,
+			}
+			cache := &NodeGroupState{
+				Opts: NodeGroupOptions{Name: "cache", ScaleDownAfter: []string{"batch"}, ScaleDownAfterThresholdPercent: tt.threshold},
+			}
+			c := &Controller{
+				nodeGroups: map[string]*NodeGroupState{"batch": dependent, "cache": cache},
+			}
+
+			name, blocked := c.scaleDownBlocked(cache)
+			assert.Equal(t, tt.want, blocked)
+			if tt.want {
+				assert.Equal(t, "batch", name)
+			}
+			_, blocked = c.scaleDownBlocked(dependent)
+			assert.False(t, blocked)
+		})
+	}
+}
+
 func TestControllerFilterNodes(t *testing.T) {
 	nodes := []*v1.Node{
 		0: test.BuildTestNode(test.NodeOpts{
@@ -141,7 +213,7 @@ func TestControllerFilterNodes(t *testing.T) {
 					Opts: NodeGroupOptions{
 						DryMode: true,
 					},
-					taintTracker: []string{"n1", "n3", "n5"},
+					taintTracker: []DryModeTaint{{Node: "n1"}, {Node: "n3"}, {Node: "n5"}},
 				},
 				nodes,
 				true,
@@ -158,10 +230,114 @@ func TestControllerFilterNodes(t *testing.T) {
 					DryMode: tt.args.master,
 				},
 			}
-			gotUntaintedNodes, gotTaintedNodes, gotCordonedNodes := c.filterNodes(tt.args.nodeGroup, tt.args.allNodes)
+			gotUntaintedNodes, gotTaintedNodes, gotCordonedNodes, _ := c.filterNodes(tt.args.nodeGroup, tt.args.allNodes)
 			assert.Equal(t, tt.wantUntaintedNodes, gotUntaintedNodes)
 			assert.Equal(t, tt.wantTaintedNodes, gotTaintedNodes)
 			assert.Equal(t, tt.wantCordonedNodes, gotCordonedNodes)
 		})
 	}
 }
+
+func TestControllerFilterNodes_ShuttingDown(t *testing.T) {
+	untainted := test.BuildTestNode(test.NodeOpts{Name: "untainted"})
+	tainted := test.BuildTestNode(test.NodeOpts{Name: "tainted", Tainted: true})
+	outOfService := test.BuildTestNode(test.NodeOpts{Name: "out-of-service"})
+	outOfService.Spec.Taints = append(outOfService.Spec.Taints, v1.Taint{Key: k8s.OutOfServiceTaintKey, Effect: v1.TaintEffectNoExecute})
+	// nodes tainted by escalator that are then shut down by the cloud provider are left to the cloud provider
+	taintedShutdown := test.BuildTestNode(test.NodeOpts{Name: "tainted-shutdown", Tainted: true})
+	taintedShutdown.Spec.Taints = append(taintedShutdown.Spec.Taints, v1.Taint{Key: k8s.ShutdownTaintKey, Effect: v1.TaintEffectNoSchedule})
+	nodes := []*v1.Node{untainted, tainted, outOfService, taintedShutdown}
+
+	for _, dryMode := range []bool{false, true} {
+		c := &Controller{Opts: Opts{DryMode: dryMode}}
+		nodeGroup := &NodeGroupState{taintTracker: []DryModeTaint{{Node: "tainted"}, {Node: "tainted-shutdown"}}}
+		gotUntainted, gotTainted, gotCordoned, gotShuttingDown := c.filterNodes(nodeGroup, nodes)
+		assert.Equal(t, []*v1.Node{untainted}, gotUntainted)
+		assert.Equal(t, []*v1.Node{tainted}, gotTainted)
+		assert.Empty(t, gotCordoned)
+		assert.Equal(t, []*v1.Node{outOfService, taintedShutdown}, gotShuttingDown)
+	}
+}
+
+func TestControllerFilterNodes_CordonedToBeRemoved(t *testing.T) {
+	cordoned := test.BuildTestNode(test.NodeOpts{Name: "cordoned"})
+	cordoned.Spec.Unschedulable = true
+	// nodes cordoned by escalator for scale_down_mode cordon are tainted nodes
+	marked := test.BuildTestNode(test.NodeOpts{Name: "marked"})
+	marked.Spec.Unschedulable = true
+	marked.Annotations = map[string]string{k8s.ToBeRemovedCordonAnnotationKey: "1500000000"}
+	nodes := []*v1.Node{cordoned, marked}
+
+	c := &Controller{}
+	gotUntainted, gotTainted, gotCordoned, _ := c.filterNodes(&NodeGroupState{}, nodes)
+	assert.Empty(t, gotUntainted)
+	assert.Equal(t, []*v1.Node{marked}, gotTainted)
+	assert.Equal(t, []*v1.Node{cordoned}, gotCordoned)
+}
+
+func TestFilterStartingNodes(t *testing.T) {
+	now := time.Now()
+	ready := v1.NodeCondition{Type: v1.NodeReady, Status: v1.ConditionTrue}
+
+	newNotReady := test.BuildTestNode(test.NodeOpts{Name: "new-not-ready", Creation: now.Add(-time.Minute)})
+	newReady := test.BuildTestNode(test.NodeOpts{Name: "new-ready", Creation: now.Add(-time.Minute)})
+	newReady.Status.Conditions = []v1.NodeCondition{ready}
+	oldNotReady := test.BuildTestNode(test.NodeOpts{Name: "old-not-ready", Creation: now.Add(-time.Hour)})
+	uninitialized := test.BuildTestNode(test.NodeOpts{Name: "uninitialized", Creation: now.Add(-time.Hour)})
+	uninitialized.Status.Conditions = []v1.NodeCondition{ready}
+	uninitialized.Spec.Taints = []v1.Taint{{Key: k8s.UninitializedTaintKey, Value: "true", Effect: v1.TaintEffectNoSchedule}}
+	nodes := []*v1.Node{newNotReady, newReady, oldNotReady, uninitialized}
+
+	tests := []struct {
+		name          string
+		startupTaints []string
+		gracePeriod   time.Duration
+		wantSettled   []*v1.Node
+		wantStarting  []*v1.Node
+	}{
+		{"no grace period", nil, 0, nodes, nil},
+		{"new node not ready", nil, 10 * time.Minute, []*v1.Node{newReady, oldNotReady, uninitialized}, []*v1.Node{newNotReady}},
+		{"grace period over", nil, 30 * time.Second, nodes, nil},
+		{"startup taint", k8s.DefaultStartupTaintKeys, 0, []*v1.Node{newNotReady, newReady, oldNotReady}, []*v1.Node{uninitialized}},
+		{"startup taint and new node not ready", k8s.DefaultStartupTaintKeys, 10 * time.Minute, []*v1.Node{newReady, oldNotReady}, []*v1.Node{newNotReady, uninitialized}},
+		{"other startup taint", []string{"node.cilium.io/agent-not-ready"}, 0, nodes, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settled, starting := filterStartingNodes(nodes, tt.startupTaints, tt.gracePeriod, now)
+			assert.Equal(t, tt.wantSettled, settled)
+			assert.Equal(t, tt.wantStarting, starting)
+		})
+	}
+}
+
+func TestFilterBestEffortPods(t *testing.T) {
+	burstable := test.BuildTestPod(test.PodOpts{Name: "burstable", CPU: []int64{100}, Mem: []int64{100}})
+	bestEffort := test.BuildTestPod(test.PodOpts{Name: "best-effort"})
+	guaranteed := test.BuildTestPod(test.PodOpts{Name: "guaranteed"})
+	guaranteed.Status.QOSClass = v1.PodQOSGuaranteed
+
+	filtered := filterBestEffortPods([]*v1.Pod{burstable, bestEffort, guaranteed})
+	assert.Equal(t, []*v1.Pod{burstable, guaranteed}, filtered)
+	assert.Empty(t, filterBestEffortPods([]*v1.Pod{bestEffort}))
+}
+
+func TestGetCloudProviderNodeGroup(t *testing.T) {
+	cloud := test.NewCloudProvider(2)
+	cloud.RegisterNodeGroup(test.NewNodeGroup("asg-a", 1, 10, 4))
+	cloud.RegisterNodeGroup(test.NewNodeGroup("asg-b", 0, 5, 2))
+
+	single, ok := getCloudProviderNodeGroup(cloud, NodeGroupOptions{CloudProviderGroupName: "asg-a"})
+	assert.True(t, ok)
+	assert.Equal(t, "asg-a", single.ID())
+
+	grouped, ok := getCloudProviderNodeGroup(cloud, NodeGroupOptions{CloudProviderGroupNames: []string{"asg-a", "asg-b"}})
+	assert.True(t, ok)
+	assert.Equal(t, "asg-a,asg-b", grouped.ID())
+	assert.Equal(t, int64(1), grouped.MinSize())
+	assert.Equal(t, int64(15), grouped.MaxSize())
+	assert.Equal(t, int64(6), grouped.TargetSize())
+
+	_, ok = getCloudProviderNodeGroup(cloud, NodeGroupOptions{CloudProviderGroupNames: []string{"asg-a", "asg-c"}})
+	assert.False(t, ok)
+}
@@ -0,0 +1,159 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/atlassian/escalator/pkg/errorkind"
+	"github.com/atlassian/escalator/pkg/metrics"
+	log "github.com/sirupsen/logrus"
+)
+
+// cycleHistorySize is the number of cycle summaries kept for each node group
+const cycleHistorySize = 100
+
+const (
+	// CycleDecisionNone is a cycle where the node group didn't need to scale
+	CycleDecisionNone = "none"
+	// CycleDecisionScaleUp is a cycle where the node group scaled up
+	CycleDecisionScaleUp = "scale_up"
+	// CycleDecisionScaleDown is a cycle where the node group scaled down
+	CycleDecisionScaleDown = "scale_down"
+	// CycleDecisionScaleToMinimum is a cycle where the node group had less untainted nodes than min_nodes
+	CycleDecisionScaleToMinimum = "scale_to_minimum"
+	// CycleDecisionLocked is a cycle where the node group was waiting for a scale up to finish
+	CycleDecisionLocked = "locked"
+	// CycleDecisionQuotaBlocked is a cycle where a scale up was blocked by a quota of the cloud provider account
+	CycleDecisionQuotaBlocked = "quota_blocked"
+	// CycleDecisionSpotInterruption is a cycle where nodes with a spot interruption notice were tainted and replaced
+	CycleDecisionSpotInterruption = "spot_interruption"
+	// CycleDecisionSkipped is a cycle where the node group was outside of its limits or failed before deciding
+	CycleDecisionSkipped = "skipped"
+)
+
+// CycleSummary is the inputs, decision, actions and error of a single run for a node group
+type CycleSummary struct {
+	ID   string    `json:"id"`
+	Time time.Time `json:"time"`
+
+	// inputs
+	Pods              int     `json:"pods"`
+	Nodes             int     `json:"nodes"`
+	UntaintedNodes    int     `json:"untainted_nodes"`
+	TaintedNodes      int     `json:"tainted_nodes"`
+	CordonedNodes     int     `json:"cordoned_nodes"`
+	ShuttingDownNodes int     `json:"shutting_down_nodes"`
+	StartingNodes     int     `json:"starting_nodes"`
+	CPUPercent        float64 `json:"cpu_percent"`
+	MemPercent        float64 `json:"mem_percent"`
+	// HeterogeneousAllocatable is whether the nodes disagreed materially on their allocatable
+	HeterogeneousAllocatable bool `json:"heterogeneous_allocatable,omitempty"`
+
+	// decision
+	Decision   string `json:"decision"`
+	NodesDelta int    `json:"nodes_delta"`
+	// ScaleUpRateTriggered is whether the scale up was triggered by the rise of the utilisation
+	ScaleUpRateTriggered bool `json:"scale_up_rate_triggered,omitempty"`
+	// ObserveOnly is whether the decision was only reported, as the node group is observe only
+	ObserveOnly bool `json:"observe_only,omitempty"`
+	// MaintenanceWindow is the name of the maintenance window that froze scale down of the node group
+	MaintenanceWindow string `json:"maintenance_window,omitempty"`
+
+	// actions
+	NodesDeltaResult int `json:"nodes_delta_result"`
+	NodesDeleted     int `json:"nodes_deleted"`
+
+	Error string `json:"error,omitempty"`
+	// ErrorKind is the category of the error, e.g. throttled or not_found
+	ErrorKind errorkind.Kind `json:"error_kind,omitempty"`
+
+	// State is the state of the node group after the run, as exported by the escalator_node_group_state metric
+	State string `json:"state,omitempty"`
+}
+
+// cycleID returns the id of the run that started at the time. Runs of a node group are at least a scan interval apart,
+// so the id together with the node group name identifies the run
+func cycleID(start time.Time) string {
+	return start.UTC().Format("20060102T150405Z")
+}
+
+// cycleHistory is a ring buffer of the latest cycle summaries of a node group
+type cycleHistory struct {
+	lock      sync.RWMutex
+	summaries [cycleHistorySize]CycleSummary
+	next      int
+	count     int
+}
+
+// add adds the summary to the history, overwriting the oldest summary once the history is full
+func (h *cycleHistory) add(summary CycleSummary) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.summaries[h.next] = summary
+	h.next = (h.next + 1) % cycleHistorySize
+	if h.count < cycleHistorySize {
+		h.count++
+	}
+}
+
+// list returns a copy of the summaries in the history, oldest first
+func (h *cycleHistory) list() []CycleSummary {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	summaries := make([]CycleSummary, 0, h.count)
+	start := (h.next - h.count + cycleHistorySize) % cycleHistorySize
+	for i := 0; i < h.count; i++ {
+		summaries = append(summaries, h.summaries[(start+i)%cycleHistorySize])
+	}
+	return summaries
+}
+
+// recordCycle adds the summary of the node group's latest run to its history
+func (c *Controller) recordCycle(nodeGroup *NodeGroupState, delta int, err error) {
+	summary := nodeGroup.cycle
+	summary.NodesDelta = delta
+	if err != nil {
+		summary.Error = err.Error()
+		summary.ErrorKind = errorkind.Of(err)
+	}
+	if len(summary.Error) > 0 {
+		metrics.NodeGroupErrors.WithLabelValues(nodeGroup.Opts.Name, string(summary.ErrorKind)).Add(1.0)
+	}
+	summary.State = nodeGroupState(nodeGroup.Opts, summary)
+	updateNodeGroupState(nodeGroup.Opts.Name, summary.State)
+	nodeGroup.cycles.add(summary)
+}
+
+// Cycles returns the latest cycle summaries of each node group, oldest first
+func (c *Controller) Cycles() map[string][]CycleSummary {
+	c.nodeGroupsLock.RLock()
+	defer c.nodeGroupsLock.RUnlock()
+	cycles := make(map[string][]CycleSummary, len(c.nodeGroups))
+	for name, nodeGroup := range c.nodeGroups {
+		cycles[name] = nodeGroup.cycles.list()
+	}
+	return cycles
+}
+
+// CyclesHandler serves the latest cycle summaries as json. The nodegroup query parameter limits the response to
+// a single node group
+func (c *Controller) CyclesHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cycles := c.Cycles()
+		if name := r.URL.Query().Get("nodegroup"); len(name) > 0 {
+			nodeGroupCycles, ok := cycles[name]
+			if !ok {
+				http.Error(w, "node group not found", http.StatusNotFound)
+				return
+			}
+			cycles = map[string][]CycleSummary{name: nodeGroupCycles}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(cycles); err != nil {
+			log.WithError(err).Warn("Failed to write cycles")
+		}
+	})
+}
@@ -0,0 +1,70 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/atlassian/escalator/pkg/errorkind"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCycleHistory(t *testing.T) {
+	var history cycleHistory
+	assert.Equal(t, []CycleSummary{}, history.list())
+
+	for i := 0; i < 3; i++ {
+		history.add(CycleSummary{Pods: i})
+	}
+	summaries := history.list()
+	require.Len(t, summaries, 3)
+	assert.Equal(t, 0, summaries[0].Pods)
+	assert.Equal(t, 2, summaries[2].Pods)
+
+	// overwrites the oldest summaries once full
+	for i := 3; i < cycleHistorySize+10; i++ {
+		history.add(CycleSummary{Pods: i})
+	}
+	summaries = history.list()
+	require.Len(t, summaries, cycleHistorySize)
+	assert.Equal(t, 10, summaries[0].Pods)
+	assert.Equal(t, cycleHistorySize+9, summaries[cycleHistorySize-1].Pods)
+}
+
+func TestControllerCyclesHandler(t *testing.T) {
+	shared := &NodeGroupState{Opts: NodeGroupOptions{Name: "shared"}}
+	shared.cycle = CycleSummary{Decision: CycleDecisionScaleDown, NodesDeltaResult: -2}
+	c := &Controller{
+		nodeGroups: map[string]*NodeGroupState{
+			"shared": shared,
+			"gpu":    {Opts: NodeGroupOptions{Name: "gpu"}},
+		},
+	}
+	c.recordCycle(shared, -2, errors.New("failed"))
+
+	recorder := httptest.NewRecorder()
+	c.CyclesHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/cycles", nil))
+	var cycles map[string][]CycleSummary
+	require.NoError(t, json.NewDecoder(recorder.Body).Decode(&cycles))
+	assert.Len(t, cycles, 2)
+	require.Len(t, cycles["shared"], 1)
+	assert.Equal(t, CycleDecisionScaleDown, cycles["shared"][0].Decision)
+	assert.Equal(t, -2, cycles["shared"][0].NodesDelta)
+	assert.Equal(t, "failed", cycles["shared"][0].Error)
+	assert.Equal(t, errorkind.Unknown, cycles["shared"][0].ErrorKind)
+	assert.Equal(t, NodeGroupStateDegraded, cycles["shared"][0].State)
+	assert.Len(t, cycles["gpu"], 0)
+
+	recorder = httptest.NewRecorder()
+	c.CyclesHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/cycles?nodegroup=gpu", nil))
+	cycles = nil
+	require.NoError(t, json.NewDecoder(recorder.Body).Decode(&cycles))
+	assert.Len(t, cycles, 1)
+
+	recorder = httptest.NewRecorder()
+	c.CyclesHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/cycles?nodegroup=unknown", nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+}
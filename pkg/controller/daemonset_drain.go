@@ -0,0 +1,75 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/atlassian/escalator/pkg/k8s"
+	"github.com/atlassian/escalator/pkg/metrics"
+	log "github.com/sirupsen/logrus"
+	"github.com/stephanos/clock"
+	v1 "k8s.io/api/core/v1"
+)
+
+// defaultDaemonSetDrainTimeout is how long to wait for the DaemonSet pods of a node to flush when
+// daemonset_drain.timeout isn't set
+const defaultDaemonSetDrainTimeout = 5 * time.Minute
+
+// drainDaemonSets deletes the pods of the daemonset_drain DaemonSets from the node and returns whether the node can be
+// deleted. That is once the pods have set the completed annotation on the node, or the drain has timed out.
+// The pods are only deleted once per node, the time they were deleted is kept in the node group state
+func (c *Controller) drainDaemonSets(nodeGroup *NodeGroupState, node *v1.Node) bool {
+	drainOpts := nodeGroup.Opts.DaemonSetDrain
+	if len(drainOpts.DaemonSets) == 0 {
+		return true
+	}
+	logger := log.WithField("nodegroup", nodeGroup.Opts.Name).WithField("node", node.Name)
+
+	if _, completed := node.Annotations[drainOpts.CompletedAnnotation]; completed {
+		logger.Info("DaemonSet pods have flushed, node can be deleted")
+		delete(nodeGroup.daemonSetDrains, node.Name)
+		return true
+	}
+
+	now := clock.Now()
+	started, draining := nodeGroup.daemonSetDrains[node.Name]
+	if !draining {
+		deleted, err := k8s.DeleteDaemonSetPods(node, drainOpts.DaemonSets, c.Client)
+		if err != nil {
+			logger.WithError(err).Error("failed to delete DaemonSet pods, retrying next run")
+			return false
+		}
+		if nodeGroup.daemonSetDrains == nil {
+			nodeGroup.daemonSetDrains = make(map[string]time.Time)
+		}
+		nodeGroup.daemonSetDrains[node.Name] = now
+		logger.Infof("Deleted DaemonSet pods %v, waiting for the %v annotation", deleted, drainOpts.CompletedAnnotation)
+		return false
+	}
+
+	timeout := nodeGroup.Opts.DaemonSetDrainTimeoutDuration()
+	if now.Sub(started) > timeout {
+		logger.Warningf("DaemonSet pods didn't set the %v annotation within %v, deleting the node anyway", drainOpts.CompletedAnnotation, timeout)
+		metrics.NodeGroupDaemonSetDrainTimeouts.WithLabelValues(nodeGroup.Opts.Name).Add(1.0)
+		delete(nodeGroup.daemonSetDrains, node.Name)
+		return true
+	}
+
+	logger.Debugf("waiting for the %v annotation. Time remaining %v", drainOpts.CompletedAnnotation, timeout-now.Sub(started))
+	return false
+}
+
+// pruneDaemonSetDrains forgets the drains of the nodes that are no longer tainted, e.g. because they were untainted
+func pruneDaemonSetDrains(nodeGroup *NodeGroupState, taintedNodes []*v1.Node) {
+	if len(nodeGroup.daemonSetDrains) == 0 {
+		return
+	}
+	tainted := make(map[string]bool, len(taintedNodes))
+	for _, node := range taintedNodes {
+		tainted[node.Name] = true
+	}
+	for name := range nodeGroup.daemonSetDrains {
+		if !tainted[name] {
+			delete(nodeGroup.daemonSetDrains, name)
+		}
+	}
+}
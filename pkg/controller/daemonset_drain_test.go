@@ -0,0 +1,72 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/atlassian/escalator/pkg/test"
+	"github.com/stephanos/clock"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// countPodDeletes returns how many pods were deleted through the fake client
+func countPodDeletes(client *Client) int {
+	deletes := 0
+	for _, action := range client.Interface.(*fake.Clientset).Actions() {
+		if action.Matches("delete", "pods") {
+			deletes++
+		}
+	}
+	return deletes
+}
+
+func TestControllerDrainDaemonSets(t *testing.T) {
+	fluentd := test.BuildTestPod(test.PodOpts{Name: "fluentd-abcde", Namespace: "logging", NodeName: "n1"})
+	fluentd.OwnerReferences = []metav1.OwnerReference{{Kind: "DaemonSet", Name: "fluentd"}}
+	drainOpts := DaemonSetDrainOptions{DaemonSets: []string{"logging/fluentd"}, CompletedAnnotation: "logging.example.com/flushed", Timeout: "5m"}
+
+	tests := []struct {
+		name        string
+		drainOpts   DaemonSetDrainOptions
+		annotated   bool
+		started     time.Duration
+		want        bool
+		wantDeletes int
+		wantTracked bool
+	}{
+		{"no daemonsets", DaemonSetDrainOptions{}, false, 0, true, 0, false},
+		{"deletes the pods and waits", drainOpts, false, 0, false, 1, true},
+		{"waits for the annotation", drainOpts, false, time.Minute, false, 0, true},
+		{"annotated", drainOpts, true, time.Minute, true, 0, false},
+		{"timed out", drainOpts, false, 10 * time.Minute, true, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := test.BuildTestNode(test.NodeOpts{Name: "n1", Tainted: true})
+			if tt.annotated {
+				node.Annotations = map[string]string{tt.drainOpts.CompletedAnnotation: "true"}
+			}
+			client, opts := buildTestClient([]*v1.Node{node}, []*v1.Pod{fluentd}, nil, ListerOptions{})
+			c := &Controller{Client: client, Opts: opts}
+			nodeGroup := &NodeGroupState{Opts: NodeGroupOptions{Name: "default", DaemonSetDrain: tt.drainOpts}}
+			if tt.started > 0 {
+				nodeGroup.daemonSetDrains = map[string]time.Time{"n1": clock.Now().Add(-tt.started)}
+			}
+
+			assert.Equal(t, tt.want, c.drainDaemonSets(nodeGroup, node))
+			assert.Equal(t, tt.wantDeletes, countPodDeletes(client))
+			_, tracked := nodeGroup.daemonSetDrains["n1"]
+			assert.Equal(t, tt.wantTracked, tracked)
+		})
+	}
+}
+
+func TestPruneDaemonSetDrains(t *testing.T) {
+	nodeGroup := &NodeGroupState{daemonSetDrains: map[string]time.Time{"tainted": time.Now(), "untainted": time.Now()}}
+	pruneDaemonSetDrains(nodeGroup, []*v1.Node{test.BuildTestNode(test.NodeOpts{Name: "tainted", Tainted: true})})
+	assert.Len(t, nodeGroup.daemonSetDrains, 1)
+	assert.Contains(t, nodeGroup.daemonSetDrains, "tainted")
+}
@@ -0,0 +1,63 @@
+package controller
+
+import (
+	"github.com/atlassian/escalator/pkg/k8s"
+	log "github.com/sirupsen/logrus"
+)
+
+// decommissionReport is what decommissioning a removed node group did to its nodes
+type decommissionReport struct {
+	// untainted is the nodes that had their taint removed
+	untainted []string
+	// leftovers is the escalator taints and annotations still on each node after decommissioning
+	leftovers map[string][]string
+}
+
+// decommissionNodeGroup untaints the nodes of a node group that was removed from the config, so they aren't left
+// tainted with no node group to delete or untaint them. Nodes that now belong to another node group are left to the
+// membership transition of the next run. Any escalator taints or annotations that remain are reported
+func (c *Controller) decommissionNodeGroup(nodeGroup *NodeGroupState, nodeGroups []NodeGroupOptions) decommissionReport {
+	report := decommissionReport{leftovers: make(map[string][]string)}
+	logger := log.WithField("nodegroup", nodeGroup.Opts.Name)
+
+	nodes, err := nodeGroup.Nodes.List()
+	if err != nil {
+		logger.WithError(err).Error("Failed to list nodes to decommission. Any nodes it tainted keep their taint")
+		return report
+	}
+
+	// in dry mode the taints only exist in the taint tracker
+	dryTaint := c.dryTaint(nodeGroup)
+	if dryTaint {
+		for _, name := range nodeGroup.dryTaintedNodes() {
+			logger.WithField("drymode", "on").Infof("Untainting node %v of the removed node group", name)
+		}
+		nodeGroup.taintTracker = nil
+	}
+
+	for _, node := range nodes {
+		if current := nodeGroupMembership(node, nodeGroups); current != "" {
+			logger.Infof("Not decommissioning node %v, it belongs to node group %q", node.Name, current)
+			continue
+		}
+
+		if k8s.MarkedToBeRemoved(node) && !dryTaint {
+			logger.WithField("drymode", "off").Infof("Untainting node %v of the removed node group", node.Name)
+			updatedNode, err := k8s.DeleteToBeRemovedTaint(node, c.Client)
+			if err != nil {
+				logger.WithError(err).Errorf("Failed to untaint node %v of the removed node group", node.Name)
+			} else {
+				report.untainted = append(report.untainted, node.Name)
+				node = updatedNode
+			}
+		}
+
+		if keys := k8s.GetEscalatorKeys(node); len(keys) > 0 {
+			report.leftovers[node.Name] = keys
+			logger.Warnf("Node %v of the removed node group still has escalator metadata: %v", node.Name, keys)
+		}
+	}
+
+	logger.Infof("Decommissioned node group. Untainted %v nodes, %v nodes have escalator metadata left", len(report.untainted), len(report.leftovers))
+	return report
+}
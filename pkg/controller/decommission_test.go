@@ -0,0 +1,51 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/atlassian/escalator/pkg/k8s"
+	"github.com/atlassian/escalator/pkg/test"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestControllerDecommissionNodeGroup(t *testing.T) {
+	buildNodes := func() []*v1.Node {
+		moved := test.BuildTestNode(test.NodeOpts{Name: "moved", LabelKey: "customer", LabelValue: "old", Tainted: true})
+		moved.Labels["gpu"] = "true"
+		annotated := test.BuildTestNode(test.NodeOpts{Name: "annotated", LabelKey: "customer", LabelValue: "old"})
+		annotated.Annotations = map[string]string{k8s.TaintSchemaVersionAnnotationKey: "1"}
+		return []*v1.Node{
+			test.BuildTestNode(test.NodeOpts{Name: "tainted", LabelKey: "customer", LabelValue: "old", Tainted: true}),
+			test.BuildTestNode(test.NodeOpts{Name: "untainted", LabelKey: "customer", LabelValue: "old"}),
+			annotated,
+			moved,
+		}
+	}
+	old := NodeGroupOptions{Name: "old", LabelKey: "customer", LabelValue: "old"}
+	// the moved node now belongs to the gpu node group
+	remaining := []NodeGroupOptions{{Name: "gpu", LabelKey: "gpu", LabelValue: "true"}}
+
+	t.Run("untaints the nodes of the removed node group", func(t *testing.T) {
+		client, opts := buildTestClient(buildNodes(), nil, []NodeGroupOptions{old}, ListerOptions{})
+		c := &Controller{Client: client, Opts: opts}
+		report := c.decommissionNodeGroup(&NodeGroupState{Opts: old, NodeGroupLister: client.Listers["old"]}, remaining)
+
+		assert.Equal(t, []string{"tainted"}, report.untainted)
+		assert.Equal(t, map[string][]string{"annotated": {"annotation " + k8s.TaintSchemaVersionAnnotationKey}}, report.leftovers)
+	})
+
+	t.Run("drymode only clears the taint tracker", func(t *testing.T) {
+		client, opts := buildTestClient(buildNodes(), nil, []NodeGroupOptions{old}, ListerOptions{})
+		opts.DryMode = true
+		c := &Controller{Client: client, Opts: opts}
+		state := &NodeGroupState{Opts: old, NodeGroupLister: client.Listers["old"], taintTracker: []DryModeTaint{{Node: "untainted"}}}
+		report := c.decommissionNodeGroup(state, remaining)
+
+		assert.Empty(t, state.taintTracker)
+		assert.Empty(t, report.untainted)
+		assert.Len(t, report.leftovers, 2)
+		assert.NotContains(t, report.leftovers, "moved")
+		assert.Equal(t, []string{"taint " + k8s.ToBeRemovedByAutoscalerKey}, report.leftovers["tainted"])
+	})
+}
@@ -0,0 +1,143 @@
+package controller
+
+import (
+	"sort"
+	"time"
+
+	"github.com/atlassian/escalator/pkg/errorkind"
+	"github.com/atlassian/escalator/pkg/metrics"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	// deletionBackoffBase is how long the deletion of a node waits after its first failure, doubling with each failure
+	deletionBackoffBase = time.Minute
+	// deletionBackoffMax caps the wait between the deletion attempts of a node
+	deletionBackoffMax = time.Hour
+	// deletionMaxAttempts is the number of failed deletions after which a node is left for a human to look at
+	deletionMaxAttempts = 10
+)
+
+// deletionAttempts are the failed deletions of a tainted node
+type deletionAttempts struct {
+	failures  int
+	retryAt   time.Time
+	lastError string
+}
+
+// needsAttention returns whether the node failed to be deleted too many times to be retried
+func (a *deletionAttempts) needsAttention() bool {
+	return a.failures >= deletionMaxAttempts
+}
+
+// StuckDeletion is a tainted node that failed to be deleted deletionMaxAttempts times and is no longer retried
+type StuckDeletion struct {
+	NodeGroup string `json:"node_group"`
+	Node      string `json:"node"`
+	Attempts  int    `json:"attempts"`
+	LastError string `json:"last_error"`
+}
+
+// deletionBackingOff returns whether the deletion of the node has to wait because its previous deletions failed
+func deletionBackingOff(nodeGroup *NodeGroupState, node *v1.Node, now time.Time) bool {
+	attempts, ok := nodeGroup.deletionAttempts[node.Name]
+	if !ok {
+		return false
+	}
+	logger := log.WithField("nodegroup", nodeGroup.Opts.Name).WithField("node", node.Name)
+	if attempts.needsAttention() {
+		logger.Debugf("not deleting node, it failed to be deleted %v times and needs attention", attempts.failures)
+		return true
+	}
+	if now.Before(attempts.retryAt) {
+		logger.Debugf("not deleting node, backing off after %v failed deletions. Time remaining %v", attempts.failures, attempts.retryAt.Sub(now))
+		return true
+	}
+	return false
+}
+
+// recordDeletionFailure counts a failed deletion against each of the nodes and backs them off exponentially.
+// Throttling and a node group busy with another update aren't the fault of the nodes, so they aren't counted
+func recordDeletionFailure(nodeGroup *NodeGroupState, nodes []*v1.Node, err error, now time.Time) {
+	if errorkind.Is(err, errorkind.Throttled) || errorkind.Is(err, errorkind.Conflict) {
+		return
+	}
+	if nodeGroup.deletionAttempts == nil {
+		nodeGroup.deletionAttempts = make(map[string]*deletionAttempts)
+	}
+	for _, node := range nodes {
+		attempts, ok := nodeGroup.deletionAttempts[node.Name]
+		if !ok {
+			attempts = &deletionAttempts{}
+			nodeGroup.deletionAttempts[node.Name] = attempts
+		}
+		attempts.failures++
+		attempts.lastError = err.Error()
+		metrics.NodeGroupDeletionFailures.WithLabelValues(nodeGroup.Opts.Name).Add(1.0)
+
+		logger := log.WithField("nodegroup", nodeGroup.Opts.Name).WithField("node", node.Name)
+		if attempts.needsAttention() {
+			logger.Errorf("node failed to be deleted %v times, it will no longer be retried and needs attention", attempts.failures)
+			continue
+		}
+		backoff := deletionBackoffMax
+		if shift := uint(attempts.failures - 1); shift < 32 && deletionBackoffBase<<shift < deletionBackoffMax {
+			backoff = deletionBackoffBase << shift
+		}
+		attempts.retryAt = now.Add(backoff)
+		logger.Warningf("node failed to be deleted %v times, retrying in %v", attempts.failures, backoff)
+	}
+}
+
+// pruneDeletionAttempts forgets the failed deletions of the nodes that are no longer tainted, e.g. because they were
+// untainted or deleted by someone else
+func pruneDeletionAttempts(nodeGroup *NodeGroupState, taintedNodes []*v1.Node) {
+	if len(nodeGroup.deletionAttempts) == 0 {
+		return
+	}
+	tainted := make(map[string]bool, len(taintedNodes))
+	for _, node := range taintedNodes {
+		tainted[node.Name] = true
+	}
+	for name := range nodeGroup.deletionAttempts {
+		if !tainted[name] {
+			delete(nodeGroup.deletionAttempts, name)
+		}
+	}
+}
+
+// stuckDeletions returns the nodes of the node group that need attention, sorted by name
+func stuckDeletions(nodeGroup *NodeGroupState) []StuckDeletion {
+	var stuck []StuckDeletion
+	for name, attempts := range nodeGroup.deletionAttempts {
+		if attempts.needsAttention() {
+			stuck = append(stuck, StuckDeletion{
+				NodeGroup: nodeGroup.Opts.Name,
+				Node:      name,
+				Attempts:  attempts.failures,
+				LastError: attempts.lastError,
+			})
+		}
+	}
+	sort.Slice(stuck, func(i, j int) bool { return stuck[i].Node < stuck[j].Node })
+	return stuck
+}
+
+// updateStuckDeletions exports the nodes that need attention in the report and as metrics
+func (c *Controller) updateStuckDeletions() {
+	var stuck []StuckDeletion
+	for _, nodeGroupOpts := range c.Opts.NodeGroups {
+		nodeGroup, ok := c.nodeGroups[nodeGroupOpts.Name]
+		if !ok {
+			continue
+		}
+		nodeGroupStuck := stuckDeletions(nodeGroup)
+		metrics.NodeGroupStuckDeletions.WithLabelValues(nodeGroupOpts.Name).Set(float64(len(nodeGroupStuck)))
+		stuck = append(stuck, nodeGroupStuck...)
+	}
+
+	c.reportLock.Lock()
+	c.report.StuckDeletions = stuck
+	c.reportLock.Unlock()
+}
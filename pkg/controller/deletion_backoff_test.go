@@ -0,0 +1,55 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/atlassian/escalator/pkg/errorkind"
+	"github.com/atlassian/escalator/pkg/test"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestDeletionBackoff(t *testing.T) {
+	node := test.BuildTestNode(test.NodeOpts{Name: "n1", Tainted: true})
+	nodeGroup := &NodeGroupState{Opts: NodeGroupOptions{Name: "default"}}
+	now := time.Now()
+	assert.False(t, deletionBackingOff(nodeGroup, node, now))
+
+	// doubles up to deletionBackoffMax
+	wantBackoffs := []time.Duration{
+		time.Minute, 2 * time.Minute, 4 * time.Minute, 8 * time.Minute, 16 * time.Minute, 32 * time.Minute,
+		time.Hour, time.Hour, time.Hour,
+	}
+	for i, backoff := range wantBackoffs {
+		recordDeletionFailure(nodeGroup, []*v1.Node{node}, errors.New("volume is still attached"), now)
+		assert.Equal(t, i+1, nodeGroup.deletionAttempts["n1"].failures)
+		assert.True(t, deletionBackingOff(nodeGroup, node, now.Add(backoff-time.Second)), "backoff %v", backoff)
+		assert.False(t, deletionBackingOff(nodeGroup, node, now.Add(backoff)), "backoff %v", backoff)
+		assert.Empty(t, stuckDeletions(nodeGroup))
+	}
+
+	// the last attempt leaves the node for a human
+	recordDeletionFailure(nodeGroup, []*v1.Node{node}, errors.New("volume is still attached"), now)
+	assert.True(t, deletionBackingOff(nodeGroup, node, now.Add(24*time.Hour)))
+	assert.Equal(t, []StuckDeletion{{NodeGroup: "default", Node: "n1", Attempts: deletionMaxAttempts, LastError: "volume is still attached"}}, stuckDeletions(nodeGroup))
+}
+
+func TestDeletionBackoff_Throttled(t *testing.T) {
+	node := test.BuildTestNode(test.NodeOpts{Name: "n1", Tainted: true})
+	nodeGroup := &NodeGroupState{Opts: NodeGroupOptions{Name: "default"}}
+	recordDeletionFailure(nodeGroup, []*v1.Node{node}, errorkind.New(errorkind.Throttled, "rate exceeded"), time.Now())
+	assert.False(t, deletionBackingOff(nodeGroup, node, time.Now()))
+	assert.Empty(t, nodeGroup.deletionAttempts)
+
+	recordDeletionFailure(nodeGroup, []*v1.Node{node}, errorkind.New(errorkind.Conflict, "node group is updating"), time.Now())
+	assert.Empty(t, nodeGroup.deletionAttempts)
+}
+
+func TestPruneDeletionAttempts(t *testing.T) {
+	nodeGroup := &NodeGroupState{deletionAttempts: map[string]*deletionAttempts{"tainted": {failures: 1}, "untainted": {failures: 1}}}
+	pruneDeletionAttempts(nodeGroup, []*v1.Node{test.BuildTestNode(test.NodeOpts{Name: "tainted", Tainted: true})})
+	assert.Len(t, nodeGroup.deletionAttempts, 1)
+	assert.Contains(t, nodeGroup.deletionAttempts, "tainted")
+}
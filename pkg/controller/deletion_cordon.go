@@ -0,0 +1,125 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/atlassian/escalator/pkg/cloudprovider"
+	"github.com/atlassian/escalator/pkg/k8s"
+	"github.com/atlassian/escalator/pkg/metrics"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+)
+
+// terminationVerifyTimeout is how long the instance of a deleted node has to leave its cloud provider node group before
+// the termination is reported as unverified
+const terminationVerifyTimeout = 10 * time.Minute
+
+const (
+	// TerminationVerified is a termination whose instance left the cloud provider node group
+	TerminationVerified = "verified"
+	// TerminationUnverified is a termination whose instance was still in the cloud provider node group after
+	// terminationVerifyTimeout
+	TerminationUnverified = "unverified"
+)
+
+// pendingTermination is a node whose instance the cloud provider was asked to terminate
+type pendingTermination struct {
+	instanceID  string
+	requestedAt time.Time
+}
+
+// cordonForDeletion cordons the node about to be deleted, and returns the cordoned node and whether it is cordoned. A
+// node that failed to be cordoned is left for the next run
+func (c *Controller) cordonForDeletion(nodeGroup *NodeGroupState, node *v1.Node) (*v1.Node, bool) {
+	updatedNode, err := k8s.CordonForDeletion(node, c.Client)
+	if err != nil {
+		log.WithField("nodegroup", nodeGroup.Opts.Name).WithError(err).Errorf("failed to cordon node %v, not deleting it this run", node.Name)
+		return node, false
+	}
+	if !updatedNode.Spec.Unschedulable {
+		log.WithField("nodegroup", nodeGroup.Opts.Name).Errorf("node %v is still schedulable after cordoning it, not deleting it this run", node.Name)
+		return node, false
+	}
+	return updatedNode, true
+}
+
+// countCordonedForDeletion returns how many of the nodes are cordoned for deletion and not deleted yet
+func countCordonedForDeletion(nodes []*v1.Node) int {
+	count := 0
+	for _, node := range nodes {
+		if k8s.CordonedForDeletion(node) {
+			count++
+		}
+	}
+	return count
+}
+
+// uncordonAbandonedDeletions uncordons the nodes cordoned for deletion that are no longer going to be deleted: the
+// untainted nodes, and the tainted nodes that failed to be deleted too many times to be retried
+func (c *Controller) uncordonAbandonedDeletions(nodeGroup *NodeGroupState, untaintedNodes []*v1.Node, taintedNodes []*v1.Node) {
+	abandoned := make([]*v1.Node, 0)
+	for _, node := range untaintedNodes {
+		if k8s.CordonedForDeletion(node) {
+			abandoned = append(abandoned, node)
+		}
+	}
+	for _, node := range taintedNodes {
+		if attempts, ok := nodeGroup.deletionAttempts[node.Name]; ok && attempts.needsAttention() && k8s.CordonedForDeletion(node) {
+			abandoned = append(abandoned, node)
+		}
+	}
+
+	for _, node := range abandoned {
+		logger := log.WithField("nodegroup", nodeGroup.Opts.Name).WithField("node", node.Name)
+		if _, err := k8s.UncordonAbandonedDeletion(node, c.Client); err != nil {
+			logger.WithError(err).Error("failed to uncordon node after its deletion was abandoned, retrying next run")
+			continue
+		}
+		logger.Warning("Uncordoned node after its deletion was abandoned")
+	}
+}
+
+// recordPendingTerminations remembers the instances of the deleted nodes, to verify they leave the cloud provider node
+// group
+func recordPendingTerminations(nodeGroup *NodeGroupState, nodes []*v1.Node, now time.Time) {
+	for _, node := range nodes {
+		providerID, err := cloudprovider.NodeProviderID(node)
+		if err != nil {
+			continue
+		}
+		if nodeGroup.pendingTerminations == nil {
+			nodeGroup.pendingTerminations = make(map[string]pendingTermination)
+		}
+		nodeGroup.pendingTerminations[node.Name] = pendingTermination{instanceID: providerID.InstanceID, requestedAt: now}
+	}
+}
+
+// verifyTerminations checks the instances of the deleted nodes left the cloud provider node group. A termination is
+// verified once its instance is gone, and reported as unverified if it is still there after terminationVerifyTimeout
+func verifyTerminations(nodeGroup *NodeGroupState, cloudProviderNodeGroup cloudprovider.NodeGroup, now time.Time) {
+	if len(nodeGroup.pendingTerminations) == 0 {
+		return
+	}
+	instances := make(map[string]bool)
+	for _, id := range cloudProviderNodeGroup.Nodes() {
+		providerID, err := cloudprovider.ParseProviderID(id)
+		if err != nil {
+			continue
+		}
+		instances[providerID.InstanceID] = true
+	}
+
+	for name, pending := range nodeGroup.pendingTerminations {
+		logger := log.WithField("nodegroup", nodeGroup.Opts.Name).WithField("node", name)
+		switch {
+		case !instances[pending.instanceID]:
+			logger.Debugf("instance %v left the cloud provider node group after %v", pending.instanceID, now.Sub(pending.requestedAt))
+			metrics.NodeGroupTerminationVerifications.WithLabelValues(nodeGroup.Opts.Name, TerminationVerified).Add(1.0)
+			delete(nodeGroup.pendingTerminations, name)
+		case now.Sub(pending.requestedAt) > terminationVerifyTimeout:
+			logger.Errorf("instance %v is still in cloud provider node group %v %v after it was terminated", pending.instanceID, cloudProviderNodeGroup.ID(), now.Sub(pending.requestedAt))
+			metrics.NodeGroupTerminationVerifications.WithLabelValues(nodeGroup.Opts.Name, TerminationUnverified).Add(1.0)
+			delete(nodeGroup.pendingTerminations, name)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/atlassian/escalator/pkg/k8s"
+	"github.com/atlassian/escalator/pkg/test"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+)
+
+// instancesNodeGroup is a cloud provider node group with the given instances
+type instancesNodeGroup struct {
+	*test.NodeGroup
+	instances []string
+}
+
+func (n *instancesNodeGroup) Nodes() []string {
+	return n.instances
+}
+
+func TestVerifyTerminations(t *testing.T) {
+	now := time.Now()
+	n1 := test.BuildTestNode(test.NodeOpts{Name: "n1"})
+	n1.Spec.ProviderID = "aws:///us-east-1a/i-1"
+	n2 := test.BuildTestNode(test.NodeOpts{Name: "n2"})
+	n2.Spec.ProviderID = "aws:///us-east-1a/i-2"
+	// nodes without a provider id can't be verified
+	n3 := test.BuildTestNode(test.NodeOpts{Name: "n3"})
+	n3.Spec.ProviderID = ""
+
+	nodeGroup := &NodeGroupState{Opts: NodeGroupOptions{Name: "default"}}
+	recordPendingTerminations(nodeGroup, []*v1.Node{n1, n2, n3}, now)
+	assert.Len(t, nodeGroup.pendingTerminations, 2)
+
+	cloudProviderNodeGroup := &instancesNodeGroup{
+		NodeGroup: test.NewNodeGroup("asg", 0, 10, 2),
+		instances: []string{"aws:///us-east-1a/i-2"},
+	}
+
+	// the terminated instance left the node group
+	verifyTerminations(nodeGroup, cloudProviderNodeGroup, now.Add(time.Minute))
+	assert.Len(t, nodeGroup.pendingTerminations, 1)
+	assert.Contains(t, nodeGroup.pendingTerminations, "n2")
+
+	// the other one is still there within the timeout
+	verifyTerminations(nodeGroup, cloudProviderNodeGroup, now.Add(terminationVerifyTimeout))
+	assert.Contains(t, nodeGroup.pendingTerminations, "n2")
+
+	// and is reported as unverified after it
+	verifyTerminations(nodeGroup, cloudProviderNodeGroup, now.Add(terminationVerifyTimeout+time.Second))
+	assert.Empty(t, nodeGroup.pendingTerminations)
+}
+
+func TestCordonForDeletion(t *testing.T) {
+	nodes := []*v1.Node{
+		test.BuildTestNode(test.NodeOpts{Name: "n1", Tainted: true}),
+		test.BuildTestNode(test.NodeOpts{Name: "n2", Tainted: true}),
+	}
+	client, _ := test.BuildFakeClient(nodes[:1], nil)
+	c := &Controller{Client: &Client{Interface: client}}
+	nodeGroup := &NodeGroupState{Opts: NodeGroupOptions{Name: "default"}}
+
+	cordoned, ok := c.cordonForDeletion(nodeGroup, nodes[0])
+	if assert.True(t, ok) {
+		assert.Equal(t, "n1", cordoned.Name)
+		assert.True(t, k8s.CordonedForDeletion(cordoned))
+	}
+	// the node that can't be cordoned isn't deleted
+	_, ok = c.cordonForDeletion(nodeGroup, nodes[1])
+	assert.False(t, ok)
+	assert.Equal(t, 1, countCordonedForDeletion(nodes))
+}
+
+func TestUncordonAbandonedDeletions(t *testing.T) {
+	cordonedForDeletion := func(name string, tainted bool) *v1.Node {
+		node := test.BuildTestNode(test.NodeOpts{Name: name, Tainted: tainted})
+		node.Spec.Unschedulable = true
+		node.Annotations = map[string]string{k8s.ToBeDeletedCordonAnnotationKey: "1500000000"}
+		return node
+	}
+	untainted := cordonedForDeletion("untainted", false)
+	retrying := cordonedForDeletion("retrying", true)
+	stuck := cordonedForDeletion("stuck", true)
+
+	client, _ := test.BuildFakeClient([]*v1.Node{untainted, retrying, stuck}, nil)
+	c := &Controller{Client: &Client{Interface: client}}
+	nodeGroup := &NodeGroupState{
+		Opts: NodeGroupOptions{Name: "default"},
+		deletionAttempts: map[string]*deletionAttempts{
+			"retrying": {failures: 1},
+			"stuck":    {failures: deletionMaxAttempts},
+		},
+	}
+
+	c.uncordonAbandonedDeletions(nodeGroup, []*v1.Node{untainted}, []*v1.Node{retrying, stuck})
+	assert.False(t, k8s.CordonedForDeletion(untainted))
+	assert.True(t, k8s.CordonedForDeletion(retrying))
+	assert.False(t, k8s.CordonedForDeletion(stuck))
+}
@@ -0,0 +1,78 @@
+package controller
+
+import (
+	"encoding/json"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Diagnostics is the full internal state of the controller, for debugging
+type Diagnostics struct {
+	NodeGroups     map[string]NodeGroupDiagnostics `json:"node_groups"`
+	NodeMembership map[string]string               `json:"node_membership"`
+	Report         Report                          `json:"report"`
+}
+
+// NodeGroupDiagnostics is the internal state of a node group
+type NodeGroupDiagnostics struct {
+	Opts                       NodeGroupOptions  `json:"options"`
+	TaintTracker               []DryModeTaint    `json:"taint_tracker"`
+	ScaleDelta                 int               `json:"scale_delta"`
+	LastScaleOut               time.Time         `json:"last_scale_out"`
+	ScaleUpLocked              bool              `json:"scale_up_locked"`
+	ScaleUpLockTime            time.Time         `json:"scale_up_lock_time"`
+	ScaleUpLockRequestedNodes  int               `json:"scale_up_lock_requested_nodes"`
+	ScaleUpLockMinimumDuration string            `json:"scale_up_lock_minimum_duration"`
+	BootDurationPercentiles    map[string]string `json:"boot_duration_percentiles"`
+	ReplacementPending         bool              `json:"replacement_pending"`
+	UtilisationPercent         float64           `json:"utilisation_percent"`
+	UtilisationKnown           bool              `json:"utilisation_known"`
+	LastCycle                  CycleSummary      `json:"last_cycle"`
+}
+
+// RequestDiagnostics asks the main loop to log the diagnostics between runs. It doesn't block
+func (c *Controller) RequestDiagnostics() {
+	select {
+	case c.diagnosticsChan <- struct{}{}:
+	default:
+		// a dump is already pending
+	}
+}
+
+// Diagnostics returns the internal state of the controller. It must only be called from the main loop
+func (c *Controller) Diagnostics() Diagnostics {
+	diagnostics := Diagnostics{
+		NodeGroups:     make(map[string]NodeGroupDiagnostics, len(c.nodeGroups)),
+		NodeMembership: c.nodeMembership,
+		Report:         c.Report(),
+	}
+	for name, nodeGroup := range c.nodeGroups {
+		diagnostics.NodeGroups[name] = NodeGroupDiagnostics{
+			Opts:                       nodeGroup.Opts,
+			TaintTracker:               nodeGroup.taintTracker,
+			ScaleDelta:                 nodeGroup.scaleDelta,
+			LastScaleOut:               nodeGroup.lastScaleOut,
+			ScaleUpLocked:              nodeGroup.scaleUpLock.isLocked,
+			ScaleUpLockTime:            nodeGroup.scaleUpLock.lockTime,
+			ScaleUpLockRequestedNodes:  nodeGroup.scaleUpLock.requestedNodes,
+			ScaleUpLockMinimumDuration: nodeGroup.scaleUpLock.minimumLockDuration.String(),
+			BootDurationPercentiles:    nodeGroup.bootDurations.percentiles(),
+			ReplacementPending:         nodeGroup.replacementPending,
+			UtilisationPercent:         nodeGroup.utilisationPercent,
+			UtilisationKnown:           nodeGroup.utilisationKnown,
+			LastCycle:                  nodeGroup.cycle,
+		}
+	}
+	return diagnostics
+}
+
+// logDiagnostics logs the internal state of the controller as json
+func (c *Controller) logDiagnostics() {
+	diagnostics, err := json.Marshal(c.Diagnostics())
+	if err != nil {
+		log.WithError(err).Error("Failed to encode diagnostics")
+		return
+	}
+	log.Infof("Diagnostics: %s", diagnostics)
+}
@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"sort"
+
+	"github.com/atlassian/escalator/pkg/k8s"
+	"github.com/atlassian/escalator/pkg/metrics"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// DiscoveryReport describes how well the configured node groups match the nodes in the cluster.
+// Nodes that match no node group and node groups that match no nodes usually mean the config has drifted
+// from the cluster, e.g. a label was changed or a new pool of nodes was added
+type DiscoveryReport struct {
+	UnmatchedNodes  []string `json:"unmatched_nodes"`
+	EmptyNodeGroups []string `json:"empty_node_groups"`
+}
+
+// buildDiscoveryReport works out which nodes match none of the node groups and which node groups match none of the nodes
+// Nodes are matched by the labels and platform of the node groups, the same as their listers
+func buildDiscoveryReport(nodes []*v1.Node, nodeGroups []NodeGroupOptions) DiscoveryReport {
+	report := DiscoveryReport{
+		UnmatchedNodes:  []string{},
+		EmptyNodeGroups: []string{},
+	}
+
+	matches := make(map[string]int, len(nodeGroups))
+	for _, node := range nodes {
+		matched := false
+		for _, nodeGroup := range nodeGroups {
+			if newNodeGroupNodeFilterFunc(nodeGroup)(node) {
+				matches[nodeGroup.Name]++
+				matched = true
+			}
+		}
+		// nodes outside of the node label domain belong to another escalator instance
+		if !matched && k8s.NodeInLabelDomain(node) {
+			report.UnmatchedNodes = append(report.UnmatchedNodes, node.Name)
+		}
+	}
+
+	for _, nodeGroup := range nodeGroups {
+		if matches[nodeGroup.Name] == 0 {
+			report.EmptyNodeGroups = append(report.EmptyNodeGroups, nodeGroup.Name)
+		}
+	}
+
+	sort.Strings(report.UnmatchedNodes)
+	return report
+}
+
+// updateDiscoveryHealth rebuilds the discovery report from the node cache and exports it as metrics
+func (c *Controller) updateDiscoveryHealth() {
+	if c.Client == nil || c.Client.allNodeLister == nil {
+		return
+	}
+
+	nodes, err := c.Client.allNodeLister.List(labels.Everything())
+	if err != nil {
+		log.WithError(err).Warn("Failed to list nodes for node group discovery")
+		return
+	}
+
+	report := buildDiscoveryReport(nodes, c.Opts.NodeGroups)
+	if len(report.UnmatchedNodes) > 0 {
+		log.Debugf("%v nodes don't match any node group: %v", len(report.UnmatchedNodes), report.UnmatchedNodes)
+	}
+
+	metrics.UnmatchedNodes.Set(float64(len(report.UnmatchedNodes)))
+	for _, nodeGroup := range c.Opts.NodeGroups {
+		metrics.NodeGroupEmpty.WithLabelValues(nodeGroup.Name).Set(0)
+	}
+	for _, name := range report.EmptyNodeGroups {
+		log.WithField("nodegroup", name).Debug("node group doesn't match any nodes")
+		metrics.NodeGroupEmpty.WithLabelValues(name).Set(1)
+	}
+
+	c.reportLock.Lock()
+	c.report.Discovery = report
+	c.reportLock.Unlock()
+}
@@ -0,0 +1,60 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/atlassian/escalator/pkg/k8s"
+	"github.com/atlassian/escalator/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestBuildDiscoveryReport(t *testing.T) {
+	nodes := []*v1.Node{
+		test.BuildTestNode(test.NodeOpts{Name: "n1", LabelKey: "customer", LabelValue: "shared"}),
+		test.BuildTestNode(test.NodeOpts{Name: "n2", LabelKey: "customer", LabelValue: "buildeng"}),
+		test.BuildTestNode(test.NodeOpts{Name: "n4", LabelKey: "customer", LabelValue: "unknown"}),
+		test.BuildTestNode(test.NodeOpts{Name: "n3", LabelKey: "role", LabelValue: "master"}),
+	}
+	nodeGroups := []NodeGroupOptions{
+		{Name: "shared", LabelKey: "customer", LabelValue: "shared"},
+		{Name: "buildeng", LabelKey: "customer", LabelValue: "buildeng"},
+		{Name: "gpu", LabelKey: "customer", LabelValue: "gpu"},
+	}
+
+	report := buildDiscoveryReport(nodes, nodeGroups)
+	assert.Equal(t, []string{"n3", "n4"}, report.UnmatchedNodes)
+	assert.Equal(t, []string{"gpu"}, report.EmptyNodeGroups)
+
+	// node groups sharing a label only match the nodes of their platform
+	arm := test.BuildTestNode(test.NodeOpts{Name: "n5", LabelKey: "customer", LabelValue: "gpu"})
+	arm.Labels[k8s.ArchLabelKey] = k8s.ArchARM64
+	nodeGroups[2].Arch = k8s.ArchAMD64
+	report = buildDiscoveryReport(append(nodes, arm), nodeGroups)
+	assert.Equal(t, []string{"n3", "n4", "n5"}, report.UnmatchedNodes)
+	assert.Equal(t, []string{"gpu"}, report.EmptyNodeGroups)
+
+	empty := buildDiscoveryReport(nil, nil)
+	assert.Equal(t, []string{}, empty.UnmatchedNodes)
+	assert.Equal(t, []string{}, empty.EmptyNodeGroups)
+}
+
+func TestBuildDiscoveryReport_NodeLabelDomain(t *testing.T) {
+	defer k8s.SetNodeLabelDomain("")
+	require.NoError(t, k8s.SetNodeLabelDomain("team-a.example.com"))
+
+	nodes := []*v1.Node{
+		test.BuildTestNode(test.NodeOpts{Name: "n1", LabelKey: "team-a.example.com/customer", LabelValue: "shared"}),
+		test.BuildTestNode(test.NodeOpts{Name: "n2", LabelKey: "team-a.example.com/customer", LabelValue: "unknown"}),
+		test.BuildTestNode(test.NodeOpts{Name: "n3", LabelKey: "team-b.example.com/customer", LabelValue: "shared"}),
+	}
+	nodeGroups := []NodeGroupOptions{
+		{Name: "shared", LabelKey: "team-a.example.com/customer", LabelValue: "shared"},
+	}
+
+	// the nodes of the other instance aren't unmatched
+	report := buildDiscoveryReport(nodes, nodeGroups)
+	assert.Equal(t, []string{"n2"}, report.UnmatchedNodes)
+	assert.Equal(t, []string{}, report.EmptyNodeGroups)
+}
@@ -0,0 +1,127 @@
+package controller
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/atlassian/escalator/pkg/k8s"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+)
+
+// RestartCostAnnotationKey is the pod annotation adding the cost of restarting the pod to the disruption score of its
+// node, e.g. "10" for a pod with a slow warm up
+const RestartCostAnnotationKey = "atlassian.com/escalator-restart-cost"
+
+// NodeDisruptionScore is the disruption score of an untainted node from the last run, served by the report endpoint
+type NodeDisruptionScore struct {
+	NodeGroup string  `json:"node_group"`
+	Node      string  `json:"node"`
+	Score     float64 `json:"score"`
+	Pods      int     `json:"pods"`
+}
+
+// weight returns the weight, defaulting to 1
+func weight(w float64) float64 {
+	if w == 0 {
+		return 1
+	}
+	return w
+}
+
+// podLocalDataGiB returns the local data of the pod in GiB, the larger of the ephemeral storage requested by its
+// containers and the size limits of its emptyDir volumes on disk
+func podLocalDataGiB(pod *v1.Pod) float64 {
+	var requested, emptyDirs int64
+	for _, container := range pod.Spec.Containers {
+		if storage, ok := container.Resources.Requests[v1.ResourceEphemeralStorage]; ok {
+			requested += storage.Value()
+		}
+	}
+	for _, volume := range pod.Spec.Volumes {
+		if volume.EmptyDir == nil || volume.EmptyDir.Medium == v1.StorageMediumMemory || volume.EmptyDir.SizeLimit == nil {
+			continue
+		}
+		emptyDirs += volume.EmptyDir.SizeLimit.Value()
+	}
+	if emptyDirs > requested {
+		requested = emptyDirs
+	}
+	return float64(requested) / (1 << 30)
+}
+
+// podIsJob returns if the pod is run by a Job
+func podIsJob(pod *v1.Pod) bool {
+	for _, ownerReference := range pod.ObjectMeta.OwnerReferences {
+		if ownerReference.Kind == "Job" {
+			return true
+		}
+	}
+	return false
+}
+
+// podDisruptionScore returns the disruption of moving the pod: the pod weight, its restart cost annotation, its local
+// data and, for pods of Jobs, the progress lost by restarting it
+func podDisruptionScore(opts DisruptionScoreOptions, pod *v1.Pod, now time.Time) float64 {
+	score := weight(opts.PodWeight)
+	if value, ok := pod.Annotations[RestartCostAnnotationKey]; ok {
+		cost, err := strconv.ParseFloat(value, 64)
+		if err != nil || cost < 0 {
+			log.Warnf("Ignoring the invalid %v annotation %q of pod %v/%v", RestartCostAnnotationKey, value, pod.Namespace, pod.Name)
+		} else {
+			score += cost
+		}
+	}
+	score += weight(opts.LocalDataWeight) * podLocalDataGiB(pod)
+	if podIsJob(pod) && pod.Status.StartTime != nil {
+		score += weight(opts.JobWeight) * now.Sub(pod.Status.StartTime.Time).Hours()
+	}
+	return score
+}
+
+// nodeDisruptionScore returns the disruption score of the node, the sum of the scores of its running pods except for
+// DaemonSet pods, and the number of those pods
+func nodeDisruptionScore(nodeGroup *NodeGroupState, node *v1.Node, now time.Time) (float64, int) {
+	var score float64
+	var pods int
+	for _, pod := range k8s.NodeReschedulablePods(node, nodeGroup.NodeInfoMap) {
+		if pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+			continue
+		}
+		score += podDisruptionScore(nodeGroup.Opts.DisruptionScore, pod, now)
+		pods++
+	}
+	return score, pods
+}
+
+// nodeDisruptionScores returns the disruption scores of the nodes, lowest first
+func nodeDisruptionScores(nodeGroup *NodeGroupState, nodes []*v1.Node, now time.Time) []NodeDisruptionScore {
+	scores := make([]NodeDisruptionScore, 0, len(nodes))
+	for _, node := range nodes {
+		score, pods := nodeDisruptionScore(nodeGroup, node, now)
+		scores = append(scores, NodeDisruptionScore{NodeGroup: nodeGroup.Opts.Name, Node: node.Name, Score: score, Pods: pods})
+	}
+	sort.SliceStable(scores, func(i, j int) bool {
+		if scores[i].Score != scores[j].Score {
+			return scores[i].Score < scores[j].Score
+		}
+		return scores[i].Node < scores[j].Node
+	})
+	return scores
+}
+
+// updateDisruptionScores exports the disruption scores of the untainted nodes of every node group in the report, whether
+// or not the node group taints by them
+func (c *Controller) updateDisruptionScores() {
+	var scores []NodeDisruptionScore
+	for _, nodeGroupOpts := range c.Opts.NodeGroups {
+		if nodeGroup, ok := c.nodeGroups[nodeGroupOpts.Name]; ok {
+			scores = append(scores, nodeGroup.disruptionScores...)
+		}
+	}
+
+	c.reportLock.Lock()
+	c.report.DisruptionScores = scores
+	c.reportLock.Unlock()
+}
@@ -0,0 +1,153 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/atlassian/escalator/pkg/k8s"
+	"github.com/atlassian/escalator/pkg/test"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPodDisruptionScore(t *testing.T) {
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	annotated := func(value string) *v1.Pod {
+		pod := test.BuildTestPod(test.PodOpts{Name: "p1"})
+		pod.Annotations = map[string]string{RestartCostAnnotationKey: value}
+		return pod
+	}
+	ephemeral := test.BuildTestPod(test.PodOpts{Name: "p1", CPU: []int64{100}, Mem: []int64{100}})
+	ephemeral.Spec.Containers[0].Resources.Requests[v1.ResourceEphemeralStorage] = resource.MustParse("2Gi")
+	emptyDir := func(medium v1.StorageMedium) *v1.Pod {
+		pod := test.BuildTestPod(test.PodOpts{Name: "p1"})
+		sizeLimit := resource.MustParse("4Gi")
+		pod.Spec.Volumes = []v1.Volume{
+			{Name: "scratch", VolumeSource: v1.VolumeSource{EmptyDir: &v1.EmptyDirVolumeSource{Medium: medium, SizeLimit: &sizeLimit}}},
+		}
+		return pod
+	}
+	job := test.BuildTestPod(test.PodOpts{Name: "p1", Owner: "Job"})
+	job.Status.StartTime = &metav1.Time{Time: now.Add(-90 * time.Minute)}
+
+	tests := []struct {
+		name string
+		opts DisruptionScoreOptions
+		pod  *v1.Pod
+		want float64
+	}{
+		{"pod", DisruptionScoreOptions{}, test.BuildTestPod(test.PodOpts{Name: "p1"}), 1},
+		{"pod weight", DisruptionScoreOptions{PodWeight: 2}, test.BuildTestPod(test.PodOpts{Name: "p1"}), 2},
+		{"restart cost", DisruptionScoreOptions{}, annotated("10"), 11},
+		{"invalid restart cost", DisruptionScoreOptions{}, annotated("lots"), 1},
+		{"negative restart cost", DisruptionScoreOptions{}, annotated("-5"), 1},
+		{"ephemeral storage", DisruptionScoreOptions{}, ephemeral, 3},
+		{"emptyDir", DisruptionScoreOptions{LocalDataWeight: 0.5}, emptyDir(v1.StorageMediumDefault), 3},
+		{"emptyDir in memory", DisruptionScoreOptions{}, emptyDir(v1.StorageMediumMemory), 1},
+		{"job", DisruptionScoreOptions{JobWeight: 2}, job, 4},
+		{"not a job", DisruptionScoreOptions{JobWeight: 2}, test.BuildTestPod(test.PodOpts{Name: "p1", Owner: "ReplicaSet"}), 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.InDelta(t, tt.want, podDisruptionScore(tt.opts, tt.pod, now), 0.001)
+		})
+	}
+}
+
+func TestNodeDisruptionScores(t *testing.T) {
+	nodes := []*v1.Node{
+		test.BuildTestNode(test.NodeOpts{Name: "n1"}),
+		test.BuildTestNode(test.NodeOpts{Name: "n2"}),
+		test.BuildTestNode(test.NodeOpts{Name: "n3"}),
+	}
+	finished := test.BuildTestPod(test.PodOpts{Name: "done", NodeName: "n2"})
+	finished.Status.Phase = v1.PodSucceeded
+	pods := []*v1.Pod{
+		test.BuildTestPod(test.PodOpts{Name: "p1", NodeName: "n1"}),
+		test.BuildTestPod(test.PodOpts{Name: "p2", NodeName: "n1"}),
+		test.BuildTestPod(test.PodOpts{Name: "p3", NodeName: "n2"}),
+		test.BuildTestPod(test.PodOpts{Name: "agent", NodeName: "n2", Owner: "DaemonSet"}),
+		finished,
+	}
+	nodeGroup := &NodeGroupState{
+		Opts:        NodeGroupOptions{Name: "default"},
+		NodeInfoMap: k8s.CreateNodeNameToInfoMap(pods, nodes),
+	}
+
+	assert.Equal(t, []NodeDisruptionScore{
+		{NodeGroup: "default", Node: "n3", Score: 0, Pods: 0},
+		{NodeGroup: "default", Node: "n2", Score: 1, Pods: 1},
+		{NodeGroup: "default", Node: "n1", Score: 2, Pods: 2},
+	}, nodeDisruptionScores(nodeGroup, nodes, time.Now()))
+}
+
+func TestControllerTaintOldestN_DisruptionScore(t *testing.T) {
+	nodes := []*v1.Node{
+		0: test.BuildTestNode(test.NodeOpts{Name: "n1", Creation: time.Date(2010, 3, 3, 13, 0, 0, 0, time.UTC)}),
+		1: test.BuildTestNode(test.NodeOpts{Name: "n2", Creation: time.Date(2011, 3, 3, 13, 0, 0, 0, time.UTC)}),
+		2: test.BuildTestNode(test.NodeOpts{Name: "n3", Creation: time.Date(2012, 3, 3, 13, 0, 0, 0, time.UTC)}),
+	}
+	nodes[2].Status.Conditions = []v1.NodeCondition{{Type: "KernelDeadlock", Status: v1.ConditionTrue}}
+	costly := test.BuildTestPod(test.PodOpts{Name: "costly", NodeName: "n2"})
+	costly.Annotations = map[string]string{RestartCostAnnotationKey: "10"}
+	pods := []*v1.Pod{
+		test.BuildTestPod(test.PodOpts{Name: "p1", NodeName: "n1"}),
+		test.BuildTestPod(test.PodOpts{Name: "p2", NodeName: "n1"}),
+		test.BuildTestPod(test.PodOpts{Name: "p3", NodeName: "n3"}),
+		test.BuildTestPod(test.PodOpts{Name: "p4", NodeName: "n3"}),
+		test.BuildTestPod(test.PodOpts{Name: "p5", NodeName: "n3"}),
+		costly,
+	}
+
+	tests := []struct {
+		name       string
+		enabled    bool
+		conditions []string
+		want       []int
+	}{
+		{"oldest first when disabled", false, nil, []int{0, 1}},
+		{"least disruption first", true, nil, []int{0, 2}},
+		{"unhealthy node first", true, []string{"KernelDeadlock"}, []int{2, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodeGroup := &NodeGroupState{
+				Opts: NodeGroupOptions{
+					Name:                    "buildeng",
+					AggressiveScaleDown:     true,
+					UnhealthyNodeConditions: tt.conditions,
+					DisruptionScore:         DisruptionScoreOptions{Enabled: tt.enabled},
+				},
+				NodeInfoMap: k8s.CreateNodeNameToInfoMap(pods, nodes),
+			}
+			c := &Controller{
+				Opts: Opts{DryMode: true},
+			}
+
+			budget := k8s.NewTaintBudget(2)
+			got := c.taintOldestN(context.Background(), nodes, nodeGroup, budget)
+			assert.NoError(t, budget.Validate(len(got)))
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestUpdateDisruptionScores(t *testing.T) {
+	c := &Controller{
+		Opts: Opts{NodeGroups: []NodeGroupOptions{{Name: "shared"}, {Name: "gpu"}, {Name: "new"}}},
+		nodeGroups: map[string]*NodeGroupState{
+			"shared": {disruptionScores: []NodeDisruptionScore{{NodeGroup: "shared", Node: "n1", Score: 1, Pods: 1}}},
+			"gpu":    {disruptionScores: []NodeDisruptionScore{{NodeGroup: "gpu", Node: "n2", Score: 0}}},
+		},
+	}
+
+	c.updateDisruptionScores()
+	assert.Equal(t, []NodeDisruptionScore{
+		{NodeGroup: "shared", Node: "n1", Score: 1, Pods: 1},
+		{NodeGroup: "gpu", Node: "n2", Score: 0},
+	}, c.Report().DisruptionScores)
+}
@@ -0,0 +1,64 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/atlassian/escalator/pkg/k8s"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	// SoftDeleteGracePeriodFromTaint starts the soft delete grace period when the node is tainted
+	SoftDeleteGracePeriodFromTaint = "taint"
+	// SoftDeleteGracePeriodFromEmpty starts the soft delete grace period when the tainted node becomes empty
+	SoftDeleteGracePeriodFromEmpty = "empty"
+)
+
+// softDeleteGracePeriodPassed returns whether the soft delete grace period of the tainted node has passed, and how
+// long is left when it hasn't. Nodes past their hard delete grace period have always passed it. Both periods are extended
+// by the volume_detach delay of the node
+func softDeleteGracePeriodPassed(nodeGroup *NodeGroupState, node *v1.Node, taintedTime time.Time, now time.Time) (bool, time.Duration) {
+	soft := nodeGroup.Opts.SoftDeleteGracePeriodDuration() + volumeDetachDelay(nodeGroup, node)
+	if nodeGroup.Opts.SoftDeleteGracePeriodFrom != SoftDeleteGracePeriodFromEmpty {
+		return now.Sub(taintedTime) > soft, soft - now.Sub(taintedTime)
+	}
+	if now.Sub(taintedTime) > hardDeleteGracePeriod(nodeGroup, node) {
+		return true, 0
+	}
+	emptyFor := trackEmptiness(nodeGroup, node, now)
+	return emptyFor > soft, soft - emptyFor
+}
+
+// trackEmptiness returns how long the node has been empty, or 0 if it isn't empty.
+// The time it was first seen empty is kept in the node group state
+func trackEmptiness(nodeGroup *NodeGroupState, node *v1.Node, now time.Time) time.Duration {
+	if !k8s.NodeEmpty(node, nodeGroup.NodeInfoMap) {
+		delete(nodeGroup.emptySince, node.Name)
+		return 0
+	}
+	since, ok := nodeGroup.emptySince[node.Name]
+	if !ok {
+		if nodeGroup.emptySince == nil {
+			nodeGroup.emptySince = make(map[string]time.Time)
+		}
+		nodeGroup.emptySince[node.Name] = now
+		return 0
+	}
+	return now.Sub(since)
+}
+
+// pruneEmptySince forgets when the nodes that are no longer tainted became empty, e.g. because they were untainted
+func pruneEmptySince(nodeGroup *NodeGroupState, taintedNodes []*v1.Node) {
+	if len(nodeGroup.emptySince) == 0 {
+		return
+	}
+	tainted := make(map[string]bool, len(taintedNodes))
+	for _, node := range taintedNodes {
+		tainted[node.Name] = true
+	}
+	for name := range nodeGroup.emptySince {
+		if !tainted[name] {
+			delete(nodeGroup.emptySince, name)
+		}
+	}
+}
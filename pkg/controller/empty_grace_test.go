@@ -0,0 +1,61 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/atlassian/escalator/pkg/k8s"
+	"github.com/atlassian/escalator/pkg/test"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestSoftDeleteGracePeriodPassed(t *testing.T) {
+	empty := test.BuildTestNode(test.NodeOpts{Name: "empty", Tainted: true})
+	busy := test.BuildTestNode(test.NodeOpts{Name: "busy", Tainted: true})
+	pod := test.BuildTestPod(test.PodOpts{Name: "p1", CPU: []int64{500}, Mem: []int64{100}, NodeName: "busy"})
+	nodeInfoMap := k8s.CreateNodeNameToInfoMap([]*v1.Pod{pod}, []*v1.Node{empty, busy})
+
+	now := time.Now()
+	taintedTime := now.Add(-30 * time.Minute)
+
+	t.Run("from taint", func(t *testing.T) {
+		nodeGroup := &NodeGroupState{
+			Opts:        NodeGroupOptions{SoftDeleteGracePeriod: "10m", HardDeleteGracePeriod: "1h"},
+			NodeInfoMap: nodeInfoMap,
+		}
+		passed, _ := softDeleteGracePeriodPassed(nodeGroup, busy, taintedTime, now)
+		assert.True(t, passed)
+		assert.Empty(t, nodeGroup.emptySince)
+	})
+
+	t.Run("from empty", func(t *testing.T) {
+		nodeGroup := &NodeGroupState{
+			Opts:        NodeGroupOptions{SoftDeleteGracePeriod: "10m", HardDeleteGracePeriod: "1h", SoftDeleteGracePeriodFrom: SoftDeleteGracePeriodFromEmpty},
+			NodeInfoMap: nodeInfoMap,
+		}
+
+		// the timer starts the first time the node is seen empty
+		passed, remaining := softDeleteGracePeriodPassed(nodeGroup, empty, taintedTime, now)
+		assert.False(t, passed)
+		assert.Equal(t, 10*time.Minute, remaining)
+		passed, _ = softDeleteGracePeriodPassed(nodeGroup, empty, taintedTime, now.Add(10*time.Minute+time.Second))
+		assert.True(t, passed)
+
+		// busy nodes never start the timer
+		passed, _ = softDeleteGracePeriodPassed(nodeGroup, busy, taintedTime, now.Add(20*time.Minute))
+		assert.False(t, passed)
+		assert.NotContains(t, nodeGroup.emptySince, "busy")
+
+		// the hard delete grace period still starts at the taint
+		passed, _ = softDeleteGracePeriodPassed(nodeGroup, busy, taintedTime, now.Add(31*time.Minute))
+		assert.True(t, passed)
+	})
+}
+
+func TestPruneEmptySince(t *testing.T) {
+	nodeGroup := &NodeGroupState{emptySince: map[string]time.Time{"tainted": time.Now(), "untainted": time.Now()}}
+	pruneEmptySince(nodeGroup, []*v1.Node{test.BuildTestNode(test.NodeOpts{Name: "tainted", Tainted: true})})
+	assert.Len(t, nodeGroup.emptySince, 1)
+	assert.Contains(t, nodeGroup.emptySince, "tainted")
+}
@@ -0,0 +1,143 @@
+package controller
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	// EventVerbosityNone emits no events for the scaling actions
+	EventVerbosityNone = "none"
+	// EventVerbositySummary emits one event per node group and action in a run, on the escalator pod
+	EventVerbositySummary = "summary"
+	// EventVerbosityNode additionally emits an event on each node acted on
+	EventVerbosityNode = "node"
+)
+
+// EventVerbosities are the valid event verbosities
+var EventVerbosities = []string{EventVerbosityNone, EventVerbositySummary, EventVerbosityNode}
+
+const (
+	// ActionTainted is nodes tainted for removal
+	ActionTainted = "tainted"
+	// ActionUntainted is nodes untainted to scale up
+	ActionUntainted = "untainted"
+	// ActionDeleted is tainted nodes deleted from the cloud provider and kubernetes
+	ActionDeleted = "deleted"
+)
+
+// actionEvents is the reason of the summary and node events emitted for each action, in the order they are emitted
+var actionEvents = []struct {
+	action      string
+	reason      string
+	nodeReason  string
+	description string
+}{
+	{ActionTainted, "NodesTainted", "TaintedForRemoval", "tainted for removal"},
+	{ActionUntainted, "NodesUntainted", "UntaintedForScaleUp", "untainted to scale up"},
+	{ActionDeleted, "NodesDeleted", "DeletedByEscalator", "deleted"},
+}
+
+// maxSummaryNodeNames is how many node names are listed in a summary event before the rest are counted
+const maxSummaryNodeNames = 5
+
+// eventVerbosity returns the event verbosity, defaulting to a summary
+func (c *Controller) eventVerbosity() string {
+	if len(c.Opts.EventVerbosity) == 0 {
+		return EventVerbositySummary
+	}
+	return c.Opts.EventVerbosity
+}
+
+// recordAction collects the nodes acted on for the summary event of the node group, and emits an event on each node
+// with node verbosity. Node events are de-duplicated per node and reason over the dedup window, and limited per run
+func (c *Controller) recordAction(nodeGroup *NodeGroupState, action string, nodes []*v1.Node) {
+	if len(nodes) == 0 || c.Opts.EventRecorder == nil || c.eventVerbosity() == EventVerbosityNone {
+		return
+	}
+	if nodeGroup.pendingActions == nil {
+		nodeGroup.pendingActions = make(map[string][]string)
+	}
+	for _, node := range nodes {
+		nodeGroup.pendingActions[action] = append(nodeGroup.pendingActions[action], node.Name)
+	}
+
+	if c.eventVerbosity() != EventVerbosityNode {
+		return
+	}
+	now := time.Now()
+	for _, event := range actionEvents {
+		if event.action != action {
+			continue
+		}
+		for _, node := range nodes {
+			if !c.allowNodeEvent(node.Name, event.nodeReason, now) {
+				continue
+			}
+			object := &v1.ObjectReference{Kind: "Node", Name: node.Name, UID: node.UID}
+			c.Opts.EventRecorder.Eventf(object, v1.EventTypeNormal, event.nodeReason, "node %v by escalator in node group %v", event.description, nodeGroup.Opts.Name)
+		}
+	}
+}
+
+// allowNodeEvent returns whether an event with the reason can be emitted on the node now, and records it if so
+func (c *Controller) allowNodeEvent(node string, reason string, now time.Time) bool {
+	if c.Opts.MaxNodeEventsPerRun > 0 && c.nodeEventsThisRun >= c.Opts.MaxNodeEventsPerRun {
+		return false
+	}
+	key := node + "/" + reason
+	if last, ok := c.nodeEventsEmitted[key]; ok && now.Sub(last) < c.Opts.EventDedupWindow {
+		return false
+	}
+	if c.nodeEventsEmitted == nil {
+		c.nodeEventsEmitted = make(map[string]time.Time)
+	}
+	c.nodeEventsEmitted[key] = now
+	c.nodeEventsThisRun++
+	return true
+}
+
+// resetNodeEvents starts a new run of node events and forgets the events emitted before the dedup window
+func (c *Controller) resetNodeEvents(now time.Time) {
+	c.nodeEventsThisRun = 0
+	for key, last := range c.nodeEventsEmitted {
+		if now.Sub(last) >= c.Opts.EventDedupWindow {
+			delete(c.nodeEventsEmitted, key)
+		}
+	}
+}
+
+// flushActionEvents emits one event per action collected for the node group since the last flush, on the escalator pod
+func (c *Controller) flushActionEvents(nodeGroup *NodeGroupState) {
+	pending := nodeGroup.pendingActions
+	nodeGroup.pendingActions = nil
+	if len(pending) == 0 || c.Opts.EventObject == nil {
+		return
+	}
+	for _, event := range actionEvents {
+		names := pending[event.action]
+		if len(names) == 0 {
+			continue
+		}
+		message := summariseActions(event.action, nodeGroup.Opts.Name, names)
+		log.WithField("nodegroup", nodeGroup.Opts.Name).Debug(message)
+		c.Opts.EventRecorder.Event(c.Opts.EventObject, v1.EventTypeNormal, event.reason, message)
+	}
+}
+
+// summariseActions describes the nodes an action was taken on, listing the first few node names
+func summariseActions(action string, nodeGroup string, names []string) string {
+	listed := names
+	if len(listed) > maxSummaryNodeNames {
+		listed = listed[:maxSummaryNodeNames]
+	}
+	message := fmt.Sprintf("%v %v nodes in node group %v: %v", action, len(names), nodeGroup, strings.Join(listed, ", "))
+	if len(names) > len(listed) {
+		message += fmt.Sprintf(" and %v more", len(names)-len(listed))
+	}
+	return message
+}
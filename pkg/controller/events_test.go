@@ -0,0 +1,118 @@
+package controller
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/atlassian/escalator/pkg/test"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+func buildNamedTestNodes(amount int) []*v1.Node {
+	nodes := make([]*v1.Node, 0, amount)
+	for i := 0; i < amount; i++ {
+		nodes = append(nodes, test.BuildTestNode(test.NodeOpts{Name: fmt.Sprintf("n%v", i)}))
+	}
+	return nodes
+}
+
+// drainEvents returns the events recorded so far
+func drainEvents(recorder *record.FakeRecorder) []string {
+	var events []string
+	for {
+		select {
+		case event := <-recorder.Events:
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
+}
+
+func TestSummariseActions(t *testing.T) {
+	assert.Equal(t, "tainted 2 nodes in node group default: n0, n1", summariseActions(ActionTainted, "default", []string{"n0", "n1"}))
+	assert.Equal(
+		t,
+		"deleted 7 nodes in node group default: n0, n1, n2, n3, n4 and 2 more",
+		summariseActions(ActionDeleted, "default", []string{"n0", "n1", "n2", "n3", "n4", "n5", "n6"}),
+	)
+}
+
+func TestActionEvents(t *testing.T) {
+	object := &v1.ObjectReference{APIVersion: "v1", Kind: "Pod", Namespace: "kube-system", Name: "escalator"}
+
+	t.Run("summary", func(t *testing.T) {
+		recorder := record.NewFakeRecorder(100)
+		c := &Controller{Opts: Opts{EventRecorder: recorder, EventObject: object}}
+		nodeGroup := &NodeGroupState{Opts: NodeGroupOptions{Name: "default"}}
+
+		nodes := buildNamedTestNodes(100)
+		c.recordAction(nodeGroup, ActionTainted, nodes[:60])
+		c.recordAction(nodeGroup, ActionTainted, nodes[60:])
+		c.recordAction(nodeGroup, ActionDeleted, nodes[:1])
+		assert.Empty(t, drainEvents(recorder))
+
+		c.flushActionEvents(nodeGroup)
+		assert.Equal(t, []string{
+			"Normal NodesTainted tainted 100 nodes in node group default: n0, n1, n2, n3, n4 and 95 more",
+			"Normal NodesDeleted deleted 1 nodes in node group default: n0",
+		}, drainEvents(recorder))
+
+		// the actions are only summarised once
+		c.flushActionEvents(nodeGroup)
+		assert.Empty(t, drainEvents(recorder))
+	})
+
+	t.Run("none", func(t *testing.T) {
+		recorder := record.NewFakeRecorder(100)
+		c := &Controller{Opts: Opts{EventRecorder: recorder, EventObject: object, EventVerbosity: EventVerbosityNone}}
+		nodeGroup := &NodeGroupState{Opts: NodeGroupOptions{Name: "default"}}
+
+		c.recordAction(nodeGroup, ActionTainted, buildNamedTestNodes(3))
+		c.flushActionEvents(nodeGroup)
+		assert.Empty(t, drainEvents(recorder))
+	})
+
+	t.Run("node", func(t *testing.T) {
+		recorder := record.NewFakeRecorder(100)
+		c := &Controller{Opts: Opts{
+			EventRecorder:       recorder,
+			EventObject:         object,
+			EventVerbosity:      EventVerbosityNode,
+			EventDedupWindow:    10 * time.Minute,
+			MaxNodeEventsPerRun: 3,
+		}}
+		nodeGroup := &NodeGroupState{Opts: NodeGroupOptions{Name: "default"}}
+		nodes := buildNamedTestNodes(5)
+
+		// node events are limited per run
+		c.resetNodeEvents(time.Now())
+		c.recordAction(nodeGroup, ActionTainted, nodes[:2])
+		c.recordAction(nodeGroup, ActionTainted, nodes[2:])
+		assert.Equal(t, []string{
+			"Normal TaintedForRemoval node tainted for removal by escalator in node group default",
+			"Normal TaintedForRemoval node tainted for removal by escalator in node group default",
+			"Normal TaintedForRemoval node tainted for removal by escalator in node group default",
+		}, drainEvents(recorder))
+		c.flushActionEvents(nodeGroup)
+		assert.Equal(t, []string{
+			"Normal NodesTainted tainted 5 nodes in node group default: n0, n1, n2, n3, n4",
+		}, drainEvents(recorder))
+
+		// the same reason isn't emitted again on a node in the dedup window
+		c.resetNodeEvents(time.Now())
+		c.recordAction(nodeGroup, ActionTainted, nodes[:1])
+		c.recordAction(nodeGroup, ActionUntainted, nodes[:1])
+		assert.Equal(t, []string{
+			"Normal UntaintedForScaleUp node untainted to scale up by escalator in node group default",
+		}, drainEvents(recorder))
+		assert.Len(t, c.nodeEventsEmitted, 4)
+
+		// events outside the dedup window are forgotten
+		c.resetNodeEvents(time.Now().Add(time.Hour))
+		assert.Empty(t, c.nodeEventsEmitted)
+	})
+}
@@ -0,0 +1,35 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/atlassian/escalator/pkg/k8s"
+	v1 "k8s.io/api/core/v1"
+)
+
+// evictionLimitsAllow returns whether the node, which still runs pods, can be deleted within the eviction_limits of
+// the node group, given the pods already evicted by this run. Alerts through the eviction_limits guardrail if not.
+// The first node of a run is always allowed by max_pods, so a node with more pods than the maximum is still deleted
+func (c *Controller) evictionLimitsAllow(nodeGroup *NodeGroupState, node *v1.Node, evicted int, now time.Time) bool {
+	limits := nodeGroup.Opts.EvictionLimits
+	pods, _ := k8s.NodePodsRemaining(node, nodeGroup.NodeInfoMap)
+	if limits.MaxPods > 0 && evicted > 0 && evicted+pods > limits.MaxPods {
+		c.recordGuardrail(nodeGroup, GuardrailEvictionLimits, "not deleting node %v yet, its %v pods would take the %v pods evicted this run past the maximum of %v", node.Name, pods, evicted, limits.MaxPods)
+		return false
+	}
+
+	interval := nodeGroup.Opts.EvictionNodeIntervalDuration()
+	if interval > 0 && !nodeGroup.lastEviction.IsZero() && now.Sub(nodeGroup.lastEviction) < interval {
+		c.recordGuardrail(nodeGroup, GuardrailEvictionLimits, "not deleting node %v yet, %v remaining of the node interval since the last node deleted with pods", node.Name, interval-now.Sub(nodeGroup.lastEviction))
+		return false
+	}
+	return true
+}
+
+// recordEviction starts the node interval of the node group from the deletion of the node, which still runs pods, and
+// returns the number of pods it evicts
+func recordEviction(nodeGroup *NodeGroupState, node *v1.Node, now time.Time) int {
+	nodeGroup.lastEviction = now
+	pods, _ := k8s.NodePodsRemaining(node, nodeGroup.NodeInfoMap)
+	return pods
+}
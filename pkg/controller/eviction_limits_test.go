@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/atlassian/escalator/pkg/k8s"
+	"github.com/atlassian/escalator/pkg/test"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestEvictionLimitsAllow(t *testing.T) {
+	node := test.BuildTestNode(test.NodeOpts{Name: "n1"})
+	pods := make([]*v1.Pod, 0, 3)
+	for _, name := range []string{"p1", "p2", "p3"} {
+		pods = append(pods, test.BuildTestPod(test.PodOpts{Name: name, NodeName: "n1"}))
+	}
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		limits       EvictionLimitsOptions
+		evicted      int
+		lastEviction time.Time
+		want         bool
+		wantEvent    string
+	}{
+		{"no limits", EvictionLimitsOptions{}, 100, now, true, ""},
+		{"within max pods", EvictionLimitsOptions{MaxPods: 5}, 2, time.Time{}, true, ""},
+		{
+			"past max pods",
+			EvictionLimitsOptions{MaxPods: 5},
+			3,
+			time.Time{},
+			false,
+			"Normal EvictionLimited node group default: not deleting node n1 yet, its 3 pods would take the 3 pods evicted this run past the maximum of 5",
+		},
+		{"first node of the run past max pods", EvictionLimitsOptions{MaxPods: 2}, 0, time.Time{}, true, ""},
+		{"no node deleted yet", EvictionLimitsOptions{NodeInterval: "10m"}, 0, time.Time{}, true, ""},
+		{"node interval passed", EvictionLimitsOptions{NodeInterval: "10m"}, 0, now.Add(-11 * time.Minute), true, ""},
+		{
+			"within node interval",
+			EvictionLimitsOptions{NodeInterval: "10m"},
+			0,
+			now.Add(-4 * time.Minute),
+			false,
+			"Normal EvictionLimited node group default: not deleting node n1 yet, 6m0s remaining of the node interval since the last node deleted with pods",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodeGroup := &NodeGroupState{
+				Opts:         NodeGroupOptions{Name: "default", EvictionLimits: tt.limits},
+				NodeInfoMap:  k8s.CreateNodeNameToInfoMap(pods, []*v1.Node{node}),
+				lastEviction: tt.lastEviction,
+			}
+			recorder := record.NewFakeRecorder(1)
+			c := &Controller{Opts: Opts{
+				EventRecorder: recorder,
+				EventObject:   &v1.ObjectReference{Kind: "Pod", Namespace: "kube-system", Name: "escalator"},
+			}}
+
+			assert.Equal(t, tt.want, c.evictionLimitsAllow(nodeGroup, node, tt.evicted, now))
+			events := drainEvents(recorder)
+			if len(tt.wantEvent) > 0 {
+				assert.Equal(t, []string{tt.wantEvent}, events)
+			} else {
+				assert.Empty(t, events)
+			}
+		})
+	}
+}
+
+func TestRecordEviction(t *testing.T) {
+	node := test.BuildTestNode(test.NodeOpts{Name: "n1"})
+	pods := []*v1.Pod{
+		test.BuildTestPod(test.PodOpts{Name: "p1", NodeName: "n1"}),
+		test.BuildTestPod(test.PodOpts{Name: "agent", NodeName: "n1", Owner: "DaemonSet"}),
+	}
+	nodeGroup := &NodeGroupState{NodeInfoMap: k8s.CreateNodeNameToInfoMap(pods, []*v1.Node{node})}
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// DaemonSet pods aren't evicted
+	assert.Equal(t, 1, recordEviction(nodeGroup, node, now))
+	assert.Equal(t, now, nodeGroup.lastEviction)
+}
@@ -0,0 +1,115 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/atlassian/escalator/pkg/metrics"
+	log "github.com/sirupsen/logrus"
+)
+
+// followUpPollInterval is how often the node groups waiting for a follow up run check whether the result of their last
+// scale action is observable
+const followUpPollInterval = 5 * time.Second
+
+// followUp is a follow up run of a node group, waiting for the result of the last scale action to be observable
+type followUp struct {
+	deadline time.Time
+	// scaleUp is whether the scale action was a scale up. The scale up lock is released once the new nodes are observed
+	scaleUp bool
+	// the result is observable once the node group has at least this many untainted or tainted nodes
+	untaintedNodes int
+	taintedNodes   int
+}
+
+// scheduleFollowUp schedules a follow up run of the node group once the change made by the scale action is observable.
+// nodesDelta is the number of nodes the scale action added, negative for nodes tainted by a scale down
+func (c *Controller) scheduleFollowUp(nodeGroup *NodeGroupState, untaintedNodes int, taintedNodes int, nodesDelta int) {
+	timeout := nodeGroup.Opts.FollowUpTimeoutDuration()
+	if timeout <= 0 || nodesDelta == 0 {
+		return
+	}
+
+	followUp := &followUp{deadline: time.Now().Add(timeout)}
+	if nodesDelta > 0 {
+		followUp.scaleUp = true
+		followUp.untaintedNodes = untaintedNodes + nodesDelta
+	} else {
+		followUp.taintedNodes = taintedNodes - nodesDelta
+	}
+	log.WithField("nodegroup", nodeGroup.Opts.Name).Debugf(
+		"Scheduling a follow up run once there are %v untainted and %v tainted nodes",
+		followUp.untaintedNodes,
+		followUp.taintedNodes,
+	)
+	nodeGroup.followUp = followUp
+}
+
+// followUpObserved returns whether the result of the last scale action of the node group is observable
+func (c *Controller) followUpObserved(nodeGroup *NodeGroupState) (bool, error) {
+	allNodes, err := nodeGroup.Nodes.List()
+	if err != nil {
+		return false, err
+	}
+	// new nodes that are still starting up are counted, they have registered even if they can't take pods yet
+	untaintedNodes, taintedNodes, _, _ := c.filterNodes(nodeGroup, allNodes)
+	return len(untaintedNodes) >= nodeGroup.followUp.untaintedNodes && len(taintedNodes) >= nodeGroup.followUp.taintedNodes, nil
+}
+
+// runFollowUps runs the node groups whose last scale action is now observable, so big changes in demand converge
+// without waiting for the next scan interval. Only errors that should stop escalator are returned
+func (c *Controller) runFollowUps(now time.Time) error {
+	var ready []NodeGroupOptions
+	for _, nodeGroupOpts := range c.Opts.NodeGroups {
+		state, ok := c.nodeGroups[nodeGroupOpts.Name]
+		if !ok || state.followUp == nil {
+			continue
+		}
+		logger := log.WithField("nodegroup", nodeGroupOpts.Name)
+
+		if now.After(state.followUp.deadline) {
+			logger.Info("Dropping the follow up run, the result of the last scale action wasn't observed in time")
+			state.followUp = nil
+			continue
+		}
+		observed, err := c.followUpObserved(state)
+		if err != nil {
+			logger.WithError(err).Warn("Failed to check whether the result of the last scale action is observable")
+			continue
+		}
+		if !observed {
+			continue
+		}
+
+		// the nodes the scale up lock was waiting for have arrived
+		if state.followUp.scaleUp {
+			state.scaleUpLock.unlock()
+		}
+		state.followUp = nil
+		ready = append(ready, nodeGroupOpts)
+	}
+	if len(ready) == 0 {
+		return nil
+	}
+
+	ctx, cancel := c.cycleContext()
+	defer cancel()
+	callCtx, cancelCalls := c.drainingContext(ctx)
+	defer cancelCalls()
+	ctx = withCallContext(ctx, callCtx)
+
+	if err := c.cloudProvider.Refresh(callCtx); err != nil {
+		log.WithError(err).Warn("Failed to refresh the cloud provider. Skipping follow up runs until the next scan interval")
+		return nil
+	}
+	c.resetPoolPlans()
+	c.resetNodeEvents(now)
+	c.quotaCoordinator().beginRun()
+	for _, nodeGroupOpts := range ready {
+		log.WithField("nodegroup", nodeGroupOpts.Name).Info("Running a follow up run of the node group")
+		metrics.NodeGroupFollowUpRuns.WithLabelValues(nodeGroupOpts.Name).Add(1.0)
+		if err := c.runNodeGroup(ctx, nodeGroupOpts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
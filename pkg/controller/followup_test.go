@@ -0,0 +1,70 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/atlassian/escalator/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestControllerScheduleFollowUp(t *testing.T) {
+	c := &Controller{}
+
+	disabled := &NodeGroupState{Opts: NodeGroupOptions{Name: "shared"}}
+	c.scheduleFollowUp(disabled, 2, 0, 3)
+	assert.Nil(t, disabled.followUp)
+
+	nodeGroup := &NodeGroupState{Opts: NodeGroupOptions{Name: "shared", FollowUpTimeout: "5m"}}
+	c.scheduleFollowUp(nodeGroup, 2, 1, 0)
+	assert.Nil(t, nodeGroup.followUp)
+
+	c.scheduleFollowUp(nodeGroup, 2, 1, 3)
+	require.NotNil(t, nodeGroup.followUp)
+	assert.True(t, nodeGroup.followUp.scaleUp)
+	assert.Equal(t, 5, nodeGroup.followUp.untaintedNodes)
+	assert.Equal(t, 0, nodeGroup.followUp.taintedNodes)
+	assert.WithinDuration(t, time.Now().Add(5*time.Minute), nodeGroup.followUp.deadline, time.Second)
+
+	c.scheduleFollowUp(nodeGroup, 2, 1, -2)
+	require.NotNil(t, nodeGroup.followUp)
+	assert.False(t, nodeGroup.followUp.scaleUp)
+	assert.Equal(t, 0, nodeGroup.followUp.untaintedNodes)
+	assert.Equal(t, 3, nodeGroup.followUp.taintedNodes)
+}
+
+func TestControllerRunFollowUps(t *testing.T) {
+	nodes := []*v1.Node{
+		test.BuildTestNode(test.NodeOpts{Name: "n1", LabelKey: "customer", LabelValue: "shared"}),
+		test.BuildTestNode(test.NodeOpts{Name: "n2", LabelKey: "customer", LabelValue: "shared", Tainted: true}),
+	}
+	nodeGroups := []NodeGroupOptions{{Name: "shared", LabelKey: "customer", LabelValue: "shared", FollowUpTimeout: "5m"}}
+	client, opts := buildTestClient(nodes, nil, nodeGroups, ListerOptions{})
+	state := &NodeGroupState{Opts: nodeGroups[0], NodeGroupLister: client.Listers["shared"]}
+	c := &Controller{
+		Client:     client,
+		Opts:       opts,
+		nodeGroups: map[string]*NodeGroupState{"shared": state},
+	}
+	now := time.Now()
+
+	// the new nodes haven't registered yet
+	state.followUp = &followUp{deadline: now.Add(time.Minute), scaleUp: true, untaintedNodes: 3}
+	observed, err := c.followUpObserved(state)
+	require.NoError(t, err)
+	assert.False(t, observed)
+	require.NoError(t, c.runFollowUps(now))
+	assert.NotNil(t, state.followUp)
+
+	// the follow up is dropped once it times out
+	require.NoError(t, c.runFollowUps(now.Add(2*time.Minute)))
+	assert.Nil(t, state.followUp)
+
+	// the taint of a scale down is observed
+	state.followUp = &followUp{deadline: now.Add(time.Minute), taintedNodes: 1}
+	observed, err = c.followUpObserved(state)
+	require.NoError(t, err)
+	assert.True(t, observed)
+}
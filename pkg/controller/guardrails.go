@@ -0,0 +1,68 @@
+package controller
+
+import (
+	"fmt"
+
+	"github.com/atlassian/escalator/pkg/metrics"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	// GuardrailTaintFailSafe is the taint budget of a run finding more nodes tainted than requested or recorded
+	GuardrailTaintFailSafe = "taint_failsafe"
+	// GuardrailMinNodes is a scale down clamped so the node group doesn't drop under its minimum
+	GuardrailMinNodes = "min_nodes"
+	// GuardrailMaxNodes is a scale up clamped so the cloud provider node group doesn't grow past its maximum
+	GuardrailMaxNodes = "max_nodes"
+	// GuardrailMaximumTaints is a scale down capped at the maximum number of nodes tainted in a single run
+	GuardrailMaximumTaints = "maximum_taints"
+	// GuardrailScaleUpCoolDown is a run blocked while waiting for the last scale up and its cool down period
+	GuardrailScaleUpCoolDown = "scale_up_cool_down"
+	// GuardrailProtectedPods is the deletion of a tainted node past its hard delete grace period blocked by its
+	// protected pods
+	GuardrailProtectedPods = "protected_pods"
+	// GuardrailStaticPods is the tainting, recycling or deletion of a node blocked by the static pods it is running
+	GuardrailStaticPods = "static_pods"
+	// GuardrailEvictionLimits is the deletion of a tainted node that still runs pods deferred by the eviction limits
+	GuardrailEvictionLimits = "eviction_limits"
+	// GuardrailVolumeDetach is the deletion of a volume heavy tainted node deferred by the volume_detach heavy node
+	// interval
+	GuardrailVolumeDetach = "volume_detach"
+	// GuardrailMaintenanceWindow is a scale down frozen by an open maintenance window
+	GuardrailMaintenanceWindow = "maintenance_window"
+	// GuardrailProviderWriteLimit is a resize or instance termination deferred to a later run by the provider write limits
+	GuardrailProviderWriteLimit = "provider_write_limit"
+)
+
+// guardrailEvents is the reason and type of the event emitted for each guardrail
+var guardrailEvents = map[string]struct {
+	reason    string
+	eventType string
+}{
+	GuardrailTaintFailSafe:      {"TaintFailSafe", v1.EventTypeWarning},
+	GuardrailMinNodes:           {"MinNodesClamped", v1.EventTypeNormal},
+	GuardrailMaxNodes:           {"MaxNodesClamped", v1.EventTypeNormal},
+	GuardrailMaximumTaints:      {"MaximumTaintsCapped", v1.EventTypeNormal},
+	GuardrailScaleUpCoolDown:    {"ScaleUpCoolDown", v1.EventTypeNormal},
+	GuardrailProtectedPods:      {"ProtectedPodsBlockDeletion", v1.EventTypeWarning},
+	GuardrailStaticPods:         {"StaticPodsBlockScaleDown", v1.EventTypeWarning},
+	GuardrailEvictionLimits:     {"EvictionLimited", v1.EventTypeNormal},
+	GuardrailVolumeDetach:       {"VolumeDetachSpaced", v1.EventTypeNormal},
+	GuardrailMaintenanceWindow:  {"MaintenanceWindowFreeze", v1.EventTypeNormal},
+	GuardrailProviderWriteLimit: {"ProviderWriteLimited", v1.EventTypeNormal},
+}
+
+// recordGuardrail counts an activation of the guardrail for the node group, and emits an event when the controller has
+// an event recorder
+func (c *Controller) recordGuardrail(nodeGroup *NodeGroupState, guardrail string, format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	log.WithField("nodegroup", nodeGroup.Opts.Name).WithField("guardrail", guardrail).Debug(message)
+	metrics.NodeGroupGuardrailActivations.WithLabelValues(nodeGroup.Opts.Name, guardrail).Add(1.0)
+
+	if c.Opts.EventRecorder == nil || c.Opts.EventObject == nil {
+		return
+	}
+	event := guardrailEvents[guardrail]
+	c.Opts.EventRecorder.Eventf(c.Opts.EventObject, event.eventType, event.reason, "node group %v: %v", nodeGroup.Opts.Name, message)
+}
@@ -0,0 +1,73 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestRecordGuardrail(t *testing.T) {
+	nodeGroup := &NodeGroupState{Opts: NodeGroupOptions{Name: "default"}}
+	object := &v1.ObjectReference{APIVersion: "v1", Kind: "Pod", Namespace: "kube-system", Name: "escalator"}
+
+	tests := []struct {
+		name      string
+		guardrail string
+		want      string
+	}{
+		{"taint fail safe", GuardrailTaintFailSafe, "Warning TaintFailSafe node group default: clamped 5 to 2"},
+		{"min nodes", GuardrailMinNodes, "Normal MinNodesClamped node group default: clamped 5 to 2"},
+		{"max nodes", GuardrailMaxNodes, "Normal MaxNodesClamped node group default: clamped 5 to 2"},
+		{"maximum taints", GuardrailMaximumTaints, "Normal MaximumTaintsCapped node group default: clamped 5 to 2"},
+		{"scale up cool down", GuardrailScaleUpCoolDown, "Normal ScaleUpCoolDown node group default: clamped 5 to 2"},
+		{"protected pods", GuardrailProtectedPods, "Warning ProtectedPodsBlockDeletion node group default: clamped 5 to 2"},
+		{"static pods", GuardrailStaticPods, "Warning StaticPodsBlockScaleDown node group default: clamped 5 to 2"},
+		{"eviction limits", GuardrailEvictionLimits, "Normal EvictionLimited node group default: clamped 5 to 2"},
+		{"provider write limit", GuardrailProviderWriteLimit, "Normal ProviderWriteLimited node group default: clamped 5 to 2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(1)
+			c := &Controller{Opts: Opts{EventRecorder: recorder, EventObject: object}}
+
+			c.recordGuardrail(nodeGroup, tt.guardrail, "clamped %v to %v", 5, 2)
+			assert.Equal(t, tt.want, <-recorder.Events)
+		})
+	}
+
+	// without an event recorder the activation is only counted
+	c := &Controller{}
+	c.recordGuardrail(nodeGroup, GuardrailMinNodes, "clamped %v to %v", 5, 2)
+}
+
+func TestScaleDownTaint_MinNodesGuardrail(t *testing.T) {
+	nodeGroups := []NodeGroupOptions{{
+		Name:                   "default",
+		CloudProviderGroupName: "default",
+		MinNodes:               3,
+		MaxNodes:               10,
+		DryMode:                true,
+	}}
+	nodes := buildTestNodes(4, 2000, 8000)
+	client, opts := buildTestClient(nodes, nil, nodeGroups, ListerOptions{})
+	nodeGroupsState := BuildNodeGroupsState(nodeGroupsStateOpts{nodeGroups: nodeGroups, client: *client})
+
+	recorder := record.NewFakeRecorder(10)
+	opts.EventRecorder = recorder
+	opts.EventObject = &v1.ObjectReference{APIVersion: "v1", Kind: "Pod", Namespace: "kube-system", Name: "escalator"}
+	c := &Controller{Client: client, Opts: opts, nodeGroups: nodeGroupsState}
+
+	tainted, err := c.scaleDownTaint(scaleOpts{
+		nodes:          nodes,
+		untaintedNodes: nodes,
+		nodeGroup:      nodeGroupsState["default"],
+		nodesDelta:     3,
+		ctx:            context.Background(),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, tainted)
+	assert.Equal(t, "Normal MinNodesClamped node group default: scale down of 3 nodes clamped to 1 by the minimum of 3 nodes", <-recorder.Events)
+}
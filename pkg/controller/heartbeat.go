@@ -0,0 +1,51 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/atlassian/escalator/pkg/k8s"
+	"github.com/atlassian/escalator/pkg/metrics"
+	log "github.com/sirupsen/logrus"
+)
+
+// staleRunAge is how long after the last successful run the controller is considered wedged
+func (c *Controller) staleRunAge() time.Duration {
+	// the scan interval can be tuned up to the maximum
+	return 3*maxDuration(c.Opts.ScanInterval, c.Opts.MaxScanInterval) + c.Opts.ScanJitter
+}
+
+// heartbeat records the successful run in the report and renews the heartbeat lease if it's enabled
+func (c *Controller) heartbeat(now time.Time) {
+	c.reportLock.Lock()
+	c.report.LastSuccessfulRun = now
+	c.reportLock.Unlock()
+
+	if len(c.Opts.HeartbeatLeaseName) == 0 {
+		return
+	}
+	err := k8s.UpdateHeartbeatLease(c.Client, k8s.HeartbeatConfig{
+		Namespace: c.Opts.HeartbeatLeaseNamespace,
+		Name:      c.Opts.HeartbeatLeaseName,
+		Identity:  c.Opts.HeartbeatIdentity,
+		Duration:  c.staleRunAge(),
+	}, now)
+	if err != nil {
+		log.WithError(err).Warn("Failed to renew heartbeat lease")
+		metrics.HeartbeatFailures.Add(1)
+	}
+}
+
+// HealthzHandler responds with 200 while the last successful run is recent, and 503 when the controller hasn't
+// finished a run for longer than three scan intervals, e.g. when it's wedged mid run
+func (c *Controller) HealthzHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lastSuccessfulRun := c.Report().LastSuccessfulRun
+		if age := time.Since(lastSuccessfulRun); lastSuccessfulRun.IsZero() || age > c.staleRunAge() {
+			http.Error(w, fmt.Sprintf("last successful run was at %v", lastSuccessfulRun), http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintf(w, "ok")
+	})
+}
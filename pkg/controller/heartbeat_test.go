@@ -0,0 +1,28 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestControllerHealthzHandler(t *testing.T) {
+	c := &Controller{Opts: Opts{ScanInterval: time.Minute}}
+	healthz := func() int {
+		recorder := httptest.NewRecorder()
+		c.HealthzHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		return recorder.Code
+	}
+
+	assert.Equal(t, http.StatusServiceUnavailable, healthz())
+
+	c.heartbeat(time.Now().Add(-time.Minute))
+	assert.Equal(t, http.StatusOK, healthz())
+	assert.False(t, c.Report().LastSuccessfulRun.IsZero())
+
+	c.heartbeat(time.Now().Add(-4 * time.Minute))
+	assert.Equal(t, http.StatusServiceUnavailable, healthz())
+}
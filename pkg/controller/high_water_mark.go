@@ -0,0 +1,58 @@
+package controller
+
+import (
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// highWaterMark is the peak of untainted nodes reached by the scale ups of a node group with high_water_mark_hold
+type highWaterMark struct {
+	nodes int
+	// time of the last scale up past the threshold, the hold lasts for the duration from then
+	time time.Time
+}
+
+// recordHighWaterMark records the untainted nodes reached by a scale up. A scale up past the threshold restarts the
+// hold, and raises the peak while the hold is still active
+func recordHighWaterMark(nodeGroup *NodeGroupState, untaintedNodes int, now time.Time) {
+	hold := nodeGroup.Opts.HighWaterMarkHold
+	if hold.Percent <= 0 || untaintedNodes < hold.ThresholdNodes {
+		return
+	}
+
+	peak := untaintedNodes
+	if heldMinNodes(nodeGroup, now) > 0 && nodeGroup.highWaterMark.nodes > peak {
+		peak = nodeGroup.highWaterMark.nodes
+	}
+	nodeGroup.highWaterMark = highWaterMark{nodes: peak, time: now}
+	log.WithField("nodegroup", nodeGroup.Opts.Name).Infof("High water mark of %v nodes, holding the minimum at %v nodes for %v",
+		peak, heldMinNodes(nodeGroup, now), nodeGroup.Opts.HighWaterMarkHoldDuration())
+}
+
+// heldMinNodes returns the percentage of the high water mark that the minimum of the node group is held at, or 0 when
+// there isn't a hold or it has expired
+func heldMinNodes(nodeGroup *NodeGroupState, now time.Time) int {
+	mark := nodeGroup.highWaterMark
+	if mark.nodes == 0 || now.Sub(mark.time) >= nodeGroup.Opts.HighWaterMarkHoldDuration() {
+		return 0
+	}
+	// round up, so the hold never drops a node that the percentage would partly keep
+	return (mark.nodes*nodeGroup.Opts.HighWaterMarkHold.Percent + 99) / 100
+}
+
+// minNodes returns the minimum the node group can scale down to, which is min_nodes or the held minimum, raised by the
+// nodes of the active reservations up to max_nodes
+func minNodes(nodeGroup *NodeGroupState, now time.Time) int {
+	minimum := nodeGroup.Opts.MinNodes
+	if held := heldMinNodes(nodeGroup, now); held > minimum {
+		minimum = held
+	}
+	if reserved := reservedNodes(nodeGroup, now); reserved > 0 {
+		minimum += reserved
+		if nodeGroup.Opts.MaxNodes > 0 && minimum > nodeGroup.Opts.MaxNodes {
+			minimum = nodeGroup.Opts.MaxNodes
+		}
+	}
+	return minimum
+}
@@ -0,0 +1,56 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHighWaterMarkHold(t *testing.T) {
+	now := time.Now()
+	hold := HighWaterMarkHoldOptions{ThresholdNodes: 10, Percent: 50, Duration: "1h"}
+
+	tests := []struct {
+		name         string
+		hold         HighWaterMarkHoldOptions
+		scaleUps     []int
+		sinceLast    time.Duration
+		wantHeld     int
+		wantMinNodes int
+	}{
+		{"disabled", HighWaterMarkHoldOptions{}, []int{40}, 0, 0, 2},
+		{"below threshold", hold, []int{9}, 0, 0, 2},
+		{"at threshold", hold, []int{10}, 0, 5, 5},
+		{"rounds up", hold, []int{11}, 0, 6, 6},
+		{"keeps the peak", hold, []int{40, 20}, 0, 20, 20},
+		{"raises the peak", hold, []int{20, 40}, 0, 20, 20},
+		{"within duration", hold, []int{40}, 59 * time.Minute, 20, 20},
+		{"expired", hold, []int{40}, time.Hour, 0, 2},
+		{"held under min nodes", HighWaterMarkHoldOptions{ThresholdNodes: 2, Percent: 10, Duration: "1h"}, []int{10}, 0, 1, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodeGroup := &NodeGroupState{Opts: NodeGroupOptions{Name: "default", MinNodes: 2, HighWaterMarkHold: tt.hold}}
+			for _, untaintedNodes := range tt.scaleUps {
+				recordHighWaterMark(nodeGroup, untaintedNodes, now)
+			}
+
+			assert.Equal(t, tt.wantHeld, heldMinNodes(nodeGroup, now.Add(tt.sinceLast)))
+			assert.Equal(t, tt.wantMinNodes, minNodes(nodeGroup, now.Add(tt.sinceLast)))
+		})
+	}
+}
+
+func TestHighWaterMarkHold_ExpiredPeak(t *testing.T) {
+	now := time.Now()
+	nodeGroup := &NodeGroupState{Opts: NodeGroupOptions{
+		Name:              "default",
+		HighWaterMarkHold: HighWaterMarkHoldOptions{ThresholdNodes: 10, Percent: 50, Duration: "1h"},
+	}}
+
+	// a scale up after the hold expired starts a new hold from its own peak
+	recordHighWaterMark(nodeGroup, 40, now)
+	recordHighWaterMark(nodeGroup, 20, now.Add(2*time.Hour))
+	assert.Equal(t, 10, heldMinNodes(nodeGroup, now.Add(2*time.Hour)))
+}
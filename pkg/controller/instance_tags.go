@@ -0,0 +1,93 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/atlassian/escalator/pkg/cloudprovider"
+	"github.com/atlassian/escalator/pkg/k8s"
+	"github.com/atlassian/escalator/pkg/metrics"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	// InstanceTagActionTag is an instance tagged when its node was tainted
+	InstanceTagActionTag = "tag"
+	// InstanceTagActionUntag is an instance untagged when its node was untainted
+	InstanceTagActionUntag = "untag"
+)
+
+// instanceTagger returns the cloud provider node group of the node group if it can tag instances and the node group
+// has tag_tainted_instances
+func (c *Controller) instanceTagger(nodeGroup *NodeGroupState) (cloudprovider.InstanceTagger, bool) {
+	if !nodeGroup.Opts.TagTaintedInstances {
+		return nil, false
+	}
+	cloudProviderNodeGroup, ok := getCloudProviderNodeGroup(c.cloudProvider, nodeGroup.Opts)
+	if !ok {
+		return nil, false
+	}
+	tagger, ok := cloudProviderNodeGroup.(cloudprovider.InstanceTagger)
+	if !ok {
+		log.WithField("nodegroup", nodeGroup.Opts.Name).Debugf("cloud provider %v can't tag instances", c.cloudProvider.Name())
+	}
+	return tagger, ok
+}
+
+// tagTaintedInstances tags the instances of the nodes tainted for removal with the time they were tainted. Tagging is
+// best effort: a failure is logged and counted, and doesn't affect the taint
+func (c *Controller) tagTaintedInstances(ctx context.Context, nodeGroup *NodeGroupState, nodes []*v1.Node, now time.Time) {
+	if len(nodes) == 0 {
+		return
+	}
+	tagger, ok := c.instanceTagger(nodeGroup)
+	if !ok {
+		return
+	}
+
+	// nodes tainted in the same run usually share the taint time, so they are tagged together
+	byValue := make(map[string][]*v1.Node)
+	var values []string
+	for _, node := range nodes {
+		taintedTime := now
+		if t, err := k8s.GetToBeRemovedTime(node); err == nil && t != nil {
+			taintedTime = *t
+		}
+		value := taintedTime.UTC().Format(time.RFC3339)
+		if _, ok := byValue[value]; !ok {
+			values = append(values, value)
+		}
+		byValue[value] = append(byValue[value], node)
+	}
+
+	for _, value := range values {
+		err := tagger.TagInstances(ctx, map[string]string{cloudprovider.ToBeRemovedTagKey: value}, byValue[value]...)
+		c.recordInstanceTagResult(nodeGroup, InstanceTagActionTag, byValue[value], err)
+	}
+}
+
+// untagUntaintedInstances removes the tag from the instances of the nodes untainted, so they aren't reported as going
+// to be removed any more
+func (c *Controller) untagUntaintedInstances(ctx context.Context, nodeGroup *NodeGroupState, nodes []*v1.Node) {
+	if len(nodes) == 0 {
+		return
+	}
+	tagger, ok := c.instanceTagger(nodeGroup)
+	if !ok {
+		return
+	}
+	err := tagger.UntagInstances(ctx, []string{cloudprovider.ToBeRemovedTagKey}, nodes...)
+	c.recordInstanceTagResult(nodeGroup, InstanceTagActionUntag, nodes, err)
+}
+
+// recordInstanceTagResult logs and counts a failure to tag or untag the instances of the nodes
+func (c *Controller) recordInstanceTagResult(nodeGroup *NodeGroupState, action string, nodes []*v1.Node, err error) {
+	logger := log.WithField("nodegroup", nodeGroup.Opts.Name)
+	if err != nil {
+		logger.WithError(err).Warnf("Failed to %v the instances of %v nodes with %v", action, len(nodes), cloudprovider.ToBeRemovedTagKey)
+		metrics.NodeGroupInstanceTagFailures.WithLabelValues(nodeGroup.Opts.Name, action).Add(float64(len(nodes)))
+		return
+	}
+	logger.Debugf("Finished the %v of the instances of %v nodes with %v", action, len(nodes), cloudprovider.ToBeRemovedTagKey)
+}
@@ -0,0 +1,99 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/atlassian/escalator/pkg/cloudprovider"
+	"github.com/atlassian/escalator/pkg/test"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+)
+
+// taggingNodeGroup is a cloud provider node group that records the tags set on the instances of nodes
+type taggingNodeGroup struct {
+	*test.NodeGroup
+	tags map[string]map[string]string
+	err  error
+}
+
+func (n *taggingNodeGroup) TagInstances(ctx context.Context, tags map[string]string, nodes ...*v1.Node) error {
+	if n.err != nil {
+		return n.err
+	}
+	for _, node := range nodes {
+		if n.tags[node.Name] == nil {
+			n.tags[node.Name] = make(map[string]string)
+		}
+		for key, value := range tags {
+			n.tags[node.Name][key] = value
+		}
+	}
+	return nil
+}
+
+func (n *taggingNodeGroup) UntagInstances(ctx context.Context, keys []string, nodes ...*v1.Node) error {
+	if n.err != nil {
+		return n.err
+	}
+	for _, node := range nodes {
+		for _, key := range keys {
+			delete(n.tags[node.Name], key)
+		}
+	}
+	return nil
+}
+
+func TestTagTaintedInstances(t *testing.T) {
+	now := time.Date(2019, 3, 1, 3, 12, 0, 0, time.UTC)
+	taintedAt := now.Add(-time.Hour)
+
+	tainted := test.BuildTestNode(test.NodeOpts{Name: "tainted", Tainted: true})
+	tainted.Spec.Taints[0].Value = fmt.Sprint(taintedAt.Unix())
+	// a node whose taint time can't be read is tagged with the time of the run
+	unmarked := test.BuildTestNode(test.NodeOpts{Name: "unmarked"})
+	nodes := []*v1.Node{tainted, unmarked}
+
+	setup := func(enabled bool) (*Controller, *NodeGroupState, *taggingNodeGroup) {
+		cloudProviderNodeGroup := &taggingNodeGroup{NodeGroup: test.NewNodeGroup("asg", 0, 10, 2), tags: make(map[string]map[string]string)}
+		c := &Controller{cloudProvider: &singleNodeGroupCloudProvider{test.NewCloudProvider(1), cloudProviderNodeGroup}}
+		nodeGroup := &NodeGroupState{Opts: NodeGroupOptions{Name: "default", CloudProviderGroupName: "asg", TagTaintedInstances: enabled}}
+		return c, nodeGroup, cloudProviderNodeGroup
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		c, nodeGroup, cloudProviderNodeGroup := setup(false)
+		c.tagTaintedInstances(context.Background(), nodeGroup, nodes, now)
+		assert.Empty(t, cloudProviderNodeGroup.tags)
+	})
+
+	t.Run("tag and untag", func(t *testing.T) {
+		c, nodeGroup, cloudProviderNodeGroup := setup(true)
+		c.tagTaintedInstances(context.Background(), nodeGroup, nodes, now)
+		assert.Equal(t, map[string]map[string]string{
+			"tainted":  {cloudprovider.ToBeRemovedTagKey: "2019-03-01T02:12:00Z"},
+			"unmarked": {cloudprovider.ToBeRemovedTagKey: "2019-03-01T03:12:00Z"},
+		}, cloudProviderNodeGroup.tags)
+
+		c.untagUntaintedInstances(context.Background(), nodeGroup, nodes[:1])
+		assert.Empty(t, cloudProviderNodeGroup.tags["tainted"])
+		assert.NotEmpty(t, cloudProviderNodeGroup.tags["unmarked"])
+	})
+
+	t.Run("failures don't stop the taint", func(t *testing.T) {
+		c, nodeGroup, cloudProviderNodeGroup := setup(true)
+		cloudProviderNodeGroup.err = errors.New("UnauthorizedOperation")
+		c.tagTaintedInstances(context.Background(), nodeGroup, nodes, now)
+		c.untagUntaintedInstances(context.Background(), nodeGroup, nodes)
+		assert.Empty(t, cloudProviderNodeGroup.tags)
+	})
+
+	t.Run("unsupported cloud provider", func(t *testing.T) {
+		c := &Controller{cloudProvider: test.NewCloudProvider(1)}
+		nodeGroup := &NodeGroupState{Opts: NodeGroupOptions{Name: "default", CloudProviderGroupName: "asg", TagTaintedInstances: true}}
+		c.tagTaintedInstances(context.Background(), nodeGroup, nodes, now)
+	})
+}
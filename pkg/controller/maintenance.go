@@ -0,0 +1,145 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/atlassian/escalator/pkg/cloudprovider"
+	"github.com/atlassian/escalator/pkg/k8s"
+	"github.com/atlassian/escalator/pkg/metrics"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+)
+
+// maintenancePollInterval is how often the cloud provider is asked for the maintenance scheduled for the nodes of a
+// node group. Maintenance is scheduled days ahead, so it doesn't need to be polled every run
+const maintenancePollInterval = 5 * time.Minute
+
+// ScaleUpReasonMaintenance is the scale up reason recorded on nodes brought up to replace a node with scheduled
+// maintenance
+const ScaleUpReasonMaintenance = "maintenance"
+
+// maintenanceEvents tracks the maintenance the cloud provider scheduled for the nodes of a node group
+type maintenanceEvents struct {
+	// the time the cloud provider was last asked
+	polled time.Time
+	// the scheduled maintenance by node name, from the last poll
+	events map[string]cloudprovider.MaintenanceEvent
+}
+
+// scheduled returns whether the cloud provider scheduled maintenance for the node
+func (m *maintenanceEvents) scheduled(node *v1.Node) bool {
+	_, ok := m.events[node.Name]
+	return ok
+}
+
+// pollMaintenanceEvents asks the cloud provider for the maintenance scheduled for the untainted nodes of the node group
+// with replace_on_maintenance, at most every maintenancePollInterval. The events of the last poll are kept when the
+// cloud provider fails to answer. Cloud providers that can't list maintenance are left alone
+func (c *Controller) pollMaintenanceEvents(ctx context.Context, nodeGroup *NodeGroupState, nodes []*v1.Node, now time.Time) {
+	if !nodeGroup.Opts.ReplaceOnMaintenance || now.Sub(nodeGroup.maintenance.polled) < maintenancePollInterval {
+		return
+	}
+	cloudProviderNodeGroup, ok := getCloudProviderNodeGroup(c.cloudProvider, nodeGroup.Opts)
+	if !ok {
+		return
+	}
+	reader, ok := cloudProviderNodeGroup.(cloudprovider.MaintenanceEventReader)
+	if !ok {
+		log.WithField("nodegroup", nodeGroup.Opts.Name).Debugf("cloud provider %v can't list scheduled maintenance", c.cloudProvider.Name())
+		return
+	}
+
+	logger := log.WithField("nodegroup", nodeGroup.Opts.Name)
+	scheduled, err := reader.MaintenanceEvents(ctx, nodes...)
+	if err != nil {
+		logger.WithError(err).Warn("failed to list the maintenance scheduled for the nodes")
+		return
+	}
+	nodeGroup.maintenance.polled = now
+
+	events := make(map[string]cloudprovider.MaintenanceEvent, len(scheduled))
+	for _, event := range scheduled {
+		if _, seen := nodeGroup.maintenance.events[event.NodeName]; !seen {
+			logger.Infof("%v is scheduled for node %v not before %v: %v", event.Code, event.NodeName, event.NotBefore, event.Description)
+		}
+		// a node with more than one event is replaced ahead of the earliest
+		if earliest, ok := events[event.NodeName]; ok && !event.NotBefore.Before(earliest.NotBefore) {
+			continue
+		}
+		events[event.NodeName] = event
+	}
+	nodeGroup.maintenance.events = events
+	metrics.NodeGroupNodesScheduledMaintenance.WithLabelValues(nodeGroup.Opts.Name).Set(float64(len(events)))
+}
+
+// prioritisedForTainting returns whether the node is tainted before the other nodes of the node group, as it has an
+// unhealthy condition, scheduled maintenance, a third party taint whose removal is prioritised, an outdated version or
+// is older than max_node_age. Recycling relies on it to taint an expired node whatever the taint_selection_policy
+func (nodeGroup *NodeGroupState) prioritisedForTainting(node *v1.Node) bool {
+	return k8s.NodeHasAnyCondition(node, nodeGroup.Opts.UnhealthyNodeConditions) || nodeGroup.maintenance.scheduled(node) ||
+		nodeGroup.thirdPartyTaintPrioritised(node) || nodeGroup.outdatedNodes[node.Name] || nodeGroup.expired(node, time.Now())
+}
+
+// removalReason returns the reason the node is tainted for on scale down, the reason it was prioritised for tainting
+// or max_node_age when it is being recycled, and scale_down otherwise
+func (nodeGroup *NodeGroupState) removalReason(node *v1.Node, now time.Time) string {
+	switch {
+	case k8s.NodeHasAnyCondition(node, nodeGroup.Opts.UnhealthyNodeConditions):
+		return k8s.RemovalReasonUnhealthy
+	case nodeGroup.maintenance.scheduled(node):
+		return k8s.RemovalReasonMaintenance
+	case nodeGroup.thirdPartyTaintPrioritised(node):
+		return k8s.RemovalReasonThirdPartyTaint
+	case nodeGroup.outdatedNodes[node.Name]:
+		return k8s.RemovalReasonVersionSkew
+	case nodeGroup.expired(node, now):
+		return k8s.RemovalReasonMaxNodeAge
+	}
+	return k8s.RemovalReasonScaleDown
+}
+
+// removalIntent returns the removal intent recorded on the node when it is tainted for the reason at now, which expires
+// at the end of its hard delete grace period
+func (nodeGroup *NodeGroupState) removalIntent(node *v1.Node, reason string, now time.Time) k8s.RemovalIntent {
+	return k8s.RemovalIntent{Reason: reason, Expires: now.Add(hardDeleteGracePeriod(nodeGroup, node))}
+}
+
+// maintenanceNodes returns the nodes with scheduled maintenance, except for those running static pods when the node
+// group protects them
+func maintenanceNodes(nodeGroup *NodeGroupState, nodes []*v1.Node) []*v1.Node {
+	var scheduled []*v1.Node
+	for _, node := range withoutStaticPodNodes(nodeGroup, nodes) {
+		if nodeGroup.maintenance.scheduled(node) {
+			scheduled = append(scheduled, node)
+		}
+	}
+	return scheduled
+}
+
+// replaceMaintenanceNodes replaces the untainted nodes with scheduled maintenance one at a time, the same way as
+// recycling replaces nodes older than max_node_age, so they are drained before the cloud provider disrupts them.
+// It is only run when the node group doesn't otherwise need to scale
+func (c *Controller) replaceMaintenanceNodes(opts scaleOpts) (int, error) {
+	nodeGroup := opts.nodeGroup
+	scheduled := maintenanceNodes(nodeGroup, opts.untaintedNodes)
+	log.WithField("nodegroup", nodeGroup.Opts.Name).Infof("%v nodes have scheduled maintenance", len(scheduled))
+
+	if nodeGroup.Opts.RecycleMode == RecycleModeProvisionThenTaint && len(opts.nodes) < nodeGroup.Opts.MaxNodes {
+		pending := nodeGroup.replacementPending
+		result, err := c.replaceThenTaint(opts, ScaleUpReasonMaintenance)
+		// the first call brings up the replacement, the node is only tainted once the replacement is ready
+		if err == nil && pending && !nodeGroup.replacementPending {
+			metrics.NodeGroupMaintenanceReplacements.WithLabelValues(nodeGroup.Opts.Name).Add(float64(result))
+		}
+		return result, err
+	}
+
+	// nodes with scheduled maintenance are tainted first, the normal scale up will replace the capacity if needed
+	opts.nodesDelta = 1
+	tainted, err := c.scaleDownTaint(opts)
+	if err == nil {
+		metrics.NodeGroupMaintenanceReplacements.WithLabelValues(nodeGroup.Opts.Name).Add(float64(tainted))
+	}
+	return tainted, err
+}
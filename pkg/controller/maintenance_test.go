@@ -0,0 +1,160 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/atlassian/escalator/pkg/cloudprovider"
+	"github.com/atlassian/escalator/pkg/k8s"
+	"github.com/atlassian/escalator/pkg/test"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+)
+
+// maintenanceNodeGroup is a cloud provider node group with the given scheduled maintenance
+type maintenanceNodeGroup struct {
+	*test.NodeGroup
+	events []cloudprovider.MaintenanceEvent
+	err    error
+	polls  int
+}
+
+func (n *maintenanceNodeGroup) MaintenanceEvents(ctx context.Context, nodes ...*v1.Node) ([]cloudprovider.MaintenanceEvent, error) {
+	n.polls++
+	return n.events, n.err
+}
+
+func TestPollMaintenanceEvents(t *testing.T) {
+	now := time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC)
+	retirement := cloudprovider.MaintenanceEvent{NodeName: "n1", Code: "instance-retirement", NotBefore: now.Add(48 * time.Hour)}
+	reboot := cloudprovider.MaintenanceEvent{NodeName: "n1", Code: "system-reboot", NotBefore: now.Add(24 * time.Hour)}
+	cloudProviderNodeGroup := &maintenanceNodeGroup{
+		NodeGroup: test.NewNodeGroup("asg", 0, 10, 2),
+		events:    []cloudprovider.MaintenanceEvent{retirement, reboot},
+	}
+	c := &Controller{cloudProvider: &singleNodeGroupCloudProvider{test.NewCloudProvider(1), cloudProviderNodeGroup}}
+	nodes := []*v1.Node{
+		test.BuildTestNode(test.NodeOpts{Name: "n1"}),
+		test.BuildTestNode(test.NodeOpts{Name: "n2"}),
+	}
+
+	// nothing is polled without replace_on_maintenance
+	nodeGroup := &NodeGroupState{Opts: NodeGroupOptions{Name: "default", CloudProviderGroupName: "asg"}}
+	c.pollMaintenanceEvents(context.Background(), nodeGroup, nodes, now)
+	assert.Equal(t, 0, cloudProviderNodeGroup.polls)
+
+	// the node is replaced ahead of its earliest maintenance
+	nodeGroup.Opts.ReplaceOnMaintenance = true
+	c.pollMaintenanceEvents(context.Background(), nodeGroup, nodes, now)
+	assert.Equal(t, 1, cloudProviderNodeGroup.polls)
+	assert.Equal(t, map[string]cloudprovider.MaintenanceEvent{"n1": reboot}, nodeGroup.maintenance.events)
+	assert.True(t, nodeGroup.maintenance.scheduled(nodes[0]))
+	assert.False(t, nodeGroup.maintenance.scheduled(nodes[1]))
+	assert.Equal(t, []*v1.Node{nodes[0]}, maintenanceNodes(nodeGroup, nodes))
+
+	// the cloud provider isn't asked again until the poll interval passed
+	c.pollMaintenanceEvents(context.Background(), nodeGroup, nodes, now.Add(time.Minute))
+	assert.Equal(t, 1, cloudProviderNodeGroup.polls)
+
+	// the events of the last poll are kept when the cloud provider fails
+	cloudProviderNodeGroup.err = errors.New("RequestLimitExceeded")
+	c.pollMaintenanceEvents(context.Background(), nodeGroup, nodes, now.Add(maintenancePollInterval))
+	assert.Equal(t, 2, cloudProviderNodeGroup.polls)
+	assert.Len(t, nodeGroup.maintenance.events, 1)
+
+	// and dropped once the maintenance is cancelled
+	cloudProviderNodeGroup.err = nil
+	cloudProviderNodeGroup.events = nil
+	c.pollMaintenanceEvents(context.Background(), nodeGroup, nodes, now.Add(maintenancePollInterval+time.Minute))
+	assert.Empty(t, nodeGroup.maintenance.events)
+	assert.Empty(t, maintenanceNodes(nodeGroup, nodes))
+}
+
+func TestControllerReplaceMaintenanceNodes(t *testing.T) {
+	now := time.Now()
+	nodes := []*v1.Node{
+		test.BuildTestNode(test.NodeOpts{Name: "n1", Creation: now.Add(-3 * time.Hour)}),
+		test.BuildTestNode(test.NodeOpts{Name: "n2", Creation: now.Add(-2 * time.Hour)}),
+		test.BuildTestNode(test.NodeOpts{Name: "n3", Creation: now.Add(-30 * time.Minute)}),
+	}
+	scheduled := maintenanceEvents{events: map[string]cloudprovider.MaintenanceEvent{
+		"n3": {NodeName: "n3", Code: "instance-retirement", NotBefore: now.Add(24 * time.Hour)},
+	}}
+
+	t.Run("taint the node with scheduled maintenance before older nodes", func(t *testing.T) {
+		nodeGroup := &NodeGroupState{
+			Opts: NodeGroupOptions{
+				Name:                 "buildeng",
+				MinNodes:             1,
+				MaxNodes:             3,
+				ReplaceOnMaintenance: true,
+			},
+			maintenance: scheduled,
+		}
+		c := &Controller{Opts: Opts{DryMode: true}}
+
+		tainted, err := c.replaceMaintenanceNodes(scaleOpts{nodes: nodes, untaintedNodes: nodes, nodeGroup: nodeGroup, ctx: context.Background()})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, tainted)
+		assert.Equal(t, []string{"n3"}, nodeGroup.dryTaintedNodes())
+	})
+
+	t.Run("wait for the replacement to be ready", func(t *testing.T) {
+		nodeGroup := &NodeGroupState{
+			Opts: NodeGroupOptions{
+				Name:                 "buildeng",
+				MinNodes:             1,
+				MaxNodes:             5,
+				ReplaceOnMaintenance: true,
+				RecycleMode:          RecycleModeProvisionThenTaint,
+			},
+			maintenance:           scheduled,
+			replacementPending:    true,
+			replacementReadyNodes: 4,
+		}
+		c := &Controller{Opts: Opts{DryMode: true}}
+
+		tainted, err := c.replaceMaintenanceNodes(scaleOpts{nodes: nodes, untaintedNodes: nodes, nodeGroup: nodeGroup, ctx: context.Background()})
+		assert.NoError(t, err)
+		assert.Equal(t, 0, tainted)
+		assert.Empty(t, nodeGroup.taintTracker)
+		assert.True(t, nodeGroup.replacementPending)
+	})
+}
+
+func TestRemovalReason(t *testing.T) {
+	now := time.Now()
+	unhealthy := test.BuildTestNode(test.NodeOpts{Name: "unhealthy", Creation: now})
+	unhealthy.Status.Conditions = []v1.NodeCondition{{Type: "KernelDeadlock", Status: v1.ConditionTrue}}
+	nodeGroup := &NodeGroupState{
+		Opts: NodeGroupOptions{
+			Name:                    "buildeng",
+			UnhealthyNodeConditions: []string{"KernelDeadlock"},
+			MaxNodeAge:              "24h",
+			HardDeleteGracePeriod:   "1h",
+		},
+		maintenance:   maintenanceEvents{events: map[string]cloudprovider.MaintenanceEvent{"maintenance": {NodeName: "maintenance"}}},
+		outdatedNodes: map[string]bool{"outdated": true},
+	}
+
+	tests := []struct {
+		node *v1.Node
+		want string
+	}{
+		{unhealthy, k8s.RemovalReasonUnhealthy},
+		{test.BuildTestNode(test.NodeOpts{Name: "maintenance", Creation: now}), k8s.RemovalReasonMaintenance},
+		{test.BuildTestNode(test.NodeOpts{Name: "outdated", Creation: now}), k8s.RemovalReasonVersionSkew},
+		{test.BuildTestNode(test.NodeOpts{Name: "expired", Creation: now.Add(-25 * time.Hour)}), k8s.RemovalReasonMaxNodeAge},
+		{test.BuildTestNode(test.NodeOpts{Name: "young", Creation: now.Add(-time.Hour)}), k8s.RemovalReasonScaleDown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.node.Name, func(t *testing.T) {
+			assert.Equal(t, tt.want, nodeGroup.removalReason(tt.node, now))
+		})
+	}
+
+	intent := nodeGroup.removalIntent(tests[0].node, k8s.RemovalReasonUnhealthy, now)
+	assert.Equal(t, k8s.RemovalIntent{Reason: k8s.RemovalReasonUnhealthy, Expires: now.Add(time.Hour)}, intent)
+}
@@ -0,0 +1,291 @@
+package controller
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/atlassian/escalator/pkg/errorkind"
+	"github.com/atlassian/escalator/pkg/metrics"
+	log "github.com/sirupsen/logrus"
+)
+
+// maxMaintenanceWindowDuration is the longest a maintenance window can freeze scale down for
+const maxMaintenanceWindowDuration = 7 * 24 * time.Hour
+
+// MaintenanceWindow is a recurring period during which scale down of the node groups is frozen, e.g. while the
+// cluster is upgraded, after which the node groups go back to normal by themselves
+type MaintenanceWindow struct {
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+	// Start is when the window opens, as a 5 field cron expression: minute hour day-of-month month day-of-week
+	Start string `json:"start,omitempty" yaml:"start,omitempty"`
+	// Duration is how long the window stays open, e.g. 2h
+	Duration string `json:"duration,omitempty" yaml:"duration,omitempty"`
+	// TimeZone is the IANA time zone Start is in. UTC if empty
+	TimeZone string `json:"time_zone,omitempty" yaml:"time_zone,omitempty"`
+	// NodeGroups are the names of the node groups frozen by the window. All of them if empty
+	NodeGroups []string `json:"node_groups,omitempty" yaml:"node_groups,omitempty"`
+	// UntaintNodes untaints the tainted nodes of the node groups while the window is open
+	UntaintNodes bool `json:"untaint_nodes,omitempty" yaml:"untaint_nodes,omitempty"`
+
+	// the window parsed from Start, Duration and TimeZone when the config was loaded
+	parsed *cronWindow
+}
+
+// validate returns the problems with the window, named by its position in maintenance_windows
+func (w MaintenanceWindow) validate(index int) []error {
+	var problems []error
+	field := func(name string) string {
+		return fmt.Sprintf("maintenance_windows[%v].%v", index, name)
+	}
+
+	if len(w.Name) == 0 {
+		problems = append(problems, errorkind.New(errorkind.Validation, "%v must not be empty", field("name")))
+	}
+	if _, err := parseCronSchedule(w.Start); err != nil {
+		problems = append(problems, errorkind.New(errorkind.Validation, "%v must be a valid cron expression: %v", field("start"), err))
+	}
+	duration, err := time.ParseDuration(w.Duration)
+	if err != nil {
+		problems = append(problems, errorkind.New(errorkind.Validation, "%v must be a valid duration: %v", field("duration"), err))
+	} else if duration <= 0 || duration > maxMaintenanceWindowDuration {
+		problems = append(problems, errorkind.New(errorkind.Validation, "%v must be above 0 and at most %v", field("duration"), maxMaintenanceWindowDuration))
+	}
+	if _, err := time.LoadLocation(w.TimeZone); err != nil {
+		problems = append(problems, errorkind.New(errorkind.Validation, "%v must be a valid time zone: %v", field("time_zone"), err))
+	}
+	return problems
+}
+
+// appliesTo returns whether the window freezes the node group
+func (w MaintenanceWindow) appliesTo(nodeGroup string) bool {
+	if len(w.NodeGroups) == 0 {
+		return true
+	}
+	for _, name := range w.NodeGroups {
+		if name == nodeGroup {
+			return true
+		}
+	}
+	return false
+}
+
+// cronWindow returns the parsed window, nil when it is invalid. Windows loaded with the config were parsed by
+// UnmarshalConfig, others are parsed on every call
+func (w MaintenanceWindow) cronWindow() *cronWindow {
+	if w.parsed != nil {
+		return w.parsed
+	}
+	parsed, _ := parseCronWindow(w.Start, w.Duration, w.TimeZone)
+	return parsed
+}
+
+// open returns whether the window opened less than its duration before now. Invalid windows are never open
+func (w MaintenanceWindow) open(now time.Time) bool {
+	window := w.cronWindow()
+	if window == nil {
+		return false
+	}
+	_, open := window.lastStart(now)
+	return open
+}
+
+// cronWindow is a window that opens on a cron schedule in a time zone and stays open for a duration
+type cronWindow struct {
+	schedule cronSchedule
+	length   time.Duration
+	location *time.Location
+}
+
+// parseCronWindow parses the window that opens on the cron schedule start in the time zone, UTC if empty, and stays
+// open for the duration
+func parseCronWindow(start string, duration string, timeZone string) (*cronWindow, error) {
+	schedule, err := parseCronSchedule(start)
+	if err != nil {
+		return nil, err
+	}
+	length, err := time.ParseDuration(duration)
+	if err != nil {
+		return nil, err
+	}
+	if length <= 0 {
+		return nil, fmt.Errorf("duration %v must be above 0", length)
+	}
+	location, err := time.LoadLocation(timeZone)
+	if err != nil {
+		return nil, err
+	}
+	return &cronWindow{schedule: schedule, length: length, location: location}, nil
+}
+
+// lastStart returns when the window last opened, and whether it is still open at now
+func (w *cronWindow) lastStart(now time.Time) (time.Time, bool) {
+	// walk back from the current minute until the window would have closed, skipping the days and hours the schedule
+	// doesn't fire in rather than checking each of their minutes
+	opened := now.In(w.location).Truncate(time.Minute)
+	for now.Sub(opened) < w.length {
+		switch {
+		case !w.schedule.matchesDay(opened):
+			opened = time.Date(opened.Year(), opened.Month(), opened.Day(), 0, 0, 0, 0, w.location).Add(-time.Minute)
+		case !w.schedule.hour[opened.Hour()]:
+			opened = time.Date(opened.Year(), opened.Month(), opened.Day(), opened.Hour(), 0, 0, 0, w.location).Add(-time.Minute)
+		case !w.schedule.minute[opened.Minute()]:
+			opened = opened.Add(-time.Minute)
+		default:
+			return opened, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// activeMaintenanceWindow returns the first maintenance window open at the time that freezes the node group
+func (o ClusterOptions) activeMaintenanceWindow(nodeGroup string, now time.Time) (MaintenanceWindow, bool) {
+	for _, window := range o.MaintenanceWindows {
+		if window.appliesTo(nodeGroup) && window.open(now) {
+			return window, true
+		}
+	}
+	return MaintenanceWindow{}, false
+}
+
+// updateMaintenanceWindow records the maintenance window the node group is in for the run, and logs when the node group
+// enters or leaves one
+func updateMaintenanceWindow(nodeGroup *NodeGroupState, window MaintenanceWindow, active bool) {
+	logger := log.WithField("nodegroup", nodeGroup.Opts.Name)
+	name := ""
+	if active {
+		name = window.Name
+	}
+	switch {
+	case name == nodeGroup.maintenanceWindow:
+	case len(name) == 0:
+		logger.Infof("Maintenance window %v closed, resuming scale down", nodeGroup.maintenanceWindow)
+	default:
+		logger.Infof("Maintenance window %v opened, freezing scale down for %v", name, window.Duration)
+	}
+	nodeGroup.maintenanceWindow = name
+	nodeGroup.cycle.MaintenanceWindow = name
+}
+
+// cronField is the values of a cron field that match, indexed by value
+type cronField []bool
+
+// cronSchedule is a parsed 5 field cron expression
+type cronSchedule struct {
+	minute     cronField
+	hour       cronField
+	dayOfMonth cronField
+	month      cronField
+	dayOfWeek  cronField
+	// the day of the month and day of the week match either one when both are restricted, as in cron
+	dayOfMonthAny bool
+	dayOfWeekAny  bool
+}
+
+// parseCronSchedule parses a 5 field cron expression. Each field is *, a value, a range a-b, or a list of these
+// separated by commas, each optionally with a step /n. Day of week 7 is Sunday, the same as 0
+func parseCronSchedule(expression string) (cronSchedule, error) {
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return cronSchedule{}, fmt.Errorf("expected 5 fields, got %v", len(fields))
+	}
+
+	var schedule cronSchedule
+	var err error
+	if schedule.minute, err = parseCronField(fields[0], 0, 59); err != nil {
+		return cronSchedule{}, fmt.Errorf("minute: %v", err)
+	}
+	if schedule.hour, err = parseCronField(fields[1], 0, 23); err != nil {
+		return cronSchedule{}, fmt.Errorf("hour: %v", err)
+	}
+	if schedule.dayOfMonth, err = parseCronField(fields[2], 1, 31); err != nil {
+		return cronSchedule{}, fmt.Errorf("day of month: %v", err)
+	}
+	if schedule.month, err = parseCronField(fields[3], 1, 12); err != nil {
+		return cronSchedule{}, fmt.Errorf("month: %v", err)
+	}
+	if schedule.dayOfWeek, err = parseCronField(fields[4], 0, 7); err != nil {
+		return cronSchedule{}, fmt.Errorf("day of week: %v", err)
+	}
+	if schedule.dayOfWeek[7] {
+		schedule.dayOfWeek[0] = true
+	}
+	schedule.dayOfMonthAny = strings.HasPrefix(fields[2], "*")
+	schedule.dayOfWeekAny = strings.HasPrefix(fields[4], "*")
+	return schedule, nil
+}
+
+// parseCronField parses a cron field whose values are between min and max
+func parseCronField(field string, min int, max int) (cronField, error) {
+	values := make(cronField, max+1)
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step < 1 {
+				return nil, fmt.Errorf("invalid step in %q", part)
+			}
+			part = part[:i]
+		}
+
+		from, to := min, max
+		switch {
+		case part == "*":
+		case strings.Contains(part, "-"):
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			if from, err = strconv.Atoi(bounds[0]); err != nil {
+				return nil, fmt.Errorf("invalid range %q", part)
+			}
+			if to, err = strconv.Atoi(bounds[1]); err != nil {
+				return nil, fmt.Errorf("invalid range %q", part)
+			}
+		default:
+			value, err := strconv.Atoi(part)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value %q", part)
+			}
+			from, to = value, value
+			// a single value with a step runs to the end of the field, as in cron
+			if step > 1 {
+				to = max
+			}
+		}
+		if from < min || to > max || from > to {
+			return nil, fmt.Errorf("%q is outside %v-%v", part, min, max)
+		}
+		for value := from; value <= to; value += step {
+			values[value] = true
+		}
+	}
+	return values, nil
+}
+
+// matches returns whether the schedule fires at the minute of the time, in the location of the time
+func (s cronSchedule) matches(t time.Time) bool {
+	return s.minute[t.Minute()] && s.hour[t.Hour()] && s.matchesDay(t)
+}
+
+// matchesDay returns whether the schedule fires on the day of the time, in the location of the time
+func (s cronSchedule) matchesDay(t time.Time) bool {
+	if !s.month[int(t.Month())] {
+		return false
+	}
+	dayOfMonth := s.dayOfMonth[t.Day()]
+	dayOfWeek := s.dayOfWeek[int(t.Weekday())]
+	if s.dayOfMonthAny || s.dayOfWeekAny {
+		return dayOfMonth && dayOfWeek
+	}
+	return dayOfMonth || dayOfWeek
+}
+
+// untaintMaintenanceWindowNodes untaints all of the tainted nodes of the node group for the maintenance window with
+// untaint_nodes. They are tainted again by the scale down after the window closes if they are still not needed
+func (c *Controller) untaintMaintenanceWindowNodes(opts scaleOpts, window MaintenanceWindow) int {
+	nodegroupName := opts.nodeGroup.Opts.Name
+	log.WithField("nodegroup", nodegroupName).Infof("Untainting %v tainted nodes for maintenance window %v", len(opts.taintedNodes), window.Name)
+	metrics.NodeGroupUntaintEvent.WithLabelValues(nodegroupName).Add(float64(len(opts.taintedNodes)))
+	untainted := c.untaintNewestN(opts.ctx, opts.taintedNodes, opts.nodeGroup, len(opts.taintedNodes), false)
+	return len(untainted)
+}
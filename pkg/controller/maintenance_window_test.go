@@ -0,0 +1,174 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/atlassian/escalator/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestParseCronSchedule(t *testing.T) {
+	// 2020-03-01 is a Sunday
+	sunday := time.Date(2020, 3, 1, 2, 30, 0, 0, time.UTC)
+	monday := sunday.AddDate(0, 0, 1)
+	fifteenth := time.Date(2020, 3, 15, 2, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		expression string
+		matches    []time.Time
+		misses     []time.Time
+	}{
+		{"* * * * *", []time.Time{sunday, monday}, nil},
+		{"30 2 * * 0", []time.Time{sunday, fifteenth}, []time.Time{monday, sunday.Add(time.Minute)}},
+		{"30 2 * * 7", []time.Time{sunday}, []time.Time{monday}},
+		{"*/15 1-3 * * 1-5", []time.Time{monday}, []time.Time{sunday, monday.Add(5 * time.Minute)}},
+		{"0,30 2 * 3 *", []time.Time{sunday, monday}, []time.Time{sunday.AddDate(0, 1, 0)}},
+		// the day of the month or the day of the week when both are restricted
+		{"30 2 15 * 1", []time.Time{monday, fifteenth}, []time.Time{sunday}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			schedule, err := parseCronSchedule(tt.expression)
+			require.NoError(t, err)
+			for _, match := range tt.matches {
+				assert.True(t, schedule.matches(match), "should match %v", match)
+			}
+			for _, miss := range tt.misses {
+				assert.False(t, schedule.matches(miss), "shouldn't match %v", miss)
+			}
+		})
+	}
+
+	for _, invalid := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 8", "5-1 * * * *", "*/0 * * * *", "a * * * *"} {
+		_, err := parseCronSchedule(invalid)
+		assert.Error(t, err, "%q should be invalid", invalid)
+	}
+}
+
+func TestMaintenanceWindowOpen(t *testing.T) {
+	window := MaintenanceWindow{Name: "upgrade", Start: "0 2 * * 0", Duration: "2h"}
+	sunday := time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	assert.False(t, window.open(sunday.Add(time.Hour+59*time.Minute)))
+	assert.True(t, window.open(sunday.Add(2*time.Hour)))
+	assert.True(t, window.open(sunday.Add(3*time.Hour+59*time.Minute)))
+	assert.False(t, window.open(sunday.Add(4*time.Hour)))
+	assert.False(t, window.open(sunday.AddDate(0, 0, 1).Add(3*time.Hour)))
+
+	// a window spanning midnight
+	window.Start = "0 23 * * 6"
+	assert.True(t, window.open(sunday.Add(30*time.Minute)))
+
+	// invalid windows are never open
+	window.Duration = "forever"
+	assert.False(t, window.open(sunday.Add(30*time.Minute)))
+}
+
+func TestCronWindowLastStart(t *testing.T) {
+	sydney, err := time.LoadLocation("Australia/Sydney")
+	require.NoError(t, err)
+	// 2020-04-05 03:00 is the end of daylight saving in Sydney
+	from := time.Date(2020, 4, 3, 0, 0, 0, 0, sydney)
+
+	for _, start := range []string{"* * * * *", "30 2 * * 0", "*/20 9-17 * * 1-5", "0 0 1 * *", "0 2,14 5 4 *"} {
+		t.Run(start, func(t *testing.T) {
+			window, err := parseCronWindow(start, "72h", "Australia/Sydney")
+			require.NoError(t, err)
+
+			// the skipping walk finds the same start as checking every minute
+			for now := from; now.Before(from.AddDate(0, 0, 4)); now = now.Add(17 * time.Minute) {
+				var expected time.Time
+				for minute := now.Truncate(time.Minute); now.Sub(minute) < window.length; minute = minute.Add(-time.Minute) {
+					if window.schedule.matches(minute.In(sydney)) {
+						expected = minute
+						break
+					}
+				}
+				opened, open := window.lastStart(now)
+				assert.Equal(t, !expected.IsZero(), open, "open at %v", now)
+				assert.True(t, expected.Equal(opened), "opened at %v rather than %v at %v", opened, expected, now)
+			}
+		})
+	}
+
+	for _, invalid := range [][3]string{{"* * * *", "1h", ""}, {"* * * * *", "0s", ""}, {"* * * * *", "1h", "Nowhere/Special"}} {
+		_, err := parseCronWindow(invalid[0], invalid[1], invalid[2])
+		assert.Error(t, err, "%v should be invalid", invalid)
+	}
+}
+
+func TestUnmarshalConfig_ParsesWindows(t *testing.T) {
+	config, err := UnmarshalConfig(strings.NewReader(`
+maintenance_windows:
+  - name: upgrade
+    start: "0 2 * * 0"
+    duration: 2h
+    time_zone: Australia/Sydney
+node_groups:
+  - name: shared
+    reservations:
+      - name: batch
+        start: "0 9 * * 1-5"
+        duration: 8h
+`))
+	require.NoError(t, err)
+
+	require.NotNil(t, config.MaintenanceWindows[0].parsed)
+	assert.Equal(t, "Australia/Sydney", config.MaintenanceWindows[0].parsed.location.String())
+	assert.Equal(t, 2*time.Hour, config.MaintenanceWindows[0].parsed.length)
+	require.NotNil(t, config.NodeGroups[0].Reservations[0].parsed)
+	assert.Equal(t, 8*time.Hour, config.NodeGroups[0].Reservations[0].parsed.length)
+}
+
+func TestActiveMaintenanceWindow(t *testing.T) {
+	now := time.Date(2020, 3, 1, 2, 30, 0, 0, time.UTC)
+	opts := ClusterOptions{MaintenanceWindows: []MaintenanceWindow{
+		{Name: "closed", Start: "0 12 * * *", Duration: "1h"},
+		{Name: "batch", Start: "0 2 * * *", Duration: "1h", NodeGroups: []string{"batch"}},
+		{Name: "all", Start: "0 2 * * *", Duration: "1h"},
+	}}
+
+	window, active := opts.activeMaintenanceWindow("batch", now)
+	assert.True(t, active)
+	assert.Equal(t, "batch", window.Name)
+	window, active = opts.activeMaintenanceWindow("shared", now)
+	assert.True(t, active)
+	assert.Equal(t, "all", window.Name)
+	_, active = opts.activeMaintenanceWindow("shared", now.Add(time.Hour))
+	assert.False(t, active)
+}
+
+func TestUpdateMaintenanceWindow(t *testing.T) {
+	nodeGroup := &NodeGroupState{Opts: NodeGroupOptions{Name: "default"}}
+	window := MaintenanceWindow{Name: "upgrade", Duration: "2h"}
+
+	updateMaintenanceWindow(nodeGroup, window, true)
+	assert.Equal(t, "upgrade", nodeGroup.maintenanceWindow)
+	assert.Equal(t, "upgrade", nodeGroup.cycle.MaintenanceWindow)
+
+	nodeGroup.cycle = CycleSummary{}
+	updateMaintenanceWindow(nodeGroup, MaintenanceWindow{}, false)
+	assert.Empty(t, nodeGroup.maintenanceWindow)
+	assert.Empty(t, nodeGroup.cycle.MaintenanceWindow)
+}
+
+func TestUntaintMaintenanceWindowNodes(t *testing.T) {
+	now := time.Now()
+	nodes := []*v1.Node{
+		test.BuildTestNode(test.NodeOpts{Name: "n1"}),
+		test.BuildTestNode(test.NodeOpts{Name: "n2"}),
+	}
+	nodeGroup := &NodeGroupState{Opts: NodeGroupOptions{Name: "default", DryMode: true}}
+	nodeGroup.dryTaintNode(nodes[0], now)
+	nodeGroup.dryTaintNode(nodes[1], now)
+	c := &Controller{}
+
+	untainted := c.untaintMaintenanceWindowNodes(scaleOpts{nodes: nodes, taintedNodes: nodes, nodeGroup: nodeGroup, ctx: context.Background()}, MaintenanceWindow{Name: "upgrade", UntaintNodes: true})
+	assert.Equal(t, 2, untainted)
+	assert.Empty(t, nodeGroup.taintTracker)
+}
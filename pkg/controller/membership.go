@@ -0,0 +1,78 @@
+package controller
+
+import (
+	"github.com/atlassian/escalator/pkg/k8s"
+	"github.com/atlassian/escalator/pkg/metrics"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// nodeGroupMembership returns the name of the first node group that matches the labels and platform of the node
+// returns an empty string if the node doesn't match any node group
+func nodeGroupMembership(node *v1.Node, nodeGroups []NodeGroupOptions) string {
+	for _, nodeGroup := range nodeGroups {
+		if newNodeGroupNodeFilterFunc(nodeGroup)(node) {
+			return nodeGroup.Name
+		}
+	}
+	return ""
+}
+
+// updateNodeGroupMembership lists all nodes and handles any that have moved between node groups since the last run
+func (c *Controller) updateNodeGroupMembership() {
+	if c.Client == nil || c.Client.allNodeLister == nil {
+		return
+	}
+
+	nodes, err := c.Client.allNodeLister.List(labels.Everything())
+	if err != nil {
+		log.WithError(err).Warn("Failed to list nodes for node group membership")
+		return
+	}
+	c.handleMembershipTransitions(nodes)
+}
+
+// handleMembershipTransitions detects nodes whose labels have moved them to a different node group since the last run,
+// e.g. when they are moved between pools by another system. Any taint applied under the old node group's policy is
+// removed so the node is evaluated from scratch by its new node group
+func (c *Controller) handleMembershipTransitions(nodes []*v1.Node) {
+	membership := make(map[string]string, len(nodes))
+	for _, node := range nodes {
+		current := nodeGroupMembership(node, c.Opts.NodeGroups)
+		membership[node.Name] = current
+
+		previous, seen := c.nodeMembership[node.Name]
+		if !seen || previous == current {
+			continue
+		}
+
+		log.WithField("nodegroup", current).Infof("node %v moved from node group %q to %q", node.Name, previous, current)
+		metrics.NodeGroupMembershipTransitions.WithLabelValues(previous, current).Add(1.0)
+		c.clearTaintFromPreviousNodeGroup(node, previous)
+	}
+	c.nodeMembership = membership
+}
+
+// clearTaintFromPreviousNodeGroup removes the taint that the previous node group applied to the node
+func (c *Controller) clearTaintFromPreviousNodeGroup(node *v1.Node, previous string) {
+	previousState, ok := c.nodeGroups[previous]
+	// escalator never taints the nodes of an observe only node group
+	if ok && previousState.Opts.ObserveOnly {
+		return
+	}
+	if ok && c.dryTaint(previousState) {
+		if previousState.dryUntaintNode(node) {
+			log.WithField("drymode", "on").Infof("Untainting node %v after it left node group %q", node.Name, previous)
+		}
+		return
+	}
+
+	if !k8s.MarkedToBeRemoved(node) {
+		return
+	}
+	log.WithField("drymode", "off").Infof("Untainting node %v after it left node group %q", node.Name, previous)
+	if _, err := k8s.DeleteToBeRemovedTaint(node, c.Client); err != nil {
+		log.WithError(err).Errorf("Failed to untaint node %v after it left node group %q", node.Name, previous)
+	}
+}
@@ -0,0 +1,58 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/atlassian/escalator/pkg/k8s"
+	"github.com/atlassian/escalator/pkg/test"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestNodeGroupMembership(t *testing.T) {
+	nodeGroups := []NodeGroupOptions{
+		{Name: "shared", LabelKey: "customer", LabelValue: "shared"},
+		{Name: "buildeng", LabelKey: "customer", LabelValue: "buildeng"},
+	}
+
+	assert.Equal(t, "buildeng", nodeGroupMembership(test.BuildTestNode(test.NodeOpts{LabelKey: "customer", LabelValue: "buildeng"}), nodeGroups))
+	assert.Equal(t, "", nodeGroupMembership(test.BuildTestNode(test.NodeOpts{LabelKey: "customer", LabelValue: "gpu"}), nodeGroups))
+
+	// node groups sharing a label are told apart by their platform
+	nodeGroups = []NodeGroupOptions{
+		{Name: "shared-linux", LabelKey: "customer", LabelValue: "shared", OS: k8s.OSLinux},
+		{Name: "shared-windows", LabelKey: "customer", LabelValue: "shared", OS: k8s.OSWindows},
+	}
+	windows := test.BuildTestNode(test.NodeOpts{LabelKey: "customer", LabelValue: "shared"})
+	windows.Labels[k8s.OSLabelKey] = k8s.OSWindows
+	assert.Equal(t, "shared-windows", nodeGroupMembership(windows, nodeGroups))
+	assert.Equal(t, "shared-linux", nodeGroupMembership(test.BuildTestNode(test.NodeOpts{LabelKey: "customer", LabelValue: "shared"}), nodeGroups))
+}
+
+func TestControllerHandleMembershipTransitions(t *testing.T) {
+	nodeGroups := []NodeGroupOptions{
+		{Name: "shared", LabelKey: "customer", LabelValue: "shared"},
+		{Name: "buildeng", LabelKey: "customer", LabelValue: "buildeng"},
+	}
+	nodeGroupsState := BuildNodeGroupsState(nodeGroupsStateOpts{nodeGroups: nodeGroups})
+	nodeGroupsState["shared"].taintTracker = []DryModeTaint{{Node: "n1"}, {Node: "n2"}}
+
+	c := &Controller{
+		Opts:       Opts{NodeGroups: nodeGroups, DryMode: true},
+		nodeGroups: nodeGroupsState,
+	}
+
+	nodes := []*v1.Node{
+		test.BuildTestNode(test.NodeOpts{Name: "n1", LabelKey: "customer", LabelValue: "shared"}),
+		test.BuildTestNode(test.NodeOpts{Name: "n2", LabelKey: "customer", LabelValue: "shared"}),
+	}
+	c.handleMembershipTransitions(nodes)
+	assert.Equal(t, map[string]string{"n1": "shared", "n2": "shared"}, c.nodeMembership)
+	assert.Equal(t, []string{"n1", "n2"}, nodeGroupsState["shared"].dryTaintedNodes())
+
+	// n1 is moved to another pool
+	nodes[0] = test.BuildTestNode(test.NodeOpts{Name: "n1", LabelKey: "customer", LabelValue: "buildeng"})
+	c.handleMembershipTransitions(nodes)
+	assert.Equal(t, map[string]string{"n1": "buildeng", "n2": "shared"}, c.nodeMembership)
+	assert.Equal(t, []string{"n2"}, nodeGroupsState["shared"].dryTaintedNodes())
+}
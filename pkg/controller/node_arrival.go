@@ -0,0 +1,38 @@
+package controller
+
+import (
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+)
+
+// nodeAddedEvents returns the channel of the nodes registered since the cache synced, nil without a watching client
+func (c *Controller) nodeAddedEvents() <-chan *v1.Node {
+	if c.Client == nil {
+		return nil
+	}
+	return c.Client.nodeAdded
+}
+
+// handleNodeAdded accounts a newly registered node against the in flight scale up of its node group as soon as it is
+// watched, instead of at the next run. The follow up runs waiting for the node are run straight away.
+// Only errors that should stop escalator are returned
+func (c *Controller) handleNodeAdded(node *v1.Node, now time.Time) error {
+	followUpsWaiting := false
+	for _, nodeGroupOpts := range c.Opts.NodeGroups {
+		state, ok := c.nodeGroups[nodeGroupOpts.Name]
+		if !ok || !newNodeGroupNodeFilterFunc(state.Opts)(node) {
+			continue
+		}
+		log.WithField("nodegroup", nodeGroupOpts.Name).Debugf("Node %v registered", node.Name)
+		state.scaleUpLock.nodeArrived(now)
+		if state.followUp != nil {
+			followUpsWaiting = true
+		}
+	}
+	if !followUpsWaiting {
+		return nil
+	}
+	return c.runFollowUps(now)
+}
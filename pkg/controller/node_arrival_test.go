@@ -0,0 +1,51 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/atlassian/escalator/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestControllerHandleNodeAdded(t *testing.T) {
+	nodeGroups := []NodeGroupOptions{
+		{Name: "example", LabelKey: "customer", LabelValue: "example", ScaleUpCoolDownPeriod: "1m"},
+		{Name: "other", LabelKey: "customer", LabelValue: "other", ScaleUpCoolDownPeriod: "1m"},
+	}
+	client, opts := buildTestClient(nil, nil, nodeGroups, ListerOptions{})
+	nodeGroupsState := BuildNodeGroupsState(nodeGroupsStateOpts{nodeGroups: nodeGroups, client: *client})
+	c := &Controller{Client: client, Opts: opts, nodeGroups: nodeGroupsState}
+
+	lock := &nodeGroupsState["example"].scaleUpLock
+	lock.lock(2)
+	now := time.Now()
+	newNode := func(name string, labelValue string) *v1.Node {
+		return test.BuildTestNode(test.NodeOpts{Name: name, LabelKey: "customer", LabelValue: labelValue})
+	}
+
+	// nodes of other node groups aren't accounted
+	require.NoError(t, c.handleNodeAdded(newNode("n0", "other"), now))
+	assert.Equal(t, 2, lock.incomingNodes())
+
+	require.NoError(t, c.handleNodeAdded(newNode("n1", "example"), now))
+	assert.True(t, lock.isLocked)
+	assert.Equal(t, 1, lock.incomingNodes())
+
+	// the lock is released once every requested node registered, the cool down still applies
+	require.NoError(t, c.handleNodeAdded(newNode("n2", "example"), now))
+	assert.False(t, lock.isLocked)
+	assert.Equal(t, 0, lock.incomingNodes())
+	assert.True(t, lock.locked())
+
+	// nodes registering after the lock was released are ignored
+	require.NoError(t, c.handleNodeAdded(newNode("n3", "example"), now))
+	assert.Equal(t, 0, lock.arrivedNodes)
+}
+
+func TestControllerNodeAddedEvents(t *testing.T) {
+	c := &Controller{}
+	assert.Nil(t, c.nodeAddedEvents())
+}
@@ -1,13 +1,14 @@
 package controller
 
 import (
-	"fmt"
 	"io"
+	"net/url"
+	"strings"
 	"time"
 
+	"github.com/atlassian/escalator/pkg/errorkind"
 	"github.com/atlassian/escalator/pkg/k8s"
 	v1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/util/yaml"
 	v1lister "k8s.io/client-go/listers/core/v1"
 )
 
@@ -21,26 +22,166 @@ type NodeGroupOptions struct {
 	LabelKey               string `json:"label_key,omitempty" yaml:"label_key,omitempty"`
 	LabelValue             string `json:"label_value,omitempty" yaml:"label_value,omitempty"`
 	CloudProviderGroupName string `json:"cloud_provider_group_name,omitempty" yaml:"cloud_provider_group_name,omitempty"`
+	// CloudProviderGroupNames are the node groups in the cloud provider backing the node group when there are several,
+	// e.g. an auto scaling group per availability zone. Set instead of CloudProviderGroupName
+	CloudProviderGroupNames []string `json:"cloud_provider_group_names,omitempty" yaml:"cloud_provider_group_names,omitempty"`
+	// CloudProvider is the cloud provider of the node group, e.g. aws. Empty means the --cloud-provider flag
+	CloudProvider string `json:"cloud_provider,omitempty" yaml:"cloud_provider,omitempty"`
+	// OS is the operating system of the nodes in the node group, either linux or windows. Optional for single OS clusters
+	OS string `json:"os,omitempty" yaml:"os,omitempty"`
+	// Arch is the cpu architecture of the nodes in the node group, e.g. amd64 or arm64. Optional for single architecture clusters
+	Arch string `json:"arch,omitempty" yaml:"arch,omitempty"`
 
 	MinNodes int `json:"min_nodes,omitempty" yaml:"min_nodes,omitempty"`
 	MaxNodes int `json:"max_nodes,omitempty" yaml:"max_nodes,omitempty"`
 
 	DryMode bool `json:"dry_mode,omitempty" yaml:"dry_mode,omitempty"`
+	// DryTaint, DryDelete and DryScaleUp simulate only part of the scaling actions. DryMode simulates all of them
+	DryTaint   bool `json:"dry_taint,omitempty" yaml:"dry_taint,omitempty"`
+	DryDelete  bool `json:"dry_delete,omitempty" yaml:"dry_delete,omitempty"`
+	DryScaleUp bool `json:"dry_scale_up,omitempty" yaml:"dry_scale_up,omitempty"`
+	// AuditMode performs kubernetes actions but only dry runs cloud provider deletions
+	AuditMode bool `json:"audit_mode,omitempty" yaml:"audit_mode,omitempty"`
+	// ObserveOnly computes the utilisation and scaling decision of a node group not managed by escalator, without
+	// ever acting on it
+	ObserveOnly bool `json:"observe_only,omitempty" yaml:"observe_only,omitempty"`
 
 	TaintUpperCapacityThresholdPercent int `json:"taint_upper_capacity_threshold_percent,omitempty" yaml:"taint_upper_capacity_threshold_percent,omitempty"`
 	TaintLowerCapacityThresholdPercent int `json:"taint_lower_capacity_threshold_percent,omitempty" yaml:"taint_lower_capacity_threshold_percent,omitempty"`
 
 	ScaleUpThresholdPercent int `json:"scale_up_threshold_percent,omitempty" yaml:"scale_up_threshold_percent,omitempty"`
+	// ScaleUpSteps optionally increases the scale up delta as utilisation climbs past each step
+	ScaleUpSteps []ScaleUpStep `json:"scale_up_steps,omitempty" yaml:"scale_up_steps,omitempty"`
+	// ScaleUpRate optionally scales up when the utilisation rises quickly, before it reaches the threshold
+	ScaleUpRate ScaleUpRate `json:"scale_up_rate" yaml:"scale_up_rate"`
+	// ResourceProfiles optionally track the utilisation of label selected pods against their own scale up threshold
+	ResourceProfiles []ResourceProfile `json:"resource_profiles,omitempty" yaml:"resource_profiles,omitempty"`
 
 	SlowNodeRemovalRate int `json:"slow_node_removal_rate,omitempty" yaml:"slow_node_removal_rate,omitempty"`
 	FastNodeRemovalRate int `json:"fast_node_removal_rate,omitempty" yaml:"fast_node_removal_rate,omitempty"`
 
+	// ScaleUpCount is the minimum absolute number of nodes added by a scale up
+	ScaleUpCount int `json:"scale_up_count,omitempty" yaml:"scale_up_count,omitempty"`
+	// ScaleDownCount is the minimum absolute number of nodes tainted by a scale down, raising either removal rate
+	ScaleDownCount int `json:"scale_down_count,omitempty" yaml:"scale_down_count,omitempty"`
+
 	SoftDeleteGracePeriod string `json:"soft_delete_grace_period,omitempty" yaml:"soft_delete_grace_period,omitempty"`
 	HardDeleteGracePeriod string `json:"hard_delete_grace_period,omitempty" yaml:"soft_delete_grace_period,omitempty"`
+	// SoftDeleteGracePeriodFrom is when the soft delete grace period starts, either taint (default) or empty.
+	// With empty it starts once a tainted node becomes empty, the hard delete grace period always starts at the taint
+	SoftDeleteGracePeriodFrom string `json:"soft_delete_grace_period_from,omitempty" yaml:"soft_delete_grace_period_from,omitempty"`
 
 	ScaleUpCoolDownPeriod string `json:"scale_up_cool_down_period,omitempty" yaml:"scale_up_cool_down_period,omitempty"`
+	// AutoScaleUpCoolDown sizes the scale up cool down period to the 90th percentile of the measured boot durations of
+	// the nodes of the node group, in place of scale_up_cool_down_period once enough have been measured
+	AutoScaleUpCoolDown bool `json:"auto_scale_up_cool_down,omitempty" yaml:"auto_scale_up_cool_down,omitempty"`
 
 	TaintEffect v1.TaintEffect `json:"taint_effect,omitempty" yaml:"taint_effect,omitempty"`
+	// ScaleDownMode is how the nodes to be removed are marked: taint (default), cordon or both
+	ScaleDownMode string `json:"scale_down_mode,omitempty" yaml:"scale_down_mode,omitempty"`
+	// TagTaintedInstances tags the instances of the nodes tainted for removal with the time they were tainted, for
+	// tooling without access to Kubernetes
+	TagTaintedInstances bool `json:"tag_tainted_instances,omitempty" yaml:"tag_tainted_instances,omitempty"`
+
+	// MaxNodeAge enables recycling of nodes that are older than the duration
+	MaxNodeAge string `json:"max_node_age,omitempty" yaml:"max_node_age,omitempty"`
+	// RecycleMode defines how nodes older than MaxNodeAge are replaced
+	RecycleMode string `json:"recycle_mode,omitempty" yaml:"recycle_mode,omitempty"`
+
+	// VersionSkew taints the nodes on an outdated kubelet or OS image first when the node group scales down
+	VersionSkew VersionSkewOptions `json:"version_skew" yaml:"version_skew"`
+
+	// ReplaceOnMaintenance replaces the nodes the cloud provider scheduled disruptive maintenance for, e.g. the
+	// retirement of their instance, ahead of the maintenance
+	ReplaceOnMaintenance bool `json:"replace_on_maintenance,omitempty" yaml:"replace_on_maintenance,omitempty"`
+
+	// SpotInterruption taints and replaces the nodes whose spot instance received an interruption notice
+	SpotInterruption SpotInterruptionOptions `json:"spot_interruption" yaml:"spot_interruption"`
+
+	// UnhealthyNodeConditions are node condition types, e.g. from node-problem-detector, that make a node the first
+	// candidate for tainting on scale down
+	UnhealthyNodeConditions []string `json:"unhealthy_node_conditions,omitempty" yaml:"unhealthy_node_conditions,omitempty"`
+
+	// ExcludeBestEffortPods leaves pods in the BestEffort QoS class out of the pods the node group scales on
+	ExcludeBestEffortPods bool `json:"exclude_best_effort_pods,omitempty" yaml:"exclude_best_effort_pods,omitempty"`
+
+	// ScaleUpEnabled and ScaleDownEnabled allow the node group to only grow or only shrink. Both default to true
+	ScaleUpEnabled   *bool `json:"scale_up_enabled,omitempty" yaml:"scale_up_enabled,omitempty"`
+	ScaleDownEnabled *bool `json:"scale_down_enabled,omitempty" yaml:"scale_down_enabled,omitempty"`
+
+	// AggressiveScaleDown disables the rescheduling simulation when tainting and deleting nodes
+	AggressiveScaleDown bool `json:"aggressive_scale_down,omitempty" yaml:"aggressive_scale_down,omitempty"`
+
+	// ScaleDownAfter is the names of the node groups that depend on this node group. It isn't scaled down while any of
+	// them are above ScaleDownAfterThresholdPercent utilisation
+	ScaleDownAfter []string `json:"scale_down_after,omitempty" yaml:"scale_down_after,omitempty"`
+	// ScaleDownAfterThresholdPercent defaults to the taint_upper_capacity_threshold_percent of each dependent node group
+	ScaleDownAfterThresholdPercent int `json:"scale_down_after_threshold_percent,omitempty" yaml:"scale_down_after_threshold_percent,omitempty"`
+
+	// Pool is the name of the capacity pool of the node group. The thresholds of the node groups in a pool are evaluated
+	// against their summed requests and capacity, and the scale actions are distributed between them
+	Pool string `json:"pool,omitempty" yaml:"pool,omitempty"`
+	// PoolScalePolicy is how the scale actions of the pool are distributed between its node groups, balanced (default),
+	// priority or proportional. It must be the same for every node group in the pool
+	PoolScalePolicy string `json:"pool_scale_policy,omitempty" yaml:"pool_scale_policy,omitempty"`
+
+	// QuotaPriority is the weight of the node group when the quota_coordinator shares a scarce cloud provider quota
+	// between the node groups scaling up against it. Defaults to 1
+	QuotaPriority int `json:"quota_priority,omitempty" yaml:"quota_priority,omitempty"`
+
+	// QueueDemand adds the demand waiting in a batch job queue to the requests of the node group
+	QueueDemand QueueDemandOptions `json:"queue_demand" yaml:"queue_demand"`
+
+	// NewNodeGracePeriod is how long after registering a node that isn't Ready yet is left out of the capacity and
+	// tainting candidates of the node group
+	NewNodeGracePeriod string `json:"new_node_grace_period,omitempty" yaml:"new_node_grace_period,omitempty"`
+
+	// StartupTaints are the taint keys that mark a new node as still starting up, e.g. until the cloud provider has
+	// initialised it. Defaults to k8s.DefaultStartupTaintKeys when unset, an empty list disables them
+	StartupTaints []string `json:"startup_taints" yaml:"startup_taints"`
+
+	// ThirdPartyTaints is how the nodes tainted by other systems, e.g. a GPU health daemon, are treated
+	ThirdPartyTaints ThirdPartyTaintsOptions `json:"third_party_taints" yaml:"third_party_taints"`
+
+	// HighWaterMarkHold holds the minimum of the node group at a percentage of its peak for a while after a scale up
+	HighWaterMarkHold HighWaterMarkHoldOptions `json:"high_water_mark_hold" yaml:"high_water_mark_hold"`
+	// Reservations raise the minimum of the node group by the nodes reserved for a tenant while their window is open
+	Reservations []Reservation `json:"reservations,omitempty" yaml:"reservations,omitempty"`
+
+	// DaemonSetDrain deletes the pods of DaemonSets that must flush before a node is deleted, and waits for them
+	DaemonSetDrain DaemonSetDrainOptions `json:"daemonset_drain" yaml:"daemonset_drain"`
+
+	// PreDeleteHook is a webhook that is called before a node is deleted, which can veto or delay the deletion
+	PreDeleteHook PreDeleteHookOptions `json:"pre_delete_hook" yaml:"pre_delete_hook"`
+
+	// ProtectedPods are the pods that stop a tainted node being deleted, even past its hard delete grace period
+	ProtectedPods ProtectedPodsOptions `json:"protected_pods" yaml:"protected_pods"`
+	// EvictionLimits pace the pods evicted by deleting tainted nodes that aren't empty, so their pods don't all
+	// reschedule at once
+	EvictionLimits EvictionLimitsOptions `json:"eviction_limits" yaml:"eviction_limits"`
+	// VolumeDetach delays and spaces out the deletions of tainted nodes with volumes attached, so the volumes detach
+	// before their pods need them on other nodes
+	VolumeDetach VolumeDetachOptions `json:"volume_detach" yaml:"volume_detach"`
+	// PodDisruptionBudgetMaxDeferral is how long the hard deletion of a tainted node is deferred for while evicting its
+	// pods would violate a PodDisruptionBudget, with --pod-disruption-budgets. Defaults to 1h
+	PodDisruptionBudgetMaxDeferral string `json:"pod_disruption_budget_max_deferral,omitempty" yaml:"pod_disruption_budget_max_deferral,omitempty"`
+	// RescheduleMaxDeferral is how long the hard deletion of a tainted node is deferred for while its pods can't be
+	// rescheduled onto the untainted nodes. Defaults to 1h
+	RescheduleMaxDeferral string `json:"reschedule_max_deferral,omitempty" yaml:"reschedule_max_deferral,omitempty"`
+
+	// DisruptionScore taints the nodes whose pods are the least disruptive to move first, instead of the oldest
+	DisruptionScore DisruptionScoreOptions `json:"disruption_score" yaml:"disruption_score"`
+	// TaintSelectionPolicy is the order the nodes are tainted in on scale down: oldest (default), newest,
+	// least-utilized, fewest-pods or disruption-score
+	TaintSelectionPolicy string `json:"taint_selection_policy,omitempty" yaml:"taint_selection_policy,omitempty"`
+
+	// StaticPodNodes is what happens to the nodes running static pods: protect (default) never scales them down, ignore
+	// scales them down like any other node
+	StaticPodNodes string `json:"static_pod_nodes,omitempty" yaml:"static_pod_nodes,omitempty"`
+
+	// FollowUpTimeout enables a follow up run of the node group as soon as the result of a scale action is observable,
+	// instead of waiting for the next scan interval. The follow up is dropped if nothing is observed within the duration
+	FollowUpTimeout string `json:"follow_up_timeout,omitempty" yaml:"follow_up_timeout,omitempty"`
 
 	AWS AWSNodeGroupOptions `json:"aws" yaml:"aws"`
 
@@ -48,6 +189,18 @@ type NodeGroupOptions struct {
 	softDeleteGracePeriodDuration time.Duration
 	hardDeleteGracePeriodDuration time.Duration
 	scaleUpCoolDownPeriodDuration time.Duration
+	maxNodeAgeDuration            time.Duration
+	followUpTimeoutDuration       time.Duration
+	newNodeGracePeriodDuration    time.Duration
+	daemonSetDrainTimeoutDuration time.Duration
+	highWaterMarkHoldDuration     time.Duration
+	preDeleteHookTimeoutDuration  time.Duration
+	evictionNodeIntervalDuration  time.Duration
+	volumeDelayPerVolumeDuration  time.Duration
+	volumeMaxDelayDuration        time.Duration
+	heavyNodeIntervalDuration     time.Duration
+	pdbMaxDeferralDuration        time.Duration
+	rescheduleMaxDeferralDuration time.Duration
 }
 
 // AWSNodeGroupOptions represents a nodegroup running on a cluster that is
@@ -56,20 +209,186 @@ type AWSNodeGroupOptions struct {
 	LaunchTemplateID          string `json:"launch_template_id,omitempty" yaml:"launch_template_id,omitempty"`
 	LaunchTemplateVersion     string `json:"launch_template_version,omitempty" yaml:"launch_template_version,omitempty"`
 	FleetInstanceReadyTimeout string `json:"fleet_instance_ready_timeout,omitempty" yaml:"fleet_instance_ready_timeout,omitempty"`
+	// EKSClusterName and EKSNodegroupName are the EKS managed node group backed by the auto scaling group, for
+	// scaling it through the EKS api
+	EKSClusterName   string `json:"eks_cluster_name,omitempty" yaml:"eks_cluster_name,omitempty"`
+	EKSNodegroupName string `json:"eks_nodegroup_name,omitempty" yaml:"eks_nodegroup_name,omitempty"`
 
 	// Private variables for storing the parsed duration from the string
 	fleetInstanceReadyTimeout time.Duration
 }
 
+// SpotInterruptionOptions configures how the spot interruption notices of the nodes are read, e.g. as set on the nodes
+// by aws-node-termination-handler or node-problem-detector
+type SpotInterruptionOptions struct {
+	// NodeConditions are the node condition types that are true on a node with an interruption notice
+	NodeConditions []string `json:"node_conditions,omitempty" yaml:"node_conditions,omitempty"`
+	// TaintKeys are the keys of the taints put on a node with an interruption notice
+	TaintKeys []string `json:"taint_keys,omitempty" yaml:"taint_keys,omitempty"`
+	// TaintEffect is the effect of the taint put on interrupted nodes. Defaults to NoExecute
+	TaintEffect v1.TaintEffect `json:"taint_effect,omitempty" yaml:"taint_effect,omitempty"`
+}
+
+// QueueDemandOptions configures the batch job queue that the demand of the node group is read from
+type QueueDemandOptions struct {
+	// Provider is either kueue or volcano
+	Provider string `json:"provider,omitempty" yaml:"provider,omitempty"`
+	// Queue is the name of the Kueue ClusterQueue or the Volcano Queue
+	Queue string `json:"queue,omitempty" yaml:"queue,omitempty"`
+}
+
+// VersionSkewOptions configures tainting the nodes that are behind the newest nodes of the node group first on scale
+// down, so the version skew closes as the node group scales
+type VersionSkewOptions struct {
+	// Kubelet prioritises the nodes on an older kubelet version than the newest kubelet of the node group
+	Kubelet bool `json:"kubelet,omitempty" yaml:"kubelet,omitempty"`
+	// OSImage prioritises the nodes on another OS image than the most recently created node
+	OSImage bool `json:"os_image,omitempty" yaml:"os_image,omitempty"`
+	// MaxNodesPerRun is the most outdated nodes prioritised in a run. Defaults to 1
+	MaxNodesPerRun int `json:"max_nodes_per_run,omitempty" yaml:"max_nodes_per_run,omitempty"`
+}
+
+// ThirdPartyTaintsOptions configures how the nodes with a taint put on them by another system are treated
+type ThirdPartyTaintsOptions struct {
+	// Keys are the taint keys other systems put on the nodes of the node group
+	Keys []string `json:"keys,omitempty" yaml:"keys,omitempty"`
+	// Policy is either capacity (default), exclude or prioritise_removal
+	Policy string `json:"policy,omitempty" yaml:"policy,omitempty"`
+}
+
+// HighWaterMarkHoldOptions configures holding the minimum of the node group after a scale up past ThresholdNodes
+type HighWaterMarkHoldOptions struct {
+	// ThresholdNodes is the number of untainted nodes a scale up has to reach to start a hold
+	ThresholdNodes int `json:"threshold_nodes,omitempty" yaml:"threshold_nodes,omitempty"`
+	// Percent of the peak number of untainted nodes that the minimum is held at
+	Percent int `json:"percent,omitempty" yaml:"percent,omitempty"`
+	// Duration of the hold after the last scale up past ThresholdNodes
+	Duration string `json:"duration,omitempty" yaml:"duration,omitempty"`
+}
+
+// Reservation reserves nodes of the node group for a tenant during a window, either once between From and Until or
+// every time the cron schedule Start opens it for Duration
+type Reservation struct {
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+	// Tenant the nodes are reserved for, e.g. a namespace or a label. Only shown in the report and logs
+	Tenant string `json:"tenant,omitempty" yaml:"tenant,omitempty"`
+	// Nodes is the number of nodes added to the minimum of the node group while the reservation is active
+	Nodes int `json:"nodes,omitempty" yaml:"nodes,omitempty"`
+	// From and Until are the RFC3339 times of a one off reservation
+	From  string `json:"from,omitempty" yaml:"from,omitempty"`
+	Until string `json:"until,omitempty" yaml:"until,omitempty"`
+	// Start is when a recurring reservation opens, as a 5 field cron expression in TimeZone, UTC if empty
+	Start    string `json:"start,omitempty" yaml:"start,omitempty"`
+	Duration string `json:"duration,omitempty" yaml:"duration,omitempty"`
+	TimeZone string `json:"time_zone,omitempty" yaml:"time_zone,omitempty"`
+
+	// the window of a recurring reservation parsed from Start, Duration and TimeZone when the config was loaded
+	parsed *cronWindow
+}
+
+// parseReservations parses the windows of the recurring reservations once, so checking whether they are active doesn't
+// parse the cron schedule and load the time zone every run. Invalid windows are left to the validation
+func (n *NodeGroupOptions) parseReservations() {
+	for i := range n.Reservations {
+		if n.Reservations[i].recurring() {
+			n.Reservations[i].parsed, _ = parseCronWindow(n.Reservations[i].Start, n.Reservations[i].Duration, n.Reservations[i].TimeZone)
+		}
+	}
+}
+
+// DaemonSetDrainOptions configures the DaemonSet pods deleted from a node before it is deleted
+type DaemonSetDrainOptions struct {
+	// DaemonSets are the DaemonSets whose pods are deleted, as namespace/name
+	DaemonSets []string `json:"daemonsets,omitempty" yaml:"daemonsets,omitempty"`
+	// CompletedAnnotation is the node annotation set once the pods have flushed, e.g. by their preStop hook
+	CompletedAnnotation string `json:"completed_annotation,omitempty" yaml:"completed_annotation,omitempty"`
+	// Timeout is how long to wait for the annotation before the node is deleted anyway
+	Timeout string `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+}
+
+// PreDeleteHookOptions configures the webhook called before a node of the node group is deleted
+type PreDeleteHookOptions struct {
+	// URL the hook request is POSTed to
+	URL string `json:"url,omitempty" yaml:"url,omitempty"`
+	// Timeout of a single hook request
+	Timeout string `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	// FailurePolicy is either fail_open or fail_closed, for whether the node is deleted when the hook can't be reached
+	FailurePolicy string `json:"failure_policy,omitempty" yaml:"failure_policy,omitempty"`
+}
+
+// ProtectedPodsOptions configures the pods that are never deleted along with their node by the hard delete grace period
+type ProtectedPodsOptions struct {
+	// Namespaces whose pods are protected
+	Namespaces []string `json:"namespaces,omitempty" yaml:"namespaces,omitempty"`
+	// PriorityClasses whose pods are protected, by the name of the PriorityClass
+	PriorityClasses []string `json:"priority_classes,omitempty" yaml:"priority_classes,omitempty"`
+}
+
+// EvictionLimitsOptions limits the pods evicted by the deletion of tainted nodes that still run pods, i.e. past their
+// hard delete grace period
+type EvictionLimitsOptions struct {
+	// MaxPods is the maximum number of pods evicted by the deletions of a single run
+	MaxPods int `json:"max_pods,omitempty" yaml:"max_pods,omitempty"`
+	// NodeInterval is the minimum time between the deletions of nodes that still run pods
+	NodeInterval string `json:"node_interval,omitempty" yaml:"node_interval,omitempty"`
+}
+
+// VolumeDetachOptions delays and spaces out the deletions of tainted nodes by the volumes attached to them
+type VolumeDetachOptions struct {
+	// DelayPerVolume extends the soft and hard delete grace periods of a tainted node for each volume attached to it
+	DelayPerVolume string `json:"delay_per_volume,omitempty" yaml:"delay_per_volume,omitempty"`
+	// MaxDelay caps the extension of the grace periods of a node, unlimited when unset
+	MaxDelay string `json:"max_delay,omitempty" yaml:"max_delay,omitempty"`
+	// HeavyNodeVolumes is the number of attached volumes that makes a node volume heavy
+	HeavyNodeVolumes int `json:"heavy_node_volumes,omitempty" yaml:"heavy_node_volumes,omitempty"`
+	// HeavyNodeInterval is the minimum time between the deletions of volume heavy nodes
+	HeavyNodeInterval string `json:"heavy_node_interval,omitempty" yaml:"heavy_node_interval,omitempty"`
+}
+
+// DisruptionScoreOptions weighs the disruption of moving the pods of a node, for choosing the nodes to taint. A weight of
+// 0 is the default weight of 1
+type DisruptionScoreOptions struct {
+	// Enabled taints the nodes with the lowest disruption score first
+	Enabled bool `json:"enabled,omitempty" yaml:"enabled,omitempty"`
+	// PodWeight is the score of each pod
+	PodWeight float64 `json:"pod_weight,omitempty" yaml:"pod_weight,omitempty"`
+	// LocalDataWeight is the score of each GiB of local data of a pod
+	LocalDataWeight float64 `json:"local_data_weight,omitempty" yaml:"local_data_weight,omitempty"`
+	// JobWeight is the score of each hour a pod of a Job has been running
+	JobWeight float64 `json:"job_weight,omitempty" yaml:"job_weight,omitempty"`
+}
+
+// ScaleUpStep increases the scale up delta once the utilisation of the node group reaches UtilisationPercent.
+// The step adds either Percent of the current untainted nodes or an absolute Count of nodes
+type ScaleUpStep struct {
+	UtilisationPercent float64 `json:"utilisation_percent,omitempty" yaml:"utilisation_percent,omitempty"`
+	Percent            int     `json:"percent,omitempty" yaml:"percent,omitempty"`
+	Count              int     `json:"count,omitempty" yaml:"count,omitempty"`
+}
+
+// ScaleUpRate scales the node group up once its utilisation rose by IncreasePercent over the last Cycles runs, even
+// though it is still under the scale up threshold
+type ScaleUpRate struct {
+	IncreasePercent float64 `json:"increase_percent,omitempty" yaml:"increase_percent,omitempty"`
+	Cycles          int     `json:"cycles,omitempty" yaml:"cycles,omitempty"`
+}
+
+// ResourceProfile is a set of pods in the node group, selected by a pod label, whose utilisation is scaled on against its
+// own scale up threshold
+type ResourceProfile struct {
+	Name                    string `json:"name,omitempty" yaml:"name,omitempty"`
+	LabelKey                string `json:"label_key,omitempty" yaml:"label_key,omitempty"`
+	LabelValue              string `json:"label_value,omitempty" yaml:"label_value,omitempty"`
+	ScaleUpThresholdPercent int    `json:"scale_up_threshold_percent,omitempty" yaml:"scale_up_threshold_percent,omitempty"`
+}
+
 // UnmarshalNodeGroupOptions decodes the yaml or json reader into a struct
 func UnmarshalNodeGroupOptions(reader io.Reader) ([]NodeGroupOptions, error) {
-	var wrapper struct {
-		NodeGroups []NodeGroupOptions `json:"node_groups" yaml:"node_groups"`
-	}
-	if err := yaml.NewYAMLOrJSONDecoder(reader, 4096).Decode(&wrapper); err != nil {
+	config, err := UnmarshalConfig(reader)
+	if err != nil {
 		return []NodeGroupOptions{}, err
 	}
-	return wrapper.NodeGroups, nil
+	return config.NodeGroups, nil
 }
 
 // ValidateNodeGroup is a safety check to validate that a nodegroup has valid options
@@ -78,14 +397,22 @@ func ValidateNodeGroup(nodegroup NodeGroupOptions) []error {
 
 	checkThat := func(cond bool, format string, output ...interface{}) {
 		if !cond {
-			problems = append(problems, fmt.Errorf(format, output...))
+			problems = append(problems, errorkind.New(errorkind.Validation, format, output...))
 		}
 	}
 
 	checkThat(len(nodegroup.Name) > 0, "name cannot be empty")
 	checkThat(len(nodegroup.LabelKey) > 0, "label_key cannot be empty")
+	checkThat(k8s.InNodeLabelDomain(nodegroup.LabelKey), "label_key must be in the node label domain %v", k8s.NodeLabelDomain())
 	checkThat(len(nodegroup.LabelValue) > 0, "label_value cannot be empty")
-	checkThat(len(nodegroup.CloudProviderGroupName) > 0, "cloud_provider_group_name cannot be empty")
+	checkThat(len(nodegroup.CloudProviderGroupName) > 0 || len(nodegroup.CloudProviderGroupNames) > 0, "cloud_provider_group_name cannot be empty")
+	checkThat(len(nodegroup.CloudProviderGroupName) == 0 || len(nodegroup.CloudProviderGroupNames) == 0, "cloud_provider_group_name and cloud_provider_group_names must not both be set")
+	groupNames := make(map[string]bool, len(nodegroup.CloudProviderGroupNames))
+	for i, name := range nodegroup.CloudProviderGroupNames {
+		checkThat(len(name) > 0, "cloud_provider_group_names[%d] must not be empty", i)
+		checkThat(!groupNames[name], "cloud_provider_group_names[%d] %v is not unique", i, name)
+		groupNames[name] = true
+	}
 
 	checkThat(nodegroup.TaintUpperCapacityThresholdPercent > 0, "taint_upper_capacity_threshold_percent must be larger than 0")
 	checkThat(nodegroup.TaintLowerCapacityThresholdPercent > 0, "taint_lower_capacity_threshold_percent must be larger than 0")
@@ -103,7 +430,28 @@ func ValidateNodeGroup(nodegroup NodeGroupOptions) []error {
 		checkThat(nodegroup.MinNodes >= 0, "min_nodes must be not less than 0")
 	}
 
+	profileNames := make(map[string]bool, len(nodegroup.ResourceProfiles))
+	for i, profile := range nodegroup.ResourceProfiles {
+		checkThat(len(profile.Name) > 0, "resource_profiles[%d].name must not be empty", i)
+		checkThat(!profileNames[profile.Name], "resource_profiles[%d].name %v is not unique", i, profile.Name)
+		checkThat(len(profile.LabelKey) > 0, "resource_profiles[%d].label_key must not be empty", i)
+		checkThat(profile.ScaleUpThresholdPercent > 0, "resource_profiles[%d].scale_up_threshold_percent must be larger than 0", i)
+		profileNames[profile.Name] = true
+	}
+
+	for i, step := range nodegroup.ScaleUpSteps {
+		checkThat(step.UtilisationPercent > 0, "scale_up_steps[%d].utilisation_percent must be larger than 0", i)
+		checkThat((step.Percent > 0) != (step.Count > 0), "scale_up_steps[%d] must set exactly one of percent or count", i)
+		checkThat(step.Percent >= 0 && step.Count >= 0, "scale_up_steps[%d] percent and count must not be negative", i)
+	}
+
+	checkThat(nodegroup.ScaleUpRate.IncreasePercent >= 0, "scale_up_rate.increase_percent must not be negative")
+	checkThat(nodegroup.ScaleUpRate.Cycles >= 0 && nodegroup.ScaleUpRate.Cycles <= maxScaleUpRateCycles, "scale_up_rate.cycles must be between 0 and %d", maxScaleUpRateCycles)
+	checkThat(nodegroup.ScaleUpRate.Cycles == 0 || nodegroup.ScaleUpRate.IncreasePercent > 0, "scale_up_rate.cycles requires scale_up_rate.increase_percent")
+
 	checkThat(nodegroup.SlowNodeRemovalRate <= nodegroup.FastNodeRemovalRate, "slow_node_removal_rate must be less than fast_node_removal_rate")
+	checkThat(nodegroup.ScaleUpCount >= 0, "scale_up_count must not be less than 0")
+	checkThat(nodegroup.ScaleDownCount >= 0, "scale_down_count must not be less than 0")
 
 	checkThat(len(nodegroup.SoftDeleteGracePeriod) > 0, "soft_delete_grace_period must not be empty")
 	checkThat(len(nodegroup.HardDeleteGracePeriod) > 0, "hard_delete_grace_period must not be empty")
@@ -111,19 +459,269 @@ func ValidateNodeGroup(nodegroup NodeGroupOptions) []error {
 	checkThat(nodegroup.SoftDeleteGracePeriodDuration() > 0, "soft_delete_grace_period failed to parse into a time.Duration. check your formatting.")
 	checkThat(nodegroup.HardDeleteGracePeriodDuration() > 0, "hard_delete_grace_period failed to parse into a time.Duration. check your formatting.")
 	checkThat(nodegroup.SoftDeleteGracePeriodDuration() < nodegroup.HardDeleteGracePeriodDuration(), "soft_delete_grace_period must be less than hard_delete_grace_period")
+	checkThat(len(nodegroup.SoftDeleteGracePeriodFrom) == 0 || nodegroup.SoftDeleteGracePeriodFrom == SoftDeleteGracePeriodFromTaint || nodegroup.SoftDeleteGracePeriodFrom == SoftDeleteGracePeriodFromEmpty,
+		"soft_delete_grace_period_from must be either %v or %v", SoftDeleteGracePeriodFromTaint, SoftDeleteGracePeriodFromEmpty)
 
 	checkThat(len(nodegroup.ScaleUpCoolDownPeriod) > 0, "scale_up_cool_down_period must not be empty")
 	checkThat(nodegroup.ScaleUpCoolDownPeriodDuration() > 0, "soft_delete_grace_period failed to parse into a time.Duration. check your formatting.")
 
 	checkThat(validTaintEffect(nodegroup.TaintEffect), "taint_effect must be valid kubernetes taint")
+	checkThat(validTaintEffect(nodegroup.SpotInterruption.TaintEffect), "spot_interruption.taint_effect must be valid kubernetes taint")
+	checkThat(validScaleDownMode(nodegroup.ScaleDownMode), "scale_down_mode must be either %v, %v or %v", ScaleDownModeTaint, ScaleDownModeCordon, ScaleDownModeBoth)
+	checkThat(len(nodegroup.StaticPodNodes) == 0 || nodegroup.StaticPodNodes == StaticPodNodesProtect || nodegroup.StaticPodNodes == StaticPodNodesIgnore,
+		"static_pod_nodes must be either %v or %v", StaticPodNodesProtect, StaticPodNodesIgnore)
+	checkThat(validOS(nodegroup.OS), "os must be either %v or %v", k8s.OSLinux, k8s.OSWindows)
+	// nodes are only deleted once they were tainted for real, so a simulated taint with a real delete never deletes
+	checkThat(nodegroup.DryMode || !nodegroup.DryTaint || nodegroup.DryDelete, "dry_taint requires dry_delete, nodes are only deleted after they were tainted for real")
+
+	if len(nodegroup.MaxNodeAge) > 0 {
+		checkThat(nodegroup.MaxNodeAgeDuration() > 0, "max_node_age failed to parse into a time.Duration. check your formatting.")
+	}
+	checkThat(validRecycleMode(nodegroup.RecycleMode), "recycle_mode must be either %v or %v", RecycleModeTaint, RecycleModeProvisionThenTaint)
+
+	for _, dependent := range nodegroup.ScaleDownAfter {
+		checkThat(dependent != nodegroup.Name, "scale_down_after must not contain the node group itself")
+	}
+	checkThat(nodegroup.ScaleDownAfterThresholdPercent >= 0, "scale_down_after_threshold_percent must not be less than 0")
+	checkThat(len(nodegroup.PoolScalePolicy) == 0 || nodegroup.PoolScalePolicy == PoolScalePolicyBalanced || nodegroup.PoolScalePolicy == PoolScalePolicyPriority ||
+		nodegroup.PoolScalePolicy == PoolScalePolicyProportional,
+		"pool_scale_policy must be one of %v, %v or %v", PoolScalePolicyBalanced, PoolScalePolicyPriority, PoolScalePolicyProportional)
+	checkThat(nodegroup.QuotaPriority >= 0, "quota_priority must not be less than 0")
+
+	checkThat(validQueueDemandProvider(nodegroup.QueueDemand.Provider), "queue_demand.provider must be either %v or %v", QueueDemandProviderKueue, QueueDemandProviderVolcano)
+	checkThat(len(nodegroup.QueueDemand.Provider) == 0 || len(nodegroup.QueueDemand.Queue) > 0, "queue_demand.queue must not be empty when queue_demand.provider is set")
+
+	if nodegroup.HighWaterMarkHold.Percent != 0 {
+		hold := nodegroup.HighWaterMarkHold
+		checkThat(hold.Percent > 0 && hold.Percent <= 100, "high_water_mark_hold.percent must be between 1 and 100")
+		checkThat(hold.ThresholdNodes > 0, "high_water_mark_hold.threshold_nodes must be larger than 0")
+		checkThat(nodegroup.HighWaterMarkHoldDuration() > 0, "high_water_mark_hold.duration failed to parse into a time.Duration. check your formatting.")
+	}
+
+	problems = append(problems, validateReservations(nodegroup.Reservations)...)
+
+	for _, daemonSet := range nodegroup.DaemonSetDrain.DaemonSets {
+		checkThat(validNamespacedName(daemonSet), "daemonset_drain.daemonsets must be in the form namespace/name: %v", daemonSet)
+	}
+	checkThat(len(nodegroup.DaemonSetDrain.DaemonSets) == 0 || len(nodegroup.DaemonSetDrain.CompletedAnnotation) > 0, "daemonset_drain.completed_annotation must not be empty when daemonset_drain.daemonsets is set")
+	if len(nodegroup.DaemonSetDrain.Timeout) > 0 {
+		checkThat(nodegroup.DaemonSetDrainTimeoutDuration() > 0, "daemonset_drain.timeout failed to parse into a time.Duration. check your formatting.")
+	}
+
+	if len(nodegroup.PreDeleteHook.URL) > 0 {
+		hook := nodegroup.PreDeleteHook
+		hookURL, err := url.Parse(hook.URL)
+		checkThat(err == nil && (hookURL.Scheme == "http" || hookURL.Scheme == "https") && len(hookURL.Host) > 0, "pre_delete_hook.url must be an http or https URL: %v", hook.URL)
+		checkThat(len(hook.FailurePolicy) == 0 || hook.FailurePolicy == PreDeleteHookFailOpen || hook.FailurePolicy == PreDeleteHookFailClosed,
+			"pre_delete_hook.failure_policy must be either %v or %v", PreDeleteHookFailOpen, PreDeleteHookFailClosed)
+		if len(hook.Timeout) > 0 {
+			checkThat(nodegroup.PreDeleteHookTimeoutDuration() > 0, "pre_delete_hook.timeout failed to parse into a time.Duration. check your formatting.")
+		}
+	}
+
+	checkThat(nodegroup.EvictionLimits.MaxPods >= 0, "eviction_limits.max_pods must not be negative")
+	if len(nodegroup.EvictionLimits.NodeInterval) > 0 {
+		checkThat(nodegroup.EvictionNodeIntervalDuration() > 0, "eviction_limits.node_interval failed to parse into a time.Duration. check your formatting.")
+	}
+
+	volumeDetach := nodegroup.VolumeDetach
+	if len(volumeDetach.DelayPerVolume) > 0 {
+		checkThat(nodegroup.VolumeDelayPerVolumeDuration() > 0, "volume_detach.delay_per_volume failed to parse into a time.Duration. check your formatting.")
+	}
+	if len(volumeDetach.MaxDelay) > 0 {
+		checkThat(nodegroup.VolumeMaxDelayDuration() > 0, "volume_detach.max_delay failed to parse into a time.Duration. check your formatting.")
+		checkThat(len(volumeDetach.DelayPerVolume) > 0, "volume_detach.max_delay requires volume_detach.delay_per_volume")
+	}
+	checkThat(volumeDetach.HeavyNodeVolumes >= 0, "volume_detach.heavy_node_volumes must not be negative")
+	if len(volumeDetach.HeavyNodeInterval) > 0 {
+		checkThat(nodegroup.VolumeHeavyNodeIntervalDuration() > 0, "volume_detach.heavy_node_interval failed to parse into a time.Duration. check your formatting.")
+	}
+	checkThat((volumeDetach.HeavyNodeVolumes > 0) == (len(volumeDetach.HeavyNodeInterval) > 0),
+		"volume_detach.heavy_node_volumes and volume_detach.heavy_node_interval must be set together")
+	if len(nodegroup.PodDisruptionBudgetMaxDeferral) > 0 {
+		checkThat(nodegroup.PodDisruptionBudgetMaxDeferralDuration() > 0, "pod_disruption_budget_max_deferral failed to parse into a time.Duration. check your formatting.")
+	}
+	if len(nodegroup.RescheduleMaxDeferral) > 0 {
+		checkThat(nodegroup.RescheduleMaxDeferralDuration() > 0, "reschedule_max_deferral failed to parse into a time.Duration. check your formatting.")
+	}
+
+	checkThat(nodegroup.DisruptionScore.PodWeight >= 0, "disruption_score.pod_weight must not be negative")
+	checkThat(nodegroup.DisruptionScore.LocalDataWeight >= 0, "disruption_score.local_data_weight must not be negative")
+	checkThat(nodegroup.DisruptionScore.JobWeight >= 0, "disruption_score.job_weight must not be negative")
+	checkThat(validTaintSelectionPolicy(nodegroup.TaintSelectionPolicy), "taint_selection_policy must be one of %v", strings.Join(TaintSelectionPolicies, ", "))
+	checkThat(!nodegroup.DisruptionScore.Enabled || len(nodegroup.TaintSelectionPolicy) == 0 || nodegroup.TaintSelectionPolicy == TaintSelectionPolicyDisruptionScore,
+		"disruption_score.enabled can't be used with the %v taint_selection_policy", nodegroup.TaintSelectionPolicy)
+
+	for _, namespace := range nodegroup.ProtectedPods.Namespaces {
+		checkThat(len(namespace) > 0, "protected_pods.namespaces must not contain an empty namespace")
+	}
+	for _, priorityClass := range nodegroup.ProtectedPods.PriorityClasses {
+		checkThat(len(priorityClass) > 0, "protected_pods.priority_classes must not contain an empty priority class")
+	}
+
+	for _, key := range nodegroup.StartupTaints {
+		checkThat(len(key) > 0, "startup_taints must not contain an empty taint key")
+		checkThat(key != k8s.ToBeRemovedByAutoscalerKey, "startup_taints must not contain the escalator taint %v", k8s.ToBeRemovedByAutoscalerKey)
+	}
+
+	checkThat(nodegroup.VersionSkew.MaxNodesPerRun >= 0, "version_skew.max_nodes_per_run must not be negative")
+
+	for _, key := range nodegroup.ThirdPartyTaints.Keys {
+		checkThat(len(key) > 0, "third_party_taints.keys must not contain an empty taint key")
+		checkThat(key != k8s.ToBeRemovedByAutoscalerKey, "third_party_taints.keys must not contain the escalator taint %v", k8s.ToBeRemovedByAutoscalerKey)
+	}
+	checkThat(validThirdPartyTaintsPolicy(nodegroup.ThirdPartyTaints.Policy), "third_party_taints.policy must be either %v, %v or %v",
+		ThirdPartyTaintsCapacity, ThirdPartyTaintsExclude, ThirdPartyTaintsPrioritiseRemoval)
+	checkThat(nodegroup.ThirdPartyTaints.policy() == ThirdPartyTaintsCapacity || len(nodegroup.ThirdPartyTaints.Keys) > 0,
+		"third_party_taints.keys must be set with third_party_taints.policy %v", nodegroup.ThirdPartyTaints.Policy)
+
+	if len(nodegroup.NewNodeGracePeriod) > 0 {
+		checkThat(nodegroup.NewNodeGracePeriodDuration() > 0, "new_node_grace_period failed to parse into a time.Duration. check your formatting.")
+	}
+	if len(nodegroup.FollowUpTimeout) > 0 {
+		checkThat(nodegroup.FollowUpTimeoutDuration() > 0, "follow_up_timeout failed to parse into a time.Duration. check your formatting.")
+	}
+
+	checkThat(len(nodegroup.AWS.EKSClusterName) > 0 == (len(nodegroup.AWS.EKSNodegroupName) > 0),
+		"aws.eks_cluster_name and aws.eks_nodegroup_name must be set together")
+	if len(nodegroup.AWS.EKSNodegroupName) > 0 {
+		checkThat(len(nodegroup.AWS.LaunchTemplateID) == 0, "aws.launch_template_id can't be used with an EKS managed node group")
+		// an EKS managed node group is backed by a single auto scaling group
+		checkThat(len(nodegroup.CloudProviderGroupNames) == 0, "aws.eks_nodegroup_name can't be used with cloud_provider_group_names")
+	}
 	return problems
 }
 
+// ValidateNodeGroupDependencies validates the scale_down_after dependencies between the nodegroups.
+// Every dependency must be a configured node group and the dependencies must not form a cycle
+func ValidateNodeGroupDependencies(nodegroups []NodeGroupOptions) []error {
+	var problems []error
+
+	dependencies := make(map[string][]string, len(nodegroups))
+	for _, nodegroup := range nodegroups {
+		dependencies[nodegroup.Name] = nodegroup.ScaleDownAfter
+	}
+	for _, nodegroup := range nodegroups {
+		for _, dependent := range nodegroup.ScaleDownAfter {
+			if _, ok := dependencies[dependent]; !ok {
+				problems = append(problems, errorkind.New(errorkind.Validation, "nodegroup %v: scale_down_after node group %v does not exist", nodegroup.Name, dependent))
+			}
+		}
+	}
+
+	// depth first search for cycles. a node group is visiting while its dependencies are being searched
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[string]int, len(nodegroups))
+	var visit func(name string) bool
+	visit = func(name string) bool {
+		switch state[name] {
+		case visiting:
+			return false
+		case visited:
+			return true
+		}
+		state[name] = visiting
+		for _, dependent := range dependencies[name] {
+			if !visit(dependent) {
+				return false
+			}
+		}
+		state[name] = visited
+		return true
+	}
+	for _, nodegroup := range nodegroups {
+		if !visit(nodegroup.Name) {
+			problems = append(problems, errorkind.New(errorkind.Validation, "nodegroup %v: scale_down_after dependencies must not form a cycle", nodegroup.Name))
+			break
+		}
+	}
+	return problems
+}
+
+// ValidateNodeGroupCloudProviders validates the options of the node groups that depend on their cloud provider, either
+// their cloud_provider or the default cloud provider. The gce and azure cloud providers take the min and max sizes of
+// their node groups from min_nodes and max_nodes, as managed instance groups and scale sets don't have any, so they
+// can't be auto discovered
+func ValidateNodeGroupCloudProviders(nodegroups []NodeGroupOptions, defaultProvider string) []error {
+	var problems []error
+	for _, nodegroup := range nodegroups {
+		provider := nodegroup.CloudProvider
+		if len(provider) == 0 {
+			provider = defaultProvider
+		}
+		if (provider == "gce" || provider == "azure") && nodegroup.autoDiscoverMinMaxNodeOptions() {
+			problems = append(problems, errorkind.New(errorkind.Validation, "nodegroup %v: min_nodes and max_nodes must be set, the %v cloud provider can't auto discover them", nodegroup.Name, provider))
+		}
+	}
+	return problems
+}
+
+// ValidateNodeGroupPools validates that the node groups in each pool agree on the options evaluated for the whole pool
+func ValidateNodeGroupPools(nodegroups []NodeGroupOptions) []error {
+	var problems []error
+
+	first := make(map[string]NodeGroupOptions)
+	for _, nodegroup := range nodegroups {
+		if len(nodegroup.Pool) == 0 {
+			continue
+		}
+		pooled, ok := first[nodegroup.Pool]
+		if !ok {
+			first[nodegroup.Pool] = nodegroup
+			continue
+		}
+		checkThat := func(cond bool, option string) {
+			if !cond {
+				problems = append(problems, errorkind.New(errorkind.Validation, "nodegroup %v: %v must be the same as node group %v in pool %v", nodegroup.Name, option, pooled.Name, nodegroup.Pool))
+			}
+		}
+		checkThat(nodegroup.TaintUpperCapacityThresholdPercent == pooled.TaintUpperCapacityThresholdPercent, "taint_upper_capacity_threshold_percent")
+		checkThat(nodegroup.TaintLowerCapacityThresholdPercent == pooled.TaintLowerCapacityThresholdPercent, "taint_lower_capacity_threshold_percent")
+		checkThat(nodegroup.ScaleUpThresholdPercent == pooled.ScaleUpThresholdPercent, "scale_up_threshold_percent")
+		checkThat(nodegroup.poolScalePolicy() == pooled.poolScalePolicy(), "pool_scale_policy")
+	}
+	return problems
+}
+
+// Empty String is valid value for OS and includes nodes of any operating system
+func validOS(os string) bool {
+	return len(os) == 0 || os == k8s.OSLinux || os == k8s.OSWindows
+}
+
+// Empty String is valid value for RecycleMode and defaults to RecycleModeTaint
+func validRecycleMode(recycleMode string) bool {
+	return len(recycleMode) == 0 || recycleMode == RecycleModeTaint || recycleMode == RecycleModeProvisionThenTaint
+}
+
+// Empty String is valid value for the queue demand provider and disables reading demand from batch job queues
+// validNamespacedName returns whether the name is in the form namespace/name
+func validNamespacedName(name string) bool {
+	parts := strings.Split(name, "/")
+	return len(parts) == 2 && len(parts[0]) > 0 && len(parts[1]) > 0
+}
+
+func validQueueDemandProvider(provider string) bool {
+	return len(provider) == 0 || provider == QueueDemandProviderKueue || provider == QueueDemandProviderVolcano
+}
+
+// Empty String is valid value for the third party taints policy and defaults to ThirdPartyTaintsCapacity
+func validThirdPartyTaintsPolicy(policy string) bool {
+	return len(policy) == 0 || policy == ThirdPartyTaintsCapacity || policy == ThirdPartyTaintsExclude || policy == ThirdPartyTaintsPrioritiseRemoval
+}
+
 // Empty String is valid value for TaintEffect as AddToBeRemovedTaint method will default to NoSchedule
 func validTaintEffect(taintEffect v1.TaintEffect) bool {
 	return len(taintEffect) == 0 || k8s.TaintEffectTypes[taintEffect]
 }
 
+// Empty String is valid value for ScaleDownMode as it defaults to taint
+func validScaleDownMode(mode string) bool {
+	return len(mode) == 0 || mode == ScaleDownModeTaint || mode == ScaleDownModeCordon || mode == ScaleDownModeBoth
+}
+
 // SoftDeleteGracePeriodDuration lazily returns/parses the softDeleteGracePeriod string into a duration
 func (n *NodeGroupOptions) SoftDeleteGracePeriodDuration() time.Duration {
 	if n.softDeleteGracePeriodDuration == 0 {
@@ -163,11 +761,271 @@ func (n *NodeGroupOptions) ScaleUpCoolDownPeriodDuration() time.Duration {
 	return n.scaleUpCoolDownPeriodDuration
 }
 
+// MaxNodeAgeDuration lazily returns/parses the maxNodeAge string into a duration
+// returns 0 when node recycling is disabled
+func (n *NodeGroupOptions) MaxNodeAgeDuration() time.Duration {
+	if n.maxNodeAgeDuration == 0 && len(n.MaxNodeAge) > 0 {
+		duration, err := time.ParseDuration(n.MaxNodeAge)
+		if err != nil {
+			return 0
+		}
+		n.maxNodeAgeDuration = duration
+	}
+
+	return n.maxNodeAgeDuration
+}
+
+// StartupTaintKeys returns the taint keys that mark a new node as still starting up
+func (n *NodeGroupOptions) StartupTaintKeys() []string {
+	if n.StartupTaints == nil {
+		return k8s.DefaultStartupTaintKeys
+	}
+	return n.StartupTaints
+}
+
+// NewNodeGracePeriodDuration lazily returns/parses the newNodeGracePeriod string into a duration
+// returns 0 when new nodes that aren't Ready count as capacity straight away
+func (n *NodeGroupOptions) NewNodeGracePeriodDuration() time.Duration {
+	if n.newNodeGracePeriodDuration == 0 && len(n.NewNodeGracePeriod) > 0 {
+		duration, err := time.ParseDuration(n.NewNodeGracePeriod)
+		if err != nil {
+			return 0
+		}
+		n.newNodeGracePeriodDuration = duration
+	}
+
+	return n.newNodeGracePeriodDuration
+}
+
+// FollowUpTimeoutDuration lazily returns/parses the followUpTimeout string into a duration
+// returns 0 when follow up runs are disabled
+func (n *NodeGroupOptions) FollowUpTimeoutDuration() time.Duration {
+	if n.followUpTimeoutDuration == 0 && len(n.FollowUpTimeout) > 0 {
+		duration, err := time.ParseDuration(n.FollowUpTimeout)
+		if err != nil {
+			return 0
+		}
+		n.followUpTimeoutDuration = duration
+	}
+
+	return n.followUpTimeoutDuration
+}
+
+// HighWaterMarkHoldDuration lazily returns/parses the highWaterMarkHold.duration string into a duration
+// returns 0 when the minimum of the node group isn't held
+func (n *NodeGroupOptions) HighWaterMarkHoldDuration() time.Duration {
+	if n.highWaterMarkHoldDuration == 0 && len(n.HighWaterMarkHold.Duration) > 0 {
+		duration, err := time.ParseDuration(n.HighWaterMarkHold.Duration)
+		if err != nil {
+			return 0
+		}
+		n.highWaterMarkHoldDuration = duration
+	}
+
+	return n.highWaterMarkHoldDuration
+}
+
+// DaemonSetDrainTimeoutDuration lazily returns/parses the daemonSetDrain.timeout string into a duration
+// returns defaultDaemonSetDrainTimeout when it isn't set
+func (n *NodeGroupOptions) DaemonSetDrainTimeoutDuration() time.Duration {
+	if len(n.DaemonSetDrain.Timeout) == 0 {
+		return defaultDaemonSetDrainTimeout
+	}
+	if n.daemonSetDrainTimeoutDuration == 0 {
+		duration, err := time.ParseDuration(n.DaemonSetDrain.Timeout)
+		if err != nil {
+			return 0
+		}
+		n.daemonSetDrainTimeoutDuration = duration
+	}
+
+	return n.daemonSetDrainTimeoutDuration
+}
+
+// EvictionNodeIntervalDuration lazily returns/parses the evictionLimits.nodeInterval string into a duration
+// returns 0 when it isn't set
+func (n *NodeGroupOptions) EvictionNodeIntervalDuration() time.Duration {
+	if len(n.EvictionLimits.NodeInterval) == 0 {
+		return 0
+	}
+	if n.evictionNodeIntervalDuration == 0 {
+		duration, err := time.ParseDuration(n.EvictionLimits.NodeInterval)
+		if err != nil {
+			return 0
+		}
+		n.evictionNodeIntervalDuration = duration
+	}
+
+	return n.evictionNodeIntervalDuration
+}
+
+// VolumeDelayPerVolumeDuration lazily returns/parses the volumeDetach.delayPerVolume string into a duration
+// returns 0 when it isn't set
+func (n *NodeGroupOptions) VolumeDelayPerVolumeDuration() time.Duration {
+	if len(n.VolumeDetach.DelayPerVolume) == 0 {
+		return 0
+	}
+	if n.volumeDelayPerVolumeDuration == 0 {
+		duration, err := time.ParseDuration(n.VolumeDetach.DelayPerVolume)
+		if err != nil {
+			return 0
+		}
+		n.volumeDelayPerVolumeDuration = duration
+	}
+
+	return n.volumeDelayPerVolumeDuration
+}
+
+// VolumeMaxDelayDuration lazily returns/parses the volumeDetach.maxDelay string into a duration
+// returns 0 when it isn't set
+func (n *NodeGroupOptions) VolumeMaxDelayDuration() time.Duration {
+	if len(n.VolumeDetach.MaxDelay) == 0 {
+		return 0
+	}
+	if n.volumeMaxDelayDuration == 0 {
+		duration, err := time.ParseDuration(n.VolumeDetach.MaxDelay)
+		if err != nil {
+			return 0
+		}
+		n.volumeMaxDelayDuration = duration
+	}
+
+	return n.volumeMaxDelayDuration
+}
+
+// VolumeHeavyNodeIntervalDuration lazily returns/parses the volumeDetach.heavyNodeInterval string into a duration
+// returns 0 when it isn't set
+func (n *NodeGroupOptions) VolumeHeavyNodeIntervalDuration() time.Duration {
+	if len(n.VolumeDetach.HeavyNodeInterval) == 0 {
+		return 0
+	}
+	if n.heavyNodeIntervalDuration == 0 {
+		duration, err := time.ParseDuration(n.VolumeDetach.HeavyNodeInterval)
+		if err != nil {
+			return 0
+		}
+		n.heavyNodeIntervalDuration = duration
+	}
+
+	return n.heavyNodeIntervalDuration
+}
+
+// PodDisruptionBudgetMaxDeferralDuration lazily returns/parses the podDisruptionBudgetMaxDeferral string into a duration
+// returns defaultPodDisruptionBudgetMaxDeferral when it isn't set
+func (n *NodeGroupOptions) PodDisruptionBudgetMaxDeferralDuration() time.Duration {
+	if len(n.PodDisruptionBudgetMaxDeferral) == 0 {
+		return defaultPodDisruptionBudgetMaxDeferral
+	}
+	if n.pdbMaxDeferralDuration == 0 {
+		duration, err := time.ParseDuration(n.PodDisruptionBudgetMaxDeferral)
+		if err != nil {
+			return 0
+		}
+		n.pdbMaxDeferralDuration = duration
+	}
+
+	return n.pdbMaxDeferralDuration
+}
+
+// RescheduleMaxDeferralDuration lazily returns/parses the rescheduleMaxDeferral string into a duration
+// returns defaultRescheduleMaxDeferral when it isn't set
+func (n *NodeGroupOptions) RescheduleMaxDeferralDuration() time.Duration {
+	if len(n.RescheduleMaxDeferral) == 0 {
+		return defaultRescheduleMaxDeferral
+	}
+	if n.rescheduleMaxDeferralDuration == 0 {
+		duration, err := time.ParseDuration(n.RescheduleMaxDeferral)
+		if err != nil {
+			return 0
+		}
+		n.rescheduleMaxDeferralDuration = duration
+	}
+
+	return n.rescheduleMaxDeferralDuration
+}
+
+// PreDeleteHookTimeoutDuration lazily returns/parses the preDeleteHook.timeout string into a duration
+// returns defaultPreDeleteHookTimeout when it isn't set
+func (n *NodeGroupOptions) PreDeleteHookTimeoutDuration() time.Duration {
+	if len(n.PreDeleteHook.Timeout) == 0 {
+		return defaultPreDeleteHookTimeout
+	}
+	if n.preDeleteHookTimeoutDuration == 0 {
+		duration, err := time.ParseDuration(n.PreDeleteHook.Timeout)
+		if err != nil {
+			return 0
+		}
+		n.preDeleteHookTimeoutDuration = duration
+	}
+
+	return n.preDeleteHookTimeoutDuration
+}
+
+// scaleUpEnabled returns whether the node group is allowed to grow, which it is unless scale_up_enabled is false
+func (n *NodeGroupOptions) scaleUpEnabled() bool {
+	return n.ScaleUpEnabled == nil || *n.ScaleUpEnabled
+}
+
+// scaleDownEnabled returns whether the node group is allowed to shrink, which it is unless scale_down_enabled is false
+func (n *NodeGroupOptions) scaleDownEnabled() bool {
+	return n.ScaleDownEnabled == nil || *n.ScaleDownEnabled
+}
+
+// scaleDownMarks returns whether the nodes to be removed are tainted and whether they are cordoned for the scale_down_mode
+func (n *NodeGroupOptions) scaleDownMarks() (taint bool, cordon bool) {
+	switch n.ScaleDownMode {
+	case ScaleDownModeCordon:
+		return false, true
+	case ScaleDownModeBoth:
+		return true, true
+	default:
+		return true, false
+	}
+}
+
+// staticPodNodes returns the static_pod_nodes, defaulting to protect
+func (n *NodeGroupOptions) staticPodNodes() string {
+	if len(n.StaticPodNodes) == 0 {
+		return StaticPodNodesProtect
+	}
+	return n.StaticPodNodes
+}
+
+// poolScalePolicy returns the pool_scale_policy, defaulting to balanced
+func (n *NodeGroupOptions) poolScalePolicy() string {
+	if len(n.PoolScalePolicy) == 0 {
+		return PoolScalePolicyBalanced
+	}
+	return n.PoolScalePolicy
+}
+
+// quotaPriority returns the quota_priority, defaulting to 1
+func (n *NodeGroupOptions) quotaPriority() int64 {
+	if n.QuotaPriority == 0 {
+		return 1
+	}
+	return int64(n.QuotaPriority)
+}
+
 // autoDiscoverMinMaxNodeOptions returns whether the min_nodes and max_nodes options should be "auto-discovered" from the cloud provider
 func (n *NodeGroupOptions) autoDiscoverMinMaxNodeOptions() bool {
 	return n.MinNodes == 0 && n.MaxNodes == 0
 }
 
+// CloudProviderGroupIDs returns the names of the node groups in the cloud provider backing the node group
+func (n NodeGroupOptions) CloudProviderGroupIDs() []string {
+	if len(n.CloudProviderGroupNames) > 0 {
+		return n.CloudProviderGroupNames
+	}
+	return []string{n.CloudProviderGroupName}
+}
+
+// cloudProviderGroupID returns the name of the node group in the cloud provider, or the names of the node groups
+// backing it comma separated
+func (n NodeGroupOptions) cloudProviderGroupID() string {
+	return strings.Join(n.CloudProviderGroupIDs(), ",")
+}
+
 // FleetInstanceReadyTimeoutDuration lazily returns/parses the fleetInstanceReadyTimeout string into a duration
 func (n *AWSNodeGroupOptions) FleetInstanceReadyTimeoutDuration() time.Duration {
 	if n.fleetInstanceReadyTimeout == 0 && n.FleetInstanceReadyTimeout != "" {
@@ -248,6 +1106,23 @@ func NewPodDefaultFilterFunc() k8s.PodFilterFunc {
 	}
 }
 
+// NewPodPreBoundFilterFunc wraps the pod filter so pods bound to their node without the default scheduler, e.g. by a
+// custom scheduler, count towards the node group of their node instead of the one their node selector matches.
+// They otherwise wouldn't count towards the utilisation of their node's node group, nor stop the node being empty
+func NewPodPreBoundFilterFunc(nodeLister v1lister.NodeLister, nodeFilter k8s.NodeFilterFunc, filter k8s.PodFilterFunc) k8s.PodFilterFunc {
+	return func(pod *v1.Pod) bool {
+		if !k8s.PodIsPreBound(pod) || k8s.PodIsDaemonSet(pod) || k8s.PodIsStatic(pod) {
+			return filter(pod)
+		}
+		node, err := nodeLister.Get(pod.Spec.NodeName)
+		if err != nil || node == nil {
+			// the node has gone or isn't in the cache yet
+			return filter(pod)
+		}
+		return nodeFilter(node)
+	}
+}
+
 // NewNodeLabelFilterFunc creates a new NodeFilterFunc based on filtering by node labels
 func NewNodeLabelFilterFunc(labelKey, labelValue string) k8s.NodeFilterFunc {
 	return func(node *v1.Node) bool {
@@ -260,19 +1135,80 @@ func NewNodeLabelFilterFunc(labelKey, labelValue string) k8s.NodeFilterFunc {
 	}
 }
 
+// NewNodeOSFilterFunc wraps the node filter to only include nodes running the operating system
+// nodes without an os label are assumed to be linux. An empty os includes nodes of any operating system
+func NewNodeOSFilterFunc(os string, filter k8s.NodeFilterFunc) k8s.NodeFilterFunc {
+	if len(os) == 0 {
+		return filter
+	}
+	return func(node *v1.Node) bool {
+		nodeOS := k8s.NodeOS(node)
+		if len(nodeOS) == 0 {
+			nodeOS = k8s.OSLinux
+		}
+		return nodeOS == os && filter(node)
+	}
+}
+
+// NewPodOSFilterFunc wraps the pod filter to exclude pods that can't be scheduled on the operating system
+// pods that don't select an operating system are left to the filter. An empty os doesn't exclude any pods
+func NewPodOSFilterFunc(os string, filter k8s.PodFilterFunc) k8s.PodFilterFunc {
+	if len(os) == 0 {
+		return filter
+	}
+	return func(pod *v1.Pod) bool {
+		return k8s.PodAllowsOS(pod, os) && filter(pod)
+	}
+}
+
+// NewNodeArchFilterFunc wraps the node filter to only include nodes with the cpu architecture
+// nodes without an arch label are assumed to be amd64. An empty arch includes nodes of any architecture
+func NewNodeArchFilterFunc(arch string, filter k8s.NodeFilterFunc) k8s.NodeFilterFunc {
+	if len(arch) == 0 {
+		return filter
+	}
+	return func(node *v1.Node) bool {
+		nodeArch := k8s.NodeArch(node)
+		if len(nodeArch) == 0 {
+			nodeArch = k8s.ArchAMD64
+		}
+		return nodeArch == arch && filter(node)
+	}
+}
+
+// NewPodArchFilterFunc wraps the pod filter to exclude pods that can't be scheduled on the cpu architecture
+// An empty arch doesn't exclude any pods
+func NewPodArchFilterFunc(arch string, filter k8s.PodFilterFunc) k8s.PodFilterFunc {
+	if len(arch) == 0 {
+		return filter
+	}
+	return func(pod *v1.Pod) bool {
+		return k8s.PodAllowsArch(pod, arch) && filter(pod)
+	}
+}
+
+// newNodeGroupNodeFilterFunc creates the node filter for the node group from its labels and platform
+func newNodeGroupNodeFilterFunc(nodeGroup NodeGroupOptions) k8s.NodeFilterFunc {
+	return NewNodeArchFilterFunc(nodeGroup.Arch, NewNodeOSFilterFunc(nodeGroup.OS, NewNodeLabelFilterFunc(nodeGroup.LabelKey, nodeGroup.LabelValue)))
+}
+
 // NewNodeGroupLister creates a new group from the backing lister and nodegroup filter
 func NewNodeGroupLister(allPodsLister v1lister.PodLister, allNodesLister v1lister.NodeLister, nodeGroup NodeGroupOptions) *NodeGroupLister {
+	podFilter := NewPodArchFilterFunc(nodeGroup.Arch, NewPodOSFilterFunc(nodeGroup.OS, NewPodAffinityFilterFunc(nodeGroup.LabelKey, nodeGroup.LabelValue)))
+	nodeFilter := newNodeGroupNodeFilterFunc(nodeGroup)
 	return &NodeGroupLister{
-		k8s.NewFilteredPodsLister(allPodsLister, NewPodAffinityFilterFunc(nodeGroup.LabelKey, nodeGroup.LabelValue)),
-		k8s.NewFilteredNodesLister(allNodesLister, NewNodeLabelFilterFunc(nodeGroup.LabelKey, nodeGroup.LabelValue)),
+		k8s.NewFilteredPodsLister(allPodsLister, NewPodPreBoundFilterFunc(allNodesLister, nodeFilter, podFilter)),
+		k8s.NewFilteredNodesLister(allNodesLister, nodeFilter),
 	}
 }
 
 // NewDefaultNodeGroupLister creates a new group from the backing lister and nodegroup filter with the default filter
 func NewDefaultNodeGroupLister(allPodsLister v1lister.PodLister, allNodesLister v1lister.NodeLister, nodeGroup NodeGroupOptions) *NodeGroupLister {
+	podFilter := NewPodArchFilterFunc(nodeGroup.Arch, NewPodOSFilterFunc(nodeGroup.OS, NewPodDefaultFilterFunc()))
+	nodeFilter := newNodeGroupNodeFilterFunc(nodeGroup)
 	return &NodeGroupLister{
-		k8s.NewFilteredPodsLister(allPodsLister, NewPodDefaultFilterFunc()),
-		k8s.NewFilteredNodesLister(allNodesLister, NewNodeLabelFilterFunc(nodeGroup.LabelKey, nodeGroup.LabelValue)),
+		k8s.NewFilteredPodsLister(allPodsLister, NewPodPreBoundFilterFunc(allNodesLister, nodeFilter, podFilter)),
+		k8s.NewFilteredNodesLister(allNodesLister, nodeFilter),
 	}
 }
 
@@ -0,0 +1,64 @@
+package controller
+
+import (
+	"github.com/atlassian/escalator/pkg/metrics"
+)
+
+// the states of a node group after its latest run, exported as the escalator_node_group_state state set
+const (
+	// NodeGroupStateOK is a node group that didn't need to scale
+	NodeGroupStateOK = "ok"
+	// NodeGroupStateScalingUp is a node group scaling up, or waiting for the nodes of its last scale up
+	NodeGroupStateScalingUp = "scaling_up"
+	// NodeGroupStateDraining is a node group scaling down, or waiting for its tainted nodes to be deleted
+	NodeGroupStateDraining = "draining"
+	// NodeGroupStateDegraded is a node group whose run failed or was skipped
+	NodeGroupStateDegraded = "degraded"
+	// NodeGroupStatePaused is a node group that escalator doesn't scale, as it is observe only, in a maintenance
+	// window or has both scale up and scale down disabled
+	NodeGroupStatePaused = "paused"
+	// NodeGroupStateQuotaBlocked is a node group whose scale up is blocked by a quota of the cloud provider account
+	NodeGroupStateQuotaBlocked = "quota_blocked"
+)
+
+// NodeGroupStates is all of the node group states, in the order they take precedence in
+var NodeGroupStates = []string{
+	NodeGroupStateDegraded,
+	NodeGroupStateQuotaBlocked,
+	NodeGroupStatePaused,
+	NodeGroupStateScalingUp,
+	NodeGroupStateDraining,
+	NodeGroupStateOK,
+}
+
+// nodeGroupState returns the state of the node group from the summary of its latest run. A run that fits more than
+// one state is in the first of NodeGroupStates, e.g. a failed scale up is degraded
+func nodeGroupState(opts NodeGroupOptions, summary CycleSummary) string {
+	switch {
+	case len(summary.Error) > 0 || summary.Decision == CycleDecisionSkipped:
+		return NodeGroupStateDegraded
+	case summary.Decision == CycleDecisionQuotaBlocked:
+		return NodeGroupStateQuotaBlocked
+	case summary.ObserveOnly || len(summary.MaintenanceWindow) > 0 || (!opts.scaleUpEnabled() && !opts.scaleDownEnabled()):
+		return NodeGroupStatePaused
+	case summary.Decision == CycleDecisionScaleUp || summary.Decision == CycleDecisionScaleToMinimum || summary.Decision == CycleDecisionLocked ||
+		summary.Decision == CycleDecisionSpotInterruption:
+		return NodeGroupStateScalingUp
+	case summary.Decision == CycleDecisionScaleDown || summary.TaintedNodes > 0:
+		return NodeGroupStateDraining
+	default:
+		return NodeGroupStateOK
+	}
+}
+
+// updateNodeGroupState exports the state of the node group as a state set, setting the current state to 1 and every
+// other state to 0 so alerts can match on the state directly
+func updateNodeGroupState(nodeGroup string, state string) {
+	for _, s := range NodeGroupStates {
+		value := 0.0
+		if s == state {
+			value = 1
+		}
+		metrics.NodeGroupState.WithLabelValues(nodeGroup, s).Set(value)
+	}
+}
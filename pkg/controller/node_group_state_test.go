@@ -0,0 +1,37 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNodeGroupState(t *testing.T) {
+	disabled := false
+	tests := []struct {
+		name    string
+		opts    NodeGroupOptions
+		summary CycleSummary
+		want    string
+	}{
+		{"nothing to do", NodeGroupOptions{}, CycleSummary{Decision: CycleDecisionNone}, NodeGroupStateOK},
+		{"scale up", NodeGroupOptions{}, CycleSummary{Decision: CycleDecisionScaleUp}, NodeGroupStateScalingUp},
+		{"scale to minimum", NodeGroupOptions{}, CycleSummary{Decision: CycleDecisionScaleToMinimum}, NodeGroupStateScalingUp},
+		{"locked", NodeGroupOptions{}, CycleSummary{Decision: CycleDecisionLocked}, NodeGroupStateScalingUp},
+		{"scale down", NodeGroupOptions{}, CycleSummary{Decision: CycleDecisionScaleDown}, NodeGroupStateDraining},
+		{"tainted nodes", NodeGroupOptions{}, CycleSummary{Decision: CycleDecisionNone, TaintedNodes: 2}, NodeGroupStateDraining},
+		{"quota blocked", NodeGroupOptions{}, CycleSummary{Decision: CycleDecisionQuotaBlocked}, NodeGroupStateQuotaBlocked},
+		{"skipped", NodeGroupOptions{}, CycleSummary{Decision: CycleDecisionSkipped}, NodeGroupStateDegraded},
+		{"failed scale up", NodeGroupOptions{}, CycleSummary{Decision: CycleDecisionScaleUp, Error: "failed"}, NodeGroupStateDegraded},
+		{"observe only", NodeGroupOptions{}, CycleSummary{Decision: CycleDecisionScaleUp, ObserveOnly: true}, NodeGroupStatePaused},
+		{"maintenance window", NodeGroupOptions{}, CycleSummary{Decision: CycleDecisionNone, MaintenanceWindow: "upgrade", TaintedNodes: 1}, NodeGroupStatePaused},
+		{"scaling disabled", NodeGroupOptions{ScaleUpEnabled: &disabled, ScaleDownEnabled: &disabled}, CycleSummary{Decision: CycleDecisionNone}, NodeGroupStatePaused},
+		{"scale up disabled", NodeGroupOptions{ScaleUpEnabled: &disabled}, CycleSummary{Decision: CycleDecisionScaleDown}, NodeGroupStateDraining},
+		{"paused and quota blocked", NodeGroupOptions{}, CycleSummary{Decision: CycleDecisionQuotaBlocked, ObserveOnly: true}, NodeGroupStateQuotaBlocked},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, nodeGroupState(tt.opts, tt.summary))
+		})
+	}
+}
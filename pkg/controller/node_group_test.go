@@ -5,8 +5,10 @@ import (
 	"testing"
 	"time"
 
+	"github.com/atlassian/escalator/pkg/k8s"
 	"github.com/atlassian/escalator/pkg/test"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	v1 "k8s.io/api/core/v1"
 )
 
@@ -303,6 +305,159 @@ func TestNewNodeLabelFilterFunc(t *testing.T) {
 	}
 }
 
+func TestNewNodeOSFilterFunc(t *testing.T) {
+	labelFilter := NewNodeLabelFilterFunc("customer", "render")
+	windowsNode := test.BuildTestNode(test.NodeOpts{LabelKey: "customer", LabelValue: "render"})
+	windowsNode.Labels[k8s.BetaOSLabelKey] = k8s.OSWindows
+	linuxNode := test.BuildTestNode(test.NodeOpts{LabelKey: "customer", LabelValue: "render"})
+	linuxNode.Labels[k8s.OSLabelKey] = k8s.OSLinux
+	unlabelledNode := test.BuildTestNode(test.NodeOpts{LabelKey: "customer", LabelValue: "render"})
+	otherWindowsNode := test.BuildTestNode(test.NodeOpts{LabelKey: k8s.OSLabelKey, LabelValue: k8s.OSWindows})
+
+	windows := NewNodeOSFilterFunc(k8s.OSWindows, labelFilter)
+	assert.True(t, windows(windowsNode))
+	assert.False(t, windows(linuxNode))
+	assert.False(t, windows(unlabelledNode))
+	assert.False(t, windows(otherWindowsNode))
+
+	linux := NewNodeOSFilterFunc(k8s.OSLinux, labelFilter)
+	assert.False(t, linux(windowsNode))
+	assert.True(t, linux(linuxNode))
+	assert.True(t, linux(unlabelledNode))
+
+	allOS := NewNodeOSFilterFunc("", labelFilter)
+	assert.True(t, allOS(windowsNode))
+	assert.True(t, allOS(linuxNode))
+}
+
+func TestNewPodOSFilterFunc(t *testing.T) {
+	windowsPod := test.BuildTestPod(test.PodOpts{NodeSelectorKey: k8s.OSLabelKey, NodeSelectorValue: k8s.OSWindows})
+	linuxPod := test.BuildTestPod(test.PodOpts{NodeSelectorKey: k8s.OSLabelKey, NodeSelectorValue: k8s.OSLinux})
+	anyPod := test.BuildTestPod(test.PodOpts{})
+	windowsDaemonSet := test.BuildTestPod(test.PodOpts{NodeSelectorKey: k8s.OSLabelKey, NodeSelectorValue: k8s.OSWindows, Owner: "DaemonSet"})
+
+	includeAll := func(pod *v1.Pod) bool { return true }
+	windows := NewPodOSFilterFunc(k8s.OSWindows, includeAll)
+	assert.True(t, windows(windowsPod))
+	assert.False(t, windows(linuxPod))
+	assert.True(t, windows(anyPod))
+
+	// daemonsets are still excluded by the default filter on windows node groups
+	defaultWindows := NewPodOSFilterFunc(k8s.OSWindows, NewPodDefaultFilterFunc())
+	assert.False(t, defaultWindows(windowsDaemonSet))
+	assert.False(t, defaultWindows(linuxPod))
+}
+
+func TestNewPodPreBoundFilterFunc(t *testing.T) {
+	buildeng := test.BuildTestNode(test.NodeOpts{Name: "buildeng-1", LabelKey: "customer", LabelValue: "buildeng"})
+	shared := test.BuildTestNode(test.NodeOpts{Name: "shared-1", LabelKey: "customer", LabelValue: "shared"})
+	nodeLister := test.NewTestNodeWatcher([]*v1.Node{buildeng, shared}, test.NodeListerOptions{})
+	filter := NewPodPreBoundFilterFunc(nodeLister, NewNodeLabelFilterFunc("customer", "buildeng"), NewPodAffinityFilterFunc("customer", "buildeng"))
+
+	// the custom scheduler fixture binds pods without a node selector
+	preBound := test.BuildTestPod(test.PodOpts{Name: "bound", NodeName: "buildeng-1", SchedulerName: "custom-scheduler"})
+	assert.True(t, filter(preBound))
+	preBoundElsewhere := test.BuildTestPod(test.PodOpts{Name: "elsewhere", NodeName: "shared-1", NodeSelectorKey: "customer", NodeSelectorValue: "buildeng", SchedulerName: "custom-scheduler"})
+	assert.False(t, filter(preBoundElsewhere))
+	// falls back to the node selector while the node isn't known
+	preBoundUnknownNode := test.BuildTestPod(test.PodOpts{Name: "unknown", NodeName: "buildeng-2", NodeSelectorKey: "customer", NodeSelectorValue: "buildeng"})
+	assert.True(t, filter(preBoundUnknownNode))
+	daemonSet := test.BuildTestPod(test.PodOpts{Name: "ds", NodeName: "buildeng-1", Owner: "DaemonSet"})
+	assert.False(t, filter(daemonSet))
+
+	pending := test.BuildTestPod(test.PodOpts{Name: "pending", NodeSelectorKey: "customer", NodeSelectorValue: "buildeng"})
+	assert.True(t, filter(pending))
+	scheduled := test.BuildTestPod(test.PodOpts{Name: "scheduled", NodeName: "buildeng-1"})
+	scheduled.Status.Conditions = []v1.PodCondition{{Type: v1.PodScheduled, Status: v1.ConditionTrue}}
+	assert.False(t, filter(scheduled))
+}
+
+func TestNodeGroupLister_PreBoundPods(t *testing.T) {
+	nodes := []*v1.Node{
+		test.BuildTestNode(test.NodeOpts{Name: "buildeng-1", CPU: 1000, Mem: 1000, LabelKey: "customer", LabelValue: "buildeng"}),
+		test.BuildTestNode(test.NodeOpts{Name: "default-1", CPU: 1000, Mem: 1000, LabelKey: "customer", LabelValue: "shared"}),
+	}
+	pods := []*v1.Pod{
+		test.BuildTestPod(test.PodOpts{Name: "bound", CPU: []int64{500}, Mem: []int64{500}, NodeName: "buildeng-1", SchedulerName: "custom-scheduler"}),
+		test.BuildTestPod(test.PodOpts{Name: "web", CPU: []int64{200}, Mem: []int64{200}}),
+	}
+	nodeGroups := []NodeGroupOptions{
+		{Name: DefaultNodeGroup, LabelKey: "customer", LabelValue: "shared"},
+		{Name: "buildeng", LabelKey: "customer", LabelValue: "buildeng"},
+	}
+	client, _ := buildTestClient(nodes, pods, nodeGroups, ListerOptions{})
+
+	// the pre-bound pod counts towards the node group of its node, and only that node group
+	buildengPods, err := client.Listers["buildeng"].Pods.List()
+	require.NoError(t, err)
+	require.Len(t, buildengPods, 1)
+	assert.Equal(t, "bound", buildengPods[0].Name)
+	assert.False(t, k8s.NodeEmpty(nodes[0], k8s.CreateNodeNameToInfoMap(buildengPods, nodes[:1])))
+
+	defaultPods, err := client.Listers[DefaultNodeGroup].Pods.List()
+	require.NoError(t, err)
+	require.Len(t, defaultPods, 1)
+	assert.Equal(t, "web", defaultPods[0].Name)
+}
+
+func TestValidateNodeGroupDependencies(t *testing.T) {
+	tests := []struct {
+		name       string
+		nodegroups []NodeGroupOptions
+		want       []string
+	}{
+		{
+			"no dependencies",
+			[]NodeGroupOptions{{Name: "a"}, {Name: "b"}},
+			nil,
+		},
+		{
+			"valid dependencies",
+			[]NodeGroupOptions{{Name: "cache", ScaleDownAfter: []string{"batch", "web"}}, {Name: "batch", ScaleDownAfter: []string{"web"}}, {Name: "web"}},
+			nil,
+		},
+		{
+			"unknown node group",
+			[]NodeGroupOptions{{Name: "cache", ScaleDownAfter: []string{"batch"}}},
+			[]string{"nodegroup cache: scale_down_after node group batch does not exist"},
+		},
+		{
+			"cycle",
+			[]NodeGroupOptions{{Name: "a", ScaleDownAfter: []string{"b"}}, {Name: "b", ScaleDownAfter: []string{"c"}}, {Name: "c", ScaleDownAfter: []string{"a"}}},
+			[]string{"nodegroup a: scale_down_after dependencies must not form a cycle"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := ValidateNodeGroupDependencies(tt.nodegroups)
+			if assert.Equal(t, len(tt.want), len(errs)) {
+				for i, err := range errs {
+					assert.Equal(t, tt.want[i], err.Error())
+				}
+			}
+		})
+	}
+}
+
+func TestNewArchFilterFuncs(t *testing.T) {
+	armNode := test.BuildTestNode(test.NodeOpts{LabelKey: "customer", LabelValue: "shared"})
+	armNode.Labels[k8s.ArchLabelKey] = k8s.ArchARM64
+	unlabelledNode := test.BuildTestNode(test.NodeOpts{LabelKey: "customer", LabelValue: "shared"})
+
+	nodeFilter := NewNodeArchFilterFunc(k8s.ArchARM64, NewNodeLabelFilterFunc("customer", "shared"))
+	assert.True(t, nodeFilter(armNode))
+	assert.False(t, nodeFilter(unlabelledNode))
+	assert.True(t, NewNodeArchFilterFunc(k8s.ArchAMD64, NewNodeLabelFilterFunc("customer", "shared"))(unlabelledNode))
+
+	amdPod := test.BuildTestPod(test.PodOpts{NodeSelectorKey: k8s.ArchLabelKey, NodeSelectorValue: k8s.ArchAMD64})
+	anyPod := test.BuildTestPod(test.PodOpts{})
+	allPods := func(*v1.Pod) bool { return true }
+	podFilter := NewPodArchFilterFunc(k8s.ArchARM64, allPods)
+	assert.False(t, podFilter(amdPod))
+	assert.True(t, podFilter(anyPod))
+	assert.True(t, NewPodArchFilterFunc("", allPods)(amdPod))
+}
+
 func TestUnmarshalNodeGroupOptions(t *testing.T) {
 	t.Run("test yaml unmarshal good", func(t *testing.T) {
 		yamlReader := strings.NewReader(yamlValid)
@@ -491,18 +646,720 @@ func TestValidateNodeGroup(t *testing.T) {
 				"taint_effect must be valid kubernetes taint",
 			},
 		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			errs := ValidateNodeGroup(tt.args.nodegroup)
-			eq := assert.Equal(t, len(tt.want), len(errs))
-			if eq && errs != nil {
-				for i, err := range errs {
-					assert.Equal(t, tt.want[i], err.Error())
-				}
-			}
-		})
-	}
+		{
+			"invalid queue demand",
+			args{
+				NodeGroupOptions{
+					Name:                               "test",
+					LabelKey:                           "customer",
+					LabelValue:                         "buileng",
+					CloudProviderGroupName:             "somegroup",
+					TaintUpperCapacityThresholdPercent: 70,
+					TaintLowerCapacityThresholdPercent: 60,
+					ScaleUpThresholdPercent:            100,
+					MinNodes:                           1,
+					MaxNodes:                           3,
+					SlowNodeRemovalRate:                1,
+					FastNodeRemovalRate:                2,
+					SoftDeleteGracePeriod:              "10m",
+					HardDeleteGracePeriod:              "1h10m",
+					ScaleUpCoolDownPeriod:              "55m",
+					QueueDemand:                        QueueDemandOptions{Provider: "slurm"},
+				},
+			},
+			[]string{
+				"queue_demand.provider must be either kueue or volcano",
+				"queue_demand.queue must not be empty when queue_demand.provider is set",
+			},
+		},
+		{
+			"invalid follow up timeout",
+			args{
+				NodeGroupOptions{
+					Name:                               "test",
+					LabelKey:                           "customer",
+					LabelValue:                         "buileng",
+					CloudProviderGroupName:             "somegroup",
+					TaintUpperCapacityThresholdPercent: 70,
+					TaintLowerCapacityThresholdPercent: 60,
+					ScaleUpThresholdPercent:            100,
+					MinNodes:                           1,
+					MaxNodes:                           3,
+					SlowNodeRemovalRate:                1,
+					FastNodeRemovalRate:                2,
+					SoftDeleteGracePeriod:              "10m",
+					HardDeleteGracePeriod:              "1h10m",
+					ScaleUpCoolDownPeriod:              "55m",
+					FollowUpTimeout:                    "soon",
+				},
+			},
+			[]string{
+				"follow_up_timeout failed to parse into a time.Duration. check your formatting.",
+			},
+		},
+		{
+			"invalid new node grace period",
+			args{
+				NodeGroupOptions{
+					Name:                               "test",
+					LabelKey:                           "customer",
+					LabelValue:                         "buileng",
+					CloudProviderGroupName:             "somegroup",
+					TaintUpperCapacityThresholdPercent: 70,
+					TaintLowerCapacityThresholdPercent: 60,
+					ScaleUpThresholdPercent:            100,
+					MinNodes:                           1,
+					MaxNodes:                           3,
+					SlowNodeRemovalRate:                1,
+					FastNodeRemovalRate:                2,
+					SoftDeleteGracePeriod:              "10m",
+					HardDeleteGracePeriod:              "1h10m",
+					ScaleUpCoolDownPeriod:              "55m",
+					NewNodeGracePeriod:                 "-5m",
+				},
+			},
+			[]string{
+				"new_node_grace_period failed to parse into a time.Duration. check your formatting.",
+			},
+		},
+		{
+			"invalid daemonset drain",
+			args{
+				NodeGroupOptions{
+					Name:                               "test",
+					LabelKey:                           "customer",
+					LabelValue:                         "buileng",
+					CloudProviderGroupName:             "somegroup",
+					TaintUpperCapacityThresholdPercent: 70,
+					TaintLowerCapacityThresholdPercent: 60,
+					ScaleUpThresholdPercent:            100,
+					MinNodes:                           1,
+					MaxNodes:                           3,
+					SlowNodeRemovalRate:                1,
+					FastNodeRemovalRate:                2,
+					SoftDeleteGracePeriod:              "10m",
+					HardDeleteGracePeriod:              "1h10m",
+					ScaleUpCoolDownPeriod:              "55m",
+					DaemonSetDrain:                     DaemonSetDrainOptions{DaemonSets: []string{"fluentd"}, Timeout: "later"},
+				},
+			},
+			[]string{
+				"daemonset_drain.daemonsets must be in the form namespace/name: fluentd",
+				"daemonset_drain.completed_annotation must not be empty when daemonset_drain.daemonsets is set",
+				"daemonset_drain.timeout failed to parse into a time.Duration. check your formatting.",
+			},
+		},
+		{
+			"invalid pre delete hook",
+			args{
+				NodeGroupOptions{
+					Name:                               "test",
+					LabelKey:                           "customer",
+					LabelValue:                         "buileng",
+					CloudProviderGroupName:             "somegroup",
+					TaintUpperCapacityThresholdPercent: 70,
+					TaintLowerCapacityThresholdPercent: 60,
+					ScaleUpThresholdPercent:            100,
+					MinNodes:                           1,
+					MaxNodes:                           3,
+					SlowNodeRemovalRate:                1,
+					FastNodeRemovalRate:                2,
+					SoftDeleteGracePeriod:              "10m",
+					HardDeleteGracePeriod:              "1h10m",
+					ScaleUpCoolDownPeriod:              "55m",
+					PreDeleteHook:                      PreDeleteHookOptions{URL: "license-manager/pre-delete", Timeout: "later", FailurePolicy: "ignore"},
+				},
+			},
+			[]string{
+				"pre_delete_hook.url must be an http or https URL: license-manager/pre-delete",
+				"pre_delete_hook.failure_policy must be either fail_open or fail_closed",
+				"pre_delete_hook.timeout failed to parse into a time.Duration. check your formatting.",
+			},
+		},
+		{
+			"invalid high water mark hold",
+			args{
+				NodeGroupOptions{
+					Name:                               "test",
+					LabelKey:                           "customer",
+					LabelValue:                         "buileng",
+					CloudProviderGroupName:             "somegroup",
+					TaintUpperCapacityThresholdPercent: 70,
+					TaintLowerCapacityThresholdPercent: 60,
+					ScaleUpThresholdPercent:            100,
+					MinNodes:                           1,
+					MaxNodes:                           3,
+					SlowNodeRemovalRate:                1,
+					FastNodeRemovalRate:                2,
+					SoftDeleteGracePeriod:              "10m",
+					HardDeleteGracePeriod:              "1h10m",
+					ScaleUpCoolDownPeriod:              "55m",
+					HighWaterMarkHold:                  HighWaterMarkHoldOptions{Percent: 150, Duration: "later"},
+				},
+			},
+			[]string{
+				"high_water_mark_hold.percent must be between 1 and 100",
+				"high_water_mark_hold.threshold_nodes must be larger than 0",
+				"high_water_mark_hold.duration failed to parse into a time.Duration. check your formatting.",
+			},
+		},
+		{
+			"invalid startup taints",
+			args{
+				NodeGroupOptions{
+					Name:                               "test",
+					LabelKey:                           "customer",
+					LabelValue:                         "buileng",
+					CloudProviderGroupName:             "somegroup",
+					TaintUpperCapacityThresholdPercent: 70,
+					TaintLowerCapacityThresholdPercent: 60,
+					ScaleUpThresholdPercent:            100,
+					MinNodes:                           1,
+					MaxNodes:                           3,
+					SlowNodeRemovalRate:                1,
+					FastNodeRemovalRate:                2,
+					SoftDeleteGracePeriod:              "10m",
+					HardDeleteGracePeriod:              "1h10m",
+					ScaleUpCoolDownPeriod:              "55m",
+					StartupTaints:                      []string{"", k8s.ToBeRemovedByAutoscalerKey},
+				},
+			},
+			[]string{
+				"startup_taints must not contain an empty taint key",
+				"startup_taints must not contain the escalator taint atlassian.com/escalator",
+			},
+		},
+		{
+			"invalid resource profiles",
+			args{
+				NodeGroupOptions{
+					Name:                               "test",
+					LabelKey:                           "customer",
+					LabelValue:                         "buileng",
+					CloudProviderGroupName:             "somegroup",
+					TaintUpperCapacityThresholdPercent: 70,
+					TaintLowerCapacityThresholdPercent: 60,
+					ScaleUpThresholdPercent:            100,
+					MinNodes:                           1,
+					MaxNodes:                           3,
+					SlowNodeRemovalRate:                1,
+					FastNodeRemovalRate:                2,
+					SoftDeleteGracePeriod:              "10m",
+					HardDeleteGracePeriod:              "1h10m",
+					ScaleUpCoolDownPeriod:              "55m",
+					ResourceProfiles: []ResourceProfile{
+						{Name: "large", LabelKey: "size", LabelValue: "large", ScaleUpThresholdPercent: 40},
+						{Name: "large", ScaleUpThresholdPercent: 0},
+					},
+				},
+			},
+			[]string{
+				"resource_profiles[1].name large is not unique",
+				"resource_profiles[1].label_key must not be empty",
+				"resource_profiles[1].scale_up_threshold_percent must be larger than 0",
+			},
+		},
+		{
+			"invalid soft_delete_grace_period_from",
+			args{
+				NodeGroupOptions{
+					Name:                               "test",
+					LabelKey:                           "customer",
+					LabelValue:                         "buileng",
+					CloudProviderGroupName:             "somegroup",
+					TaintUpperCapacityThresholdPercent: 70,
+					TaintLowerCapacityThresholdPercent: 60,
+					ScaleUpThresholdPercent:            100,
+					MinNodes:                           1,
+					MaxNodes:                           3,
+					SlowNodeRemovalRate:                1,
+					FastNodeRemovalRate:                2,
+					SoftDeleteGracePeriod:              "10m",
+					HardDeleteGracePeriod:              "1h10m",
+					SoftDeleteGracePeriodFrom:          "drain",
+					ScaleUpCoolDownPeriod:              "55m",
+				},
+			},
+			[]string{"soft_delete_grace_period_from must be either taint or empty"},
+		},
+		{
+			"invalid scale_down_mode",
+			args{
+				NodeGroupOptions{
+					Name:                               "test",
+					LabelKey:                           "customer",
+					LabelValue:                         "buileng",
+					CloudProviderGroupName:             "somegroup",
+					TaintUpperCapacityThresholdPercent: 70,
+					TaintLowerCapacityThresholdPercent: 60,
+					ScaleUpThresholdPercent:            100,
+					MinNodes:                           1,
+					MaxNodes:                           3,
+					SlowNodeRemovalRate:                1,
+					FastNodeRemovalRate:                2,
+					SoftDeleteGracePeriod:              "10m",
+					HardDeleteGracePeriod:              "1h10m",
+					ScaleUpCoolDownPeriod:              "55m",
+					ScaleDownMode:                      "drain",
+				},
+			},
+			[]string{"scale_down_mode must be either taint, cordon or both"},
+		},
+		{
+			"invalid quota_priority",
+			args{
+				NodeGroupOptions{
+					Name:                               "test",
+					LabelKey:                           "customer",
+					LabelValue:                         "buileng",
+					CloudProviderGroupName:             "somegroup",
+					TaintUpperCapacityThresholdPercent: 70,
+					TaintLowerCapacityThresholdPercent: 60,
+					ScaleUpThresholdPercent:            100,
+					MinNodes:                           1,
+					MaxNodes:                           3,
+					SlowNodeRemovalRate:                1,
+					FastNodeRemovalRate:                2,
+					SoftDeleteGracePeriod:              "10m",
+					HardDeleteGracePeriod:              "1h10m",
+					ScaleUpCoolDownPeriod:              "55m",
+					QuotaPriority:                      -1,
+				},
+			},
+			[]string{"quota_priority must not be less than 0"},
+		},
+		{
+			"empty protected_pods",
+			args{
+				NodeGroupOptions{
+					Name:                               "test",
+					LabelKey:                           "customer",
+					LabelValue:                         "buileng",
+					CloudProviderGroupName:             "somegroup",
+					TaintUpperCapacityThresholdPercent: 70,
+					TaintLowerCapacityThresholdPercent: 60,
+					ScaleUpThresholdPercent:            100,
+					MinNodes:                           1,
+					MaxNodes:                           3,
+					SlowNodeRemovalRate:                1,
+					FastNodeRemovalRate:                2,
+					SoftDeleteGracePeriod:              "10m",
+					HardDeleteGracePeriod:              "1h10m",
+					ScaleUpCoolDownPeriod:              "55m",
+					ProtectedPods:                      ProtectedPodsOptions{Namespaces: []string{""}, PriorityClasses: []string{""}},
+				},
+			},
+			[]string{
+				"protected_pods.namespaces must not contain an empty namespace",
+				"protected_pods.priority_classes must not contain an empty priority class",
+			},
+		},
+		{
+			"negative disruption_score weights",
+			args{
+				NodeGroupOptions{
+					Name:                               "test",
+					LabelKey:                           "customer",
+					LabelValue:                         "buileng",
+					CloudProviderGroupName:             "somegroup",
+					TaintUpperCapacityThresholdPercent: 70,
+					TaintLowerCapacityThresholdPercent: 60,
+					ScaleUpThresholdPercent:            100,
+					MinNodes:                           1,
+					MaxNodes:                           3,
+					SlowNodeRemovalRate:                1,
+					FastNodeRemovalRate:                2,
+					SoftDeleteGracePeriod:              "10m",
+					HardDeleteGracePeriod:              "1h10m",
+					ScaleUpCoolDownPeriod:              "55m",
+					DisruptionScore:                    DisruptionScoreOptions{Enabled: true, PodWeight: -1, LocalDataWeight: -1, JobWeight: -1},
+				},
+			},
+			[]string{
+				"disruption_score.pod_weight must not be negative",
+				"disruption_score.local_data_weight must not be negative",
+				"disruption_score.job_weight must not be negative",
+			},
+		},
+		{
+			"invalid taint_selection_policy",
+			args{
+				NodeGroupOptions{
+					Name:                               "test",
+					LabelKey:                           "customer",
+					LabelValue:                         "buileng",
+					CloudProviderGroupName:             "somegroup",
+					TaintUpperCapacityThresholdPercent: 70,
+					TaintLowerCapacityThresholdPercent: 60,
+					ScaleUpThresholdPercent:            100,
+					MinNodes:                           1,
+					MaxNodes:                           3,
+					SlowNodeRemovalRate:                1,
+					FastNodeRemovalRate:                2,
+					SoftDeleteGracePeriod:              "10m",
+					HardDeleteGracePeriod:              "1h10m",
+					ScaleUpCoolDownPeriod:              "55m",
+					TaintSelectionPolicy:               "cheapest",
+				},
+			},
+			[]string{
+				"taint_selection_policy must be one of oldest, newest, least-utilized, fewest-pods, disruption-score",
+			},
+		},
+		{
+			"disruption_score with another taint_selection_policy",
+			args{
+				NodeGroupOptions{
+					Name:                               "test",
+					LabelKey:                           "customer",
+					LabelValue:                         "buileng",
+					CloudProviderGroupName:             "somegroup",
+					TaintUpperCapacityThresholdPercent: 70,
+					TaintLowerCapacityThresholdPercent: 60,
+					ScaleUpThresholdPercent:            100,
+					MinNodes:                           1,
+					MaxNodes:                           3,
+					SlowNodeRemovalRate:                1,
+					FastNodeRemovalRate:                2,
+					SoftDeleteGracePeriod:              "10m",
+					HardDeleteGracePeriod:              "1h10m",
+					ScaleUpCoolDownPeriod:              "55m",
+					DisruptionScore:                    DisruptionScoreOptions{Enabled: true},
+					TaintSelectionPolicy:               TaintSelectionPolicyFewestPods,
+				},
+			},
+			[]string{
+				"disruption_score.enabled can't be used with the fewest-pods taint_selection_policy",
+			},
+		},
+		{
+			"incomplete EKS managed node group",
+			args{
+				NodeGroupOptions{
+					Name:                               "test",
+					LabelKey:                           "customer",
+					LabelValue:                         "buileng",
+					CloudProviderGroupName:             "somegroup",
+					TaintUpperCapacityThresholdPercent: 70,
+					TaintLowerCapacityThresholdPercent: 60,
+					ScaleUpThresholdPercent:            100,
+					MinNodes:                           1,
+					MaxNodes:                           3,
+					SlowNodeRemovalRate:                1,
+					FastNodeRemovalRate:                2,
+					SoftDeleteGracePeriod:              "10m",
+					HardDeleteGracePeriod:              "1h10m",
+					ScaleUpCoolDownPeriod:              "55m",
+					AWS:                                AWSNodeGroupOptions{EKSNodegroupName: "workers", LaunchTemplateID: "lt-1a2b3c4d"},
+				},
+			},
+			[]string{
+				"aws.eks_cluster_name and aws.eks_nodegroup_name must be set together",
+				"aws.launch_template_id can't be used with an EKS managed node group",
+			},
+		},
+		{
+			"invalid static_pod_nodes",
+			args{
+				NodeGroupOptions{
+					Name:                               "test",
+					LabelKey:                           "customer",
+					LabelValue:                         "buileng",
+					CloudProviderGroupName:             "somegroup",
+					TaintUpperCapacityThresholdPercent: 70,
+					TaintLowerCapacityThresholdPercent: 60,
+					ScaleUpThresholdPercent:            100,
+					MinNodes:                           1,
+					MaxNodes:                           3,
+					SlowNodeRemovalRate:                1,
+					FastNodeRemovalRate:                2,
+					SoftDeleteGracePeriod:              "10m",
+					HardDeleteGracePeriod:              "1h10m",
+					ScaleUpCoolDownPeriod:              "55m",
+					StaticPodNodes:                     "evict",
+				},
+			},
+			[]string{
+				"static_pod_nodes must be either protect or ignore",
+			},
+		},
+		{
+			"invalid eviction_limits",
+			args{
+				NodeGroupOptions{
+					Name:                               "test",
+					LabelKey:                           "customer",
+					LabelValue:                         "buileng",
+					CloudProviderGroupName:             "somegroup",
+					TaintUpperCapacityThresholdPercent: 70,
+					TaintLowerCapacityThresholdPercent: 60,
+					ScaleUpThresholdPercent:            100,
+					MinNodes:                           1,
+					MaxNodes:                           3,
+					SlowNodeRemovalRate:                1,
+					FastNodeRemovalRate:                2,
+					SoftDeleteGracePeriod:              "10m",
+					HardDeleteGracePeriod:              "1h10m",
+					ScaleUpCoolDownPeriod:              "55m",
+					EvictionLimits:                     EvictionLimitsOptions{MaxPods: -1, NodeInterval: "5 minutes"},
+				},
+			},
+			[]string{
+				"eviction_limits.max_pods must not be negative",
+				"eviction_limits.node_interval failed to parse into a time.Duration. check your formatting.",
+			},
+		},
+		{
+			"invalid volume_detach",
+			args{
+				NodeGroupOptions{
+					Name:                               "test",
+					LabelKey:                           "customer",
+					LabelValue:                         "buileng",
+					CloudProviderGroupName:             "somegroup",
+					TaintUpperCapacityThresholdPercent: 70,
+					TaintLowerCapacityThresholdPercent: 60,
+					ScaleUpThresholdPercent:            100,
+					MinNodes:                           1,
+					MaxNodes:                           3,
+					SlowNodeRemovalRate:                1,
+					FastNodeRemovalRate:                2,
+					SoftDeleteGracePeriod:              "10m",
+					HardDeleteGracePeriod:              "1h10m",
+					ScaleUpCoolDownPeriod:              "55m",
+					VolumeDetach:                       VolumeDetachOptions{MaxDelay: "ten minutes", HeavyNodeVolumes: -1},
+				},
+			},
+			[]string{
+				"volume_detach.max_delay failed to parse into a time.Duration. check your formatting.",
+				"volume_detach.max_delay requires volume_detach.delay_per_volume",
+				"volume_detach.heavy_node_volumes must not be negative",
+			},
+		},
+		{
+			"invalid pod_disruption_budget_max_deferral",
+			args{
+				NodeGroupOptions{
+					Name:                               "test",
+					LabelKey:                           "customer",
+					LabelValue:                         "buileng",
+					CloudProviderGroupName:             "somegroup",
+					TaintUpperCapacityThresholdPercent: 70,
+					TaintLowerCapacityThresholdPercent: 60,
+					ScaleUpThresholdPercent:            100,
+					MinNodes:                           1,
+					MaxNodes:                           3,
+					SlowNodeRemovalRate:                1,
+					FastNodeRemovalRate:                2,
+					SoftDeleteGracePeriod:              "10m",
+					HardDeleteGracePeriod:              "1h10m",
+					ScaleUpCoolDownPeriod:              "55m",
+					PodDisruptionBudgetMaxDeferral:     "an hour",
+				},
+			},
+			[]string{
+				"pod_disruption_budget_max_deferral failed to parse into a time.Duration. check your formatting.",
+			},
+		},
+		{
+			"invalid reschedule_max_deferral",
+			args{
+				NodeGroupOptions{
+					Name:                               "test",
+					LabelKey:                           "customer",
+					LabelValue:                         "buileng",
+					CloudProviderGroupName:             "somegroup",
+					TaintUpperCapacityThresholdPercent: 70,
+					TaintLowerCapacityThresholdPercent: 60,
+					ScaleUpThresholdPercent:            100,
+					MinNodes:                           1,
+					MaxNodes:                           3,
+					SlowNodeRemovalRate:                1,
+					FastNodeRemovalRate:                2,
+					SoftDeleteGracePeriod:              "10m",
+					HardDeleteGracePeriod:              "1h10m",
+					ScaleUpCoolDownPeriod:              "55m",
+					RescheduleMaxDeferral:              "an hour",
+				},
+			},
+			[]string{
+				"reschedule_max_deferral failed to parse into a time.Duration. check your formatting.",
+			},
+		},
+		{
+			"invalid cloud_provider_group_names",
+			args{
+				NodeGroupOptions{
+					Name:                               "test",
+					LabelKey:                           "customer",
+					LabelValue:                         "buileng",
+					CloudProviderGroupName:             "somegroup",
+					CloudProviderGroupNames:            []string{"somegroup-a", "", "somegroup-a"},
+					TaintUpperCapacityThresholdPercent: 70,
+					TaintLowerCapacityThresholdPercent: 60,
+					ScaleUpThresholdPercent:            100,
+					MinNodes:                           1,
+					MaxNodes:                           3,
+					SlowNodeRemovalRate:                1,
+					FastNodeRemovalRate:                2,
+					SoftDeleteGracePeriod:              "10m",
+					HardDeleteGracePeriod:              "1h10m",
+					ScaleUpCoolDownPeriod:              "55m",
+				},
+			},
+			[]string{
+				"cloud_provider_group_name and cloud_provider_group_names must not both be set",
+				"cloud_provider_group_names[1] must not be empty",
+				"cloud_provider_group_names[2] somegroup-a is not unique",
+			},
+		},
+		{
+			"dry_taint without dry_delete",
+			args{
+				NodeGroupOptions{
+					Name:                               "test",
+					LabelKey:                           "customer",
+					LabelValue:                         "buileng",
+					CloudProviderGroupName:             "somegroup",
+					TaintUpperCapacityThresholdPercent: 70,
+					TaintLowerCapacityThresholdPercent: 60,
+					ScaleUpThresholdPercent:            100,
+					MinNodes:                           1,
+					MaxNodes:                           3,
+					SlowNodeRemovalRate:                1,
+					FastNodeRemovalRate:                2,
+					SoftDeleteGracePeriod:              "10m",
+					HardDeleteGracePeriod:              "1h10m",
+					ScaleUpCoolDownPeriod:              "55m",
+					DryTaint:                           true,
+				},
+			},
+			[]string{
+				"dry_taint requires dry_delete, nodes are only deleted after they were tainted for real",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := ValidateNodeGroup(tt.args.nodegroup)
+			eq := assert.Equal(t, len(tt.want), len(errs))
+			if eq && errs != nil {
+				for i, err := range errs {
+					assert.Equal(t, tt.want[i], err.Error())
+				}
+			}
+		})
+	}
+}
+
+func TestValidateNodeGroup_NodeLabelDomain(t *testing.T) {
+	defer k8s.SetNodeLabelDomain("")
+	require.NoError(t, k8s.SetNodeLabelDomain("team-a.example.com"))
+
+	nodeGroup := NodeGroupOptions{
+		Name:                               "test",
+		LabelKey:                           "customer",
+		LabelValue:                         "buileng",
+		CloudProviderGroupName:             "somegroup",
+		TaintUpperCapacityThresholdPercent: 70,
+		TaintLowerCapacityThresholdPercent: 60,
+		ScaleUpThresholdPercent:            100,
+		MinNodes:                           1,
+		MaxNodes:                           3,
+		SlowNodeRemovalRate:                1,
+		FastNodeRemovalRate:                2,
+		SoftDeleteGracePeriod:              "10m",
+		HardDeleteGracePeriod:              "1h10m",
+		ScaleUpCoolDownPeriod:              "55m",
+	}
+	errs := ValidateNodeGroup(nodeGroup)
+	require.Len(t, errs, 1)
+	assert.Equal(t, "label_key must be in the node label domain team-a.example.com", errs[0].Error())
+
+	nodeGroup.LabelKey = "team-a.example.com/customer"
+	assert.Empty(t, ValidateNodeGroup(nodeGroup))
+}
+
+func TestValidateNodeGroupPools(t *testing.T) {
+	tests := []struct {
+		name       string
+		nodegroups []NodeGroupOptions
+		want       []string
+	}{
+		{
+			"no pools",
+			[]NodeGroupOptions{{Name: "a", ScaleUpThresholdPercent: 70}, {Name: "b", ScaleUpThresholdPercent: 80}},
+			nil,
+		},
+		{
+			"same options",
+			[]NodeGroupOptions{
+				{Name: "a", Pool: "shared", ScaleUpThresholdPercent: 70},
+				{Name: "b", Pool: "shared", ScaleUpThresholdPercent: 70, PoolScalePolicy: PoolScalePolicyBalanced},
+				{Name: "c", Pool: "other", ScaleUpThresholdPercent: 80},
+			},
+			nil,
+		},
+		{
+			"different options",
+			[]NodeGroupOptions{
+				{Name: "a", Pool: "shared", ScaleUpThresholdPercent: 70, TaintLowerCapacityThresholdPercent: 30},
+				{Name: "b", Pool: "shared", ScaleUpThresholdPercent: 80, TaintLowerCapacityThresholdPercent: 30, PoolScalePolicy: PoolScalePolicyPriority},
+			},
+			[]string{
+				"nodegroup b: scale_up_threshold_percent must be the same as node group a in pool shared",
+				"nodegroup b: pool_scale_policy must be the same as node group a in pool shared",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := ValidateNodeGroupPools(tt.nodegroups)
+			if assert.Equal(t, len(tt.want), len(errs)) {
+				for i, err := range errs {
+					assert.Equal(t, tt.want[i], err.Error())
+				}
+			}
+		})
+	}
+}
+
+func TestValidateNodeGroupCloudProviders(t *testing.T) {
+	nodegroups := []NodeGroupOptions{
+		{Name: "discovered"},
+		{Name: "bounded", MinNodes: 1, MaxNodes: 5},
+		{Name: "gce", CloudProvider: "gce"},
+		{Name: "aws", CloudProvider: "aws"},
+	}
+	tests := []struct {
+		name            string
+		defaultProvider string
+		want            []string
+	}{
+		{"aws", "aws", []string{
+			"nodegroup gce: min_nodes and max_nodes must be set, the gce cloud provider can't auto discover them",
+		}},
+		{"azure", "azure", []string{
+			"nodegroup discovered: min_nodes and max_nodes must be set, the azure cloud provider can't auto discover them",
+			"nodegroup gce: min_nodes and max_nodes must be set, the gce cloud provider can't auto discover them",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := ValidateNodeGroupCloudProviders(nodegroups, tt.defaultProvider)
+			if assert.Equal(t, len(tt.want), len(errs)) {
+				for i, err := range errs {
+					assert.Equal(t, tt.want[i], err.Error())
+				}
+			}
+		})
+	}
+}
+
+func TestNodeGroupOptions_StartupTaintKeys(t *testing.T) {
+	assert.Equal(t, k8s.DefaultStartupTaintKeys, (&NodeGroupOptions{}).StartupTaintKeys())
+	assert.Equal(t, []string{}, (&NodeGroupOptions{StartupTaints: []string{}}).StartupTaintKeys())
+	assert.Equal(t, []string{"node.cilium.io/agent-not-ready"}, (&NodeGroupOptions{StartupTaints: []string{"node.cilium.io/agent-not-ready"}}).StartupTaintKeys())
 }
 
 func TestNodeGroupOptions_autoDiscoverMinMaxNodeOptions(t *testing.T) {
@@ -0,0 +1,16 @@
+package controller
+
+import (
+	log "github.com/sirupsen/logrus"
+)
+
+// observeDecision records the decision of an observe only node group without acting on it, and returns the nodes
+// delta it would have scaled by
+func observeDecision(nodeGroup *NodeGroupState, decision string, nodesDelta int) int {
+	log.WithField("nodegroup", nodeGroup.Opts.Name).
+		WithField("observe_only", true).
+		Infof("Not acting on the %v decision with a delta of %v nodes as the node group is observe only", decision, nodesDelta)
+	nodeGroup.cycle.Decision = decision
+	nodeGroup.cycle.ObserveOnly = true
+	return nodesDelta
+}
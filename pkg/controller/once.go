@@ -0,0 +1,116 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/atlassian/escalator/pkg/metrics"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// RunModeFull runs the scaling logic of the node groups as normal
+	RunModeFull = "full"
+	// RunModeReap only deletes the tainted nodes that are empty or past their hard delete grace period
+	RunModeReap = "reap"
+	// RunModeUntaint only untaints every node tainted by escalator
+	RunModeUntaint = "untaint"
+)
+
+// RunModes are the valid run modes
+var RunModes = []string{RunModeFull, RunModeReap, RunModeUntaint}
+
+// OnceSummary is the machine readable summary of a single run, e.g. of a one-shot cleanup
+type OnceSummary struct {
+	Time time.Time `json:"time"`
+	Mode string    `json:"mode"`
+	// Passed is whether the run and the runs of all of the node groups succeeded
+	Passed bool   `json:"passed"`
+	Error  string `json:"error,omitempty"`
+	// NodeGroups is the cycle summary of each node group that was run, by name
+	NodeGroups map[string]CycleSummary `json:"node_groups"`
+}
+
+// runMode returns the run mode, defaulting to full
+func (c *Controller) runMode() string {
+	if len(c.Opts.RunMode) == 0 {
+		return RunModeFull
+	}
+	return c.Opts.RunMode
+}
+
+// cleanupOnly returns whether the runs only clean up the nodes of the node groups, without a scaling decision
+func (c *Controller) cleanupOnly() bool {
+	return c.runMode() == RunModeReap || c.runMode() == RunModeUntaint
+}
+
+// RunOnceSummary runs the node groups once and summarises the run. Node groups that weren't run, e.g. after the run
+// was cancelled, are left out
+func (c *Controller) RunOnceSummary() OnceSummary {
+	start := time.Now()
+	summary := OnceSummary{Time: start, Mode: c.runMode(), Passed: true, NodeGroups: make(map[string]CycleSummary)}
+	if err := c.RunOnce(); err != nil {
+		summary.Passed = false
+		summary.Error = err.Error()
+	}
+
+	for name, cycles := range c.Cycles() {
+		if len(cycles) == 0 {
+			continue
+		}
+		latest := cycles[len(cycles)-1]
+		if latest.Time.Before(start) {
+			continue
+		}
+		summary.NodeGroups[name] = latest
+		if len(latest.Error) > 0 {
+			summary.Passed = false
+		}
+	}
+	return summary
+}
+
+// runCleanup only reaps or only untaints the nodes of the node group, by the run mode. The guards of the reaper of a
+// normal run still apply, so nodes aren't deleted during a maintenance window or with scale down disabled
+func (c *Controller) runCleanup(opts scaleOpts, now time.Time) (int, error) {
+	nodeGroup := opts.nodeGroup
+	logger := log.WithField("nodegroup", nodeGroup.Opts.Name)
+	nodeGroup.cycle.Decision = CycleDecisionNone
+	window, inMaintenanceWindow := c.Opts.Cluster.activeMaintenanceWindow(nodeGroup.Opts.Name, now)
+	updateMaintenanceWindow(nodeGroup, window, inMaintenanceWindow)
+	if nodeGroup.Opts.ObserveOnly {
+		logger.Infof("Not running the %v cleanup as the node group is observe only", c.runMode())
+		nodeGroup.cycle.ObserveOnly = true
+		return 0, nil
+	}
+
+	switch c.runMode() {
+	case RunModeReap:
+		if inMaintenanceWindow {
+			logger.Infof("Reaper: not deleting nodes during maintenance window %v", window.Name)
+			return 0, nil
+		}
+		if blockingNodeGroup, blocked := c.scaleDownBlocked(nodeGroup); blocked {
+			logger.Infof("Reaper: not deleting nodes while dependent node group %v is busy", blockingNodeGroup)
+			return 0, nil
+		}
+		if !nodeGroup.Opts.scaleDownEnabled() {
+			logger.Info("Reaper: not deleting nodes as scale_down_enabled is false")
+			return 0, nil
+		}
+		removed, err := c.TryRemoveTaintedNodes(opts)
+		logger.Infof("Reaper: There were %v empty nodes deleted this round", removed)
+		nodeGroup.cycle.NodesDeleted = removed
+		return 0, err
+	case RunModeUntaint:
+		if len(opts.taintedNodes) == 0 {
+			logger.Info("There are no tainted nodes to untaint")
+			return 0, nil
+		}
+		logger.Infof("Untainting all %v tainted nodes", len(opts.taintedNodes))
+		metrics.NodeGroupUntaintEvent.WithLabelValues(nodeGroup.Opts.Name).Add(float64(len(opts.taintedNodes)))
+		untainted := c.untaintNewestN(opts.ctx, opts.taintedNodes, nodeGroup, len(opts.taintedNodes), false)
+		nodeGroup.cycle.NodesDeltaResult = len(untainted)
+		return 0, nil
+	}
+	return 0, nil
+}
@@ -0,0 +1,82 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/atlassian/escalator/pkg/test"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestRunMode(t *testing.T) {
+	c := &Controller{}
+	assert.Equal(t, RunModeFull, c.runMode())
+	assert.False(t, c.cleanupOnly())
+
+	c.Opts.RunMode = RunModeReap
+	assert.True(t, c.cleanupOnly())
+	c.Opts.RunMode = RunModeUntaint
+	assert.True(t, c.cleanupOnly())
+}
+
+func TestRunCleanup_Untaint(t *testing.T) {
+	now := time.Now()
+	nodes := []*v1.Node{
+		test.BuildTestNode(test.NodeOpts{Name: "n1"}),
+		test.BuildTestNode(test.NodeOpts{Name: "n2"}),
+		test.BuildTestNode(test.NodeOpts{Name: "n3"}),
+	}
+	nodeGroup := &NodeGroupState{Opts: NodeGroupOptions{Name: "default", DryMode: true}}
+	nodeGroup.dryTaintNode(nodes[0], now)
+	nodeGroup.dryTaintNode(nodes[1], now)
+	c := &Controller{Opts: Opts{RunMode: RunModeUntaint}}
+
+	delta, err := c.runCleanup(scaleOpts{
+		ctx:            context.Background(),
+		nodes:          nodes,
+		taintedNodes:   nodes[:2],
+		untaintedNodes: nodes[2:],
+		nodeGroup:      nodeGroup,
+	}, now)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, delta)
+	assert.Equal(t, CycleDecisionNone, nodeGroup.cycle.Decision)
+	assert.Equal(t, 2, nodeGroup.cycle.NodesDeltaResult)
+	assert.Empty(t, nodeGroup.taintTracker)
+}
+
+func TestRunCleanup_Skipped(t *testing.T) {
+	now := time.Now()
+	nodes := []*v1.Node{test.BuildTestNode(test.NodeOpts{Name: "n1"})}
+	scaleDownEnabled := false
+
+	tests := []struct {
+		name string
+		mode string
+		opts NodeGroupOptions
+	}{
+		{"observe only untaint", RunModeUntaint, NodeGroupOptions{Name: "default", DryMode: true, ObserveOnly: true}},
+		{"observe only reap", RunModeReap, NodeGroupOptions{Name: "default", DryMode: true, ObserveOnly: true}},
+		{"scale down disabled reap", RunModeReap, NodeGroupOptions{Name: "default", DryMode: true, ScaleDownEnabled: &scaleDownEnabled}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodeGroup := &NodeGroupState{Opts: tt.opts}
+			nodeGroup.dryTaintNode(nodes[0], now)
+			c := &Controller{Opts: Opts{RunMode: tt.mode}}
+
+			_, err := c.runCleanup(scaleOpts{
+				ctx:          context.Background(),
+				nodes:        nodes,
+				taintedNodes: nodes,
+				nodeGroup:    nodeGroup,
+			}, now)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.opts.ObserveOnly, nodeGroup.cycle.ObserveOnly)
+			assert.Equal(t, 0, nodeGroup.cycle.NodesDeleted)
+			assert.Len(t, nodeGroup.taintTracker, 1)
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package controller
+
+import (
+	"strings"
+	"time"
+
+	"github.com/atlassian/escalator/pkg/k8s"
+	"github.com/atlassian/escalator/pkg/metrics"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	policy "k8s.io/api/policy/v1beta1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// defaultPodDisruptionBudgetMaxDeferral is how long a hard deletion is deferred for by the PodDisruptionBudgets when
+// pod_disruption_budget_max_deferral isn't set
+const defaultPodDisruptionBudgetMaxDeferral = time.Hour
+
+// podDisruptionBudgets returns the PodDisruptionBudgets of the cluster, nil when they aren't watched
+func (c *Controller) podDisruptionBudgets() []*policy.PodDisruptionBudget {
+	if c.Client == nil || c.Client.allPodDisruptionBudgetLister == nil {
+		return nil
+	}
+	pdbs, err := c.Client.allPodDisruptionBudgetLister.List(labels.Everything())
+	if err != nil {
+		log.WithError(err).Warn("Failed to list the PodDisruptionBudgets")
+		return nil
+	}
+	return pdbs
+}
+
+// podDisruptionBudgetsBlockDeletion returns whether the hard deletion of the tainted node, which still runs pods, is
+// deferred because evicting its pods would violate a PodDisruptionBudget, given the pods of each budget evicted by
+// this run. The deletion is deferred for at most pod_disruption_budget_max_deferral from the first time it was, after
+// which the node is deleted anyway
+func (c *Controller) podDisruptionBudgetsBlockDeletion(nodeGroup *NodeGroupState, node *v1.Node, evicted map[string]int, now time.Time) bool {
+	pdbs := c.podDisruptionBudgets()
+	if len(pdbs) == 0 {
+		return false
+	}
+	logger := log.WithField("nodegroup", nodeGroup.Opts.Name)
+	violated := k8s.ViolatedPodDisruptionBudgets(k8s.NodeReschedulablePods(node, nodeGroup.NodeInfoMap), pdbs, evicted)
+	if len(violated) == 0 {
+		delete(nodeGroup.podDisruptionBudgetDeferrals, node.Name)
+		return false
+	}
+
+	since, ok := nodeGroup.podDisruptionBudgetDeferrals[node.Name]
+	if !ok {
+		if nodeGroup.podDisruptionBudgetDeferrals == nil {
+			nodeGroup.podDisruptionBudgetDeferrals = make(map[string]time.Time)
+		}
+		nodeGroup.podDisruptionBudgetDeferrals[node.Name] = now
+		since = now
+	}
+	maxDeferral := nodeGroup.Opts.PodDisruptionBudgetMaxDeferralDuration()
+	if now.Sub(since) >= maxDeferral {
+		logger.Warningf("deleting node %v although evicting its pods violates the PodDisruptionBudgets %v, its deletion was deferred for the maximum of %v",
+			node.Name, strings.Join(violated, ", "), maxDeferral)
+		return false
+	}
+	logger.Warningf("deferring deletion of node %v: evicting its pods would violate the PodDisruptionBudgets %v, %v remaining of the maximum deferral",
+		node.Name, strings.Join(violated, ", "), maxDeferral-now.Sub(since))
+	metrics.NodeGroupDeletionsBlockedByPDB.WithLabelValues(nodeGroup.Opts.Name).Add(1.0)
+	return true
+}
+
+// recordPodDisruptionBudgetEvictions counts the pods of each PodDisruptionBudget evicted by the deletion of the node
+// against the disruptions the budgets allow for the rest of the run
+func (c *Controller) recordPodDisruptionBudgetEvictions(nodeGroup *NodeGroupState, node *v1.Node, evicted map[string]int) {
+	pdbs := c.podDisruptionBudgets()
+	if len(pdbs) == 0 {
+		return
+	}
+	for key, pods := range k8s.PodDisruptionBudgetEvictions(k8s.NodeReschedulablePods(node, nodeGroup.NodeInfoMap), pdbs) {
+		evicted[key] += pods
+	}
+}
+
+// prunePodDisruptionBudgetDeferrals forgets the deferrals of the nodes that are no longer tainted
+func prunePodDisruptionBudgetDeferrals(nodeGroup *NodeGroupState, taintedNodes []*v1.Node) {
+	if len(nodeGroup.podDisruptionBudgetDeferrals) == 0 {
+		return
+	}
+	tainted := make(map[string]bool, len(taintedNodes))
+	for _, node := range taintedNodes {
+		tainted[node.Name] = true
+	}
+	for name := range nodeGroup.podDisruptionBudgetDeferrals {
+		if !tainted[name] {
+			delete(nodeGroup.podDisruptionBudgetDeferrals, name)
+		}
+	}
+}
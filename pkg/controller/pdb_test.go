@@ -0,0 +1,76 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/atlassian/escalator/pkg/k8s"
+	"github.com/atlassian/escalator/pkg/test"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	policy "k8s.io/api/policy/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	policylister "k8s.io/client-go/listers/policy/v1beta1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// buildWebPod returns a pod of the web app on the node
+func buildWebPod(name string, nodeName string) *v1.Pod {
+	pod := test.BuildTestPod(test.PodOpts{Name: name, Namespace: "default", NodeName: nodeName})
+	pod.Labels = map[string]string{"app": "web"}
+	return pod
+}
+
+func TestPodDisruptionBudgetsBlockDeletion(t *testing.T) {
+	nodes := []*v1.Node{
+		test.BuildTestNode(test.NodeOpts{Name: "n1", Tainted: true}),
+		test.BuildTestNode(test.NodeOpts{Name: "n2", Tainted: true}),
+	}
+	pods := []*v1.Pod{buildWebPod("web-1", "n1"), buildWebPod("web-2", "n2")}
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	assert.NoError(t, indexer.Add(&policy.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"},
+		Spec:       policy.PodDisruptionBudgetSpec{Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}},
+		Status:     policy.PodDisruptionBudgetStatus{PodDisruptionsAllowed: 1},
+	}))
+
+	c := &Controller{Client: &Client{allPodDisruptionBudgetLister: policylister.NewPodDisruptionBudgetLister(indexer)}}
+	nodeGroup := &NodeGroupState{
+		Opts:        NodeGroupOptions{Name: "default", PodDisruptionBudgetMaxDeferral: "30m"},
+		NodeInfoMap: k8s.CreateNodeNameToInfoMap(pods, nodes),
+	}
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// the budget allows the first node of the run, but not a second one
+	evicted := make(map[string]int)
+	assert.False(t, c.podDisruptionBudgetsBlockDeletion(nodeGroup, nodes[0], evicted, now))
+	c.recordPodDisruptionBudgetEvictions(nodeGroup, nodes[0], evicted)
+	assert.Equal(t, map[string]int{"default/web": 1}, evicted)
+	assert.True(t, c.podDisruptionBudgetsBlockDeletion(nodeGroup, nodes[1], evicted, now))
+	assert.Equal(t, map[string]time.Time{"n2": now}, nodeGroup.podDisruptionBudgetDeferrals)
+
+	// still deferred within the maximum, deleted anyway past it
+	assert.True(t, c.podDisruptionBudgetsBlockDeletion(nodeGroup, nodes[1], evicted, now.Add(29*time.Minute)))
+	assert.False(t, c.podDisruptionBudgetsBlockDeletion(nodeGroup, nodes[1], evicted, now.Add(30*time.Minute)))
+
+	// the deferral is forgotten once the budget allows the node
+	assert.False(t, c.podDisruptionBudgetsBlockDeletion(nodeGroup, nodes[1], make(map[string]int), now.Add(31*time.Minute)))
+	assert.Empty(t, nodeGroup.podDisruptionBudgetDeferrals)
+}
+
+func TestPodDisruptionBudgetsBlockDeletion_NotWatched(t *testing.T) {
+	node := test.BuildTestNode(test.NodeOpts{Name: "n1", Tainted: true})
+	nodeGroup := &NodeGroupState{
+		Opts:        NodeGroupOptions{Name: "default"},
+		NodeInfoMap: k8s.CreateNodeNameToInfoMap([]*v1.Pod{buildWebPod("web-1", "n1")}, []*v1.Node{node}),
+	}
+	c := &Controller{Client: &Client{}}
+	assert.False(t, c.podDisruptionBudgetsBlockDeletion(nodeGroup, node, make(map[string]int), time.Now()))
+}
+
+func TestPrunePodDisruptionBudgetDeferrals(t *testing.T) {
+	nodeGroup := &NodeGroupState{podDisruptionBudgetDeferrals: map[string]time.Time{"tainted": time.Now(), "untainted": time.Now()}}
+	prunePodDisruptionBudgetDeferrals(nodeGroup, []*v1.Node{test.BuildTestNode(test.NodeOpts{Name: "tainted", Tainted: true})})
+	assert.Len(t, nodeGroup.podDisruptionBudgetDeferrals, 1)
+	assert.Contains(t, nodeGroup.podDisruptionBudgetDeferrals, "tainted")
+}
@@ -0,0 +1,308 @@
+package controller
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/atlassian/escalator/pkg/k8s"
+	"github.com/atlassian/escalator/pkg/metrics"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const (
+	// PoolScalePolicyBalanced adds nodes to the node group of the pool with the fewest untainted nodes and removes them
+	// from the one with the most
+	PoolScalePolicyBalanced = "balanced"
+	// PoolScalePolicyPriority adds nodes to the node groups of the pool in config order, or by the priority expander
+	// ConfigMap when it is set, and removes them in reverse
+	PoolScalePolicyPriority = "priority"
+	// PoolScalePolicyProportional adds and removes the nodes of the pool in proportion to the untainted nodes of each
+	// node group, so their sizes keep the same ratios
+	PoolScalePolicyProportional = "proportional"
+)
+
+// poolPlan is the scaling decision of a pool, planned once per run
+type poolPlan struct {
+	cpuPercent float64
+	memPercent float64
+	// the nodes delta of each node group in the pool
+	deltas map[string]int
+}
+
+// poolMembers returns the states of the node groups in the pool, in config order
+func (c *Controller) poolMembers(pool string) []*NodeGroupState {
+	var members []*NodeGroupState
+	for _, nodeGroupOpts := range c.Opts.NodeGroups {
+		if nodeGroupOpts.Pool != pool {
+			continue
+		}
+		if state, ok := c.nodeGroups[nodeGroupOpts.Name]; ok {
+			members = append(members, state)
+		}
+	}
+	return members
+}
+
+// poolNodesDelta returns the share of the node group in the nodes delta of its pool. The pool is planned by the first
+// of its node groups evaluated in a run, from the requests and capacity of each node group as of its latest run
+func (c *Controller) poolNodesDelta(nodeGroup *NodeGroupState) (int, error) {
+	pool := nodeGroup.Opts.Pool
+	plan, ok := c.poolPlans[pool]
+	if !ok {
+		members := c.poolMembers(pool)
+		if nodeGroup.Opts.poolScalePolicy() == PoolScalePolicyPriority {
+			members = orderByExpanderPriority(members, c.expanderPriorities)
+		}
+		var err error
+		plan, err = planPool(nodeGroup, members)
+		if err != nil {
+			return 0, err
+		}
+		if c.poolPlans == nil {
+			c.poolPlans = make(map[string]poolPlan)
+		}
+		c.poolPlans[pool] = plan
+	}
+	log.WithField("nodegroup", nodeGroup.Opts.Name).Infof("pool %v cpu: %v, memory: %v, delta: %v", pool, plan.cpuPercent, plan.memPercent, plan.deltas[nodeGroup.Opts.Name])
+	return plan.deltas[nodeGroup.Opts.Name], nil
+}
+
+// resetPoolPlans makes each pool be planned again by the next of its node groups evaluated
+func (c *Controller) resetPoolPlans() {
+	c.poolPlans = nil
+}
+
+// refreshExpanderPriorities reads the priorities of the priority_expander ConfigMap for the run. The priorities of the
+// last successful read are kept when it fails, so a transient API error doesn't reorder the pools
+func (c *Controller) refreshExpanderPriorities() {
+	opts := c.Opts.Cluster.PriorityExpander
+	if !opts.enabled() {
+		c.expanderPriorities = nil
+		return
+	}
+	if c.Client == nil {
+		return
+	}
+	priorities, err := k8s.GetExpanderPriorities(c.Client, opts.ConfigMapNamespace(), opts.Name)
+	if err != nil {
+		log.WithError(err).Warn("Failed to read the priority expander priorities, using the last priorities read")
+		return
+	}
+	c.expanderPriorities = priorities
+}
+
+// orderByExpanderPriority orders the members from the highest priority to the lowest by their cloud provider group
+// name, as the cluster autoscaler matches the priorities against the names of the auto scaling groups. Members without
+// a priority go last, and members with the same priority stay in config order
+func orderByExpanderPriority(members []*NodeGroupState, priorities k8s.ExpanderPriorities) []*NodeGroupState {
+	if len(priorities) == 0 {
+		return members
+	}
+	type rankedMember struct {
+		member   *NodeGroupState
+		priority int
+		matched  bool
+	}
+	ranked := make([]rankedMember, 0, len(members))
+	for _, member := range members {
+		// a node group backed by several groups takes the highest priority of any of them
+		ranked = append(ranked, rankedMember{member: member})
+		for _, groupID := range member.Opts.CloudProviderGroupIDs() {
+			priority, matched := priorities.Priority(groupID)
+			if matched && (!ranked[len(ranked)-1].matched || priority > ranked[len(ranked)-1].priority) {
+				ranked[len(ranked)-1].priority = priority
+				ranked[len(ranked)-1].matched = true
+			}
+		}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].matched != ranked[j].matched {
+			return ranked[i].matched
+		}
+		return ranked[i].priority > ranked[j].priority
+	})
+
+	ordered := make([]*NodeGroupState, 0, len(ranked))
+	for _, r := range ranked {
+		ordered = append(ordered, r.member)
+	}
+	return ordered
+}
+
+// planPool decides the nodes delta of the pool from the thresholds of the node group, which are the same for every
+// node group in the pool, and distributes it between the members
+func planPool(nodeGroup *NodeGroupState, members []*NodeGroupState) (poolPlan, error) {
+	var cpuRequest, memRequest, cpuCapacity, memCapacity resource.Quantity
+	var untaintedNodes int64
+	for _, member := range members {
+		cpuRequest.Add(member.cpuRequest)
+		memRequest.Add(member.memRequest)
+		cpuCapacity.Add(member.untaintedCPUCapacity)
+		memCapacity.Add(member.untaintedMemCapacity)
+		untaintedNodes += int64(member.untaintedNodeCount)
+	}
+
+	cpuPercent, memPercent, err := calcPercentUsage(cpuRequest, memRequest, cpuCapacity, memCapacity, untaintedNodes)
+	if err != nil {
+		return poolPlan{}, err
+	}
+	plan := poolPlan{cpuPercent: cpuPercent, memPercent: memPercent, deltas: make(map[string]int, len(members))}
+	if cpuPercent == math.MaxFloat64 || memPercent == math.MaxFloat64 {
+		metrics.PoolCPUPercent.WithLabelValues(nodeGroup.Opts.Pool).Set(0)
+		metrics.PoolMemPercent.WithLabelValues(nodeGroup.Opts.Pool).Set(0)
+	} else {
+		metrics.PoolCPUPercent.WithLabelValues(nodeGroup.Opts.Pool).Set(cpuPercent)
+		metrics.PoolMemPercent.WithLabelValues(nodeGroup.Opts.Pool).Set(memPercent)
+	}
+
+	policy := nodeGroup.Opts.poolScalePolicy()
+	maxPercent := math.Max(cpuPercent, memPercent)
+	switch {
+	case maxPercent < float64(nodeGroup.Opts.TaintLowerCapacityThresholdPercent):
+		distributePoolScaleDown(plan.deltas, members, calcScaleDownDelta(nodeGroup.Opts.FastNodeRemovalRate, nodeGroup.Opts.ScaleDownCount, int(untaintedNodes)), policy)
+	case maxPercent < float64(nodeGroup.Opts.TaintUpperCapacityThresholdPercent):
+		distributePoolScaleDown(plan.deltas, members, calcScaleDownDelta(nodeGroup.Opts.SlowNodeRemovalRate, nodeGroup.Opts.ScaleDownCount, int(untaintedNodes)), policy)
+	case maxPercent > float64(nodeGroup.Opts.ScaleUpThresholdPercent):
+		// the capacity missing to bring the utilisation of the pool back to the scale up threshold
+		threshold := float64(nodeGroup.Opts.ScaleUpThresholdPercent) / 100
+		neededCPU := int64(math.Ceil(float64(cpuRequest.MilliValue())/threshold)) - cpuCapacity.MilliValue()
+		neededMem := int64(math.Ceil(float64(memRequest.MilliValue())/threshold)) - memCapacity.MilliValue()
+		distributePoolScaleUp(plan.deltas, members, neededCPU, neededMem, policy)
+	}
+	return plan, nil
+}
+
+// distributePoolScaleUp adds nodes to the members by the policy until they cover the needed cpu and memory, in milli
+// units. Node groups at their max_nodes or with scale up disabled are skipped
+func distributePoolScaleUp(deltas map[string]int, members []*NodeGroupState, neededCPU, neededMem int64, policy string) {
+	roundRobin := newPoolRoundRobin(members)
+	for neededCPU > 0 || neededMem > 0 {
+		var eligible []*NodeGroupState
+		for _, member := range members {
+			if !member.Opts.scaleUpEnabled() || member.nodeCount+deltas[member.Opts.Name] >= member.Opts.MaxNodes {
+				continue
+			}
+			eligible = append(eligible, member)
+		}
+		if len(eligible) == 0 {
+			log.Warn("every node group in the pool is at its max_nodes or has scale up disabled")
+			return
+		}
+
+		chosen := eligible[0]
+		switch policy {
+		case PoolScalePolicyPriority:
+			// the first in config order
+		case PoolScalePolicyProportional:
+			chosen = roundRobin.nextMember(eligible)
+		default:
+			for _, member := range eligible[1:] {
+				if member.untaintedNodeCount+deltas[member.Opts.Name] < chosen.untaintedNodeCount+deltas[chosen.Opts.Name] {
+					chosen = member
+				}
+			}
+		}
+		deltas[chosen.Opts.Name]++
+
+		// without a cached node capacity the new node is assumed to cover the rest, as when scaling up from 0
+		if chosen.cpuCapacity.IsZero() || chosen.memCapacity.IsZero() {
+			return
+		}
+		neededCPU -= chosen.cpuCapacity.MilliValue()
+		neededMem -= chosen.memCapacity.MilliValue()
+	}
+}
+
+// distributePoolScaleDown removes nodes from the members by the policy. Node groups at their min_nodes, including the
+// held and reserved nodes, or with scale down disabled are skipped
+func distributePoolScaleDown(deltas map[string]int, members []*NodeGroupState, nodes int, policy string) {
+	now := time.Now()
+	roundRobin := newPoolRoundRobin(members)
+	for i := 0; i < nodes; i++ {
+		var eligible []*NodeGroupState
+		for j := len(members) - 1; j >= 0; j-- {
+			member := members[j]
+			if !member.Opts.scaleDownEnabled() || member.untaintedNodeCount+deltas[member.Opts.Name] <= minNodes(member, now) {
+				continue
+			}
+			eligible = append(eligible, member)
+		}
+		if len(eligible) == 0 {
+			return
+		}
+
+		chosen := eligible[0]
+		switch policy {
+		case PoolScalePolicyPriority:
+			// the last in config order
+		case PoolScalePolicyProportional:
+			chosen = roundRobin.nextMember(eligible)
+		default:
+			for _, member := range eligible[1:] {
+				if member.untaintedNodeCount+deltas[member.Opts.Name] > chosen.untaintedNodeCount+deltas[chosen.Opts.Name] {
+					chosen = member
+				}
+			}
+		}
+		deltas[chosen.Opts.Name]--
+	}
+}
+
+// weightedRoundRobin picks keys, e.g. node groups, in proportion to their weights. The picks are interleaved (smooth
+// weighted round robin), so any number of them is split as close to the proportions as it can be
+type weightedRoundRobin struct {
+	weights map[string]int
+	current map[string]int
+}
+
+// newWeightedRoundRobin picks the keys by the weights
+func newWeightedRoundRobin(weights map[string]int) *weightedRoundRobin {
+	return &weightedRoundRobin{
+		weights: weights,
+		current: make(map[string]int, len(weights)),
+	}
+}
+
+// newPoolRoundRobin weights each member by its untainted nodes when the pool was planned
+func newPoolRoundRobin(members []*NodeGroupState) *weightedRoundRobin {
+	weights := make(map[string]int, len(members))
+	for _, member := range members {
+		weights[member.Opts.Name] = member.untaintedNodeCount
+	}
+	return newWeightedRoundRobin(weights)
+}
+
+// next picks one of the eligible keys. Keys that aren't eligible sit the pick out, so their share goes to the rest in
+// proportion to their weights. When every weight is 0 the first eligible key is picked
+func (w *weightedRoundRobin) next(eligible []string) string {
+	chosen := -1
+	var total int
+	for i, key := range eligible {
+		w.current[key] += w.weights[key]
+		total += w.weights[key]
+		if chosen < 0 || w.current[key] > w.current[eligible[chosen]] {
+			chosen = i
+		}
+	}
+	w.current[eligible[chosen]] -= total
+	return eligible[chosen]
+}
+
+// nextMember picks one of the eligible members of the pool. The share of a node group at its min_nodes or max_nodes
+// goes to the rest, and when every member has no untainted nodes, e.g. a pool scaling up from 0, the first eligible
+// member is picked
+func (w *weightedRoundRobin) nextMember(eligible []*NodeGroupState) *NodeGroupState {
+	names := make([]string, 0, len(eligible))
+	for _, member := range eligible {
+		names = append(names, member.Opts.Name)
+	}
+	name := w.next(names)
+	for _, member := range eligible {
+		if member.Opts.Name == name {
+			return member
+		}
+	}
+	return nil
+}
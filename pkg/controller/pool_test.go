@@ -0,0 +1,210 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/atlassian/escalator/pkg/k8s"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// buildPoolMember builds the state of a pooled node group as of its latest run, with nodes of 1 cpu and 1Gi
+func buildPoolMember(name string, untaintedNodes int, cpuRequest string, minNodes int, maxNodes int) *NodeGroupState {
+	return &NodeGroupState{
+		Opts: NodeGroupOptions{
+			Name:                               name,
+			Pool:                               "shared",
+			MinNodes:                           minNodes,
+			MaxNodes:                           maxNodes,
+			TaintUpperCapacityThresholdPercent: 40,
+			TaintLowerCapacityThresholdPercent: 10,
+			ScaleUpThresholdPercent:            70,
+			SlowNodeRemovalRate:                1,
+			FastNodeRemovalRate:                3,
+		},
+		cpuCapacity:          resource.MustParse("1"),
+		memCapacity:          resource.MustParse("1Gi"),
+		untaintedCPUCapacity: *resource.NewQuantity(int64(untaintedNodes), resource.DecimalSI),
+		untaintedMemCapacity: *resource.NewQuantity(int64(untaintedNodes)*1024*1024*1024, resource.BinarySI),
+		cpuRequest:           resource.MustParse(cpuRequest),
+		memRequest:           resource.MustParse("0"),
+		nodeCount:            untaintedNodes,
+		untaintedNodeCount:   untaintedNodes,
+	}
+}
+
+func TestPlanPool(t *testing.T) {
+	t.Run("scale up balanced", func(t *testing.T) {
+		// 6 cpu requested of 6 across the pool, 9 nodes are needed to be back at 70%
+		a := buildPoolMember("a", 1, "3", 1, 10)
+		b := buildPoolMember("b", 5, "3", 1, 10)
+		plan, err := planPool(a, []*NodeGroupState{a, b})
+		require.NoError(t, err)
+		assert.Equal(t, float64(100), plan.cpuPercent)
+		assert.Equal(t, map[string]int{"a": 3}, plan.deltas)
+	})
+
+	t.Run("scale up balanced evens out", func(t *testing.T) {
+		a := buildPoolMember("a", 2, "4", 1, 10)
+		b := buildPoolMember("b", 2, "4", 1, 10)
+		plan, err := planPool(b, []*NodeGroupState{a, b})
+		require.NoError(t, err)
+		// 8 cpu needs 12 nodes to be at 70%, 8 more split evenly
+		assert.Equal(t, map[string]int{"a": 4, "b": 4}, plan.deltas)
+	})
+
+	t.Run("scale up priority", func(t *testing.T) {
+		a := buildPoolMember("a", 2, "4", 1, 4)
+		b := buildPoolMember("b", 2, "4", 1, 10)
+		a.Opts.PoolScalePolicy = PoolScalePolicyPriority
+		plan, err := planPool(a, []*NodeGroupState{a, b})
+		require.NoError(t, err)
+		// a is filled up to its max_nodes first
+		assert.Equal(t, map[string]int{"a": 2, "b": 6}, plan.deltas)
+	})
+
+	t.Run("scale down balanced", func(t *testing.T) {
+		// 0.2 cpu requested of 9, below the lower threshold
+		a := buildPoolMember("a", 4, "100m", 1, 10)
+		b := buildPoolMember("b", 5, "100m", 1, 10)
+		plan, err := planPool(a, []*NodeGroupState{a, b})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]int{"a": -1, "b": -2}, plan.deltas)
+	})
+
+	t.Run("scale down priority respects min_nodes", func(t *testing.T) {
+		a := buildPoolMember("a", 3, "100m", 1, 10)
+		b := buildPoolMember("b", 2, "100m", 1, 10)
+		a.Opts.PoolScalePolicy = PoolScalePolicyPriority
+		plan, err := planPool(a, []*NodeGroupState{a, b})
+		require.NoError(t, err)
+		// b is emptied down to its min_nodes first
+		assert.Equal(t, map[string]int{"a": -2, "b": -1}, plan.deltas)
+	})
+
+	t.Run("scale down respects reservations", func(t *testing.T) {
+		a := buildPoolMember("a", 4, "100m", 1, 10)
+		b := buildPoolMember("b", 5, "100m", 1, 10)
+		now := time.Now().UTC()
+		b.Opts.Reservations = []Reservation{{
+			Name:  "load-test",
+			Nodes: 4,
+			From:  now.Add(-time.Hour).Format(time.RFC3339),
+			Until: now.Add(time.Hour).Format(time.RFC3339),
+		}}
+		plan, err := planPool(a, []*NodeGroupState{a, b})
+		require.NoError(t, err)
+		// b is held at its min_nodes plus the reserved nodes
+		assert.Equal(t, map[string]int{"a": -3}, plan.deltas)
+	})
+
+	t.Run("scale down proportional", func(t *testing.T) {
+		// 0.2 cpu requested of 12, below the lower threshold, with 3 nodes to remove
+		a := buildPoolMember("a", 4, "100m", 1, 10)
+		b := buildPoolMember("b", 8, "100m", 1, 10)
+		a.Opts.PoolScalePolicy = PoolScalePolicyProportional
+		plan, err := planPool(a, []*NodeGroupState{a, b})
+		require.NoError(t, err)
+		// balanced would take all 3 from b, proportional keeps b at twice the size of a
+		assert.Equal(t, map[string]int{"a": -1, "b": -2}, plan.deltas)
+	})
+
+	t.Run("scale down proportional respects min_nodes", func(t *testing.T) {
+		a := buildPoolMember("a", 2, "100m", 2, 10)
+		b := buildPoolMember("b", 6, "100m", 1, 10)
+		a.Opts.PoolScalePolicy = PoolScalePolicyProportional
+		plan, err := planPool(a, []*NodeGroupState{a, b})
+		require.NoError(t, err)
+		// a is at its min_nodes so its share goes to b
+		assert.Equal(t, map[string]int{"b": -3}, plan.deltas)
+	})
+
+	t.Run("scale up proportional", func(t *testing.T) {
+		// 9 cpu requested of 6 across the pool, 13 nodes are needed to be back at 70%
+		a := buildPoolMember("a", 2, "4.5", 1, 20)
+		b := buildPoolMember("b", 4, "4.5", 1, 20)
+		a.Opts.PoolScalePolicy = PoolScalePolicyProportional
+		plan, err := planPool(a, []*NodeGroupState{a, b})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]int{"a": 2, "b": 5}, plan.deltas)
+	})
+
+	t.Run("within thresholds", func(t *testing.T) {
+		a := buildPoolMember("a", 2, "1", 1, 10)
+		b := buildPoolMember("b", 2, "1", 1, 10)
+		plan, err := planPool(a, []*NodeGroupState{a, b})
+		require.NoError(t, err)
+		assert.Equal(t, float64(50), plan.cpuPercent)
+		assert.Empty(t, plan.deltas)
+	})
+}
+
+func TestPoolNodesDelta_PlannedOncePerRun(t *testing.T) {
+	a := buildPoolMember("a", 1, "3", 1, 10)
+	b := buildPoolMember("b", 5, "3", 1, 10)
+	c := &Controller{
+		Opts:       Opts{NodeGroups: []NodeGroupOptions{a.Opts, b.Opts}},
+		nodeGroups: map[string]*NodeGroupState{"a": a, "b": b},
+	}
+
+	delta, err := c.poolNodesDelta(a)
+	require.NoError(t, err)
+	assert.Equal(t, 3, delta)
+
+	// the node group scaling up doesn't change the plan for the rest of the pool in the same run
+	a.untaintedNodeCount = 4
+	delta, err = c.poolNodesDelta(b)
+	require.NoError(t, err)
+	assert.Equal(t, 0, delta)
+
+	// once the new nodes are observed the pool is within its thresholds
+	c.resetPoolPlans()
+	a.untaintedCPUCapacity = resource.MustParse("4")
+	delta, err = c.poolNodesDelta(b)
+	require.NoError(t, err)
+	assert.Equal(t, 0, delta)
+}
+
+func TestOrderByExpanderPriority(t *testing.T) {
+	priorities, err := k8s.ParseExpanderPriorities("10:\n  - .*-spot\n20:\n  - ^reserved-.*\n")
+	require.NoError(t, err)
+	a := buildPoolMember("a", 1, "1", 0, 10)
+	b := buildPoolMember("b", 1, "1", 0, 10)
+	c := buildPoolMember("c", 1, "1", 0, 10)
+	d := buildPoolMember("d", 1, "1", 0, 10)
+	a.Opts.CloudProviderGroupName = "on-demand"
+	b.Opts.CloudProviderGroupName = "batch-spot"
+	c.Opts.CloudProviderGroupName = "reserved-batch"
+	d.Opts.CloudProviderGroupName = "shared-spot"
+	members := []*NodeGroupState{a, b, c, d}
+
+	// unmatched members go last, ties stay in config order
+	assert.Equal(t, []*NodeGroupState{c, b, d, a}, orderByExpanderPriority(members, priorities))
+	assert.Equal(t, members, orderByExpanderPriority(members, nil))
+}
+
+func TestPoolNodesDelta_ExpanderPriority(t *testing.T) {
+	// 6 cpu requested of 6 across the pool, 9 nodes are needed to be back at 70%
+	a := buildPoolMember("a", 3, "3", 1, 10)
+	b := buildPoolMember("b", 3, "3", 1, 10)
+	a.Opts.PoolScalePolicy = PoolScalePolicyPriority
+	b.Opts.PoolScalePolicy = PoolScalePolicyPriority
+	a.Opts.CloudProviderGroupName = "on-demand"
+	b.Opts.CloudProviderGroupName = "spot"
+	priorities, err := k8s.ParseExpanderPriorities("10:\n  - spot\n1:\n  - .*\n")
+	require.NoError(t, err)
+	c := &Controller{
+		Opts:               Opts{NodeGroups: []NodeGroupOptions{a.Opts, b.Opts}},
+		nodeGroups:         map[string]*NodeGroupState{"a": a, "b": b},
+		expanderPriorities: priorities,
+	}
+
+	delta, err := c.poolNodesDelta(a)
+	require.NoError(t, err)
+	assert.Equal(t, 0, delta)
+	delta, err = c.poolNodesDelta(b)
+	require.NoError(t, err)
+	assert.Equal(t, 3, delta)
+}
@@ -0,0 +1,158 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/atlassian/escalator/pkg/events"
+	"github.com/atlassian/escalator/pkg/k8s"
+	"github.com/atlassian/escalator/pkg/metrics"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/stephanos/clock"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	// PreDeleteHookFailOpen deletes the node when the pre delete hook can't be reached or fails
+	PreDeleteHookFailOpen = "fail_open"
+	// PreDeleteHookFailClosed keeps the node until the pre delete hook answers
+	PreDeleteHookFailClosed = "fail_closed"
+
+	// defaultPreDeleteHookTimeout is the timeout of a pre delete hook request when pre_delete_hook.timeout isn't set
+	defaultPreDeleteHookTimeout = 10 * time.Second
+)
+
+// the results of a pre delete hook call, as exported by the node_group_pre_delete_hook_results metric
+const (
+	preDeleteHookAllowed      = "allowed"
+	preDeleteHookVetoed       = "vetoed"
+	preDeleteHookDelayed      = "delayed"
+	preDeleteHookFailedOpen   = "failed_open"
+	preDeleteHookFailedClosed = "failed_closed"
+)
+
+// preDeleteHookClient sends the pre delete hook requests, the timeout comes from the request context
+var preDeleteHookClient = &http.Client{}
+
+// PreDeleteHookRequest is the body POSTed to the pre delete hook for a node that is about to be deleted
+type PreDeleteHookRequest = events.PreDeleteHookRequest
+
+// PreDeleteHookResponse is the answer of the pre delete hook
+type PreDeleteHookResponse = events.PreDeleteHookResponse
+
+// preDeleteHookAllows calls the pre_delete_hook of the node group for the node and returns whether it can be deleted.
+// A node whose deletion was delayed by the hook isn't deleted, and the hook isn't called, until the delay has passed
+func (c *Controller) preDeleteHookAllows(ctx context.Context, nodeGroup *NodeGroupState, node *v1.Node) bool {
+	hookOpts := nodeGroup.Opts.PreDeleteHook
+	if len(hookOpts.URL) == 0 {
+		return true
+	}
+	logger := log.WithField("nodegroup", nodeGroup.Opts.Name).WithField("node", node.Name)
+
+	now := clock.Now()
+	if retryAt, delayed := nodeGroup.preDeleteHookDelays[node.Name]; delayed {
+		if now.Before(retryAt) {
+			logger.Debugf("pre delete hook delayed the deletion. Time remaining %v", retryAt.Sub(now))
+			return false
+		}
+		delete(nodeGroup.preDeleteHookDelays, node.Name)
+	}
+
+	var podsRemaining []string
+	for _, pod := range k8s.NodeReschedulablePods(node, nodeGroup.NodeInfoMap) {
+		podsRemaining = append(podsRemaining, fmt.Sprintf("%v/%v", pod.Namespace, pod.Name))
+	}
+	request := PreDeleteHookRequest{
+		Version:       events.Version,
+		NodeGroup:     nodeGroup.Opts.Name,
+		Node:          node.Name,
+		ProviderID:    node.Spec.ProviderID,
+		PodsRemaining: podsRemaining,
+		CycleID:       nodeGroup.cycle.ID,
+	}
+
+	response, err := callPreDeleteHook(ctx, hookOpts.URL, nodeGroup.Opts.PreDeleteHookTimeoutDuration(), request)
+	if err != nil {
+		if hookOpts.FailurePolicy == PreDeleteHookFailOpen {
+			logger.WithError(err).Warning("pre delete hook failed, deleting the node anyway")
+			metrics.NodeGroupPreDeleteHookResults.WithLabelValues(nodeGroup.Opts.Name, preDeleteHookFailedOpen).Add(1.0)
+			return true
+		}
+		logger.WithError(err).Error("pre delete hook failed, retrying next run")
+		metrics.NodeGroupPreDeleteHookResults.WithLabelValues(nodeGroup.Opts.Name, preDeleteHookFailedClosed).Add(1.0)
+		return false
+	}
+
+	if response.Allow {
+		logger.Info("pre delete hook allowed the deletion")
+		metrics.NodeGroupPreDeleteHookResults.WithLabelValues(nodeGroup.Opts.Name, preDeleteHookAllowed).Add(1.0)
+		return true
+	}
+
+	retryAfter, _ := time.ParseDuration(response.RetryAfter)
+	if retryAfter > 0 {
+		if nodeGroup.preDeleteHookDelays == nil {
+			nodeGroup.preDeleteHookDelays = make(map[string]time.Time)
+		}
+		nodeGroup.preDeleteHookDelays[node.Name] = now.Add(retryAfter)
+		logger.Infof("pre delete hook delayed the deletion by %v: %v", retryAfter, response.Reason)
+		metrics.NodeGroupPreDeleteHookResults.WithLabelValues(nodeGroup.Opts.Name, preDeleteHookDelayed).Add(1.0)
+		return false
+	}
+	logger.Infof("pre delete hook vetoed the deletion: %v", response.Reason)
+	metrics.NodeGroupPreDeleteHookResults.WithLabelValues(nodeGroup.Opts.Name, preDeleteHookVetoed).Add(1.0)
+	return false
+}
+
+// callPreDeleteHook POSTs the request to the hook URL and decodes its response. Any response that isn't a 2xx status
+// with a valid body is an error
+func callPreDeleteHook(ctx context.Context, url string, timeout time.Duration, request PreDeleteHookRequest) (PreDeleteHookResponse, error) {
+	var response PreDeleteHookResponse
+	body, err := json.Marshal(request)
+	if err != nil {
+		return response, errors.Wrap(err, "failed to encode the pre delete hook request")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	httpRequest, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return response, errors.Wrap(err, "failed to build the pre delete hook request")
+	}
+	httpRequest.Header.Set("Content-Type", events.ContentType)
+
+	httpResponse, err := preDeleteHookClient.Do(httpRequest.WithContext(ctx))
+	if err != nil {
+		return response, errors.Wrap(err, "failed to call the pre delete hook")
+	}
+	defer httpResponse.Body.Close()
+
+	if httpResponse.StatusCode < 200 || httpResponse.StatusCode > 299 {
+		return response, errors.Errorf("pre delete hook returned status %v", httpResponse.StatusCode)
+	}
+	if err := json.NewDecoder(httpResponse.Body).Decode(&response); err != nil {
+		return response, errors.Wrap(err, "failed to decode the pre delete hook response")
+	}
+	return response, nil
+}
+
+// prunePreDeleteHookDelays forgets the delays of the nodes that are no longer tainted, e.g. because they were untainted
+func prunePreDeleteHookDelays(nodeGroup *NodeGroupState, taintedNodes []*v1.Node) {
+	if len(nodeGroup.preDeleteHookDelays) == 0 {
+		return
+	}
+	tainted := make(map[string]bool, len(taintedNodes))
+	for _, node := range taintedNodes {
+		tainted[node.Name] = true
+	}
+	for name := range nodeGroup.preDeleteHookDelays {
+		if !tainted[name] {
+			delete(nodeGroup.preDeleteHookDelays, name)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/atlassian/escalator/pkg/events"
+	"github.com/atlassian/escalator/pkg/k8s"
+	"github.com/atlassian/escalator/pkg/test"
+	"github.com/stephanos/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestControllerPreDeleteHookAllows(t *testing.T) {
+	node := test.BuildTestNode(test.NodeOpts{Name: "n1", Tainted: true})
+	pod := test.BuildTestPod(test.PodOpts{Name: "job", Namespace: "batch", NodeName: "n1"})
+
+	tests := []struct {
+		name          string
+		status        int
+		response      PreDeleteHookResponse
+		failurePolicy string
+		delayedFor    time.Duration
+		want          bool
+		wantCalls     int
+		wantDelayed   bool
+	}{
+		{"allowed", http.StatusOK, PreDeleteHookResponse{Allow: true}, "", 0, true, 1, false},
+		{"vetoed", http.StatusOK, PreDeleteHookResponse{Reason: "seats in use"}, "", 0, false, 1, false},
+		{"delayed", http.StatusOK, PreDeleteHookResponse{Reason: "seats in use", RetryAfter: "5m"}, "", 0, false, 1, true},
+		{"still delayed", http.StatusOK, PreDeleteHookResponse{Allow: true}, "", time.Minute, false, 0, true},
+		{"delay passed", http.StatusOK, PreDeleteHookResponse{Allow: true}, "", -time.Minute, true, 1, false},
+		{"failed closed", http.StatusInternalServerError, PreDeleteHookResponse{}, PreDeleteHookFailClosed, 0, false, 1, false},
+		{"failed closed by default", http.StatusInternalServerError, PreDeleteHookResponse{}, "", 0, false, 1, false},
+		{"failed open", http.StatusInternalServerError, PreDeleteHookResponse{}, PreDeleteHookFailOpen, 0, true, 1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				var request PreDeleteHookRequest
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+				assert.Equal(t, events.Version, request.Version)
+				assert.Equal(t, "default", request.NodeGroup)
+				assert.Equal(t, "n1", request.Node)
+				assert.Equal(t, []string{"batch/job"}, request.PodsRemaining)
+
+				w.WriteHeader(tt.status)
+				json.NewEncoder(w).Encode(tt.response)
+			}))
+			defer server.Close()
+
+			nodeGroup := &NodeGroupState{
+				Opts: NodeGroupOptions{
+					Name:          "default",
+					PreDeleteHook: PreDeleteHookOptions{URL: server.URL, FailurePolicy: tt.failurePolicy},
+				},
+				NodeInfoMap: k8s.CreateNodeNameToInfoMap([]*v1.Pod{pod}, []*v1.Node{node}),
+			}
+			if tt.delayedFor != 0 {
+				nodeGroup.preDeleteHookDelays = map[string]time.Time{"n1": clock.Now().Add(tt.delayedFor)}
+			}
+
+			c := &Controller{}
+			assert.Equal(t, tt.want, c.preDeleteHookAllows(context.Background(), nodeGroup, node))
+			assert.Equal(t, tt.wantCalls, calls)
+			_, delayed := nodeGroup.preDeleteHookDelays["n1"]
+			assert.Equal(t, tt.wantDelayed, delayed)
+		})
+	}
+}
+
+func TestControllerPreDeleteHookAllows_Timeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		json.NewEncoder(w).Encode(PreDeleteHookResponse{Allow: true})
+	}))
+	defer server.Close()
+
+	node := test.BuildTestNode(test.NodeOpts{Name: "n1", Tainted: true})
+	for _, policy := range []string{PreDeleteHookFailOpen, PreDeleteHookFailClosed} {
+		nodeGroup := &NodeGroupState{
+			Opts: NodeGroupOptions{
+				Name:          "default",
+				PreDeleteHook: PreDeleteHookOptions{URL: server.URL, Timeout: "50ms", FailurePolicy: policy},
+			},
+		}
+		c := &Controller{}
+		assert.Equal(t, policy == PreDeleteHookFailOpen, c.preDeleteHookAllows(context.Background(), nodeGroup, node), policy)
+	}
+}
+
+func TestControllerPreDeleteHookAllows_NoHook(t *testing.T) {
+	c := &Controller{}
+	nodeGroup := &NodeGroupState{Opts: NodeGroupOptions{Name: "default"}}
+	assert.True(t, c.preDeleteHookAllows(context.Background(), nodeGroup, test.BuildTestNode(test.NodeOpts{Name: "n1"})))
+}
+
+func TestPrunePreDeleteHookDelays(t *testing.T) {
+	nodeGroup := &NodeGroupState{preDeleteHookDelays: map[string]time.Time{"tainted": time.Now(), "untainted": time.Now()}}
+	prunePreDeleteHookDelays(nodeGroup, []*v1.Node{test.BuildTestNode(test.NodeOpts{Name: "tainted", Tainted: true})})
+	assert.Len(t, nodeGroup.preDeleteHookDelays, 1)
+	assert.Contains(t, nodeGroup.preDeleteHookDelays, "tainted")
+}
@@ -0,0 +1,44 @@
+package controller
+
+import (
+	"strings"
+
+	"github.com/atlassian/escalator/pkg/k8s"
+	v1 "k8s.io/api/core/v1"
+)
+
+// protectedPods returns the running pods on the node, except for DaemonSet pods, that are in one of the protected
+// namespaces or have one of the protected priority classes of the node group
+func protectedPods(nodeGroup *NodeGroupState, node *v1.Node) []*v1.Pod {
+	protected := nodeGroup.Opts.ProtectedPods
+	if len(protected.Namespaces) == 0 && len(protected.PriorityClasses) == 0 {
+		return nil
+	}
+
+	var pods []*v1.Pod
+	for _, pod := range k8s.NodeReschedulablePods(node, nodeGroup.NodeInfoMap) {
+		if pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+			continue
+		}
+		if containsString(protected.Namespaces, pod.Namespace) ||
+			(len(pod.Spec.PriorityClassName) > 0 && containsString(protected.PriorityClasses, pod.Spec.PriorityClassName)) {
+			pods = append(pods, pod)
+		}
+	}
+	return pods
+}
+
+// protectedPodsBlockDeletion returns whether the node can't be deleted because of its protected pods, and alerts
+// through the protected_pods guardrail if so. The node stays tainted until the pods finish or are moved by hand
+func (c *Controller) protectedPodsBlockDeletion(nodeGroup *NodeGroupState, node *v1.Node) bool {
+	pods := protectedPods(nodeGroup, node)
+	if len(pods) == 0 {
+		return false
+	}
+	names := make([]string, 0, len(pods))
+	for _, pod := range pods {
+		names = append(names, pod.Namespace+"/"+pod.Name)
+	}
+	c.recordGuardrail(nodeGroup, GuardrailProtectedPods, "not deleting node %v past its hard delete grace period, it is running protected pods %v", node.Name, strings.Join(names, ", "))
+	return true
+}
@@ -0,0 +1,78 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/atlassian/escalator/pkg/k8s"
+	"github.com/atlassian/escalator/pkg/test"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestProtectedPods(t *testing.T) {
+	node := test.BuildTestNode(test.NodeOpts{Name: "n1"})
+	buildPod := func(name string, namespace string, priorityClass string, owner string) *v1.Pod {
+		pod := test.BuildTestPod(test.PodOpts{Name: name, Namespace: namespace, NodeName: "n1", Owner: owner})
+		pod.Spec.PriorityClassName = priorityClass
+		return pod
+	}
+	coordinator := buildPod("coordinator", "checkpoint", "", "")
+	critical := buildPod("critical", "default", "checkpoint-critical", "")
+	other := buildPod("other", "default", "", "")
+	daemonSet := buildPod("agent", "checkpoint", "", "DaemonSet")
+	finished := buildPod("finished", "checkpoint", "", "")
+	finished.Status.Phase = v1.PodSucceeded
+	pods := []*v1.Pod{coordinator, critical, other, daemonSet, finished}
+
+	tests := []struct {
+		name      string
+		protected ProtectedPodsOptions
+		want      []*v1.Pod
+	}{
+		{"nothing protected", ProtectedPodsOptions{}, nil},
+		{"namespaces", ProtectedPodsOptions{Namespaces: []string{"checkpoint"}}, []*v1.Pod{coordinator}},
+		{"priority classes", ProtectedPodsOptions{PriorityClasses: []string{"checkpoint-critical"}}, []*v1.Pod{critical}},
+		{
+			"both",
+			ProtectedPodsOptions{Namespaces: []string{"checkpoint"}, PriorityClasses: []string{"checkpoint-critical"}},
+			[]*v1.Pod{coordinator, critical},
+		},
+		{"no match", ProtectedPodsOptions{Namespaces: []string{"kube-system"}}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodeGroup := &NodeGroupState{
+				Opts:        NodeGroupOptions{Name: "default", ProtectedPods: tt.protected},
+				NodeInfoMap: k8s.CreateNodeNameToInfoMap(pods, []*v1.Node{node}),
+			}
+			assert.ElementsMatch(t, tt.want, protectedPods(nodeGroup, node))
+		})
+	}
+}
+
+func TestProtectedPodsBlockDeletion(t *testing.T) {
+	node := test.BuildTestNode(test.NodeOpts{Name: "n1"})
+	pod := test.BuildTestPod(test.PodOpts{Name: "coordinator", Namespace: "checkpoint", NodeName: "n1"})
+	nodeGroup := &NodeGroupState{
+		Opts: NodeGroupOptions{
+			Name:          "default",
+			ProtectedPods: ProtectedPodsOptions{Namespaces: []string{"checkpoint"}},
+		},
+		NodeInfoMap: k8s.CreateNodeNameToInfoMap([]*v1.Pod{pod}, []*v1.Node{node}),
+	}
+	recorder := record.NewFakeRecorder(1)
+	c := &Controller{Opts: Opts{
+		EventRecorder: recorder,
+		EventObject:   &v1.ObjectReference{Kind: "Pod", Namespace: "kube-system", Name: "escalator"},
+	}}
+
+	assert.True(t, c.protectedPodsBlockDeletion(nodeGroup, node))
+	assert.Equal(t,
+		"Warning ProtectedPodsBlockDeletion node group default: not deleting node n1 past its hard delete grace period, it is running protected pods checkpoint/coordinator",
+		<-recorder.Events,
+	)
+
+	nodeGroup.Opts.ProtectedPods = ProtectedPodsOptions{}
+	assert.False(t, c.protectedPodsBlockDeletion(nodeGroup, node))
+}
@@ -0,0 +1,121 @@
+package controller
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// ProviderWriteLimits rate limits the calls that change the cloud provider node groups, i.e. resizing them and
+// terminating their instances, so a scale event across every node group doesn't get the cloud provider API throttled
+type ProviderWriteLimits struct {
+	// QPS is the rate of the writes shared by all of the node groups. 0 disables the limits
+	QPS float64
+	// Burst is the most writes made at once after a quiet period
+	Burst int
+	// GroupSharePercent is the most of the rate and burst a single node group can take, so the other node groups
+	// still have writes left during its scale event. 100 lets a node group take all of it
+	GroupSharePercent int
+}
+
+// providerWriteLimiter is a token bucket shared by the node groups, with a smaller bucket for each node group. Each
+// write takes a token from both
+type providerWriteLimiter struct {
+	limits ProviderWriteLimits
+	shared *rate.Limiter
+
+	lock   sync.Mutex
+	groups map[string]*rate.Limiter
+}
+
+// newProviderWriteLimiter returns the limiter of the writes, nil when the limits are disabled
+func newProviderWriteLimiter(limits ProviderWriteLimits) *providerWriteLimiter {
+	if limits.QPS <= 0 {
+		return nil
+	}
+	if limits.Burst < 1 {
+		limits.Burst = 1
+	}
+	if limits.GroupSharePercent <= 0 || limits.GroupSharePercent > 100 {
+		limits.GroupSharePercent = 100
+	}
+	return &providerWriteLimiter{
+		limits: limits,
+		shared: rate.NewLimiter(rate.Limit(limits.QPS), limits.Burst),
+		groups: make(map[string]*rate.Limiter),
+	}
+}
+
+// groupLimiter returns the bucket of the node group, created on its first write
+func (l *providerWriteLimiter) groupLimiter(nodeGroup string) *rate.Limiter {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	limiter, ok := l.groups[nodeGroup]
+	if !ok {
+		share := float64(l.limits.GroupSharePercent) / 100
+		burst := int(math.Ceil(float64(l.limits.Burst) * share))
+		limiter = rate.NewLimiter(rate.Limit(l.limits.QPS*share), burst)
+		l.groups[nodeGroup] = limiter
+	}
+	return limiter
+}
+
+// take takes up to n tokens for writes of the node group at the time, one for each write, and returns how many it
+// took. The writes that didn't get a token are retried on a later run. Everything is allowed without limits
+func (l *providerWriteLimiter) take(nodeGroup string, n int, now time.Time) int {
+	if l == nil {
+		return n
+	}
+	group := l.groupLimiter(nodeGroup)
+	taken := 0
+	for ; taken < n; taken++ {
+		groupToken := group.ReserveN(now, 1)
+		if !groupToken.OK() || groupToken.DelayFrom(now) > 0 {
+			groupToken.CancelAt(now)
+			break
+		}
+		sharedToken := l.shared.ReserveN(now, 1)
+		if !sharedToken.OK() || sharedToken.DelayFrom(now) > 0 {
+			sharedToken.CancelAt(now)
+			groupToken.CancelAt(now)
+			break
+		}
+	}
+	return taken
+}
+
+// forget drops the buckets of the node groups that are no longer configured
+func (l *providerWriteLimiter) forget(nodeGroups map[string]*NodeGroupState) {
+	if l == nil {
+		return
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	for name := range l.groups {
+		if _, ok := nodeGroups[name]; !ok {
+			delete(l.groups, name)
+		}
+	}
+}
+
+// allowProviderResize returns whether the node group can resize its cloud provider node group now, and activates the
+// provider_write_limit guardrail when it can't
+func (c *Controller) allowProviderResize(nodeGroup *NodeGroupState) bool {
+	if c.providerWrites.take(nodeGroup.Opts.Name, 1, time.Now()) == 1 {
+		return true
+	}
+	c.recordGuardrail(nodeGroup, GuardrailProviderWriteLimit, "resize of the cloud provider node group deferred to a later run by the provider write limits")
+	return false
+}
+
+// allowProviderDeletion returns whether the node group can terminate another instance now, and activates the
+// provider_write_limit guardrail when it can't
+func (c *Controller) allowProviderDeletion(nodeGroup *NodeGroupState) bool {
+	if c.providerWrites.take(nodeGroup.Opts.Name, 1, time.Now()) == 1 {
+		return true
+	}
+	c.recordGuardrail(nodeGroup, GuardrailProviderWriteLimit, "node deletions deferred to a later run by the provider write limits")
+	return false
+}
@@ -0,0 +1,51 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProviderWriteLimiter(t *testing.T) {
+	now := time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC)
+	limiter := newProviderWriteLimiter(ProviderWriteLimits{QPS: 2, Burst: 20, GroupSharePercent: 50})
+
+	// a node group takes at most its share of the burst
+	assert.Equal(t, 10, limiter.take("batch", 15, now))
+	assert.Equal(t, 0, limiter.take("batch", 1, now))
+	// leaving the rest to the other node groups
+	assert.Equal(t, 10, limiter.take("shared", 10, now))
+	assert.Equal(t, 0, limiter.take("cache", 1, now))
+
+	// the node group bucket refills at its share of the rate, when the shared bucket has room
+	assert.Equal(t, 1, limiter.take("batch", 5, now.Add(time.Second)))
+	assert.Equal(t, 1, limiter.take("cache", 5, now.Add(time.Second)))
+	assert.Equal(t, 0, limiter.take("shared", 5, now.Add(time.Second)))
+
+	// the buckets of removed node groups are forgotten
+	limiter.forget(map[string]*NodeGroupState{"batch": {}})
+	assert.Len(t, limiter.groups, 1)
+}
+
+func TestProviderWriteLimiter_Disabled(t *testing.T) {
+	limiter := newProviderWriteLimiter(ProviderWriteLimits{Burst: 1})
+	assert.Nil(t, limiter)
+	assert.Equal(t, 100, limiter.take("batch", 100, time.Now()))
+	limiter.forget(nil)
+}
+
+func TestAllowProviderDeletion(t *testing.T) {
+	nodeGroup := &NodeGroupState{Opts: NodeGroupOptions{Name: "default"}}
+
+	c := &Controller{}
+	for i := 0; i < 3; i++ {
+		assert.True(t, c.allowProviderDeletion(nodeGroup))
+	}
+
+	c.providerWrites = newProviderWriteLimiter(ProviderWriteLimits{QPS: 0.01, Burst: 2, GroupSharePercent: 100})
+	assert.True(t, c.allowProviderDeletion(nodeGroup))
+	assert.True(t, c.allowProviderDeletion(nodeGroup))
+	assert.False(t, c.allowProviderDeletion(nodeGroup))
+	assert.False(t, c.allowProviderResize(nodeGroup))
+}
@@ -0,0 +1,42 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/atlassian/escalator/pkg/k8s"
+	"github.com/pkg/errors"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// QueueDemandProviderKueue reads the demand of the Workloads waiting in a Kueue ClusterQueue
+	QueueDemandProviderKueue = "kueue"
+	// QueueDemandProviderVolcano reads the demand of the PodGroups waiting in a Volcano Queue
+	QueueDemandProviderVolcano = "volcano"
+)
+
+// queueDemandClient returns the rest client used to read the batch job queues. The discovery client is used as it
+// isn't bound to an api group
+func (c *Controller) queueDemandClient() rest.Interface {
+	if c.Opts.K8SClient == nil {
+		return nil
+	}
+	return c.Opts.K8SClient.Discovery().RESTClient()
+}
+
+// queueDemand returns the demand waiting in the batch job queue of the node group
+func (c *Controller) queueDemand(ctx context.Context, nodeGroup *NodeGroupState) (k8s.QueueDemand, error) {
+	client := c.queueDemandClient()
+	if client == nil {
+		return k8s.QueueDemand{}, errors.New("no kubernetes client to read the batch job queue with")
+	}
+
+	switch nodeGroup.Opts.QueueDemand.Provider {
+	case QueueDemandProviderKueue:
+		return k8s.GetKueueClusterQueueDemand(ctx, client, nodeGroup.Opts.QueueDemand.Queue)
+	case QueueDemandProviderVolcano:
+		return k8s.GetVolcanoQueueDemand(ctx, client, nodeGroup.Opts.QueueDemand.Queue)
+	default:
+		return k8s.QueueDemand{}, nil
+	}
+}
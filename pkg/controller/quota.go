@@ -0,0 +1,193 @@
+package controller
+
+import (
+	"context"
+	"sort"
+
+	"github.com/atlassian/escalator/pkg/cloudprovider"
+	"github.com/atlassian/escalator/pkg/errorkind"
+	"github.com/atlassian/escalator/pkg/metrics"
+	log "github.com/sirupsen/logrus"
+)
+
+// limitScaleUpToQuota reduces the nodes to add to what the quotas of the cloud provider account have room for, when
+// the cloud provider node group can check them. With a coordinator the room is shared with the other node groups
+// scaling up against the quota. A scale up with no room at all is blocked with a QuotaBlocked error and the run is
+// recorded as quota blocked. Failing to check the quota doesn't block the scale up
+func limitScaleUpToQuota(ctx context.Context, nodeGroup *NodeGroupState, cloudProviderNodeGroup cloudprovider.NodeGroup, nodesToAdd int64, coordinator *quotaCoordinator, nodeGroups map[string]*NodeGroupState) (int64, error) {
+	checker, ok := cloudProviderNodeGroup.(cloudprovider.QuotaChecker)
+	if !ok {
+		return nodesToAdd, nil
+	}
+
+	nodegroupName := nodeGroup.Opts.Name
+	quota, ok, err := checker.RemainingQuota(ctx)
+	if err != nil {
+		log.WithField("nodegroup", nodegroupName).WithError(err).Warn("Failed to check the cloud provider quota, scaling up anyway")
+		return nodesToAdd, nil
+	}
+	if !ok {
+		return nodesToAdd, nil
+	}
+
+	metrics.NodeGroupQuotaRemainingNodes.WithLabelValues(nodegroupName).Set(float64(quota.RemainingNodes))
+	if coordinator != nil {
+		allowed := coordinator.limit(nodeGroup, nodeGroups, quota, nodesToAdd)
+		if allowed < quota.RemainingNodes {
+			quota.RemainingNodes = allowed
+		}
+	}
+	if nodesToAdd <= quota.RemainingNodes {
+		return nodesToAdd, nil
+	}
+
+	metrics.NodeGroupQuotaBlocked.WithLabelValues(nodegroupName).Add(1.0)
+	if quota.RemainingNodes <= 0 {
+		nodeGroup.cycle.Decision = CycleDecisionQuotaBlocked
+		return 0, errorkind.New(errorkind.QuotaBlocked, "the %v quota has no room for any of the %v nodes to add. Taking no action", quota.Name, nodesToAdd)
+	}
+	log.WithField("nodegroup", nodegroupName).Warningf("the %v quota only has room for %v of the %v nodes to add. Limiting the scale up", quota.Name, quota.RemainingNodes, nodesToAdd)
+	return quota.RemainingNodes, nil
+}
+
+// quotaCoordinator shares the room left in each cloud provider quota between the node groups scaling up against it.
+// The demand of a node group is the room its latest scale up wanted. While the demands of the node groups not yet
+// scaled up in a run don't fit in the room left, each gets a share weighted by its quota_priority, so the node groups
+// evaluated first can't use up the room of those evaluated after them
+type quotaCoordinator struct {
+	// the room each node group wanted from each quota in its latest scale up, in the unit of the quota
+	demands map[string]map[string]int64
+	// the room left in each quota after the scale ups of the current run, in the unit of the quota
+	remaining map[string]int64
+	// the node groups that scaled up in the current run
+	served map[string]bool
+}
+
+// quotaCoordinator returns the quota coordinator, nil unless quota_coordinator is set
+func (c *Controller) quotaCoordinator() *quotaCoordinator {
+	if !c.Opts.Cluster.QuotaCoordinator {
+		return nil
+	}
+	if c.quota == nil {
+		c.quota = &quotaCoordinator{demands: make(map[string]map[string]int64)}
+		c.quota.beginRun()
+	}
+	return c.quota
+}
+
+// beginRun starts a new run, in which every node group can scale up again
+func (q *quotaCoordinator) beginRun() {
+	if q == nil {
+		return
+	}
+	q.remaining = make(map[string]int64)
+	q.served = make(map[string]bool)
+}
+
+// forget drops the demands of the node group, when it is evaluated again or removed from the config
+func (q *quotaCoordinator) forget(nodeGroup string) {
+	if q == nil {
+		return
+	}
+	for _, demands := range q.demands {
+		delete(demands, nodeGroup)
+	}
+}
+
+// limit returns how many of the nodes to add the node group can have from the quota, and records them as used
+func (q *quotaCoordinator) limit(nodeGroup *NodeGroupState, nodeGroups map[string]*NodeGroupState, quota cloudprovider.Quota, nodesToAdd int64) int64 {
+	perNode := quota.PerNode
+	remaining := quota.Remaining
+	if perNode <= 0 {
+		perNode = 1
+		remaining = quota.RemainingNodes
+	}
+	// the quota may not reflect the scale ups earlier in the run yet
+	if left, ok := q.remaining[quota.Name]; ok && left < remaining {
+		remaining = left
+	}
+
+	name := nodeGroup.Opts.Name
+	if q.demands[quota.Name] == nil {
+		q.demands[quota.Name] = make(map[string]int64)
+	}
+	q.demands[quota.Name][name] = nodesToAdd * perNode
+
+	// the demands of the node groups yet to scale up in this run
+	pending := make(map[string]int64)
+	weights := make(map[string]int64)
+	var total int64
+	for demander, demand := range q.demands[quota.Name] {
+		state, ok := nodeGroups[demander]
+		if !ok || q.served[demander] {
+			continue
+		}
+		pending[demander] = demand
+		weights[demander] = state.Opts.quotaPriority()
+		total += demand
+	}
+
+	allowed := nodesToAdd
+	if total > remaining {
+		allowed = shareQuota(remaining, pending, weights)[name] / perNode
+		log.WithField("nodegroup", name).Infof(
+			"the %v quota is shared with other node groups scaling up. Limiting the scale up from %v to %v nodes",
+			quota.Name, nodesToAdd, allowed,
+		)
+	}
+	q.served[name] = true
+	q.remaining[quota.Name] = remaining - allowed*perNode
+	return allowed
+}
+
+// shareQuota splits the room between the demands in proportion to their weights, without giving any demand more than
+// it asked for. The room a demand doesn't need is shared between the others
+func shareQuota(room int64, demands map[string]int64, weights map[string]int64) map[string]int64 {
+	shares := make(map[string]int64, len(demands))
+	active := make([]string, 0, len(demands))
+	for name := range demands {
+		active = append(active, name)
+	}
+	// highest weight first, so the rounding favours them
+	sort.Slice(active, func(i, j int) bool {
+		if weights[active[i]] != weights[active[j]] {
+			return weights[active[i]] > weights[active[j]]
+		}
+		return active[i] < active[j]
+	})
+
+	for len(active) > 0 && room > 0 {
+		var totalWeight int64
+		for _, name := range active {
+			totalWeight += weights[name]
+		}
+		// demands that need less than their share get what they need, and the rest is shared again
+		var unsatisfied []string
+		var used int64
+		for _, name := range active {
+			if demands[name]*totalWeight <= room*weights[name] {
+				shares[name] = demands[name]
+				used += demands[name]
+			} else {
+				unsatisfied = append(unsatisfied, name)
+			}
+		}
+		if len(unsatisfied) == len(active) {
+			left := room
+			for _, name := range active {
+				share := room * weights[name] / totalWeight
+				shares[name] = share
+				left -= share
+			}
+			// the room lost to rounding goes to the highest weights
+			for i := 0; left > 0; i = (i + 1) % len(active) {
+				shares[active[i]]++
+				left--
+			}
+			break
+		}
+		room -= used
+		active = unsatisfied
+	}
+	return shares
+}
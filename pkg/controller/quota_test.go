@@ -0,0 +1,121 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/atlassian/escalator/pkg/cloudprovider"
+	"github.com/atlassian/escalator/pkg/errorkind"
+	"github.com/atlassian/escalator/pkg/test"
+	"github.com/stretchr/testify/assert"
+)
+
+// quotaNodeGroup is a test node group with a cloud provider quota
+type quotaNodeGroup struct {
+	*test.NodeGroup
+	quota cloudprovider.Quota
+	ok    bool
+	err   error
+}
+
+func (n quotaNodeGroup) RemainingQuota(ctx context.Context) (cloudprovider.Quota, bool, error) {
+	return n.quota, n.ok, n.err
+}
+
+func TestLimitScaleUpToQuota(t *testing.T) {
+	testNodeGroup := test.NewNodeGroup("default", 1, 100, 10)
+	tests := []struct {
+		name                   string
+		cloudProviderNodeGroup cloudprovider.NodeGroup
+		want                   int64
+		wantKind               errorkind.Kind
+		wantDecision           string
+	}{
+		{"no quota checker", testNodeGroup, 5, "", CycleDecisionScaleUp},
+		{"quota unknown", quotaNodeGroup{testNodeGroup, cloudprovider.Quota{}, false, nil}, 5, "", CycleDecisionScaleUp},
+		{"quota check failed", quotaNodeGroup{testNodeGroup, cloudprovider.Quota{}, false, errors.New("throttled")}, 5, "", CycleDecisionScaleUp},
+		{"room left", quotaNodeGroup{testNodeGroup, cloudprovider.Quota{Name: "vcpus", RemainingNodes: 8}, true, nil}, 5, "", CycleDecisionScaleUp},
+		{"limited", quotaNodeGroup{testNodeGroup, cloudprovider.Quota{Name: "vcpus", RemainingNodes: 3}, true, nil}, 3, "", CycleDecisionScaleUp},
+		{"blocked", quotaNodeGroup{testNodeGroup, cloudprovider.Quota{Name: "vcpus", RemainingNodes: 0}, true, nil}, 0, errorkind.QuotaBlocked, CycleDecisionQuotaBlocked},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodeGroup := &NodeGroupState{Opts: NodeGroupOptions{Name: "default"}, cycle: CycleSummary{Decision: CycleDecisionScaleUp}}
+
+			got, err := limitScaleUpToQuota(context.Background(), nodeGroup, tt.cloudProviderNodeGroup, 5, nil, nil)
+			assert.Equal(t, tt.want, got)
+			if len(tt.wantKind) > 0 {
+				assert.True(t, errorkind.Is(err, tt.wantKind))
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.wantDecision, nodeGroup.cycle.Decision)
+		})
+	}
+}
+
+func TestShareQuota(t *testing.T) {
+	tests := []struct {
+		name    string
+		room    int64
+		demands map[string]int64
+		weights map[string]int64
+		want    map[string]int64
+	}{
+		{"enough room", 10, map[string]int64{"a": 3, "b": 4}, map[string]int64{"a": 1, "b": 1}, map[string]int64{"a": 3, "b": 4}},
+		{"equal weights", 8, map[string]int64{"a": 6, "b": 6}, map[string]int64{"a": 1, "b": 1}, map[string]int64{"a": 4, "b": 4}},
+		{"weighted", 8, map[string]int64{"a": 8, "b": 8}, map[string]int64{"a": 1, "b": 3}, map[string]int64{"a": 2, "b": 6}},
+		{"unneeded share is shared again", 8, map[string]int64{"a": 6, "b": 6}, map[string]int64{"a": 1, "b": 3}, map[string]int64{"a": 2, "b": 6}},
+		{"small demand", 9, map[string]int64{"a": 1, "b": 8, "c": 8}, map[string]int64{"a": 1, "b": 1, "c": 1}, map[string]int64{"a": 1, "b": 4, "c": 4}},
+		{"rounding favours the highest weight", 5, map[string]int64{"a": 5, "b": 5}, map[string]int64{"a": 1, "b": 2}, map[string]int64{"a": 1, "b": 4}},
+		{"no room", 0, map[string]int64{"a": 5}, map[string]int64{"a": 1}, map[string]int64{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, shareQuota(tt.room, tt.demands, tt.weights))
+		})
+	}
+}
+
+func TestQuotaCoordinator(t *testing.T) {
+	nodeGroups := map[string]*NodeGroupState{
+		"a": {Opts: NodeGroupOptions{Name: "a"}},
+		"b": {Opts: NodeGroupOptions{Name: "b", QuotaPriority: 3}},
+	}
+	quota := cloudprovider.Quota{Name: "vcpus", RemainingNodes: 2, Remaining: 8, PerNode: 4}
+	cloudProviderNodeGroup := quotaNodeGroup{test.NewNodeGroup("default", 1, 100, 10), quota, true, nil}
+	c := &Controller{Opts: Opts{Cluster: ClusterOptions{QuotaCoordinator: true}}, nodeGroups: nodeGroups}
+	coordinator := c.quotaCoordinator()
+
+	// without a demand from b yet, a is first come first served
+	coordinator.beginRun()
+	got, err := limitScaleUpToQuota(context.Background(), nodeGroups["a"], cloudProviderNodeGroup, 2, coordinator, nodeGroups)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), got)
+	got, err = limitScaleUpToQuota(context.Background(), nodeGroups["b"], cloudProviderNodeGroup, 2, coordinator, nodeGroups)
+	assert.True(t, errorkind.Is(err, errorkind.QuotaBlocked))
+	assert.Equal(t, int64(0), got)
+
+	// the next run b's demand is kept room for, even though a is evaluated first
+	coordinator.beginRun()
+	coordinator.forget("a")
+	got, err = limitScaleUpToQuota(context.Background(), nodeGroups["a"], cloudProviderNodeGroup, 2, coordinator, nodeGroups)
+	assert.True(t, errorkind.Is(err, errorkind.QuotaBlocked))
+	assert.Equal(t, int64(0), got)
+	coordinator.forget("b")
+	got, err = limitScaleUpToQuota(context.Background(), nodeGroups["b"], cloudProviderNodeGroup, 2, coordinator, nodeGroups)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), got)
+
+	// demands of node groups removed from the config don't hold any room
+	delete(nodeGroups, "b")
+	coordinator.beginRun()
+	got, err = limitScaleUpToQuota(context.Background(), nodeGroups["a"], cloudProviderNodeGroup, 2, coordinator, nodeGroups)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), got)
+
+	// the coordinator is only used with quota_coordinator
+	c.Opts.Cluster.QuotaCoordinator = false
+	assert.Nil(t, c.quotaCoordinator())
+}
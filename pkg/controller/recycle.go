@@ -0,0 +1,118 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/atlassian/escalator/pkg/k8s"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	// RecycleModeTaint taints expired nodes straight away and lets the node group scale up to replace them
+	RecycleModeTaint = "taint"
+	// RecycleModeProvisionThenTaint brings up a replacement node and waits for it to be ready before tainting
+	// an expired node, so the capacity of the node group never dips while recycling
+	RecycleModeProvisionThenTaint = "provision_then_taint"
+)
+
+// ScaleUpReasonRecycle is the scale up reason recorded on nodes brought up to replace an expired node
+const ScaleUpReasonRecycle = "recycle"
+
+// recycleExpiredNodes replaces the untainted nodes that are older than max_node_age, one node at a time.
+// It is only run when the node group doesn't otherwise need to scale
+func (c *Controller) recycleExpiredNodes(opts scaleOpts) (int, error) {
+	nodeGroup := opts.nodeGroup
+	expired := nodesOlderThan(opts.untaintedNodes, nodeGroup.Opts.MaxNodeAgeDuration(), time.Now())
+	expired = withoutStaticPodNodes(nodeGroup, expired)
+	if len(expired) == 0 {
+		nodeGroup.replacementPending = false
+		return 0, nil
+	}
+	log.WithField("nodegroup", nodeGroup.Opts.Name).Infof("%v nodes are older than the max node age of %v", len(expired), nodeGroup.Opts.MaxNodeAge)
+
+	if nodeGroup.Opts.RecycleMode == RecycleModeProvisionThenTaint && len(opts.nodes) < nodeGroup.Opts.MaxNodes {
+		return c.replaceThenTaint(opts, ScaleUpReasonRecycle)
+	}
+
+	// expired nodes are tainted first whatever the taint_selection_policy, the normal scale up will replace the capacity
+	// if needed
+	opts.nodesDelta = 1
+	return c.scaleDownTaint(opts)
+}
+
+// replaceThenTaint requests a replacement node for the reason on the first call, then taints the oldest node on a later
+// call once the replacement is ready
+func (c *Controller) replaceThenTaint(opts scaleOpts, reason string) (int, error) {
+	nodeGroup := opts.nodeGroup
+	readyNodes := len(readyNodes(opts.untaintedNodes))
+
+	if !nodeGroup.replacementPending {
+		// untaint or add a node. the scale lock holds the node group until the new node has been brought up
+		// the nodes being replaced, e.g. the expired nodes recycled before, aren't untainted as the replacement, or the
+		// node group would untaint and taint them again on every call
+		opts.taintedNodes = withoutPrioritisedNodes(nodeGroup, opts.taintedNodes)
+		opts.nodesDelta = 1
+		opts.reason = reason
+		added, err := c.ScaleUp(opts)
+		if err != nil || added == 0 {
+			return added, err
+		}
+		log.WithField("nodegroup", nodeGroup.Opts.Name).Infof("Requested a replacement node (%v)", reason)
+		nodeGroup.replacementPending = true
+		nodeGroup.replacementReadyNodes = readyNodes + added
+		return added, nil
+	}
+
+	if readyNodes < nodeGroup.replacementReadyNodes {
+		log.WithField("nodegroup", nodeGroup.Opts.Name).Infof(
+			"Waiting for replacement node to be ready before recycling. %v/%v nodes ready",
+			readyNodes,
+			nodeGroup.replacementReadyNodes,
+		)
+		return 0, nil
+	}
+
+	nodeGroup.replacementPending = false
+	opts.nodesDelta = 1
+	return c.scaleDownTaint(opts)
+}
+
+// expired returns whether the node is older than the max_node_age of the node group
+func (nodeGroup *NodeGroupState) expired(node *v1.Node, now time.Time) bool {
+	age := nodeGroup.Opts.MaxNodeAgeDuration()
+	return age > 0 && now.Sub(node.CreationTimestamp.Time) > age
+}
+
+// withoutPrioritisedNodes returns the nodes, except for those that are tainted before the other nodes of the node group
+func withoutPrioritisedNodes(nodeGroup *NodeGroupState, nodes []*v1.Node) []*v1.Node {
+	var filtered []*v1.Node
+	for _, node := range nodes {
+		if !nodeGroup.prioritisedForTainting(node) {
+			filtered = append(filtered, node)
+		}
+	}
+	return filtered
+}
+
+// nodesOlderThan returns the nodes that were created more than age ago
+func nodesOlderThan(nodes []*v1.Node, age time.Duration, now time.Time) []*v1.Node {
+	var old []*v1.Node
+	for _, node := range nodes {
+		if now.Sub(node.CreationTimestamp.Time) > age {
+			old = append(old, node)
+		}
+	}
+	return old
+}
+
+// readyNodes returns the nodes that have the Ready condition
+func readyNodes(nodes []*v1.Node) []*v1.Node {
+	var ready []*v1.Node
+	for _, node := range nodes {
+		if k8s.NodeReady(node) {
+			ready = append(ready, node)
+		}
+	}
+	return ready
+}
@@ -0,0 +1,167 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/atlassian/escalator/pkg/test"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestNodesOlderThan(t *testing.T) {
+	now := time.Date(2019, 3, 3, 12, 0, 0, 0, time.UTC)
+	nodes := []*v1.Node{
+		test.BuildTestNode(test.NodeOpts{Name: "n1", Creation: now.Add(-3 * time.Hour)}),
+		test.BuildTestNode(test.NodeOpts{Name: "n2", Creation: now.Add(-30 * time.Minute)}),
+		test.BuildTestNode(test.NodeOpts{Name: "n3", Creation: now.Add(-2 * time.Hour)}),
+	}
+
+	old := nodesOlderThan(nodes, time.Hour, now)
+	assert.Len(t, old, 2)
+	assert.Equal(t, "n1", old[0].Name)
+	assert.Equal(t, "n3", old[1].Name)
+
+	assert.Empty(t, nodesOlderThan(nodes, 4*time.Hour, now))
+}
+
+func TestControllerRecycleExpiredNodes(t *testing.T) {
+	now := time.Now()
+	nodes := []*v1.Node{
+		test.BuildTestNode(test.NodeOpts{Name: "n1", Creation: now.Add(-30 * time.Minute)}),
+		test.BuildTestNode(test.NodeOpts{Name: "n2", Creation: now.Add(-3 * time.Hour)}),
+		test.BuildTestNode(test.NodeOpts{Name: "n3", Creation: now.Add(-2 * time.Hour)}),
+	}
+
+	t.Run("taint the oldest expired node", func(t *testing.T) {
+		nodeGroup := &NodeGroupState{
+			Opts: NodeGroupOptions{
+				Name:       "buildeng",
+				MinNodes:   1,
+				MaxNodes:   3,
+				MaxNodeAge: "1h",
+			},
+		}
+		c := &Controller{Opts: Opts{DryMode: true}}
+
+		tainted, err := c.recycleExpiredNodes(scaleOpts{nodes: nodes, untaintedNodes: nodes, nodeGroup: nodeGroup, ctx: context.Background()})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, tainted)
+		assert.Equal(t, []string{"n2"}, nodeGroup.dryTaintedNodes())
+	})
+
+	t.Run("taint an expired node whatever the taint selection policy", func(t *testing.T) {
+		nodeGroup := &NodeGroupState{
+			Opts: NodeGroupOptions{
+				Name:                 "buildeng",
+				MinNodes:             1,
+				MaxNodes:             3,
+				MaxNodeAge:           "1h",
+				TaintSelectionPolicy: TaintSelectionPolicyNewest,
+			},
+		}
+		c := &Controller{Opts: Opts{DryMode: true}}
+
+		tainted, err := c.recycleExpiredNodes(scaleOpts{nodes: nodes, untaintedNodes: nodes, nodeGroup: nodeGroup, ctx: context.Background()})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, tainted)
+		assert.Equal(t, []string{"n3"}, nodeGroup.dryTaintedNodes())
+	})
+
+	t.Run("wait for the replacement to be ready", func(t *testing.T) {
+		nodeGroup := &NodeGroupState{
+			Opts: NodeGroupOptions{
+				Name:        "buildeng",
+				MinNodes:    1,
+				MaxNodes:    5,
+				MaxNodeAge:  "1h",
+				RecycleMode: RecycleModeProvisionThenTaint,
+			},
+			replacementPending:    true,
+			replacementReadyNodes: 4,
+		}
+		c := &Controller{Opts: Opts{DryMode: true}}
+
+		tainted, err := c.recycleExpiredNodes(scaleOpts{nodes: nodes, untaintedNodes: nodes, nodeGroup: nodeGroup, ctx: context.Background()})
+		assert.NoError(t, err)
+		assert.Equal(t, 0, tainted)
+		assert.Empty(t, nodeGroup.taintTracker)
+		assert.True(t, nodeGroup.replacementPending)
+	})
+
+	t.Run("nothing to recycle", func(t *testing.T) {
+		nodeGroup := &NodeGroupState{
+			Opts: NodeGroupOptions{
+				Name:       "buildeng",
+				MinNodes:   1,
+				MaxNodes:   3,
+				MaxNodeAge: "24h",
+			},
+			replacementPending: true,
+		}
+		c := &Controller{Opts: Opts{DryMode: true}}
+
+		tainted, err := c.recycleExpiredNodes(scaleOpts{nodes: nodes, untaintedNodes: nodes, nodeGroup: nodeGroup, ctx: context.Background()})
+		assert.NoError(t, err)
+		assert.Equal(t, 0, tainted)
+		assert.False(t, nodeGroup.replacementPending)
+	})
+}
+
+func TestControllerRecycleExpiredNodes_ProvisionThenTaint(t *testing.T) {
+	now := time.Now()
+	// n1 was recycled by an earlier run and is still tainted
+	nodes := []*v1.Node{
+		test.BuildTestNode(test.NodeOpts{Name: "n1", CPU: 1000, Mem: 1000, Creation: now.Add(-4 * time.Hour), Tainted: true}),
+		test.BuildTestNode(test.NodeOpts{Name: "n2", CPU: 1000, Mem: 1000, Creation: now.Add(-3 * time.Hour)}),
+		test.BuildTestNode(test.NodeOpts{Name: "n3", CPU: 1000, Mem: 1000, Creation: now.Add(-2 * time.Hour)}),
+		test.BuildTestNode(test.NodeOpts{Name: "n4", CPU: 1000, Mem: 1000, Creation: now.Add(-30 * time.Minute)}),
+	}
+	nodeGroups := []NodeGroupOptions{{
+		Name:                   "buildeng",
+		CloudProviderGroupName: "buildeng",
+		MinNodes:               1,
+		MaxNodes:               5,
+		MaxNodeAge:             "1h",
+		RecycleMode:            RecycleModeProvisionThenTaint,
+		ScaleUpCoolDownPeriod:  "1m",
+	}}
+	fakeClient, updates := test.BuildFakeClient(nodes, nil)
+	cloudProvider := test.NewCloudProvider(1)
+	cloudProviderNodeGroup := test.NewNodeGroup("buildeng", 1, 5, int64(len(nodes)))
+	cloudProvider.RegisterNodeGroup(cloudProviderNodeGroup)
+	nodeGroup := BuildNodeGroupsState(nodeGroupsStateOpts{nodeGroups: nodeGroups})["buildeng"]
+	c := &Controller{
+		Client:        &Client{Interface: fakeClient},
+		Opts:          Opts{K8SClient: fakeClient, NodeGroups: nodeGroups},
+		cloudProvider: cloudProvider,
+	}
+	opts := scaleOpts{
+		nodes:          nodes,
+		taintedNodes:   nodes[:1],
+		untaintedNodes: nodes[1:],
+		nodeGroup:      nodeGroup,
+		ctx:            context.Background(),
+	}
+
+	// the expired tainted node isn't untainted as the replacement, a node is added instead
+	added, err := c.recycleExpiredNodes(opts)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, added)
+	assert.Equal(t, int64(len(nodes)+1), cloudProviderNodeGroup.TargetSize())
+	assert.True(t, nodeGroup.replacementPending)
+	select {
+	case name := <-updates:
+		t.Fatalf("node %v was updated", name)
+	default:
+	}
+
+	// once the replacement is ready the oldest untainted expired node is tainted
+	nodeGroup.replacementReadyNodes = 0
+	tainted, err := c.recycleExpiredNodes(opts)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, tainted)
+	assert.False(t, nodeGroup.replacementPending)
+	assert.Equal(t, "n2", <-updates)
+}
@@ -0,0 +1,126 @@
+package controller
+
+import (
+	"github.com/atlassian/escalator/pkg/cloudprovider"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// reloadRequest asks the main loop to replace the node groups between runs
+type reloadRequest struct {
+	config               Config
+	cloudProviderBuilder cloudprovider.Builder
+	// source and rollbackOf are recorded in the config history when the reload succeeds
+	source     string
+	rollbackOf int
+	result     chan error
+}
+
+// Reload replaces the node groups and cluster options with the validated config and rebuilds the cloud provider with
+// the builder. The reload happens between runs, so Reload blocks until the current run has finished.
+// The existing node groups are kept if the reload fails
+func (c *Controller) Reload(config Config, cloudProviderBuilder cloudprovider.Builder) error {
+	return c.requestReload(reloadRequest{
+		config:               config,
+		cloudProviderBuilder: cloudProviderBuilder,
+		source:               ConfigSourceReload,
+		result:               make(chan error, 1),
+	})
+}
+
+// requestReload asks the main loop to handle the reload request, and waits for its result
+func (c *Controller) requestReload(request reloadRequest) error {
+	select {
+	case c.reloadChan <- request:
+		return <-request.result
+	case <-c.stopChan:
+		return errors.New("controller stopped")
+	}
+}
+
+// reload replaces the node groups. Node groups that keep their name keep their state, e.g. the scale lock and
+// the taint tracker, so a reload doesn't reset cool downs
+func (c *Controller) reload(config Config, cloudProviderBuilder cloudprovider.Builder) error {
+	nodeGroups := config.NodeGroups
+	cloud, err := cloudProviderBuilder.Build()
+	if err != nil {
+		return errors.Wrap(err, "failed to create cloudprovider")
+	}
+
+	// discover every node group before touching any state, so a failed reload keeps the current node groups intact
+	discovered := make([]NodeGroupOptions, 0, len(nodeGroups))
+	listers := make(map[string]*NodeGroupLister, len(nodeGroups))
+	for _, nodeGroupOpts := range nodeGroups {
+		nodeGroupOpts, err = discoverNodeGroupOptions(cloud, nodeGroupOpts)
+		if err != nil {
+			return err
+		}
+		discovered = append(discovered, nodeGroupOpts)
+		listers[nodeGroupOpts.Name] = newNodeGroupLister(c.Client.allPodLister, c.Client.allNodeLister, nodeGroupOpts)
+	}
+
+	var removed []*NodeGroupState
+	for name, state := range c.nodeGroups {
+		if !containsNodeGroup(discovered, name) {
+			if config.DecommissionRemovedNodeGroups {
+				log.WithField("nodegroup", name).Info("Removing node group. Decommissioning its nodes")
+				removed = append(removed, state)
+			} else {
+				log.WithField("nodegroup", name).Warn("Removing node group. Any nodes it tainted keep their taint")
+			}
+		}
+	}
+
+	for _, change := range diffConfigs(Config{ClusterOptions: c.Opts.Cluster, NodeGroups: c.Opts.NodeGroups}, config) {
+		log.Infof("Reloading %v: %v -> %v", change.Path, displayConfigValue(change.Old), displayConfigValue(change.New))
+	}
+
+	// the existing states are updated and swapped in together, under the lock
+	c.nodeGroupsLock.Lock()
+	nodeGroupMap := make(map[string]*NodeGroupState, len(discovered))
+	for _, nodeGroupOpts := range discovered {
+		state, ok := c.nodeGroups[nodeGroupOpts.Name]
+		if !ok {
+			log.WithField("nodegroup", nodeGroupOpts.Name).Info("Adding node group")
+			state = &NodeGroupState{
+				scaleUpLock: scaleLock{nodegroup: nodeGroupOpts.Name},
+			}
+		}
+		state.Opts = nodeGroupOpts
+		state.NodeGroupLister = listers[nodeGroupOpts.Name]
+		state.scaleUpLock.minimumLockDuration = nodeGroupOpts.ScaleUpCoolDownPeriodDuration()
+		nodeGroupMap[nodeGroupOpts.Name] = state
+	}
+	c.Opts.NodeGroups = nodeGroups
+	c.Opts.Cluster = config.ClusterOptions
+	c.Opts.CloudProviderBuilder = cloudProviderBuilder
+	c.cloudProvider = cloud
+	c.Client.Listers = listers
+	c.nodeGroups = nodeGroupMap
+	c.nodeGroupsLock.Unlock()
+	c.providerWrites.forget(nodeGroupMap)
+	log.Infof("Reloaded %v node groups", len(nodeGroups))
+
+	for _, state := range removed {
+		c.decommissionNodeGroup(state, nodeGroups)
+	}
+	return nil
+}
+
+// containsNodeGroup returns whether a node group has the name
+func containsNodeGroup(nodeGroups []NodeGroupOptions, name string) bool {
+	for _, nodeGroupOpts := range nodeGroups {
+		if nodeGroupOpts.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// displayConfigValue returns the json value of a config change for the logs, or unset when it's empty
+func displayConfigValue(value string) string {
+	if len(value) == 0 {
+		return "unset"
+	}
+	return value
+}
@@ -0,0 +1,109 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/atlassian/escalator/pkg/cloudprovider"
+	"github.com/atlassian/escalator/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+)
+
+type testCloudProviderBuilder struct {
+	cloudProvider cloudprovider.CloudProvider
+}
+
+func (b testCloudProviderBuilder) Build() (cloudprovider.CloudProvider, error) {
+	return b.cloudProvider, nil
+}
+
+func TestControllerReload(t *testing.T) {
+	nodes := []*v1.Node{
+		test.BuildTestNode(test.NodeOpts{Name: "n1", LabelKey: "customer", LabelValue: "shared"}),
+		test.BuildTestNode(test.NodeOpts{Name: "n2", LabelKey: "customer", LabelValue: "buildeng"}),
+	}
+	nodeGroups := []NodeGroupOptions{
+		{Name: "shared", LabelKey: "customer", LabelValue: "shared", CloudProviderGroupName: "shared", ScaleUpCoolDownPeriod: "1m"},
+		{Name: "old", LabelKey: "customer", LabelValue: "old", CloudProviderGroupName: "old", ScaleUpCoolDownPeriod: "1m"},
+	}
+	client, opts := buildTestClient(nodes, nil, nodeGroups, ListerOptions{})
+
+	shared := &NodeGroupState{Opts: nodeGroups[0], NodeGroupLister: client.Listers["shared"], taintTracker: []DryModeTaint{{Node: "n1"}}}
+	c := &Controller{
+		Client: client,
+		Opts:   opts,
+		nodeGroups: map[string]*NodeGroupState{
+			"shared": shared,
+			"old":    {Opts: nodeGroups[1], NodeGroupLister: client.Listers["old"]},
+		},
+	}
+
+	cloudProvider := test.NewCloudProvider(2)
+	cloudProvider.RegisterNodeGroup(test.NewNodeGroup("shared", 1, 10, 1))
+	cloudProvider.RegisterNodeGroup(test.NewNodeGroup("buildeng", 1, 10, 1))
+	builder := testCloudProviderBuilder{cloudProvider}
+
+	reloaded := []NodeGroupOptions{
+		{Name: "shared", LabelKey: "customer", LabelValue: "shared", CloudProviderGroupName: "shared", ScaleUpCoolDownPeriod: "2m", MaxNodes: 5},
+		{Name: "buildeng", LabelKey: "customer", LabelValue: "buildeng", CloudProviderGroupName: "buildeng", ScaleUpCoolDownPeriod: "1m"},
+	}
+	require.NoError(t, c.reload(Config{NodeGroups: reloaded}, builder))
+
+	assert.Len(t, c.nodeGroups, 2)
+	assert.Equal(t, reloaded, c.Opts.NodeGroups)
+	// existing node groups keep their state
+	assert.True(t, shared == c.nodeGroups["shared"])
+	assert.Equal(t, []string{"n1"}, c.nodeGroups["shared"].dryTaintedNodes())
+	assert.Equal(t, 5, c.nodeGroups["shared"].Opts.MaxNodes)
+	assert.Equal(t, "2m0s", c.nodeGroups["shared"].scaleUpLock.minimumLockDuration.String())
+
+	buildengNodes, err := c.nodeGroups["buildeng"].Nodes.List()
+	require.NoError(t, err)
+	require.Len(t, buildengNodes, 1)
+	assert.Equal(t, "n2", buildengNodes[0].Name)
+	assert.Equal(t, c.nodeGroups["buildeng"].NodeGroupLister, c.Client.Listers["buildeng"])
+
+	// missing cloud provider node groups keep the current node groups
+	err = c.reload(Config{NodeGroups: []NodeGroupOptions{{Name: "gpu", CloudProviderGroupName: "gpu"}}}, builder)
+	assert.Error(t, err)
+	assert.Len(t, c.nodeGroups, 2)
+	assert.Equal(t, reloaded, c.Opts.NodeGroups)
+
+	// nor are the node groups before the missing one changed
+	sharedLister := c.nodeGroups["shared"].NodeGroupLister
+	err = c.reload(Config{NodeGroups: []NodeGroupOptions{
+		{Name: "shared", LabelKey: "customer", LabelValue: "shared", CloudProviderGroupName: "shared", ScaleUpCoolDownPeriod: "3m", MaxNodes: 7},
+		{Name: "gpu", CloudProviderGroupName: "gpu"},
+	}}, builder)
+	assert.Error(t, err)
+	assert.Equal(t, 5, c.nodeGroups["shared"].Opts.MaxNodes)
+	assert.Equal(t, "2m0s", c.nodeGroups["shared"].scaleUpLock.minimumLockDuration.String())
+	assert.True(t, sharedLister == c.nodeGroups["shared"].NodeGroupLister)
+	assert.True(t, sharedLister == c.Client.Listers["shared"])
+}
+
+func TestControllerDiagnostics(t *testing.T) {
+	c := &Controller{
+		nodeGroups: map[string]*NodeGroupState{
+			"shared": {Opts: NodeGroupOptions{Name: "shared"}, taintTracker: []DryModeTaint{{Node: "n1"}}, scaleDelta: -1},
+		},
+		nodeMembership:  map[string]string{"n1": "shared"},
+		diagnosticsChan: make(chan struct{}, 1),
+	}
+
+	diagnostics := c.Diagnostics()
+	assert.Equal(t, []DryModeTaint{{Node: "n1"}}, diagnostics.NodeGroups["shared"].TaintTracker)
+	assert.Equal(t, -1, diagnostics.NodeGroups["shared"].ScaleDelta)
+	assert.Equal(t, map[string]string{"n1": "shared"}, diagnostics.NodeMembership)
+
+	// requests don't block when a dump is already pending
+	c.RequestDiagnostics()
+	c.RequestDiagnostics()
+	assert.Len(t, c.diagnosticsChan, 1)
+}
+
+func TestDisplayConfigValue(t *testing.T) {
+	assert.Equal(t, "unset", displayConfigValue(""))
+	assert.Equal(t, "5", displayConfigValue("5"))
+}
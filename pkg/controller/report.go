@@ -0,0 +1,41 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Report is the state of the controller served by the report endpoint
+type Report struct {
+	Discovery         DiscoveryReport `json:"discovery"`
+	LastSuccessfulRun time.Time       `json:"last_successful_run"`
+	// StuckDeletions are the tainted nodes that failed to be deleted too many times and need attention
+	StuckDeletions []StuckDeletion `json:"stuck_deletions"`
+	// DisruptionScores are the disruption scores of the untainted nodes, lowest first by node group
+	DisruptionScores []NodeDisruptionScore `json:"disruption_scores"`
+	// SchedulingFailures are the latest FailedScheduling events of the pods of each node group, newest first by node
+	// group. Only set with --scheduling-failures
+	SchedulingFailures []SchedulingFailure `json:"scheduling_failures"`
+	// Reservations are the reservations of the node groups active in the last run
+	Reservations []ActiveReservation `json:"reservations"`
+}
+
+// Report returns a copy of the latest report
+func (c *Controller) Report() Report {
+	c.reportLock.RLock()
+	defer c.reportLock.RUnlock()
+	return c.report
+}
+
+// ReportHandler serves the latest report as json
+func (c *Controller) ReportHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(c.Report()); err != nil {
+			log.WithError(err).Warn("Failed to write report")
+		}
+	})
+}
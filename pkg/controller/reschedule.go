@@ -0,0 +1,59 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/atlassian/escalator/pkg/metrics"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+)
+
+// defaultRescheduleMaxDeferral is how long a hard deletion is deferred for because the pods of the node can't be
+// rescheduled when reschedule_max_deferral isn't set
+const defaultRescheduleMaxDeferral = time.Hour
+
+// reschedulingDefersDeletion returns whether the hard deletion of the tainted node, which still runs pods, is deferred
+// because the simulation couldn't reschedule its pods onto the untainted nodes. The deletion is deferred for at most
+// reschedule_max_deferral from the first time it was, after which the node is deleted anyway
+func reschedulingDefersDeletion(nodeGroup *NodeGroupState, node *v1.Node, rescheduled bool, now time.Time) bool {
+	if rescheduled {
+		delete(nodeGroup.rescheduleDeferrals, node.Name)
+		return false
+	}
+
+	logger := log.WithField("nodegroup", nodeGroup.Opts.Name)
+	since, ok := nodeGroup.rescheduleDeferrals[node.Name]
+	if !ok {
+		if nodeGroup.rescheduleDeferrals == nil {
+			nodeGroup.rescheduleDeferrals = make(map[string]time.Time)
+		}
+		nodeGroup.rescheduleDeferrals[node.Name] = now
+		since = now
+	}
+	maxDeferral := nodeGroup.Opts.RescheduleMaxDeferralDuration()
+	if now.Sub(since) >= maxDeferral {
+		logger.Warningf("deleting node %v although its remaining pods could not be rescheduled onto the untainted nodes, its deletion was deferred for the maximum of %v",
+			node.Name, maxDeferral)
+		return false
+	}
+	logger.Warningf("deferring deletion of node %v: its remaining pods could not be rescheduled onto the untainted nodes, %v remaining of the maximum deferral",
+		node.Name, maxDeferral-now.Sub(since))
+	metrics.NodeGroupDeletionsDeferred.WithLabelValues(nodeGroup.Opts.Name).Add(1.0)
+	return true
+}
+
+// pruneRescheduleDeferrals forgets the deferrals of the nodes that are no longer tainted
+func pruneRescheduleDeferrals(nodeGroup *NodeGroupState, taintedNodes []*v1.Node) {
+	if len(nodeGroup.rescheduleDeferrals) == 0 {
+		return
+	}
+	tainted := make(map[string]bool, len(taintedNodes))
+	for _, node := range taintedNodes {
+		tainted[node.Name] = true
+	}
+	for name := range nodeGroup.rescheduleDeferrals {
+		if !tainted[name] {
+			delete(nodeGroup.rescheduleDeferrals, name)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/atlassian/escalator/pkg/errorkind"
+	"github.com/atlassian/escalator/pkg/metrics"
+	log "github.com/sirupsen/logrus"
+)
+
+// maxReservationDuration is the longest a recurring reservation can stay open for
+const maxReservationDuration = 7 * 24 * time.Hour
+
+// ActiveReservation is a reservation of a node group active in the last run, as shown in the report
+type ActiveReservation struct {
+	NodeGroup string    `json:"node_group"`
+	Name      string    `json:"name"`
+	Tenant    string    `json:"tenant"`
+	Nodes     int       `json:"nodes"`
+	From      time.Time `json:"from"`
+	Until     time.Time `json:"until"`
+}
+
+// recurring returns whether the reservation opens on a cron schedule rather than once
+func (r Reservation) recurring() bool {
+	return len(r.Start) > 0
+}
+
+// cronWindow returns the parsed window of a recurring reservation, nil when it is invalid. Reservations loaded with the
+// config were parsed by UnmarshalConfig, others are parsed on every call
+func (r Reservation) cronWindow() *cronWindow {
+	if r.parsed != nil {
+		return r.parsed
+	}
+	parsed, _ := parseCronWindow(r.Start, r.Duration, r.TimeZone)
+	return parsed
+}
+
+// window returns when the reservation opened and closes, and whether it is active at now. Invalid reservations are
+// never active
+func (r Reservation) window(now time.Time) (time.Time, time.Time, bool) {
+	if r.recurring() {
+		window := r.cronWindow()
+		if window == nil {
+			return time.Time{}, time.Time{}, false
+		}
+		opened, open := window.lastStart(now)
+		if !open {
+			return time.Time{}, time.Time{}, false
+		}
+		return opened, opened.Add(window.length), true
+	}
+
+	from, err := time.Parse(time.RFC3339, r.From)
+	if err != nil {
+		return time.Time{}, time.Time{}, false
+	}
+	until, err := time.Parse(time.RFC3339, r.Until)
+	if err != nil {
+		return time.Time{}, time.Time{}, false
+	}
+	return from, until, !now.Before(from) && now.Before(until)
+}
+
+// validateReservations returns the problems with the reservations of a node group
+func validateReservations(reservations []Reservation) []error {
+	var problems []error
+
+	checkThat := func(cond bool, format string, output ...interface{}) {
+		if !cond {
+			problems = append(problems, errorkind.New(errorkind.Validation, format, output...))
+		}
+	}
+
+	names := make(map[string]bool, len(reservations))
+	for i, reservation := range reservations {
+		checkThat(len(reservation.Name) > 0, "reservations[%d].name must not be empty", i)
+		checkThat(len(reservation.Name) == 0 || !names[reservation.Name], "reservations has %v more than once", reservation.Name)
+		names[reservation.Name] = true
+		checkThat(reservation.Nodes > 0, "reservations[%d].nodes must be larger than 0", i)
+
+		if reservation.recurring() {
+			checkThat(len(reservation.From) == 0 && len(reservation.Until) == 0, "reservations[%d].start can't be used with from and until", i)
+			if _, err := parseCronSchedule(reservation.Start); err != nil {
+				checkThat(false, "reservations[%d].start must be a valid cron expression: %v", i, err)
+			}
+			duration, err := time.ParseDuration(reservation.Duration)
+			if err != nil {
+				checkThat(false, "reservations[%d].duration must be a valid duration: %v", i, err)
+			} else {
+				checkThat(duration > 0 && duration <= maxReservationDuration, "reservations[%d].duration must be above 0 and at most %v", i, maxReservationDuration)
+			}
+			if _, err := time.LoadLocation(reservation.TimeZone); err != nil {
+				checkThat(false, "reservations[%d].time_zone must be a valid time zone: %v", i, err)
+			}
+			continue
+		}
+
+		checkThat(len(reservation.Duration) == 0 && len(reservation.TimeZone) == 0, "reservations[%d].duration and time_zone are only used with start", i)
+		from, fromErr := time.Parse(time.RFC3339, reservation.From)
+		if fromErr != nil {
+			checkThat(false, "reservations[%d].from must be an RFC3339 time, e.g. 2019-03-01T09:00:00Z: %v", i, fromErr)
+		}
+		until, untilErr := time.Parse(time.RFC3339, reservation.Until)
+		if untilErr != nil {
+			checkThat(false, "reservations[%d].until must be an RFC3339 time, e.g. 2019-03-01T17:00:00Z: %v", i, untilErr)
+		}
+		if fromErr == nil && untilErr == nil {
+			checkThat(until.After(from), "reservations[%d].until must be after from", i)
+		}
+	}
+	return problems
+}
+
+// activeReservations returns the reservations of the node group active at now
+func activeReservations(nodeGroup *NodeGroupState, now time.Time) []ActiveReservation {
+	var active []ActiveReservation
+	for _, reservation := range nodeGroup.Opts.Reservations {
+		from, until, ok := reservation.window(now)
+		if !ok {
+			continue
+		}
+		active = append(active, ActiveReservation{
+			NodeGroup: nodeGroup.Opts.Name,
+			Name:      reservation.Name,
+			Tenant:    reservation.Tenant,
+			Nodes:     reservation.Nodes,
+			From:      from,
+			Until:     until,
+		})
+	}
+	return active
+}
+
+// reservedNodes returns the nodes reserved by the active reservations of the node group
+func reservedNodes(nodeGroup *NodeGroupState, now time.Time) int {
+	var nodes int
+	for _, reservation := range activeReservations(nodeGroup, now) {
+		nodes += reservation.Nodes
+	}
+	return nodes
+}
+
+// updateReservations records the reservations of the node group active for the run, and logs when they start or end
+func updateReservations(nodeGroup *NodeGroupState, now time.Time) {
+	logger := log.WithField("nodegroup", nodeGroup.Opts.Name)
+	active := activeReservations(nodeGroup, now)
+
+	previous := make(map[string]bool, len(nodeGroup.activeReservations))
+	for _, reservation := range nodeGroup.activeReservations {
+		previous[reservation.Name] = true
+	}
+	current := make(map[string]bool, len(active))
+	var nodes int
+	for _, reservation := range active {
+		current[reservation.Name] = true
+		nodes += reservation.Nodes
+		if !previous[reservation.Name] {
+			logger.Infof("Reservation %v of %v nodes for %v started, until %v", reservation.Name, reservation.Nodes, reservation.Tenant, reservation.Until)
+		}
+	}
+	for _, reservation := range nodeGroup.activeReservations {
+		if !current[reservation.Name] {
+			logger.Infof("Reservation %v of %v nodes for %v ended", reservation.Name, reservation.Nodes, reservation.Tenant)
+		}
+	}
+	nodeGroup.activeReservations = active
+	metrics.NodeGroupReservedNodes.WithLabelValues(nodeGroup.Opts.Name).Set(float64(nodes))
+}
+
+// updateActiveReservations exports the active reservations of every node group in the report
+func (c *Controller) updateActiveReservations() {
+	var reservations []ActiveReservation
+	for _, nodeGroupOpts := range c.Opts.NodeGroups {
+		if nodeGroup, ok := c.nodeGroups[nodeGroupOpts.Name]; ok {
+			reservations = append(reservations, nodeGroup.activeReservations...)
+		}
+	}
+
+	c.reportLock.Lock()
+	c.report.Reservations = reservations
+	c.reportLock.Unlock()
+}
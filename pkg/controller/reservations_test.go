@@ -0,0 +1,120 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateReservations(t *testing.T) {
+	tests := []struct {
+		name         string
+		reservations []Reservation
+		want         int
+	}{
+		{"unset", nil, 0},
+		{"one off", []Reservation{{Name: "load-test", Tenant: "payroll", Nodes: 5, From: "2019-03-01T09:00:00Z", Until: "2019-03-01T17:00:00+01:00"}}, 0},
+		{"recurring", []Reservation{{Name: "nightly", Nodes: 2, Start: "0 22 * * 1-5", Duration: "4h", TimeZone: "Australia/Sydney"}}, 0},
+		{"empty", []Reservation{{}}, 4},
+		{"duplicate", []Reservation{
+			{Name: "nightly", Nodes: 2, Start: "0 22 * * *", Duration: "4h"},
+			{Name: "nightly", Nodes: 2, Start: "0 2 * * *", Duration: "4h"},
+		}, 1},
+		{"until before from", []Reservation{{Name: "load-test", Nodes: 5, From: "2019-03-01T17:00:00Z", Until: "2019-03-01T09:00:00Z"}}, 1},
+		{"one off with a duration", []Reservation{{Name: "load-test", Nodes: 5, From: "2019-03-01T09:00:00Z", Until: "2019-03-01T17:00:00Z", Duration: "1h"}}, 1},
+		{"recurring with from", []Reservation{{Name: "nightly", Nodes: 2, Start: "0 22 * * *", Duration: "4h", From: "2019-03-01T09:00:00Z"}}, 1},
+		{"invalid recurring", []Reservation{{Name: "nightly", Nodes: 2, Start: "0 22 * *", Duration: "8d", TimeZone: "Mars/Olympus"}}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Len(t, validateReservations(tt.reservations), tt.want)
+		})
+	}
+}
+
+func TestReservationWindow(t *testing.T) {
+	oneOff := Reservation{Name: "load-test", Nodes: 5, From: "2019-03-01T09:00:00Z", Until: "2019-03-01T17:00:00Z"}
+	recurring := Reservation{Name: "nightly", Nodes: 2, Start: "0 22 * * *", Duration: "4h"}
+
+	tests := []struct {
+		name        string
+		reservation Reservation
+		now         time.Time
+		wantActive  bool
+		wantFrom    time.Time
+		wantUntil   time.Time
+	}{
+		{"before a one off", oneOff, time.Date(2019, 3, 1, 8, 59, 0, 0, time.UTC), false, time.Time{}, time.Time{}},
+		{"during a one off", oneOff, time.Date(2019, 3, 1, 9, 0, 0, 0, time.UTC), true, time.Date(2019, 3, 1, 9, 0, 0, 0, time.UTC), time.Date(2019, 3, 1, 17, 0, 0, 0, time.UTC)},
+		{"end of a one off", oneOff, time.Date(2019, 3, 1, 17, 0, 0, 0, time.UTC), false, time.Time{}, time.Time{}},
+		{"during a recurring", recurring, time.Date(2019, 3, 2, 1, 30, 0, 0, time.UTC), true, time.Date(2019, 3, 1, 22, 0, 0, 0, time.UTC), time.Date(2019, 3, 2, 2, 0, 0, 0, time.UTC)},
+		{"after a recurring", recurring, time.Date(2019, 3, 2, 2, 0, 0, 0, time.UTC), false, time.Time{}, time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, until, active := tt.reservation.window(tt.now)
+			assert.Equal(t, tt.wantActive, active)
+			if tt.wantActive {
+				assert.True(t, tt.wantFrom.Equal(from), "from %v", from)
+				assert.True(t, tt.wantUntil.Equal(until), "until %v", until)
+			}
+		})
+	}
+}
+
+func TestMinNodes_Reservations(t *testing.T) {
+	now := time.Date(2019, 3, 1, 12, 0, 0, 0, time.UTC)
+	nodeGroup := &NodeGroupState{
+		Opts: NodeGroupOptions{
+			Name:     "shared",
+			MinNodes: 3,
+			MaxNodes: 10,
+			Reservations: []Reservation{
+				{Name: "load-test", Tenant: "payroll", Nodes: 4, From: "2019-03-01T09:00:00Z", Until: "2019-03-01T17:00:00Z"},
+				{Name: "nightly", Tenant: "batch", Nodes: 2, Start: "0 22 * * *", Duration: "4h"},
+			},
+		},
+	}
+	assert.Equal(t, 7, minNodes(nodeGroup, now))
+	// the reservations are added to the held minimum
+	nodeGroup.Opts.HighWaterMarkHold = HighWaterMarkHoldOptions{ThresholdNodes: 1, Percent: 50, Duration: "1h"}
+	nodeGroup.highWaterMark = highWaterMark{nodes: 10, time: now}
+	assert.Equal(t, 9, minNodes(nodeGroup, now))
+	// capped at max_nodes
+	nodeGroup.Opts.Reservations[0].Nodes = 8
+	assert.Equal(t, 10, minNodes(nodeGroup, now))
+	// back to the minimum once they end
+	assert.Equal(t, 3, minNodes(nodeGroup, now.Add(6*time.Hour)))
+}
+
+func TestUpdateActiveReservations(t *testing.T) {
+	now := time.Date(2019, 3, 1, 12, 0, 0, 0, time.UTC)
+	reservations := []Reservation{
+		{Name: "load-test", Tenant: "payroll", Nodes: 4, From: "2019-03-01T09:00:00Z", Until: "2019-03-01T17:00:00Z"},
+		{Name: "nightly", Tenant: "batch", Nodes: 2, Start: "0 22 * * *", Duration: "4h"},
+	}
+	c := &Controller{
+		Opts: Opts{NodeGroups: []NodeGroupOptions{{Name: "shared", Reservations: reservations}, {Name: "batch"}}},
+		nodeGroups: map[string]*NodeGroupState{
+			"shared": {Opts: NodeGroupOptions{Name: "shared", Reservations: reservations}},
+			"batch":  {Opts: NodeGroupOptions{Name: "batch"}},
+		},
+	}
+	for _, nodeGroup := range c.nodeGroups {
+		updateReservations(nodeGroup, now)
+	}
+	c.updateActiveReservations()
+	assert.Equal(t, []ActiveReservation{{
+		NodeGroup: "shared",
+		Name:      "load-test",
+		Tenant:    "payroll",
+		Nodes:     4,
+		From:      time.Date(2019, 3, 1, 9, 0, 0, 0, time.UTC),
+		Until:     time.Date(2019, 3, 1, 17, 0, 0, 0, time.UTC),
+	}}, c.Report().Reservations)
+
+	updateReservations(c.nodeGroups["shared"], now.Add(6*time.Hour))
+	c.updateActiveReservations()
+	assert.Empty(t, c.Report().Reservations)
+}
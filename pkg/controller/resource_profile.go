@@ -0,0 +1,60 @@
+package controller
+
+import (
+	"math"
+
+	"github.com/atlassian/escalator/pkg/k8s"
+	"github.com/atlassian/escalator/pkg/metrics"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// profilePods returns the pods selected by the label of the resource profile
+func profilePods(pods []*v1.Pod, profile ResourceProfile) []*v1.Pod {
+	selected := make([]*v1.Pod, 0, len(pods))
+	for _, pod := range pods {
+		if value, ok := pod.Labels[profile.LabelKey]; ok && value == profile.LabelValue {
+			selected = append(selected, pod)
+		}
+	}
+	return selected
+}
+
+// applyResourceProfiles raises the cpu and memory percentages of the node group to the highest of its resource
+// profiles. The utilisation of each profile is its pods' requests against the capacity of the node group, normalised
+// from the profile's scale up threshold to the node group's, so a profile reaching its own threshold scales the node
+// group like the node group reaching its threshold
+func applyResourceProfiles(nodeGroup *NodeGroupState, pods []*v1.Pod, overheads k8s.RuntimeClassOverheads, cpuCapacity, memCapacity resource.Quantity, cpuPercent, memPercent float64) (float64, float64, error) {
+	return resourceProfilesPercent(nodeGroup.Opts, pods, overheads, cpuCapacity, memCapacity, cpuPercent, memPercent, func(profile ResourceProfile, profileCPUPercent, profileMemPercent float64) {
+		log.WithField("nodegroup", nodeGroup.Opts.Name).Infof("profile %v cpu: %v, memory: %v", profile.Name, profileCPUPercent, profileMemPercent)
+		metrics.NodeGroupProfileCPUPercent.WithLabelValues(nodeGroup.Opts.Name, profile.Name).Set(profileCPUPercent)
+		metrics.NodeGroupProfileMemPercent.WithLabelValues(nodeGroup.Opts.Name, profile.Name).Set(profileMemPercent)
+	})
+}
+
+// resourceProfilesPercent returns the cpu and memory percentages raised to the highest of the resource profiles of
+// the options, calling observe with the utilisation of each profile when it is set
+func resourceProfilesPercent(opts NodeGroupOptions, pods []*v1.Pod, overheads k8s.RuntimeClassOverheads, cpuCapacity, memCapacity resource.Quantity, cpuPercent, memPercent float64, observe func(profile ResourceProfile, profileCPUPercent, profileMemPercent float64)) (float64, float64, error) {
+	// scaling up from 0 is already decided on the requests of all of the pods
+	if cpuPercent == math.MaxFloat64 || memPercent == math.MaxFloat64 || cpuCapacity.IsZero() || memCapacity.IsZero() {
+		return cpuPercent, memPercent, nil
+	}
+
+	for _, profile := range opts.ResourceProfiles {
+		memRequest, cpuRequest, err := k8s.CalculatePodsRequestsTotalWithOverhead(profilePods(pods, profile), overheads)
+		if err != nil {
+			return cpuPercent, memPercent, err
+		}
+		profileCPUPercent := float64(cpuRequest.MilliValue()) / float64(cpuCapacity.MilliValue()) * 100
+		profileMemPercent := float64(memRequest.MilliValue()) / float64(memCapacity.MilliValue()) * 100
+		if observe != nil {
+			observe(profile, profileCPUPercent, profileMemPercent)
+		}
+
+		scale := float64(opts.ScaleUpThresholdPercent) / float64(profile.ScaleUpThresholdPercent)
+		cpuPercent = math.Max(cpuPercent, profileCPUPercent*scale)
+		memPercent = math.Max(memPercent, profileMemPercent*scale)
+	}
+	return cpuPercent, memPercent, nil
+}
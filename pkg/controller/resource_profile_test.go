@@ -0,0 +1,67 @@
+package controller
+
+import (
+	"math"
+	"testing"
+
+	"github.com/atlassian/escalator/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func buildProfilePod(name string, size string, cpu int64, mem int64) *v1.Pod {
+	pod := test.BuildTestPod(test.PodOpts{Name: name, CPU: []int64{cpu}, Mem: []int64{mem}})
+	if len(size) > 0 {
+		pod.Labels = map[string]string{"size": size}
+	}
+	return pod
+}
+
+func TestProfilePods(t *testing.T) {
+	small := buildProfilePod("small", "small", 100, 100)
+	large := buildProfilePod("large", "large", 4000, 4000)
+	unlabelled := buildProfilePod("unlabelled", "", 100, 100)
+	pods := []*v1.Pod{small, large, unlabelled}
+
+	assert.Equal(t, []*v1.Pod{large}, profilePods(pods, ResourceProfile{LabelKey: "size", LabelValue: "large"}))
+	assert.Equal(t, []*v1.Pod{}, profilePods(pods, ResourceProfile{LabelKey: "size", LabelValue: "medium"}))
+	assert.Equal(t, []*v1.Pod{}, profilePods(pods, ResourceProfile{LabelKey: "team", LabelValue: ""}))
+}
+
+func TestApplyResourceProfiles(t *testing.T) {
+	// 10 nodes of 1000m cpu and 1000 bytes memory
+	cpuCapacity := *resource.NewMilliQuantity(10000, resource.DecimalSI)
+	memCapacity := *resource.NewQuantity(10000, resource.DecimalSI)
+	pods := []*v1.Pod{
+		buildProfilePod("small", "small", 2000, 1000),
+		buildProfilePod("large", "large", 3000, 2000),
+	}
+	large := ResourceProfile{Name: "large", LabelKey: "size", LabelValue: "large", ScaleUpThresholdPercent: 35}
+
+	tests := []struct {
+		name       string
+		profiles   []ResourceProfile
+		cpuPercent float64
+		memPercent float64
+		wantCPU    float64
+		wantMem    float64
+	}{
+		{"no profiles", nil, 50, 30, 50, 30},
+		// the large pods request 30% of the cpu, normalised from their 35% threshold to the node group's 70%
+		{"profile above the node group", []ResourceProfile{large}, 50, 30, 60, 40},
+		{"node group above the profile", []ResourceProfile{large}, 80, 50, 80, 50},
+		{"profile without pods", []ResourceProfile{{Name: "medium", LabelKey: "size", LabelValue: "medium", ScaleUpThresholdPercent: 10}}, 50, 30, 50, 30},
+		{"scaling up from zero", []ResourceProfile{large}, math.MaxFloat64, math.MaxFloat64, math.MaxFloat64, math.MaxFloat64},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodeGroup := &NodeGroupState{Opts: NodeGroupOptions{Name: "default", ScaleUpThresholdPercent: 70, ResourceProfiles: tt.profiles}}
+			cpuPercent, memPercent, err := applyResourceProfiles(nodeGroup, pods, nil, cpuCapacity, memCapacity, tt.cpuPercent, tt.memPercent)
+			require.NoError(t, err)
+			assert.InDelta(t, tt.wantCPU, cpuPercent, 0.001)
+			assert.InDelta(t, tt.wantMem, memPercent, 0.001)
+		})
+	}
+}
@@ -1,10 +1,13 @@
 package controller
 
 import (
+	"context"
 	"fmt"
 	"sort"
+	duration "time"
 
 	"github.com/atlassian/escalator/pkg/cloudprovider"
+	"github.com/atlassian/escalator/pkg/errorkind"
 	"github.com/atlassian/escalator/pkg/k8s"
 	"github.com/atlassian/escalator/pkg/metrics"
 	log "github.com/sirupsen/logrus"
@@ -12,6 +15,15 @@ import (
 	v1 "k8s.io/api/core/v1"
 )
 
+const (
+	// ScaleDownModeTaint marks the nodes to be removed with the escalator taint
+	ScaleDownModeTaint = "taint"
+	// ScaleDownModeCordon marks the nodes to be removed by cordoning them
+	ScaleDownModeCordon = "cordon"
+	// ScaleDownModeBoth marks the nodes to be removed with the escalator taint and by cordoning them
+	ScaleDownModeBoth = "both"
+)
+
 // ScaleDown performs the taint and remove node logic
 func (c *Controller) ScaleDown(opts scaleOpts) (int, error) {
 	removed, err := c.TryRemoveTaintedNodes(opts)
@@ -34,23 +46,111 @@ func (c *Controller) ScaleDown(opts scaleOpts) (int, error) {
 // * have passed their grace period
 func (c *Controller) TryRemoveTaintedNodes(opts scaleOpts) (int, error) {
 	var toBeDeleted []*v1.Node
+	// simulator is only built when a non-empty node reaches its hard delete grace period
+	var simulator *k8s.SchedulingSimulator
+	// the pods evicted by the nodes deleted so far in this run, for eviction_limits.max_pods
+	evictedPods := 0
+	// the pods of each PodDisruptionBudget evicted by the nodes deleted so far in this run
+	pdbEvictions := make(map[string]int)
+	pruneDaemonSetDrains(opts.nodeGroup, opts.taintedNodes)
+	prunePreDeleteHookDelays(opts.nodeGroup, opts.taintedNodes)
+	pruneDeletionAttempts(opts.nodeGroup, opts.taintedNodes)
+	pruneEmptySince(opts.nodeGroup, opts.taintedNodes)
+	prunePodDisruptionBudgetDeferrals(opts.nodeGroup, opts.taintedNodes)
+	pruneRescheduleDeferrals(opts.nodeGroup, opts.taintedNodes)
+	c.uncordonAbandonedDeletions(opts.nodeGroup, opts.untaintedNodes, opts.taintedNodes)
+	if len(opts.nodeGroup.pendingTerminations) > 0 {
+		if cloudProviderNodeGroup, ok := getCloudProviderNodeGroup(c.cloudProvider, opts.nodeGroup.Opts); ok {
+			verifyTerminations(opts.nodeGroup, cloudProviderNodeGroup, time.Now())
+		}
+	}
 	for _, candidate := range opts.taintedNodes {
+		// nodes whose deletion keeps failing are retried with a backoff
+		if deletionBackingOff(opts.nodeGroup, candidate, time.Now()) {
+			continue
+		}
+		// nodes running static pods are never deleted, even once tainted. they stay tainted until the static pods are
+		// moved off or a scale up untaints them
+		if c.staticPodsBlockScaleDown(opts.nodeGroup, candidate, "deleting") {
+			continue
+		}
+		// nodes are only terminated after they have been tainted for real
+		if c.dryTaint(opts.nodeGroup) && !c.dryDelete(opts.nodeGroup) {
+			continue
+		}
 		// if the time the node was tainted is larger than the hard period then it is deleted no matter what
 		// if the soft time is passed and the node is empty (excluding daemonsets) then it can be deleted
-		taintedTime, err := k8s.GetToBeRemovedTime(candidate)
+		// in dry mode the time is the time the node was tainted in the taint tracker
+		taintedTime, err := c.toBeRemovedTime(opts.nodeGroup, candidate)
 		if err != nil || taintedTime == nil {
-			log.WithError(err).Errorf("unable to get tainted time from node %v. Ignore if running in drymode", candidate.Name)
+			log.WithError(err).Errorf("unable to get tainted time from node %v", candidate.Name)
 			continue
 		}
 
 		now := time.Now()
-		if now.Sub(*taintedTime) > opts.nodeGroup.Opts.SoftDeleteGracePeriodDuration() {
-			if k8s.NodeEmpty(candidate, opts.nodeGroup.NodeInfoMap) || now.Sub(*taintedTime) > opts.nodeGroup.Opts.HardDeleteGracePeriodDuration() {
-				drymode := c.dryMode(opts.nodeGroup)
+		hard := hardDeleteGracePeriod(opts.nodeGroup, candidate)
+		softPassed, softRemaining := softDeleteGracePeriodPassed(opts.nodeGroup, candidate, *taintedTime, now)
+		if softPassed {
+			empty := k8s.NodeEmpty(candidate, opts.nodeGroup.NodeInfoMap)
+			if empty || now.Sub(*taintedTime) > hard {
+				// never hard delete a node running protected pods, even with aggressive_scale_down
+				if !empty && c.protectedPodsBlockDeletion(opts.nodeGroup, candidate) {
+					continue
+				}
+				// defer the hard deletion while evicting the pods would violate a PodDisruptionBudget
+				if !empty && c.podDisruptionBudgetsBlockDeletion(opts.nodeGroup, candidate, pdbEvictions, now) {
+					continue
+				}
+				// don't hard delete a node if the pods on it have nowhere to go
+				if !empty && !opts.nodeGroup.Opts.AggressiveScaleDown {
+					if simulator == nil {
+						simulator = k8s.NewSchedulingSimulator(opts.untaintedNodes, opts.nodeGroup.NodeInfoMap)
+					}
+					rescheduled := simulator.TrySchedule(k8s.NodeReschedulablePods(candidate, opts.nodeGroup.NodeInfoMap))
+					if reschedulingDefersDeletion(opts.nodeGroup, candidate, rescheduled, now) {
+						continue
+					}
+				}
+				// pace the deletions of nodes that still run pods, so their pods don't all reschedule at once
+				if !empty && !c.evictionLimitsAllow(opts.nodeGroup, candidate, evictedPods, now) {
+					continue
+				}
+				// space out the deletions of nodes with many volumes attached, so their volumes detach in time
+				if !c.volumeDetachAllows(opts.nodeGroup, candidate, now) {
+					continue
+				}
+
+				drymode := c.dryDelete(opts.nodeGroup)
 				log.WithField("drymode", drymode).Infof("Node %v, %v ready to be deleted", candidate.Name, candidate.Spec.ProviderID)
 				if !drymode {
+					// audit mode only dry runs the deletions, so it leaves the nodes and the provider write limits alone
+					if !c.auditMode(opts.nodeGroup) {
+						// the termination takes a provider write before anything is done to the node. Once the
+						// limits refuse, the remaining candidates stay tainted until a later run
+						if !c.allowProviderDeletion(opts.nodeGroup) {
+							break
+						}
+						// cordon the node as a separate step first, so no pods are scheduled onto it while it is drained
+						// and terminated
+						cordoned, ok := c.cordonForDeletion(opts.nodeGroup, candidate)
+						if !ok {
+							continue
+						}
+						candidate = cordoned
+						// wait for the DaemonSet pods that must flush first and ask the pre delete hook
+						if !c.drainDaemonSets(opts.nodeGroup, candidate) || !c.preDeleteHookAllows(opts.ctx, opts.nodeGroup, candidate) {
+							continue
+						}
+					}
 					toBeDeleted = append(toBeDeleted, candidate)
+				} else {
+					opts.nodeGroup.simulateDelete(candidate.Name)
+				}
+				if !empty {
+					evictedPods += recordEviction(opts.nodeGroup, candidate, now)
+					c.recordPodDisruptionBudgetEvictions(opts.nodeGroup, candidate, pdbEvictions)
 				}
+				recordVolumeHeavyDeletion(opts.nodeGroup, candidate, now)
 			} else {
 				nodePodsRemaining, ok := k8s.NodePodsRemaining(candidate, opts.nodeGroup.NodeInfoMap)
 				var podsRemainingMessage string
@@ -62,18 +162,30 @@ func (c *Controller) TryRemoveTaintedNodes(opts scaleOpts) (int, error) {
 				log.Debugf("node %v not ready for deletion (%s). Hard delete time remaining %v",
 					candidate.Name,
 					podsRemainingMessage,
-					opts.nodeGroup.Opts.HardDeleteGracePeriodDuration()-now.Sub(*taintedTime),
+					hard-now.Sub(*taintedTime),
 				)
 			}
 		} else {
 			log.Debugf("node %v not ready for deletion yet. Time remaining %v",
 				candidate.Name,
-				opts.nodeGroup.Opts.SoftDeleteGracePeriodDuration()-now.Sub(*taintedTime),
+				softRemaining,
 			)
 		}
 	}
 
 	if len(toBeDeleted) > 0 {
+		cloudProviderNodeGroup, ok := getCloudProviderNodeGroup(c.cloudProvider, opts.nodeGroup.Opts)
+		if !ok {
+			return 0, errorkind.New(errorkind.NotFound, "cloud provider node group does not exist: %s", opts.nodeGroup.Opts.cloudProviderGroupID())
+		}
+
+		if c.auditMode(opts.nodeGroup) {
+			return 0, auditDeleteNodes(callContext(opts.ctx), cloudProviderNodeGroup, toBeDeleted)
+		}
+		if err := contextErr(opts.ctx, "deleting nodes"); err != nil {
+			return 0, err
+		}
+
 		podsRemaining := 0
 		for _, nodeToBeDeleted := range toBeDeleted {
 			nodePodsRemaining, ok := k8s.NodePodsRemaining(nodeToBeDeleted, opts.nodeGroup.NodeInfoMap)
@@ -83,49 +195,144 @@ func (c *Controller) TryRemoveTaintedNodes(opts scaleOpts) (int, error) {
 			podsRemaining += nodePodsRemaining
 		}
 
-		cloudProviderNodeGroup, ok := c.cloudProvider.GetNodeGroup(opts.nodeGroup.Opts.CloudProviderGroupName)
-		if !ok {
-			return 0, fmt.Errorf("cloud provider node group does not exist: %s", opts.nodeGroup.Opts.CloudProviderGroupName)
+		// Terminate the nodes in the cloud provider, the nodes that failed are left tainted to be retried
+		toBeDeleted, deleteErr := c.deleteFromCloudProvider(callContext(opts.ctx), opts.nodeGroup, cloudProviderNodeGroup, toBeDeleted)
+		if len(toBeDeleted) == 0 {
+			return 0, deleteErr
 		}
+		recordPendingTerminations(opts.nodeGroup, toBeDeleted, time.Now())
 
-		// Terminate the nodes in the cloud provider
-		err := cloudProviderNodeGroup.DeleteNodes(toBeDeleted...)
-		if err != nil {
-			for _, nodeToDelete := range toBeDeleted {
-				log.WithError(err).Errorf("failed to terminate node in cloud provider %v, %v", nodeToDelete.Name, nodeToDelete.Spec.ProviderID)
+		// Delete the nodes from kubernetes
+		var k8sErr error
+		for _, node := range toBeDeleted {
+			if err := k8s.DeleteNode(node, c.Client); err != nil {
+				log.WithError(err).Errorf("failed to delete node %v from kubernetes", node.Name)
+				recordDeletionFailure(opts.nodeGroup, []*v1.Node{node}, err, time.Now())
+				k8sErr = err
 			}
-			return 0, err
 		}
-
-		// Delete the nodes from kubernetes
-		err = k8s.DeleteNodes(toBeDeleted, c.Client)
-		if err != nil {
-			log.WithError(err).Errorf("failed to delete nodes from kubernetes")
-			return 0, err
+		if k8sErr != nil {
+			return 0, k8sErr
 		}
 		log.Infof("Sent delete request to %v nodes", len(toBeDeleted))
 		metrics.NodeGroupPodsEvicted.WithLabelValues(opts.nodeGroup.Opts.Name).Add(float64(podsRemaining))
+		recordNodeLifetimes(opts.nodeGroup, toBeDeleted, time.Now())
+		c.recordAction(opts.nodeGroup, ActionDeleted, toBeDeleted)
+		if deleteErr != nil {
+			return -len(toBeDeleted), deleteErr
+		}
 	}
 
 	return -len(toBeDeleted), nil
 }
 
+// recordNodeLifetimes records how long the deleted nodes lived and how long they were tainted for
+func recordNodeLifetimes(nodeGroup *NodeGroupState, nodes []*v1.Node, now duration.Time) {
+	for _, node := range nodes {
+		metrics.NodeGroupNodeLifetime.WithLabelValues(nodeGroup.Opts.Name).Observe(now.Sub(node.CreationTimestamp.Time).Seconds())
+		taintedTime, err := k8s.GetToBeRemovedTime(node)
+		if err != nil || taintedTime == nil {
+			continue
+		}
+		metrics.NodeGroupNodeTaintedDuration.WithLabelValues(nodeGroup.Opts.Name).Observe(now.Sub(*taintedTime).Seconds())
+	}
+}
+
+// deleteFromCloudProvider terminates the instances of the nodes, and returns the nodes that were deleted. A failure is
+// charged only to the nodes that failed, so one bad node doesn't back off the rest of the batch
+func (c *Controller) deleteFromCloudProvider(ctx context.Context, nodeGroup *NodeGroupState, cloudProviderNodeGroup cloudprovider.NodeGroup, nodes []*v1.Node) ([]*v1.Node, error) {
+	err := cloudProviderNodeGroup.DeleteNodes(ctx, nodes...)
+	if err == nil {
+		return nodes, nil
+	}
+
+	failed := make(map[string]error)
+	switch e := err.(type) {
+	case *cloudprovider.NodesNotDeleted:
+		failed = e.Failed
+	case *cloudprovider.NodeNotInNodeGroup:
+		// nothing was deleted, and the other nodes aren't retried as the node group can't be trusted
+		for _, node := range nodes {
+			if node.Name == e.NodeName {
+				recordDeletionFailure(nodeGroup, []*v1.Node{node}, err, time.Now())
+			}
+		}
+		return nil, err
+	default:
+		if len(nodes) == 1 || errorkind.Is(err, errorkind.Throttled) || errorkind.Is(err, errorkind.Conflict) {
+			for _, node := range nodes {
+				failed[node.Name] = err
+			}
+			break
+		}
+		// the cloud provider doesn't say which of the nodes failed, so they are retried one by one to find out
+		log.WithField("nodegroup", nodeGroup.Opts.Name).WithError(err).Warningf("failed to delete %v nodes at once, retrying them one by one", len(nodes))
+		for _, node := range nodes {
+			if nodeErr := cloudProviderNodeGroup.DeleteNodes(ctx, node); nodeErr != nil {
+				failed[node.Name] = nodeErr
+			}
+		}
+	}
+
+	deleted := make([]*v1.Node, 0, len(nodes))
+	notDeleted := make([]*v1.Node, 0, len(failed))
+	for _, node := range nodes {
+		nodeErr, ok := failed[node.Name]
+		if !ok {
+			deleted = append(deleted, node)
+			continue
+		}
+		log.WithError(nodeErr).Errorf("failed to terminate node in cloud provider %v, %v", node.Name, node.Spec.ProviderID)
+		recordDeletionFailure(nodeGroup, []*v1.Node{node}, nodeErr, time.Now())
+		notDeleted = append(notDeleted, node)
+	}
+	// the nodes that won't be retried don't stay cordoned
+	c.uncordonAbandonedDeletions(nodeGroup, nil, notDeleted)
+	if len(notDeleted) == 0 {
+		return deleted, nil
+	}
+	return deleted, err
+}
+
+// auditDeleteNodes dry runs the deletion of the nodes in the cloud provider instead of deleting them.
+// The nodes are left in kubernetes as the instances behind them are still running
+func auditDeleteNodes(ctx context.Context, cloudProviderNodeGroup cloudprovider.NodeGroup, nodes []*v1.Node) error {
+	dryRunner, ok := cloudProviderNodeGroup.(cloudprovider.DeletionDryRunner)
+	if !ok {
+		log.WithField("audit", true).Warningf("cloud provider node group %v doesn't support dry run deletions. Not deleting %v nodes", cloudProviderNodeGroup.ID(), len(nodes))
+		return nil
+	}
+
+	if err := dryRunner.DryRunDeleteNodes(ctx, nodes...); err != nil {
+		log.WithField("audit", true).WithError(err).Errorf("dry run deletion of %v nodes failed", len(nodes))
+		return err
+	}
+	for _, node := range nodes {
+		log.WithField("audit", true).Infof("dry run deletion of node %v, %v succeeded", node.Name, node.Spec.ProviderID)
+	}
+	return nil
+}
+
 func (c *Controller) scaleDownTaint(opts scaleOpts) (int, error) {
 	nodegroupName := opts.nodeGroup.Opts.Name
 	nodesToRemove := opts.nodesDelta
+	// the minimum is raised while the node group holds a high water mark
+	minimum := minNodes(opts.nodeGroup, time.Now())
 
 	// Clamp the scale down so it doesn't drop under the min nodes
-	if len(opts.untaintedNodes)-nodesToRemove < opts.nodeGroup.Opts.MinNodes {
+	if len(opts.untaintedNodes)-nodesToRemove < minimum {
 		// Set the delta to maximum amount we can remove without going over
-		nodesToRemove = len(opts.untaintedNodes) - opts.nodeGroup.Opts.MinNodes
+		nodesToRemove = len(opts.untaintedNodes) - minimum
 
-		log.Infof("untainted nodes close to minimum (%v). Adjusting taint amount to (%v)", opts.nodeGroup.Opts.MinNodes, nodesToRemove)
+		log.Infof("untainted nodes close to minimum (%v). Adjusting taint amount to (%v)", minimum, nodesToRemove)
+		c.recordGuardrail(opts.nodeGroup, GuardrailMinNodes, "scale down of %v nodes clamped to %v by the minimum of %v nodes", opts.nodesDelta, nodesToRemove, minimum)
 		// If have less node than the minimum, abort!
 		if nodesToRemove < 0 {
-			err := fmt.Errorf(
+			err := errorkind.New(
+				errorkind.Limit,
 				"the number of nodes(%v) is less than specified minimum of %v. Taking no action",
 				len(opts.untaintedNodes),
-				opts.nodeGroup.Opts.MinNodes,
+				minimum,
 			)
 			log.WithError(err).Error("Cancelling scaledown")
 			return 0, err
@@ -135,17 +342,13 @@ func (c *Controller) scaleDownTaint(opts scaleOpts) (int, error) {
 	log.WithField("nodegroup", nodegroupName).Infof("Scaling Down: tainting %v nodes", nodesToRemove)
 	metrics.NodeGroupTaintEvent.WithLabelValues(nodegroupName).Add(float64(nodesToRemove))
 
-	// Lock the tainting to a maximum on 10 nodes
-	if err := k8s.BeginTaintFailSafe(nodesToRemove); err != nil {
-		// Don't taint if there was an error on the lock
-		log.Errorf("Failed to get safety lock on tainter: %v", err)
-		return 0, err
-	}
-	// Perform the tainting loop with the fail safe around it
-	tainted := c.taintOldestN(opts.untaintedNodes, opts.nodeGroup, nodesToRemove)
+	// the taints of this run are limited by its own budget, capped at a maximum of 10 nodes
+	budget := k8s.NewTaintBudget(nodesToRemove)
+	tainted := c.taintOldestN(opts.ctx, opts.untaintedNodes, opts.nodeGroup, budget)
 	// Validate the fail-safe worked
-	if err := k8s.EndTaintFailSafe(len(tainted)); err != nil {
-		log.Errorf("Failed to validate safety lock on tainter: %v", err)
+	if err := budget.Validate(len(tainted)); err != nil {
+		log.Errorf("Failed to validate the taint budget: %v", err)
+		c.recordGuardrail(opts.nodeGroup, GuardrailTaintFailSafe, "tainted nodes failed validation: %v", err)
 		return len(tainted), err
 	}
 
@@ -153,41 +356,172 @@ func (c *Controller) scaleDownTaint(opts scaleOpts) (int, error) {
 	return len(tainted), nil
 }
 
-// taintOldestN sorts nodes by creation time and taints the oldest N. It will return an array of indices of the nodes it tainted
+// taintOldestN sorts nodes by the taint_selection_policy, oldest first by default, and taints the first N of the taint budget. It will return an array of indices of the nodes it tainted
 // indices are from the parameter nodes indexes, not the sorted index
-func (c *Controller) taintOldestN(nodes []*v1.Node, nodeGroup *NodeGroupState, n int) []int {
-	sorted := make(nodesByOldestCreationTime, 0, len(nodes))
-	for i, node := range nodes {
-		sorted = append(sorted, nodeIndexBundle{node, i})
+func (c *Controller) taintOldestN(ctx context.Context, nodes []*v1.Node, nodeGroup *NodeGroupState, budget *k8s.TaintBudget) []int {
+	n := budget.Target()
+	sorted := taintOrder(nodeGroup, nodes, time.Now())
+	if len(nodeGroup.Opts.CloudProviderGroupNames) > 1 && c.cloudProvider != nil {
+		if cloudProviderNodeGroup, ok := getCloudProviderNodeGroup(c.cloudProvider, nodeGroup.Opts); ok {
+			if grouped, ok := cloudProviderNodeGroup.(*cloudprovider.GroupedNodeGroup); ok {
+				sorted = spreadTaintOrder(nodeGroup, sorted, grouped)
+			}
+		}
 	}
-	sort.Sort(sorted)
 
 	taintedIndices := make([]int, 0, n)
+	var taintedNodes []*v1.Node
 	for i, bundle := range sorted {
 		// stop at N (or when array is fully iterated)
-		if len(taintedIndices) >= n || i >= k8s.MaximumTaints {
+		if len(taintedIndices) >= n {
 			break
 		}
+		if i >= k8s.MaximumTaints {
+			c.recordGuardrail(nodeGroup, GuardrailMaximumTaints, "tainted %v of %v nodes, capped at the maximum of %v nodes per run", len(taintedIndices), n, k8s.MaximumTaints)
+			break
+		}
+		if err := contextErr(ctx, "tainting any more nodes"); err != nil {
+			log.WithField("nodegroup", nodeGroup.Opts.Name).WithError(err).Warn("Run was cancelled")
+			break
+		}
+		// nodes running static pods are never scaled down, even when unhealthy
+		if c.staticPodsBlockScaleDown(nodeGroup, bundle.node, "tainting") {
+			continue
+		}
+		if k8s.NodeHasAnyCondition(bundle.node, nodeGroup.Opts.UnhealthyNodeConditions) {
+			log.WithField("nodegroup", nodeGroup.Opts.Name).Infof("Node %v has an unhealthy condition, prioritising it for tainting", bundle.node.Name)
+		} else if nodeGroup.maintenance.scheduled(bundle.node) {
+			log.WithField("nodegroup", nodeGroup.Opts.Name).Infof("Node %v has scheduled maintenance, prioritising it for tainting", bundle.node.Name)
+		} else if nodeGroup.thirdPartyTaintPrioritised(bundle.node) {
+			log.WithField("nodegroup", nodeGroup.Opts.Name).Infof("Node %v has a third party taint, prioritising it for tainting", bundle.node.Name)
+		} else if nodeGroup.outdatedNodes[bundle.node.Name] {
+			log.WithField("nodegroup", nodeGroup.Opts.Name).Infof("Node %v has an outdated kubelet or OS image, prioritising it for tainting", bundle.node.Name)
+		}
+
+		// don't taint a node if its pods, and those of the nodes already tainted, couldn't fit on the rest of the nodes
+		if !nodeGroup.Opts.AggressiveScaleDown && !podsFitOnRemainingNodes(nodes, append(taintedNodes, bundle.node), nodeGroup) {
+			log.WithField("nodegroup", nodeGroup.Opts.Name).Infof(
+				"Not tainting node %v: its pods could not be rescheduled onto the remaining nodes",
+				bundle.node.Name,
+			)
+			continue
+		}
 
 		// only actually taint in dry mode
-		if !c.dryMode(nodeGroup) {
+		if !c.dryTaint(nodeGroup) {
 			log.WithField("drymode", "off").Infof("Tainting node %v", bundle.node.Name)
 
 			// Taint the node
-			updatedNode, err := k8s.AddToBeRemovedTaint(bundle.node, c.Client, nodeGroup.Opts.TaintEffect)
+			taint, cordon := nodeGroup.Opts.scaleDownMarks()
+			now := time.Now()
+			intent := nodeGroup.removalIntent(bundle.node, nodeGroup.removalReason(bundle.node, now), now)
+			updatedNode, err := k8s.MarkToBeRemoved(bundle.node, c.Client, nodeGroup.Opts.TaintEffect, taint, cordon, intent, budget)
 			if err != nil {
 				log.Errorf("While tainting %v: %v", bundle.node.Name, err)
 			} else {
 				bundle.node = updatedNode
 				taintedIndices = append(taintedIndices, bundle.index)
+				taintedNodes = append(taintedNodes, bundle.node)
 			}
 		} else {
-			nodeGroup.taintTracker = append(nodeGroup.taintTracker, bundle.node.Name)
-			k8s.IncrementTaintCount()
+			if err := budget.Allow(); err != nil {
+				log.WithField("drymode", "on").Errorf("While tainting %v: %v", bundle.node.Name, err)
+				break
+			}
+			nodeGroup.dryTaintNode(bundle.node, time.Now())
+			budget.Spend()
 			taintedIndices = append(taintedIndices, bundle.index)
+			taintedNodes = append(taintedNodes, bundle.node)
 			log.WithField("drymode", "on").Infof("Tainting node %v", bundle.node.Name)
 		}
 	}
 
+	if !c.dryTaint(nodeGroup) {
+		c.recordAction(nodeGroup, ActionTainted, taintedNodes)
+		c.tagTaintedInstances(callContext(ctx), nodeGroup, taintedNodes, time.Now())
+	}
 	return taintedIndices
 }
+
+// taintOrder returns the nodes in the order they are tainted in, by the taint_selection_policy
+func taintOrder(nodeGroup *NodeGroupState, nodes []*v1.Node, now duration.Time) nodesByOldestCreationTime {
+	sorted := make(nodesByOldestCreationTime, 0, len(nodes))
+	for i, node := range nodes {
+		sorted = append(sorted, nodeIndexBundle{node, i})
+	}
+	// nodes with unhealthy conditions or scheduled maintenance are tainted first, whatever the policy
+	byUnhealthy := nodesByUnhealthyThenOldestCreationTime{sorted, nodeGroup.prioritisedForTainting}
+	if scores := taintSelectionScores(nodeGroup, nodes, now); scores != nil {
+		// the nodes with the lowest scores are tainted first, e.g. the lowest disruption scores minimise the total
+		// disruption of the N nodes
+		sort.Sort(nodesByUnhealthyThenLowestScore{byUnhealthy, scores})
+	} else if nodeGroup.Opts.taintSelectionPolicy() == TaintSelectionPolicyNewest {
+		sort.Sort(nodesByUnhealthyThenNewestCreationTime{byUnhealthy})
+	} else {
+		sort.Sort(byUnhealthy)
+	}
+	return sorted
+}
+
+// spreadTaintOrder reorders the nodes of a node group backed by several cloud provider groups, so the nodes tainted are
+// spread across the groups in proportion to their untainted nodes rather than taken wherever the first nodes of the
+// order happen to be. This keeps the sizes of the groups near their ratios. The nodes prioritised for tainting stay
+// first, the order of the nodes within each group is kept, and nodes that belong to none of the groups go last
+func spreadTaintOrder(nodeGroup *NodeGroupState, sorted nodesByOldestCreationTime, grouped *cloudprovider.GroupedNodeGroup) nodesByOldestCreationTime {
+	spread := make(nodesByOldestCreationTime, 0, len(sorted))
+	queues := make(map[string][]nodeIndexBundle)
+	weights := make(map[string]int)
+	var ungrouped []nodeIndexBundle
+	for _, bundle := range sorted {
+		group, ok := grouped.GroupOf(bundle.node)
+		if ok {
+			weights[group.ID()]++
+		}
+		switch {
+		case nodeGroup.prioritisedForTainting(bundle.node):
+			spread = append(spread, bundle)
+		case ok:
+			queues[group.ID()] = append(queues[group.ID()], bundle)
+		default:
+			ungrouped = append(ungrouped, bundle)
+		}
+	}
+
+	roundRobin := newWeightedRoundRobin(weights)
+	for {
+		var eligible []string
+		for _, group := range grouped.Groups() {
+			if len(queues[group.ID()]) > 0 {
+				eligible = append(eligible, group.ID())
+			}
+		}
+		if len(eligible) == 0 {
+			break
+		}
+		id := roundRobin.next(eligible)
+		spread = append(spread, queues[id][0])
+		queues[id] = queues[id][1:]
+	}
+	return append(spread, ungrouped...)
+}
+
+// podsFitOnRemainingNodes simulates whether the pods on the removed nodes could be rescheduled onto the rest of the nodes
+func podsFitOnRemainingNodes(nodes []*v1.Node, removed []*v1.Node, nodeGroup *NodeGroupState) bool {
+	removedNames := make(map[string]bool, len(removed))
+	var pods []*v1.Pod
+	for _, node := range removed {
+		removedNames[node.Name] = true
+		pods = append(pods, k8s.NodeReschedulablePods(node, nodeGroup.NodeInfoMap)...)
+	}
+	if len(pods) == 0 {
+		return true
+	}
+
+	remaining := make([]*v1.Node, 0, len(nodes))
+	for _, node := range nodes {
+		if !removedNames[node.Name] {
+			remaining = append(remaining, node)
+		}
+	}
+	return k8s.NewSchedulingSimulator(remaining, nodeGroup.NodeInfoMap).TrySchedule(pods)
+}
@@ -1,10 +1,15 @@
 package controller
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
+	"github.com/atlassian/escalator/pkg/cloudprovider"
 	"github.com/atlassian/escalator/pkg/k8s"
 	"github.com/atlassian/escalator/pkg/test"
 	"github.com/stretchr/testify/assert"
@@ -84,11 +89,12 @@ func TestControllerScaleDownTaint(t *testing.T) {
 			"test valid taint 2",
 			args{
 				scaleOpts{
-					nodes,
-					[]*v1.Node{},
-					nodes,
-					nodeGroupsState["buildeng"],
-					2,
+					nodes:          nodes,
+					taintedNodes:   []*v1.Node{},
+					untaintedNodes: nodes,
+					nodeGroup:      nodeGroupsState["buildeng"],
+					nodesDelta:     2,
+					ctx:            context.Background(),
 				},
 			},
 			2,
@@ -99,11 +105,12 @@ func TestControllerScaleDownTaint(t *testing.T) {
 			"test try taint 4, min nodes = 3, total nodes = 6",
 			args{
 				scaleOpts{
-					nodes,
-					[]*v1.Node{},
-					nodes,
-					nodeGroupsState["buildeng"],
-					4,
+					nodes:          nodes,
+					taintedNodes:   []*v1.Node{},
+					untaintedNodes: nodes,
+					nodeGroup:      nodeGroupsState["buildeng"],
+					nodesDelta:     4,
+					ctx:            context.Background(),
 				},
 			},
 			3,
@@ -114,11 +121,12 @@ func TestControllerScaleDownTaint(t *testing.T) {
 			"test try taint 4, min nodes = 3, total nodes = 2",
 			args{
 				scaleOpts{
-					nodes[:2],
-					[]*v1.Node{},
-					nodes[:2],
-					nodeGroupsState["buildeng"],
-					4,
+					nodes:          nodes[:2],
+					taintedNodes:   []*v1.Node{},
+					untaintedNodes: nodes[:2],
+					nodeGroup:      nodeGroupsState["buildeng"],
+					nodesDelta:     4,
+					ctx:            context.Background(),
 				},
 			},
 			0,
@@ -129,11 +137,12 @@ func TestControllerScaleDownTaint(t *testing.T) {
 			"test try taint 4, min nodes = 0, total nodes = 3",
 			args{
 				scaleOpts{
-					nodes[:3],
-					[]*v1.Node{},
-					nodes[:3],
-					nodeGroupsState["default"],
-					4,
+					nodes:          nodes[:3],
+					taintedNodes:   []*v1.Node{},
+					untaintedNodes: nodes[:3],
+					nodeGroup:      nodeGroupsState["default"],
+					nodesDelta:     4,
+					ctx:            context.Background(),
 				},
 			},
 			3,
@@ -144,11 +153,12 @@ func TestControllerScaleDownTaint(t *testing.T) {
 			"test try taint 4, min nodes = 0, total nodes = 6",
 			args{
 				scaleOpts{
-					nodes,
-					[]*v1.Node{},
-					nodes,
-					nodeGroupsState["default"],
-					4,
+					nodes:          nodes,
+					taintedNodes:   []*v1.Node{},
+					untaintedNodes: nodes,
+					nodeGroup:      nodeGroupsState["default"],
+					nodesDelta:     4,
+					ctx:            context.Background(),
 				},
 			},
 			4,
@@ -330,9 +340,9 @@ func TestControllerTaintOldestN(t *testing.T) {
 			}
 			// test wet mode
 			c.Opts.DryMode = false
-			assert.NoError(t, k8s.BeginTaintFailSafe(len(tt.want)))
-			got := c.taintOldestN(tt.args.nodes, tt.args.nodeGroup, tt.args.n)
-			assert.NoError(t, k8s.EndTaintFailSafe(len(got)))
+			budget := k8s.NewTaintBudget(tt.args.n)
+			got := c.taintOldestN(context.Background(), tt.args.nodes, tt.args.nodeGroup, budget)
+			assert.NoError(t, budget.Validate(len(got)))
 			eq := assert.Equal(t, tt.want, got)
 			if eq {
 				for _, i := range got {
@@ -348,10 +358,10 @@ func TestControllerTaintOldestN(t *testing.T) {
 			}
 
 			// test dry mode
-			assert.NoError(t, k8s.BeginTaintFailSafe(len(tt.want)))
 			c.Opts.DryMode = true
-			got = c.taintOldestN(tt.args.nodes, tt.args.nodeGroup, tt.args.n)
-			assert.NoError(t, k8s.EndTaintFailSafe(len(got)))
+			budget = k8s.NewTaintBudget(tt.args.n)
+			got = c.taintOldestN(context.Background(), tt.args.nodes, tt.args.nodeGroup, budget)
+			assert.NoError(t, budget.Validate(len(got)))
 			assert.Equal(t, tt.want, got)
 
 			// untaint all
@@ -366,6 +376,420 @@ func TestControllerTaintOldestN(t *testing.T) {
 	}
 }
 
+func TestControllerTaintOldestN_Reschedulable(t *testing.T) {
+	nodes := []*v1.Node{
+		0: test.BuildTestNode(test.NodeOpts{
+			Name:     "n1",
+			CPU:      1000,
+			Mem:      1000,
+			Creation: time.Date(2010, 3, 3, 13, 0, 0, 0, time.UTC),
+		}),
+		1: test.BuildTestNode(test.NodeOpts{
+			Name:     "n2",
+			CPU:      1000,
+			Mem:      1000,
+			Creation: time.Date(2011, 3, 3, 13, 0, 0, 0, time.UTC),
+		}),
+		2: test.BuildTestNode(test.NodeOpts{
+			Name:     "n3",
+			CPU:      1000,
+			Mem:      1000,
+			Creation: time.Date(2012, 3, 3, 13, 0, 0, 0, time.UTC),
+		}),
+	}
+
+	tests := []struct {
+		name       string
+		pods       []*v1.Pod
+		aggressive bool
+		want       []int
+	}{
+		{
+			"pods fit on the remaining nodes",
+			[]*v1.Pod{
+				test.BuildTestPod(test.PodOpts{NodeName: "n1", CPU: []int64{400}, Mem: []int64{400}}),
+				test.BuildTestPod(test.PodOpts{NodeName: "n2", CPU: []int64{400}, Mem: []int64{400}}),
+			},
+			false,
+			[]int{0},
+		},
+		{
+			"pods don't fit on the remaining nodes",
+			[]*v1.Pod{
+				test.BuildTestPod(test.PodOpts{NodeName: "n1", CPU: []int64{800}, Mem: []int64{800}}),
+				test.BuildTestPod(test.PodOpts{NodeName: "n2", CPU: []int64{800}, Mem: []int64{800}}),
+				test.BuildTestPod(test.PodOpts{NodeName: "n3", CPU: []int64{800}, Mem: []int64{800}}),
+			},
+			false,
+			[]int{},
+		},
+		{
+			"skip nodes whose pods don't fit",
+			[]*v1.Pod{
+				test.BuildTestPod(test.PodOpts{NodeName: "n1", CPU: []int64{800}, Mem: []int64{800}}),
+				test.BuildTestPod(test.PodOpts{NodeName: "n2", CPU: []int64{300}, Mem: []int64{300}}),
+				test.BuildTestPod(test.PodOpts{NodeName: "n3", CPU: []int64{300}, Mem: []int64{300}}),
+			},
+			false,
+			[]int{1},
+		},
+		{
+			"aggressive scale down ignores the pods",
+			[]*v1.Pod{
+				test.BuildTestPod(test.PodOpts{NodeName: "n1", CPU: []int64{800}, Mem: []int64{800}}),
+				test.BuildTestPod(test.PodOpts{NodeName: "n2", CPU: []int64{800}, Mem: []int64{800}}),
+				test.BuildTestPod(test.PodOpts{NodeName: "n3", CPU: []int64{800}, Mem: []int64{800}}),
+			},
+			true,
+			[]int{0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodeGroup := &NodeGroupState{
+				Opts: NodeGroupOptions{
+					Name:                "buildeng",
+					AggressiveScaleDown: tt.aggressive,
+				},
+				NodeInfoMap: k8s.CreateNodeNameToInfoMap(tt.pods, nodes),
+			}
+			c := &Controller{
+				Opts: Opts{DryMode: true},
+			}
+
+			budget := k8s.NewTaintBudget(1)
+			got := c.taintOldestN(context.Background(), nodes, nodeGroup, budget)
+			assert.NoError(t, budget.Validate(len(got)))
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestControllerTryRemoveTaintedNodes_Reschedulable(t *testing.T) {
+	now := time.Now()
+	tainted := test.BuildTestNode(test.NodeOpts{Name: "n1", CPU: 1000, Mem: 1000})
+	untainted := test.BuildTestNode(test.NodeOpts{Name: "n2", CPU: 1000, Mem: 1000})
+
+	tests := []struct {
+		name        string
+		pods        []*v1.Pod
+		aggressive  bool
+		wantDeleted map[string]bool
+	}{
+		{
+			"pods fit on the untainted nodes",
+			[]*v1.Pod{
+				test.BuildTestPod(test.PodOpts{NodeName: "n1", CPU: []int64{400}, Mem: []int64{400}}),
+				test.BuildTestPod(test.PodOpts{NodeName: "n2", CPU: []int64{400}, Mem: []int64{400}}),
+			},
+			false,
+			map[string]bool{"n1": true},
+		},
+		{
+			// the node is past its hard delete grace period, but its deletion is deferred
+			"pods don't fit on the untainted nodes",
+			[]*v1.Pod{
+				test.BuildTestPod(test.PodOpts{NodeName: "n1", CPU: []int64{800}, Mem: []int64{800}}),
+				test.BuildTestPod(test.PodOpts{NodeName: "n2", CPU: []int64{800}, Mem: []int64{800}}),
+			},
+			false,
+			nil,
+		},
+		{
+			"aggressive scale down ignores the pods",
+			[]*v1.Pod{
+				test.BuildTestPod(test.PodOpts{NodeName: "n1", CPU: []int64{800}, Mem: []int64{800}}),
+				test.BuildTestPod(test.PodOpts{NodeName: "n2", CPU: []int64{800}, Mem: []int64{800}}),
+			},
+			true,
+			map[string]bool{"n1": true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodeGroup := &NodeGroupState{
+				Opts: NodeGroupOptions{
+					Name:                  "buildeng",
+					DryMode:               true,
+					SoftDeleteGracePeriod: "1m",
+					HardDeleteGracePeriod: "10m",
+					AggressiveScaleDown:   tt.aggressive,
+				},
+				NodeInfoMap: k8s.CreateNodeNameToInfoMap(tt.pods, []*v1.Node{tainted, untainted}),
+			}
+			nodeGroup.dryTaintNode(tainted, now.Add(-20*time.Minute))
+			c := &Controller{}
+
+			removed, err := c.TryRemoveTaintedNodes(scaleOpts{
+				nodes:          []*v1.Node{tainted, untainted},
+				taintedNodes:   []*v1.Node{tainted},
+				untaintedNodes: []*v1.Node{untainted},
+				nodeGroup:      nodeGroup,
+				ctx:            context.Background(),
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, 0, removed)
+			assert.Equal(t, tt.wantDeleted, nodeGroup.simulated.deleted)
+		})
+	}
+}
+
+func TestControllerTryRemoveTaintedNodes_RescheduleMaxDeferral(t *testing.T) {
+	now := time.Now()
+	tainted := test.BuildTestNode(test.NodeOpts{Name: "n1", CPU: 1000, Mem: 1000})
+	untainted := test.BuildTestNode(test.NodeOpts{Name: "n2", CPU: 1000, Mem: 1000})
+	pods := []*v1.Pod{
+		test.BuildTestPod(test.PodOpts{NodeName: "n1", CPU: []int64{800}, Mem: []int64{800}}),
+		test.BuildTestPod(test.PodOpts{NodeName: "n2", CPU: []int64{800}, Mem: []int64{800}}),
+	}
+	nodeGroup := &NodeGroupState{
+		Opts: NodeGroupOptions{
+			Name:                  "buildeng",
+			DryMode:               true,
+			SoftDeleteGracePeriod: "1m",
+			HardDeleteGracePeriod: "10m",
+			RescheduleMaxDeferral: "30m",
+		},
+		NodeInfoMap: k8s.CreateNodeNameToInfoMap(pods, []*v1.Node{tainted, untainted}),
+	}
+	nodeGroup.dryTaintNode(tainted, now.Add(-20*time.Minute))
+	c := &Controller{}
+	opts := scaleOpts{
+		nodes:          []*v1.Node{tainted, untainted},
+		taintedNodes:   []*v1.Node{tainted},
+		untaintedNodes: []*v1.Node{untainted},
+		nodeGroup:      nodeGroup,
+		ctx:            context.Background(),
+	}
+
+	// the first deferral is remembered
+	_, err := c.TryRemoveTaintedNodes(opts)
+	assert.NoError(t, err)
+	assert.Nil(t, nodeGroup.simulated.deleted)
+	assert.Contains(t, nodeGroup.rescheduleDeferrals, "n1")
+
+	// still within the maximum deferral
+	nodeGroup.rescheduleDeferrals["n1"] = now.Add(-29 * time.Minute)
+	_, err = c.TryRemoveTaintedNodes(opts)
+	assert.NoError(t, err)
+	assert.Nil(t, nodeGroup.simulated.deleted)
+
+	// deleted anyway once it was deferred for the maximum
+	nodeGroup.rescheduleDeferrals["n1"] = now.Add(-31 * time.Minute)
+	_, err = c.TryRemoveTaintedNodes(opts)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]bool{"n1": true}, nodeGroup.simulated.deleted)
+
+	// the deferral is forgotten once the node is no longer tainted
+	opts.taintedNodes = nil
+	_, err = c.TryRemoveTaintedNodes(opts)
+	assert.NoError(t, err)
+	assert.NotContains(t, nodeGroup.rescheduleDeferrals, "n1")
+}
+
+func TestControllerTaintOldestN_UnhealthyFirst(t *testing.T) {
+	nodes := []*v1.Node{
+		0: test.BuildTestNode(test.NodeOpts{Name: "n1", Creation: time.Date(2010, 3, 3, 13, 0, 0, 0, time.UTC)}),
+		1: test.BuildTestNode(test.NodeOpts{Name: "n2", Creation: time.Date(2011, 3, 3, 13, 0, 0, 0, time.UTC)}),
+		2: test.BuildTestNode(test.NodeOpts{Name: "n3", Creation: time.Date(2012, 3, 3, 13, 0, 0, 0, time.UTC)}),
+	}
+	nodes[2].Status.Conditions = []v1.NodeCondition{{Type: "KernelDeadlock", Status: v1.ConditionTrue}}
+
+	tests := []struct {
+		name       string
+		conditions []string
+		want       []int
+	}{
+		{"oldest without conditions", nil, []int{0}},
+		{"unhealthy node first", []string{"KernelDeadlock", "ReadonlyFilesystem"}, []int{2}},
+		{"other conditions", []string{"ReadonlyFilesystem"}, []int{0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodeGroup := &NodeGroupState{
+				Opts: NodeGroupOptions{
+					Name:                    "buildeng",
+					UnhealthyNodeConditions: tt.conditions,
+				},
+				NodeInfoMap: k8s.CreateNodeNameToInfoMap(nil, nodes),
+			}
+			c := &Controller{
+				Opts: Opts{DryMode: true},
+			}
+
+			budget := k8s.NewTaintBudget(1)
+			got := c.taintOldestN(context.Background(), nodes, nodeGroup, budget)
+			assert.NoError(t, budget.Validate(len(got)))
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestControllerTaintOldestN_SpreadAcrossGroups(t *testing.T) {
+	// the nodes of asg-a are all older than the nodes of asg-b
+	var nodes []*v1.Node
+	for i, name := range []string{"a1", "a2", "a3", "a4", "b1", "b2"} {
+		nodes = append(nodes, test.BuildTestNode(test.NodeOpts{Name: name, Creation: time.Date(2010+i, 3, 3, 13, 0, 0, 0, time.UTC)}))
+	}
+	nodes[5].Status.Conditions = []v1.NodeCondition{{Type: "KernelDeadlock", Status: v1.ConditionTrue}}
+
+	tests := []struct {
+		name  string
+		opts  NodeGroupOptions
+		taint int
+		want  []int
+	}{
+		{"single group takes the oldest", NodeGroupOptions{CloudProviderGroupName: "asg-a"}, 3, []int{0, 1, 2}},
+		{"spread by the size of each group", NodeGroupOptions{CloudProviderGroupNames: []string{"asg-a", "asg-b"}}, 3, []int{0, 4, 1}},
+		{"unhealthy node first", NodeGroupOptions{CloudProviderGroupNames: []string{"asg-a", "asg-b"}, UnhealthyNodeConditions: []string{"KernelDeadlock"}}, 3, []int{5, 0, 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cloud := test.NewCloudProvider(2)
+			cloud.RegisterNodeGroup(test.NewNodeGroup("asg-a", 0, 10, 4).WithNodes("a1", "a2", "a3", "a4"))
+			cloud.RegisterNodeGroup(test.NewNodeGroup("asg-b", 0, 10, 2).WithNodes("b1", "b2"))
+
+			tt.opts.Name = "buildeng"
+			nodeGroup := &NodeGroupState{
+				Opts:        tt.opts,
+				NodeInfoMap: k8s.CreateNodeNameToInfoMap(nil, nodes),
+			}
+			c := &Controller{
+				Opts:          Opts{DryMode: true},
+				cloudProvider: cloud,
+			}
+
+			budget := k8s.NewTaintBudget(tt.taint)
+			got := c.taintOldestN(context.Background(), nodes, nodeGroup, budget)
+			assert.NoError(t, budget.Validate(len(got)))
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
 func TestControllerScaleDown(t *testing.T) {
 	t.Skip("test not implemented")
 }
+
+func TestControllerTryRemoveTaintedNodes_ProviderWriteLimits(t *testing.T) {
+	var nodes []*v1.Node
+	for i := 1; i <= 3; i++ {
+		node := test.BuildTestNode(test.NodeOpts{Name: fmt.Sprintf("n%v", i), Tainted: true})
+		node.Spec.Taints[0].Value = fmt.Sprint(time.Now().Add(-time.Hour).Unix())
+		nodes = append(nodes, node)
+	}
+
+	hookCalls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hookCalls++
+		json.NewEncoder(w).Encode(PreDeleteHookResponse{Allow: true})
+	}))
+	defer server.Close()
+
+	nodeGroup := &NodeGroupState{
+		Opts: NodeGroupOptions{
+			Name:                   "default",
+			CloudProviderGroupName: "default",
+			SoftDeleteGracePeriod:  "1m",
+			HardDeleteGracePeriod:  "10m",
+			PreDeleteHook:          PreDeleteHookOptions{URL: server.URL},
+		},
+		NodeInfoMap: k8s.CreateNodeNameToInfoMap(nil, nodes),
+	}
+	client, _ := test.BuildFakeClient(nodes, nil)
+	cloudProvider := test.NewCloudProvider(1)
+	cloudProviderNodeGroup := test.NewNodeGroup("default", 0, 10, 3)
+	cloudProvider.RegisterNodeGroup(cloudProviderNodeGroup)
+	c := &Controller{
+		Client:         &Client{Interface: client},
+		cloudProvider:  cloudProvider,
+		providerWrites: newProviderWriteLimiter(ProviderWriteLimits{QPS: 0.01, Burst: 2, GroupSharePercent: 100}),
+	}
+
+	removed, err := c.TryRemoveTaintedNodes(scaleOpts{
+		nodes:        nodes,
+		taintedNodes: nodes,
+		nodeGroup:    nodeGroup,
+		ctx:          context.Background(),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, -2, removed)
+	assert.Equal(t, int64(1), cloudProviderNodeGroup.TargetSize())
+	// the node refused by the limits isn't asked about by the pre delete hook
+	assert.Equal(t, 2, hookCalls)
+	// nor cordoned
+	assert.Equal(t, 2, countCordonedForDeletion(nodes))
+	assert.False(t, k8s.CordonedForDeletion(nodes[2]))
+}
+
+// failingNodeGroup is a cloud provider node group that fails to delete some of the nodes. It either says which nodes
+// failed, or fails the whole batch when it has one of them
+type failingNodeGroup struct {
+	*test.NodeGroup
+	failing  map[string]bool
+	perNode  bool
+	attempts int
+}
+
+func (n *failingNodeGroup) DeleteNodes(ctx context.Context, nodes ...*v1.Node) error {
+	n.attempts++
+	failed := make(map[string]error)
+	var deleted []*v1.Node
+	for _, node := range nodes {
+		if n.failing[node.Name] {
+			failed[node.Name] = fmt.Errorf("instance of %v is protected", node.Name)
+			continue
+		}
+		deleted = append(deleted, node)
+	}
+	if len(failed) == 0 {
+		return n.NodeGroup.DeleteNodes(context.Background(), nodes...)
+	}
+	if !n.perNode {
+		return fmt.Errorf("failed to delete instances")
+	}
+	n.NodeGroup.DeleteNodes(context.Background(), deleted...)
+	return &cloudprovider.NodesNotDeleted{Failed: failed}
+}
+
+func TestControllerDeleteFromCloudProvider_PartialFailure(t *testing.T) {
+	nodes := []*v1.Node{
+		test.BuildTestNode(test.NodeOpts{Name: "n1", Tainted: true}),
+		test.BuildTestNode(test.NodeOpts{Name: "n2", Tainted: true}),
+		test.BuildTestNode(test.NodeOpts{Name: "n3", Tainted: true}),
+	}
+
+	tests := []struct {
+		name         string
+		perNode      bool
+		wantAttempts int
+	}{
+		{"the cloud provider says which nodes failed", true, 1},
+		{"the batch is retried one by one", false, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodeGroup := &NodeGroupState{Opts: NodeGroupOptions{Name: "default"}}
+			cloudProviderNodeGroup := &failingNodeGroup{
+				NodeGroup: test.NewNodeGroup("default", 0, 10, 3),
+				failing:   map[string]bool{"n2": true},
+				perNode:   tt.perNode,
+			}
+			c := &Controller{}
+
+			deleted, err := c.deleteFromCloudProvider(context.Background(), nodeGroup, cloudProviderNodeGroup, nodes)
+			assert.Error(t, err)
+			assert.Equal(t, []*v1.Node{nodes[0], nodes[2]}, deleted)
+			assert.Equal(t, int64(1), cloudProviderNodeGroup.TargetSize())
+			assert.Equal(t, tt.wantAttempts, cloudProviderNodeGroup.attempts)
+
+			// only the node that failed is charged with the failure
+			if assert.Len(t, nodeGroup.deletionAttempts, 1) {
+				assert.Equal(t, 1, nodeGroup.deletionAttempts["n2"].failures)
+			}
+		})
+	}
+}
@@ -14,6 +14,8 @@ type scaleLock struct {
 	requestedNodes      int
 	lockTime            time.Time
 	minimumLockDuration time.Duration
+	// arrivedNodes are the requested nodes that have registered since the lock was locked
+	arrivedNodes int
 	// Needed for metrics label value
 	nodegroup string
 }
@@ -38,7 +40,9 @@ func (l *scaleLock) lock(nodes int) {
 	log.Debug("Locking scale lock")
 	l.isLocked = true
 	l.requestedNodes = nodes
+	l.arrivedNodes = 0
 	l.lockTime = time.Now()
+	metrics.NodeGroupIncomingNodes.WithLabelValues(l.nodegroup).Set(float64(nodes))
 }
 
 // unlock unlocks the scale lock
@@ -50,11 +54,36 @@ func (l *scaleLock) unlock() {
 		log.Debug(fmt.Sprintf("Unlocking scale lock. Lock Duration: %0.0f s Node Group: %s", lockDuration, l.nodegroup))
 		l.isLocked = false
 		l.requestedNodes = 0
+		l.arrivedNodes = 0
+		metrics.NodeGroupIncomingNodes.WithLabelValues(l.nodegroup).Set(0.0)
 		metrics.NodeGroupScaleLockDuration.WithLabelValues(l.nodegroup).Observe(lockDuration)
 		metrics.NodeGroupScaleLock.WithLabelValues(l.nodegroup).Set(0.0)
 	}
 }
 
+// incomingNodes returns the number of requested nodes that haven't registered yet
+func (l *scaleLock) incomingNodes() int {
+	if !l.isLocked || l.arrivedNodes >= l.requestedNodes {
+		return 0
+	}
+	return l.requestedNodes - l.arrivedNodes
+}
+
+// nodeArrived accounts a node of the node group that registered while locked. The lock is unlocked once every
+// requested node has registered, the scale up cool down period still applies
+func (l *scaleLock) nodeArrived(now time.Time) {
+	if !l.isLocked {
+		return
+	}
+	l.arrivedNodes++
+	metrics.NodeGroupScaleUpNodeArrival.WithLabelValues(l.nodegroup).Observe(now.Sub(l.lockTime).Seconds())
+	metrics.NodeGroupIncomingNodes.WithLabelValues(l.nodegroup).Set(float64(l.incomingNodes()))
+	if l.arrivedNodes >= l.requestedNodes {
+		log.WithField("nodegroup", l.nodegroup).Infof("All %v requested nodes have registered", l.requestedNodes)
+		l.unlock()
+	}
+}
+
 // timeUntilMinimumUnlock returns the the time until the minimum unlock
 func (l *scaleLock) timeUntilMinimumUnlock() time.Duration {
 	return l.lockTime.Add(l.minimumLockDuration).Sub(time.Now())
@@ -62,8 +91,9 @@ func (l *scaleLock) timeUntilMinimumUnlock() time.Duration {
 
 func (l scaleLock) String() string {
 	return fmt.Sprintf(
-		"lock(%v): there are %v upcoming nodes requested, %v before min cooldown.",
+		"lock(%v): there are %v upcoming nodes of %v requested, %v before min cooldown.",
 		l.locked(),
+		l.incomingNodes(),
 		l.requestedNodes,
 		l.timeUntilMinimumUnlock(),
 	)
@@ -1,9 +1,12 @@
 package controller
 
 import (
-	"fmt"
+	"context"
 	"sort"
+	"time"
 
+	"github.com/atlassian/escalator/pkg/cloudprovider"
+	"github.com/atlassian/escalator/pkg/errorkind"
 	"github.com/atlassian/escalator/pkg/k8s"
 	"github.com/atlassian/escalator/pkg/metrics"
 	log "github.com/sirupsen/logrus"
@@ -37,6 +40,7 @@ func (c *Controller) ScaleUp(opts scaleOpts) (int, error) {
 				return 0, err
 			}
 			opts.nodeGroup.scaleUpLock.lock(added)
+			opts.nodeGroup.bootDurations.scaleUpRequested(opts.nodeGroup.scaleUpLock.lockTime)
 			return untainted + added, nil
 		}
 	}
@@ -57,15 +61,19 @@ func (c *Controller) calculateNodesToAdd(nodesToAdd int64, TargetSize int64, Max
 // scaleUpCloudProviderNodeGroup increases the size of the cloud provider node group by opts.nodesDelta
 func (c *Controller) scaleUpCloudProviderNodeGroup(opts scaleOpts) (int, error) {
 
-	cloudProviderNodeGroup, ok := c.cloudProvider.GetNodeGroup(opts.nodeGroup.Opts.CloudProviderGroupName)
+	cloudProviderNodeGroup, ok := getCloudProviderNodeGroup(c.cloudProvider, opts.nodeGroup.Opts)
 	if !ok {
-		return 0, fmt.Errorf("cloud provider node group does not exist: %s", opts.nodeGroup.Opts.CloudProviderGroupName)
+		return 0, errorkind.New(errorkind.NotFound, "cloud provider node group does not exist: %s", opts.nodeGroup.Opts.cloudProviderGroupID())
 	}
 
 	nodegroupName := opts.nodeGroup.Opts.Name
 	nodesToAdd := c.calculateNodesToAdd(int64(opts.nodesDelta), cloudProviderNodeGroup.TargetSize(), cloudProviderNodeGroup.MaxSize())
+	if nodesToAdd < int64(opts.nodesDelta) {
+		c.recordGuardrail(opts.nodeGroup, GuardrailMaxNodes, "scale up of %v nodes clamped to %v by the maximum of %v nodes", opts.nodesDelta, nodesToAdd, cloudProviderNodeGroup.MaxSize())
+	}
 	if nodesToAdd <= 0 {
-		err := fmt.Errorf(
+		err := errorkind.New(
+			errorkind.Limit,
 			"refusing to scaleup up beyond the maximum size of the autoscaling group (TargetSize: %v; MaxNodes: %v). Taking no action",
 			cloudProviderNodeGroup.TargetSize(),
 			opts.nodeGroup.Opts.MaxNodes,
@@ -74,21 +82,47 @@ func (c *Controller) scaleUpCloudProviderNodeGroup(opts scaleOpts) (int, error)
 		return 0, err
 	}
 
+	// don't ask for more nodes than the quotas of the cloud provider account have room for
+	nodesToAdd, err := limitScaleUpToQuota(callContext(opts.ctx), opts.nodeGroup, cloudProviderNodeGroup, nodesToAdd, c.quotaCoordinator(), c.nodeGroups)
+	if err != nil {
+		log.WithError(err).Error("Cancelling scaleup")
+		return 0, err
+	}
+
 	if nodesToAdd > 0 {
-		drymode := c.dryMode(opts.nodeGroup)
+		drymode := c.dryScaleUp(opts.nodeGroup)
 		log.WithField("drymode", drymode).
 			WithField("nodegroup", nodegroupName).
 			Infof("increasing cloud provider node group by %v", nodesToAdd)
 
 		if !drymode {
-			err := cloudProviderNodeGroup.IncreaseSize(nodesToAdd)
+			if err := contextErr(opts.ctx, "increasing the cloud provider node group"); err != nil {
+				return 0, err
+			}
+			if !c.allowProviderResize(opts.nodeGroup) {
+				return 0, errorkind.New(errorkind.Throttled, "increasing the cloud provider node group by %v deferred by the provider write limits", nodesToAdd)
+			}
+			var err error
+			// record the decision on the new instances when the cloud provider supports it
+			if increaser, ok := cloudProviderNodeGroup.(cloudprovider.MetadataIncreaser); ok {
+				err = increaser.IncreaseSizeWithMetadata(callContext(opts.ctx), nodesToAdd, cloudprovider.ScaleUpMetadata{
+					NodeGroup: nodegroupName,
+					CycleID:   opts.nodeGroup.cycle.ID,
+					Reason:    opts.reason,
+				})
+			} else {
+				err = cloudProviderNodeGroup.IncreaseSize(callContext(opts.ctx), nodesToAdd)
+			}
 			if err != nil {
 				log.Errorf("failed to set cloud provider node group size: %v", err)
 				return 0, err
 			}
+			opts.nodeGroup.watchScalingActivities(opts.nodeGroup.cycle.ID, opts.reason, time.Now())
+		} else {
+			opts.nodeGroup.simulateScaleUp(int(nodesToAdd))
 		}
 	} else {
-		return 0, fmt.Errorf("adding %v nodes would breach max cloud provider node group size (%v)", nodesToAdd, cloudProviderNodeGroup.MaxSize())
+		return 0, errorkind.New(errorkind.Limit, "adding %v nodes would breach max cloud provider node group size (%v)", nodesToAdd, cloudProviderNodeGroup.MaxSize())
 	}
 
 	return int(nodesToAdd), nil
@@ -98,6 +132,8 @@ func (c *Controller) scaleUpCloudProviderNodeGroup(opts scaleOpts) (int, error)
 func (c *Controller) scaleUpUntaint(opts scaleOpts) (int, error) {
 	nodegroupName := opts.nodeGroup.Opts.Name
 	nodesToAdd := opts.nodesDelta
+	// nodes with a spot interruption notice are about to be reclaimed, so they are never put back to use
+	opts.taintedNodes, _ = filterSpotInterruptedNodes(opts.nodeGroup, opts.taintedNodes)
 
 	if len(opts.taintedNodes) == 0 {
 		log.WithField("nodegroup", nodegroupName).Warning("There are no tainted nodes to untaint")
@@ -108,29 +144,39 @@ func (c *Controller) scaleUpUntaint(opts scaleOpts) (int, error) {
 	log.WithField("nodegroup", nodegroupName).Infof("Scaling Up: Trying to untaint %v tainted nodes", nodesToAdd)
 	metrics.NodeGroupUntaintEvent.WithLabelValues(nodegroupName).Add(float64(nodesToAdd))
 
-	untainted := c.untaintNewestN(opts.taintedNodes, opts.nodeGroup, nodesToAdd)
+	untainted := c.untaintNewestN(opts.ctx, opts.taintedNodes, opts.nodeGroup, nodesToAdd, opts.untaintNewestTainted)
 	log.Infof("Untainted a total of %v nodes", len(untainted))
 	return len(untainted), nil
 }
 
 // untaintNewestN sorts nodes by creation time and untaints the newest N. It will return an array of indices of the nodes it untainted
 // indices are from the parameter nodes indexes, not the sorted index
-func (c *Controller) untaintNewestN(nodes []*v1.Node, nodeGroup *NodeGroupState, n int) []int {
+// when newestTainted is set the most recently tainted nodes are untainted first instead
+func (c *Controller) untaintNewestN(ctx context.Context, nodes []*v1.Node, nodeGroup *NodeGroupState, n int, newestTainted bool) []int {
 	sorted := make(nodesByNewestCreationTime, 0, len(nodes))
 	for i, node := range nodes {
 		sorted = append(sorted, nodeIndexBundle{node, i})
 	}
-	sort.Sort(sorted)
+	if newestTainted {
+		sort.Sort(nodesByNewestTaintTime{sorted})
+	} else {
+		sort.Sort(sorted)
+	}
 
 	untaintedIndices := make([]int, 0, n)
+	var untaintedNodes []*v1.Node
 	for _, bundle := range sorted {
 		// stop at N (or when array is fully iterated)
 		if len(untaintedIndices) >= n {
 			break
 		}
+		if err := contextErr(ctx, "untainting any more nodes"); err != nil {
+			log.WithField("nodegroup", nodeGroup.Opts.Name).WithError(err).Warn("Run was cancelled")
+			break
+		}
 		// only actually taint in dry mode
-		if !c.dryMode(nodeGroup) {
-			if _, tainted := k8s.GetToBeRemovedTaint(bundle.node); tainted {
+		if !c.dryTaint(nodeGroup) {
+			if k8s.MarkedToBeRemoved(bundle.node) {
 				log.WithField("drymode", "off").Infof("Untainting node %v", bundle.node.Name)
 
 				// Remove the taint from the node
@@ -140,24 +186,20 @@ func (c *Controller) untaintNewestN(nodes []*v1.Node, nodeGroup *NodeGroupState,
 				} else {
 					bundle.node = updatedNode
 					untaintedIndices = append(untaintedIndices, bundle.index)
+					untaintedNodes = append(untaintedNodes, bundle.node)
 				}
 			}
 		} else {
-			deleteIndex := -1
-			for i, name := range nodeGroup.taintTracker {
-				if bundle.node.Name == name {
-					deleteIndex = i
-					break
-				}
-			}
-			if deleteIndex != -1 {
-				// Delete from tracker
-				nodeGroup.taintTracker = append(nodeGroup.taintTracker[:deleteIndex], nodeGroup.taintTracker[deleteIndex+1:]...)
+			// Delete from tracker
+			if nodeGroup.dryUntaintNode(bundle.node) {
+				nodeGroup.simulateUntaint(bundle.node.Name)
 				untaintedIndices = append(untaintedIndices, bundle.index)
 				log.WithField("drymode", "on").Infof("Untainting node %v", bundle.node.Name)
 			}
 		}
 	}
 
+	c.recordAction(nodeGroup, ActionUntainted, untaintedNodes)
+	c.untagUntaintedInstances(callContext(ctx), nodeGroup, untaintedNodes)
 	return untaintedIndices
 }
@@ -0,0 +1,64 @@
+package controller
+
+import (
+	"math"
+)
+
+const (
+	// defaultScaleUpRateCycles is the number of runs the rise of the utilisation is measured over when
+	// scale_up_rate.cycles isn't set
+	defaultScaleUpRateCycles = 1
+	// maxScaleUpRateCycles is the most runs the rise of the utilisation can be measured over
+	maxScaleUpRateCycles = 10
+)
+
+// enabled returns whether the node group scales up on the rise of its utilisation
+func (r ScaleUpRate) enabled() bool {
+	return r.IncreasePercent > 0
+}
+
+// cycles returns the number of runs the rise of the utilisation is measured over
+func (r ScaleUpRate) cycles() int {
+	if r.Cycles > 0 {
+		return r.Cycles
+	}
+	return defaultScaleUpRateCycles
+}
+
+// utilisationRise returns how far the utilisation percent rose above the lowest utilisation of the last runs. Zero
+// until there is a previous run to compare with
+func (nodeGroup *NodeGroupState) utilisationRise(percent float64) float64 {
+	if len(nodeGroup.utilisationHistory) == 0 || percent == math.MaxFloat64 {
+		return 0
+	}
+	lowest := nodeGroup.utilisationHistory[0]
+	for _, previous := range nodeGroup.utilisationHistory[1:] {
+		lowest = math.Min(lowest, previous)
+	}
+	return math.Max(percent-lowest, 0)
+}
+
+// recordUtilisation keeps the utilisation percent of the run for measuring the rise of the utilisation of the next
+// runs. Scaling up from 0 has no utilisation to compare with, so the history starts over
+func (nodeGroup *NodeGroupState) recordUtilisation(percent float64) {
+	if !nodeGroup.Opts.ScaleUpRate.enabled() || percent == math.MaxFloat64 {
+		nodeGroup.utilisationHistory = nil
+		return
+	}
+	nodeGroup.utilisationHistory = append(nodeGroup.utilisationHistory, percent)
+	if extra := len(nodeGroup.utilisationHistory) - nodeGroup.Opts.ScaleUpRate.cycles(); extra > 0 {
+		nodeGroup.utilisationHistory = nodeGroup.utilisationHistory[extra:]
+	}
+}
+
+// calcScaleUpRateDelta returns the nodes to add for a rise of the utilisation, assuming the utilisation keeps rising
+// as fast: enough nodes to keep the projected utilisation under the scale up threshold, and at least one
+func calcScaleUpRateDelta(nodeCount int, percent float64, rise float64, scaleUpThresholdPercent int) int {
+	threshold := float64(scaleUpThresholdPercent)
+	projected := percent + rise
+	delta := int(math.Ceil(float64(nodeCount) * (projected - threshold) / threshold))
+	if delta < 1 {
+		return 1
+	}
+	return delta
+}
@@ -0,0 +1,95 @@
+package controller
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUtilisationRise(t *testing.T) {
+	nodeGroup := &NodeGroupState{Opts: NodeGroupOptions{ScaleUpRate: ScaleUpRate{IncreasePercent: 15, Cycles: 2}}}
+
+	// nothing to compare the first run with
+	assert.Equal(t, 0.0, nodeGroup.utilisationRise(30))
+	nodeGroup.recordUtilisation(30)
+	assert.Equal(t, 10.0, nodeGroup.utilisationRise(40))
+	nodeGroup.recordUtilisation(40)
+	assert.Equal(t, 20.0, nodeGroup.utilisationRise(50))
+	nodeGroup.recordUtilisation(50)
+	// the rise is measured over the last 2 runs only
+	assert.Equal(t, []float64{40, 50}, nodeGroup.utilisationHistory)
+	assert.Equal(t, 15.0, nodeGroup.utilisationRise(55))
+	// a falling utilisation doesn't rise
+	assert.Equal(t, 0.0, nodeGroup.utilisationRise(20))
+
+	// scaling up from 0 starts over
+	assert.Equal(t, 0.0, nodeGroup.utilisationRise(math.MaxFloat64))
+	nodeGroup.recordUtilisation(math.MaxFloat64)
+	assert.Empty(t, nodeGroup.utilisationHistory)
+
+	// nothing is kept without scale_up_rate
+	nodeGroup.Opts.ScaleUpRate = ScaleUpRate{}
+	nodeGroup.recordUtilisation(30)
+	assert.Empty(t, nodeGroup.utilisationHistory)
+}
+
+func TestCalcScaleUpRateDelta(t *testing.T) {
+	tests := []struct {
+		name      string
+		nodeCount int
+		percent   float64
+		rise      float64
+		threshold int
+		want      int
+	}{
+		// projected to 80%, 15 more nodes keep the requests of 100 nodes under 70%
+		{"projected over the threshold", 100, 50, 30, 70, 15},
+		// projected to 60%, under the threshold
+		{"projected under the threshold", 100, 40, 20, 70, 1},
+		{"no nodes", 0, 0, 20, 70, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, calcScaleUpRateDelta(tt.nodeCount, tt.percent, tt.rise, tt.threshold))
+		})
+	}
+}
+
+func TestValidateNodeGroup_ScaleUpRate(t *testing.T) {
+	nodeGroup := NodeGroupOptions{
+		Name:                               "test",
+		LabelKey:                           "customer",
+		LabelValue:                         "buileng",
+		CloudProviderGroupName:             "somegroup",
+		TaintUpperCapacityThresholdPercent: 70,
+		TaintLowerCapacityThresholdPercent: 60,
+		ScaleUpThresholdPercent:            100,
+		MinNodes:                           1,
+		MaxNodes:                           3,
+		SlowNodeRemovalRate:                1,
+		FastNodeRemovalRate:                2,
+		SoftDeleteGracePeriod:              "10m",
+		HardDeleteGracePeriod:              "1h10m",
+		ScaleUpCoolDownPeriod:              "55m",
+	}
+
+	tests := []struct {
+		name string
+		rate ScaleUpRate
+		want int
+	}{
+		{"unset", ScaleUpRate{}, 0},
+		{"increase only", ScaleUpRate{IncreasePercent: 15}, 0},
+		{"increase over cycles", ScaleUpRate{IncreasePercent: 15, Cycles: 2}, 0},
+		{"negative increase", ScaleUpRate{IncreasePercent: -15}, 1},
+		{"too many cycles", ScaleUpRate{IncreasePercent: 15, Cycles: maxScaleUpRateCycles + 1}, 1},
+		{"cycles without increase", ScaleUpRate{Cycles: 2}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodeGroup.ScaleUpRate = tt.rate
+			assert.Len(t, ValidateNodeGroup(nodeGroup), tt.want)
+		})
+	}
+}
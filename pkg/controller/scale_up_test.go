@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"context"
 	"fmt"
 	"testing"
 	"time"
@@ -160,21 +161,21 @@ func TestControllerUntaintNewestN(t *testing.T) {
 			}
 
 			// taint all
-			k8s.BeginTaintFailSafe(len(nodes))
+			budget := k8s.NewTaintBudget(len(nodes))
 			var tc int
 			for _, node := range nodes {
 				if _, tainted := k8s.GetToBeRemovedTaint(node); !tainted {
-					k8s.AddToBeRemovedTaint(node, client, "NoSchedule")
-					nodeGroupsState["buildeng"].taintTracker = append(nodeGroupsState["buildeng"].taintTracker, node.Name)
+					k8s.AddToBeRemovedTaint(node, client, "NoSchedule", budget)
+					nodeGroupsState["buildeng"].dryTaintNode(node, time.Now())
 					<-updateChan
 					tc++
 				}
 			}
-			k8s.EndTaintFailSafe(tc)
+			budget.Validate(tc)
 
 			// test wet mode
 			c.Opts.DryMode = false
-			got := c.untaintNewestN(tt.args.nodes, tt.args.nodeGroup, tt.args.n)
+			got := c.untaintNewestN(context.Background(), tt.args.nodes, tt.args.nodeGroup, tt.args.n, false)
 			eq := assert.Equal(t, tt.want, got)
 			if eq {
 				for _, i := range got {
@@ -192,7 +193,7 @@ func TestControllerUntaintNewestN(t *testing.T) {
 
 			// test dry mode
 			c.Opts.DryMode = true
-			got = c.untaintNewestN(tt.args.nodes, tt.args.nodeGroup, tt.args.n)
+			got = c.untaintNewestN(context.Background(), tt.args.nodes, tt.args.nodeGroup, tt.args.n, false)
 			assert.Equal(t, tt.want, got)
 		})
 	}
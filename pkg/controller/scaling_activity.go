@@ -0,0 +1,113 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/atlassian/escalator/pkg/cloudprovider"
+	"github.com/atlassian/escalator/pkg/metrics"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	// scalingActivityWatchWindow is how long the scaling activities of the cloud provider are watched after a scale up
+	scalingActivityWatchWindow = 15 * time.Minute
+	// scalingActivityClockSkew allows for the clocks of escalator and the cloud provider being out by this much, so
+	// the activities started by a scale up aren't missed
+	scalingActivityClockSkew = time.Minute
+)
+
+// scalingActivityWatch is the scale up whose scaling activities are watched for failures
+type scalingActivityWatch struct {
+	// the cycle and reason of the scale up
+	cycleID string
+	reason  string
+	since   time.Time
+	until   time.Time
+	// the activities already reported, by id
+	seen map[string]bool
+}
+
+// watchScalingActivities starts watching the scaling activities of the cloud provider after a scale up, for the
+// failures of the instances it asked for. A later scale up takes over the watch
+func (nodeGroup *NodeGroupState) watchScalingActivities(cycleID string, reason string, now time.Time) {
+	seen := make(map[string]bool)
+	if nodeGroup.activityWatch != nil {
+		seen = nodeGroup.activityWatch.seen
+	}
+	nodeGroup.activityWatch = &scalingActivityWatch{
+		cycleID: cycleID,
+		reason:  reason,
+		since:   now.Add(-scalingActivityClockSkew),
+		until:   now.Add(scalingActivityWatchWindow),
+		seen:    seen,
+	}
+}
+
+// checkScalingActivities reports the failed scaling activities of the cloud provider node group since the last scale
+// up, e.g. instances that couldn't be launched because of insufficient capacity, as warning events and metrics tied to
+// the run that decided to scale up. Cloud providers that can't list scaling activities are left alone
+func (c *Controller) checkScalingActivities(ctx context.Context, nodeGroup *NodeGroupState, now time.Time) {
+	watch := nodeGroup.activityWatch
+	if watch == nil {
+		return
+	}
+	if now.After(watch.until) {
+		nodeGroup.activityWatch = nil
+	}
+	cloudProviderNodeGroup, ok := getCloudProviderNodeGroup(c.cloudProvider, nodeGroup.Opts)
+	if !ok {
+		return
+	}
+	reader, ok := cloudProviderNodeGroup.(cloudprovider.ScalingActivityReader)
+	if !ok {
+		nodeGroup.activityWatch = nil
+		return
+	}
+
+	logger := log.WithField("nodegroup", nodeGroup.Opts.Name).WithField("cycle", watch.cycleID)
+	activities, err := reader.ScalingActivities(ctx, watch.since)
+	if err != nil {
+		logger.WithError(err).Warn("failed to list the scaling activities of the cloud provider node group")
+		return
+	}
+	for _, activity := range activities {
+		if !activity.Done || watch.seen[activity.ID] {
+			continue
+		}
+		watch.seen[activity.ID] = true
+		if !activity.Failed {
+			logger.Debugf("scaling activity %v succeeded: %v", activity.ID, activity.Description)
+			continue
+		}
+		c.recordScalingActivityFailure(nodeGroup, watch, activity)
+	}
+}
+
+// recordScalingActivityFailure reports a failed scaling activity against the scale up that caused it
+func (c *Controller) recordScalingActivityFailure(nodeGroup *NodeGroupState, watch *scalingActivityWatch, activity cloudprovider.ScalingActivity) {
+	log.WithField("nodegroup", nodeGroup.Opts.Name).WithField("cycle", watch.cycleID).Errorf(
+		"scaling activity %v of the %v failed: %v: %v",
+		activity.ID,
+		watch.reason,
+		activity.Description,
+		activity.StatusMessage,
+	)
+	metrics.NodeGroupScalingActivityFailures.WithLabelValues(nodeGroup.Opts.Name).Add(1.0)
+
+	if c.Opts.EventRecorder == nil || c.Opts.EventObject == nil {
+		return
+	}
+	c.Opts.EventRecorder.Eventf(
+		c.Opts.EventObject,
+		v1.EventTypeWarning,
+		"ScalingActivityFailed",
+		"node group %v: %v of cycle %v failed in the cloud provider: %v: %v",
+		nodeGroup.Opts.Name,
+		watch.reason,
+		watch.cycleID,
+		activity.Description,
+		activity.StatusMessage,
+	)
+}
@@ -0,0 +1,89 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/atlassian/escalator/pkg/cloudprovider"
+	"github.com/atlassian/escalator/pkg/test"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+// activitiesNodeGroup is a cloud provider node group with the given scaling activities
+type activitiesNodeGroup struct {
+	*test.NodeGroup
+	activities []cloudprovider.ScalingActivity
+}
+
+func (n *activitiesNodeGroup) ScalingActivities(ctx context.Context, since time.Time) ([]cloudprovider.ScalingActivity, error) {
+	var activities []cloudprovider.ScalingActivity
+	for _, activity := range n.activities {
+		if !activity.StartTime.Before(since) {
+			activities = append(activities, activity)
+		}
+	}
+	return activities, nil
+}
+
+// singleNodeGroupCloudProvider is a cloud provider with a single node group of any type
+type singleNodeGroupCloudProvider struct {
+	*test.CloudProvider
+	nodeGroup cloudprovider.NodeGroup
+}
+
+func (c *singleNodeGroupCloudProvider) GetNodeGroup(id string) (cloudprovider.NodeGroup, bool) {
+	return c.nodeGroup, id == c.nodeGroup.ID()
+}
+
+func TestCheckScalingActivities(t *testing.T) {
+	now := time.Now()
+	cloudProviderNodeGroup := &activitiesNodeGroup{NodeGroup: test.NewNodeGroup("asg", 0, 10, 1)}
+	recorder := record.NewFakeRecorder(10)
+	c := &Controller{
+		Opts: Opts{
+			EventRecorder: recorder,
+			EventObject:   &v1.ObjectReference{Kind: "Pod", Namespace: "kube-system", Name: "escalator"},
+		},
+		cloudProvider: &singleNodeGroupCloudProvider{test.NewCloudProvider(1), cloudProviderNodeGroup},
+	}
+	nodeGroup := &NodeGroupState{Opts: NodeGroupOptions{Name: "default", CloudProviderGroupName: "asg"}}
+
+	// nothing is checked without a scale up
+	cloudProviderNodeGroup.activities = []cloudprovider.ScalingActivity{
+		{ID: "before", StartTime: now.Add(-time.Hour), Done: true, Failed: true},
+	}
+	c.checkScalingActivities(context.Background(), nodeGroup, now)
+	assert.Empty(t, drainEvents(recorder))
+
+	nodeGroup.watchScalingActivities("20190101T000000Z", CycleDecisionScaleUp, now)
+	cloudProviderNodeGroup.activities = append(cloudProviderNodeGroup.activities,
+		cloudprovider.ScalingActivity{ID: "launching", StartTime: now},
+		cloudprovider.ScalingActivity{ID: "launched", StartTime: now, Done: true},
+		cloudprovider.ScalingActivity{
+			ID:            "failed",
+			Description:   "Launching a new EC2 instance",
+			StatusMessage: "insufficient capacity",
+			StartTime:     now,
+			Done:          true,
+			Failed:        true,
+		},
+	)
+	c.checkScalingActivities(context.Background(), nodeGroup, now.Add(time.Minute))
+	assert.Equal(t, []string{
+		"Warning ScalingActivityFailed node group default: scale_up of cycle 20190101T000000Z failed in the cloud provider: Launching a new EC2 instance: insufficient capacity",
+	}, drainEvents(recorder))
+
+	// failures are only reported once, and activities that finish later are reported when they do
+	cloudProviderNodeGroup.activities[1] = cloudprovider.ScalingActivity{ID: "launching", StartTime: now, Done: true, Failed: true}
+	c.checkScalingActivities(context.Background(), nodeGroup, now.Add(2*time.Minute))
+	assert.Len(t, drainEvents(recorder), 1)
+	c.checkScalingActivities(context.Background(), nodeGroup, now.Add(3*time.Minute))
+	assert.Empty(t, drainEvents(recorder))
+
+	// the watch ends after the window
+	c.checkScalingActivities(context.Background(), nodeGroup, now.Add(scalingActivityWatchWindow+time.Second))
+	assert.Nil(t, nodeGroup.activityWatch)
+}
@@ -0,0 +1,91 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/atlassian/escalator/pkg/metrics"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// scanPaceUrgent is a node group above its scale up threshold or waiting for an operation in flight
+	scanPaceUrgent = iota
+	// scanPaceNormal is a node group scaling down or waiting to delete tainted nodes
+	scanPaceNormal
+	// scanPaceIdle is a node group in the dead zone between its taint upper and scale up thresholds with nothing to do
+	scanPaceIdle
+)
+
+// scanPace returns how soon the node group needs to be evaluated again, from its latest run
+func scanPace(nodeGroup *NodeGroupState) int {
+	if nodeGroup.scaleUpLock.isLocked || nodeGroup.cycle.StartingNodes > 0 || nodeGroup.cycle.ShuttingDownNodes > 0 {
+		return scanPaceUrgent
+	}
+	if !nodeGroup.utilisationKnown {
+		return scanPaceNormal
+	}
+	if nodeGroup.utilisationPercent > float64(nodeGroup.Opts.ScaleUpThresholdPercent) {
+		return scanPaceUrgent
+	}
+	if nodeGroup.utilisationPercent >= float64(nodeGroup.Opts.TaintUpperCapacityThresholdPercent) && nodeGroup.cycle.TaintedNodes == 0 {
+		return scanPaceIdle
+	}
+	return scanPaceNormal
+}
+
+// tuneScanInterval returns the interval until the next run. Without --scaninterval-min and --scaninterval-max it is
+// the scan interval. Otherwise the interval is halved, down to the minimum, while any node group is urgent, doubled, up
+// to the maximum, while every node group is idle, and goes back to the scan interval in between
+func (c *Controller) tuneScanInterval() time.Duration {
+	base := c.Opts.ScanInterval
+	minimum, maximum := base, base
+	if c.Opts.MinScanInterval > 0 && c.Opts.MinScanInterval < base {
+		minimum = c.Opts.MinScanInterval
+	}
+	if c.Opts.MaxScanInterval > base {
+		maximum = c.Opts.MaxScanInterval
+	}
+	if c.scanInterval == 0 {
+		c.scanInterval = base
+	}
+
+	pace := scanPaceIdle
+	for _, nodeGroup := range c.nodeGroups {
+		if nodeGroupPace := scanPace(nodeGroup); nodeGroupPace < pace {
+			pace = nodeGroupPace
+		}
+	}
+
+	previous := c.scanInterval
+	switch pace {
+	case scanPaceUrgent:
+		interval := c.scanInterval
+		if interval > base {
+			interval = base
+		}
+		c.scanInterval = maxDuration(interval/2, minimum)
+	case scanPaceIdle:
+		c.scanInterval = minDuration(c.scanInterval*2, maximum)
+	default:
+		c.scanInterval = base
+	}
+	if c.scanInterval != previous {
+		log.Debugf("Scan interval tuned from %v to %v", previous, c.scanInterval)
+	}
+	metrics.ScanIntervalSeconds.Set(c.scanInterval.Seconds())
+	return c.scanInterval
+}
+
+func minDuration(a, b time.Duration) time.Duration {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxDuration(a, b time.Duration) time.Duration {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,77 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScanPace(t *testing.T) {
+	opts := NodeGroupOptions{ScaleUpThresholdPercent: 70, TaintUpperCapacityThresholdPercent: 40}
+	tests := []struct {
+		name      string
+		nodeGroup *NodeGroupState
+		want      int
+	}{
+		{"unknown utilisation", &NodeGroupState{Opts: opts}, scanPaceNormal},
+		{"above scale up threshold", &NodeGroupState{Opts: opts, utilisationKnown: true, utilisationPercent: 80}, scanPaceUrgent},
+		{"dead zone", &NodeGroupState{Opts: opts, utilisationKnown: true, utilisationPercent: 50}, scanPaceIdle},
+		{"below taint upper threshold", &NodeGroupState{Opts: opts, utilisationKnown: true, utilisationPercent: 30}, scanPaceNormal},
+		{
+			"dead zone with tainted nodes",
+			&NodeGroupState{Opts: opts, utilisationKnown: true, utilisationPercent: 50, cycle: CycleSummary{TaintedNodes: 1}},
+			scanPaceNormal,
+		},
+		{
+			"dead zone with starting nodes",
+			&NodeGroupState{Opts: opts, utilisationKnown: true, utilisationPercent: 50, cycle: CycleSummary{StartingNodes: 1}},
+			scanPaceUrgent,
+		},
+		{"scale up locked", &NodeGroupState{Opts: opts, scaleUpLock: scaleLock{isLocked: true}}, scanPaceUrgent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, scanPace(tt.nodeGroup))
+		})
+	}
+}
+
+func TestTuneScanInterval(t *testing.T) {
+	opts := NodeGroupOptions{ScaleUpThresholdPercent: 70, TaintUpperCapacityThresholdPercent: 40}
+	idle := &NodeGroupState{Opts: opts, utilisationKnown: true, utilisationPercent: 50}
+	busy := &NodeGroupState{Opts: opts, utilisationKnown: true, utilisationPercent: 80}
+	normal := &NodeGroupState{Opts: opts, utilisationKnown: true, utilisationPercent: 30}
+
+	t.Run("bounded", func(t *testing.T) {
+		c := &Controller{
+			Opts:       Opts{ScanInterval: time.Minute, MinScanInterval: 15 * time.Second, MaxScanInterval: 4 * time.Minute},
+			nodeGroups: map[string]*NodeGroupState{"idle": idle},
+		}
+		// the interval grows while every node group is idle
+		assert.Equal(t, 2*time.Minute, c.tuneScanInterval())
+		assert.Equal(t, 4*time.Minute, c.tuneScanInterval())
+		assert.Equal(t, 4*time.Minute, c.tuneScanInterval())
+
+		// a single urgent node group shortens it from the scan interval
+		c.nodeGroups["busy"] = busy
+		assert.Equal(t, 30*time.Second, c.tuneScanInterval())
+		assert.Equal(t, 15*time.Second, c.tuneScanInterval())
+		assert.Equal(t, 15*time.Second, c.tuneScanInterval())
+
+		// and it goes back to the scan interval in between
+		c.nodeGroups["busy"] = normal
+		assert.Equal(t, time.Minute, c.tuneScanInterval())
+	})
+
+	t.Run("unbounded", func(t *testing.T) {
+		for _, nodeGroup := range []*NodeGroupState{idle, busy, normal} {
+			c := &Controller{
+				Opts:       Opts{ScanInterval: time.Minute},
+				nodeGroups: map[string]*NodeGroupState{"default": nodeGroup},
+			}
+			assert.Equal(t, time.Minute, c.tuneScanInterval())
+			assert.Equal(t, time.Minute, c.tuneScanInterval())
+		}
+	})
+}
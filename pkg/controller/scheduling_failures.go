@@ -0,0 +1,125 @@
+package controller
+
+import (
+	"sort"
+	"time"
+
+	"github.com/atlassian/escalator/pkg/metrics"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// schedulingFailureExamples is the number of the latest scheduling failures of each node group kept for the report
+const schedulingFailureExamples = 10
+
+// SchedulingFailure is a FailedScheduling event of a pod of a node group, as shown in the report
+type SchedulingFailure struct {
+	NodeGroup string `json:"node_group"`
+	Namespace string `json:"namespace"`
+	Pod       string `json:"pod"`
+	// Message is the reason the scheduler gave, e.g. 0/12 nodes are available: 12 Insufficient cpu
+	Message string `json:"message"`
+	// Count is the number of times the scheduler failed to schedule the pod for the event
+	Count int32     `json:"count"`
+	Time  time.Time `json:"time"`
+}
+
+// schedulingFailures tracks the FailedScheduling events of the pods of a node group
+type schedulingFailures struct {
+	// counted is the count of each event already added to the metric, by event uid. nil until the first run, whose
+	// events are only taken as the baseline
+	counted map[types.UID]int32
+	// latest is the latest scheduling failures of the pods from the last run, newest first
+	latest []SchedulingFailure
+}
+
+// eventCount returns the number of times the event happened
+func eventCount(event *v1.Event) int32 {
+	if event.Series != nil && event.Series.Count > event.Count {
+		return event.Series.Count
+	}
+	if event.Count == 0 {
+		return 1
+	}
+	return event.Count
+}
+
+// eventTime returns when the event last happened
+func eventTime(event *v1.Event) time.Time {
+	if event.Series != nil && !event.Series.LastObservedTime.IsZero() {
+		return event.Series.LastObservedTime.Time
+	}
+	if !event.LastTimestamp.IsZero() {
+		return event.LastTimestamp.Time
+	}
+	return event.EventTime.Time
+}
+
+// trackSchedulingFailures counts the FailedScheduling events of the pods of the node group since the last run, and
+// keeps the latest for the report. The events from before the first run of the node group aren't counted, so a
+// restart doesn't count them again. Does nothing unless the events are watched
+func (c *Controller) trackSchedulingFailures(nodeGroup *NodeGroupState, pods []*v1.Pod) {
+	if c.Client == nil || c.Client.allEventLister == nil {
+		return
+	}
+	logger := log.WithField("nodegroup", nodeGroup.Opts.Name)
+	events, err := c.Client.allEventLister.List(labels.Everything())
+	if err != nil {
+		logger.WithError(err).Warn("Failed to list the FailedScheduling events")
+		return
+	}
+
+	podUIDs := make(map[types.UID]bool, len(pods))
+	for _, pod := range pods {
+		podUIDs[pod.UID] = true
+	}
+
+	baseline := nodeGroup.schedulingFailures.counted == nil
+	counted := make(map[types.UID]int32)
+	var failures []SchedulingFailure
+	var added int32
+	for _, event := range events {
+		if event.InvolvedObject.Kind != "Pod" || !podUIDs[event.InvolvedObject.UID] {
+			continue
+		}
+		count := eventCount(event)
+		counted[event.UID] = count
+		if !baseline && count > nodeGroup.schedulingFailures.counted[event.UID] {
+			added += count - nodeGroup.schedulingFailures.counted[event.UID]
+		}
+		failures = append(failures, SchedulingFailure{
+			NodeGroup: nodeGroup.Opts.Name,
+			Namespace: event.InvolvedObject.Namespace,
+			Pod:       event.InvolvedObject.Name,
+			Message:   event.Message,
+			Count:     count,
+			Time:      eventTime(event),
+		})
+	}
+
+	sort.SliceStable(failures, func(i, j int) bool { return failures[i].Time.After(failures[j].Time) })
+	if len(failures) > schedulingFailureExamples {
+		failures = failures[:schedulingFailureExamples]
+	}
+	if added > 0 {
+		logger.Infof("pods failed to schedule %v times since the last run", added)
+	}
+	metrics.NodeGroupSchedulingFailures.WithLabelValues(nodeGroup.Opts.Name).Add(float64(added))
+	nodeGroup.schedulingFailures = schedulingFailures{counted: counted, latest: failures}
+}
+
+// updateSchedulingFailures exports the latest scheduling failures of every node group in the report
+func (c *Controller) updateSchedulingFailures() {
+	var failures []SchedulingFailure
+	for _, nodeGroupOpts := range c.Opts.NodeGroups {
+		if nodeGroup, ok := c.nodeGroups[nodeGroupOpts.Name]; ok {
+			failures = append(failures, nodeGroup.schedulingFailures.latest...)
+		}
+	}
+
+	c.reportLock.Lock()
+	c.report.SchedulingFailures = failures
+	c.reportLock.Unlock()
+}
@@ -0,0 +1,80 @@
+package controller
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/atlassian/escalator/pkg/test"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	v1lister "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// failedSchedulingEvent returns a FailedScheduling event of the pod
+func failedSchedulingEvent(uid string, pod *v1.Pod, count int32, last time.Time) *v1.Event {
+	return &v1.Event{
+		ObjectMeta: metav1.ObjectMeta{Name: uid, Namespace: pod.Namespace, UID: types.UID(uid)},
+		InvolvedObject: v1.ObjectReference{
+			Kind:      "Pod",
+			Namespace: pod.Namespace,
+			Name:      pod.Name,
+			UID:       pod.UID,
+		},
+		Reason:        "FailedScheduling",
+		Message:       "0/3 nodes are available: 3 Insufficient cpu.",
+		Count:         count,
+		LastTimestamp: metav1.NewTime(last),
+	}
+}
+
+func TestTrackSchedulingFailures(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	pods := []*v1.Pod{
+		test.BuildTestPod(test.PodOpts{Name: "p1", Namespace: "default"}),
+		test.BuildTestPod(test.PodOpts{Name: "p2", Namespace: "default"}),
+		test.BuildTestPod(test.PodOpts{Name: "other", Namespace: "default"}),
+	}
+	for i, pod := range pods {
+		pod.UID = types.UID(fmt.Sprintf("pod-%v", i))
+	}
+
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	assert.NoError(t, indexer.Add(failedSchedulingEvent("e1", pods[0], 2, now.Add(-time.Minute))))
+	assert.NoError(t, indexer.Add(failedSchedulingEvent("e2", pods[2], 5, now)))
+	c := &Controller{Client: &Client{allEventLister: v1lister.NewEventLister(indexer)}}
+	nodeGroup := &NodeGroupState{Opts: NodeGroupOptions{Name: "default"}}
+
+	// the events from before the first run are the baseline
+	c.trackSchedulingFailures(nodeGroup, pods[:2])
+	assert.Equal(t, map[types.UID]int32{"e1": 2}, nodeGroup.schedulingFailures.counted)
+	assert.Len(t, nodeGroup.schedulingFailures.latest, 1)
+
+	// later runs count the new events and the repeats of the existing ones
+	assert.NoError(t, indexer.Update(failedSchedulingEvent("e1", pods[0], 3, now.Add(-30*time.Second))))
+	assert.NoError(t, indexer.Add(failedSchedulingEvent("e3", pods[1], 1, now)))
+	c.trackSchedulingFailures(nodeGroup, pods[:2])
+	assert.Equal(t, map[types.UID]int32{"e1": 3, "e3": 1}, nodeGroup.schedulingFailures.counted)
+	assert.Equal(t, []SchedulingFailure{
+		{NodeGroup: "default", Namespace: "default", Pod: "p2", Message: "0/3 nodes are available: 3 Insufficient cpu.", Count: 1, Time: now},
+		{NodeGroup: "default", Namespace: "default", Pod: "p1", Message: "0/3 nodes are available: 3 Insufficient cpu.", Count: 3, Time: now.Add(-30 * time.Second)},
+	}, nodeGroup.schedulingFailures.latest)
+
+	// only the latest failures are kept
+	for i := 0; i < schedulingFailureExamples+5; i++ {
+		assert.NoError(t, indexer.Add(failedSchedulingEvent(fmt.Sprintf("f%v", i), pods[0], 1, now.Add(time.Duration(i)*time.Second))))
+	}
+	c.trackSchedulingFailures(nodeGroup, pods[:2])
+	assert.Len(t, nodeGroup.schedulingFailures.latest, schedulingFailureExamples)
+	assert.Equal(t, now.Add(time.Duration(schedulingFailureExamples+4)*time.Second), nodeGroup.schedulingFailures.latest[0].Time)
+}
+
+func TestTrackSchedulingFailures_NotWatched(t *testing.T) {
+	c := &Controller{Client: &Client{}}
+	nodeGroup := &NodeGroupState{Opts: NodeGroupOptions{Name: "default"}}
+	c.trackSchedulingFailures(nodeGroup, nil)
+	assert.Nil(t, nodeGroup.schedulingFailures.counted)
+}
@@ -0,0 +1,429 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/atlassian/escalator/pkg/errorkind"
+	"github.com/atlassian/escalator/pkg/k8s"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+)
+
+// SimulationRequest is the changes to the options of the node groups to simulate. Each node group is given as the
+// json of the options that change, which are applied over its current options, e.g.
+// {"node_groups": {"shared": {"scale_up_threshold_percent": 80}}}
+type SimulationRequest struct {
+	NodeGroups map[string]json.RawMessage `json:"node_groups"`
+}
+
+// SimulationResult is what the current state of the node groups would produce under their current options and under
+// the changed options
+type SimulationResult struct {
+	Time       time.Time             `json:"time"`
+	NodeGroups []NodeGroupSimulation `json:"node_groups"`
+}
+
+// NodeGroupSimulation compares the outcome of a run of the node group under its current options with the outcome
+// under the changed options
+type NodeGroupSimulation struct {
+	NodeGroup string `json:"node_group"`
+	// Changes are the options that differ from the current options
+	Changes   []ConfigChange   `json:"changes"`
+	Current   SimulatedOutcome `json:"current"`
+	Simulated SimulatedOutcome `json:"simulated"`
+}
+
+// SimulatedOutcome is the scaling decision a run would make on the current state of a node group
+type SimulatedOutcome struct {
+	Decision string `json:"decision"`
+	// NodesDelta is the number of nodes the run would add, or remove when negative, after the limits of the node group
+	NodesDelta     int     `json:"nodes_delta"`
+	CPUPercent     float64 `json:"cpu_percent"`
+	MemPercent     float64 `json:"mem_percent"`
+	Pods           int     `json:"pods"`
+	Nodes          int     `json:"nodes"`
+	UntaintedNodes int     `json:"untainted_nodes"`
+	TaintedNodes   int     `json:"tainted_nodes"`
+	// Candidates are the nodes the run would act on, in order: the untainted nodes tainted by a scale down, or the
+	// tainted nodes untainted by a scale up
+	Candidates []string `json:"candidates"`
+	// NodesToAdd is how many nodes the cloud provider node group would be increased by after untainting the candidates
+	NodesToAdd int `json:"nodes_to_add,omitempty"`
+	// Limits are the limits and guardrails that reduced the delta
+	Limits []string `json:"limits,omitempty"`
+	// Error is why the run would take no action
+	Error string `json:"error,omitempty"`
+}
+
+// simulationRequest is a simulation handled by the main loop
+type simulationRequest struct {
+	request SimulationRequest
+	result  chan simulationResponse
+}
+
+type simulationResponse struct {
+	result SimulationResult
+	err    error
+}
+
+// Simulate asks the main loop for the outcome of the changed options on the current state of the node groups. The
+// simulation runs between runs and changes nothing, so Simulate blocks until the current run has finished
+func (c *Controller) Simulate(request SimulationRequest) (SimulationResult, error) {
+	result := make(chan simulationResponse, 1)
+	select {
+	case c.simulationChan <- simulationRequest{request: request, result: result}:
+		response := <-result
+		return response.result, response.err
+	case <-c.stopChan:
+		return SimulationResult{}, errors.New("controller stopped")
+	}
+}
+
+// simulate returns the outcome of the current and the changed options of each node group in the request. It must only
+// be called from the main loop
+func (c *Controller) simulate(request SimulationRequest, now time.Time) (SimulationResult, error) {
+	if len(request.NodeGroups) == 0 {
+		return SimulationResult{}, errorkind.New(errorkind.Validation, "no node groups to simulate")
+	}
+
+	names := make([]string, 0, len(request.NodeGroups))
+	for name := range request.NodeGroups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	result := SimulationResult{Time: now, NodeGroups: make([]NodeGroupSimulation, 0, len(names))}
+	for _, name := range names {
+		state, ok := c.nodeGroups[name]
+		if !ok {
+			return SimulationResult{}, errorkind.New(errorkind.NotFound, "node group %v not found", name)
+		}
+		opts, err := changedNodeGroupOptions(state.Opts, request.NodeGroups[name])
+		if err != nil {
+			return SimulationResult{}, err
+		}
+
+		result.NodeGroups = append(result.NodeGroups, NodeGroupSimulation{
+			NodeGroup: name,
+			Changes:   diffConfigs(Config{NodeGroups: []NodeGroupOptions{state.Opts}}, Config{NodeGroups: []NodeGroupOptions{opts}}),
+			Current:   c.simulateNodeGroup(state, state.Opts, now),
+			Simulated: c.simulateNodeGroup(state, opts, now),
+		})
+	}
+	return result, nil
+}
+
+// changedNodeGroupOptions applies the json of the changed options over a copy of the options, and validates the result
+func changedNodeGroupOptions(current NodeGroupOptions, changes json.RawMessage) (NodeGroupOptions, error) {
+	// the options are copied through json, so decoding the changes never writes to the slices and maps of the current
+	// options
+	encoded, err := json.Marshal(current)
+	if err != nil {
+		return NodeGroupOptions{}, err
+	}
+	var opts NodeGroupOptions
+	if err := json.Unmarshal(encoded, &opts); err != nil {
+		return NodeGroupOptions{}, err
+	}
+	if len(changes) > 0 {
+		if err := json.Unmarshal(changes, &opts); err != nil {
+			return NodeGroupOptions{}, errorkind.Wrap(errorkind.Validation, err, fmt.Sprintf("invalid options for node group %v", current.Name))
+		}
+	}
+	if opts.Name != current.Name {
+		return NodeGroupOptions{}, errorkind.New(errorkind.Validation, "node group %v can't be renamed to %v", current.Name, opts.Name)
+	}
+	opts.parseReservations()
+
+	if problems := ValidateNodeGroup(opts); len(problems) > 0 {
+		messages := make([]string, 0, len(problems))
+		for _, problem := range problems {
+			messages = append(messages, problem.Error())
+		}
+		return NodeGroupOptions{}, errorkind.New(errorkind.Validation, "invalid options for node group %v: %v", current.Name, strings.Join(messages, ", "))
+	}
+	return opts, nil
+}
+
+// simulateNodeGroup returns the decision a run of the node group would make under the options
+func (c *Controller) simulateNodeGroup(state *NodeGroupState, opts NodeGroupOptions, now time.Time) SimulatedOutcome {
+	outcome := SimulatedOutcome{Decision: CycleDecisionSkipped, Candidates: []string{}}
+	if err := c.simulateRun(state, opts, now, &outcome); err != nil {
+		outcome.Error = err.Error()
+	}
+	return outcome
+}
+
+// simulateRun makes the scaling decision of scaleNodeGroup on a scratch copy of the state of the node group. Nothing is
+// changed in the cluster, the cloud provider, the state of the node group or the metrics
+func (c *Controller) simulateRun(state *NodeGroupState, opts NodeGroupOptions, now time.Time, outcome *SimulatedOutcome) error {
+	if len(opts.Pool) > 0 {
+		return errorkind.New(errorkind.Validation, "node groups in a pool scale on the utilisation of the whole pool, which can't be simulated")
+	}
+
+	// the state kept across runs is shared read only, the state of the run is the scratch copy's own
+	nodeGroup := &NodeGroupState{
+		NodeGroupLister:    newNodeGroupLister(c.Client.allPodLister, c.Client.allNodeLister, opts),
+		Opts:               opts,
+		scaleUpLock:        state.scaleUpLock,
+		taintTracker:       state.taintTracker,
+		highWaterMark:      state.highWaterMark,
+		maintenance:        state.maintenance,
+		staticPodNodes:     state.staticPodNodes,
+		utilisationHistory: state.utilisationHistory,
+		cpuCapacity:        state.cpuCapacity,
+		memCapacity:        state.memCapacity,
+	}
+
+	pods, err := nodeGroup.Pods.List()
+	if err != nil {
+		return err
+	}
+	scalingPods := pods
+	if opts.ExcludeBestEffortPods {
+		scalingPods = filterBestEffortPods(pods)
+	}
+	allNodes, err := nodeGroup.Nodes.List()
+	if err != nil {
+		return err
+	}
+
+	untaintedNodes, taintedNodes, _, _ := c.filterNodes(nodeGroup, allNodes)
+	untaintedNodes, startingNodes := filterStartingNodes(untaintedNodes, opts.StartupTaintKeys(), opts.NewNodeGracePeriodDuration(), now)
+	untaintedNodes, _ = filterThirdPartyTaintedNodes(nodeGroup, untaintedNodes)
+	outcome.Pods = len(pods)
+	outcome.Nodes = len(allNodes)
+	outcome.UntaintedNodes = len(untaintedNodes)
+	outcome.TaintedNodes = len(taintedNodes)
+
+	if len(allNodes) == 0 && len(scalingPods) == 0 {
+		outcome.Decision = CycleDecisionNone
+		return nil
+	}
+	if len(allNodes) < opts.MinNodes {
+		return errorkind.New(errorkind.Limit, "node count of %v less than the minimum of %v", len(allNodes), opts.MinNodes)
+	}
+	if len(allNodes) > opts.MaxNodes {
+		return errorkind.New(errorkind.Limit, "node count of %v larger than the maximum of %v", len(allNodes), opts.MaxNodes)
+	}
+
+	nodeGroup.NodeInfoMap = k8s.CreateNodeNameToInfoMap(pods, allNodes)
+	if opts.VersionSkew.enabled() {
+		outdated := outdatedNodes(opts.VersionSkew, allNodes, untaintedNodes)
+		if len(outdated) > opts.VersionSkew.maxNodesPerRun() {
+			outdated = outdated[:opts.VersionSkew.maxNodesPerRun()]
+		}
+		nodeGroup.outdatedNodes = make(map[string]bool, len(outdated))
+		for _, node := range outdated {
+			nodeGroup.outdatedNodes[node.Name] = true
+		}
+	}
+
+	overheads := c.Opts.Cluster.runtimeClassOverheads()
+	memRequest, cpuRequest, err := k8s.CalculatePodsRequestsTotalWithOverhead(scalingPods, overheads)
+	if err != nil {
+		return err
+	}
+	memCapacity, cpuCapacity, err := k8s.CalculateNodesCapacityTotal(untaintedNodes)
+	if err != nil {
+		return err
+	}
+
+	if len(untaintedNodes)+len(startingNodes) < opts.MinNodes && opts.scaleUpEnabled() {
+		outcome.Decision = CycleDecisionScaleToMinimum
+		c.simulateScaleUp(nodeGroup, allNodes, taintedNodes, opts.MinNodes-len(untaintedNodes)-len(startingNodes), true, outcome)
+		return nil
+	}
+
+	cpuPercent, memPercent, err := calcPercentUsage(cpuRequest, memRequest, cpuCapacity, memCapacity, int64(len(untaintedNodes)))
+	if err != nil {
+		return err
+	}
+	cpuPercent, memPercent, err = resourceProfilesPercent(opts, scalingPods, overheads, cpuCapacity, memCapacity, cpuPercent, memPercent, nil)
+	if err != nil {
+		return err
+	}
+	outcome.CPUPercent = cpuPercent
+	outcome.MemPercent = memPercent
+
+	if nodeGroup.scaleUpLock.locked() {
+		outcome.Decision = CycleDecisionLocked
+		outcome.Limits = append(outcome.Limits, fmt.Sprintf("%v: waiting for %v requested nodes and the scale up cool down period", GuardrailScaleUpCoolDown, nodeGroup.scaleUpLock.requestedNodes))
+		return nil
+	}
+
+	maxPercent := math.Max(cpuPercent, memPercent)
+	nodesDelta := 0
+	switch {
+	case opts.ScaleUpRate.enabled() && maxPercent <= float64(opts.ScaleUpThresholdPercent) &&
+		nodeGroup.utilisationRise(maxPercent) >= opts.ScaleUpRate.IncreasePercent:
+		nodesDelta = calcScaleUpRateDelta(len(untaintedNodes), maxPercent, nodeGroup.utilisationRise(maxPercent), opts.ScaleUpThresholdPercent)
+		if nodesDelta < opts.ScaleUpCount {
+			nodesDelta = opts.ScaleUpCount
+		}
+	case maxPercent < float64(opts.TaintLowerCapacityThresholdPercent):
+		nodesDelta = -calcScaleDownDelta(opts.FastNodeRemovalRate, opts.ScaleDownCount, len(untaintedNodes))
+	case maxPercent < float64(opts.TaintUpperCapacityThresholdPercent):
+		nodesDelta = -calcScaleDownDelta(opts.SlowNodeRemovalRate, opts.ScaleDownCount, len(untaintedNodes))
+	case maxPercent > float64(opts.ScaleUpThresholdPercent):
+		nodesDelta, err = calcScaleUpDelta(untaintedNodes, cpuPercent, memPercent, cpuRequest, memRequest, nodeGroup)
+		if err != nil {
+			return err
+		}
+		if nodesDelta < opts.ScaleUpCount {
+			nodesDelta = opts.ScaleUpCount
+		}
+	}
+
+	if blockingNodeGroup, blocked := c.scaleDownBlocked(nodeGroup); blocked && nodesDelta < 0 {
+		outcome.Limits = append(outcome.Limits, fmt.Sprintf("scale_down_after: dependent node group %v is busy", blockingNodeGroup))
+		nodesDelta = 0
+	}
+	if window, ok := c.Opts.Cluster.activeMaintenanceWindow(opts.Name, now); ok && nodesDelta < 0 {
+		outcome.Limits = append(outcome.Limits, fmt.Sprintf("%v: not scaling down during maintenance window %v", GuardrailMaintenanceWindow, window.Name))
+		nodesDelta = 0
+	}
+	if limited := c.limitScaleDownToClusterFloor(nodeGroup, nodesDelta); limited != nodesDelta {
+		outcome.Limits = append(outcome.Limits, fmt.Sprintf("cluster floor: scale down of %v nodes limited to %v", -nodesDelta, -limited))
+		nodesDelta = limited
+	}
+	if nodesDelta > 0 && !opts.scaleUpEnabled() {
+		outcome.Limits = append(outcome.Limits, "scale_up_enabled is false")
+		nodesDelta = 0
+	}
+	if nodesDelta < 0 && !opts.scaleDownEnabled() {
+		outcome.Limits = append(outcome.Limits, "scale_down_enabled is false")
+		nodesDelta = 0
+	}
+
+	switch {
+	case nodesDelta < 0:
+		outcome.Decision = CycleDecisionScaleDown
+		return simulateScaleDown(nodeGroup, untaintedNodes, -nodesDelta, now, outcome)
+	case nodesDelta > 0:
+		outcome.Decision = CycleDecisionScaleUp
+		c.simulateScaleUp(nodeGroup, allNodes, taintedNodes, nodesDelta, false, outcome)
+	default:
+		outcome.Decision = CycleDecisionNone
+	}
+	return nil
+}
+
+// simulateScaleDown sets the untainted nodes a scale down would taint, after clamping it to the minimum of the node
+// group, the same as scaleDownTaint
+func simulateScaleDown(nodeGroup *NodeGroupState, untaintedNodes []*v1.Node, nodesToRemove int, now time.Time, outcome *SimulatedOutcome) error {
+	minimum := minNodes(nodeGroup, now)
+	if len(untaintedNodes)-nodesToRemove < minimum {
+		clamped := len(untaintedNodes) - minimum
+		if clamped < 0 {
+			return errorkind.New(errorkind.Limit, "the number of nodes(%v) is less than specified minimum of %v", len(untaintedNodes), minimum)
+		}
+		outcome.Limits = append(outcome.Limits, fmt.Sprintf("%v: scale down of %v nodes clamped to %v by the minimum of %v nodes", GuardrailMinNodes, nodesToRemove, clamped, minimum))
+		nodesToRemove = clamped
+	}
+
+	var removed []*v1.Node
+	for i, bundle := range taintOrder(nodeGroup, untaintedNodes, now) {
+		if len(removed) >= nodesToRemove {
+			break
+		}
+		if i >= k8s.MaximumTaints {
+			outcome.Limits = append(outcome.Limits, fmt.Sprintf("%v: capped at the maximum of %v nodes per run", GuardrailMaximumTaints, k8s.MaximumTaints))
+			break
+		}
+		if staticPodsProtect(nodeGroup, bundle.node) {
+			outcome.Limits = append(outcome.Limits, fmt.Sprintf("%v: node %v is running static pods", GuardrailStaticPods, bundle.node.Name))
+			continue
+		}
+		if !nodeGroup.Opts.AggressiveScaleDown && !podsFitOnRemainingNodes(untaintedNodes, append(removed, bundle.node), nodeGroup) {
+			continue
+		}
+		removed = append(removed, bundle.node)
+		outcome.Candidates = append(outcome.Candidates, bundle.node.Name)
+	}
+	outcome.NodesDelta = -len(removed)
+	return nil
+}
+
+// simulateScaleUp sets the tainted nodes a scale up would untaint, newest first, and how many nodes the cloud provider
+// node group would be increased by for the rest of the delta, clamped to its maximum
+func (c *Controller) simulateScaleUp(nodeGroup *NodeGroupState, allNodes []*v1.Node, taintedNodes []*v1.Node, nodesDelta int, newestTainted bool, outcome *SimulatedOutcome) {
+	sorted := make(nodesByNewestCreationTime, 0, len(taintedNodes))
+	for i, node := range taintedNodes {
+		sorted = append(sorted, nodeIndexBundle{node, i})
+	}
+	if newestTainted {
+		sort.Sort(nodesByNewestTaintTime{sorted})
+	} else {
+		sort.Sort(sorted)
+	}
+	for _, bundle := range sorted {
+		if len(outcome.Candidates) >= nodesDelta {
+			break
+		}
+		outcome.Candidates = append(outcome.Candidates, bundle.node.Name)
+	}
+
+	remaining := int64(nodesDelta - len(outcome.Candidates))
+	if remaining <= 0 {
+		outcome.NodesDelta = len(outcome.Candidates)
+		return
+	}
+	targetSize, maxSize := int64(len(allNodes)), int64(nodeGroup.Opts.MaxNodes)
+	if c.cloudProvider != nil {
+		if cloudProviderNodeGroup, ok := getCloudProviderNodeGroup(c.cloudProvider, nodeGroup.Opts); ok {
+			targetSize, maxSize = cloudProviderNodeGroup.TargetSize(), cloudProviderNodeGroup.MaxSize()
+		}
+	}
+	nodesToAdd := c.calculateNodesToAdd(remaining, targetSize, maxSize)
+	if nodesToAdd < remaining {
+		outcome.Limits = append(outcome.Limits, fmt.Sprintf("%v: scale up of %v nodes clamped to %v by the maximum of %v nodes", GuardrailMaxNodes, remaining, nodesToAdd, maxSize))
+	}
+	if nodesToAdd < 0 {
+		nodesToAdd = 0
+	}
+	outcome.NodesToAdd = int(nodesToAdd)
+	outcome.NodesDelta = len(outcome.Candidates) + outcome.NodesToAdd
+}
+
+// SimulateHandler simulates the changed node group options posted as json, and serves the outcomes as json
+func (c *Controller) SimulateHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "simulate must be a POST", http.StatusMethodNotAllowed)
+			return
+		}
+		var request SimulationRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, fmt.Sprintf("invalid simulation request: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		result, err := c.Simulate(request)
+		if err != nil {
+			status := http.StatusInternalServerError
+			switch errorkind.Of(err) {
+			case errorkind.Validation:
+				status = http.StatusBadRequest
+			case errorkind.NotFound:
+				status = http.StatusNotFound
+			default:
+				log.WithError(err).Warn("Failed to simulate the node group options")
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(result); err != nil {
+			log.WithError(err).Warn("Failed to write simulation result")
+		}
+	})
+}
@@ -0,0 +1,224 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/atlassian/escalator/pkg/api"
+	"github.com/atlassian/escalator/pkg/errorkind"
+	"github.com/atlassian/escalator/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func buildSimulationController() *Controller {
+	nodeGroups := []NodeGroupOptions{{
+		Name:                               "shared",
+		LabelKey:                           "customer",
+		LabelValue:                         "shared",
+		CloudProviderGroupName:             "shared",
+		MinNodes:                           1,
+		MaxNodes:                           10,
+		TaintLowerCapacityThresholdPercent: 10,
+		TaintUpperCapacityThresholdPercent: 40,
+		ScaleUpThresholdPercent:            70,
+		SlowNodeRemovalRate:                1,
+		FastNodeRemovalRate:                2,
+		SoftDeleteGracePeriod:              "1m",
+		HardDeleteGracePeriod:              "10m",
+		ScaleUpCoolDownPeriod:              "1m",
+	}}
+	nodes := test.BuildTestNodes(4, test.NodeOpts{CPU: 1000, Mem: 1000, LabelKey: "customer", LabelValue: "shared"})
+	pods := test.BuildTestPods(4, test.PodOpts{
+		CPU:               []int64{500},
+		Mem:               []int64{500},
+		NodeSelectorKey:   "customer",
+		NodeSelectorValue: "shared",
+	})
+	client, opts := buildTestClient(nodes, pods, nodeGroups, ListerOptions{})
+
+	return &Controller{
+		Client: client,
+		Opts:   opts,
+		nodeGroups: BuildNodeGroupsState(nodeGroupsStateOpts{
+			nodeGroups: nodeGroups,
+			client:     *client,
+		}),
+	}
+}
+
+func TestControllerSimulate(t *testing.T) {
+	c := buildSimulationController()
+
+	// the node group is at 50% utilisation, which is above taint_upper_capacity_threshold_percent
+	result, err := c.simulate(SimulationRequest{NodeGroups: map[string]json.RawMessage{
+		"shared": json.RawMessage(`{"taint_upper_capacity_threshold_percent": 60}`),
+	}}, time.Now())
+	require.NoError(t, err)
+	require.Len(t, result.NodeGroups, 1)
+
+	simulation := result.NodeGroups[0]
+	assert.Equal(t, "shared", simulation.NodeGroup)
+	assert.Equal(t, []ConfigChange{{
+		Path: "node_groups.shared.taint_upper_capacity_threshold_percent",
+		Old:  "40",
+		New:  "60",
+	}}, simulation.Changes)
+
+	assert.Equal(t, CycleDecisionNone, simulation.Current.Decision)
+	assert.Equal(t, 0, simulation.Current.NodesDelta)
+	assert.Empty(t, simulation.Current.Candidates)
+	assert.Equal(t, float64(50), simulation.Current.CPUPercent)
+	assert.Equal(t, 4, simulation.Current.UntaintedNodes)
+
+	assert.Equal(t, CycleDecisionScaleDown, simulation.Simulated.Decision)
+	assert.Equal(t, -1, simulation.Simulated.NodesDelta)
+	assert.Len(t, simulation.Simulated.Candidates, 1)
+
+	// nothing is changed by the simulation
+	assert.Equal(t, 40, c.nodeGroups["shared"].Opts.TaintUpperCapacityThresholdPercent)
+	nodes, err := c.nodeGroups["shared"].Nodes.List()
+	require.NoError(t, err)
+	untainted, tainted, _, _ := c.filterNodes(c.nodeGroups["shared"], nodes)
+	assert.Len(t, untainted, 4)
+	assert.Empty(t, tainted)
+}
+
+func TestControllerSimulate_ScaleUp(t *testing.T) {
+	c := buildSimulationController()
+
+	result, err := c.simulate(SimulationRequest{NodeGroups: map[string]json.RawMessage{
+		"shared": json.RawMessage(`{"taint_lower_capacity_threshold_percent": 5, "taint_upper_capacity_threshold_percent": 20, "scale_up_threshold_percent": 25, "max_nodes": 5}`),
+	}}, time.Now())
+	require.NoError(t, err)
+
+	// 50% over a threshold of 25% needs 4 more nodes, clamped to the max of 5 nodes
+	simulated := result.NodeGroups[0].Simulated
+	assert.Equal(t, CycleDecisionScaleUp, simulated.Decision)
+	assert.Equal(t, 1, simulated.NodesToAdd)
+	assert.Equal(t, 1, simulated.NodesDelta)
+	assert.Len(t, simulated.Limits, 1)
+}
+
+func TestControllerSimulate_ScaleDownCount(t *testing.T) {
+	c := buildSimulationController()
+
+	// the changed scale_down_count is used, and is clamped to the 4 untainted nodes of the small node group
+	result, err := c.simulate(SimulationRequest{NodeGroups: map[string]json.RawMessage{
+		"shared": json.RawMessage(`{"taint_upper_capacity_threshold_percent": 60, "scale_down_count": 10, "min_nodes": 0}`),
+	}}, time.Now())
+	require.NoError(t, err)
+
+	simulated := result.NodeGroups[0].Simulated
+	assert.Equal(t, CycleDecisionScaleDown, simulated.Decision)
+	assert.Equal(t, -4, simulated.NodesDelta)
+	assert.Len(t, simulated.Candidates, 4)
+}
+
+func TestControllerSimulate_Errors(t *testing.T) {
+	c := buildSimulationController()
+
+	tests := []struct {
+		name    string
+		request SimulationRequest
+		kind    errorkind.Kind
+	}{
+		{"no node groups", SimulationRequest{}, errorkind.Validation},
+		{"unknown node group", SimulationRequest{NodeGroups: map[string]json.RawMessage{"gpu": json.RawMessage(`{}`)}}, errorkind.NotFound},
+		{"invalid options", SimulationRequest{NodeGroups: map[string]json.RawMessage{"shared": json.RawMessage(`{"scale_up_threshold_percent": 30}`)}}, errorkind.Validation},
+		{"invalid json", SimulationRequest{NodeGroups: map[string]json.RawMessage{"shared": json.RawMessage(`{"max_nodes": "ten"}`)}}, errorkind.Validation},
+		{"renamed", SimulationRequest{NodeGroups: map[string]json.RawMessage{"shared": json.RawMessage(`{"name": "other"}`)}}, errorkind.Validation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := c.simulate(tt.request, time.Now())
+			require.Error(t, err)
+			assert.Equal(t, tt.kind, errorkind.Of(err))
+		})
+	}
+}
+
+// serveSimulations handles the simulations of the controller the same as the main loop, until stopChan is closed
+func serveSimulations(c *Controller, stopChan chan struct{}) {
+	c.stopChan = stopChan
+	c.simulationChan = make(chan simulationRequest)
+	go func() {
+		for {
+			select {
+			case request := <-c.simulationChan:
+				result, err := c.simulate(request.request, time.Now())
+				request.result <- simulationResponse{result: result, err: err}
+			case <-stopChan:
+				return
+			}
+		}
+	}()
+}
+
+func TestControllerSimulateHandler(t *testing.T) {
+	c := buildSimulationController()
+	stopChan := make(chan struct{})
+	defer close(stopChan)
+	serveSimulations(c, stopChan)
+
+	recorder := httptest.NewRecorder()
+	c.SimulateHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/v1/simulate", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, recorder.Code)
+	assert.Equal(t, http.MethodPost, recorder.Header().Get("Allow"))
+
+	recorder = httptest.NewRecorder()
+	c.SimulateHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/api/v1/simulate", strings.NewReader(`{"node_groups": {"gpu": {}}}`)))
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	c.SimulateHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/api/v1/simulate", strings.NewReader(`{"node_groups": {"shared": {"taint_upper_capacity_threshold_percent": 60}}}`)))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+	var result SimulationResult
+	require.NoError(t, json.NewDecoder(recorder.Body).Decode(&result))
+	require.Len(t, result.NodeGroups, 1)
+	assert.Equal(t, CycleDecisionScaleDown, result.NodeGroups[0].Simulated.Decision)
+}
+
+func TestControllerSimulateHandler_Client(t *testing.T) {
+	c := buildSimulationController()
+	stopChan := make(chan struct{})
+	defer close(stopChan)
+	serveSimulations(c, stopChan)
+
+	mux := http.NewServeMux()
+	mux.Handle("/api/v1/simulate", c.SimulateHandler())
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := api.NewClient(server.URL, nil)
+
+	result, err := client.Simulate(api.SimulationRequest{NodeGroups: map[string]json.RawMessage{
+		"shared": json.RawMessage(`{"taint_upper_capacity_threshold_percent": 60}`),
+	}})
+	require.NoError(t, err)
+	require.Len(t, result.NodeGroups, 1)
+	simulation := result.NodeGroups[0]
+	assert.Equal(t, "shared", simulation.NodeGroup)
+	assert.Equal(t, []api.ConfigChange{{
+		Path: "node_groups.shared.taint_upper_capacity_threshold_percent",
+		Old:  "40",
+		New:  "60",
+	}}, simulation.Changes)
+	assert.Equal(t, CycleDecisionNone, simulation.Current.Decision)
+	assert.Equal(t, CycleDecisionScaleDown, simulation.Simulated.Decision)
+	assert.Equal(t, -1, simulation.Simulated.NodesDelta)
+
+	_, err = client.Simulate(api.SimulationRequest{NodeGroups: map[string]json.RawMessage{"gpu": json.RawMessage(`{}`)}})
+	require.Error(t, err)
+	assert.Equal(t, http.StatusNotFound, err.(*api.StatusError).StatusCode)
+
+	_, err = client.Simulate(api.SimulationRequest{NodeGroups: map[string]json.RawMessage{
+		"shared": json.RawMessage(`{"scale_up_threshold_percent": 30}`),
+	}})
+	require.Error(t, err)
+	assert.Equal(t, http.StatusBadRequest, err.(*api.StatusError).StatusCode)
+}
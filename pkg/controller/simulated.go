@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"github.com/atlassian/escalator/pkg/metrics"
+	v1 "k8s.io/api/core/v1"
+)
+
+// simulatedNodes tracks the scale ups and deletions skipped in dry mode, for the node count the node group would have
+// if they were real
+type simulatedNodes struct {
+	// the nodes the skipped scale ups would have added
+	added int
+	// the tainted nodes that would have been deleted
+	deleted map[string]bool
+}
+
+// simulateScaleUp records a scale up skipped by dry mode
+func (nodeGroup *NodeGroupState) simulateScaleUp(nodesToAdd int) {
+	nodeGroup.simulated.added += nodesToAdd
+	nodeGroup.updateSimulatedNodes()
+}
+
+// simulateDelete records the deletion of a tainted node skipped by dry mode
+func (nodeGroup *NodeGroupState) simulateDelete(name string) {
+	if nodeGroup.simulated.deleted == nil {
+		nodeGroup.simulated.deleted = make(map[string]bool)
+	}
+	nodeGroup.simulated.deleted[name] = true
+	nodeGroup.updateSimulatedNodes()
+}
+
+// simulateUntaint forgets the deletion of a node untainted in dry mode, as it would have been deleted for real before
+func (nodeGroup *NodeGroupState) simulateUntaint(name string) {
+	delete(nodeGroup.simulated.deleted, name)
+}
+
+// resetSimulatedNodes drops the simulated actions that are real now, and the deletions of nodes that are gone. Called
+// with the nodes of the node group at the start of each run
+func (c *Controller) resetSimulatedNodes(nodeGroup *NodeGroupState, allNodes []*v1.Node) {
+	if !c.dryScaleUp(nodeGroup) {
+		nodeGroup.simulated.added = 0
+	}
+	if !c.dryDelete(nodeGroup) {
+		nodeGroup.simulated.deleted = nil
+	}
+
+	names := make(map[string]bool, len(allNodes))
+	for _, node := range allNodes {
+		names[node.Name] = true
+	}
+	for name := range nodeGroup.simulated.deleted {
+		if !names[name] {
+			delete(nodeGroup.simulated.deleted, name)
+		}
+	}
+	nodeGroup.updateSimulatedNodes()
+}
+
+// simulatedNodeCount returns the nodes the node group would have if the actions skipped in dry mode were real, and how
+// many of them would be untainted, within the maximum of the node group. Nodes tainted in dry mode are already left out
+// of the untainted nodes. Both are the real counts when dry mode is off
+func (nodeGroup *NodeGroupState) simulatedNodeCount() (nodes int, untainted int) {
+	nodes = nodeGroup.cycle.Nodes + nodeGroup.simulated.added - len(nodeGroup.simulated.deleted)
+	untainted = nodeGroup.cycle.UntaintedNodes + nodeGroup.simulated.added
+	return clampSimulated(nodes, nodeGroup.Opts.MaxNodes), clampSimulated(untainted, nodeGroup.Opts.MaxNodes)
+}
+
+func clampSimulated(count int, maximum int) int {
+	if count > maximum {
+		return maximum
+	}
+	if count < 0 {
+		return 0
+	}
+	return count
+}
+
+// updateSimulatedNodes exports the simulated node counts
+func (nodeGroup *NodeGroupState) updateSimulatedNodes() {
+	nodes, untainted := nodeGroup.simulatedNodeCount()
+	metrics.NodeGroupSimulatedNodes.WithLabelValues(nodeGroup.Opts.Name).Set(float64(nodes))
+	metrics.NodeGroupSimulatedNodesUntainted.WithLabelValues(nodeGroup.Opts.Name).Set(float64(untainted))
+}
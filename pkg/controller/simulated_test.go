@@ -0,0 +1,60 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/atlassian/escalator/pkg/test"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestSimulatedNodeCount(t *testing.T) {
+	nodes := []*v1.Node{
+		test.BuildTestNode(test.NodeOpts{Name: "n1"}),
+		test.BuildTestNode(test.NodeOpts{Name: "n2"}),
+		test.BuildTestNode(test.NodeOpts{Name: "n3"}),
+	}
+	nodeGroup := &NodeGroupState{
+		Opts:  NodeGroupOptions{Name: "default", MaxNodes: 6},
+		cycle: CycleSummary{Nodes: 3, UntaintedNodes: 2},
+	}
+	c := &Controller{Opts: Opts{DryMode: true}}
+
+	c.resetSimulatedNodes(nodeGroup, nodes)
+	total, untainted := nodeGroup.simulatedNodeCount()
+	assert.Equal(t, 3, total)
+	assert.Equal(t, 2, untainted)
+
+	// skipped scale ups add up across runs, within the maximum of the node group
+	nodeGroup.simulateScaleUp(2)
+	c.resetSimulatedNodes(nodeGroup, nodes)
+	total, untainted = nodeGroup.simulatedNodeCount()
+	assert.Equal(t, 5, total)
+	assert.Equal(t, 4, untainted)
+	nodeGroup.simulateScaleUp(2)
+	total, untainted = nodeGroup.simulatedNodeCount()
+	assert.Equal(t, 6, total)
+	assert.Equal(t, 6, untainted)
+
+	// a skipped deletion is only counted once
+	nodeGroup.simulateDelete("n3")
+	nodeGroup.simulateDelete("n3")
+	total, _ = nodeGroup.simulatedNodeCount()
+	assert.Equal(t, 6, total)
+	assert.Len(t, nodeGroup.simulated.deleted, 1)
+
+	// and is forgotten when the node is untainted or gone
+	nodeGroup.simulateUntaint("n3")
+	assert.Empty(t, nodeGroup.simulated.deleted)
+	nodeGroup.simulateDelete("n3")
+	c.resetSimulatedNodes(nodeGroup, nodes[:2])
+	assert.Empty(t, nodeGroup.simulated.deleted)
+
+	// the simulated counts are the real counts once dry mode is off
+	nodeGroup.simulateDelete("n1")
+	c.Opts.DryMode = false
+	c.resetSimulatedNodes(nodeGroup, nodes)
+	total, untainted = nodeGroup.simulatedNodeCount()
+	assert.Equal(t, 3, total)
+	assert.Equal(t, 2, untainted)
+}
@@ -1,6 +1,9 @@
 package controller
 
-import v1 "k8s.io/api/core/v1"
+import (
+	"github.com/atlassian/escalator/pkg/k8s"
+	v1 "k8s.io/api/core/v1"
+)
 
 // nodeIndexBundle bundles an original index to a node so that it can be tracked during sorting
 type nodeIndexBundle struct {
@@ -37,3 +40,73 @@ func (n nodesByNewestCreationTime) Less(i, j int) bool {
 func (n nodesByNewestCreationTime) Swap(i, j int) {
 	n[i], n[j] = n[j], n[i]
 }
+
+// nodesByUnhealthyThenOldestCreationTime Sort functions for sorting unhealthy nodes first, e.g. nodes with any of the
+// unhealthy conditions, then by creation time
+type nodesByUnhealthyThenOldestCreationTime struct {
+	nodesByOldestCreationTime
+	unhealthy func(*v1.Node) bool
+}
+
+func (n nodesByUnhealthyThenOldestCreationTime) Less(i, j int) bool {
+	iUnhealthy := n.unhealthy(n.nodesByOldestCreationTime[i].node)
+	jUnhealthy := n.unhealthy(n.nodesByOldestCreationTime[j].node)
+	if iUnhealthy != jUnhealthy {
+		return iUnhealthy
+	}
+	return n.nodesByOldestCreationTime.Less(i, j)
+}
+
+// nodesByUnhealthyThenNewestCreationTime Sort functions for sorting unhealthy nodes first, then the most recently
+// created nodes first
+type nodesByUnhealthyThenNewestCreationTime struct {
+	nodesByUnhealthyThenOldestCreationTime
+}
+
+func (n nodesByUnhealthyThenNewestCreationTime) Less(i, j int) bool {
+	sorted := n.nodesByOldestCreationTime
+	iUnhealthy := n.unhealthy(sorted[i].node)
+	jUnhealthy := n.unhealthy(sorted[j].node)
+	if iUnhealthy != jUnhealthy {
+		return iUnhealthy
+	}
+	return sorted.Less(j, i)
+}
+
+// nodesByUnhealthyThenLowestScore Sort functions for sorting unhealthy nodes first, then by the lowest score, e.g. the
+// disruption score or utilisation, then by creation time
+type nodesByUnhealthyThenLowestScore struct {
+	nodesByUnhealthyThenOldestCreationTime
+	scores map[string]float64
+}
+
+func (n nodesByUnhealthyThenLowestScore) Less(i, j int) bool {
+	sorted := n.nodesByOldestCreationTime
+	iUnhealthy := n.unhealthy(sorted[i].node)
+	jUnhealthy := n.unhealthy(sorted[j].node)
+	if iUnhealthy != jUnhealthy {
+		return iUnhealthy
+	}
+	if iScore, jScore := n.scores[sorted[i].node.Name], n.scores[sorted[j].node.Name]; iScore != jScore {
+		return iScore < jScore
+	}
+	return sorted.Less(i, j)
+}
+
+// nodesByNewestTaintTime Sort functions for sorting the most recently tainted nodes first, then by creation time.
+// Nodes without a taint time, e.g. the nodes tainted in dry mode, go last
+type nodesByNewestTaintTime struct {
+	nodesByNewestCreationTime
+}
+
+func (n nodesByNewestTaintTime) Less(i, j int) bool {
+	iTime, _ := k8s.GetToBeRemovedTime(n.nodesByNewestCreationTime[i].node)
+	jTime, _ := k8s.GetToBeRemovedTime(n.nodesByNewestCreationTime[j].node)
+	if (iTime == nil) != (jTime == nil) {
+		return iTime != nil
+	}
+	if iTime != nil && !iTime.Equal(*jTime) {
+		return iTime.After(*jTime)
+	}
+	return n.nodesByNewestCreationTime.Less(i, j)
+}
@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"fmt"
 	"math/rand"
 	"sort"
 	"testing"
@@ -104,6 +105,57 @@ func TestSortNewestNode(t *testing.T) {
 	}
 }
 
+func TestSortNewestTaintTime(t *testing.T) {
+	taintedAt := func(node *v1.Node, at time.Time) *v1.Node {
+		node.Spec.Taints[0].Value = fmt.Sprint(at.Unix())
+		return node
+	}
+	now := time.Now()
+	// Ordered nodes for testing
+	nodesOrdered := []*v1.Node{
+		taintedAt(test.BuildTestNode(test.NodeOpts{
+			Creation: time.Date(1996, time.May, 12, 9, 0, 0, 0, time.UTC),
+			Tainted:  true,
+		}), now.Add(-time.Minute)),
+		taintedAt(test.BuildTestNode(test.NodeOpts{
+			Creation: time.Date(2018, time.January, 1, 1, 1, 0, 0, time.UTC),
+			Tainted:  true,
+		}), now.Add(-time.Hour)),
+		taintedAt(test.BuildTestNode(test.NodeOpts{
+			Creation: time.Date(2018, time.January, 1, 1, 0, 0, 0, time.UTC),
+			Tainted:  true,
+		}), now.Add(-time.Hour)),
+		test.BuildTestNode(test.NodeOpts{
+			Creation: time.Date(2020, time.December, 2, 2, 2, 2, 2, time.UTC),
+		}),
+		test.BuildTestNode(test.NodeOpts{
+			Creation: time.Date(2018, time.January, 1, 1, 1, 1, 1, time.UTC),
+		}),
+	}
+
+	// Shuffle with the nodesByNewestCreationTime bundle
+	shuffled := make(nodesByNewestCreationTime, 0, len(nodesOrdered))
+	for i, node := range nodesOrdered {
+		shuffled = append(shuffled, nodeIndexBundle{node, i})
+	}
+	shuffleNewest(shuffled)
+
+	// keep track of order before sorting
+	shuffledOrder := make([]int, 0, len(shuffled))
+	for i := range shuffled {
+		shuffledOrder = append(shuffledOrder, shuffled[i].index)
+	}
+
+	// sort and test
+	sort.Sort(nodesByNewestTaintTime{shuffled})
+	for i, bundle := range shuffled {
+		t.Run("sort newest tainted", func(t *testing.T) {
+			assert.Equal(t, nodesOrdered[i], bundle.node)
+			assert.Equal(t, i, shuffledOrder[i])
+		})
+	}
+}
+
 // shuffle shuffles the nodes and also swap their original indices for testing
 func shuffleOldest(nodes nodesByOldestCreationTime) {
 	for i := range nodes {
@@ -0,0 +1,137 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/atlassian/escalator/pkg/k8s"
+	"github.com/atlassian/escalator/pkg/metrics"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+)
+
+// ScaleUpReasonSpotInterruption is the scale up reason recorded on nodes brought up to replace nodes whose spot
+// instance is being interrupted
+const ScaleUpReasonSpotInterruption = "spot_interruption"
+
+// enabled returns whether the node group watches for spot interruption notices
+func (o SpotInterruptionOptions) enabled() bool {
+	return len(o.NodeConditions) > 0 || len(o.TaintKeys) > 0
+}
+
+// taintEffect returns the effect of the taint put on interrupted nodes, defaulting to NoExecute so their pods are
+// evicted straight away
+func (o SpotInterruptionOptions) taintEffect() v1.TaintEffect {
+	if len(o.TaintEffect) == 0 {
+		return v1.TaintEffectNoExecute
+	}
+	return o.TaintEffect
+}
+
+// interrupted returns whether the node has the node condition or taint of a spot interruption notice
+func (o SpotInterruptionOptions) interrupted(node *v1.Node) bool {
+	if k8s.NodeHasAnyCondition(node, o.NodeConditions) {
+		return true
+	}
+	for _, taint := range node.Spec.Taints {
+		for _, key := range o.TaintKeys {
+			if taint.Key == key {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// filterSpotInterruptedNodes separates out the nodes with a spot interruption notice. They are about to be reclaimed,
+// so they are neither capacity nor candidates for tainting on scale down
+func filterSpotInterruptedNodes(nodeGroup *NodeGroupState, nodes []*v1.Node) (remainingNodes, interruptedNodes []*v1.Node) {
+	if !nodeGroup.Opts.SpotInterruption.enabled() {
+		return nodes, nil
+	}
+
+	remainingNodes = make([]*v1.Node, 0, len(nodes))
+	for _, node := range nodes {
+		if nodeGroup.Opts.SpotInterruption.interrupted(node) {
+			interruptedNodes = append(interruptedNodes, node)
+			continue
+		}
+		remainingNodes = append(remainingNodes, node)
+	}
+	return remainingNodes, interruptedNodes
+}
+
+// handleSpotInterruptions taints the untainted nodes with a spot interruption notice so their pods move off before the
+// instance is reclaimed, and scales up to replace them. Tainted nodes are untainted for the replacement capacity first,
+// the same way as a normal scale up. The taint and replacement aren't held back by the scale down guards, e.g.
+// maintenance windows, as the cloud provider reclaims the instances regardless
+func (c *Controller) handleSpotInterruptions(opts scaleOpts, interruptedNodes []*v1.Node) (int, error) {
+	nodeGroup := opts.nodeGroup
+	logger := log.WithField("nodegroup", nodeGroup.Opts.Name)
+	logger.Infof("%v nodes have a spot interruption notice", len(interruptedNodes))
+	metrics.NodeGroupSpotInterruptions.WithLabelValues(nodeGroup.Opts.Name).Add(float64(len(interruptedNodes)))
+	if nodeGroup.Opts.ObserveOnly {
+		return observeDecision(nodeGroup, CycleDecisionSpotInterruption, len(interruptedNodes)), nil
+	}
+	nodeGroup.cycle.Decision = CycleDecisionSpotInterruption
+
+	tainted := c.taintInterruptedNodes(opts, interruptedNodes)
+	if len(tainted) == 0 || !nodeGroup.Opts.scaleUpEnabled() {
+		if len(tainted) > 0 {
+			logger.Infof("Not replacing %v interrupted nodes as scale_up_enabled is false", len(tainted))
+		}
+		return 0, nil
+	}
+
+	replacement := opts
+	replacement.nodesDelta = len(tainted)
+	replacement.reason = ScaleUpReasonSpotInterruption
+	result, err := c.ScaleUp(replacement)
+	if err != nil {
+		logger.WithError(err).Error("Failed to replace the interrupted nodes")
+	}
+	nodeGroup.cycle.NodesDeltaResult = result
+	return result, err
+}
+
+// taintInterruptedNodes taints the interrupted nodes with the spot interruption taint effect, in dry mode only in the
+// taint tracker. Like any taint, at most k8s.MaximumTaints nodes are tainted in a run
+func (c *Controller) taintInterruptedNodes(opts scaleOpts, interruptedNodes []*v1.Node) []*v1.Node {
+	nodeGroup := opts.nodeGroup
+	budget := k8s.NewTaintBudget(len(interruptedNodes))
+	_, cordon := nodeGroup.Opts.scaleDownMarks()
+
+	var tainted []*v1.Node
+	for _, node := range interruptedNodes {
+		if err := contextErr(opts.ctx, "tainting any more interrupted nodes"); err != nil {
+			log.WithField("nodegroup", nodeGroup.Opts.Name).WithError(err).Warn("Run was cancelled")
+			break
+		}
+		if c.dryTaint(nodeGroup) {
+			if err := budget.Allow(); err != nil {
+				log.WithField("drymode", "on").Errorf("While tainting %v: %v", node.Name, err)
+				break
+			}
+			log.WithField("drymode", "on").Infof("Tainting interrupted node %v", node.Name)
+			nodeGroup.dryTaintNode(node, time.Now())
+			budget.Spend()
+			tainted = append(tainted, node)
+			continue
+		}
+
+		log.WithField("drymode", "off").Infof("Tainting interrupted node %v", node.Name)
+		intent := nodeGroup.removalIntent(node, k8s.RemovalReasonSpotInterruption, time.Now())
+		updatedNode, err := k8s.MarkToBeRemoved(node, c.Client, nodeGroup.Opts.SpotInterruption.taintEffect(), true, cordon, intent, budget)
+		if err != nil {
+			log.Errorf("While tainting %v: %v", node.Name, err)
+			continue
+		}
+		tainted = append(tainted, updatedNode)
+	}
+
+	metrics.NodeGroupTaintEvent.WithLabelValues(nodeGroup.Opts.Name).Add(float64(len(tainted)))
+	if !c.dryTaint(nodeGroup) {
+		c.recordAction(nodeGroup, ActionTainted, tainted)
+		c.tagTaintedInstances(callContext(opts.ctx), nodeGroup, tainted, time.Now())
+	}
+	return tainted
+}
@@ -0,0 +1,122 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/atlassian/escalator/pkg/test"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+)
+
+// spotInterruptionNodes returns a node with the interruption taint, a node with the interruption condition and a node
+// without a notice
+func spotInterruptionNodes() []*v1.Node {
+	nodes := []*v1.Node{
+		test.BuildTestNode(test.NodeOpts{Name: "n1"}),
+		test.BuildTestNode(test.NodeOpts{Name: "n2"}),
+		test.BuildTestNode(test.NodeOpts{Name: "n3"}),
+	}
+	nodes[0].Spec.Taints = append(nodes[0].Spec.Taints, v1.Taint{Key: "aws-node-termination-handler/spot-itn", Effect: v1.TaintEffectNoSchedule})
+	nodes[1].Status.Conditions = append(nodes[1].Status.Conditions, v1.NodeCondition{Type: "SpotInterruption", Status: v1.ConditionTrue})
+	return nodes
+}
+
+func spotInterruptionOptions() SpotInterruptionOptions {
+	return SpotInterruptionOptions{
+		NodeConditions: []string{"SpotInterruption"},
+		TaintKeys:      []string{"aws-node-termination-handler/spot-itn"},
+	}
+}
+
+func TestFilterSpotInterruptedNodes(t *testing.T) {
+	nodes := spotInterruptionNodes()
+
+	// nothing is filtered without spot_interruption
+	nodeGroup := &NodeGroupState{Opts: NodeGroupOptions{Name: "spot"}}
+	remaining, interrupted := filterSpotInterruptedNodes(nodeGroup, nodes)
+	assert.Equal(t, nodes, remaining)
+	assert.Empty(t, interrupted)
+
+	nodeGroup.Opts.SpotInterruption = spotInterruptionOptions()
+	remaining, interrupted = filterSpotInterruptedNodes(nodeGroup, nodes)
+	assert.Equal(t, []*v1.Node{nodes[2]}, remaining)
+	assert.Equal(t, []*v1.Node{nodes[0], nodes[1]}, interrupted)
+
+	// a condition that isn't true isn't a notice
+	nodes[1].Status.Conditions[len(nodes[1].Status.Conditions)-1].Status = v1.ConditionFalse
+	_, interrupted = filterSpotInterruptedNodes(nodeGroup, nodes)
+	assert.Equal(t, []*v1.Node{nodes[0]}, interrupted)
+}
+
+func TestHandleSpotInterruptions(t *testing.T) {
+	scaleUpDisabled := false
+
+	tests := []struct {
+		name         string
+		opts         NodeGroupOptions
+		wantResult   int
+		wantTainted  []string
+		wantObserved bool
+	}{
+		{
+			name:        "taint and replace the interrupted nodes",
+			opts:        NodeGroupOptions{Name: "spot", CloudProviderGroupName: "asg", MaxNodes: 10, SpotInterruption: spotInterruptionOptions()},
+			wantResult:  2,
+			wantTainted: []string{"n1", "n2"},
+		},
+		{
+			name:        "taint without replacing with scale up disabled",
+			opts:        NodeGroupOptions{Name: "spot", CloudProviderGroupName: "asg", MaxNodes: 10, SpotInterruption: spotInterruptionOptions(), ScaleUpEnabled: &scaleUpDisabled},
+			wantResult:  0,
+			wantTainted: []string{"n1", "n2"},
+		},
+		{
+			name:         "only observe the interrupted nodes",
+			opts:         NodeGroupOptions{Name: "spot", CloudProviderGroupName: "asg", MaxNodes: 10, SpotInterruption: spotInterruptionOptions(), ObserveOnly: true},
+			wantResult:   2,
+			wantObserved: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodes := spotInterruptionNodes()
+			nodeGroup := &NodeGroupState{Opts: tt.opts}
+			c := &Controller{
+				Opts:          Opts{DryMode: true},
+				cloudProvider: &singleNodeGroupCloudProvider{test.NewCloudProvider(1), test.NewNodeGroup("asg", 0, 10, 3)},
+			}
+			untainted, interrupted := filterSpotInterruptedNodes(nodeGroup, nodes)
+
+			result, err := c.handleSpotInterruptions(scaleOpts{
+				ctx:            context.Background(),
+				nodes:          nodes,
+				untaintedNodes: untainted,
+				nodeGroup:      nodeGroup,
+			}, interrupted)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantResult, result)
+			assert.Equal(t, CycleDecisionSpotInterruption, nodeGroup.cycle.Decision)
+			assert.Equal(t, tt.wantObserved, nodeGroup.cycle.ObserveOnly)
+			if len(tt.wantTainted) > 0 {
+				assert.Equal(t, tt.wantTainted, nodeGroup.dryTaintedNodes())
+			} else {
+				assert.Empty(t, nodeGroup.taintTracker)
+			}
+		})
+	}
+}
+
+func TestScaleUpUntaint_SkipsSpotInterruptedNodes(t *testing.T) {
+	nodes := spotInterruptionNodes()
+	nodeGroup := &NodeGroupState{Opts: NodeGroupOptions{Name: "spot", DryMode: true, SpotInterruption: spotInterruptionOptions()}}
+	for _, node := range nodes {
+		nodeGroup.dryTaintNode(node, node.CreationTimestamp.Time)
+	}
+	c := &Controller{}
+
+	untainted, err := c.scaleUpUntaint(scaleOpts{ctx: context.Background(), nodes: nodes, taintedNodes: nodes, nodeGroup: nodeGroup, nodesDelta: 3})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, untainted)
+	assert.Equal(t, []string{"n1", "n2"}, nodeGroup.dryTaintedNodes())
+}
@@ -0,0 +1,100 @@
+package controller
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/atlassian/escalator/pkg/k8s"
+	"github.com/atlassian/escalator/pkg/metrics"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	// StaticPodNodesProtect never taints, recycles or deletes the nodes running static pods
+	StaticPodNodesProtect = "protect"
+	// StaticPodNodesIgnore scales down the nodes running static pods like any other node
+	StaticPodNodesIgnore = "ignore"
+)
+
+// nodeStaticPods returns the static pods and the mirror pods of static pods running on each of the nodes, as
+// namespace/name by node name. Nodes without any aren't in the map
+func nodeStaticPods(pods []*v1.Pod, nodes []*v1.Node) map[string][]string {
+	nodeNames := make(map[string]bool, len(nodes))
+	for _, node := range nodes {
+		nodeNames[node.Name] = true
+	}
+
+	static := make(map[string][]string)
+	for _, pod := range pods {
+		if !nodeNames[pod.Spec.NodeName] || (!k8s.PodIsStatic(pod) && !k8s.PodIsMirror(pod)) {
+			continue
+		}
+		static[pod.Spec.NodeName] = append(static[pod.Spec.NodeName], pod.Namespace+"/"+pod.Name)
+	}
+	for _, names := range static {
+		sort.Strings(names)
+	}
+	return static
+}
+
+// detectStaticPodNodes finds the nodes of the node group running static pods. Static pods aren't in the pods of the
+// node group as they aren't scheduled, so every pod is looked at. Such nodes are usually control plane nodes matched by
+// the label of the node group by mistake, so they are warned about on every run
+func (c *Controller) detectStaticPodNodes(nodeGroup *NodeGroupState, nodes []*v1.Node) error {
+	pods, err := c.Client.allPodLister.List(labels.Everything())
+	if err != nil {
+		return err
+	}
+	nodeGroup.staticPodNodes = nodeStaticPods(pods, nodes)
+	metrics.NodeGroupNodesStaticPods.WithLabelValues(nodeGroup.Opts.Name).Set(float64(len(nodeGroup.staticPodNodes)))
+	if len(nodeGroup.staticPodNodes) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(nodeGroup.staticPodNodes))
+	for name, pods := range nodeGroup.staticPodNodes {
+		names = append(names, name+" ("+strings.Join(pods, ", ")+")")
+	}
+	sort.Strings(names)
+	action := "they are never scaled down"
+	if nodeGroup.Opts.staticPodNodes() == StaticPodNodesIgnore {
+		action = "they are scaled down like any other node as static_pod_nodes is ignore"
+	}
+	log.WithField("nodegroup", nodeGroup.Opts.Name).Warningf(
+		"%v nodes of the node group are running static pods, %v. Check the label of the node group doesn't match control plane nodes: %v",
+		len(names), action, strings.Join(names, "; "),
+	)
+	return nil
+}
+
+// staticPodsBlockScaleDown returns whether the node can't be scaled down because it is running static pods, and alerts
+// through the static_pods guardrail if so
+func (c *Controller) staticPodsBlockScaleDown(nodeGroup *NodeGroupState, node *v1.Node, action string) bool {
+	if !staticPodsProtect(nodeGroup, node) {
+		return false
+	}
+	c.recordGuardrail(nodeGroup, GuardrailStaticPods, "not %v node %v, it is running static pods %v", action, node.Name, strings.Join(nodeGroup.staticPodNodes[node.Name], ", "))
+	return true
+}
+
+// withoutStaticPodNodes returns the nodes, except for those running static pods when the node group protects them
+func withoutStaticPodNodes(nodeGroup *NodeGroupState, nodes []*v1.Node) []*v1.Node {
+	var filtered []*v1.Node
+	for _, node := range nodes {
+		if !staticPodsProtect(nodeGroup, node) {
+			filtered = append(filtered, node)
+		}
+	}
+	return filtered
+}
+
+// staticPodsProtect returns whether the node is running static pods and the node group protects such nodes
+func staticPodsProtect(nodeGroup *NodeGroupState, node *v1.Node) bool {
+	if nodeGroup.Opts.staticPodNodes() != StaticPodNodesProtect {
+		return false
+	}
+	_, ok := nodeGroup.staticPodNodes[node.Name]
+	return ok
+}
@@ -0,0 +1,107 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/atlassian/escalator/pkg/k8s"
+	"github.com/atlassian/escalator/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+func buildStaticPodNodesTestPods() []*v1.Pod {
+	static := test.BuildTestPod(test.PodOpts{Name: "etcd-n1", Namespace: "kube-system", NodeName: "n1"})
+	static.Annotations = map[string]string{"kubernetes.io/config.source": "file"}
+	mirror := test.BuildTestPod(test.PodOpts{Name: "kube-apiserver-n2", Namespace: "kube-system", NodeName: "n2"})
+	mirror.Annotations = map[string]string{"kubernetes.io/config.mirror": "5c9ae0a8d1ab4f1c"}
+	other := test.BuildTestPod(test.PodOpts{Name: "web", Namespace: "default", NodeName: "n3"})
+	elsewhere := test.BuildTestPod(test.PodOpts{Name: "etcd-n9", Namespace: "kube-system", NodeName: "n9"})
+	elsewhere.Annotations = map[string]string{"kubernetes.io/config.mirror": "0c1f9e2d"}
+	return []*v1.Pod{static, mirror, other, elsewhere}
+}
+
+func TestNodeStaticPods(t *testing.T) {
+	nodes := []*v1.Node{
+		test.BuildTestNode(test.NodeOpts{Name: "n1"}),
+		test.BuildTestNode(test.NodeOpts{Name: "n2"}),
+		test.BuildTestNode(test.NodeOpts{Name: "n3"}),
+	}
+
+	assert.Equal(t, map[string][]string{
+		"n1": {"kube-system/etcd-n1"},
+		"n2": {"kube-system/kube-apiserver-n2"},
+	}, nodeStaticPods(buildStaticPodNodesTestPods(), nodes))
+	assert.Empty(t, nodeStaticPods(nil, nodes))
+}
+
+func TestDetectStaticPodNodes(t *testing.T) {
+	nodeGroups := []NodeGroupOptions{{Name: "default"}}
+	nodes := []*v1.Node{
+		test.BuildTestNode(test.NodeOpts{Name: "n1"}),
+		test.BuildTestNode(test.NodeOpts{Name: "n3"}),
+	}
+	client, opts := buildTestClient(nodes, buildStaticPodNodesTestPods(), nodeGroups, ListerOptions{})
+	nodeGroupsState := BuildNodeGroupsState(nodeGroupsStateOpts{nodeGroups: nodeGroups, client: *client})
+	c := &Controller{Client: client, Opts: opts, nodeGroups: nodeGroupsState}
+
+	require.NoError(t, c.detectStaticPodNodes(nodeGroupsState["default"], nodes))
+	assert.Equal(t, map[string][]string{"n1": {"kube-system/etcd-n1"}}, nodeGroupsState["default"].staticPodNodes)
+}
+
+func TestTaintOldestN_StaticPodNodes(t *testing.T) {
+	nodes := []*v1.Node{
+		test.BuildTestNode(test.NodeOpts{Name: "n1"}),
+		test.BuildTestNode(test.NodeOpts{Name: "n2"}),
+		test.BuildTestNode(test.NodeOpts{Name: "n3"}),
+	}
+
+	tests := []struct {
+		name           string
+		staticPodNodes string
+		want           []int
+		wantEvent      bool
+	}{
+		{"protected by default", "", []int{1, 2}, true},
+		{"protect", StaticPodNodesProtect, []int{1, 2}, true},
+		{"ignore", StaticPodNodesIgnore, []int{0, 1, 2}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodeGroup := &NodeGroupState{
+				Opts:           NodeGroupOptions{Name: "default", DryMode: true, StaticPodNodes: tt.staticPodNodes},
+				staticPodNodes: map[string][]string{"n1": {"kube-system/etcd-n1"}},
+			}
+			recorder := record.NewFakeRecorder(10)
+			c := &Controller{Opts: Opts{
+				EventRecorder: recorder,
+				EventObject:   &v1.ObjectReference{Kind: "Pod", Namespace: "kube-system", Name: "escalator"},
+			}}
+
+			assert.ElementsMatch(t, tt.want, c.taintOldestN(context.Background(), nodes, nodeGroup, k8s.NewTaintBudget(3)))
+			events := drainEvents(recorder)
+			if tt.wantEvent {
+				assert.Equal(t, []string{
+					"Warning StaticPodsBlockScaleDown node group default: not tainting node n1, it is running static pods kube-system/etcd-n1",
+				}, events)
+			} else {
+				assert.Empty(t, events)
+			}
+		})
+	}
+}
+
+func TestWithoutStaticPodNodes(t *testing.T) {
+	n1 := test.BuildTestNode(test.NodeOpts{Name: "n1"})
+	n2 := test.BuildTestNode(test.NodeOpts{Name: "n2"})
+	nodeGroup := &NodeGroupState{
+		Opts:           NodeGroupOptions{Name: "default"},
+		staticPodNodes: map[string][]string{"n1": {"kube-system/etcd-n1"}},
+	}
+
+	assert.Equal(t, []*v1.Node{n2}, withoutStaticPodNodes(nodeGroup, []*v1.Node{n1, n2}))
+	nodeGroup.Opts.StaticPodNodes = StaticPodNodesIgnore
+	assert.Equal(t, []*v1.Node{n1, n2}, withoutStaticPodNodes(nodeGroup, []*v1.Node{n1, n2}))
+}
@@ -0,0 +1,230 @@
+package controller
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/atlassian/escalator/pkg/api"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+	log "github.com/sirupsen/logrus"
+)
+
+// redactedValue replaces the secrets in the configs of a snapshot
+const redactedValue = "REDACTED"
+
+// Snapshot is the state of the controller collected for a support bundle. The secrets in the configs are redacted
+type Snapshot struct {
+	// Config is the config currently applied
+	Config        Config                    `json:"config"`
+	ConfigHistory []ConfigRevision          `json:"config_history"`
+	Diagnostics   Diagnostics               `json:"diagnostics"`
+	Cycles        map[string][]CycleSummary `json:"cycles"`
+}
+
+// Snapshot asks the main loop for a snapshot of the controller. The snapshot is taken between runs, so Snapshot
+// blocks until the current run has finished
+func (c *Controller) Snapshot() (Snapshot, error) {
+	result := make(chan Snapshot, 1)
+	select {
+	case c.snapshotChan <- result:
+		return <-result, nil
+	case <-c.stopChan:
+		return Snapshot{}, errors.New("controller stopped")
+	}
+}
+
+// snapshot returns the redacted state of the controller. It must only be called from the main loop
+func (c *Controller) snapshot() Snapshot {
+	snapshot := Snapshot{
+		Config:        redactConfig(Config{ClusterOptions: c.Opts.Cluster, NodeGroups: c.Opts.NodeGroups}),
+		ConfigHistory: c.ConfigHistory(),
+		Diagnostics:   c.Diagnostics(),
+		Cycles:        c.Cycles(),
+	}
+	for i, revision := range snapshot.ConfigHistory {
+		// the changes are shared with the history, so they are copied before being redacted
+		changes := make([]ConfigChange, 0, len(revision.Changes))
+		for _, change := range revision.Changes {
+			changes = append(changes, redactConfigChange(change))
+		}
+		snapshot.ConfigHistory[i].Config = redactConfig(revision.Config)
+		snapshot.ConfigHistory[i].Changes = changes
+	}
+	nodeGroups := make(map[string]NodeGroupDiagnostics, len(snapshot.Diagnostics.NodeGroups))
+	for name, nodeGroup := range snapshot.Diagnostics.NodeGroups {
+		nodeGroup.Opts = redactNodeGroupOptions(nodeGroup.Opts)
+		nodeGroups[name] = nodeGroup
+	}
+	snapshot.Diagnostics.NodeGroups = nodeGroups
+	return snapshot
+}
+
+// WriteSupportBundle writes a gzipped tarball of the snapshot of the controller, the metrics of the gatherer and the
+// version info, with one file each for the config, config history, node group states, cycles, metrics and version
+func WriteSupportBundle(w io.Writer, snapshot Snapshot, gatherer prometheus.Gatherer, version api.VersionInfo) error {
+	var metrics bytes.Buffer
+	families, err := gatherer.Gather()
+	if err != nil {
+		// the metrics that were gathered are still written
+		log.WithError(err).Warn("Failed to gather some of the metrics for the support bundle")
+	}
+	for _, family := range families {
+		if _, err := expfmt.MetricFamilyToText(&metrics, family); err != nil {
+			return errors.Wrap(err, "failed to encode metrics")
+		}
+	}
+
+	files := []struct {
+		name    string
+		content interface{}
+	}{
+		{"version.json", version},
+		{"config.json", snapshot.Config},
+		{"config_history.json", snapshot.ConfigHistory},
+		{"node_groups.json", snapshot.Diagnostics},
+		{"cycles.json", snapshot.Cycles},
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	dir := strings.TrimSuffix(api.SupportBundleFileName(version.Time), ".tar.gz")
+	for _, file := range files {
+		encoded, err := json.MarshalIndent(file.content, "", "  ")
+		if err != nil {
+			return errors.Wrapf(err, "failed to encode %v", file.name)
+		}
+		if err := writeTarFile(tw, dir+"/"+file.name, encoded, version.Time); err != nil {
+			return err
+		}
+	}
+	if err := writeTarFile(tw, dir+"/metrics.txt", metrics.Bytes(), version.Time); err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return errors.Wrap(err, "failed to close support bundle tarball")
+	}
+	return errors.Wrap(gz.Close(), "failed to close support bundle gzip")
+}
+
+func writeTarFile(tw *tar.Writer, name string, content []byte, modTime time.Time) error {
+	header := &tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    int64(len(content)),
+		ModTime: modTime,
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return errors.Wrapf(err, "failed to write %v", name)
+	}
+	_, err := tw.Write(content)
+	return errors.Wrapf(err, "failed to write %v", name)
+}
+
+// SupportBundleHandler serves the support bundle of the controller as a gzipped tarball, at api.SupportBundlePath. The
+// snapshot is taken between runs, so the response waits for the current run to finish
+func (c *Controller) SupportBundleHandler(gatherer prometheus.Gatherer, version string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		snapshot, err := c.Snapshot()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+
+		now := time.Now()
+		// the bundle is built in memory first so a failure can still be reported with a status code
+		var bundle bytes.Buffer
+		if err := WriteSupportBundle(&bundle, snapshot, gatherer, api.NewVersionInfo(version, now)); err != nil {
+			log.WithError(err).Error("Failed to build support bundle")
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/gzip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", api.SupportBundleFileName(now)))
+		if _, err := bundle.WriteTo(w); err != nil {
+			log.WithError(err).Warn("Failed to write support bundle")
+		}
+	})
+}
+
+// redactConfig returns a copy of the config without its secrets
+func redactConfig(config Config) Config {
+	redacted := Config{ClusterOptions: config.ClusterOptions, NodeGroups: make([]NodeGroupOptions, 0, len(config.NodeGroups))}
+	for _, nodeGroup := range config.NodeGroups {
+		redacted.NodeGroups = append(redacted.NodeGroups, redactNodeGroupOptions(nodeGroup))
+	}
+	return redacted
+}
+
+// redactNodeGroupOptions returns the options without the credentials and query of the pre_delete_hook url, which may
+// carry a token
+func redactNodeGroupOptions(opts NodeGroupOptions) NodeGroupOptions {
+	opts.PreDeleteHook.URL = redactURL(opts.PreDeleteHook.URL)
+	return opts
+}
+
+// redactConfigChange returns the change without the secrets of its values. The values are either the pre_delete_hook
+// url, or a whole node group that was added or removed
+func redactConfigChange(change ConfigChange) ConfigChange {
+	if !strings.HasPrefix(change.Path, "node_groups.") {
+		return change
+	}
+	change.Old = redactConfigChangeValue(change.Path, change.Old)
+	change.New = redactConfigChangeValue(change.Path, change.New)
+	return change
+}
+
+func redactConfigChangeValue(path string, value string) string {
+	if len(value) == 0 {
+		return value
+	}
+	if strings.HasSuffix(path, ".pre_delete_hook.url") {
+		var rawURL string
+		if err := json.Unmarshal([]byte(value), &rawURL); err != nil {
+			return redactedValue
+		}
+		encoded, _ := json.Marshal(redactURL(rawURL))
+		return string(encoded)
+	}
+	// the leaves of the node group options are never objects, so an object is a whole node group
+	if strings.HasPrefix(value, "{") {
+		var nodeGroup NodeGroupOptions
+		if err := json.Unmarshal([]byte(value), &nodeGroup); err != nil {
+			return redactedValue
+		}
+		encoded, _ := json.Marshal(redactNodeGroupOptions(nodeGroup))
+		return string(encoded)
+	}
+	return value
+}
+
+// redactURL removes the user info and the values of the query of the url
+func redactURL(rawURL string) string {
+	if len(rawURL) == 0 {
+		return rawURL
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return redactedValue
+	}
+	if u.User != nil {
+		u.User = url.User(redactedValue)
+	}
+	if query := u.Query(); len(query) > 0 {
+		for key := range query {
+			query.Set(key, redactedValue)
+		}
+		u.RawQuery = query.Encode()
+	}
+	return u.String()
+}
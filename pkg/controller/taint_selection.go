@@ -0,0 +1,102 @@
+package controller
+
+import (
+	"math"
+	"time"
+
+	"github.com/atlassian/escalator/pkg/k8s"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	// TaintSelectionPolicyOldest taints the oldest nodes first
+	TaintSelectionPolicyOldest = "oldest"
+	// TaintSelectionPolicyNewest taints the most recently created nodes first
+	TaintSelectionPolicyNewest = "newest"
+	// TaintSelectionPolicyLeastUtilized taints the nodes with the lowest share of their allocatable cpu or memory
+	// requested first
+	TaintSelectionPolicyLeastUtilized = "least-utilized"
+	// TaintSelectionPolicyFewestPods taints the nodes running the fewest pods first, not counting DaemonSet pods
+	TaintSelectionPolicyFewestPods = "fewest-pods"
+	// TaintSelectionPolicyDisruptionScore taints the nodes with the lowest disruption_score first
+	TaintSelectionPolicyDisruptionScore = "disruption-score"
+)
+
+// TaintSelectionPolicies are the values of taint_selection_policy
+var TaintSelectionPolicies = []string{
+	TaintSelectionPolicyOldest,
+	TaintSelectionPolicyNewest,
+	TaintSelectionPolicyLeastUtilized,
+	TaintSelectionPolicyFewestPods,
+	TaintSelectionPolicyDisruptionScore,
+}
+
+// validTaintSelectionPolicy returns whether the policy is empty or one of the taint selection policies
+func validTaintSelectionPolicy(policy string) bool {
+	if len(policy) == 0 {
+		return true
+	}
+	for _, valid := range TaintSelectionPolicies {
+		if policy == valid {
+			return true
+		}
+	}
+	return false
+}
+
+// taintSelectionPolicy returns the taint_selection_policy, defaulting to disruption-score with disruption_score.enabled
+// and oldest otherwise
+func (n *NodeGroupOptions) taintSelectionPolicy() string {
+	if len(n.TaintSelectionPolicy) > 0 {
+		return n.TaintSelectionPolicy
+	}
+	if n.DisruptionScore.Enabled {
+		return TaintSelectionPolicyDisruptionScore
+	}
+	return TaintSelectionPolicyOldest
+}
+
+// nodeRequestedPercent returns the larger of the percentages of the allocatable cpu and memory of the node requested by
+// its pods
+func nodeRequestedPercent(nodeGroup *NodeGroupState, node *v1.Node) float64 {
+	nodeInfo, ok := nodeGroup.NodeInfoMap[node.Name]
+	if !ok {
+		return 0
+	}
+	allocatable := nodeInfo.AllocatableResource()
+	requested := nodeInfo.RequestedResource()
+
+	var percent float64
+	if allocatable.MilliCPU > 0 {
+		percent = math.Max(percent, float64(requested.MilliCPU)/float64(allocatable.MilliCPU)*100)
+	}
+	if allocatable.Memory > 0 {
+		percent = math.Max(percent, float64(requested.Memory)/float64(allocatable.Memory)*100)
+	}
+	return percent
+}
+
+// taintSelectionScores returns the score of each node by name for the policies that taint the lowest scores first, or
+// nil for the policies that go by creation time
+func taintSelectionScores(nodeGroup *NodeGroupState, nodes []*v1.Node, now time.Time) map[string]float64 {
+	var scores map[string]float64
+	switch nodeGroup.Opts.taintSelectionPolicy() {
+	case TaintSelectionPolicyDisruptionScore:
+		scores = make(map[string]float64, len(nodes))
+		for _, score := range nodeDisruptionScores(nodeGroup, nodes, now) {
+			scores[score.Node] = score.Score
+		}
+	case TaintSelectionPolicyLeastUtilized:
+		scores = make(map[string]float64, len(nodes))
+		for _, node := range nodes {
+			scores[node.Name] = nodeRequestedPercent(nodeGroup, node)
+		}
+	case TaintSelectionPolicyFewestPods:
+		scores = make(map[string]float64, len(nodes))
+		for _, node := range nodes {
+			pods, _ := k8s.NodePodsRemaining(node, nodeGroup.NodeInfoMap)
+			scores[node.Name] = float64(pods)
+		}
+	}
+	return scores
+}
@@ -0,0 +1,79 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/atlassian/escalator/pkg/k8s"
+	"github.com/atlassian/escalator/pkg/test"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestTaintOrder_TaintSelectionPolicy(t *testing.T) {
+	nodes := []*v1.Node{
+		test.BuildTestNode(test.NodeOpts{Name: "n1", CPU: 1000, Mem: 1000, Creation: time.Date(2010, 3, 3, 13, 0, 0, 0, time.UTC)}),
+		test.BuildTestNode(test.NodeOpts{Name: "n2", CPU: 1000, Mem: 1000, Creation: time.Date(2011, 3, 3, 13, 0, 0, 0, time.UTC)}),
+		test.BuildTestNode(test.NodeOpts{Name: "n3", CPU: 1000, Mem: 1000, Creation: time.Date(2012, 3, 3, 13, 0, 0, 0, time.UTC)}),
+		test.BuildTestNode(test.NodeOpts{Name: "n4", CPU: 1000, Mem: 1000, Creation: time.Date(2013, 3, 3, 13, 0, 0, 0, time.UTC)}),
+	}
+	nodes[3].Status.Conditions = []v1.NodeCondition{{Type: "KernelDeadlock", Status: v1.ConditionTrue}}
+	// n1 is 30% utilised by 2 pods, n2 60% by 1 pod, n3 15% by 3 pods and n4 90% by 4 pods
+	pods := []*v1.Pod{
+		test.BuildTestPod(test.PodOpts{Name: "p1", NodeName: "n1", CPU: []int64{100}, Mem: []int64{100}}),
+		test.BuildTestPod(test.PodOpts{Name: "p2", NodeName: "n1", CPU: []int64{100}, Mem: []int64{200}}),
+		test.BuildTestPod(test.PodOpts{Name: "p3", NodeName: "n2", CPU: []int64{600}, Mem: []int64{100}}),
+		test.BuildTestPod(test.PodOpts{Name: "p4", NodeName: "n3", CPU: []int64{50}, Mem: []int64{50}}),
+		test.BuildTestPod(test.PodOpts{Name: "p5", NodeName: "n3", CPU: []int64{50}, Mem: []int64{50}}),
+		test.BuildTestPod(test.PodOpts{Name: "p6", NodeName: "n3", CPU: []int64{50}, Mem: []int64{50}}),
+		test.BuildTestPod(test.PodOpts{Name: "p7", NodeName: "n4", CPU: []int64{300}, Mem: []int64{100}}),
+		test.BuildTestPod(test.PodOpts{Name: "p8", NodeName: "n4", CPU: []int64{300}, Mem: []int64{100}}),
+		test.BuildTestPod(test.PodOpts{Name: "p9", NodeName: "n4", CPU: []int64{200}, Mem: []int64{100}}),
+		test.BuildTestPod(test.PodOpts{Name: "p10", NodeName: "n4", CPU: []int64{100}, Mem: []int64{100}}),
+	}
+
+	tests := []struct {
+		name       string
+		policy     string
+		conditions []string
+		want       []string
+	}{
+		{"oldest by default", "", nil, []string{"n1", "n2", "n3", "n4"}},
+		{"oldest", TaintSelectionPolicyOldest, nil, []string{"n1", "n2", "n3", "n4"}},
+		{"newest", TaintSelectionPolicyNewest, nil, []string{"n4", "n3", "n2", "n1"}},
+		{"least utilized", TaintSelectionPolicyLeastUtilized, nil, []string{"n3", "n1", "n2", "n4"}},
+		{"fewest pods", TaintSelectionPolicyFewestPods, nil, []string{"n2", "n1", "n3", "n4"}},
+		{"disruption score", TaintSelectionPolicyDisruptionScore, nil, []string{"n2", "n1", "n3", "n4"}},
+		{"unhealthy node first", TaintSelectionPolicyLeastUtilized, []string{"KernelDeadlock"}, []string{"n4", "n3", "n1", "n2"}},
+		{"unhealthy node first when newest", TaintSelectionPolicyNewest, []string{"KernelDeadlock"}, []string{"n4", "n3", "n2", "n1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodeGroup := &NodeGroupState{
+				Opts: NodeGroupOptions{
+					Name:                    "buildeng",
+					UnhealthyNodeConditions: tt.conditions,
+					TaintSelectionPolicy:    tt.policy,
+				},
+				NodeInfoMap: k8s.CreateNodeNameToInfoMap(pods, nodes),
+			}
+
+			var got []string
+			for _, bundle := range taintOrder(nodeGroup, nodes, time.Now()) {
+				got = append(got, bundle.node.Name)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestTaintSelectionPolicy(t *testing.T) {
+	opts := NodeGroupOptions{}
+	assert.Equal(t, TaintSelectionPolicyOldest, opts.taintSelectionPolicy())
+	opts.DisruptionScore.Enabled = true
+	assert.Equal(t, TaintSelectionPolicyDisruptionScore, opts.taintSelectionPolicy())
+	opts.TaintSelectionPolicy = TaintSelectionPolicyDisruptionScore
+	assert.Equal(t, TaintSelectionPolicyDisruptionScore, opts.taintSelectionPolicy())
+	opts = NodeGroupOptions{TaintSelectionPolicy: TaintSelectionPolicyNewest}
+	assert.Equal(t, TaintSelectionPolicyNewest, opts.taintSelectionPolicy())
+}
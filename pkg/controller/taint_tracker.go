@@ -0,0 +1,101 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/atlassian/escalator/pkg/k8s"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// DryModeTaint is a node tainted in dry mode, which is only tainted in the taint tracker of its node group
+type DryModeTaint struct {
+	Node string `json:"node"`
+	// UID tells the node apart from a later node registered with the same name, and keeps the taint if the node is
+	// renamed
+	UID types.UID `json:"uid,omitempty"`
+	// TaintedAt is the time the node would have been tainted, for the soft and hard delete grace periods
+	TaintedAt time.Time `json:"tainted_at"`
+}
+
+// matches returns whether the taint is of the node. Taints are matched by the uid of the node, or by its name when
+// either doesn't have a uid
+func (t DryModeTaint) matches(node *v1.Node) bool {
+	if len(t.UID) > 0 && len(node.UID) > 0 {
+		return t.UID == node.UID
+	}
+	return t.Node == node.Name
+}
+
+// dryTaintNode records the taint of the node in dry mode
+func (nodeGroup *NodeGroupState) dryTaintNode(node *v1.Node, now time.Time) {
+	nodeGroup.taintTracker = append(nodeGroup.taintTracker, DryModeTaint{Node: node.Name, UID: node.UID, TaintedAt: now})
+}
+
+// dryUntaintNode forgets the taint of the node in dry mode. Returns false if the node wasn't tainted
+func (nodeGroup *NodeGroupState) dryUntaintNode(node *v1.Node) bool {
+	for i, taint := range nodeGroup.taintTracker {
+		if taint.matches(node) {
+			nodeGroup.taintTracker = append(nodeGroup.taintTracker[:i], nodeGroup.taintTracker[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// dryModeTaint returns the taint of the node in dry mode, false if the node isn't tainted
+func (nodeGroup *NodeGroupState) dryModeTaint(node *v1.Node) (DryModeTaint, bool) {
+	for _, taint := range nodeGroup.taintTracker {
+		if taint.matches(node) {
+			return taint, true
+		}
+	}
+	return DryModeTaint{}, false
+}
+
+// dryTaintedNodes returns the names of the nodes tainted in dry mode
+func (nodeGroup *NodeGroupState) dryTaintedNodes() []string {
+	names := make([]string, 0, len(nodeGroup.taintTracker))
+	for _, taint := range nodeGroup.taintTracker {
+		names = append(names, taint.Node)
+	}
+	return names
+}
+
+// expireDryModeTaints drops the taints of the nodes that are gone from the node group, and follows the nodes that were
+// renamed. Called with the nodes of the node group at the start of each run
+func expireDryModeTaints(nodeGroup *NodeGroupState, allNodes []*v1.Node) {
+	if len(nodeGroup.taintTracker) == 0 {
+		return
+	}
+	kept := nodeGroup.taintTracker[:0]
+	for _, taint := range nodeGroup.taintTracker {
+		var found *v1.Node
+		for _, node := range allNodes {
+			if taint.matches(node) {
+				found = node
+				break
+			}
+		}
+		if found == nil {
+			log.WithField("nodegroup", nodeGroup.Opts.Name).WithField("drymode", "on").Debugf("Forgetting the taint of node %v, it is gone", taint.Node)
+			continue
+		}
+		taint.Node = found.Name
+		kept = append(kept, taint)
+	}
+	nodeGroup.taintTracker = kept
+}
+
+// toBeRemovedTime returns the time the node was tainted, from the taint tracker when tainting is simulated
+func (c *Controller) toBeRemovedTime(nodeGroup *NodeGroupState, node *v1.Node) (*time.Time, error) {
+	if !c.dryTaint(nodeGroup) {
+		return k8s.GetToBeRemovedTime(node)
+	}
+	taint, ok := nodeGroup.dryModeTaint(node)
+	if !ok {
+		return nil, nil
+	}
+	return &taint.TaintedAt, nil
+}
@@ -0,0 +1,103 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/atlassian/escalator/pkg/k8s"
+	"github.com/atlassian/escalator/pkg/test"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestDryModeTaintMatches(t *testing.T) {
+	node := test.BuildTestNode(test.NodeOpts{Name: "n1"})
+	node.UID = types.UID("uid-1")
+	renamed := test.BuildTestNode(test.NodeOpts{Name: "n2"})
+	renamed.UID = types.UID("uid-1")
+	reregistered := test.BuildTestNode(test.NodeOpts{Name: "n1"})
+	reregistered.UID = types.UID("uid-2")
+
+	taint := DryModeTaint{Node: "n1", UID: "uid-1"}
+	assert.True(t, taint.matches(node))
+	assert.True(t, taint.matches(renamed))
+	assert.False(t, taint.matches(reregistered))
+	// taints from before uids were tracked are matched by name
+	assert.True(t, DryModeTaint{Node: "n1"}.matches(reregistered))
+}
+
+func TestExpireDryModeTaints(t *testing.T) {
+	now := time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC)
+	n1 := test.BuildTestNode(test.NodeOpts{Name: "n1"})
+	n1.UID = types.UID("uid-1")
+	n2 := test.BuildTestNode(test.NodeOpts{Name: "n2"})
+	n2.UID = types.UID("uid-2")
+	nodeGroup := &NodeGroupState{Opts: NodeGroupOptions{Name: "default"}}
+	nodeGroup.dryTaintNode(n1, now)
+	nodeGroup.dryTaintNode(n2, now.Add(time.Minute))
+
+	// n1 was renamed and n2 is gone
+	renamed := test.BuildTestNode(test.NodeOpts{Name: "n1-renamed"})
+	renamed.UID = types.UID("uid-1")
+	expireDryModeTaints(nodeGroup, []*v1.Node{renamed})
+	assert.Equal(t, []DryModeTaint{{Node: "n1-renamed", UID: "uid-1", TaintedAt: now}}, nodeGroup.taintTracker)
+
+	assert.False(t, nodeGroup.dryUntaintNode(n2))
+	assert.True(t, nodeGroup.dryUntaintNode(renamed))
+	assert.Empty(t, nodeGroup.taintTracker)
+}
+
+func TestToBeRemovedTime(t *testing.T) {
+	now := time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC)
+	node := test.BuildTestNode(test.NodeOpts{Name: "n1"})
+	nodeGroup := &NodeGroupState{Opts: NodeGroupOptions{Name: "default"}}
+	nodeGroup.dryTaintNode(node, now)
+
+	c := &Controller{Opts: Opts{DryMode: true}}
+	taintedTime, err := c.toBeRemovedTime(nodeGroup, node)
+	assert.NoError(t, err)
+	assert.Equal(t, now, *taintedTime)
+
+	// the node isn't tainted for real
+	c.Opts.DryMode = false
+	taintedTime, err = c.toBeRemovedTime(nodeGroup, node)
+	assert.NoError(t, err)
+	assert.Nil(t, taintedTime)
+}
+
+func TestTryRemoveTaintedNodes_DryMode(t *testing.T) {
+	now := time.Now()
+	nodes := []*v1.Node{
+		test.BuildTestNode(test.NodeOpts{Name: "n1"}),
+		test.BuildTestNode(test.NodeOpts{Name: "n2"}),
+	}
+
+	tests := []struct {
+		name        string
+		opts        NodeGroupOptions
+		wantDeleted map[string]bool
+	}{
+		// the deletion of n1 is simulated once its soft grace period passed, the same as a node tainted for real
+		{"dry mode", NodeGroupOptions{DryMode: true}, map[string]bool{"n1": true}},
+		// nodes are only deleted after they have been tainted for real
+		{"dry taint", NodeGroupOptions{DryTaint: true}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.Name = "default"
+			tt.opts.SoftDeleteGracePeriod = "1m"
+			tt.opts.HardDeleteGracePeriod = "10m"
+			nodeGroup := &NodeGroupState{Opts: tt.opts, NodeInfoMap: k8s.CreateNodeNameToInfoMap(nil, nodes)}
+			nodeGroup.dryTaintNode(nodes[0], now.Add(-5*time.Minute))
+			nodeGroup.dryTaintNode(nodes[1], now)
+			c := &Controller{}
+
+			removed, err := c.TryRemoveTaintedNodes(scaleOpts{nodes: nodes, taintedNodes: nodes, nodeGroup: nodeGroup, ctx: context.Background()})
+			assert.NoError(t, err)
+			assert.Equal(t, 0, removed)
+			assert.Equal(t, tt.wantDeleted, nodeGroup.simulated.deleted)
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package controller
+
+import (
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	// ThirdPartyTaintsCapacity counts the nodes with a third party taint as capacity and candidates for tainting like
+	// any other node
+	ThirdPartyTaintsCapacity = "capacity"
+	// ThirdPartyTaintsExclude leaves the nodes with a third party taint out of the capacity and the candidates for
+	// tainting, like the nodes cordoned by someone else
+	ThirdPartyTaintsExclude = "exclude"
+	// ThirdPartyTaintsPrioritiseRemoval counts the nodes with a third party taint as capacity, but taints them before
+	// the other nodes on scale down
+	ThirdPartyTaintsPrioritiseRemoval = "prioritise_removal"
+)
+
+// policy returns how the nodes with a third party taint are treated
+func (o ThirdPartyTaintsOptions) policy() string {
+	if len(o.Policy) == 0 {
+		return ThirdPartyTaintsCapacity
+	}
+	return o.Policy
+}
+
+// thirdPartyTaint returns the first taint of the node with one of the third party taint keys of the node group
+func (nodeGroup *NodeGroupState) thirdPartyTaint(node *v1.Node) (v1.Taint, bool) {
+	for _, taint := range node.Spec.Taints {
+		for _, key := range nodeGroup.Opts.ThirdPartyTaints.Keys {
+			if taint.Key == key {
+				return taint, true
+			}
+		}
+	}
+	return v1.Taint{}, false
+}
+
+// filterThirdPartyTaintedNodes separates out the nodes with a third party taint. With the exclude policy they are
+// neither capacity nor candidates for tainting, with the other policies they stay in the remaining nodes
+func filterThirdPartyTaintedNodes(nodeGroup *NodeGroupState, nodes []*v1.Node) (remainingNodes, thirdPartyTaintedNodes []*v1.Node) {
+	if len(nodeGroup.Opts.ThirdPartyTaints.Keys) == 0 {
+		return nodes, nil
+	}
+
+	exclude := nodeGroup.Opts.ThirdPartyTaints.policy() == ThirdPartyTaintsExclude
+	remainingNodes = make([]*v1.Node, 0, len(nodes))
+	for _, node := range nodes {
+		if _, ok := nodeGroup.thirdPartyTaint(node); ok {
+			thirdPartyTaintedNodes = append(thirdPartyTaintedNodes, node)
+			if exclude {
+				continue
+			}
+		}
+		remainingNodes = append(remainingNodes, node)
+	}
+	return remainingNodes, thirdPartyTaintedNodes
+}
+
+// thirdPartyTaintPrioritised returns whether the node is tainted before the other nodes of the node group, as it has
+// a third party taint and the node group prioritises their removal
+func (nodeGroup *NodeGroupState) thirdPartyTaintPrioritised(node *v1.Node) bool {
+	if nodeGroup.Opts.ThirdPartyTaints.policy() != ThirdPartyTaintsPrioritiseRemoval {
+		return false
+	}
+	_, ok := nodeGroup.thirdPartyTaint(node)
+	return ok
+}
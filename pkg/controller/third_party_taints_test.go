@@ -0,0 +1,117 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/atlassian/escalator/pkg/k8s"
+	"github.com/atlassian/escalator/pkg/test"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+)
+
+func buildThirdPartyTaintedTestNode(name string, creation time.Time, key string) *v1.Node {
+	node := test.BuildTestNode(test.NodeOpts{Name: name, Creation: creation})
+	node.Spec.Taints = append(node.Spec.Taints, v1.Taint{Key: key, Effect: v1.TaintEffectNoSchedule})
+	return node
+}
+
+func TestFilterThirdPartyTaintedNodes(t *testing.T) {
+	now := time.Now()
+	healthy := test.BuildTestNode(test.NodeOpts{Name: "healthy", Creation: now})
+	unhealthy := buildThirdPartyTaintedTestNode("unhealthy", now, "gpu.example.com/unhealthy")
+	dedicated := buildThirdPartyTaintedTestNode("dedicated", now, "dedicated")
+	nodes := []*v1.Node{healthy, unhealthy, dedicated}
+
+	tests := []struct {
+		name          string
+		opts          ThirdPartyTaintsOptions
+		wantRemaining []*v1.Node
+		wantTainted   []*v1.Node
+	}{
+		{"no keys", ThirdPartyTaintsOptions{}, nodes, nil},
+		{"capacity", ThirdPartyTaintsOptions{Keys: []string{"gpu.example.com/unhealthy"}}, nodes, []*v1.Node{unhealthy}},
+		{"exclude", ThirdPartyTaintsOptions{Keys: []string{"gpu.example.com/unhealthy"}, Policy: ThirdPartyTaintsExclude}, []*v1.Node{healthy, dedicated}, []*v1.Node{unhealthy}},
+		{"prioritise removal", ThirdPartyTaintsOptions{Keys: []string{"gpu.example.com/unhealthy"}, Policy: ThirdPartyTaintsPrioritiseRemoval}, nodes, []*v1.Node{unhealthy}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodeGroup := &NodeGroupState{Opts: NodeGroupOptions{Name: "default", ThirdPartyTaints: tt.opts}}
+			remaining, tainted := filterThirdPartyTaintedNodes(nodeGroup, nodes)
+			assert.Equal(t, tt.wantRemaining, remaining)
+			assert.Equal(t, tt.wantTainted, tainted)
+		})
+	}
+}
+
+func TestTaintOldestN_ThirdPartyTaints(t *testing.T) {
+	now := time.Now()
+	nodes := []*v1.Node{
+		test.BuildTestNode(test.NodeOpts{Name: "n1", Creation: now.Add(-2 * time.Hour)}),
+		test.BuildTestNode(test.NodeOpts{Name: "n2", Creation: now.Add(-time.Hour)}),
+		buildThirdPartyTaintedTestNode("n3", now, "gpu.example.com/unhealthy"),
+	}
+
+	tests := []struct {
+		name   string
+		policy string
+		want   []int
+	}{
+		{"oldest first by default", "", []int{0}},
+		{"oldest first with capacity", ThirdPartyTaintsCapacity, []int{0}},
+		{"third party tainted first", ThirdPartyTaintsPrioritiseRemoval, []int{2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodeGroup := &NodeGroupState{Opts: NodeGroupOptions{
+				Name:             "default",
+				DryMode:          true,
+				ThirdPartyTaints: ThirdPartyTaintsOptions{Keys: []string{"gpu.example.com/unhealthy"}, Policy: tt.policy},
+			}}
+			c := &Controller{}
+			assert.Equal(t, tt.want, c.taintOldestN(context.Background(), nodes, nodeGroup, k8s.NewTaintBudget(1)))
+		})
+	}
+}
+
+func TestValidateNodeGroup_ThirdPartyTaints(t *testing.T) {
+	nodeGroup := NodeGroupOptions{
+		Name:                               "test",
+		LabelKey:                           "customer",
+		LabelValue:                         "buileng",
+		CloudProviderGroupName:             "somegroup",
+		TaintUpperCapacityThresholdPercent: 70,
+		TaintLowerCapacityThresholdPercent: 60,
+		ScaleUpThresholdPercent:            100,
+		MinNodes:                           1,
+		MaxNodes:                           3,
+		SlowNodeRemovalRate:                1,
+		FastNodeRemovalRate:                2,
+		SoftDeleteGracePeriod:              "10m",
+		HardDeleteGracePeriod:              "1h10m",
+		ScaleUpCoolDownPeriod:              "55m",
+	}
+
+	tests := []struct {
+		name string
+		opts ThirdPartyTaintsOptions
+		want int
+	}{
+		{"unset", ThirdPartyTaintsOptions{}, 0},
+		{"keys only", ThirdPartyTaintsOptions{Keys: []string{"gpu.example.com/unhealthy"}}, 0},
+		{"exclude", ThirdPartyTaintsOptions{Keys: []string{"gpu.example.com/unhealthy"}, Policy: ThirdPartyTaintsExclude}, 0},
+		{"prioritise removal", ThirdPartyTaintsOptions{Keys: []string{"gpu.example.com/unhealthy"}, Policy: ThirdPartyTaintsPrioritiseRemoval}, 0},
+		{"capacity without keys", ThirdPartyTaintsOptions{Policy: ThirdPartyTaintsCapacity}, 0},
+		{"exclude without keys", ThirdPartyTaintsOptions{Policy: ThirdPartyTaintsExclude}, 1},
+		{"unknown policy", ThirdPartyTaintsOptions{Keys: []string{"gpu.example.com/unhealthy"}, Policy: "ignore"}, 1},
+		{"empty key", ThirdPartyTaintsOptions{Keys: []string{""}}, 1},
+		{"escalator taint", ThirdPartyTaintsOptions{Keys: []string{"atlassian.com/escalator"}}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodeGroup.ThirdPartyTaints = tt.opts
+			assert.Len(t, ValidateNodeGroup(nodeGroup), tt.want)
+		})
+	}
+}
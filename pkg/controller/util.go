@@ -2,6 +2,8 @@ package controller
 
 import (
 	"math"
+	"math/rand"
+	"time"
 
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
@@ -42,9 +44,54 @@ func calcScaleUpDelta(allNodes []*v1.Node, cpuPercent, memPercent float64, cpuRe
 	if delta < 0 {
 		return delta, errors.New("negative scale up delta")
 	}
+
+	// Scaling up from 0 is handled above, steps only apply to the utilisation of existing nodes
+	if cpuPercent != math.MaxFloat64 && memPercent != math.MaxFloat64 {
+		stepDelta := calcScaleUpStepDelta(len(allNodes), math.Max(cpuPercent, memPercent), nodeGroup.Opts.ScaleUpSteps)
+		if stepDelta > delta {
+			log.WithField("nodegroup", nodeGroup.Opts.Name).Debugf("scale up step increased delta from %v to %v", delta, stepDelta)
+			delta = stepDelta
+		}
+	}
 	return delta, nil
 }
 
+// calcScaleUpStepDelta returns the delta of the highest scale up step reached by the utilisation percent.
+// returns 0 if no step has been reached
+func calcScaleUpStepDelta(nodeCount int, percent float64, steps []ScaleUpStep) int {
+	var reached *ScaleUpStep
+	for i, step := range steps {
+		if percent >= step.UtilisationPercent && (reached == nil || step.UtilisationPercent > reached.UtilisationPercent) {
+			reached = &steps[i]
+		}
+	}
+	if reached == nil {
+		return 0
+	}
+
+	if reached.Count > 0 {
+		return reached.Count
+	}
+	return int(math.Ceil(float64(nodeCount) * float64(reached.Percent) / 100))
+}
+
+// calcScaleDownDelta determines the amount of nodes to taint for the removal rate.
+// scale_down_count is the minimum taken by any scale down, the same as scale_up_count is for a scale up, so it only
+// changes the delta when it is larger than the rate. The delta is clamped to the untainted nodes, as no more can be tainted
+func calcScaleDownDelta(removalRate int, scaleDownCount int, untaintedNodes int) int {
+	delta := removalRate
+	if delta < scaleDownCount {
+		delta = scaleDownCount
+	}
+	if delta > untaintedNodes {
+		delta = untaintedNodes
+	}
+	if delta < 0 {
+		delta = 0
+	}
+	return delta
+}
+
 func allEqual(matchValue int64, resourceValues ...int64) bool {
 	for _, v := range resourceValues {
 		if v != matchValue {
@@ -79,3 +126,37 @@ func calcPercentUsage(cpuRequest, memRequest, cpuCapacity, memCapacity resource.
 	memPercent := float64(memRequest.MilliValue()) / float64(memCapacity.MilliValue()) * 100
 	return cpuPercent, memPercent, nil
 }
+
+// calcNextScanDelay determines how long to wait before the next run.
+// When align is set the run is aligned to the next multiple of the interval on the wall clock, e.g. on the minute.
+// A random jitter of up to jitter is added so that many instances don't call the cloud provider at the same time.
+// When neither is set the runs keep a fixed rate, the same as a ticker: the next run is an interval after the previous
+// scheduled run rather than after the previous run finished, skipping the runs missed while a run took too long
+func calcNextScanDelay(now, previous time.Time, interval, jitter time.Duration, align bool) time.Duration {
+	if !align && jitter <= 0 && !previous.IsZero() && interval > 0 {
+		next := previous.Add(interval)
+		for !next.After(now) {
+			next = next.Add(interval)
+		}
+		return next.Sub(now)
+	}
+
+	delay := interval
+	if align && interval > 0 {
+		delay = now.Truncate(interval).Add(interval).Sub(now)
+	}
+	if jitter > 0 {
+		delay += time.Duration(rand.Int63n(int64(jitter)))
+	}
+	return delay
+}
+
+// containsString returns whether the value is in the values
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -2,6 +2,7 @@ package controller
 
 import (
 	"testing"
+	"time"
 
 	"github.com/atlassian/escalator/pkg/k8s"
 	"github.com/atlassian/escalator/pkg/test"
@@ -191,6 +192,58 @@ func TestCalcScaleUpDeltaBelowThreshold(t *testing.T) {
 
 }
 
+func TestCalcScaleUpStepDelta(t *testing.T) {
+	steps := []ScaleUpStep{
+		{UtilisationPercent: 90, Percent: 20},
+		{UtilisationPercent: 99, Percent: 50},
+		{UtilisationPercent: 150, Count: 3},
+	}
+
+	tests := []struct {
+		name      string
+		nodeCount int
+		percent   float64
+		steps     []ScaleUpStep
+		want      int
+	}{
+		{"no steps", 10, 95, nil, 0},
+		{"below all steps", 10, 80, steps, 0},
+		{"first step", 10, 90, steps, 2},
+		{"second step", 10, 99.5, steps, 5},
+		{"rounds up", 3, 91, steps, 1},
+		{"absolute count", 10, 200, steps, 3},
+		{"unordered steps", 10, 99.5, []ScaleUpStep{steps[1], steps[0]}, 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, calcScaleUpStepDelta(tt.nodeCount, tt.percent, tt.steps))
+		})
+	}
+}
+
+func TestCalcScaleDownDelta(t *testing.T) {
+	tests := []struct {
+		name           string
+		removalRate    int
+		scaleDownCount int
+		untaintedNodes int
+		want           int
+	}{
+		{"rate", 5, 0, 10, 5},
+		{"count below rate", 5, 1, 10, 5},
+		{"count above rate", 2, 4, 10, 4},
+		{"small group clamps rate", 5, 0, 3, 3},
+		{"small group clamps count", 1, 4, 2, 2},
+		{"no untainted nodes", 1, 4, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, calcScaleDownDelta(tt.removalRate, tt.scaleDownCount, tt.untaintedNodes))
+		})
+	}
+}
+
 // Helper function for calculating percentage usage
 func calculatePercentageUsage(pods []*v1.Pod, nodes []*v1.Node) (float64, float64, error) {
 	// Calculate requests and capacity
@@ -295,3 +348,28 @@ func TestCalcPercentUsage(t *testing.T) {
 		})
 	}
 }
+
+func TestCalcNextScanDelay(t *testing.T) {
+	now := time.Date(2019, 3, 1, 10, 0, 20, 0, time.UTC)
+
+	assert.Equal(t, time.Minute, calcNextScanDelay(now, time.Time{}, time.Minute, 0, false))
+	assert.Equal(t, 40*time.Second, calcNextScanDelay(now, time.Time{}, time.Minute, 0, true))
+	assert.Equal(t, 4*time.Minute+40*time.Second, calcNextScanDelay(now, time.Time{}, 5*time.Minute, 0, true))
+	assert.Equal(t, time.Minute, calcNextScanDelay(now.Add(40*time.Second), time.Time{}, time.Minute, 0, true))
+
+	// without jitter or alignment the runs keep the fixed rate of the previous scheduled run, even when a run is slow
+	previous := now.Add(-15 * time.Second)
+	assert.Equal(t, 45*time.Second, calcNextScanDelay(now, previous, time.Minute, 0, false))
+	// runs missed by a run that took longer than the interval are skipped
+	assert.Equal(t, 15*time.Second, calcNextScanDelay(now, now.Add(-105*time.Second), time.Minute, 0, false))
+	// the alignment doesn't depend on the previous run
+	assert.Equal(t, 40*time.Second, calcNextScanDelay(now, previous, time.Minute, 0, true))
+
+	for i := 0; i < 100; i++ {
+		delay := calcNextScanDelay(now, previous, time.Minute, 10*time.Second, false)
+		assert.True(t, delay >= time.Minute && delay < time.Minute+10*time.Second, "delay %v out of range", delay)
+
+		delay = calcNextScanDelay(now, previous, time.Minute, 10*time.Second, true)
+		assert.True(t, delay >= 40*time.Second && delay < 50*time.Second, "delay %v out of range", delay)
+	}
+}
@@ -0,0 +1,106 @@
+package controller
+
+import (
+	"sort"
+
+	"github.com/atlassian/escalator/pkg/metrics"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/version"
+)
+
+// defaultVersionSkewMaxNodesPerRun is the number of outdated nodes prioritised for tainting in a run when
+// version_skew.max_nodes_per_run isn't set
+const defaultVersionSkewMaxNodesPerRun = 1
+
+// enabled returns whether outdated nodes are prioritised for tainting
+func (o VersionSkewOptions) enabled() bool {
+	return o.Kubelet || o.OSImage
+}
+
+// maxNodesPerRun returns the most outdated nodes prioritised for tainting in a run
+func (o VersionSkewOptions) maxNodesPerRun() int {
+	if o.MaxNodesPerRun > 0 {
+		return o.MaxNodesPerRun
+	}
+	return defaultVersionSkewMaxNodesPerRun
+}
+
+// nodeVersions is the newest kubelet version and the OS image of the newest node of a node group, which the other
+// nodes are compared with
+type nodeVersions struct {
+	kubelet *version.Version
+	osImage string
+}
+
+// newestNodeVersions returns the newest kubelet version of the nodes and the OS image of the most recently created
+// node. Nodes that haven't reported their versions yet are skipped
+func newestNodeVersions(nodes []*v1.Node) nodeVersions {
+	var newest nodeVersions
+	var newestNode *v1.Node
+	for _, node := range nodes {
+		if kubelet, err := version.ParseGeneric(node.Status.NodeInfo.KubeletVersion); err == nil {
+			if newest.kubelet == nil || newest.kubelet.LessThan(kubelet) {
+				newest.kubelet = kubelet
+			}
+		}
+		if len(node.Status.NodeInfo.OSImage) > 0 && (newestNode == nil || newestNode.CreationTimestamp.Before(&node.CreationTimestamp)) {
+			newestNode = node
+		}
+	}
+	if newestNode != nil {
+		newest.osImage = newestNode.Status.NodeInfo.OSImage
+	}
+	return newest
+}
+
+// outdated returns whether the node runs an older kubelet, or another OS image, than the newest node versions
+func (o VersionSkewOptions) outdated(node *v1.Node, newest nodeVersions) bool {
+	if o.Kubelet && newest.kubelet != nil {
+		if kubelet, err := version.ParseGeneric(node.Status.NodeInfo.KubeletVersion); err == nil && kubelet.LessThan(newest.kubelet) {
+			return true
+		}
+	}
+	if o.OSImage && len(newest.osImage) > 0 && len(node.Status.NodeInfo.OSImage) > 0 {
+		return node.Status.NodeInfo.OSImage != newest.osImage
+	}
+	return false
+}
+
+// outdatedNodes returns the candidates that are outdated compared to the newest of all of the nodes, oldest first
+func outdatedNodes(opts VersionSkewOptions, allNodes []*v1.Node, candidates []*v1.Node) []*v1.Node {
+	newest := newestNodeVersions(allNodes)
+	var outdated []*v1.Node
+	for _, node := range candidates {
+		if opts.outdated(node, newest) {
+			outdated = append(outdated, node)
+		}
+	}
+	sort.SliceStable(outdated, func(i, j int) bool {
+		return outdated[i].CreationTimestamp.Before(&outdated[j].CreationTimestamp)
+	})
+	return outdated
+}
+
+// updateOutdatedNodes finds the untainted nodes on an outdated kubelet or OS image, and picks the oldest of them, up
+// to version_skew.max_nodes_per_run, to be tainted first on the next scale down of the run
+func updateOutdatedNodes(nodeGroup *NodeGroupState, allNodes []*v1.Node, untaintedNodes []*v1.Node) {
+	nodeGroup.outdatedNodes = nil
+	if !nodeGroup.Opts.VersionSkew.enabled() {
+		return
+	}
+
+	outdated := outdatedNodes(nodeGroup.Opts.VersionSkew, allNodes, untaintedNodes)
+	metrics.NodeGroupNodesOutdated.WithLabelValues(nodeGroup.Opts.Name).Set(float64(len(outdated)))
+	if len(outdated) == 0 {
+		return
+	}
+	if len(outdated) > nodeGroup.Opts.VersionSkew.maxNodesPerRun() {
+		outdated = outdated[:nodeGroup.Opts.VersionSkew.maxNodesPerRun()]
+	}
+	nodeGroup.outdatedNodes = make(map[string]bool, len(outdated))
+	for _, node := range outdated {
+		nodeGroup.outdatedNodes[node.Name] = true
+	}
+	log.WithField("nodegroup", nodeGroup.Opts.Name).Infof("outdated nodes prioritised for tainting: %v", len(outdated))
+}
@@ -0,0 +1,91 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/atlassian/escalator/pkg/k8s"
+	"github.com/atlassian/escalator/pkg/test"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+)
+
+func buildVersionSkewTestNode(name string, creation time.Time, kubelet string, osImage string) *v1.Node {
+	node := test.BuildTestNode(test.NodeOpts{Name: name, Creation: creation})
+	node.Status.NodeInfo.KubeletVersion = kubelet
+	node.Status.NodeInfo.OSImage = osImage
+	return node
+}
+
+func TestOutdatedNodes(t *testing.T) {
+	now := time.Now()
+	oldKubelet := buildVersionSkewTestNode("old-kubelet", now.Add(-3*time.Hour), "v1.12.5-eks-6a2b1c", "Amazon Linux 2")
+	oldImage := buildVersionSkewTestNode("old-image", now.Add(-2*time.Hour), "v1.13.2-eks-6a2b1c", "Amazon Linux 1")
+	current := buildVersionSkewTestNode("current", now.Add(-time.Hour), "v1.13.2-eks-6a2b1c", "Amazon Linux 2")
+	unknown := buildVersionSkewTestNode("unknown", now, "", "")
+	nodes := []*v1.Node{current, unknown, oldImage, oldKubelet}
+
+	tests := []struct {
+		name string
+		opts VersionSkewOptions
+		want []*v1.Node
+	}{
+		{"disabled", VersionSkewOptions{}, nil},
+		{"kubelet", VersionSkewOptions{Kubelet: true}, []*v1.Node{oldKubelet}},
+		{"os image", VersionSkewOptions{OSImage: true}, []*v1.Node{oldImage}},
+		{"both, oldest first", VersionSkewOptions{Kubelet: true, OSImage: true}, []*v1.Node{oldKubelet, oldImage}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, outdatedNodes(tt.opts, nodes, nodes))
+		})
+	}
+}
+
+func TestUpdateOutdatedNodes(t *testing.T) {
+	now := time.Now()
+	nodes := []*v1.Node{
+		buildVersionSkewTestNode("n1", now.Add(-3*time.Hour), "v1.12.5", "Ubuntu 18.04"),
+		buildVersionSkewTestNode("n2", now.Add(-2*time.Hour), "v1.12.5", "Ubuntu 18.04"),
+		buildVersionSkewTestNode("n3", now.Add(-time.Hour), "v1.13.2", "Ubuntu 18.04"),
+	}
+	nodeGroup := &NodeGroupState{Opts: NodeGroupOptions{Name: "default", VersionSkew: VersionSkewOptions{Kubelet: true}}}
+
+	// only the oldest outdated node is prioritised by default
+	updateOutdatedNodes(nodeGroup, nodes, nodes)
+	assert.Equal(t, map[string]bool{"n1": true}, nodeGroup.outdatedNodes)
+	assert.True(t, nodeGroup.prioritisedForTainting(nodes[0]))
+	assert.False(t, nodeGroup.prioritisedForTainting(nodes[1]))
+
+	nodeGroup.Opts.VersionSkew.MaxNodesPerRun = 5
+	updateOutdatedNodes(nodeGroup, nodes, nodes)
+	assert.Equal(t, map[string]bool{"n1": true, "n2": true}, nodeGroup.outdatedNodes)
+
+	// tainted nodes are never prioritised again
+	updateOutdatedNodes(nodeGroup, nodes, nodes[1:])
+	assert.Equal(t, map[string]bool{"n2": true}, nodeGroup.outdatedNodes)
+
+	nodeGroup.Opts.VersionSkew = VersionSkewOptions{}
+	updateOutdatedNodes(nodeGroup, nodes, nodes)
+	assert.Empty(t, nodeGroup.outdatedNodes)
+}
+
+func TestTaintOldestN_VersionSkew(t *testing.T) {
+	now := time.Now()
+	nodes := []*v1.Node{
+		buildVersionSkewTestNode("n1", now.Add(-3*time.Hour), "v1.13.2", "Ubuntu 18.04"),
+		buildVersionSkewTestNode("n2", now.Add(-2*time.Hour), "v1.12.5", "Ubuntu 18.04"),
+		buildVersionSkewTestNode("n3", now.Add(-time.Hour), "v1.13.2", "Ubuntu 18.04"),
+	}
+	nodeGroup := &NodeGroupState{Opts: NodeGroupOptions{Name: "default", DryMode: true}}
+	c := &Controller{}
+
+	// the oldest node is tainted first without version_skew
+	updateOutdatedNodes(nodeGroup, nodes, nodes)
+	assert.Equal(t, []int{0}, c.taintOldestN(context.Background(), nodes, nodeGroup, k8s.NewTaintBudget(1)))
+
+	nodeGroup = &NodeGroupState{Opts: NodeGroupOptions{Name: "default", DryMode: true, VersionSkew: VersionSkewOptions{Kubelet: true}}}
+	updateOutdatedNodes(nodeGroup, nodes, nodes)
+	assert.Equal(t, []int{1}, c.taintOldestN(context.Background(), nodes, nodeGroup, k8s.NewTaintBudget(1)))
+}
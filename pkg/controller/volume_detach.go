@@ -0,0 +1,57 @@
+package controller
+
+import (
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// attachedVolumes returns the number of volumes the node reports attached to it
+func attachedVolumes(node *v1.Node) int {
+	return len(node.Status.VolumesAttached)
+}
+
+// volumeDetachDelay returns how much the delete grace periods of the tainted node are extended by for the volumes
+// attached to it, capped at volume_detach.max_delay. The delay shrinks as the volumes of the pods that left the node
+// detach
+func volumeDetachDelay(nodeGroup *NodeGroupState, node *v1.Node) time.Duration {
+	delay := time.Duration(attachedVolumes(node)) * nodeGroup.Opts.VolumeDelayPerVolumeDuration()
+	if maxDelay := nodeGroup.Opts.VolumeMaxDelayDuration(); maxDelay > 0 && delay > maxDelay {
+		return maxDelay
+	}
+	return delay
+}
+
+// hardDeleteGracePeriod returns the hard delete grace period of the tainted node, extended by its volume_detach delay
+func hardDeleteGracePeriod(nodeGroup *NodeGroupState, node *v1.Node) time.Duration {
+	return nodeGroup.Opts.HardDeleteGracePeriodDuration() + volumeDetachDelay(nodeGroup, node)
+}
+
+// volumeHeavy returns whether the node has at least volume_detach.heavy_node_volumes volumes attached
+func volumeHeavy(nodeGroup *NodeGroupState, node *v1.Node) bool {
+	heavy := nodeGroup.Opts.VolumeDetach.HeavyNodeVolumes
+	return heavy > 0 && attachedVolumes(node) >= heavy
+}
+
+// volumeDetachAllows returns whether the tainted node can be deleted within the volume_detach heavy node interval.
+// Alerts through the volume_detach guardrail if not
+func (c *Controller) volumeDetachAllows(nodeGroup *NodeGroupState, node *v1.Node, now time.Time) bool {
+	if !volumeHeavy(nodeGroup, node) {
+		return true
+	}
+	interval := nodeGroup.Opts.VolumeHeavyNodeIntervalDuration()
+	since := now.Sub(nodeGroup.lastVolumeHeavyDeletion)
+	if !nodeGroup.lastVolumeHeavyDeletion.IsZero() && since < interval {
+		c.recordGuardrail(nodeGroup, GuardrailVolumeDetach, "not deleting node %v yet, it has %v volumes attached and %v remains of the interval since the last volume heavy node deleted", node.Name, attachedVolumes(node), interval-since)
+		return false
+	}
+	return true
+}
+
+// recordVolumeHeavyDeletion starts the heavy node interval of the node group from the deletion of the node, if it is
+// volume heavy
+func recordVolumeHeavyDeletion(nodeGroup *NodeGroupState, node *v1.Node, now time.Time) {
+	if volumeHeavy(nodeGroup, node) {
+		nodeGroup.lastVolumeHeavyDeletion = now
+	}
+}
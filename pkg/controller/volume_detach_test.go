@@ -0,0 +1,131 @@
+package controller
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/atlassian/escalator/pkg/k8s"
+	"github.com/atlassian/escalator/pkg/test"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+// buildVolumeNode returns a tainted node with the number of volumes attached
+func buildVolumeNode(name string, volumes int) *v1.Node {
+	node := test.BuildTestNode(test.NodeOpts{Name: name, Tainted: true})
+	for i := 0; i < volumes; i++ {
+		node.Status.VolumesAttached = append(node.Status.VolumesAttached, v1.AttachedVolume{
+			Name:       v1.UniqueVolumeName(fmt.Sprintf("kubernetes.io/aws-ebs/vol-%v-%v", name, i)),
+			DevicePath: fmt.Sprintf("/dev/xvd%c", 'b'+i),
+		})
+	}
+	return node
+}
+
+func TestVolumeDetachDelay(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    VolumeDetachOptions
+		volumes int
+		want    time.Duration
+	}{
+		{"disabled", VolumeDetachOptions{}, 5, 0},
+		{"no volumes", VolumeDetachOptions{DelayPerVolume: "1m"}, 0, 0},
+		{"per volume", VolumeDetachOptions{DelayPerVolume: "1m"}, 5, 5 * time.Minute},
+		{"below the max", VolumeDetachOptions{DelayPerVolume: "1m", MaxDelay: "10m"}, 5, 5 * time.Minute},
+		{"capped at the max", VolumeDetachOptions{DelayPerVolume: "1m", MaxDelay: "3m"}, 5, 3 * time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodeGroup := &NodeGroupState{Opts: NodeGroupOptions{HardDeleteGracePeriod: "1h", VolumeDetach: tt.opts}}
+			node := buildVolumeNode("n1", tt.volumes)
+			assert.Equal(t, tt.want, volumeDetachDelay(nodeGroup, node))
+			assert.Equal(t, time.Hour+tt.want, hardDeleteGracePeriod(nodeGroup, node))
+		})
+	}
+}
+
+func TestSoftDeleteGracePeriodPassed_VolumeDetach(t *testing.T) {
+	node := buildVolumeNode("n1", 4)
+	nodeGroup := &NodeGroupState{
+		Opts: NodeGroupOptions{
+			SoftDeleteGracePeriod: "10m",
+			HardDeleteGracePeriod: "1h",
+			VolumeDetach:          VolumeDetachOptions{DelayPerVolume: "5m"},
+		},
+		NodeInfoMap: k8s.CreateNodeNameToInfoMap(nil, []*v1.Node{node}),
+	}
+	now := time.Now()
+
+	// the 10m soft delete grace period is extended by 20m for the 4 volumes
+	passed, remaining := softDeleteGracePeriodPassed(nodeGroup, node, now.Add(-15*time.Minute), now)
+	assert.False(t, passed)
+	assert.Equal(t, 15*time.Minute, remaining)
+	passed, _ = softDeleteGracePeriodPassed(nodeGroup, node, now.Add(-31*time.Minute), now)
+	assert.True(t, passed)
+
+	// the delay shrinks as the volumes detach
+	node.Status.VolumesAttached = nil
+	passed, _ = softDeleteGracePeriodPassed(nodeGroup, node, now.Add(-15*time.Minute), now)
+	assert.True(t, passed)
+}
+
+func TestVolumeDetachAllows(t *testing.T) {
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	opts := VolumeDetachOptions{HeavyNodeVolumes: 3, HeavyNodeInterval: "10m"}
+
+	tests := []struct {
+		name       string
+		opts       VolumeDetachOptions
+		volumes    int
+		lastDelete time.Time
+		want       bool
+		wantEvent  string
+	}{
+		{"disabled", VolumeDetachOptions{}, 10, now, true, ""},
+		{"not volume heavy", opts, 2, now, true, ""},
+		{"no volume heavy node deleted yet", opts, 3, time.Time{}, true, ""},
+		{"interval passed", opts, 3, now.Add(-11 * time.Minute), true, ""},
+		{
+			"within the interval",
+			opts,
+			5,
+			now.Add(-4 * time.Minute),
+			false,
+			"Normal VolumeDetachSpaced node group default: not deleting node n1 yet, it has 5 volumes attached and 6m0s remains of the interval since the last volume heavy node deleted",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodeGroup := &NodeGroupState{
+				Opts:                    NodeGroupOptions{Name: "default", VolumeDetach: tt.opts},
+				lastVolumeHeavyDeletion: tt.lastDelete,
+			}
+			recorder := record.NewFakeRecorder(1)
+			c := &Controller{Opts: Opts{
+				EventRecorder: recorder,
+				EventObject:   &v1.ObjectReference{Kind: "Pod", Namespace: "kube-system", Name: "escalator"},
+			}}
+
+			assert.Equal(t, tt.want, c.volumeDetachAllows(nodeGroup, buildVolumeNode("n1", tt.volumes), now))
+			events := drainEvents(recorder)
+			if len(tt.wantEvent) > 0 {
+				assert.Equal(t, []string{tt.wantEvent}, events)
+			} else {
+				assert.Empty(t, events)
+			}
+		})
+	}
+}
+
+func TestRecordVolumeHeavyDeletion(t *testing.T) {
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	nodeGroup := &NodeGroupState{Opts: NodeGroupOptions{VolumeDetach: VolumeDetachOptions{HeavyNodeVolumes: 3, HeavyNodeInterval: "10m"}}}
+
+	recordVolumeHeavyDeletion(nodeGroup, buildVolumeNode("light", 2), now)
+	assert.True(t, nodeGroup.lastVolumeHeavyDeletion.IsZero())
+	recordVolumeHeavyDeletion(nodeGroup, buildVolumeNode("heavy", 3), now)
+	assert.Equal(t, now, nodeGroup.lastVolumeHeavyDeletion)
+}
@@ -0,0 +1,162 @@
+// Package errorkind categorises the errors returned by escalator, the kubernetes api and the cloud providers, so callers
+// can branch on the kind of an error instead of its message
+package errorkind
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// Kind is the category of an error
+type Kind string
+
+const (
+	// Unknown is an error that doesn't fall in any of the other kinds
+	Unknown Kind = "unknown"
+	// Throttled is a request rejected by the kubernetes api or the cloud provider because of rate limiting
+	Throttled Kind = "throttled"
+	// NotFound is a request for a node, node group or instance that doesn't exist
+	NotFound Kind = "not_found"
+	// Conflict is an update that lost a race with another update of the same object
+	Conflict Kind = "conflict"
+	// Validation is invalid config or an invalid request
+	Validation Kind = "validation"
+	// Limit is an action refused because it would breach a limit, e.g. the size of a node group
+	Limit Kind = "limit"
+	// QuotaBlocked is a scale up refused because it would exceed a quota of the cloud provider account
+	QuotaBlocked Kind = "quota_blocked"
+	// Cancelled is a request or action cancelled because the run reached its deadline or escalator is stopping
+	Cancelled Kind = "cancelled"
+)
+
+// Kinds is all of the kinds, for initialising metrics
+var Kinds = []Kind{Unknown, Throttled, NotFound, Conflict, Validation, Limit, QuotaBlocked, Cancelled}
+
+// throttledCodes are the error codes the cloud providers use for rate limited requests
+var throttledCodes = map[string]bool{
+	"Throttling":                true,
+	"ThrottlingException":       true,
+	"RequestLimitExceeded":      true,
+	"RequestThrottled":          true,
+	"RequestThrottledException": true,
+	"TooManyRequestsException":  true,
+}
+
+// quotaCodes are the error codes the cloud providers use for requests that exceed a quota of the account
+var quotaCodes = map[string]bool{
+	"VcpuLimitExceeded":            true,
+	"InstanceLimitExceeded":        true,
+	"MaxSpotInstanceCountExceeded": true,
+}
+
+// validationCodes are the error codes the cloud providers use for invalid requests
+var validationCodes = map[string]bool{
+	"ValidationError":       true,
+	"InvalidParameterValue": true,
+	"InvalidParameter":      true,
+}
+
+// Error is an error of a known kind
+type Error struct {
+	Kind Kind
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+// Cause returns the underlying error, for errors.Cause
+func (e *Error) Cause() error {
+	return e.Err
+}
+
+// Kinder is implemented by error types that know their kind
+type Kinder interface {
+	ErrorKind() Kind
+}
+
+// New returns an error of the kind with the formatted message
+func New(kind Kind, format string, args ...interface{}) error {
+	return &Error{Kind: kind, Err: fmt.Errorf(format, args...)}
+}
+
+// Wrap returns the error annotated with the message as an error of the kind. Returns nil if err is nil
+func Wrap(kind Kind, err error, message string) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Kind: kind, Err: errors.Wrap(err, message)}
+}
+
+// Of returns the kind of the error. The error and each of its causes are checked in turn, so wrapped kubernetes api
+// and cloud provider errors keep their kind
+func Of(err error) Kind {
+	for err != nil {
+		if kind := kindOf(err); kind != Unknown {
+			return kind
+		}
+		causer, ok := err.(interface{ Cause() error })
+		if !ok {
+			break
+		}
+		cause := causer.Cause()
+		if cause == err {
+			break
+		}
+		err = cause
+	}
+	return Unknown
+}
+
+// Is returns whether the error is of the kind
+func Is(err error, kind Kind) bool {
+	return err != nil && Of(err) == kind
+}
+
+// kindOf returns the kind of the error without checking its causes
+func kindOf(err error) Kind {
+	if err == context.DeadlineExceeded || err == context.Canceled {
+		return Cancelled
+	}
+
+	switch e := err.(type) {
+	case *Error:
+		return e.Kind
+	case Kinder:
+		return e.ErrorKind()
+	}
+
+	switch {
+	case apiErrors.IsTooManyRequests(err), apiErrors.IsServerTimeout(err):
+		return Throttled
+	case apiErrors.IsNotFound(err):
+		return NotFound
+	case apiErrors.IsConflict(err), apiErrors.IsAlreadyExists(err):
+		return Conflict
+	case apiErrors.IsInvalid(err), apiErrors.IsBadRequest(err):
+		return Validation
+	}
+
+	// cloud provider sdk errors, e.g. awserr.Error, carry a code
+	if coder, ok := err.(interface{ Code() string }); ok {
+		code := coder.Code()
+		switch {
+		case throttledCodes[code]:
+			return Throttled
+		case quotaCodes[code]:
+			return QuotaBlocked
+		case code == "RequestCanceled":
+			return Cancelled
+		case strings.HasSuffix(code, ".NotFound"):
+			return NotFound
+		case validationCodes[code]:
+			return Validation
+		}
+	}
+	return Unknown
+}
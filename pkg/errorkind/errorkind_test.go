@@ -0,0 +1,65 @@
+package errorkind
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	pkgerrors "github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+type testKinder struct{}
+
+func (testKinder) Error() string {
+	return "test"
+}
+
+func (testKinder) ErrorKind() Kind {
+	return Limit
+}
+
+func TestOf(t *testing.T) {
+	nodes := schema.GroupResource{Resource: "nodes"}
+	tests := []struct {
+		name string
+		err  error
+		want Kind
+	}{
+		{"nil", nil, Unknown},
+		{"plain", errors.New("plain"), Unknown},
+		{"new", New(Validation, "bad %v", "option"), Validation},
+		{"kinder", testKinder{}, Limit},
+		{"api not found", apiErrors.NewNotFound(nodes, "n1"), NotFound},
+		{"api conflict", apiErrors.NewConflict(nodes, "n1", errors.New("changed")), Conflict},
+		{"api too many requests", apiErrors.NewTooManyRequests("slow down", 1), Throttled},
+		{"api invalid", apiErrors.NewBadRequest("bad"), Validation},
+		{"wrapped api error", pkgerrors.Wrap(apiErrors.NewNotFound(nodes, "n1"), "failed to get node"), NotFound},
+		{"wrapped kind", pkgerrors.Wrap(New(Conflict, "conflict"), "outer"), Conflict},
+		{"kind wrapping api error", Wrap(Limit, apiErrors.NewNotFound(nodes, "n1"), "outer"), Limit},
+		{"aws throttling", awserr.New("Throttling", "Rate exceeded", nil), Throttled},
+		{"aws not found", awserr.New("InvalidInstanceID.NotFound", "missing", nil), NotFound},
+		{"aws validation", pkgerrors.Wrap(awserr.New("ValidationError", "bad", nil), "outer"), Validation},
+		{"deadline", pkgerrors.Wrap(context.DeadlineExceeded, "run cancelled"), Cancelled},
+		{"aws cancelled", awserr.New("RequestCanceled", "request context canceled", context.Canceled), Cancelled},
+		{"aws vcpu limit", awserr.New("VcpuLimitExceeded", "You have requested more vCPU capacity than your current vCPU limit", nil), QuotaBlocked},
+		{"aws other", awserr.New("InternalFailure", "oops", nil), Unknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Of(tt.err))
+		})
+	}
+}
+
+func TestIs(t *testing.T) {
+	err := Wrap(Throttled, errors.New("rate exceeded"), "failed to describe node groups")
+	assert.True(t, Is(err, Throttled))
+	assert.False(t, Is(err, NotFound))
+	assert.False(t, Is(nil, Unknown))
+	assert.Equal(t, "failed to describe node groups: rate exceeded", err.Error())
+	assert.Nil(t, Wrap(Throttled, nil, "nothing"))
+}
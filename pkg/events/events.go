@@ -0,0 +1,39 @@
+// Package events defines the payloads of the webhooks escalator calls, for integrations to decode the requests and
+// answer them without depending on the internal types of the controller.
+//
+// The payloads are versioned by Version. Within a version fields are only ever added, never renamed, retyped or
+// removed, so a consumer built against an older release keeps decoding the payloads of newer ones. A breaking change
+// is a new version. The JSON schemas of the payloads are in schema.go and the payloads of each version are pinned by
+// the tests of this package.
+package events
+
+// Version is the version of the webhook payloads sent by this release of escalator
+const Version = "v1"
+
+// ContentType is the content type of the webhook requests and responses
+const ContentType = "application/json"
+
+// PreDeleteHookRequest is the body POSTed to the pre delete hook of a node group for a node that is about to be
+// deleted
+type PreDeleteHookRequest struct {
+	// Version is the version of the payload. Empty from releases before the payloads were versioned, which sent v1
+	Version   string `json:"version,omitempty"`
+	NodeGroup string `json:"node_group"`
+	// Node is the name of the node
+	Node       string `json:"node"`
+	ProviderID string `json:"provider_id"`
+	// PodsRemaining are the pods still on the node excluding DaemonSet pods, as namespace/name
+	PodsRemaining []string `json:"pods_remaining"`
+	// CycleID is the id of the run deleting the node, as listed by the /cycles endpoint
+	CycleID string `json:"cycle_id,omitempty"`
+}
+
+// PreDeleteHookResponse is the answer of the pre delete hook. When Allow is false the node isn't deleted, and the hook
+// isn't called again for the node until RetryAfter has passed
+type PreDeleteHookResponse struct {
+	Allow bool `json:"allow"`
+	// Reason is logged when the deletion is vetoed or delayed
+	Reason string `json:"reason,omitempty"`
+	// RetryAfter is a duration such as 5m. Without it the hook is called again on the next run
+	RetryAfter string `json:"retry_after,omitempty"`
+}
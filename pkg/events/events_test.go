@@ -0,0 +1,118 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// jsonFields returns the json names of the fields of the struct type
+func jsonFields(t reflect.Type) []string {
+	var fields []string
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if len(name) > 0 && name != "-" {
+			fields = append(fields, name)
+		}
+	}
+	return fields
+}
+
+// TestPayloadCompatibility decodes the pinned payloads of each version and checks they encode back the same, so a
+// field renamed, retyped or removed breaks the test
+func TestPayloadCompatibility(t *testing.T) {
+	payloads := map[string]func() interface{}{
+		"pre_delete_hook_request.json":  func() interface{} { return &PreDeleteHookRequest{} },
+		"pre_delete_hook_response.json": func() interface{} { return &PreDeleteHookResponse{} },
+	}
+	for _, version := range []string{"v1"} {
+		for file, payload := range payloads {
+			t.Run(version+"/"+file, func(t *testing.T) {
+				pinned, err := ioutil.ReadFile(filepath.Join("testdata", version, file))
+				require.NoError(t, err)
+
+				decoder := json.NewDecoder(strings.NewReader(string(pinned)))
+				decoder.DisallowUnknownFields()
+				value := payload()
+				require.NoError(t, decoder.Decode(value))
+				encoded, err := json.Marshal(value)
+				require.NoError(t, err)
+				assert.JSONEq(t, string(pinned), string(encoded))
+			})
+		}
+	}
+}
+
+func TestSchemasMatchTypes(t *testing.T) {
+	schemas := map[string]interface{}{
+		PreDeleteHookRequestSchema:  PreDeleteHookRequest{},
+		PreDeleteHookResponseSchema: PreDeleteHookResponse{},
+	}
+	for schema, value := range schemas {
+		name := reflect.TypeOf(value).Name()
+		t.Run(name, func(t *testing.T) {
+			var parsed struct {
+				Title      string                 `json:"title"`
+				Required   []string               `json:"required"`
+				Properties map[string]interface{} `json:"properties"`
+			}
+			require.NoError(t, json.Unmarshal([]byte(schema), &parsed))
+			assert.Equal(t, name, parsed.Title)
+			fields := jsonFields(reflect.TypeOf(value))
+			assert.Len(t, parsed.Properties, len(fields))
+			for _, field := range fields {
+				assert.Contains(t, parsed.Properties, field)
+			}
+			for _, field := range parsed.Required {
+				assert.Contains(t, fields, field)
+			}
+		})
+	}
+}
+
+func TestPreDeleteHookFunc(t *testing.T) {
+	var received PreDeleteHookRequest
+	handler := PreDeleteHookFunc(func(ctx context.Context, request PreDeleteHookRequest) PreDeleteHookResponse {
+		received = request
+		return PreDeleteHookResponse{Reason: "seats in use", RetryAfter: "5m"}
+	})
+
+	tests := []struct {
+		name       string
+		method     string
+		body       string
+		wantStatus int
+	}{
+		{"v1", http.MethodPost, `{"version": "v1", "node_group": "shared", "node": "n1"}`, http.StatusOK},
+		{"unversioned", http.MethodPost, `{"node_group": "shared", "node": "n1"}`, http.StatusOK},
+		{"unsupported version", http.MethodPost, `{"version": "v2", "node_group": "shared", "node": "n1"}`, http.StatusBadRequest},
+		{"invalid body", http.MethodPost, `{"node_group": `, http.StatusBadRequest},
+		{"not a post", http.MethodGet, ``, http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			received = PreDeleteHookRequest{}
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(tt.method, "/pre-delete", strings.NewReader(tt.body)))
+			assert.Equal(t, tt.wantStatus, recorder.Code)
+			if tt.wantStatus != http.StatusOK {
+				assert.Empty(t, received.Node)
+				return
+			}
+			assert.Equal(t, "n1", received.Node)
+			assert.Equal(t, ContentType, recorder.Header().Get("Content-Type"))
+			var response PreDeleteHookResponse
+			require.NoError(t, json.NewDecoder(recorder.Body).Decode(&response))
+			assert.Equal(t, PreDeleteHookResponse{Reason: "seats in use", RetryAfter: "5m"}, response)
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// PreDeleteHookFunc answers the pre delete hook requests of escalator. Serve it as the pre_delete_hook url of the node
+// groups, e.g. http.Handle("/pre-delete", events.PreDeleteHookFunc(allowDeletion))
+type PreDeleteHookFunc func(ctx context.Context, request PreDeleteHookRequest) PreDeleteHookResponse
+
+// ServeHTTP decodes the request, calls the function and encodes its response. Requests that aren't POSTs of a payload
+// of a supported version are rejected, which escalator handles with the failure_policy of the hook
+func (f PreDeleteHookFunc) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "only POST is supported", http.StatusMethodNotAllowed)
+		return
+	}
+	var request PreDeleteHookRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, fmt.Sprintf("invalid pre delete hook request: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err := checkVersion(request.Version); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	response := f(r.Context(), request)
+	w.Header().Set("Content-Type", ContentType)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, fmt.Sprintf("failed to encode the pre delete hook response: %v", err), http.StatusInternalServerError)
+	}
+}
+
+// checkVersion returns an error if the payload version isn't one this package decodes. Payloads without a version are
+// from releases before the payloads were versioned, which are v1
+func checkVersion(version string) error {
+	if len(version) == 0 || version == Version {
+		return nil
+	}
+	return fmt.Errorf("unsupported payload version %q, expected %v", version, Version)
+}
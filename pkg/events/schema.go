@@ -0,0 +1,32 @@
+package events
+
+// PreDeleteHookRequestSchema is the JSON schema of PreDeleteHookRequest
+const PreDeleteHookRequestSchema = `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "$id": "https://github.com/atlassian/escalator/pkg/events/v1/pre_delete_hook_request.json",
+  "title": "PreDeleteHookRequest",
+  "type": "object",
+  "required": ["node_group", "node", "provider_id", "pods_remaining"],
+  "properties": {
+    "version": {"type": "string", "enum": ["v1"]},
+    "node_group": {"type": "string"},
+    "node": {"type": "string"},
+    "provider_id": {"type": "string"},
+    "pods_remaining": {"type": ["array", "null"], "items": {"type": "string"}},
+    "cycle_id": {"type": "string"}
+  }
+}`
+
+// PreDeleteHookResponseSchema is the JSON schema of PreDeleteHookResponse
+const PreDeleteHookResponseSchema = `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "$id": "https://github.com/atlassian/escalator/pkg/events/v1/pre_delete_hook_response.json",
+  "title": "PreDeleteHookResponse",
+  "type": "object",
+  "required": ["allow"],
+  "properties": {
+    "allow": {"type": "boolean"},
+    "reason": {"type": "string"},
+    "retry_after": {"type": "string"}
+  }
+}`
@@ -6,9 +6,11 @@ import (
 
 	log "github.com/sirupsen/logrus"
 	v1 "k8s.io/api/core/v1"
+	policy "k8s.io/api/policy/v1beta1"
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/client-go/kubernetes"
 	v1lister "k8s.io/client-go/listers/core/v1"
+	policylister "k8s.io/client-go/listers/policy/v1beta1"
 	"k8s.io/client-go/tools/cache"
 )
 
@@ -37,6 +39,12 @@ func NewCachePodWatcher(client kubernetes.Interface, stop <-chan struct{}) (v1li
 
 // NewCacheNodeWatcher creates a new IndexerInformer for watching nodes from cache
 func NewCacheNodeWatcher(client kubernetes.Interface, stop <-chan struct{}) (v1lister.NodeLister, cache.InformerSynced) {
+	return NewCacheNodeWatcherWithHandler(client, stop, cache.ResourceEventHandlerFuncs{})
+}
+
+// NewCacheNodeWatcherWithHandler creates a new IndexerInformer for watching nodes from cache that calls the handler
+// on node events
+func NewCacheNodeWatcherWithHandler(client kubernetes.Interface, stop <-chan struct{}, handler cache.ResourceEventHandler) (v1lister.NodeLister, cache.InformerSynced) {
 	selector := fields.Everything()
 	nodesListWatch := cache.NewListWatchFromClient(
 		client.CoreV1().RESTClient(),
@@ -48,7 +56,7 @@ func NewCacheNodeWatcher(client kubernetes.Interface, stop <-chan struct{}) (v1l
 		nodesListWatch,
 		&v1.Node{},
 		1*time.Hour,
-		cache.ResourceEventHandlerFuncs{},
+		handler,
 		cache.Indexers{
 			cache.NamespaceIndex: cache.MetaNamespaceIndexFunc,
 		},
@@ -58,6 +66,54 @@ func NewCacheNodeWatcher(client kubernetes.Interface, stop <-chan struct{}) (v1l
 	return nodeLister, nodeController.HasSynced
 }
 
+// FailedSchedulingReason is the reason of the events the scheduler records on pods it couldn't schedule
+const FailedSchedulingReason = "FailedScheduling"
+
+// NewCacheEventWatcher creates a new IndexerInformer for watching the events with the reason from cache
+func NewCacheEventWatcher(client kubernetes.Interface, reason string, stop <-chan struct{}) (v1lister.EventLister, cache.InformerSynced) {
+	selector := fields.OneTermEqualSelector("reason", reason)
+	eventsListWatch := cache.NewListWatchFromClient(
+		client.CoreV1().RESTClient(),
+		"events",
+		v1.NamespaceAll,
+		selector,
+	)
+	eventIndexer, eventController := cache.NewIndexerInformer(
+		eventsListWatch,
+		&v1.Event{},
+		1*time.Hour,
+		cache.ResourceEventHandlerFuncs{},
+		cache.Indexers{
+			cache.NamespaceIndex: cache.MetaNamespaceIndexFunc,
+		},
+	)
+	eventLister := v1lister.NewEventLister(eventIndexer)
+	go eventController.Run(stop)
+	return eventLister, eventController.HasSynced
+}
+
+// NewCachePodDisruptionBudgetWatcher creates a new IndexerInformer for watching the PodDisruptionBudgets from cache
+func NewCachePodDisruptionBudgetWatcher(client kubernetes.Interface, stop <-chan struct{}) (policylister.PodDisruptionBudgetLister, cache.InformerSynced) {
+	pdbsListWatch := cache.NewListWatchFromClient(
+		client.PolicyV1beta1().RESTClient(),
+		"poddisruptionbudgets",
+		v1.NamespaceAll,
+		fields.Everything(),
+	)
+	pdbIndexer, pdbController := cache.NewIndexerInformer(
+		pdbsListWatch,
+		&policy.PodDisruptionBudget{},
+		1*time.Hour,
+		cache.ResourceEventHandlerFuncs{},
+		cache.Indexers{
+			cache.NamespaceIndex: cache.MetaNamespaceIndexFunc,
+		},
+	)
+	pdbLister := policylister.NewPodDisruptionBudgetLister(pdbIndexer)
+	go pdbController.Run(stop)
+	return pdbLister, pdbController.HasSynced
+}
+
 // WaitForSync wait for the cache sync for all the registered listers
 // it will try <tries> times and return the result
 func WaitForSync(tries int, stopChan <-chan struct{}, informers ...cache.InformerSynced) bool {
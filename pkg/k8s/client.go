@@ -9,12 +9,17 @@ import (
 
 // NewOutOfClusterClient returns a new kubernetes clientset using a kubeconfig file
 // For running outside the cluster
-func NewOutOfClusterClient(kubeconfig string) (*kubernetes.Clientset, error) {
-	// use the current context in kubeconfig
-	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+// An empty kubeconfig uses the default kubeconfig loading rules and an empty kubeContext uses the current context
+func NewOutOfClusterClient(kubeconfig string, kubeContext string, impersonate rest.ImpersonationConfig) (*kubernetes.Clientset, error) {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = kubeconfig
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: kubeContext}
+
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides).ClientConfig()
 	if err != nil {
 		return nil, errors.Errorf("Failed to create out of cluster config: %v", err)
 	}
+	config.Impersonate = impersonate
 
 	// create the clientset
 	clientset, err := kubernetes.NewForConfig(config)
@@ -25,12 +30,14 @@ func NewOutOfClusterClient(kubeconfig string) (*kubernetes.Clientset, error) {
 }
 
 // NewInClusterClient returns a new kubernetes clientset from inside the cluster
-func NewInClusterClient() (*kubernetes.Clientset, error) {
+func NewInClusterClient(impersonate rest.ImpersonationConfig) (*kubernetes.Clientset, error) {
 	// creates the in-cluster config
 	config, err := rest.InClusterConfig()
 	if err != nil {
 		return nil, errors.Errorf("Failed to create in of cluster config: %v", err)
 	}
+	config.Impersonate = impersonate
+
 	// creates the clientset
 	clientset, err := kubernetes.NewForConfig(config)
 	if err != nil {
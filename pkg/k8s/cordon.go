@@ -0,0 +1,87 @@
+package k8s
+
+import (
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Nodes are cordoned as a separate step right before they are deleted, so no pods are scheduled onto them while the
+// instance is terminated:
+// Unschedulable: true
+// Annotation: atlassian.com/escalator-cordoned-for-deletion: time.Now().Unix()
+// The annotation tells a node cordoned for deletion apart from one cordoned by someone else, so the cordon can be
+// undone when the deletion is abandoned
+
+// ToBeDeletedCordonAnnotationKey is the node annotation recording when the autoscaler cordoned the node to delete it
+// It is in the node label domain of the escalator instance, see SetNodeLabelDomain
+var ToBeDeletedCordonAnnotationKey string
+
+// CordonedForDeletion returns whether the node was cordoned by the autoscaler right before deleting it
+func CordonedForDeletion(node *apiv1.Node) bool {
+	_, ok := node.Annotations[ToBeDeletedCordonAnnotationKey]
+	return ok && node.Spec.Unschedulable
+}
+
+// CordonForDeletion cordons the node before it is deleted. Nodes that are already cordoned are left as they are
+// returns the latest successful update of the node
+func CordonForDeletion(node *apiv1.Node, client kubernetes.Interface) (*apiv1.Node, error) {
+	// fetch the latest version of the node to avoid conflict
+	updatedNode, err := client.CoreV1().Nodes().Get(node.Name, metav1.GetOptions{})
+	if err != nil || updatedNode == nil {
+		return node, wrapNodeError(err, "failed to get node %v", node.Name)
+	}
+	if updatedNode.Spec.Unschedulable {
+		return updatedNode, nil
+	}
+
+	if updatedNode.Annotations == nil {
+		updatedNode.Annotations = make(map[string]string)
+	}
+	updatedNode.Spec.Unschedulable = true
+	updatedNode.Annotations[ToBeDeletedCordonAnnotationKey] = encodeTaintTime(time.Now())
+
+	updatedNodeWithCordon, err := client.CoreV1().Nodes().Update(updatedNode)
+	if err != nil || updatedNodeWithCordon == nil {
+		return updatedNode, wrapNodeError(err, "failed to update node %v after cordoning it for deletion", updatedNode.Name)
+	}
+	log.Infof("Successfully cordoned node %v for deletion", updatedNodeWithCordon.Name)
+	return updatedNodeWithCordon, nil
+}
+
+// UncordonAbandonedDeletion undoes the cordon added by CordonForDeletion, when the node is no longer going to be
+// deleted. The cordon that marks a node to be removed is left alone
+// returns the latest successful update of the node
+func UncordonAbandonedDeletion(node *apiv1.Node, client kubernetes.Interface) (*apiv1.Node, error) {
+	// fetch the latest version of the node to avoid conflict
+	updatedNode, err := client.CoreV1().Nodes().Get(node.Name, metav1.GetOptions{})
+	if err != nil || updatedNode == nil {
+		return node, wrapNodeError(err, "failed to get node %v", node.Name)
+	}
+	if !removeDeletionCordon(updatedNode) {
+		return updatedNode, nil
+	}
+
+	updatedNodeWithoutCordon, err := client.CoreV1().Nodes().Update(updatedNode)
+	if err != nil || updatedNodeWithoutCordon == nil {
+		return updatedNode, wrapNodeError(err, "failed to update node %v after uncordoning it", updatedNode.Name)
+	}
+	log.Infof("Successfully uncordoned node %v after its deletion was abandoned", updatedNodeWithoutCordon.Name)
+	return updatedNodeWithoutCordon, nil
+}
+
+// removeDeletionCordon removes the deletion cordon from the node in place, returns whether the node changed. The
+// annotation is removed even when someone else uncordoned the node since
+func removeDeletionCordon(node *apiv1.Node) bool {
+	if _, ok := node.Annotations[ToBeDeletedCordonAnnotationKey]; !ok {
+		return false
+	}
+	if !CordonedToBeRemoved(node) {
+		node.Spec.Unschedulable = false
+	}
+	delete(node.Annotations, ToBeDeletedCordonAnnotationKey)
+	return true
+}
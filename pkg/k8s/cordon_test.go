@@ -0,0 +1,88 @@
+package k8s
+
+import (
+	"testing"
+
+	"github.com/atlassian/escalator/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCordonForDeletion(t *testing.T) {
+	node := test.BuildTestNode(test.NodeOpts{Name: "n1"})
+	client, updates := buildFakeClientAndUpdateChannel(node)
+
+	cordoned, err := CordonForDeletion(node, client)
+	require.NoError(t, err)
+	assert.Equal(t, "n1", getStringFromChan(updates))
+	assert.True(t, CordonedForDeletion(cordoned))
+	// the deletion cordon doesn't mark the node to be removed
+	assert.False(t, CordonedToBeRemoved(cordoned))
+
+	// the node is only cordoned once
+	_, err = CordonForDeletion(cordoned, client)
+	require.NoError(t, err)
+	assert.Equal(t, "nothing returned", getStringFromChan(updates))
+
+	uncordoned, err := UncordonAbandonedDeletion(cordoned, client)
+	require.NoError(t, err)
+	assert.Equal(t, "n1", getStringFromChan(updates))
+	assert.False(t, uncordoned.Spec.Unschedulable)
+	assert.False(t, CordonedForDeletion(uncordoned))
+	assert.NotContains(t, uncordoned.Annotations, ToBeDeletedCordonAnnotationKey)
+
+	// nothing to undo
+	_, err = UncordonAbandonedDeletion(uncordoned, client)
+	require.NoError(t, err)
+	assert.Equal(t, "nothing returned", getStringFromChan(updates))
+}
+
+func TestCordonForDeletion_AlreadyCordoned(t *testing.T) {
+	// cordoned by someone else
+	node := test.BuildTestNode(test.NodeOpts{Name: "n1"})
+	node.Spec.Unschedulable = true
+	client, updates := buildFakeClientAndUpdateChannel(node)
+
+	cordoned, err := CordonForDeletion(node, client)
+	require.NoError(t, err)
+	assert.Equal(t, "nothing returned", getStringFromChan(updates))
+	assert.True(t, cordoned.Spec.Unschedulable)
+	assert.False(t, CordonedForDeletion(cordoned))
+
+	// and so isn't uncordoned when the deletion is abandoned
+	uncordoned, err := UncordonAbandonedDeletion(cordoned, client)
+	require.NoError(t, err)
+	assert.Equal(t, "nothing returned", getStringFromChan(updates))
+	assert.True(t, uncordoned.Spec.Unschedulable)
+}
+
+func TestUncordonAbandonedDeletion_MarkedToBeRemoved(t *testing.T) {
+	// a node cordoned to mark it to be removed stays cordoned
+	node := test.BuildTestNode(test.NodeOpts{Name: "n1"})
+	node.Spec.Unschedulable = true
+	node.Annotations = map[string]string{
+		ToBeRemovedCordonAnnotationKey: "1500000000",
+		ToBeDeletedCordonAnnotationKey: "1500000000",
+	}
+	client, updates := buildFakeClientAndUpdateChannel(node)
+
+	uncordoned, err := UncordonAbandonedDeletion(node, client)
+	require.NoError(t, err)
+	assert.Equal(t, "n1", getStringFromChan(updates))
+	assert.True(t, CordonedToBeRemoved(uncordoned))
+	assert.NotContains(t, uncordoned.Annotations, ToBeDeletedCordonAnnotationKey)
+}
+
+func TestDeleteToBeRemovedTaint_CordonedForDeletion(t *testing.T) {
+	node := test.BuildTestNode(test.NodeOpts{Name: "n1", Tainted: true})
+	node.Spec.Unschedulable = true
+	node.Annotations = map[string]string{ToBeDeletedCordonAnnotationKey: "1500000000"}
+	client, updates := buildFakeClientAndUpdateChannel(node)
+
+	untainted, err := DeleteToBeRemovedTaint(node, client)
+	require.NoError(t, err)
+	assert.Equal(t, "n1", getStringFromChan(updates))
+	assert.False(t, MarkedToBeRemoved(untainted))
+	assert.False(t, untainted.Spec.Unschedulable)
+	assert.NotContains(t, untainted.Annotations, ToBeDeletedCordonAnnotationKey)
+}
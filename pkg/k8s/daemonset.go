@@ -0,0 +1,52 @@
+package k8s
+
+import (
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	v12 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/kubernetes"
+)
+
+// PodOwnedByDaemonSet returns if the pod belongs to the DaemonSet, given as namespace/name
+func PodOwnedByDaemonSet(pod *v1.Pod, daemonSet string) bool {
+	parts := strings.SplitN(daemonSet, "/", 2)
+	if len(parts) != 2 || pod.Namespace != parts[0] {
+		return false
+	}
+	for _, ownerReference := range pod.ObjectMeta.OwnerReferences {
+		if ownerReference.Kind == "DaemonSet" && ownerReference.Name == parts[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// DeleteDaemonSetPods deletes the pods of the DaemonSets, given as namespace/name, that run on the node.
+// The pods are listed from the API, as DaemonSet pods usually aren't selected by the node group listers
+// It returns the names of the pods deleted
+func DeleteDaemonSetPods(node *v1.Node, daemonSets []string, client kubernetes.Interface) ([]string, error) {
+	var deleted []string
+	for _, daemonSet := range daemonSets {
+		namespace := strings.SplitN(daemonSet, "/", 2)[0]
+		pods, err := client.CoreV1().Pods(namespace).List(v12.ListOptions{
+			FieldSelector: fields.OneTermEqualSelector("spec.nodeName", node.Name).String(),
+		})
+		if err != nil {
+			return deleted, err
+		}
+
+		for i := range pods.Items {
+			pod := &pods.Items[i]
+			if pod.Spec.NodeName != node.Name || !PodOwnedByDaemonSet(pod, daemonSet) {
+				continue
+			}
+			if err := client.CoreV1().Pods(pod.Namespace).Delete(pod.Name, &v12.DeleteOptions{}); err != nil {
+				return deleted, err
+			}
+			deleted = append(deleted, pod.Namespace+"/"+pod.Name)
+		}
+	}
+	return deleted, nil
+}
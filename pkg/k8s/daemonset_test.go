@@ -0,0 +1,59 @@
+package k8s
+
+import (
+	"testing"
+
+	"github.com/atlassian/escalator/pkg/test"
+	"github.com/stretchr/testify/assert"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	core "k8s.io/client-go/testing"
+)
+
+func buildDaemonSetPod(namespace, name, owner, nodeName string) *apiv1.Pod {
+	pod := test.BuildTestPod(test.PodOpts{Name: name, Namespace: namespace, NodeName: nodeName})
+	pod.OwnerReferences = []metav1.OwnerReference{{Kind: "DaemonSet", Name: owner}}
+	return pod
+}
+
+func TestPodOwnedByDaemonSet(t *testing.T) {
+	pod := buildDaemonSetPod("logging", "fluentd-abcde", "fluentd", "n1")
+	tests := []struct {
+		name      string
+		daemonSet string
+		want      bool
+	}{
+		{"owner", "logging/fluentd", true},
+		{"other namespace", "kube-system/fluentd", false},
+		{"other daemonset", "logging/vector", false},
+		{"no namespace", "fluentd", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, PodOwnedByDaemonSet(pod, tt.daemonSet))
+		})
+	}
+}
+
+func TestDeleteDaemonSetPods(t *testing.T) {
+	node := test.BuildTestNode(test.NodeOpts{Name: "n1"})
+	pods := []*apiv1.Pod{
+		buildDaemonSetPod("logging", "fluentd-abcde", "fluentd", "n1"),
+		buildDaemonSetPod("logging", "fluentd-fghij", "fluentd", "n2"),
+		buildDaemonSetPod("kube-system", "kube-proxy-abcde", "kube-proxy", "n1"),
+		test.BuildTestPod(test.PodOpts{Name: "app", Namespace: "logging", NodeName: "n1"}),
+	}
+	client, _ := test.BuildFakeClient([]*apiv1.Node{node}, pods)
+
+	deleted, err := DeleteDaemonSetPods(node, []string{"logging/fluentd"}, client)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"logging/fluentd-abcde"}, deleted)
+
+	var deleteActions []string
+	for _, action := range client.Actions() {
+		if deleteAction, ok := action.(core.DeleteAction); ok {
+			deleteActions = append(deleteActions, deleteAction.GetNamespace()+"/"+deleteAction.GetName())
+		}
+	}
+	assert.Equal(t, []string{"logging/fluentd-abcde"}, deleteActions)
+}
@@ -0,0 +1,88 @@
+package k8s
+
+import (
+	"strings"
+
+	"github.com/atlassian/escalator/pkg/errorkind"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// Escalator instances are segregated by a node label domain, so more than one can run in a cluster:
+// Taint: <domain>/escalator
+// Annotations: <domain>/escalator-taint-version, <domain>/escalator-cordoned, ...
+// Without a domain the keys are in the atlassian.com domain, as written by every earlier version of escalator
+
+// DefaultKeyDomain is the domain of the taint and annotation keys of an escalator without a node label domain
+const DefaultKeyDomain = "atlassian.com"
+
+// nodeLabelDomain is the node label domain of this escalator instance, empty if it isn't segregated
+var nodeLabelDomain string
+
+func init() {
+	setKeyDomain(DefaultKeyDomain)
+}
+
+// SetNodeLabelDomain segregates this escalator instance by the node label domain. The taint and annotation keys
+// written by escalator are moved into the domain. An empty domain restores the default keys
+func SetNodeLabelDomain(domain string) error {
+	if len(domain) == 0 {
+		nodeLabelDomain = ""
+		setKeyDomain(DefaultKeyDomain)
+		return nil
+	}
+	if errs := validation.IsDNS1123Subdomain(domain); len(errs) > 0 {
+		return errorkind.New(errorkind.Validation, "invalid node label domain %q: %v", domain, strings.Join(errs, ", "))
+	}
+	nodeLabelDomain = domain
+	setKeyDomain(domain)
+	return nil
+}
+
+// NodeLabelDomain returns the node label domain of this escalator instance, empty if it isn't segregated
+func NodeLabelDomain() string {
+	return nodeLabelDomain
+}
+
+// InNodeLabelDomain returns whether the label key is in the node label domain of this escalator instance. Every key
+// is when the instance isn't segregated
+func InNodeLabelDomain(key string) bool {
+	if len(nodeLabelDomain) == 0 {
+		return true
+	}
+	return strings.HasPrefix(key, nodeLabelDomain+"/")
+}
+
+// NodeInLabelDomain returns whether the node has a label in the node label domain of this escalator instance. Every
+// node does when the instance isn't segregated
+func NodeInLabelDomain(node *apiv1.Node) bool {
+	if len(nodeLabelDomain) == 0 {
+		return true
+	}
+	for key := range node.Labels {
+		if InNodeLabelDomain(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// InstanceResourceName returns the name of a cluster resource owned by this escalator instance, e.g. the leader
+// election config map, suffixed with the node label domain so each instance has its own
+func InstanceResourceName(name string) string {
+	if len(nodeLabelDomain) == 0 || len(name) == 0 {
+		return name
+	}
+	return name + "-" + nodeLabelDomain
+}
+
+// setKeyDomain sets the domain of the taint and annotation keys written by escalator
+func setKeyDomain(domain string) {
+	EscalatorKeyPrefix = domain + "/escalator"
+	ToBeRemovedByAutoscalerKey = EscalatorKeyPrefix
+	TaintSchemaVersionAnnotationKey = EscalatorKeyPrefix + "-taint-version"
+	ToBeRemovedCordonAnnotationKey = EscalatorKeyPrefix + "-cordoned"
+	ToBeRemovedIntentAnnotationKey = EscalatorKeyPrefix + "-removal-intent"
+	ToBeDeletedCordonAnnotationKey = EscalatorKeyPrefix + "-cordoned-for-deletion"
+	SelfTestTaintKey = EscalatorKeyPrefix + "-selftest"
+}
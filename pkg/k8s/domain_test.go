@@ -0,0 +1,62 @@
+package k8s
+
+import (
+	"testing"
+
+	"github.com/atlassian/escalator/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetNodeLabelDomain(t *testing.T) {
+	defer SetNodeLabelDomain("")
+
+	assert.Equal(t, "atlassian.com/escalator", ToBeRemovedByAutoscalerKey)
+	assert.Equal(t, "escalator-leader-elect", InstanceResourceName("escalator-leader-elect"))
+	assert.True(t, InNodeLabelDomain("customer"))
+
+	require.NoError(t, SetNodeLabelDomain("team-a.example.com"))
+	assert.Equal(t, "team-a.example.com", NodeLabelDomain())
+	assert.Equal(t, "team-a.example.com/escalator", ToBeRemovedByAutoscalerKey)
+	assert.Equal(t, "team-a.example.com/escalator-taint-version", TaintSchemaVersionAnnotationKey)
+	assert.Equal(t, "team-a.example.com/escalator-cordoned", ToBeRemovedCordonAnnotationKey)
+	assert.Equal(t, "team-a.example.com/escalator-removal-intent", ToBeRemovedIntentAnnotationKey)
+	assert.Equal(t, "team-a.example.com/escalator-cordoned-for-deletion", ToBeDeletedCordonAnnotationKey)
+	assert.Equal(t, "team-a.example.com/escalator-selftest", SelfTestTaintKey)
+	assert.Equal(t, "escalator-leader-elect-team-a.example.com", InstanceResourceName("escalator-leader-elect"))
+	// an empty name stays disabled
+	assert.Equal(t, "", InstanceResourceName(""))
+
+	assert.True(t, InNodeLabelDomain("team-a.example.com/nodegroup"))
+	assert.False(t, InNodeLabelDomain("team-b.example.com/nodegroup"))
+	assert.False(t, InNodeLabelDomain("customer"))
+
+	assert.Error(t, SetNodeLabelDomain("Team_A"))
+
+	require.NoError(t, SetNodeLabelDomain(""))
+	assert.Equal(t, "atlassian.com/escalator", ToBeRemovedByAutoscalerKey)
+	assert.Equal(t, "atlassian.com/escalator-taint-version", TaintSchemaVersionAnnotationKey)
+}
+
+func TestNodeInLabelDomain(t *testing.T) {
+	defer SetNodeLabelDomain("")
+
+	teamA := test.BuildTestNode(test.NodeOpts{Name: "n1", LabelKey: "team-a.example.com/nodegroup", LabelValue: "shared"})
+	teamB := test.BuildTestNode(test.NodeOpts{Name: "n2", LabelKey: "team-b.example.com/nodegroup", LabelValue: "shared"})
+	assert.True(t, NodeInLabelDomain(teamB))
+
+	require.NoError(t, SetNodeLabelDomain("team-a.example.com"))
+	assert.True(t, NodeInLabelDomain(teamA))
+	assert.False(t, NodeInLabelDomain(teamB))
+}
+
+func TestGetEscalatorKeys_NodeLabelDomain(t *testing.T) {
+	defer SetNodeLabelDomain("")
+	require.NoError(t, SetNodeLabelDomain("team-a.example.com"))
+
+	// the taint of the other instance isn't ours
+	node := test.BuildTestNode(test.NodeOpts{Name: "n1", Tainted: true})
+	_, tainted := GetToBeRemovedTaint(node)
+	assert.False(t, tainted)
+	assert.Empty(t, GetEscalatorKeys(node))
+}
@@ -0,0 +1,58 @@
+package k8s
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	coordinationv1beta1 "k8s.io/api/coordination/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// HeartbeatConfig stores the configuration for the heartbeat lease
+type HeartbeatConfig struct {
+	Namespace string
+	Name      string
+	// Identity is written as the holder of the lease, usually the pod name
+	Identity string
+	// Duration is how long the heartbeat is valid for. Watchdogs should consider the lease stale after it expires
+	Duration time.Duration
+}
+
+// UpdateHeartbeatLease renews the heartbeat lease, creating it if it doesn't exist
+func UpdateHeartbeatLease(client kubernetes.Interface, config HeartbeatConfig, now time.Time) error {
+	leases := client.CoordinationV1beta1().Leases(config.Namespace)
+	renewTime := metav1.NewMicroTime(now)
+	durationSeconds := int32(config.Duration.Seconds())
+
+	lease, err := leases.Get(config.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = leases.Create(&coordinationv1beta1.Lease{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      config.Name,
+				Namespace: config.Namespace,
+			},
+			Spec: coordinationv1beta1.LeaseSpec{
+				HolderIdentity:       &config.Identity,
+				LeaseDurationSeconds: &durationSeconds,
+				AcquireTime:          &renewTime,
+				RenewTime:            &renewTime,
+			},
+		})
+		return errors.Wrap(err, "failed to create heartbeat lease")
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to get heartbeat lease")
+	}
+
+	// a new holder, e.g. after a leader election, acquires the lease
+	if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity != config.Identity {
+		lease.Spec.HolderIdentity = &config.Identity
+		lease.Spec.AcquireTime = &renewTime
+	}
+	lease.Spec.LeaseDurationSeconds = &durationSeconds
+	lease.Spec.RenewTime = &renewTime
+	_, err = leases.Update(lease)
+	return errors.Wrap(err, "failed to update heartbeat lease")
+}
@@ -0,0 +1,47 @@
+package k8s
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestUpdateHeartbeatLease(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	config := HeartbeatConfig{
+		Namespace: "kube-system",
+		Name:      "escalator-heartbeat",
+		Identity:  "escalator-1",
+		Duration:  3 * time.Minute,
+	}
+	created := time.Date(2019, 3, 1, 3, 12, 0, 0, time.UTC)
+	require.NoError(t, UpdateHeartbeatLease(client, config, created))
+
+	lease, err := client.CoordinationV1beta1().Leases("kube-system").Get("escalator-heartbeat", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "escalator-1", *lease.Spec.HolderIdentity)
+	assert.Equal(t, int32(180), *lease.Spec.LeaseDurationSeconds)
+	assert.True(t, created.Equal(lease.Spec.RenewTime.Time))
+	assert.True(t, created.Equal(lease.Spec.AcquireTime.Time))
+
+	// renewing keeps the acquire time
+	renewed := created.Add(time.Minute)
+	require.NoError(t, UpdateHeartbeatLease(client, config, renewed))
+	lease, err = client.CoordinationV1beta1().Leases("kube-system").Get("escalator-heartbeat", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.True(t, renewed.Equal(lease.Spec.RenewTime.Time))
+	assert.True(t, created.Equal(lease.Spec.AcquireTime.Time))
+
+	// a new holder acquires the lease
+	config.Identity = "escalator-2"
+	acquired := renewed.Add(time.Minute)
+	require.NoError(t, UpdateHeartbeatLease(client, config, acquired))
+	lease, err = client.CoordinationV1beta1().Leases("kube-system").Get("escalator-heartbeat", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "escalator-2", *lease.Spec.HolderIdentity)
+	assert.True(t, acquired.Equal(lease.Spec.AcquireTime.Time))
+}
@@ -39,13 +39,13 @@ func CreateNodeNameToInfoMap(pods []*v1.Pod, nodes []*v1.Node) map[string]*cache
 	return nodeNameToNodeInfo
 }
 
-// NodeEmpty returns if the node is empty of pods, except for daemonsets
+// NodeEmpty returns if the node is empty of pods, except for daemonsets and pause only pods
 func NodeEmpty(node *v1.Node, nodeInfoMap map[string]*cache.NodeInfo) bool {
 	nodePodsRemaining, ok := NodePodsRemaining(node, nodeInfoMap)
 	return ok && nodePodsRemaining == 0
 }
 
-// NodePodsRemaining returns the number of pods on the node, except for daemonset pods
+// NodePodsRemaining returns the number of pods on the node, except for daemonset and pause only pods
 func NodePodsRemaining(node *v1.Node, nodeInfoMap map[string]*cache.NodeInfo) (int, bool) {
 	nodeInfo, ok := nodeInfoMap[node.Name]
 	if !ok {
@@ -53,11 +53,11 @@ func NodePodsRemaining(node *v1.Node, nodeInfoMap map[string]*cache.NodeInfo) (i
 		return 0, false
 	}
 
-	// check all the pods and make sure they're daemonsets
+	// check all the pods and make sure they're daemonsets or pause only pods
 	// otherwise there are sacred pods still on the node
 	pods := 0
 	for _, pod := range nodeInfo.Pods() {
-		if !PodIsDaemonSet(pod) {
+		if PodKeepsNodeBusy(pod) {
 			pods++
 		}
 	}
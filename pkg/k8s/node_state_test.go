@@ -74,6 +74,13 @@ func TestCreateNodeNameToInfoMap(t *testing.T) {
 	}
 }
 
+// buildTestPausePod builds a pod on the node that only runs the pause image
+func buildTestPausePod(nodeName string, image string) *v1.Pod {
+	pod := test.BuildTestPod(test.PodOpts{NodeName: nodeName, CPU: []int64{0}, Mem: []int64{0}})
+	pod.Spec.Containers[0].Image = image
+	return pod
+}
+
 func TestNodeEmpty(t *testing.T) {
 	type args struct {
 		nodes         []*v1.Node
@@ -141,6 +148,65 @@ func TestNodeEmpty(t *testing.T) {
 			},
 			true,
 		},
+		{
+			"windows node with just daemon sets",
+			args{
+				[]*v1.Node{
+					test.BuildTestNode(test.NodeOpts{Name: "node-1", LabelKey: OSLabelKey, LabelValue: OSWindows}),
+				},
+				[]*v1.Pod{
+					test.BuildTestPod(test.PodOpts{NodeName: "node-1", Owner: "DaemonSet", NodeSelectorKey: OSLabelKey, NodeSelectorValue: OSWindows}),
+					test.BuildTestPod(test.PodOpts{NodeName: "node-1", Owner: "DaemonSet", NodeSelectorKey: OSLabelKey, NodeSelectorValue: OSWindows}),
+				},
+				"node-1",
+				false,
+			},
+			true,
+		},
+		{
+			"windows node with daemon sets and pause pods",
+			args{
+				[]*v1.Node{
+					test.BuildTestNode(test.NodeOpts{Name: "node-1", LabelKey: OSLabelKey, LabelValue: OSWindows}),
+				},
+				[]*v1.Pod{
+					test.BuildTestPod(test.PodOpts{NodeName: "node-1", Owner: "DaemonSet", NodeSelectorKey: OSLabelKey, NodeSelectorValue: OSWindows}),
+					buildTestPausePod("node-1", "mcr.microsoft.com/oss/kubernetes/pause:1.4.1"),
+				},
+				"node-1",
+				false,
+			},
+			true,
+		},
+		{
+			"node with just pause pods",
+			args{
+				[]*v1.Node{
+					test.BuildTestNode(test.NodeOpts{Name: "node-1"}),
+				},
+				[]*v1.Pod{
+					buildTestPausePod("node-1", "k8s.gcr.io/pause:3.1"),
+				},
+				"node-1",
+				false,
+			},
+			true,
+		},
+		{
+			"node with pause pods and pods",
+			args{
+				[]*v1.Node{
+					test.BuildTestNode(test.NodeOpts{Name: "node-1"}),
+				},
+				[]*v1.Pod{
+					buildTestPausePod("node-1", "k8s.gcr.io/pause:3.1"),
+					test.BuildTestPod(test.PodOpts{NodeName: "node-1"}),
+				},
+				"node-1",
+				false,
+			},
+			false,
+		},
 		{
 			"node with daemon sets and pods",
 			args{
@@ -0,0 +1,37 @@
+package k8s
+
+import (
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// RuntimeClassOverheads are the resources used by a pod running with a RuntimeClass on top of the requests of its
+// containers, e.g. the sandbox of gVisor or Kata, by RuntimeClass name. They mirror the overhead.podFixed of each
+// RuntimeClass, as pods don't carry spec.overhead in the version of the kubernetes api escalator is built against
+type RuntimeClassOverheads map[string]v1.ResourceList
+
+// PodOverhead returns the overhead of the RuntimeClass of the pod, nil if the pod has no RuntimeClass or the
+// RuntimeClass has no overhead
+func (o RuntimeClassOverheads) PodOverhead(pod *v1.Pod) v1.ResourceList {
+	if pod.Spec.RuntimeClassName == nil {
+		return nil
+	}
+	return o[*pod.Spec.RuntimeClassName]
+}
+
+// CalculatePodsRequestsTotalWithOverhead returns the total capacity of all pods, including the overhead of their
+// RuntimeClasses
+func CalculatePodsRequestsTotalWithOverhead(pods []*v1.Pod, overheads RuntimeClassOverheads) (resource.Quantity, resource.Quantity, error) {
+	memoryRequest, cpuRequests, err := CalculatePodsRequestsTotal(pods)
+	if err != nil || len(overheads) == 0 {
+		return memoryRequest, cpuRequests, err
+	}
+
+	for _, pod := range pods {
+		if overhead := overheads.PodOverhead(pod); overhead != nil {
+			memoryRequest.Add(*overhead.Memory())
+			cpuRequests.Add(*overhead.Cpu())
+		}
+	}
+	return memoryRequest, cpuRequests, nil
+}
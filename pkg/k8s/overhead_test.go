@@ -0,0 +1,46 @@
+package k8s
+
+import (
+	"testing"
+
+	"github.com/atlassian/escalator/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestCalculatePodsRequestsTotalWithOverhead(t *testing.T) {
+	withRuntimeClass := func(pod *v1.Pod, runtimeClass string) *v1.Pod {
+		pod.Spec.RuntimeClassName = &runtimeClass
+		return pod
+	}
+	overheads := RuntimeClassOverheads{
+		"gvisor": v1.ResourceList{v1.ResourceCPU: resource.MustParse("250m"), v1.ResourceMemory: resource.MustParse("100")},
+		// only the memory overhead is known
+		"kata": v1.ResourceList{v1.ResourceMemory: resource.MustParse("50")},
+	}
+	// a node group running runc, gVisor and Kata pods side by side
+	pods := []*v1.Pod{
+		test.BuildTestPod(test.PodOpts{CPU: []int64{1000}, Mem: []int64{1000}}),
+		withRuntimeClass(test.BuildTestPod(test.PodOpts{CPU: []int64{1000}, Mem: []int64{1000}}), "gvisor"),
+		withRuntimeClass(test.BuildTestPod(test.PodOpts{CPU: []int64{500}, Mem: []int64{500}}), "gvisor"),
+		withRuntimeClass(test.BuildTestPod(test.PodOpts{CPU: []int64{1000}, Mem: []int64{1000}}), "kata"),
+		// a RuntimeClass without an overhead
+		withRuntimeClass(test.BuildTestPod(test.PodOpts{CPU: []int64{1000}, Mem: []int64{1000}}), "runc"),
+	}
+
+	mem, cpu, err := CalculatePodsRequestsTotalWithOverhead(pods, overheads)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4500+2*250), cpu.MilliValue())
+	assert.Equal(t, int64(4500+2*100+50), mem.Value())
+
+	// without overheads only the containers are counted
+	mem, cpu, err = CalculatePodsRequestsTotalWithOverhead(pods, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4500), cpu.MilliValue())
+	assert.Equal(t, int64(4500), mem.Value())
+
+	assert.Nil(t, overheads.PodOverhead(pods[0]))
+	assert.Equal(t, overheads["gvisor"], overheads.PodOverhead(pods[1]))
+}
@@ -0,0 +1,53 @@
+package k8s
+
+import (
+	"fmt"
+	"sort"
+
+	v1 "k8s.io/api/core/v1"
+	policy "k8s.io/api/policy/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// PodDisruptionBudgetKey returns the namespace/name of the PodDisruptionBudget
+func PodDisruptionBudgetKey(pdb *policy.PodDisruptionBudget) string {
+	return fmt.Sprintf("%v/%v", pdb.Namespace, pdb.Name)
+}
+
+// PodDisruptionBudgetEvictions returns the number of the pods covered by each PodDisruptionBudget by namespace/name.
+// Pods that have finished aren't counted, and a budget with an empty selector covers no pods
+func PodDisruptionBudgetEvictions(pods []*v1.Pod, pdbs []*policy.PodDisruptionBudget) map[string]int {
+	evictions := make(map[string]int)
+	for _, pdb := range pdbs {
+		selector, err := metav1.LabelSelectorAsSelector(pdb.Spec.Selector)
+		if err != nil || selector.Empty() {
+			continue
+		}
+		for _, pod := range pods {
+			if pod.Namespace != pdb.Namespace || pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+				continue
+			}
+			if selector.Matches(labels.Set(pod.Labels)) {
+				evictions[PodDisruptionBudgetKey(pdb)]++
+			}
+		}
+	}
+	return evictions
+}
+
+// ViolatedPodDisruptionBudgets returns the namespace/name of the PodDisruptionBudgets that evicting the pods would
+// violate, sorted. evicted are the pods of each budget by namespace/name already evicted since the status of the
+// budgets was last updated, which count against the disruptions they allow
+func ViolatedPodDisruptionBudgets(pods []*v1.Pod, pdbs []*policy.PodDisruptionBudget, evicted map[string]int) []string {
+	evictions := PodDisruptionBudgetEvictions(pods, pdbs)
+	var violated []string
+	for _, pdb := range pdbs {
+		key := PodDisruptionBudgetKey(pdb)
+		if evictions[key] > 0 && evicted[key]+evictions[key] > int(pdb.Status.PodDisruptionsAllowed) {
+			violated = append(violated, key)
+		}
+	}
+	sort.Strings(violated)
+	return violated
+}
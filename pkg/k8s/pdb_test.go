@@ -0,0 +1,75 @@
+package k8s
+
+import (
+	"testing"
+
+	"github.com/atlassian/escalator/pkg/test"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	policy "k8s.io/api/policy/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// buildPodDisruptionBudget returns a PodDisruptionBudget of the pods with the app label that allows the disruptions
+func buildPodDisruptionBudget(namespace string, app string, allowed int32) *policy.PodDisruptionBudget {
+	return &policy.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: app},
+		Spec:       policy.PodDisruptionBudgetSpec{Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": app}}},
+		Status:     policy.PodDisruptionBudgetStatus{PodDisruptionsAllowed: allowed},
+	}
+}
+
+// buildAppPod returns a pod with the app label
+func buildAppPod(namespace string, name string, app string) *v1.Pod {
+	pod := test.BuildTestPod(test.PodOpts{Name: name, Namespace: namespace})
+	pod.Labels = map[string]string{"app": app}
+	return pod
+}
+
+func TestPodDisruptionBudgetEvictions(t *testing.T) {
+	finished := buildAppPod("default", "web-3", "web")
+	finished.Status.Phase = v1.PodSucceeded
+	pods := []*v1.Pod{
+		buildAppPod("default", "web-1", "web"),
+		buildAppPod("default", "web-2", "web"),
+		finished,
+		buildAppPod("other", "web-1", "web"),
+		buildAppPod("default", "db-1", "db"),
+	}
+	emptySelector := buildPodDisruptionBudget("default", "everything", 0)
+	emptySelector.Spec.Selector = &metav1.LabelSelector{}
+	pdbs := []*policy.PodDisruptionBudget{
+		buildPodDisruptionBudget("default", "web", 1),
+		buildPodDisruptionBudget("default", "cache", 0),
+		emptySelector,
+	}
+
+	assert.Equal(t, map[string]int{"default/web": 2}, PodDisruptionBudgetEvictions(pods, pdbs))
+}
+
+func TestViolatedPodDisruptionBudgets(t *testing.T) {
+	pdbs := []*policy.PodDisruptionBudget{
+		buildPodDisruptionBudget("default", "web", 1),
+		buildPodDisruptionBudget("default", "db", 0),
+		buildPodDisruptionBudget("default", "cache", 2),
+	}
+
+	tests := []struct {
+		name    string
+		pods    []*v1.Pod
+		evicted map[string]int
+		want    []string
+	}{
+		{"no pods", nil, nil, nil},
+		{"uncovered pods", []*v1.Pod{buildAppPod("default", "batch-1", "batch")}, nil, nil},
+		{"within the budget", []*v1.Pod{buildAppPod("default", "web-1", "web")}, nil, nil},
+		{"past the budget", []*v1.Pod{buildAppPod("default", "web-1", "web"), buildAppPod("default", "web-2", "web")}, nil, []string{"default/web"}},
+		{"no disruptions allowed", []*v1.Pod{buildAppPod("default", "db-1", "db"), buildAppPod("default", "web-1", "web")}, nil, []string{"default/db"}},
+		{"already evicted", []*v1.Pod{buildAppPod("default", "cache-1", "cache"), buildAppPod("default", "web-1", "web")}, map[string]int{"default/cache": 2, "default/web": 1}, []string{"default/cache", "default/web"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, ViolatedPodDisruptionBudgets(tt.pods, pdbs, tt.evicted))
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package k8s
+
+import (
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	// OSLabelKey is the well known node label for the operating system of the node
+	OSLabelKey = "kubernetes.io/os"
+	// BetaOSLabelKey is the deprecated node label for the operating system of the node
+	BetaOSLabelKey = "beta.kubernetes.io/os"
+
+	// OSLinux is the value of the os label on linux nodes
+	OSLinux = "linux"
+	// OSWindows is the value of the os label on windows nodes
+	OSWindows = "windows"
+
+	// ArchLabelKey is the well known node label for the cpu architecture of the node
+	ArchLabelKey = "kubernetes.io/arch"
+	// BetaArchLabelKey is the deprecated node label for the cpu architecture of the node
+	BetaArchLabelKey = "beta.kubernetes.io/arch"
+
+	// ArchAMD64 is the value of the arch label on x86-64 nodes
+	ArchAMD64 = "amd64"
+	// ArchARM64 is the value of the arch label on arm64 nodes, e.g. AWS Graviton
+	ArchARM64 = "arm64"
+)
+
+// NodeOS returns the operating system of the node from its labels. Returns an empty string if it isn't labelled
+func NodeOS(node *v1.Node) string {
+	return firstLabel(node.Labels, OSLabelKey, BetaOSLabelKey)
+}
+
+// PodAllowsOS returns whether the node selector and required node affinity of the pod allow it to be scheduled
+// on a node with the operating system. Pods that don't select an operating system are allowed on every operating system
+func PodAllowsOS(pod *v1.Pod, os string) bool {
+	return podAllowsLabelValue(pod, os, OSLabelKey, BetaOSLabelKey)
+}
+
+// NodeArch returns the cpu architecture of the node from its labels. Returns an empty string if it isn't labelled
+func NodeArch(node *v1.Node) string {
+	return firstLabel(node.Labels, ArchLabelKey, BetaArchLabelKey)
+}
+
+// PodAllowsArch returns whether the node selector and required node affinity of the pod allow it to be scheduled
+// on a node with the cpu architecture. Pods that don't select an architecture are allowed on every architecture.
+// The images of the pod aren't inspected, so pods with single architecture images must select their architecture
+func PodAllowsArch(pod *v1.Pod, arch string) bool {
+	return podAllowsLabelValue(pod, arch, ArchLabelKey, BetaArchLabelKey)
+}
+
+// firstLabel returns the value of the first of the keys that is set in the labels
+func firstLabel(labels map[string]string, keys ...string) string {
+	for _, key := range keys {
+		if value, ok := labels[key]; ok {
+			return value
+		}
+	}
+	return ""
+}
+
+// podAllowsLabelValue returns whether a node with the value for the label keys satisfies the node selector and
+// the required node affinity of the pod. Only the expressions on the label keys are considered
+func podAllowsLabelValue(pod *v1.Pod, value string, keys ...string) bool {
+	for _, key := range keys {
+		if selected, ok := pod.Spec.NodeSelector[key]; ok && selected != value {
+			return false
+		}
+	}
+
+	affinity := pod.Spec.Affinity
+	if affinity == nil || affinity.NodeAffinity == nil || affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		return true
+	}
+	terms := affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+	if len(terms) == 0 {
+		return true
+	}
+	// node selector terms are ORed, so the value is allowed if any of the terms allow it
+	for _, term := range terms {
+		if termAllowsLabelValue(term, value, keys...) {
+			return true
+		}
+	}
+	return false
+}
+
+// termAllowsLabelValue returns whether every expression of the term on the label keys allows the value
+func termAllowsLabelValue(term v1.NodeSelectorTerm, value string, keys ...string) bool {
+	for _, expression := range term.MatchExpressions {
+		if !containsString(keys, expression.Key) {
+			continue
+		}
+		switch expression.Operator {
+		case v1.NodeSelectorOpIn:
+			if !containsString(expression.Values, value) {
+				return false
+			}
+		case v1.NodeSelectorOpNotIn:
+			if containsString(expression.Values, value) {
+				return false
+			}
+		case v1.NodeSelectorOpDoesNotExist:
+			return false
+		}
+	}
+	return true
+}
+
+// containsString returns whether the value is in the values
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,141 @@
+package k8s
+
+import (
+	"testing"
+
+	"github.com/atlassian/escalator/pkg/test"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestNodeOS(t *testing.T) {
+	windows := test.BuildTestNode(test.NodeOpts{LabelKey: OSLabelKey, LabelValue: OSWindows})
+	betaLinux := test.BuildTestNode(test.NodeOpts{LabelKey: BetaOSLabelKey, LabelValue: OSLinux})
+	unlabelled := test.BuildTestNode(test.NodeOpts{})
+
+	assert.Equal(t, OSWindows, NodeOS(windows))
+	assert.Equal(t, OSLinux, NodeOS(betaLinux))
+	assert.Equal(t, "", NodeOS(unlabelled))
+}
+
+func TestPodAllowsOS(t *testing.T) {
+	selector := test.BuildTestPod(test.PodOpts{NodeSelectorKey: OSLabelKey, NodeSelectorValue: OSWindows})
+	betaSelector := test.BuildTestPod(test.PodOpts{NodeSelectorKey: BetaOSLabelKey, NodeSelectorValue: OSLinux})
+	affinity := test.BuildTestPod(test.PodOpts{})
+	affinity.Spec.Affinity = &v1.Affinity{
+		NodeAffinity: &v1.NodeAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: &v1.NodeSelector{
+				NodeSelectorTerms: []v1.NodeSelectorTerm{
+					{
+						MatchExpressions: []v1.NodeSelectorRequirement{
+							{Key: OSLabelKey, Operator: v1.NodeSelectorOpNotIn, Values: []string{OSLinux}},
+						},
+					},
+				},
+			},
+		},
+	}
+	none := test.BuildTestPod(test.PodOpts{})
+
+	assert.True(t, PodAllowsOS(selector, OSWindows))
+	assert.False(t, PodAllowsOS(selector, OSLinux))
+	assert.True(t, PodAllowsOS(betaSelector, OSLinux))
+	assert.False(t, PodAllowsOS(betaSelector, OSWindows))
+	assert.True(t, PodAllowsOS(affinity, OSWindows))
+	assert.False(t, PodAllowsOS(affinity, OSLinux))
+	assert.True(t, PodAllowsOS(none, OSLinux))
+	assert.True(t, PodAllowsOS(none, OSWindows))
+}
+
+func TestNodeArch(t *testing.T) {
+	arm := test.BuildTestNode(test.NodeOpts{LabelKey: ArchLabelKey, LabelValue: ArchARM64})
+	betaAMD := test.BuildTestNode(test.NodeOpts{LabelKey: BetaArchLabelKey, LabelValue: ArchAMD64})
+	unlabelled := test.BuildTestNode(test.NodeOpts{})
+
+	assert.Equal(t, ArchARM64, NodeArch(arm))
+	assert.Equal(t, ArchAMD64, NodeArch(betaAMD))
+	assert.Equal(t, "", NodeArch(unlabelled))
+}
+
+func TestPodAllowsArch(t *testing.T) {
+	buildAffinityPod := func(terms ...v1.NodeSelectorTerm) *v1.Pod {
+		pod := test.BuildTestPod(test.PodOpts{})
+		pod.Spec.Affinity = &v1.Affinity{
+			NodeAffinity: &v1.NodeAffinity{
+				RequiredDuringSchedulingIgnoredDuringExecution: &v1.NodeSelector{NodeSelectorTerms: terms},
+			},
+		}
+		return pod
+	}
+	term := func(key string, operator v1.NodeSelectorOperator, values ...string) v1.NodeSelectorTerm {
+		return v1.NodeSelectorTerm{
+			MatchExpressions: []v1.NodeSelectorRequirement{{Key: key, Operator: operator, Values: values}},
+		}
+	}
+
+	tests := []struct {
+		name  string
+		pod   *v1.Pod
+		arm64 bool
+		amd64 bool
+	}{
+		{
+			"no selector",
+			test.BuildTestPod(test.PodOpts{}),
+			true,
+			true,
+		},
+		{
+			"node selector",
+			test.BuildTestPod(test.PodOpts{NodeSelectorKey: ArchLabelKey, NodeSelectorValue: ArchAMD64}),
+			false,
+			true,
+		},
+		{
+			"beta node selector",
+			test.BuildTestPod(test.PodOpts{NodeSelectorKey: BetaArchLabelKey, NodeSelectorValue: ArchARM64}),
+			true,
+			false,
+		},
+		{
+			"other node selector",
+			test.BuildTestPod(test.PodOpts{NodeSelectorKey: "customer", NodeSelectorValue: "shared"}),
+			true,
+			true,
+		},
+		{
+			"affinity in multiple architectures",
+			buildAffinityPod(term(ArchLabelKey, v1.NodeSelectorOpIn, ArchAMD64, ArchARM64)),
+			true,
+			true,
+		},
+		{
+			"affinity not in",
+			buildAffinityPod(term(ArchLabelKey, v1.NodeSelectorOpNotIn, ArchARM64)),
+			false,
+			true,
+		},
+		{
+			"affinity terms are ored",
+			buildAffinityPod(
+				term(ArchLabelKey, v1.NodeSelectorOpIn, ArchAMD64),
+				term("customer", v1.NodeSelectorOpIn, "shared"),
+			),
+			true,
+			true,
+		},
+		{
+			"affinity on other key",
+			buildAffinityPod(term("customer", v1.NodeSelectorOpIn, "shared")),
+			true,
+			true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.arm64, PodAllowsArch(tt.pod, ArchARM64))
+			assert.Equal(t, tt.amd64, PodAllowsArch(tt.pod, ArchAMD64))
+		})
+	}
+}
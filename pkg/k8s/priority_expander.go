@@ -0,0 +1,75 @@
+package k8s
+
+import (
+	"regexp"
+	"sort"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+)
+
+// PriorityExpanderConfigMapKey is the key of the priorities in the data of a cluster-autoscaler-priority-expander
+// ConfigMap
+const PriorityExpanderConfigMapKey = "priorities"
+
+// ExpanderPriority is a priority of the cluster autoscaler priority expander and the regular expressions of the node
+// group names that have it
+type ExpanderPriority struct {
+	Priority int
+	Patterns []*regexp.Regexp
+}
+
+// ExpanderPriorities are the priorities of a cluster-autoscaler-priority-expander ConfigMap, highest first
+type ExpanderPriorities []ExpanderPriority
+
+// ParseExpanderPriorities parses the priorities in the format of the cluster autoscaler priority expander, a yaml map
+// of priorities to lists of regular expressions of node group names, e.g. {10: [".*-spot-.*"], 1: [".*"]}
+func ParseExpanderPriorities(data string) (ExpanderPriorities, error) {
+	var raw map[int][]string
+	if err := yaml.Unmarshal([]byte(data), &raw); err != nil {
+		return nil, errors.Wrap(err, "failed to parse the priorities")
+	}
+
+	priorities := make(ExpanderPriorities, 0, len(raw))
+	for priority, expressions := range raw {
+		expanderPriority := ExpanderPriority{Priority: priority}
+		for _, expression := range expressions {
+			pattern, err := regexp.Compile(expression)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to compile %q of priority %v", expression, priority)
+			}
+			expanderPriority.Patterns = append(expanderPriority.Patterns, pattern)
+		}
+		priorities = append(priorities, expanderPriority)
+	}
+	sort.Slice(priorities, func(i, j int) bool { return priorities[i].Priority > priorities[j].Priority })
+	return priorities, nil
+}
+
+// Priority returns the highest priority with a regular expression matching the node group name, and whether any
+// matched
+func (p ExpanderPriorities) Priority(name string) (int, bool) {
+	for _, priority := range p {
+		for _, pattern := range priority.Patterns {
+			if pattern.MatchString(name) {
+				return priority.Priority, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// GetExpanderPriorities reads the priorities of the cluster-autoscaler-priority-expander ConfigMap
+func GetExpanderPriorities(client kubernetes.Interface, namespace string, name string) (ExpanderPriorities, error) {
+	configMap, err := client.CoreV1().ConfigMaps(namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get the priority expander config map %v/%v", namespace, name)
+	}
+	data, ok := configMap.Data[PriorityExpanderConfigMapKey]
+	if !ok {
+		return nil, errors.Errorf("priority expander config map %v/%v has no %v", namespace, name, PriorityExpanderConfigMapKey)
+	}
+	return ParseExpanderPriorities(data)
+}
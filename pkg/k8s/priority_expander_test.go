@@ -0,0 +1,68 @@
+package k8s
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const expanderPriorities = `
+10:
+  - .*-spot-.*
+  - ^gpu-.*
+50:
+  - .*-reserved-.*
+1:
+  - .*
+`
+
+func TestParseExpanderPriorities(t *testing.T) {
+	priorities, err := ParseExpanderPriorities(expanderPriorities)
+	require.NoError(t, err)
+	require.Len(t, priorities, 3)
+	assert.Equal(t, []int{50, 10, 1}, []int{priorities[0].Priority, priorities[1].Priority, priorities[2].Priority})
+
+	tests := []struct {
+		name      string
+		groupName string
+		want      int
+		wantOk    bool
+	}{
+		{"highest match wins", "batch-reserved-spot-a", 50, true},
+		{"second expression", "gpu-a", 10, true},
+		{"catch all", "shared", 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			priority, ok := priorities.Priority(tt.groupName)
+			assert.Equal(t, tt.want, priority)
+			assert.Equal(t, tt.wantOk, ok)
+		})
+	}
+
+	_, ok := ExpanderPriorities{}.Priority("shared")
+	assert.False(t, ok)
+
+	_, err = ParseExpanderPriorities("10: [\"(\"]")
+	assert.Error(t, err)
+	_, err = ParseExpanderPriorities("high: [\".*\"]")
+	assert.Error(t, err)
+}
+
+func TestGetExpanderPriorities(t *testing.T) {
+	client := fake.NewSimpleClientset(&v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster-autoscaler-priority-expander", Namespace: "kube-system"},
+		Data:       map[string]string{PriorityExpanderConfigMapKey: expanderPriorities},
+	})
+
+	priorities, err := GetExpanderPriorities(client, "kube-system", "cluster-autoscaler-priority-expander")
+	require.NoError(t, err)
+	assert.Len(t, priorities, 3)
+
+	_, err = GetExpanderPriorities(client, "kube-system", "missing")
+	assert.Error(t, err)
+}
@@ -0,0 +1,166 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/client-go/rest"
+)
+
+// Batch job queues are custom resources, so they are read as json with the raw rest client instead of generated clients
+const (
+	// KueueLocalQueuesPath lists the Kueue LocalQueues in all namespaces
+	KueueLocalQueuesPath = "/apis/kueue.x-k8s.io/v1beta1/localqueues"
+	// KueueWorkloadsPath lists the Kueue Workloads in all namespaces
+	KueueWorkloadsPath = "/apis/kueue.x-k8s.io/v1beta1/workloads"
+	// VolcanoPodGroupsPath lists the Volcano PodGroups in all namespaces
+	VolcanoPodGroupsPath = "/apis/scheduling.volcano.sh/v1beta1/podgroups"
+)
+
+// QueueDemand is the cpu and memory requested by the workloads waiting in a batch job queue
+type QueueDemand struct {
+	Workloads int
+	CPU       resource.Quantity
+	Memory    resource.Quantity
+}
+
+func (d *QueueDemand) add(requests apiv1.ResourceList, count int64) {
+	cpu := requests.Cpu()
+	memory := requests.Memory()
+	for i := int64(0); i < count; i++ {
+		d.CPU.Add(*cpu)
+		d.Memory.Add(*memory)
+	}
+}
+
+type kueueLocalQueueList struct {
+	Items []struct {
+		Metadata struct {
+			Name      string `json:"name"`
+			Namespace string `json:"namespace"`
+		} `json:"metadata"`
+		Spec struct {
+			ClusterQueue string `json:"clusterQueue"`
+		} `json:"spec"`
+	} `json:"items"`
+}
+
+type kueueWorkloadList struct {
+	Items []struct {
+		Metadata struct {
+			Namespace string `json:"namespace"`
+		} `json:"metadata"`
+		Spec struct {
+			QueueName string `json:"queueName"`
+			PodSets   []struct {
+				Count    int64                 `json:"count"`
+				Template apiv1.PodTemplateSpec `json:"template"`
+			} `json:"podSets"`
+		} `json:"spec"`
+		Status struct {
+			Conditions []struct {
+				Type   string `json:"type"`
+				Status string `json:"status"`
+			} `json:"conditions"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+type volcanoPodGroupList struct {
+	Items []struct {
+		Spec struct {
+			Queue        string             `json:"queue"`
+			MinResources apiv1.ResourceList `json:"minResources"`
+		} `json:"spec"`
+		Status struct {
+			Phase string `json:"phase"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+// getJSON gets the path from the api server and decodes the json response into into
+func getJSON(ctx context.Context, client rest.Interface, path string, into interface{}) error {
+	body, err := client.Get().AbsPath(path).Context(ctx).DoRaw()
+	if err != nil {
+		return errors.Wrapf(err, "failed to get %v", path)
+	}
+	return errors.Wrapf(json.Unmarshal(body, into), "failed to decode %v", path)
+}
+
+// GetKueueClusterQueueDemand returns the demand of the Kueue Workloads waiting for quota in the ClusterQueue.
+// Workloads are submitted to namespaced LocalQueues, which point at the ClusterQueue
+func GetKueueClusterQueueDemand(ctx context.Context, client rest.Interface, clusterQueue string) (QueueDemand, error) {
+	var demand QueueDemand
+
+	var localQueues kueueLocalQueueList
+	if err := getJSON(ctx, client, KueueLocalQueuesPath, &localQueues); err != nil {
+		return demand, err
+	}
+	inClusterQueue := make(map[string]bool)
+	for _, localQueue := range localQueues.Items {
+		if localQueue.Spec.ClusterQueue == clusterQueue {
+			inClusterQueue[localQueue.Metadata.Namespace+"/"+localQueue.Metadata.Name] = true
+		}
+	}
+
+	var workloads kueueWorkloadList
+	if err := getJSON(ctx, client, KueueWorkloadsPath, &workloads); err != nil {
+		return demand, err
+	}
+	for _, workload := range workloads.Items {
+		if !inClusterQueue[workload.Metadata.Namespace+"/"+workload.Spec.QueueName] {
+			continue
+		}
+		// workloads with reserved quota have been admitted and their pods are counted by the node group already
+		pending := true
+		for _, condition := range workload.Status.Conditions {
+			switch condition.Type {
+			case "QuotaReserved", "Admitted", "Finished":
+				if condition.Status == string(apiv1.ConditionTrue) {
+					pending = false
+				}
+			}
+		}
+		if !pending {
+			continue
+		}
+
+		demand.Workloads++
+		for _, podSet := range workload.Spec.PodSets {
+			pod := &apiv1.Pod{Spec: podSet.Template.Spec}
+			memRequest, cpuRequest, _ := CalculatePodsRequestsTotal([]*apiv1.Pod{pod})
+			demand.add(apiv1.ResourceList{apiv1.ResourceCPU: cpuRequest, apiv1.ResourceMemory: memRequest}, podSet.Count)
+		}
+	}
+	return demand, nil
+}
+
+// GetVolcanoQueueDemand returns the demand of the Volcano PodGroups in the queue that are waiting to be enqueued
+func GetVolcanoQueueDemand(ctx context.Context, client rest.Interface, queue string) (QueueDemand, error) {
+	var demand QueueDemand
+
+	var podGroups volcanoPodGroupList
+	if err := getJSON(ctx, client, VolcanoPodGroupsPath, &podGroups); err != nil {
+		return demand, err
+	}
+	for _, podGroup := range podGroups.Items {
+		// pod groups without a queue are in the default queue
+		podGroupQueue := podGroup.Spec.Queue
+		if len(podGroupQueue) == 0 {
+			podGroupQueue = "default"
+		}
+		if podGroupQueue != queue {
+			continue
+		}
+		// only Pending pod groups are waiting. Once Inqueue their pods are created and counted by the node group already
+		if podGroup.Status.Phase != "Pending" {
+			continue
+		}
+		demand.Workloads++
+		demand.add(podGroup.Spec.MinResources, 1)
+	}
+	return demand, nil
+}
@@ -0,0 +1,124 @@
+package k8s
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+)
+
+const kueueLocalQueues = `{"items": [
+  {"metadata": {"name": "team-a", "namespace": "a"}, "spec": {"clusterQueue": "batch"}},
+  {"metadata": {"name": "team-b", "namespace": "b"}, "spec": {"clusterQueue": "other"}}
+]}`
+
+const kueueWorkloads = `{"items": [
+  {
+    "metadata": {"namespace": "a"},
+    "spec": {"queueName": "team-a", "podSets": [
+      {"count": 3, "template": {"spec": {"containers": [{"resources": {"requests": {"cpu": "500m", "memory": "1Gi"}}}]}}},
+      {"count": 1, "template": {"spec": {"containers": [{"resources": {"requests": {"cpu": "1"}}}]}}}
+    ]}
+  },
+  {
+    "metadata": {"namespace": "a"},
+    "spec": {"queueName": "team-a", "podSets": [
+      {"count": 10, "template": {"spec": {"containers": [{"resources": {"requests": {"cpu": "1", "memory": "1Gi"}}}]}}}
+    ]},
+    "status": {"conditions": [{"type": "QuotaReserved", "status": "True"}]}
+  },
+  {
+    "metadata": {"namespace": "b"},
+    "spec": {"queueName": "team-b", "podSets": [
+      {"count": 10, "template": {"spec": {"containers": [{"resources": {"requests": {"cpu": "1", "memory": "1Gi"}}}]}}}
+    ]}
+  },
+  {
+    "metadata": {"namespace": "b"},
+    "spec": {"queueName": "team-a", "podSets": [
+      {"count": 10, "template": {"spec": {"containers": [{"resources": {"requests": {"cpu": "1", "memory": "1Gi"}}}]}}}
+    ]}
+  }
+]}`
+
+const volcanoPodGroups = `{"items": [
+  {"spec": {"queue": "batch", "minResources": {"cpu": "2", "memory": "4Gi"}}, "status": {"phase": "Pending"}},
+  {"spec": {"queue": "batch", "minResources": {"cpu": "1", "memory": "1Gi"}}, "status": {"phase": "Inqueue"}},
+  {"spec": {"queue": "batch", "minResources": {"cpu": "8", "memory": "8Gi"}}, "status": {"phase": "Running"}},
+  {"spec": {"minResources": {"cpu": "4", "memory": "4Gi"}}, "status": {"phase": "Pending"}}
+]}`
+
+func buildTestRESTClient(t *testing.T, responses map[string]string) (rest.Interface, func()) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := responses[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+
+	client, err := rest.UnversionedRESTClientFor(&rest.Config{
+		Host:          server.URL,
+		ContentConfig: rest.ContentConfig{NegotiatedSerializer: scheme.Codecs},
+	})
+	require.NoError(t, err)
+	return client, server.Close
+}
+
+func TestGetKueueClusterQueueDemand(t *testing.T) {
+	client, closeServer := buildTestRESTClient(t, map[string]string{
+		KueueLocalQueuesPath: kueueLocalQueues,
+		KueueWorkloadsPath:   kueueWorkloads,
+	})
+	defer closeServer()
+
+	demand, err := GetKueueClusterQueueDemand(context.Background(), client, "batch")
+	require.NoError(t, err)
+	assert.Equal(t, 1, demand.Workloads)
+	assert.Equal(t, int64(2500), demand.CPU.MilliValue())
+	assert.Equal(t, int64(3*1024*1024*1024), demand.Memory.Value())
+
+	demand, err = GetKueueClusterQueueDemand(context.Background(), client, "missing")
+	require.NoError(t, err)
+	assert.Equal(t, 0, demand.Workloads)
+	assert.True(t, demand.CPU.IsZero())
+
+	missingClient, closeMissingServer := buildTestRESTClient(t, map[string]string{})
+	defer closeMissingServer()
+	_, err = GetKueueClusterQueueDemand(context.Background(), missingClient, "batch")
+	assert.Error(t, err)
+}
+
+func TestGetVolcanoQueueDemand(t *testing.T) {
+	client, closeServer := buildTestRESTClient(t, map[string]string{VolcanoPodGroupsPath: volcanoPodGroups})
+	defer closeServer()
+
+	demand, err := GetVolcanoQueueDemand(context.Background(), client, "batch")
+	require.NoError(t, err)
+	assert.Equal(t, 1, demand.Workloads)
+	assert.Equal(t, int64(2000), demand.CPU.MilliValue())
+	assert.Equal(t, int64(4*1024*1024*1024), demand.Memory.Value())
+
+	demand, err = GetVolcanoQueueDemand(context.Background(), client, "default")
+	require.NoError(t, err)
+	assert.Equal(t, 1, demand.Workloads)
+	assert.Equal(t, int64(4000), demand.CPU.MilliValue())
+
+	missingClient, closeMissingServer := buildTestRESTClient(t, map[string]string{})
+	defer closeMissingServer()
+	_, err = GetVolcanoQueueDemand(context.Background(), missingClient, "batch")
+	assert.Error(t, err)
+
+	// the request is cancelled with the context
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = GetVolcanoQueueDemand(ctx, client, "batch")
+	assert.Error(t, err)
+}
@@ -0,0 +1,147 @@
+package k8s
+
+import (
+	"sort"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// RBACFeatures are the features escalator is run with that need permissions beyond reading pods and managing nodes
+type RBACFeatures struct {
+	// Namespace escalator runs in. Its events are created there
+	Namespace string
+	// NodeEvents is whether events are emitted on the nodes acted on, which are created in the default namespace
+	NodeEvents bool
+	// SchedulingFailures is whether the FailedScheduling events of pods are watched, in every namespace
+	SchedulingFailures bool
+	// PodDisruptionBudgets is whether the PodDisruptionBudgets are watched, in every namespace
+	PodDisruptionBudgets bool
+	// LeaderElectionNamespace and LeaderElectionName of the leader election config map. Disabled when the name is empty
+	LeaderElectionNamespace string
+	LeaderElectionName      string
+	// HeartbeatLeaseNamespace and HeartbeatLeaseName of the heartbeat lease. Disabled when the name is empty
+	HeartbeatLeaseNamespace string
+	HeartbeatLeaseName      string
+	// PriorityExpanderNamespace and PriorityExpanderName of the priority expander config map. Disabled when the name is
+	// empty
+	PriorityExpanderNamespace string
+	PriorityExpanderName      string
+	// Kueue and Volcano are whether any node group reads its queue_demand from them
+	Kueue   bool
+	Volcano bool
+	// DaemonSetDrainNamespaces are the namespaces of the daemonset_drain DaemonSets.
+	// An empty namespace allows deleting pods in every namespace
+	DaemonSetDrainNamespaces []string
+}
+
+// RBACRules are the permissions escalator needs, cluster wide and in each namespace
+type RBACRules struct {
+	Cluster    []rbacv1.PolicyRule
+	Namespaced map[string][]rbacv1.PolicyRule
+}
+
+// addNamespaced adds the rule to the rules of the namespace
+func (r *RBACRules) addNamespaced(namespace string, rule rbacv1.PolicyRule) {
+	if r.Namespaced == nil {
+		r.Namespaced = make(map[string][]rbacv1.PolicyRule)
+	}
+	r.Namespaced[namespace] = append(r.Namespaced[namespace], rule)
+}
+
+// Namespaces returns the namespaces that have rules, sorted
+func (r RBACRules) Namespaces() []string {
+	namespaces := make([]string, 0, len(r.Namespaced))
+	for namespace := range r.Namespaced {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+	return namespaces
+}
+
+// BuildRBACRules returns the minimal permissions escalator needs to run with the features
+func BuildRBACRules(features RBACFeatures) RBACRules {
+	rules := RBACRules{
+		Cluster: []rbacv1.PolicyRule{
+			// pods are watched to calculate the requests of the node groups
+			{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get", "list", "watch"}},
+			// nodes are tainted, annotated and deleted
+			{APIGroups: []string{""}, Resources: []string{"nodes"}, Verbs: []string{"get", "list", "watch", "update", "patch", "delete"}},
+		},
+	}
+
+	// events are emitted on the escalator pod and the leader election config map
+	rules.addNamespaced(features.Namespace, rbacv1.PolicyRule{
+		APIGroups: []string{""}, Resources: []string{"events"}, Verbs: []string{"create", "update", "patch"},
+	})
+
+	if features.NodeEvents && features.Namespace != metav1.NamespaceDefault {
+		rules.addNamespaced(metav1.NamespaceDefault, rbacv1.PolicyRule{
+			APIGroups: []string{""}, Resources: []string{"events"}, Verbs: []string{"create", "update", "patch"},
+		})
+	}
+
+	if len(features.LeaderElectionName) > 0 {
+		// create can't be limited to a name
+		rules.addNamespaced(features.LeaderElectionNamespace, rbacv1.PolicyRule{
+			APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"create"},
+		})
+		rules.addNamespaced(features.LeaderElectionNamespace, rbacv1.PolicyRule{
+			APIGroups: []string{""}, Resources: []string{"configmaps"}, ResourceNames: []string{features.LeaderElectionName}, Verbs: []string{"get", "update"},
+		})
+		if features.LeaderElectionNamespace != features.Namespace {
+			rules.addNamespaced(features.LeaderElectionNamespace, rbacv1.PolicyRule{
+				APIGroups: []string{""}, Resources: []string{"events"}, Verbs: []string{"create", "update", "patch"},
+			})
+		}
+	}
+
+	if len(features.HeartbeatLeaseName) > 0 {
+		rules.addNamespaced(features.HeartbeatLeaseNamespace, rbacv1.PolicyRule{
+			APIGroups: []string{"coordination.k8s.io"}, Resources: []string{"leases"}, Verbs: []string{"create"},
+		})
+		rules.addNamespaced(features.HeartbeatLeaseNamespace, rbacv1.PolicyRule{
+			APIGroups: []string{"coordination.k8s.io"}, Resources: []string{"leases"}, ResourceNames: []string{features.HeartbeatLeaseName}, Verbs: []string{"get", "update"},
+		})
+	}
+
+	if len(features.PriorityExpanderName) > 0 {
+		rules.addNamespaced(features.PriorityExpanderNamespace, rbacv1.PolicyRule{
+			APIGroups: []string{""}, Resources: []string{"configmaps"}, ResourceNames: []string{features.PriorityExpanderName}, Verbs: []string{"get"},
+		})
+	}
+	if features.SchedulingFailures {
+		rules.Cluster = append(rules.Cluster, rbacv1.PolicyRule{
+			APIGroups: []string{""}, Resources: []string{"events"}, Verbs: []string{"get", "list", "watch"},
+		})
+	}
+	if features.PodDisruptionBudgets {
+		rules.Cluster = append(rules.Cluster, rbacv1.PolicyRule{
+			APIGroups: []string{"policy"}, Resources: []string{"poddisruptionbudgets"}, Verbs: []string{"get", "list", "watch"},
+		})
+	}
+	if features.Kueue {
+		rules.Cluster = append(rules.Cluster, rbacv1.PolicyRule{
+			APIGroups: []string{"kueue.x-k8s.io"}, Resources: []string{"localqueues", "workloads"}, Verbs: []string{"list"},
+		})
+	}
+	if features.Volcano {
+		rules.Cluster = append(rules.Cluster, rbacv1.PolicyRule{
+			APIGroups: []string{"scheduling.volcano.sh"}, Resources: []string{"podgroups"}, Verbs: []string{"list"},
+		})
+	}
+
+	drainNamespaces := make(map[string]bool, len(features.DaemonSetDrainNamespaces))
+	for _, namespace := range features.DaemonSetDrainNamespaces {
+		drainNamespaces[namespace] = true
+	}
+	deletePods := rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"delete"}}
+	if drainNamespaces[""] {
+		rules.Cluster = append(rules.Cluster, deletePods)
+	} else {
+		for namespace := range drainNamespaces {
+			rules.addNamespaced(namespace, deletePods)
+		}
+	}
+	return rules
+}
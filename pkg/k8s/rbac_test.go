@@ -0,0 +1,93 @@
+package k8s
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+// hasRule returns whether any of the rules grants the verb on the resource
+func hasRule(rules []rbacv1.PolicyRule, resource string, verb string) bool {
+	for _, rule := range rules {
+		for _, r := range rule.Resources {
+			for _, v := range rule.Verbs {
+				if r == resource && v == verb {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func TestBuildRBACRules(t *testing.T) {
+	t.Run("minimal", func(t *testing.T) {
+		rules := BuildRBACRules(RBACFeatures{Namespace: "kube-system"})
+		assert.True(t, hasRule(rules.Cluster, "pods", "list"))
+		assert.True(t, hasRule(rules.Cluster, "nodes", "update"))
+		assert.True(t, hasRule(rules.Cluster, "nodes", "delete"))
+		assert.False(t, hasRule(rules.Cluster, "pods", "delete"))
+		assert.False(t, hasRule(rules.Cluster, "workloads", "list"))
+		assert.False(t, hasRule(rules.Cluster, "events", "watch"))
+		assert.False(t, hasRule(rules.Cluster, "poddisruptionbudgets", "list"))
+		assert.Equal(t, []string{"kube-system"}, rules.Namespaces())
+		assert.True(t, hasRule(rules.Namespaced["kube-system"], "events", "create"))
+		assert.False(t, hasRule(rules.Namespaced["kube-system"], "configmaps", "create"))
+		assert.False(t, hasRule(rules.Namespaced["kube-system"], "leases", "create"))
+	})
+
+	t.Run("every feature", func(t *testing.T) {
+		rules := BuildRBACRules(RBACFeatures{
+			Namespace:                "escalator",
+			LeaderElectionNamespace:  "kube-system",
+			LeaderElectionName:       "escalator-leader-elect",
+			HeartbeatLeaseNamespace:  "escalator",
+			HeartbeatLeaseName:       "escalator-heartbeat",
+			Kueue:                    true,
+			Volcano:                  true,
+			DaemonSetDrainNamespaces: []string{"logging"},
+		})
+		assert.True(t, hasRule(rules.Cluster, "localqueues", "list"))
+		assert.True(t, hasRule(rules.Cluster, "podgroups", "list"))
+		assert.False(t, hasRule(rules.Cluster, "pods", "delete"))
+		assert.Equal(t, []string{"escalator", "kube-system", "logging"}, rules.Namespaces())
+		assert.True(t, hasRule(rules.Namespaced["kube-system"], "configmaps", "update"))
+		assert.True(t, hasRule(rules.Namespaced["kube-system"], "events", "create"))
+		assert.True(t, hasRule(rules.Namespaced["escalator"], "leases", "update"))
+		assert.True(t, hasRule(rules.Namespaced["logging"], "pods", "delete"))
+	})
+
+	t.Run("node events", func(t *testing.T) {
+		rules := BuildRBACRules(RBACFeatures{Namespace: "escalator", NodeEvents: true})
+		assert.Equal(t, []string{"default", "escalator"}, rules.Namespaces())
+		assert.True(t, hasRule(rules.Namespaced["default"], "events", "create"))
+	})
+
+	t.Run("scheduling failures", func(t *testing.T) {
+		rules := BuildRBACRules(RBACFeatures{Namespace: "escalator", SchedulingFailures: true})
+		assert.True(t, hasRule(rules.Cluster, "events", "list"))
+		assert.True(t, hasRule(rules.Cluster, "events", "watch"))
+		assert.Equal(t, []string{"escalator"}, rules.Namespaces())
+	})
+
+	t.Run("pod disruption budgets", func(t *testing.T) {
+		rules := BuildRBACRules(RBACFeatures{Namespace: "escalator", PodDisruptionBudgets: true})
+		assert.True(t, hasRule(rules.Cluster, "poddisruptionbudgets", "list"))
+		assert.True(t, hasRule(rules.Cluster, "poddisruptionbudgets", "watch"))
+		assert.False(t, hasRule(rules.Cluster, "events", "watch"))
+	})
+
+	t.Run("priority expander", func(t *testing.T) {
+		rules := BuildRBACRules(RBACFeatures{Namespace: "escalator", PriorityExpanderNamespace: "kube-system", PriorityExpanderName: "cluster-autoscaler-priority-expander"})
+		assert.Equal(t, []string{"escalator", "kube-system"}, rules.Namespaces())
+		assert.True(t, hasRule(rules.Namespaced["kube-system"], "configmaps", "get"))
+		assert.False(t, hasRule(rules.Namespaced["kube-system"], "configmaps", "update"))
+	})
+
+	t.Run("daemonset drain in every namespace", func(t *testing.T) {
+		rules := BuildRBACRules(RBACFeatures{Namespace: "kube-system", DaemonSetDrainNamespaces: []string{"logging", ""}})
+		assert.True(t, hasRule(rules.Cluster, "pods", "delete"))
+		assert.Equal(t, []string{"kube-system"}, rules.Namespaces())
+	})
+}
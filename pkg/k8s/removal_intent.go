@@ -0,0 +1,99 @@
+package k8s
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/atlassian/escalator/pkg/errorkind"
+	"github.com/pkg/errors"
+	apiv1 "k8s.io/api/core/v1"
+)
+
+// ToBeRemovedIntentAnnotationKey is the node annotation recording why the autoscaler marked the node and when it is
+// deleted by. It is in the node label domain of the escalator instance, see SetNodeLabelDomain
+var ToBeRemovedIntentAnnotationKey string
+
+// The reasons escalator marks a node to be removed for. Readers should treat reasons they don't know as
+// RemovalReasonScaleDown, as new reasons can be added
+const (
+	// RemovalReasonScaleDown is a node removed as the node group has more capacity than it needs
+	RemovalReasonScaleDown = "scale_down"
+	// RemovalReasonUnhealthy is a node with one of the unhealthy_node_conditions of its node group
+	RemovalReasonUnhealthy = "unhealthy"
+	// RemovalReasonMaintenance is a node replaced ahead of the maintenance the cloud provider scheduled for it
+	RemovalReasonMaintenance = "maintenance"
+	// RemovalReasonThirdPartyTaint is a node with a third party taint whose removal is prioritised
+	RemovalReasonThirdPartyTaint = "third_party_taint"
+	// RemovalReasonVersionSkew is a node on an outdated kubelet or OS image
+	RemovalReasonVersionSkew = "version_skew"
+	// RemovalReasonMaxNodeAge is a node recycled as it is older than the max_node_age of its node group
+	RemovalReasonMaxNodeAge = "max_node_age"
+	// RemovalReasonSpotInterruption is a node whose spot instance is being interrupted
+	RemovalReasonSpotInterruption = "spot_interruption"
+)
+
+// RemovalIntent is why escalator marked a node to be removed and when it is deleted by. It is recorded as json in the
+// ToBeRemovedIntentAnnotationKey annotation of the node, e.g. {"reason":"scale_down","expires":"2019-03-01T04:00:00Z"},
+// so admission webhooks and other controllers can act on it, e.g. reject long jobs on nodes about to be removed
+type RemovalIntent struct {
+	// Reason is one of the RemovalReason constants
+	Reason string `json:"reason"`
+	// Expires is when the hard delete grace period of the node ends. The node can be deleted any time before then once
+	// it is empty, and is deleted after it even if it still runs pods, unless the deletion is deferred, e.g. by a
+	// PodDisruptionBudget
+	Expires time.Time `json:"expires"`
+}
+
+// Remaining returns how long is left until the intent expires at now, or 0 once it has
+func (i RemovalIntent) Remaining(now time.Time) time.Duration {
+	if remaining := i.Expires.Sub(now); remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+// EncodeRemovalIntent encodes the intent into the value of the ToBeRemovedIntentAnnotationKey annotation. The expiry is
+// written in UTC to the second
+func EncodeRemovalIntent(intent RemovalIntent) string {
+	intent.Expires = intent.Expires.UTC().Truncate(time.Second)
+	value, _ := json.Marshal(intent)
+	return string(value)
+}
+
+// ParseRemovalIntent decodes the value of the ToBeRemovedIntentAnnotationKey annotation. Unknown reasons are kept
+func ParseRemovalIntent(value string) (RemovalIntent, error) {
+	var intent RemovalIntent
+	if err := json.Unmarshal([]byte(value), &intent); err != nil {
+		return RemovalIntent{}, errorkind.Wrap(errorkind.Validation, err, fmt.Sprintf("invalid removal intent %q", value))
+	}
+	if len(intent.Reason) == 0 || intent.Expires.IsZero() {
+		return RemovalIntent{}, errorkind.New(errorkind.Validation, "removal intent %q must have a reason and an expiry", value)
+	}
+	return intent, nil
+}
+
+// GetRemovalIntent returns the removal intent of the node and whether it has one. Nodes that aren't marked to be
+// removed never have one, even if the annotation was left behind
+func GetRemovalIntent(node *apiv1.Node) (RemovalIntent, bool, error) {
+	value, ok := node.Annotations[ToBeRemovedIntentAnnotationKey]
+	if !ok || !MarkedToBeRemoved(node) {
+		return RemovalIntent{}, false, nil
+	}
+	intent, err := ParseRemovalIntent(value)
+	if err != nil {
+		return RemovalIntent{}, false, errors.Wrapf(err, "invalid %v annotation on node %v", ToBeRemovedIntentAnnotationKey, node.Name)
+	}
+	return intent, true, nil
+}
+
+// setRemovalIntent records the removal intent on the node, when it has a reason
+func setRemovalIntent(node *apiv1.Node, intent RemovalIntent) {
+	if len(intent.Reason) == 0 {
+		return
+	}
+	if node.Annotations == nil {
+		node.Annotations = make(map[string]string)
+	}
+	node.Annotations[ToBeRemovedIntentAnnotationKey] = EncodeRemovalIntent(intent)
+}
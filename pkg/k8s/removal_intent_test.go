@@ -0,0 +1,91 @@
+package k8s
+
+import (
+	"testing"
+	"time"
+
+	"github.com/atlassian/escalator/pkg/errorkind"
+	"github.com/atlassian/escalator/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apiv1 "k8s.io/api/core/v1"
+)
+
+func TestEncodeRemovalIntent(t *testing.T) {
+	sydney := time.FixedZone("AEDT", 11*60*60)
+	intent := RemovalIntent{Reason: RemovalReasonScaleDown, Expires: time.Date(2019, 3, 1, 15, 0, 0, 500, sydney)}
+	value := EncodeRemovalIntent(intent)
+	assert.Equal(t, `{"reason":"scale_down","expires":"2019-03-01T04:00:00Z"}`, value)
+
+	parsed, err := ParseRemovalIntent(value)
+	require.NoError(t, err)
+	assert.Equal(t, RemovalReasonScaleDown, parsed.Reason)
+	assert.True(t, time.Date(2019, 3, 1, 4, 0, 0, 0, time.UTC).Equal(parsed.Expires))
+}
+
+func TestParseRemovalIntent(t *testing.T) {
+	intent, err := ParseRemovalIntent(`{"reason":"some_future_reason","expires":"2019-03-01T04:00:00Z","extra":1}`)
+	assert.NoError(t, err)
+	assert.Equal(t, "some_future_reason", intent.Reason)
+
+	for _, invalid := range []string{"", "scale_down", `{"reason":"scale_down"}`, `{"expires":"2019-03-01T04:00:00Z"}`, `{"reason":"scale_down","expires":"tomorrow"}`} {
+		_, err := ParseRemovalIntent(invalid)
+		assert.Error(t, err, invalid)
+		assert.Equal(t, errorkind.Validation, errorkind.Of(err), invalid)
+	}
+}
+
+func TestRemovalIntentRemaining(t *testing.T) {
+	now := time.Date(2019, 3, 1, 4, 0, 0, 0, time.UTC)
+	intent := RemovalIntent{Reason: RemovalReasonScaleDown, Expires: now.Add(time.Hour)}
+	assert.Equal(t, time.Hour, intent.Remaining(now))
+	assert.Equal(t, time.Duration(0), intent.Remaining(now.Add(2*time.Hour)))
+}
+
+func TestGetRemovalIntent(t *testing.T) {
+	value := `{"reason":"max_node_age","expires":"2019-03-01T04:00:00Z"}`
+
+	marked := test.BuildTestNode(test.NodeOpts{Tainted: true})
+	marked.Annotations = map[string]string{ToBeRemovedIntentAnnotationKey: value}
+	intent, ok, err := GetRemovalIntent(marked)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, RemovalReasonMaxNodeAge, intent.Reason)
+
+	// left behind on a node that isn't marked any more
+	unmarked := test.BuildTestNode(test.NodeOpts{})
+	unmarked.Annotations = map[string]string{ToBeRemovedIntentAnnotationKey: value}
+	_, ok, err = GetRemovalIntent(unmarked)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	// marked without a reason
+	_, ok, err = GetRemovalIntent(test.BuildTestNode(test.NodeOpts{Tainted: true}))
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	marked.Annotations[ToBeRemovedIntentAnnotationKey] = "scale_down"
+	_, ok, err = GetRemovalIntent(marked)
+	assert.Error(t, err)
+	assert.False(t, ok)
+}
+
+func TestMarkToBeRemoved_RemovalIntent(t *testing.T) {
+	node := test.BuildTestNode(test.NodeOpts{})
+	fakeClient, updatedNodes := buildFakeClientAndUpdateChannel(node)
+
+	expires := time.Now().Add(time.Hour).Truncate(time.Second)
+	updated, err := MarkToBeRemoved(node, fakeClient, apiv1.TaintEffectNoSchedule, true, false, RemovalIntent{Reason: RemovalReasonUnhealthy, Expires: expires}, NewTaintBudget(1))
+	require.NoError(t, err)
+	assert.Equal(t, updated.Name, getStringFromChan(updatedNodes))
+	intent, ok, err := GetRemovalIntent(updated)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, RemovalReasonUnhealthy, intent.Reason)
+	assert.True(t, expires.Equal(intent.Expires))
+
+	updated, err = DeleteToBeRemovedTaint(updated, fakeClient)
+	assert.NoError(t, err)
+	assert.Equal(t, updated.Name, getStringFromChan(updatedNodes))
+	assert.NotContains(t, updated.Annotations, ToBeRemovedIntentAnnotationKey)
+}
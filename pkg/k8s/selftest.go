@@ -0,0 +1,96 @@
+package k8s
+
+import (
+	"encoding/json"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+// SelfTestTaintKey is the key of the taint the selftest command adds to and removes from its test node. The taint has
+// the PreferNoSchedule effect so it doesn't change where pods run, and isn't spent against a taint budget
+// It is in the node label domain of the escalator instance, see SetNodeLabelDomain
+var SelfTestTaintKey string
+
+// AddSelfTestTaint adds the selftest taint to the node
+// returns the latest successful update of the node
+func AddSelfTestTaint(node *apiv1.Node, client kubernetes.Interface) (*apiv1.Node, error) {
+	// fetch the latest version of the node to avoid conflict
+	updatedNode, err := client.CoreV1().Nodes().Get(node.Name, metav1.GetOptions{})
+	if err != nil || updatedNode == nil {
+		return node, wrapNodeError(err, "failed to get node %v", node.Name)
+	}
+	for _, taint := range updatedNode.Spec.Taints {
+		if taint.Key == SelfTestTaintKey {
+			return updatedNode, nil
+		}
+	}
+
+	updatedNode.Spec.Taints = append(updatedNode.Spec.Taints, apiv1.Taint{
+		Key:    SelfTestTaintKey,
+		Effect: apiv1.TaintEffectPreferNoSchedule,
+	})
+	updatedNodeWithTaint, err := client.CoreV1().Nodes().Update(updatedNode)
+	if err != nil || updatedNodeWithTaint == nil {
+		return updatedNode, wrapNodeError(err, "failed to update node %v after adding the selftest taint", updatedNode.Name)
+	}
+	return updatedNodeWithTaint, nil
+}
+
+// DeleteSelfTestTaint removes the selftest taint from the node if it exists
+// returns the latest successful update of the node
+func DeleteSelfTestTaint(node *apiv1.Node, client kubernetes.Interface) (*apiv1.Node, error) {
+	// fetch the latest version of the node to avoid conflict
+	updatedNode, err := client.CoreV1().Nodes().Get(node.Name, metav1.GetOptions{})
+	if err != nil || updatedNode == nil {
+		return node, wrapNodeError(err, "failed to get node %v", node.Name)
+	}
+
+	taints := make([]apiv1.Taint, 0, len(updatedNode.Spec.Taints))
+	for _, taint := range updatedNode.Spec.Taints {
+		if taint.Key != SelfTestTaintKey {
+			taints = append(taints, taint)
+		}
+	}
+	if len(taints) == len(updatedNode.Spec.Taints) {
+		return updatedNode, nil
+	}
+	updatedNode.Spec.Taints = taints
+
+	updatedNodeWithoutTaint, err := client.CoreV1().Nodes().Update(updatedNode)
+	if err != nil || updatedNodeWithoutTaint == nil {
+		return updatedNode, wrapNodeError(err, "failed to update node %v after deleting the selftest taint", updatedNode.Name)
+	}
+	return updatedNodeWithoutTaint, nil
+}
+
+// DryRunPatchNode checks the node can be updated with a server side dry run patch, which changes nothing
+func DryRunPatchNode(node *apiv1.Node, client kubernetes.Interface) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{SelfTestTaintKey: "dry-run"},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return client.CoreV1().RESTClient().Patch(types.MergePatchType).
+		Resource("nodes").
+		Name(node.Name).
+		Param("dryRun", metav1.DryRunAll).
+		Body(patch).
+		Do().
+		Error()
+}
+
+// DryRunDeleteNode checks the node can be deleted with a server side dry run delete, which deletes nothing
+func DryRunDeleteNode(node *apiv1.Node, client kubernetes.Interface) error {
+	return client.CoreV1().RESTClient().Delete().
+		Resource("nodes").
+		Name(node.Name).
+		Param("dryRun", metav1.DryRunAll).
+		Do().
+		Error()
+}
@@ -0,0 +1,37 @@
+package k8s
+
+import (
+	"testing"
+
+	"github.com/atlassian/escalator/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelfTestTaint(t *testing.T) {
+	node := test.BuildTestNode(test.NodeOpts{Name: "n1", CPU: 1000, Mem: 1000})
+	client, updates := buildFakeClientAndUpdateChannel(node)
+
+	taintedNode, err := AddSelfTestTaint(node, client)
+	require.NoError(t, err)
+	assert.Equal(t, "n1", getStringFromChan(updates))
+	assert.Len(t, taintedNode.Spec.Taints, 1)
+	assert.Equal(t, SelfTestTaintKey, taintedNode.Spec.Taints[0].Key)
+	// nodes the selftest taints aren't marked for removal
+	assert.False(t, MarkedToBeRemoved(taintedNode))
+
+	// the taint is only added once
+	_, err = AddSelfTestTaint(taintedNode, client)
+	require.NoError(t, err)
+	assert.Equal(t, "nothing returned", getStringFromChan(updates))
+
+	untainted, err := DeleteSelfTestTaint(taintedNode, client)
+	require.NoError(t, err)
+	assert.Equal(t, "n1", getStringFromChan(updates))
+	assert.Empty(t, untainted.Spec.Taints)
+
+	// nothing to remove
+	_, err = DeleteSelfTestTaint(untainted, client)
+	require.NoError(t, err)
+	assert.Equal(t, "nothing returned", getStringFromChan(updates))
+}
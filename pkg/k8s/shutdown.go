@@ -0,0 +1,27 @@
+package k8s
+
+import (
+	apiv1 "k8s.io/api/core/v1"
+)
+
+const (
+	// OutOfServiceTaintKey is the taint put on nodes that are shut down and won't come back, e.g. by cloud maintenance
+	OutOfServiceTaintKey = "node.kubernetes.io/out-of-service"
+	// ShutdownTaintKey is the taint the cloud node lifecycle controller puts on nodes whose instance is shut down
+	ShutdownTaintKey = "node.cloudprovider.kubernetes.io/shutdown"
+)
+
+// ShutdownTaintKeys are the taints that mark a node as being shut down outside of Escalator
+var ShutdownTaintKeys = []string{OutOfServiceTaintKey, ShutdownTaintKey}
+
+// GetShutdownTaint returns the taint marking the node as being shut down outside of Escalator, if it has one
+func GetShutdownTaint(node *apiv1.Node) (apiv1.Taint, bool) {
+	for _, taint := range node.Spec.Taints {
+		for _, key := range ShutdownTaintKeys {
+			if taint.Key == key {
+				return taint, true
+			}
+		}
+	}
+	return apiv1.Taint{}, false
+}
@@ -0,0 +1,32 @@
+package k8s
+
+import (
+	"testing"
+
+	"github.com/atlassian/escalator/pkg/test"
+	"github.com/stretchr/testify/assert"
+	apiv1 "k8s.io/api/core/v1"
+)
+
+func TestGetShutdownTaint(t *testing.T) {
+	tests := []struct {
+		name   string
+		taints []apiv1.Taint
+		want   string
+		ok     bool
+	}{
+		{"no taints", nil, "", false},
+		{"escalator taint", []apiv1.Taint{{Key: ToBeRemovedByAutoscalerKey, Effect: apiv1.TaintEffectNoSchedule}}, "", false},
+		{"out of service", []apiv1.Taint{{Key: OutOfServiceTaintKey, Value: "nodeshutdown", Effect: apiv1.TaintEffectNoExecute}}, OutOfServiceTaintKey, true},
+		{"cloud shutdown", []apiv1.Taint{{Key: "other"}, {Key: ShutdownTaintKey, Effect: apiv1.TaintEffectNoSchedule}}, ShutdownTaintKey, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := test.BuildTestNode(test.NodeOpts{})
+			node.Spec.Taints = tt.taints
+			taint, ok := GetShutdownTaint(node)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.want, taint.Key)
+		})
+	}
+}
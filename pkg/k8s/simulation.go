@@ -0,0 +1,268 @@
+package k8s
+
+import (
+	"sort"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	v1helper "k8s.io/kubernetes/pkg/apis/core/v1/helper"
+	"k8s.io/kubernetes/pkg/scheduler/cache"
+)
+
+// SchedulingSimulator is a very small in-memory scheduler used to check if pods could be rescheduled onto a set of
+// nodes. It only considers allocatable cpu/memory/pods, taints, node selectors, required node affinity and required
+// pod anti-affinity.
+// Placements made by successful simulations are remembered, so the same capacity is not promised to more than one
+// set of pods.
+type SchedulingSimulator struct {
+	nodeInfos []*cache.NodeInfo
+}
+
+// NewSchedulingSimulator creates a simulator for the nodes. nodeInfoMap provides the pods already running on the nodes
+func NewSchedulingSimulator(nodes []*v1.Node, nodeInfoMap map[string]*cache.NodeInfo) *SchedulingSimulator {
+	nodeInfos := make([]*cache.NodeInfo, 0, len(nodes))
+	for _, node := range nodes {
+		if nodeInfo, ok := nodeInfoMap[node.Name]; ok {
+			nodeInfos = append(nodeInfos, nodeInfo.Clone())
+			continue
+		}
+		nodeInfo := cache.NewNodeInfo()
+		nodeInfo.SetNode(node)
+		nodeInfos = append(nodeInfos, nodeInfo)
+	}
+	return &SchedulingSimulator{nodeInfos}
+}
+
+// TrySchedule simulates scheduling all of the pods. Pods are placed largest first onto the first node they fit on.
+// If every pod fits the placements are kept and true is returned, otherwise the simulator is left unchanged
+func (s *SchedulingSimulator) TrySchedule(pods []*v1.Pod) bool {
+	if len(pods) == 0 {
+		return true
+	}
+
+	// work on copies so a failed simulation doesn't consume capacity
+	nodeInfos := make([]*cache.NodeInfo, 0, len(s.nodeInfos))
+	for _, nodeInfo := range s.nodeInfos {
+		nodeInfos = append(nodeInfos, nodeInfo.Clone())
+	}
+
+	sorted := make([]*v1.Pod, len(pods))
+	copy(sorted, pods)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		iCPU, iMem := podRequests(sorted[i])
+		jCPU, jMem := podRequests(sorted[j])
+		if iCPU != jCPU {
+			return iCPU > jCPU
+		}
+		return iMem > jMem
+	})
+
+	for _, pod := range sorted {
+		placed := false
+		for _, nodeInfo := range nodeInfos {
+			if PodFitsNode(pod, nodeInfo) && podSatisfiesAntiAffinity(pod, nodeInfo.Node(), nodeInfos) {
+				nodeInfo.AddPod(pod)
+				placed = true
+				break
+			}
+		}
+		if !placed {
+			return false
+		}
+	}
+
+	s.nodeInfos = nodeInfos
+	return true
+}
+
+// PodFitsNode returns whether the pod could be scheduled onto the node given the pods already on it
+func PodFitsNode(pod *v1.Pod, nodeInfo *cache.NodeInfo) bool {
+	node := nodeInfo.Node()
+	if node == nil || node.Spec.Unschedulable {
+		return false
+	}
+	return podFitsResources(pod, nodeInfo) &&
+		podToleratesNodeTaints(pod, node) &&
+		podMatchesNodeSelector(pod, node)
+}
+
+// podFitsResources checks the free allocatable cpu, memory and pod count of the node
+func podFitsResources(pod *v1.Pod, nodeInfo *cache.NodeInfo) bool {
+	allocatable := nodeInfo.AllocatableResource()
+	requested := nodeInfo.RequestedResource()
+
+	if allocatable.AllowedPodNumber > 0 && len(nodeInfo.Pods())+1 > allocatable.AllowedPodNumber {
+		return false
+	}
+
+	cpu, mem := podRequests(pod)
+	return requested.MilliCPU+cpu <= allocatable.MilliCPU && requested.Memory+mem <= allocatable.Memory
+}
+
+// podToleratesNodeTaints checks that the pod tolerates all of the NoSchedule and NoExecute taints on the node
+func podToleratesNodeTaints(pod *v1.Pod, node *v1.Node) bool {
+	for i := range node.Spec.Taints {
+		taint := &node.Spec.Taints[i]
+		if taint.Effect == v1.TaintEffectPreferNoSchedule {
+			continue
+		}
+		tolerated := false
+		for j := range pod.Spec.Tolerations {
+			if pod.Spec.Tolerations[j].ToleratesTaint(taint) {
+				tolerated = true
+				break
+			}
+		}
+		if !tolerated {
+			return false
+		}
+	}
+	return true
+}
+
+// podMatchesNodeSelector checks the node selector and required node affinity of the pod against the node labels
+func podMatchesNodeSelector(pod *v1.Pod, node *v1.Node) bool {
+	nodeLabels := labels.Set(node.Labels)
+	if len(pod.Spec.NodeSelector) > 0 && !labels.SelectorFromSet(pod.Spec.NodeSelector).Matches(nodeLabels) {
+		return false
+	}
+
+	affinity := pod.Spec.Affinity
+	if affinity == nil || affinity.NodeAffinity == nil || affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		return true
+	}
+
+	// node selector terms are ORed, match expressions within a term are ANDed
+	terms := affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+	for _, term := range terms {
+		if len(term.MatchExpressions) == 0 {
+			continue
+		}
+		selector, err := v1helper.NodeSelectorRequirementsAsSelector(term.MatchExpressions)
+		if err != nil {
+			continue
+		}
+		if selector.Matches(nodeLabels) {
+			return true
+		}
+	}
+	return false
+}
+
+// podSatisfiesAntiAffinity checks the required pod anti-affinity of the pod against the pods in the same topology
+// domain as the node, and the required pod anti-affinity of those pods against the pod. e.g. a one replica per node
+// service can't be placed on a node that already runs a replica
+func podSatisfiesAntiAffinity(pod *v1.Pod, node *v1.Node, nodeInfos []*cache.NodeInfo) bool {
+	for _, term := range requiredAntiAffinityTerms(pod) {
+		for _, existing := range podsInTopology(node, term.TopologyKey, nodeInfos) {
+			if antiAffinityTermMatches(term, pod, existing) {
+				return false
+			}
+		}
+	}
+
+	// existing pods with anti-affinity to the pod also stop it being placed
+	for _, nodeInfo := range nodeInfos {
+		for _, existing := range nodeInfo.Pods() {
+			for _, term := range requiredAntiAffinityTerms(existing) {
+				if sameTopology(node, nodeInfo.Node(), term.TopologyKey) && antiAffinityTermMatches(term, existing, pod) {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+// requiredAntiAffinityTerms returns the required pod anti-affinity terms of the pod
+func requiredAntiAffinityTerms(pod *v1.Pod) []v1.PodAffinityTerm {
+	affinity := pod.Spec.Affinity
+	if affinity == nil || affinity.PodAntiAffinity == nil {
+		return nil
+	}
+	return affinity.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution
+}
+
+// podsInTopology returns the pods on the nodes in the same topology domain as the node
+func podsInTopology(node *v1.Node, topologyKey string, nodeInfos []*cache.NodeInfo) []*v1.Pod {
+	var pods []*v1.Pod
+	for _, nodeInfo := range nodeInfos {
+		if sameTopology(node, nodeInfo.Node(), topologyKey) {
+			pods = append(pods, nodeInfo.Pods()...)
+		}
+	}
+	return pods
+}
+
+// sameTopology returns whether both nodes have the same value for the topology key
+func sameTopology(a, b *v1.Node, topologyKey string) bool {
+	if a == nil || b == nil || len(topologyKey) == 0 {
+		return false
+	}
+	aValue, ok := a.Labels[topologyKey]
+	if !ok {
+		return false
+	}
+	bValue, ok := b.Labels[topologyKey]
+	return ok && aValue == bValue
+}
+
+// antiAffinityTermMatches returns whether the anti-affinity term of owner matches the target pod.
+// The term only applies to pods in its namespaces, which default to the namespace of owner
+func antiAffinityTermMatches(term v1.PodAffinityTerm, owner *v1.Pod, target *v1.Pod) bool {
+	if owner == target {
+		return false
+	}
+	namespaces := term.Namespaces
+	if len(namespaces) == 0 {
+		namespaces = []string{owner.Namespace}
+	}
+	if !containsString(namespaces, target.Namespace) {
+		return false
+	}
+	if term.LabelSelector == nil {
+		return false
+	}
+	selector, err := metav1.LabelSelectorAsSelector(term.LabelSelector)
+	if err != nil {
+		return false
+	}
+	return selector.Matches(labels.Set(target.Labels))
+}
+
+// podRequests returns the milli cpu and memory bytes requested by the pod, the same as the scheduler: the larger of
+// the sum of its containers and its largest init container, as the init containers run one at a time before them
+func podRequests(pod *v1.Pod) (int64, int64) {
+	var cpu, mem int64
+	for _, container := range pod.Spec.Containers {
+		cpu += container.Resources.Requests.Cpu().MilliValue()
+		mem += container.Resources.Requests.Memory().Value()
+	}
+	for _, container := range pod.Spec.InitContainers {
+		if initCPU := container.Resources.Requests.Cpu().MilliValue(); initCPU > cpu {
+			cpu = initCPU
+		}
+		if initMem := container.Resources.Requests.Memory().Value(); initMem > mem {
+			mem = initMem
+		}
+	}
+	return cpu, mem
+}
+
+// NodeReschedulablePods returns the pods on the node that would need to be rescheduled if it were removed
+// daemonset pods are excluded as they are tied to the node
+func NodeReschedulablePods(node *v1.Node, nodeInfoMap map[string]*cache.NodeInfo) []*v1.Pod {
+	nodeInfo, ok := nodeInfoMap[node.Name]
+	if !ok {
+		return nil
+	}
+
+	var pods []*v1.Pod
+	for _, pod := range nodeInfo.Pods() {
+		if !PodIsDaemonSet(pod) {
+			pods = append(pods, pod)
+		}
+	}
+	return pods
+}
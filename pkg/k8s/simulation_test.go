@@ -0,0 +1,234 @@
+package k8s
+
+import (
+	"testing"
+
+	"github.com/atlassian/escalator/pkg/test"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSchedulingSimulator_TrySchedule(t *testing.T) {
+	tests := []struct {
+		name     string
+		nodes    []*v1.Node
+		existing []*v1.Pod
+		pods     []*v1.Pod
+		want     bool
+	}{
+		{
+			"no pods always fit",
+			nil,
+			nil,
+			nil,
+			true,
+		},
+		{
+			"pods fit in free capacity",
+			[]*v1.Node{
+				test.BuildTestNode(test.NodeOpts{Name: "n1", CPU: 1000, Mem: 1000}),
+			},
+			[]*v1.Pod{
+				test.BuildTestPod(test.PodOpts{NodeName: "n1", CPU: []int64{500}, Mem: []int64{500}}),
+			},
+			test.BuildTestPods(2, test.PodOpts{CPU: []int64{250}, Mem: []int64{250}}),
+			true,
+		},
+		{
+			"pods don't fit in free capacity",
+			[]*v1.Node{
+				test.BuildTestNode(test.NodeOpts{Name: "n1", CPU: 1000, Mem: 1000}),
+			},
+			[]*v1.Pod{
+				test.BuildTestPod(test.PodOpts{NodeName: "n1", CPU: []int64{500}, Mem: []int64{500}}),
+			},
+			test.BuildTestPods(3, test.PodOpts{CPU: []int64{250}, Mem: []int64{250}}),
+			false,
+		},
+		{
+			"pods spread across nodes",
+			test.BuildTestNodes(2, test.NodeOpts{CPU: 1000, Mem: 1000}),
+			nil,
+			test.BuildTestPods(4, test.PodOpts{CPU: []int64{500}, Mem: []int64{500}}),
+			true,
+		},
+		{
+			"untolerated taint",
+			[]*v1.Node{
+				test.BuildTestNode(test.NodeOpts{Name: "n1", CPU: 1000, Mem: 1000, Tainted: true}),
+			},
+			nil,
+			test.BuildTestPods(1, test.PodOpts{CPU: []int64{100}, Mem: []int64{100}}),
+			false,
+		},
+		{
+			"node selector matches",
+			[]*v1.Node{
+				test.BuildTestNode(test.NodeOpts{Name: "n1", CPU: 1000, Mem: 1000, LabelKey: "group", LabelValue: "a"}),
+			},
+			nil,
+			test.BuildTestPods(1, test.PodOpts{CPU: []int64{100}, Mem: []int64{100}, NodeSelectorKey: "group", NodeSelectorValue: "a"}),
+			true,
+		},
+		{
+			"node selector doesn't match",
+			[]*v1.Node{
+				test.BuildTestNode(test.NodeOpts{Name: "n1", CPU: 1000, Mem: 1000, LabelKey: "group", LabelValue: "a"}),
+			},
+			nil,
+			test.BuildTestPods(1, test.PodOpts{CPU: []int64{100}, Mem: []int64{100}, NodeSelectorKey: "group", NodeSelectorValue: "b"}),
+			false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodeInfoMap := CreateNodeNameToInfoMap(tt.existing, tt.nodes)
+			simulator := NewSchedulingSimulator(tt.nodes, nodeInfoMap)
+			assert.Equal(t, tt.want, simulator.TrySchedule(tt.pods))
+		})
+	}
+}
+
+func TestSchedulingSimulator_ConsumesCapacity(t *testing.T) {
+	nodes := []*v1.Node{
+		test.BuildTestNode(test.NodeOpts{Name: "n1", CPU: 1000, Mem: 1000}),
+	}
+	simulator := NewSchedulingSimulator(nodes, CreateNodeNameToInfoMap(nil, nodes))
+
+	pods := test.BuildTestPods(2, test.PodOpts{CPU: []int64{400}, Mem: []int64{400}})
+	assert.True(t, simulator.TrySchedule(pods))
+	// the capacity was promised to the first pods
+	assert.False(t, simulator.TrySchedule(pods))
+
+	// a failed simulation doesn't consume capacity
+	small := test.BuildTestPods(1, test.PodOpts{CPU: []int64{200}, Mem: []int64{200}})
+	assert.True(t, simulator.TrySchedule(small))
+}
+
+func TestSchedulingSimulator_InitContainers(t *testing.T) {
+	nodes := []*v1.Node{
+		test.BuildTestNode(test.NodeOpts{Name: "n1", CPU: 600, Mem: 1000}),
+	}
+
+	// the init container requests more than the containers, so the pod needs 800m while it starts
+	pod := test.BuildTestPod(test.PodOpts{CPU: []int64{200}, Mem: []int64{200}})
+	pod.Spec.InitContainers = []v1.Container{{
+		Resources: v1.ResourceRequirements{Requests: v1.ResourceList{
+			v1.ResourceCPU:    *resource.NewMilliQuantity(800, resource.DecimalSI),
+			v1.ResourceMemory: *resource.NewQuantity(100, resource.DecimalSI),
+		}},
+	}}
+	cpu, mem := podRequests(pod)
+	assert.Equal(t, int64(800), cpu)
+	assert.Equal(t, int64(200), mem)
+
+	// the containers alone would fit in the 600m of the node, the init container doesn't
+	simulator := NewSchedulingSimulator(nodes, CreateNodeNameToInfoMap(nil, nodes))
+	assert.False(t, simulator.TrySchedule([]*v1.Pod{pod}))
+	pod.Spec.InitContainers = nil
+	assert.True(t, simulator.TrySchedule([]*v1.Pod{pod}))
+}
+
+func TestSchedulingSimulator_AntiAffinity(t *testing.T) {
+	const hostname = "kubernetes.io/hostname"
+	buildNode := func(name string) *v1.Node {
+		return test.BuildTestNode(test.NodeOpts{Name: name, CPU: 1000, Mem: 1000, LabelKey: hostname, LabelValue: name})
+	}
+	// a one replica per node service
+	buildReplica := func(name string, nodeName string) *v1.Pod {
+		pod := test.BuildTestPod(test.PodOpts{Name: name, NodeName: nodeName, CPU: []int64{100}, Mem: []int64{100}})
+		pod.Labels = map[string]string{"app": "cache"}
+		pod.Spec.Affinity = &v1.Affinity{
+			PodAntiAffinity: &v1.PodAntiAffinity{
+				RequiredDuringSchedulingIgnoredDuringExecution: []v1.PodAffinityTerm{
+					{
+						LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "cache"}},
+						TopologyKey:   hostname,
+					},
+				},
+			},
+		}
+		return pod
+	}
+	// a pod selected by the replicas' anti-affinity without its own anti-affinity
+	buildOther := func(name string, nodeName string) *v1.Pod {
+		pod := test.BuildTestPod(test.PodOpts{Name: name, NodeName: nodeName, CPU: []int64{100}, Mem: []int64{100}})
+		pod.Labels = map[string]string{"app": "cache"}
+		return pod
+	}
+
+	tests := []struct {
+		name     string
+		nodes    []*v1.Node
+		existing []*v1.Pod
+		pods     []*v1.Pod
+		want     bool
+	}{
+		{
+			"replica fits on a node without a replica",
+			[]*v1.Node{buildNode("n1"), buildNode("n2")},
+			[]*v1.Pod{buildReplica("r1", "n1")},
+			[]*v1.Pod{buildReplica("r3", "")},
+			true,
+		},
+		{
+			"replica doesn't fit when every node has a replica",
+			[]*v1.Node{buildNode("n1"), buildNode("n2")},
+			[]*v1.Pod{buildReplica("r1", "n1"), buildReplica("r2", "n2")},
+			[]*v1.Pod{buildReplica("r3", "")},
+			false,
+		},
+		{
+			"replicas being rescheduled don't share a node",
+			[]*v1.Node{buildNode("n1")},
+			nil,
+			[]*v1.Pod{buildReplica("r1", ""), buildReplica("r2", "")},
+			false,
+		},
+		{
+			"existing replica's anti-affinity rejects a matching pod",
+			[]*v1.Node{buildNode("n1")},
+			[]*v1.Pod{buildReplica("r1", "n1")},
+			[]*v1.Pod{buildOther("o1", "")},
+			false,
+		},
+		{
+			"anti-affinity only applies in the same namespace",
+			[]*v1.Node{buildNode("n1")},
+			[]*v1.Pod{buildReplica("r1", "n1")},
+			func() []*v1.Pod {
+				pod := buildReplica("r2", "")
+				pod.Namespace = "other"
+				pod.Spec.Affinity.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution[0].Namespaces = []string{"other"}
+				return []*v1.Pod{pod}
+			}(),
+			true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodeInfoMap := CreateNodeNameToInfoMap(tt.existing, tt.nodes)
+			simulator := NewSchedulingSimulator(tt.nodes, nodeInfoMap)
+			assert.Equal(t, tt.want, simulator.TrySchedule(tt.pods))
+		})
+	}
+}
+
+func TestNodeReschedulablePods(t *testing.T) {
+	node := test.BuildTestNode(test.NodeOpts{Name: "n1"})
+	pods := []*v1.Pod{
+		test.BuildTestPod(test.PodOpts{Name: "p1", NodeName: "n1"}),
+		test.BuildTestPod(test.PodOpts{Name: "p2", NodeName: "n1", Owner: "DaemonSet"}),
+	}
+	nodeInfoMap := CreateNodeNameToInfoMap(pods, []*v1.Node{node})
+
+	reschedulable := NodeReschedulablePods(node, nodeInfoMap)
+	assert.Len(t, reschedulable, 1)
+	assert.Equal(t, "p1", reschedulable[0].Name)
+
+	assert.Nil(t, NodeReschedulablePods(test.BuildTestNode(test.NodeOpts{Name: "missing"}), nodeInfoMap))
+}
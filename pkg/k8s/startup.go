@@ -0,0 +1,25 @@
+package k8s
+
+import (
+	apiv1 "k8s.io/api/core/v1"
+)
+
+// UninitializedTaintKey is the taint the kubelet puts on nodes registered with an external cloud provider, until the
+// cloud controller manager has initialised them
+const UninitializedTaintKey = "node.cloudprovider.kubernetes.io/uninitialized"
+
+// DefaultStartupTaintKeys are the taints that mark a node as still starting up when a node group doesn't configure
+// its own
+var DefaultStartupTaintKeys = []string{UninitializedTaintKey}
+
+// GetStartupTaint returns the first taint of the node with one of the keys, marking the node as still starting up
+func GetStartupTaint(node *apiv1.Node, keys []string) (apiv1.Taint, bool) {
+	for _, taint := range node.Spec.Taints {
+		for _, key := range keys {
+			if taint.Key == key {
+				return taint, true
+			}
+		}
+	}
+	return apiv1.Taint{}, false
+}
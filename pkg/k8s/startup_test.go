@@ -0,0 +1,34 @@
+package k8s
+
+import (
+	"testing"
+
+	"github.com/atlassian/escalator/pkg/test"
+	"github.com/stretchr/testify/assert"
+	apiv1 "k8s.io/api/core/v1"
+)
+
+func TestGetStartupTaint(t *testing.T) {
+	tests := []struct {
+		name   string
+		taints []apiv1.Taint
+		keys   []string
+		want   string
+		ok     bool
+	}{
+		{"no taints", nil, DefaultStartupTaintKeys, "", false},
+		{"escalator taint", []apiv1.Taint{{Key: ToBeRemovedByAutoscalerKey, Effect: apiv1.TaintEffectNoSchedule}}, DefaultStartupTaintKeys, "", false},
+		{"uninitialized", []apiv1.Taint{{Key: UninitializedTaintKey, Value: "true", Effect: apiv1.TaintEffectNoSchedule}}, DefaultStartupTaintKeys, UninitializedTaintKey, true},
+		{"configured", []apiv1.Taint{{Key: "other"}, {Key: "node.cilium.io/agent-not-ready", Effect: apiv1.TaintEffectNoSchedule}}, []string{"node.cilium.io/agent-not-ready"}, "node.cilium.io/agent-not-ready", true},
+		{"none configured", []apiv1.Taint{{Key: UninitializedTaintKey, Effect: apiv1.TaintEffectNoSchedule}}, nil, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := test.BuildTestNode(test.NodeOpts{})
+			node.Spec.Taints = tt.taints
+			taint, ok := GetStartupTaint(node, tt.keys)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.want, taint.Key)
+		})
+	}
+}
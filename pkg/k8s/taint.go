@@ -2,9 +2,12 @@ package k8s
 
 import (
 	"fmt"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/atlassian/escalator/pkg/errorkind"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	apiv1 "k8s.io/api/core/v1"
@@ -15,9 +18,22 @@ import (
 // Utility functions that assist with the tainting of nodes
 // ----
 // Taint Scheme:
-// Key: atlassian.com/escalator
+// Key: atlassian.com/escalator, or <domain>/escalator with a node label domain
 // Value: time.Now().Unix()
 // Effect: NoSchedule | NoExecute | PreferNoSchedule
+// Annotation: atlassian.com/escalator-taint-version: TaintSchemaVersion
+// Annotation: atlassian.com/escalator-removal-intent: the RemovalIntent of the node as json
+//
+// Nodes can also be marked by cordoning them instead of, or as well as, tainting them:
+// Unschedulable: true
+// Annotation: atlassian.com/escalator-cordoned: time.Now().Unix()
+// Nodes cordoned without the annotation were cordoned by someone else and are never marked by Escalator
+//
+// Nodes tainted before the version annotation was added are version 0, which has the same encoding as version 1.
+// The removal intent is kept out of the taint value so readers of the taint time don't need to change. Nodes marked
+// without a reason, e.g. by older versions, don't have it.
+// If the key or the encoding of the value changes, bump TaintSchemaVersion, add a decoder for the old version to
+// decodeTaintTime and use `escalator migrate-taints` to rewrite existing taints
 
 // TaintEffectTypes a map of TaintEffect to boolean true used for validating supported taint types
 var TaintEffectTypes = map[apiv1.TaintEffect]bool{
@@ -27,97 +43,89 @@ var TaintEffectTypes = map[apiv1.TaintEffect]bool{
 }
 
 const (
-	// ToBeRemovedByAutoscalerKey specifies the key the autoscaler uses to taint nodes as MARKED
-	ToBeRemovedByAutoscalerKey = "atlassian.com/escalator"
 	// MaximumTaints we can taint at one time
 	MaximumTaints = 10
+
+	// TaintSchemaVersion is the version of the taint scheme written by this version of escalator
+	TaintSchemaVersion = 1
 )
 
+// The keys are in the node label domain of the escalator instance, see SetNodeLabelDomain
 var (
-	tainted      = 0
-	targetTaints = 0
-)
+	// ToBeRemovedByAutoscalerKey specifies the key the autoscaler uses to taint nodes as MARKED
+	ToBeRemovedByAutoscalerKey string
 
-// BeginTaintFailSafe locks the tainting function to taint a max of maximum nodes
-func BeginTaintFailSafe(target int) error {
-	if tainted != 0 {
-		return errors.New("failed to ensure taint lifecycle is valid")
-	}
-	targetTaints = target
-	return nil
-}
+	// TaintSchemaVersionAnnotationKey is the node annotation recording the version of the taint scheme
+	TaintSchemaVersionAnnotationKey string
 
-// IncrementTaintCount is used to increase the taint count. Exposed to use in dry mode testing
-func IncrementTaintCount() {
-	tainted++
-}
+	// ToBeRemovedCordonAnnotationKey is the node annotation recording when the autoscaler cordoned the node as MARKED
+	ToBeRemovedCordonAnnotationKey string
 
-// EndTaintFailSafe unlocks the tainting function and ensures proper use by programmer
-func EndTaintFailSafe(actualTainted int) error {
-	if tainted > MaximumTaints {
-		return fmt.Errorf("tainted nodes %v exceeded maximum of %v", tainted, MaximumTaints)
-	}
-	if tainted > actualTainted {
-		return fmt.Errorf("tainted nodes %v exceeded recorded of %v", tainted, actualTainted)
-	}
-	if tainted != targetTaints {
-		log.Warningf("tainted nodes %v differs from target of %v", tainted, targetTaints)
-	}
+	// EscalatorKeyPrefix is the prefix of the taint and annotation keys written by escalator
+	EscalatorKeyPrefix string
+)
 
-	tainted = 0
-	return nil
+// AddToBeRemovedTaint takes a k8s node and adds the ToBeRemovedByAutoscaler taint to the node
+// returns the most recent update of the node that is successful
+func AddToBeRemovedTaint(node *apiv1.Node, client kubernetes.Interface, taintEffect apiv1.TaintEffect, budget *TaintBudget) (*apiv1.Node, error) {
+	return MarkToBeRemoved(node, client, taintEffect, true, false, RemovalIntent{}, budget)
 }
 
-// AddToBeRemovedTaint takes a k8s node and adds the ToBeRemovedByAutoscaler taint to the node
+// MarkToBeRemoved takes a k8s node and marks it for removal by adding the ToBeRemovedByAutoscaler taint, cordoning it,
+// or both, and records the removal intent when it has a reason. Marking a node spends a single taint of the budget,
+// and nothing is marked once the budget is spent
 // returns the most recent update of the node that is successful
-func AddToBeRemovedTaint(node *apiv1.Node, client kubernetes.Interface, taintEffect apiv1.TaintEffect) (*apiv1.Node, error) {
-	if tainted > targetTaints {
-		log.Warning("Taint count exceeds the target set by the lock")
-	}
-	if tainted > MaximumTaints {
-		IncrementTaintCount()
-		return node, fmt.Errorf("Actual taints %v exceeded maximum of %v", tainted, MaximumTaints)
+func MarkToBeRemoved(node *apiv1.Node, client kubernetes.Interface, taintEffect apiv1.TaintEffect, taint bool, cordon bool, intent RemovalIntent, budget *TaintBudget) (*apiv1.Node, error) {
+	if err := budget.Allow(); err != nil {
+		return node, err
 	}
 
 	// fetch the latest version of the node to avoid conflict
 	updatedNode, err := client.CoreV1().Nodes().Get(node.Name, metav1.GetOptions{})
 	if err != nil || updatedNode == nil {
-		return node, fmt.Errorf("failed to get node %v: %v", node.Name, err)
+		return node, wrapNodeError(err, "failed to get node %v", node.Name)
 	}
 
-	// check if the taint already exists
-	var taintExists bool
-	for _, taint := range updatedNode.Spec.Taints {
-		if taint.Key == ToBeRemovedByAutoscalerKey {
-			taintExists = true
-			break
-		}
-	}
+	// check if the marks already exist
+	_, taintExists := GetToBeRemovedTaint(updatedNode)
+	cordonExists := CordonedToBeRemoved(updatedNode)
 
-	// don't need to re-add the taint
-	if taintExists {
+	// don't need to re-add the marks
+	if (!taint || taintExists) && (!cordon || cordonExists) {
 		log.Debugf("%v already present on node %v", ToBeRemovedByAutoscalerKey, updatedNode.Name)
 		return updatedNode, nil
 	}
 
-	effect := apiv1.TaintEffectNoSchedule
-	if len(taintEffect) > 0 {
-		effect = taintEffect
-	}
+	now := time.Now()
+	if taint && !taintExists {
+		effect := apiv1.TaintEffectNoSchedule
+		if len(taintEffect) > 0 {
+			effect = taintEffect
+		}
 
-	updatedNode.Spec.Taints = append(updatedNode.Spec.Taints, apiv1.Taint{
-		Key:    ToBeRemovedByAutoscalerKey,
-		Value:  fmt.Sprint(time.Now().Unix()),
-		Effect: effect,
-	})
+		updatedNode.Spec.Taints = append(updatedNode.Spec.Taints, apiv1.Taint{
+			Key:    ToBeRemovedByAutoscalerKey,
+			Value:  encodeTaintTime(now),
+			Effect: effect,
+		})
+		setTaintSchemaVersion(updatedNode)
+	}
+	if cordon && !cordonExists {
+		if updatedNode.Annotations == nil {
+			updatedNode.Annotations = make(map[string]string)
+		}
+		updatedNode.Spec.Unschedulable = true
+		updatedNode.Annotations[ToBeRemovedCordonAnnotationKey] = encodeTaintTime(now)
+	}
+	setRemovalIntent(updatedNode, intent)
 
 	updatedNodeWithTaint, err := client.CoreV1().Nodes().Update(updatedNode)
 	if err != nil || updatedNodeWithTaint == nil {
-		return updatedNode, fmt.Errorf("failed to update node %v after adding taint: %v", updatedNode.Name, err)
+		return updatedNode, wrapNodeError(err, "failed to update node %v after marking it to be removed", updatedNode.Name)
 	}
 
-	log.Infof("Successfully added taint on node %v", updatedNodeWithTaint.Name)
-	IncrementTaintCount()
+	log.Infof("Successfully marked node %v to be removed (taint: %v, cordon: %v)", updatedNodeWithTaint.Name, taint, cordon)
+	budget.Spend()
 	return updatedNodeWithTaint, nil
 }
 
@@ -132,45 +140,214 @@ func GetToBeRemovedTaint(node *apiv1.Node) (apiv1.Taint, bool) {
 	return apiv1.Taint{}, false
 }
 
-// GetToBeRemovedTime returns the time the node was tainted
+// CordonedToBeRemoved returns whether the node was cordoned by the autoscaler to mark it to be removed.
+// Nodes cordoned by someone else, or uncordoned since, aren't
+func CordonedToBeRemoved(node *apiv1.Node) bool {
+	_, ok := node.Annotations[ToBeRemovedCordonAnnotationKey]
+	return ok && node.Spec.Unschedulable
+}
+
+// MarkedToBeRemoved returns whether the node is tainted with the ToBeRemovedByAutoscalerKey taint or was cordoned by
+// the autoscaler
+func MarkedToBeRemoved(node *apiv1.Node) bool {
+	_, tainted := GetToBeRemovedTaint(node)
+	return tainted || CordonedToBeRemoved(node)
+}
+
+// GetToBeRemovedTime returns the time the node was tainted, or cordoned by the autoscaler when it isn't tainted
 // result will be nil if does not exist
 func GetToBeRemovedTime(node *apiv1.Node) (*time.Time, error) {
+	if !hasToBeRemovedTaint(node) && CordonedToBeRemoved(node) {
+		result, err := decodeTaintTime(TaintSchemaVersion, node.Annotations[ToBeRemovedCordonAnnotationKey])
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid %v annotation on node %v", ToBeRemovedCordonAnnotationKey, node.Name)
+		}
+		return &result, nil
+	}
 	if taint, ok := GetToBeRemovedTaint(node); ok {
-		timestamp, err := strconv.ParseInt(taint.Value, 10, 64)
+		version, err := GetTaintSchemaVersion(node)
+		if err != nil {
+			return nil, err
+		}
+		result, err := decodeTaintTime(version, taint.Value)
 		if err != nil {
 			return nil, err
 		}
-		result := time.Unix(timestamp, 0)
 		return &result, nil
 	}
 	return nil, nil
 }
 
-// DeleteToBeRemovedTaint removes the ToBeRemovedByAutoscaler taint fromt the node if it exists
+// hasToBeRemovedTaint returns whether the node is tainted with the ToBeRemovedByAutoscalerKey taint
+func hasToBeRemovedTaint(node *apiv1.Node) bool {
+	_, ok := GetToBeRemovedTaint(node)
+	return ok
+}
+
+// GetTaintSchemaVersion returns the version of the taint scheme the node was tainted with.
+// Nodes without the version annotation are version 0
+func GetTaintSchemaVersion(node *apiv1.Node) (int, error) {
+	value, ok := node.Annotations[TaintSchemaVersionAnnotationKey]
+	if !ok {
+		return 0, nil
+	}
+	version, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid taint schema version %q on node %v", value, node.Name)
+	}
+	if version > TaintSchemaVersion {
+		return version, errorkind.New(errorkind.Validation, "taint schema version %v on node %v is newer than the supported version %v", version, node.Name, TaintSchemaVersion)
+	}
+	return version, nil
+}
+
+// setTaintSchemaVersion records the current taint scheme version on the node
+func setTaintSchemaVersion(node *apiv1.Node) {
+	if node.Annotations == nil {
+		node.Annotations = make(map[string]string)
+	}
+	node.Annotations[TaintSchemaVersionAnnotationKey] = strconv.Itoa(TaintSchemaVersion)
+}
+
+// encodeTaintTime encodes the taint time into the taint value with the current taint scheme
+func encodeTaintTime(t time.Time) string {
+	return fmt.Sprint(t.Unix())
+}
+
+// decodeTaintTime decodes the taint time from the taint value written with the version of the taint scheme
+func decodeTaintTime(version int, value string) (time.Time, error) {
+	switch version {
+	case 0, 1:
+		timestamp, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return time.Unix(timestamp, 0), nil
+	default:
+		return time.Time{}, errorkind.New(errorkind.Validation, "unsupported taint schema version %v", version)
+	}
+}
+
+// TaintNeedsMigration returns whether the taint or the version annotation on the node need rewriting
+// with the current taint scheme
+func TaintNeedsMigration(node *apiv1.Node) bool {
+	_, tainted := GetToBeRemovedTaint(node)
+	value, annotated := node.Annotations[TaintSchemaVersionAnnotationKey]
+	if !tainted {
+		// the annotation is left behind on untainted nodes
+		return annotated
+	}
+	return value != strconv.Itoa(TaintSchemaVersion)
+}
+
+// MigrateToBeRemovedTaint rewrites the ToBeRemovedByAutoscaler taint on the node with the current taint scheme,
+// keeping the time the node was tainted. Version annotations left on untainted nodes are removed.
+// returns the latest successful update of the node and whether it was changed
+func MigrateToBeRemovedTaint(node *apiv1.Node, client kubernetes.Interface) (*apiv1.Node, bool, error) {
+	// fetch the latest version of the node to avoid conflict
+	updatedNode, err := client.CoreV1().Nodes().Get(node.Name, metav1.GetOptions{})
+	if err != nil || updatedNode == nil {
+		return node, false, wrapNodeError(err, "failed to get node %v", node.Name)
+	}
+	if !TaintNeedsMigration(updatedNode) {
+		return updatedNode, false, nil
+	}
+
+	if _, ok := GetToBeRemovedTaint(updatedNode); !ok {
+		delete(updatedNode.Annotations, TaintSchemaVersionAnnotationKey)
+	} else {
+		taintedTime, err := GetToBeRemovedTime(updatedNode)
+		if err != nil {
+			return updatedNode, false, errors.Wrapf(err, "failed to decode taint on node %v", updatedNode.Name)
+		}
+		for i, taint := range updatedNode.Spec.Taints {
+			if taint.Key == ToBeRemovedByAutoscalerKey {
+				updatedNode.Spec.Taints[i].Value = encodeTaintTime(*taintedTime)
+			}
+		}
+		setTaintSchemaVersion(updatedNode)
+	}
+
+	migratedNode, err := client.CoreV1().Nodes().Update(updatedNode)
+	if err != nil || migratedNode == nil {
+		return updatedNode, false, wrapNodeError(err, "failed to update node %v after migrating taint", updatedNode.Name)
+	}
+	log.Infof("Successfully migrated taint on node %v to version %v", migratedNode.Name, TaintSchemaVersion)
+	return migratedNode, true, nil
+}
+
+// DeleteToBeRemovedTaint removes the ToBeRemovedByAutoscaler taint from the node if it exists, and uncordons the node
+// if the autoscaler cordoned it
 // returns the latest successful update of the node
 func DeleteToBeRemovedTaint(node *apiv1.Node, client kubernetes.Interface) (*apiv1.Node, error) {
 	// fetch the latest version of the node to avoid conflict
 	updatedNode, err := client.CoreV1().Nodes().Get(node.Name, metav1.GetOptions{})
 	if err != nil || updatedNode == nil {
-		return node, fmt.Errorf("failed to get node %v: %v", node.Name, err)
+		return node, wrapNodeError(err, "failed to get node %v", node.Name)
 	}
 
+	var changed bool
 	for i, taint := range updatedNode.Spec.Taints {
 		if taint.Key == ToBeRemovedByAutoscalerKey {
 			// Delete the element from the array without preserving order
 			// https://github.com/golang/go/wiki/SliceTricks#delete-without-preserving-order
 			updatedNode.Spec.Taints[i] = updatedNode.Spec.Taints[len(updatedNode.Spec.Taints)-1]
 			updatedNode.Spec.Taints = updatedNode.Spec.Taints[:len(updatedNode.Spec.Taints)-1]
+			delete(updatedNode.Annotations, TaintSchemaVersionAnnotationKey)
+			changed = true
+			break
+		}
+	}
+	// the annotation is removed even when someone else uncordoned the node since
+	if _, ok := updatedNode.Annotations[ToBeRemovedCordonAnnotationKey]; ok {
+		updatedNode.Spec.Unschedulable = false
+		delete(updatedNode.Annotations, ToBeRemovedCordonAnnotationKey)
+		changed = true
+	}
+	if _, ok := updatedNode.Annotations[ToBeRemovedIntentAnnotationKey]; ok {
+		delete(updatedNode.Annotations, ToBeRemovedIntentAnnotationKey)
+		changed = true
+	}
+	// a node untainted while it was being deleted isn't going to be deleted any more
+	if removeDeletionCordon(updatedNode) {
+		changed = true
+	}
+	if !changed {
+		return updatedNode, nil
+	}
 
-			updatedNodeWithoutTaint, err := client.CoreV1().Nodes().Update(updatedNode)
-			if err != nil || updatedNodeWithoutTaint == nil {
-				return updatedNode, fmt.Errorf("failed to update node %v after deleting taint: %v", updatedNode.Name, err)
-			}
+	updatedNodeWithoutTaint, err := client.CoreV1().Nodes().Update(updatedNode)
+	if err != nil || updatedNodeWithoutTaint == nil {
+		return updatedNode, wrapNodeError(err, "failed to update node %v after deleting taint", updatedNode.Name)
+	}
+
+	log.Infof("Successfully removed taint on node %v", updatedNodeWithoutTaint.Name)
+	return updatedNodeWithoutTaint, nil
+}
 
-			log.Infof("Successfully removed taint on node %v", updatedNodeWithoutTaint.Name)
-			return updatedNodeWithoutTaint, nil
+// GetEscalatorKeys returns the keys of the taints and annotations written by escalator that are on the node
+func GetEscalatorKeys(node *apiv1.Node) []string {
+	var keys []string
+	for _, taint := range node.Spec.Taints {
+		if strings.HasPrefix(taint.Key, EscalatorKeyPrefix) {
+			keys = append(keys, "taint "+taint.Key)
+		}
+	}
+	annotations := make([]string, 0, len(node.Annotations))
+	for key := range node.Annotations {
+		if strings.HasPrefix(key, EscalatorKeyPrefix) {
+			annotations = append(annotations, "annotation "+key)
 		}
 	}
+	sort.Strings(annotations)
+	return append(keys, annotations...)
+}
 
-	return updatedNode, nil
+// wrapNodeError wraps the error of a node request so the kind of the api error is kept.
+// The client returning no node without an error is an error too
+func wrapNodeError(err error, format string, args ...interface{}) error {
+	if err == nil {
+		err = errors.New("no node returned")
+	}
+	return errors.Wrapf(err, format, args...)
 }
@@ -0,0 +1,77 @@
+package k8s
+
+import (
+	"github.com/atlassian/escalator/pkg/errorkind"
+	log "github.com/sirupsen/logrus"
+)
+
+// TaintBudget is the fail safe around tainting: the number of nodes a node group can mark to be removed in a single
+// run. Each run of a node group that taints gets its own budget, which is passed down the taint path, so node groups
+// tainting at the same time don't share any state and a failed run can't leave the next one locked
+type TaintBudget struct {
+	target int
+	spent  int
+}
+
+// NewTaintBudget returns the budget of a run that intends to mark target nodes to be removed
+func NewTaintBudget(target int) *TaintBudget {
+	return &TaintBudget{target: target}
+}
+
+// Target returns the number of nodes the run intends to mark to be removed
+func (b *TaintBudget) Target() int {
+	return b.target
+}
+
+// Spent returns the number of nodes marked to be removed against the budget
+func (b *TaintBudget) Spent() int {
+	return b.spent
+}
+
+// Remaining returns the number of nodes that can still be marked to be removed against the budget
+func (b *TaintBudget) Remaining() int {
+	limit := b.target
+	if limit > MaximumTaints {
+		limit = MaximumTaints
+	}
+	if b.spent >= limit {
+		return 0
+	}
+	return limit - b.spent
+}
+
+// Allow returns an error when no more nodes can be marked to be removed against the budget, i.e. the run already
+// marked its target or MaximumTaints nodes
+func (b *TaintBudget) Allow() error {
+	if b == nil {
+		return errorkind.New(errorkind.Limit, "no taint budget to mark nodes to be removed against")
+	}
+	if b.Remaining() == 0 {
+		return errorkind.New(errorkind.Limit, "taint budget of %v nodes spent", b.spent)
+	}
+	return nil
+}
+
+// Spend records a node marked to be removed against the budget. Exposed for the nodes marked in dry mode
+func (b *TaintBudget) Spend() {
+	b.spent++
+}
+
+// Validate checks the nodes the run recorded as marked to be removed against the budget, once the run finished
+// tainting. Nodes that failed to be marked aren't spent, so a partial failure only warns that the target wasn't
+// reached. Nodes that were already marked are recorded without being spent
+func (b *TaintBudget) Validate(recorded int) error {
+	if b.spent > MaximumTaints {
+		return errorkind.New(errorkind.Limit, "tainted nodes %v exceeded maximum of %v", b.spent, MaximumTaints)
+	}
+	if b.spent > b.target {
+		return errorkind.New(errorkind.Limit, "tainted nodes %v exceeded target of %v", b.spent, b.target)
+	}
+	if b.spent > recorded {
+		return errorkind.New(errorkind.Limit, "tainted nodes %v exceeded recorded of %v", b.spent, recorded)
+	}
+	if recorded != b.target {
+		log.Warningf("tainted nodes %v differs from target of %v", recorded, b.target)
+	}
+	return nil
+}
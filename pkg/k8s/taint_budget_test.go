@@ -0,0 +1,144 @@
+package k8s
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/atlassian/escalator/pkg/errorkind"
+	"github.com/atlassian/escalator/pkg/test"
+	"github.com/stretchr/testify/assert"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	core "k8s.io/client-go/testing"
+)
+
+func TestTaintBudget(t *testing.T) {
+	budget := NewTaintBudget(2)
+	assert.Equal(t, 2, budget.Target())
+	assert.Equal(t, 2, budget.Remaining())
+
+	assert.NoError(t, budget.Allow())
+	budget.Spend()
+	assert.NoError(t, budget.Allow())
+	budget.Spend()
+	assert.Equal(t, 2, budget.Spent())
+	assert.Equal(t, 0, budget.Remaining())
+
+	err := budget.Allow()
+	assert.Error(t, err)
+	assert.Equal(t, errorkind.Limit, errorkind.Of(err))
+	assert.NoError(t, budget.Validate(2))
+}
+
+func TestTaintBudget_Maximum(t *testing.T) {
+	budget := NewTaintBudget(MaximumTaints + 5)
+	for i := 0; i < MaximumTaints; i++ {
+		assert.NoError(t, budget.Allow())
+		budget.Spend()
+	}
+	assert.Error(t, budget.Allow())
+	assert.NoError(t, budget.Validate(MaximumTaints))
+
+	budget.Spend()
+	assert.Error(t, budget.Validate(MaximumTaints+1))
+}
+
+func TestTaintBudget_Validate(t *testing.T) {
+	tests := []struct {
+		name     string
+		target   int
+		spent    int
+		recorded int
+		wantErr  bool
+	}{
+		{"all tainted", 3, 3, 3, false},
+		{"partial failure", 3, 1, 1, false},
+		{"already tainted nodes recorded", 3, 1, 3, false},
+		{"nothing tainted", 3, 0, 0, false},
+		{"more tainted than recorded", 3, 3, 2, true},
+		{"more tainted than the target", 2, 3, 3, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			budget := NewTaintBudget(tt.target)
+			for i := 0; i < tt.spent; i++ {
+				budget.Spend()
+			}
+			err := budget.Validate(tt.recorded)
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.Equal(t, errorkind.Limit, errorkind.Of(err))
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestMarkToBeRemoved_TaintBudget(t *testing.T) {
+	node := test.BuildTestNode(test.NodeOpts{Name: "n1"})
+	fakeClient, updatedNodes := buildFakeClientAndUpdateChannel(node)
+
+	// nothing is marked without a budget, or once the budget is spent
+	_, err := AddToBeRemovedTaint(node, fakeClient, "NoSchedule", nil)
+	assert.Error(t, err)
+	budget := NewTaintBudget(0)
+	_, err = AddToBeRemovedTaint(node, fakeClient, "NoSchedule", budget)
+	assert.Error(t, err)
+	assert.Equal(t, "nothing returned", getStringFromChan(updatedNodes))
+
+	budget = NewTaintBudget(1)
+	updated, err := AddToBeRemovedTaint(node, fakeClient, "NoSchedule", budget)
+	assert.NoError(t, err)
+	assert.Equal(t, "n1", getStringFromChan(updatedNodes))
+	assert.Equal(t, 1, budget.Spent())
+
+	// a node that is already marked doesn't spend the budget
+	budget = NewTaintBudget(1)
+	_, err = AddToBeRemovedTaint(updated, fakeClient, "NoSchedule", budget)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, budget.Spent())
+	assert.NoError(t, budget.Validate(1))
+}
+
+func TestMarkToBeRemoved_TaintBudgetPartialFailure(t *testing.T) {
+	nodes := []*apiv1.Node{
+		test.BuildTestNode(test.NodeOpts{Name: "n1"}),
+		test.BuildTestNode(test.NodeOpts{Name: "n2"}),
+		test.BuildTestNode(test.NodeOpts{Name: "n3"}),
+	}
+	fakeClient := &fake.Clientset{}
+	fakeClient.Fake.AddReactor("get", "nodes", func(action core.Action) (bool, runtime.Object, error) {
+		name := action.(core.GetAction).GetName()
+		for _, node := range nodes {
+			if node.Name == name {
+				return true, node, nil
+			}
+		}
+		return true, nil, errors.New("not found")
+	})
+	fakeClient.Fake.AddReactor("update", "nodes", func(action core.Action) (bool, runtime.Object, error) {
+		node := action.(core.UpdateAction).GetObject().(*apiv1.Node)
+		if node.Name == "n2" {
+			return true, nil, errors.New("conflict")
+		}
+		return true, node, nil
+	})
+
+	// the failed update of n2 isn't spent, so the budget of 2 still marks n3
+	budget := NewTaintBudget(2)
+	var recorded int
+	for _, node := range nodes {
+		if _, err := AddToBeRemovedTaint(node, fakeClient, "NoSchedule", budget); err == nil {
+			recorded++
+		}
+	}
+	assert.Equal(t, 2, recorded)
+	assert.Equal(t, 2, budget.Spent())
+	assert.NoError(t, budget.Validate(recorded))
+
+	// every run gets its own budget, so the failure doesn't leave the next run locked
+	next := NewTaintBudget(1)
+	assert.NoError(t, next.Allow())
+}
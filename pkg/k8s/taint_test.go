@@ -1,6 +1,7 @@
 package k8s
 
 import (
+	"fmt"
 	"testing"
 	"time"
 
@@ -12,6 +13,7 @@ import (
 
 	"strconv"
 
+	"github.com/atlassian/escalator/pkg/errorkind"
 	"github.com/atlassian/escalator/pkg/test"
 	"github.com/stretchr/testify/assert"
 )
@@ -48,7 +50,7 @@ func getStringFromChan(c chan string) string {
 func TestAddToBeRemovedTaint(t *testing.T) {
 	node := test.BuildTestNode(test.NodeOpts{})
 	fakeClient, updatedNodes := buildFakeClientAndUpdateChannel(node)
-	updated, err := AddToBeRemovedTaint(node, fakeClient, "NoExecute")
+	updated, err := AddToBeRemovedTaint(node, fakeClient, "NoExecute", NewTaintBudget(1))
 
 	assert.NoError(t, err)
 	assert.Equal(t, updated.Name, getStringFromChan(updatedNodes))
@@ -60,7 +62,7 @@ func TestAddToBeRemovedTaint(t *testing.T) {
 func TestAddToBeRemovedTaint_DefaultNoScheduleTaintOnEmptyObject(t *testing.T) {
 	node := test.BuildTestNode(test.NodeOpts{})
 	fakeClient, updatedNodes := buildFakeClientAndUpdateChannel(node)
-	updated, err := AddToBeRemovedTaint(node, fakeClient, apiv1.TaintEffect(""))
+	updated, err := AddToBeRemovedTaint(node, fakeClient, apiv1.TaintEffect(""), NewTaintBudget(1))
 
 	assert.NoError(t, err)
 	assert.Equal(t, updated.Name, getStringFromChan(updatedNodes))
@@ -73,7 +75,7 @@ func TestAddToBeRemovedTaint_DefaultNoScheduleTaintOnEmptyObject(t *testing.T) {
 func TestAddToBeRemovedTaint_DefaultNoScheduleTaintOnEmptyString(t *testing.T) {
 	node := test.BuildTestNode(test.NodeOpts{})
 	fakeClient, updatedNodes := buildFakeClientAndUpdateChannel(node)
-	updated, err := AddToBeRemovedTaint(node, fakeClient, "")
+	updated, err := AddToBeRemovedTaint(node, fakeClient, "", NewTaintBudget(1))
 
 	assert.NoError(t, err)
 	assert.Equal(t, updated.Name, getStringFromChan(updatedNodes))
@@ -87,7 +89,7 @@ func TestAddToBeRemovedTaint_AlreadyExists(t *testing.T) {
 	fakeClient, updatedNodes := buildFakeClientAndUpdateChannel(node)
 
 	// Add the taint
-	updated, err := AddToBeRemovedTaint(node, fakeClient, "NoSchedule")
+	updated, err := AddToBeRemovedTaint(node, fakeClient, "NoSchedule", NewTaintBudget(1))
 	assert.NoError(t, err)
 	assert.Equal(t, updated.Name, getStringFromChan(updatedNodes))
 
@@ -95,7 +97,7 @@ func TestAddToBeRemovedTaint_AlreadyExists(t *testing.T) {
 	fakeClient, updatedNodes = buildFakeClientAndUpdateChannel(updated)
 
 	// Add the taint again on the updated node
-	_, err = AddToBeRemovedTaint(updated, fakeClient, "NoSchedule")
+	_, err = AddToBeRemovedTaint(updated, fakeClient, "NoSchedule", NewTaintBudget(1))
 	assert.NoError(t, err)
 	// Ensure the taint is not added again
 	assert.Equal(t, "nothing returned", getStringFromChan(updatedNodes))
@@ -104,7 +106,7 @@ func TestAddToBeRemovedTaint_AlreadyExists(t *testing.T) {
 func TestGetToBeRemovedTaint(t *testing.T) {
 	node := test.BuildTestNode(test.NodeOpts{})
 	fakeClient, updatedNodes := buildFakeClientAndUpdateChannel(node)
-	updated, err := AddToBeRemovedTaint(node, fakeClient, "NoExecute")
+	updated, err := AddToBeRemovedTaint(node, fakeClient, "NoExecute", NewTaintBudget(1))
 
 	assert.NoError(t, err)
 	assert.Equal(t, updated.Name, getStringFromChan(updatedNodes))
@@ -126,7 +128,7 @@ func TestGetToBeRemovedTime(t *testing.T) {
 	fakeClient, updatedNodes := buildFakeClientAndUpdateChannel(node)
 
 	// Add the taint to the node
-	updated, err := AddToBeRemovedTaint(node, fakeClient, "NoSchedule")
+	updated, err := AddToBeRemovedTaint(node, fakeClient, "NoSchedule", NewTaintBudget(1))
 	assert.NoError(t, err)
 	assert.Equal(t, updated.Name, getStringFromChan(updatedNodes))
 	_, ok := GetToBeRemovedTaint(updated)
@@ -157,7 +159,7 @@ func TestDeleteToBeRemovedTaint(t *testing.T) {
 	node := test.BuildTestNode(test.NodeOpts{})
 	fakeClient, updatedNodes := buildFakeClientAndUpdateChannel(node)
 
-	updated, err := AddToBeRemovedTaint(node, fakeClient, "NoSchedule")
+	updated, err := AddToBeRemovedTaint(node, fakeClient, "NoSchedule", NewTaintBudget(1))
 	assert.NoError(t, err)
 	assert.Equal(t, updated.Name, getStringFromChan(updatedNodes))
 
@@ -167,3 +169,160 @@ func TestDeleteToBeRemovedTaint(t *testing.T) {
 	_, ok := GetToBeRemovedTaint(updated)
 	assert.False(t, ok)
 }
+
+func TestDeleteToBeRemovedTaint_NotFound(t *testing.T) {
+	node := test.BuildTestNode(test.NodeOpts{Name: "gone"})
+	fakeClient := &fake.Clientset{}
+	fakeClient.Fake.AddReactor("get", "nodes", func(action core.Action) (bool, runtime.Object, error) {
+		return true, nil, apiErrors.NewNotFound(apiv1.Resource("nodes"), "gone")
+	})
+
+	_, err := DeleteToBeRemovedTaint(node, fakeClient)
+	assert.Error(t, err)
+	assert.Equal(t, errorkind.NotFound, errorkind.Of(err))
+}
+
+func TestMarkToBeRemoved_Cordon(t *testing.T) {
+	node := test.BuildTestNode(test.NodeOpts{})
+	fakeClient, updatedNodes := buildFakeClientAndUpdateChannel(node)
+
+	updated, err := MarkToBeRemoved(node, fakeClient, "NoSchedule", false, true, RemovalIntent{}, NewTaintBudget(1))
+	assert.NoError(t, err)
+	assert.Equal(t, updated.Name, getStringFromChan(updatedNodes))
+	_, tainted := GetToBeRemovedTaint(updated)
+	assert.False(t, tainted)
+	assert.True(t, updated.Spec.Unschedulable)
+	assert.True(t, CordonedToBeRemoved(updated))
+	assert.True(t, MarkedToBeRemoved(updated))
+	val, err := GetToBeRemovedTime(updated)
+	assert.NoError(t, err)
+	assert.True(t, time.Now().Sub(*val) < 10*time.Second)
+
+	// the node isn't updated again
+	fakeClient, updatedNodes = buildFakeClientAndUpdateChannel(updated)
+	_, err = MarkToBeRemoved(updated, fakeClient, "NoSchedule", false, true, RemovalIntent{}, NewTaintBudget(1))
+	assert.NoError(t, err)
+	assert.Equal(t, "nothing returned", getStringFromChan(updatedNodes))
+
+	updated, err = DeleteToBeRemovedTaint(updated, fakeClient)
+	assert.NoError(t, err)
+	assert.Equal(t, updated.Name, getStringFromChan(updatedNodes))
+	assert.False(t, updated.Spec.Unschedulable)
+	assert.False(t, MarkedToBeRemoved(updated))
+	assert.NotContains(t, updated.Annotations, ToBeRemovedCordonAnnotationKey)
+}
+
+func TestMarkToBeRemoved_Both(t *testing.T) {
+	node := test.BuildTestNode(test.NodeOpts{})
+	fakeClient, updatedNodes := buildFakeClientAndUpdateChannel(node)
+
+	updated, err := MarkToBeRemoved(node, fakeClient, "NoExecute", true, true, RemovalIntent{}, NewTaintBudget(1))
+	assert.NoError(t, err)
+	// both marks are added in a single update
+	assert.Equal(t, updated.Name, getStringFromChan(updatedNodes))
+	assert.Equal(t, "nothing returned", getStringFromChan(updatedNodes))
+	taint, tainted := GetToBeRemovedTaint(updated)
+	assert.True(t, tainted)
+	assert.Equal(t, apiv1.TaintEffectNoExecute, taint.Effect)
+	assert.True(t, CordonedToBeRemoved(updated))
+}
+
+func TestCordonedToBeRemoved(t *testing.T) {
+	cordoned := test.BuildTestNode(test.NodeOpts{})
+	cordoned.Spec.Unschedulable = true
+	assert.False(t, CordonedToBeRemoved(cordoned), "cordoned by someone else")
+	assert.False(t, MarkedToBeRemoved(cordoned))
+
+	uncordoned := test.BuildTestNode(test.NodeOpts{})
+	uncordoned.Annotations = map[string]string{ToBeRemovedCordonAnnotationKey: "1500000000"}
+	assert.False(t, CordonedToBeRemoved(uncordoned), "uncordoned by someone else")
+
+	marked := test.BuildTestNode(test.NodeOpts{})
+	marked.Spec.Unschedulable = true
+	marked.Annotations = map[string]string{ToBeRemovedCordonAnnotationKey: "1500000000"}
+	assert.True(t, CordonedToBeRemoved(marked))
+	val, err := GetToBeRemovedTime(marked)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Unix(1500000000, 0), *val)
+}
+
+func TestGetEscalatorKeys(t *testing.T) {
+	node := test.BuildTestNode(test.NodeOpts{Name: "n", Tainted: true})
+	node.Annotations = map[string]string{
+		TaintSchemaVersionAnnotationKey: "1",
+		"example.com/other":             "value",
+	}
+	node.Spec.Taints = append(node.Spec.Taints, apiv1.Taint{Key: "example.com/other", Effect: apiv1.TaintEffectNoSchedule})
+
+	assert.Equal(t, []string{"taint " + ToBeRemovedByAutoscalerKey, "annotation " + TaintSchemaVersionAnnotationKey}, GetEscalatorKeys(node))
+	assert.Empty(t, GetEscalatorKeys(test.BuildTestNode(test.NodeOpts{Name: "n"})))
+}
+
+func TestGetTaintSchemaVersion(t *testing.T) {
+	node := test.BuildTestNode(test.NodeOpts{})
+	version, err := GetTaintSchemaVersion(node)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, version)
+
+	fakeClient, _ := buildFakeClientAndUpdateChannel(node)
+	updated, err := AddToBeRemovedTaint(node, fakeClient, "NoSchedule", NewTaintBudget(1))
+	assert.NoError(t, err)
+	version, err = GetTaintSchemaVersion(updated)
+	assert.NoError(t, err)
+	assert.Equal(t, TaintSchemaVersion, version)
+
+	// a newer version written by a newer escalator can't be decoded
+	updated.Annotations[TaintSchemaVersionAnnotationKey] = strconv.Itoa(TaintSchemaVersion + 1)
+	_, err = GetTaintSchemaVersion(updated)
+	assert.Error(t, err)
+	val, err := GetToBeRemovedTime(updated)
+	assert.Nil(t, val)
+	assert.Error(t, err)
+
+	updated.Annotations[TaintSchemaVersionAnnotationKey] = "invalid"
+	_, err = GetTaintSchemaVersion(updated)
+	assert.Error(t, err)
+}
+
+func TestMigrateToBeRemovedTaint(t *testing.T) {
+	taintedTime := time.Date(2019, 3, 1, 3, 12, 0, 0, time.UTC)
+
+	// a node tainted before the version annotation existed
+	legacy := test.BuildTestNode(test.NodeOpts{Name: "legacy"})
+	legacy.Spec.Taints = append(legacy.Spec.Taints, apiv1.Taint{
+		Key:    ToBeRemovedByAutoscalerKey,
+		Value:  fmt.Sprint(taintedTime.Unix()),
+		Effect: apiv1.TaintEffectNoSchedule,
+	})
+	assert.True(t, TaintNeedsMigration(legacy))
+
+	fakeClient, updatedNodes := buildFakeClientAndUpdateChannel(legacy)
+	migrated, changed, err := MigrateToBeRemovedTaint(legacy, fakeClient)
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, "legacy", getStringFromChan(updatedNodes))
+	assert.Equal(t, strconv.Itoa(TaintSchemaVersion), migrated.Annotations[TaintSchemaVersionAnnotationKey])
+	val, err := GetToBeRemovedTime(migrated)
+	assert.NoError(t, err)
+	assert.True(t, taintedTime.Equal(*val))
+	assert.False(t, TaintNeedsMigration(migrated))
+
+	// already migrated nodes aren't updated
+	_, changed, err = MigrateToBeRemovedTaint(migrated, fakeClient)
+	assert.NoError(t, err)
+	assert.False(t, changed)
+	assert.Equal(t, "nothing returned", getStringFromChan(updatedNodes))
+
+	// the annotation left on an untainted node is removed
+	untainted := test.BuildTestNode(test.NodeOpts{Name: "untainted"})
+	untainted.Annotations = map[string]string{TaintSchemaVersionAnnotationKey: "1"}
+	assert.True(t, TaintNeedsMigration(untainted))
+	fakeClient, updatedNodes = buildFakeClientAndUpdateChannel(untainted)
+	migrated, changed, err = MigrateToBeRemovedTaint(untainted, fakeClient)
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, "untainted", getStringFromChan(updatedNodes))
+	assert.NotContains(t, migrated.Annotations, TaintSchemaVersionAnnotationKey)
+
+	assert.False(t, TaintNeedsMigration(test.BuildTestNode(test.NodeOpts{})))
+}
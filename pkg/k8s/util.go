@@ -1,6 +1,9 @@
 package k8s
 
 import (
+	"strings"
+	"time"
+
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 )
@@ -15,12 +18,145 @@ func PodIsDaemonSet(pod *v1.Pod) bool {
 	return false
 }
 
+// PodIsPauseOnly returns if every container of the pod runs a pause image, e.g. k8s.gcr.io/pause or the Windows
+// mcr.microsoft.com/oss/kubernetes/pause. Such pods only hold a place on the node and don't run a workload
+func PodIsPauseOnly(pod *v1.Pod) bool {
+	if len(pod.Spec.Containers) == 0 {
+		return false
+	}
+	for _, container := range pod.Spec.Containers {
+		if !imageIsPause(container.Image) {
+			return false
+		}
+	}
+	return true
+}
+
+// imageIsPause returns if the repository of the image is a pause image, ignoring its registry, tag and digest
+func imageIsPause(image string) bool {
+	if i := strings.Index(image, "@"); i >= 0 {
+		image = image[:i]
+	}
+	name := image[strings.LastIndex(image, "/")+1:]
+	if i := strings.Index(name, ":"); i >= 0 {
+		name = name[:i]
+	}
+	// e.g. pause-amd64 or pause-win
+	return name == "pause" || strings.HasPrefix(name, "pause-")
+}
+
+// PodKeepsNodeBusy returns if the pod stops its node from being empty. DaemonSet pods, on Linux and Windows nodes alike,
+// and pause only pods don't, as they only exist to run on the node
+func PodKeepsNodeBusy(pod *v1.Pod) bool {
+	return !PodIsDaemonSet(pod) && !PodIsPauseOnly(pod)
+}
+
+// NodeReady returns if the node has the Ready condition
+func NodeReady(node *v1.Node) bool {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == v1.NodeReady {
+			return condition.Status == v1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// NodeReadyTime returns when the node last became ready, false if it isn't ready
+func NodeReadyTime(node *v1.Node) (time.Time, bool) {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == v1.NodeReady && condition.Status == v1.ConditionTrue {
+			return condition.LastTransitionTime.Time, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// NodeHasAnyCondition returns if any of the condition types is true on the node,
+// e.g. the KernelDeadlock condition set by node-problem-detector
+func NodeHasAnyCondition(node *v1.Node, conditionTypes []string) bool {
+	for _, condition := range node.Status.Conditions {
+		if condition.Status != v1.ConditionTrue {
+			continue
+		}
+		for _, conditionType := range conditionTypes {
+			if string(condition.Type) == conditionType {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// PodQOSClasses are the pod QoS classes, from highest to lowest priority
+var PodQOSClasses = []v1.PodQOSClass{v1.PodQOSGuaranteed, v1.PodQOSBurstable, v1.PodQOSBestEffort}
+
+// PodQOSClass returns the QoS class of the pod. The class in the pod status is used when the api server has set it,
+// otherwise it is worked out from the cpu and memory requests and limits of the containers
+func PodQOSClass(pod *v1.Pod) v1.PodQOSClass {
+	if len(pod.Status.QOSClass) > 0 {
+		return pod.Status.QOSClass
+	}
+
+	containers := append(append([]v1.Container{}, pod.Spec.InitContainers...), pod.Spec.Containers...)
+	isBestEffort := true
+	isGuaranteed := true
+	for _, container := range containers {
+		for _, name := range []v1.ResourceName{v1.ResourceCPU, v1.ResourceMemory} {
+			request, hasRequest := container.Resources.Requests[name]
+			limit, hasLimit := container.Resources.Limits[name]
+			if (hasRequest && !request.IsZero()) || (hasLimit && !limit.IsZero()) {
+				isBestEffort = false
+			}
+			// requests default to the limits when they aren't set
+			if !hasLimit || limit.IsZero() || (hasRequest && request.Cmp(limit) != 0) {
+				isGuaranteed = false
+			}
+		}
+	}
+
+	switch {
+	case isBestEffort:
+		return v1.PodQOSBestEffort
+	case isGuaranteed:
+		return v1.PodQOSGuaranteed
+	default:
+		return v1.PodQOSBurstable
+	}
+}
+
+// DefaultSchedulerName is the scheduler of the pods that don't set spec.schedulerName
+const DefaultSchedulerName = "default-scheduler"
+
+// PodIsPreBound returns if the pod was bound to its node without the default scheduler, either by a custom scheduler
+// or by setting spec.nodeName at creation. The node of such a pod doesn't have to match its node selector or affinity
+func PodIsPreBound(pod *v1.Pod) bool {
+	if len(pod.Spec.NodeName) == 0 {
+		return false
+	}
+	if len(pod.Spec.SchedulerName) > 0 && pod.Spec.SchedulerName != DefaultSchedulerName {
+		return true
+	}
+	// the PodScheduled condition is only set when a pod is bound by a scheduler
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == v1.PodScheduled {
+			return false
+		}
+	}
+	return true
+}
+
 // PodIsStatic returns if the pod is static or not
 func PodIsStatic(pod *v1.Pod) bool {
 	configSource, ok := pod.ObjectMeta.Annotations["kubernetes.io/config.source"]
 	return ok && configSource == "file"
 }
 
+// PodIsMirror returns if the pod is the mirror pod the kubelet creates in the API server for one of its static pods
+func PodIsMirror(pod *v1.Pod) bool {
+	_, ok := pod.ObjectMeta.Annotations["kubernetes.io/config.mirror"]
+	return ok
+}
+
 // CalculatePodsRequestsTotal returns the total capacity of all pods
 func CalculatePodsRequestsTotal(pods []*v1.Pod) (resource.Quantity, resource.Quantity, error) {
 	var memoryRequest resource.Quantity
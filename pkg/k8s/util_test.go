@@ -2,6 +2,7 @@ package k8s_test
 
 import (
 	"testing"
+	"time"
 
 	"github.com/atlassian/escalator/pkg/k8s"
 	"github.com/atlassian/escalator/pkg/test"
@@ -9,6 +10,7 @@ import (
 
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func TestPodIsDaemonSet(t *testing.T) {
@@ -21,6 +23,79 @@ func TestPodIsDaemonSet(t *testing.T) {
 	assert.False(t, k8s.PodIsDaemonSet(pod))
 }
 
+func TestPodIsPauseOnly(t *testing.T) {
+	tests := []struct {
+		images []string
+		want   bool
+	}{
+		{[]string{"k8s.gcr.io/pause:3.1"}, true},
+		{[]string{"mcr.microsoft.com/oss/kubernetes/pause:1.4.1"}, true},
+		{[]string{"gcr.io/google_containers/pause-amd64@sha256:59eec8837a4d942cc19a52b8c09ea75121acc38114a2c68b98983ce9356b8610"}, true},
+		{[]string{"pause", "registry:5000/pause-win:1.2.0"}, true},
+		{[]string{"k8s.gcr.io/pause:3.1", "nginx:1.17"}, false},
+		{[]string{"example.com/pause/nginx:1.17"}, false},
+		{[]string{"pauser:latest"}, false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		pod := test.BuildTestPod(test.PodOpts{})
+		pod.Spec.Containers = nil
+		for _, image := range tt.images {
+			pod.Spec.Containers = append(pod.Spec.Containers, v1.Container{Image: image})
+		}
+		assert.Equal(t, tt.want, k8s.PodIsPauseOnly(pod), "%v", tt.images)
+	}
+}
+
+func TestNodeReady(t *testing.T) {
+	ready := test.BuildTestNode(test.NodeOpts{})
+	ready.Status.Conditions = []v1.NodeCondition{
+		{Type: v1.NodeMemoryPressure, Status: v1.ConditionFalse},
+		{Type: v1.NodeReady, Status: v1.ConditionTrue},
+	}
+	notReady := test.BuildTestNode(test.NodeOpts{})
+	notReady.Status.Conditions = []v1.NodeCondition{
+		{Type: v1.NodeReady, Status: v1.ConditionUnknown},
+	}
+
+	assert.True(t, k8s.NodeReady(ready))
+	assert.False(t, k8s.NodeReady(notReady))
+	assert.False(t, k8s.NodeReady(test.BuildTestNode(test.NodeOpts{})))
+}
+
+func TestNodeReadyTime(t *testing.T) {
+	readyAt := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	ready := test.BuildTestNode(test.NodeOpts{})
+	ready.Status.Conditions = []v1.NodeCondition{
+		{Type: v1.NodeReady, Status: v1.ConditionTrue, LastTransitionTime: metav1.NewTime(readyAt)},
+	}
+	notReady := test.BuildTestNode(test.NodeOpts{})
+	notReady.Status.Conditions = []v1.NodeCondition{
+		{Type: v1.NodeReady, Status: v1.ConditionFalse, LastTransitionTime: metav1.NewTime(readyAt)},
+	}
+
+	got, ok := k8s.NodeReadyTime(ready)
+	assert.True(t, ok)
+	assert.Equal(t, readyAt, got)
+	_, ok = k8s.NodeReadyTime(notReady)
+	assert.False(t, ok)
+	_, ok = k8s.NodeReadyTime(test.BuildTestNode(test.NodeOpts{}))
+	assert.False(t, ok)
+}
+
+func TestNodeHasAnyCondition(t *testing.T) {
+	node := test.BuildTestNode(test.NodeOpts{})
+	node.Status.Conditions = []v1.NodeCondition{
+		{Type: v1.NodeReady, Status: v1.ConditionTrue},
+		{Type: "KernelDeadlock", Status: v1.ConditionTrue},
+		{Type: "ReadonlyFilesystem", Status: v1.ConditionFalse},
+	}
+
+	assert.True(t, k8s.NodeHasAnyCondition(node, []string{"ReadonlyFilesystem", "KernelDeadlock"}))
+	assert.False(t, k8s.NodeHasAnyCondition(node, []string{"ReadonlyFilesystem"}))
+	assert.False(t, k8s.NodeHasAnyCondition(node, nil))
+}
+
 func TestPodIsStatic(t *testing.T) {
 	staticPod := test.BuildTestPod(test.PodOpts{})
 	staticPod.ObjectMeta.Annotations = make(map[string]string)
@@ -31,6 +106,80 @@ func TestPodIsStatic(t *testing.T) {
 	assert.False(t, k8s.PodIsStatic(pod))
 }
 
+func TestPodIsMirror(t *testing.T) {
+	mirrorPod := test.BuildTestPod(test.PodOpts{})
+	mirrorPod.ObjectMeta.Annotations = map[string]string{"kubernetes.io/config.mirror": "5c9ae0a8d1ab4f1c"}
+	pod := test.BuildTestPod(test.PodOpts{})
+
+	assert.True(t, k8s.PodIsMirror(mirrorPod))
+	assert.False(t, k8s.PodIsMirror(pod))
+}
+
+func TestPodIsPreBound(t *testing.T) {
+	pending := test.BuildTestPod(test.PodOpts{})
+	assert.False(t, k8s.PodIsPreBound(pending))
+
+	scheduled := test.BuildTestPod(test.PodOpts{NodeName: "node-1"})
+	scheduled.Spec.SchedulerName = k8s.DefaultSchedulerName
+	scheduled.Status.Conditions = []v1.PodCondition{{Type: v1.PodScheduled, Status: v1.ConditionTrue}}
+	assert.False(t, k8s.PodIsPreBound(scheduled))
+
+	createdWithNodeName := test.BuildTestPod(test.PodOpts{NodeName: "node-1"})
+	createdWithNodeName.Spec.SchedulerName = k8s.DefaultSchedulerName
+	assert.True(t, k8s.PodIsPreBound(createdWithNodeName))
+
+	customScheduler := test.BuildTestPod(test.PodOpts{NodeName: "node-1", SchedulerName: "custom-scheduler"})
+	customScheduler.Status.Conditions = []v1.PodCondition{{Type: v1.PodScheduled, Status: v1.ConditionTrue}}
+	assert.True(t, k8s.PodIsPreBound(customScheduler))
+}
+
+func TestPodQOSClass(t *testing.T) {
+	resources := func(cpuRequest, cpuLimit, memRequest, memLimit string) v1.ResourceRequirements {
+		requirements := v1.ResourceRequirements{Requests: v1.ResourceList{}, Limits: v1.ResourceList{}}
+		for _, r := range []struct {
+			list  v1.ResourceList
+			name  v1.ResourceName
+			value string
+		}{
+			{requirements.Requests, v1.ResourceCPU, cpuRequest},
+			{requirements.Limits, v1.ResourceCPU, cpuLimit},
+			{requirements.Requests, v1.ResourceMemory, memRequest},
+			{requirements.Limits, v1.ResourceMemory, memLimit},
+		} {
+			if len(r.value) > 0 {
+				r.list[r.name] = resource.MustParse(r.value)
+			}
+		}
+		return requirements
+	}
+
+	tests := []struct {
+		name      string
+		resources []v1.ResourceRequirements
+		status    v1.PodQOSClass
+		want      v1.PodQOSClass
+	}{
+		{"no resources", []v1.ResourceRequirements{resources("", "", "", "")}, "", v1.PodQOSBestEffort},
+		{"requests", []v1.ResourceRequirements{resources("100m", "", "1Gi", "")}, "", v1.PodQOSBurstable},
+		{"requests lower than limits", []v1.ResourceRequirements{resources("100m", "1", "1Gi", "1Gi")}, "", v1.PodQOSBurstable},
+		{"requests equal limits", []v1.ResourceRequirements{resources("1", "1", "1Gi", "1Gi")}, "", v1.PodQOSGuaranteed},
+		{"only limits", []v1.ResourceRequirements{resources("", "1", "", "1Gi")}, "", v1.PodQOSGuaranteed},
+		{"one container without limits", []v1.ResourceRequirements{resources("1", "1", "1Gi", "1Gi"), resources("", "", "", "")}, "", v1.PodQOSBurstable},
+		{"status takes priority", []v1.ResourceRequirements{resources("", "", "", "")}, v1.PodQOSGuaranteed, v1.PodQOSGuaranteed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := test.BuildTestPod(test.PodOpts{})
+			pod.Spec.Containers = nil
+			for _, requirements := range tt.resources {
+				pod.Spec.Containers = append(pod.Spec.Containers, v1.Container{Resources: requirements})
+			}
+			pod.Status.QOSClass = tt.status
+			assert.Equal(t, tt.want, k8s.PodQOSClass(pod))
+		})
+	}
+}
+
 func TestCalculatePodsRequestTotal(t *testing.T) {
 	p1 := test.BuildTestPod(test.PodOpts{
 		CPU: []int64{1000},
@@ -5,6 +5,7 @@ import (
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
 )
 
 const NAMESPACE = "escalator"
@@ -16,6 +17,79 @@ var (
 		Namespace: NAMESPACE,
 		Help:      "Number of times the controller has checked for cluster state",
 	})
+	// ScanIntervalSeconds is the scan interval until the next run, as tuned to the state of the node groups
+	ScanIntervalSeconds = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name:      "scan_interval_seconds",
+		Namespace: NAMESPACE,
+		Help:      "Scan interval until the next run, as tuned to the state of the node groups",
+	})
+	// HeartbeatFailures is the number of times renewing the heartbeat lease failed
+	HeartbeatFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Name:      "heartbeat_failures",
+		Namespace: NAMESPACE,
+		Help:      "Number of times renewing the heartbeat lease failed",
+	})
+	// SlowCycleProfiles is the number of runs slower than the profile threshold, by whether they were profiled
+	SlowCycleProfiles = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name:      "slow_cycle_profiles",
+			Namespace: NAMESPACE,
+			Help:      "Number of runs slower than the profile threshold, by whether they were profiled",
+		},
+		[]string{"result"},
+	)
+	// UnmatchedNodes nodes that don't match any node group
+	UnmatchedNodes = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name:      "unmatched_nodes",
+		Namespace: NAMESPACE,
+		Help:      "nodes that don't match any node group",
+	})
+	// NodeGroupEmpty whether a node group doesn't match any nodes
+	NodeGroupEmpty = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:      "node_group_empty",
+			Namespace: NAMESPACE,
+			Help:      "1 if the node group doesn't match any nodes, otherwise 0",
+		},
+		[]string{"node_group"},
+	)
+	// NodeGroupMembershipTransitions nodes whose labels moved them between node groups
+	NodeGroupMembershipTransitions = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name:      "node_group_membership_transitions",
+			Namespace: NAMESPACE,
+			Help:      "nodes whose labels moved them between node groups",
+		},
+		[]string{"from_node_group", "to_node_group"},
+	)
+	// NodeGroupErrors runs of a node group that failed, by the kind of error
+	NodeGroupErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name:      "node_group_errors",
+			Namespace: NAMESPACE,
+			Help:      "runs of a node group that failed, by the kind of error",
+		},
+		[]string{"node_group", "kind"},
+	)
+	// NodeGroupState the state of specific node groups after their latest run, as an OpenMetrics state set: 1 for the
+	// current state and 0 for the others
+	NodeGroupState = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:      "node_group_state",
+			Namespace: NAMESPACE,
+			Help:      "state of the node group after its latest run, 1 for the current state and 0 for the others",
+		},
+		[]string{"node_group", NodeGroupStateLabel},
+	)
+	// NodeGroupFollowUpRuns runs of a node group started early because the result of its last scale action was observed
+	NodeGroupFollowUpRuns = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name:      "node_group_follow_up_runs",
+			Namespace: NAMESPACE,
+			Help:      "runs of a node group started early because the result of its last scale action was observed",
+		},
+		[]string{"node_group"},
+	)
 	// NodeGroupNodesUntainted nodes considered by specific node groups that are untainted
 	NodeGroupNodesUntainted = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
@@ -34,6 +108,114 @@ var (
 		},
 		[]string{"node_group"},
 	)
+	// NodeGroupNodesStarting nodes considered by specific node groups that are new and not Ready yet
+	NodeGroupNodesStarting = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:      "node_group_starting_nodes",
+			Namespace: NAMESPACE,
+			Help:      "nodes considered by specific node groups that are new and not Ready yet",
+		},
+		[]string{"node_group"},
+	)
+	// NodeGroupNodesStaticPods nodes considered by specific node groups that are running static pods
+	NodeGroupNodesStaticPods = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:      "node_group_static_pod_nodes",
+			Namespace: NAMESPACE,
+			Help:      "nodes considered by specific node groups that are running static pods",
+		},
+		[]string{"node_group"},
+	)
+	// NodeGroupAllocatableHeterogeneous whether the nodes of specific node groups disagree materially on their allocatable
+	NodeGroupAllocatableHeterogeneous = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:      "node_group_allocatable_heterogeneous",
+			Namespace: NAMESPACE,
+			Help:      "1 if the nodes of the node group disagree materially on their allocatable, otherwise 0",
+		},
+		[]string{"node_group"},
+	)
+	// NodeGroupNodesScheduledMaintenance nodes considered by specific node groups that the cloud provider scheduled maintenance for
+	NodeGroupNodesScheduledMaintenance = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:      "node_group_scheduled_maintenance_nodes",
+			Namespace: NAMESPACE,
+			Help:      "nodes considered by specific node groups that the cloud provider scheduled maintenance for",
+		},
+		[]string{"node_group"},
+	)
+	// NodeGroupMaintenanceReplacements nodes tainted to replace them ahead of the maintenance the cloud provider scheduled for them
+	NodeGroupMaintenanceReplacements = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name:      "node_group_maintenance_replacements",
+			Namespace: NAMESPACE,
+			Help:      "nodes tainted to replace them ahead of the maintenance the cloud provider scheduled for them",
+		},
+		[]string{"node_group"},
+	)
+	// NodeGroupSpotInterruptions nodes of specific node groups tainted and replaced after a spot interruption notice
+	NodeGroupSpotInterruptions = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name:      "node_group_spot_interruptions",
+			Namespace: NAMESPACE,
+			Help:      "nodes of specific node groups tainted and replaced after a spot interruption notice",
+		},
+		[]string{"node_group"},
+	)
+	// NodeGroupSchedulingFailures times the scheduler failed to schedule the pods of specific node groups
+	NodeGroupSchedulingFailures = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name:      "node_group_scheduling_failures",
+			Namespace: NAMESPACE,
+			Help:      "times the scheduler failed to schedule the pods of specific node groups",
+		},
+		[]string{"node_group"},
+	)
+	// NodeGroupProfileCPUPercent percentage of the cpu of specific node groups requested by the pods of each resource profile
+	NodeGroupProfileCPUPercent = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:      "node_group_profile_cpu_percent",
+			Namespace: NAMESPACE,
+			Help:      "percentage of the cpu of specific node groups requested by the pods of each resource profile",
+		},
+		[]string{"node_group", "profile"},
+	)
+	// NodeGroupProfileMemPercent percentage of the memory of specific node groups requested by the pods of each resource profile
+	NodeGroupProfileMemPercent = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:      "node_group_profile_mem_percent",
+			Namespace: NAMESPACE,
+			Help:      "percentage of the memory of specific node groups requested by the pods of each resource profile",
+		},
+		[]string{"node_group", "profile"},
+	)
+	// NodeGroupGuardrailActivations guardrails that clamped or blocked the scaling actions of specific node groups
+	NodeGroupGuardrailActivations = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name:      "node_group_guardrail_activations",
+			Namespace: NAMESPACE,
+			Help:      "guardrails that clamped or blocked the scaling actions of specific node groups",
+		},
+		[]string{"node_group", "guardrail"},
+	)
+	// NodeGroupHeldMinNodes minimum nodes held by the high water mark of specific node groups
+	NodeGroupHeldMinNodes = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:      "node_group_held_min_nodes",
+			Namespace: NAMESPACE,
+			Help:      "minimum nodes held by the high water mark of specific node groups, 0 when there isn't a hold",
+		},
+		[]string{"node_group"},
+	)
+	// NodeGroupReservedNodes nodes reserved by the active reservations of specific node groups
+	NodeGroupReservedNodes = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:      "node_group_reserved_nodes",
+			Namespace: NAMESPACE,
+			Help:      "nodes reserved by the active reservations of specific node groups, 0 when there aren't any",
+		},
+		[]string{"node_group"},
+	)
 	// NodeGroupNodes nodes considered by specific node groups
 	NodeGroupNodesCordoned = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
@@ -43,6 +225,33 @@ var (
 		},
 		[]string{"node_group"},
 	)
+	// NodeGroupNodesShuttingDown nodes considered by specific node groups that are being shut down outside of escalator
+	NodeGroupNodesShuttingDown = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:      "node_group_shutting_down_nodes",
+			Namespace: NAMESPACE,
+			Help:      "nodes considered by specific node groups that are being shut down outside of escalator",
+		},
+		[]string{"node_group"},
+	)
+	// NodeGroupNodesThirdPartyTainted nodes considered by specific node groups that have one of their third party taints
+	NodeGroupNodesThirdPartyTainted = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:      "node_group_third_party_tainted_nodes",
+			Namespace: NAMESPACE,
+			Help:      "nodes considered by specific node groups that have one of their third party taints",
+		},
+		[]string{"node_group"},
+	)
+	// NodeGroupNodesOutdated untainted nodes of specific node groups on an outdated kubelet or OS image
+	NodeGroupNodesOutdated = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:      "node_group_outdated_nodes",
+			Namespace: NAMESPACE,
+			Help:      "untainted nodes of specific node groups on an outdated kubelet or OS image",
+		},
+		[]string{"node_group"},
+	)
 	// NodeGroupNodes nodes considered by specific node groups
 	NodeGroupNodes = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
@@ -52,6 +261,25 @@ var (
 		},
 		[]string{"node_group"},
 	)
+	// NodeGroupSimulatedNodes nodes specific node groups would have if the actions skipped in dry mode were real
+	NodeGroupSimulatedNodes = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:      "node_group_simulated_nodes",
+			Namespace: NAMESPACE,
+			Help:      "nodes specific node groups would have if the scale ups and deletions skipped in dry mode were real",
+		},
+		[]string{"node_group"},
+	)
+	// NodeGroupSimulatedNodesUntainted untainted nodes specific node groups would have if the actions skipped in dry
+	// mode were real
+	NodeGroupSimulatedNodesUntainted = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:      "node_group_simulated_untainted_nodes",
+			Namespace: NAMESPACE,
+			Help:      "untainted nodes specific node groups would have if the scale ups skipped in dry mode were real",
+		},
+		[]string{"node_group"},
+	)
 	// NodeGroupPods pods considered by specific node groups
 	NodeGroupPods = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
@@ -70,6 +298,115 @@ var (
 		},
 		[]string{"node_group"},
 	)
+	// NodeGroupDeletionsDeferred nodes not deleted because their pods could not be rescheduled
+	NodeGroupDeletionsDeferred = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name:      "node_group_deletions_deferred",
+			Namespace: NAMESPACE,
+			Help:      "nodes not deleted because their pods could not be rescheduled",
+		},
+		[]string{"node_group"},
+	)
+	// NodeGroupDeletionsBlockedByPDB hard deletions of tainted nodes deferred because they would violate a
+	// PodDisruptionBudget
+	NodeGroupDeletionsBlockedByPDB = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name:      "node_group_deletions_blocked_by_pdb",
+			Namespace: NAMESPACE,
+			Help:      "hard deletions of tainted nodes deferred because evicting their pods would violate a PodDisruptionBudget",
+		},
+		[]string{"node_group"},
+	)
+	// NodeGroupDeletionFailures failed deletions of tainted nodes
+	NodeGroupDeletionFailures = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name:      "node_group_deletion_failures",
+			Namespace: NAMESPACE,
+			Help:      "failed deletions of tainted nodes",
+		},
+		[]string{"node_group"},
+	)
+	// NodeGroupStuckDeletions tainted nodes that failed to be deleted too many times and are no longer retried
+	NodeGroupStuckDeletions = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:      "node_group_stuck_deletions",
+			Namespace: NAMESPACE,
+			Help:      "tainted nodes that failed to be deleted too many times and are no longer retried",
+		},
+		[]string{"node_group"},
+	)
+	// NodeGroupNodesCordonedForDeletion nodes cordoned by escalator right before deleting them, that are not deleted yet
+	NodeGroupNodesCordonedForDeletion = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:      "node_group_cordoned_for_deletion_nodes",
+			Namespace: NAMESPACE,
+			Help:      "nodes cordoned right before deleting them, that are not deleted yet",
+		},
+		[]string{"node_group"},
+	)
+	// NodeGroupTerminationVerifications terminations of deleted nodes checked against the cloud provider node group
+	NodeGroupTerminationVerifications = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name:      "node_group_termination_verifications",
+			Namespace: NAMESPACE,
+			Help:      "terminations of deleted nodes checked against the cloud provider node group, by result",
+		},
+		[]string{"node_group", "result"},
+	)
+	// NodeGroupScalingActivityFailures scaling activities of the cloud provider that failed after a scale up
+	NodeGroupScalingActivityFailures = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name:      "node_group_scaling_activity_failures",
+			Namespace: NAMESPACE,
+			Help:      "scaling activities of the cloud provider that failed after a scale up",
+		},
+		[]string{"node_group"},
+	)
+	// NodeGroupInstanceTagFailures instances that failed to be tagged or untagged when their nodes were tainted or untainted
+	NodeGroupInstanceTagFailures = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name:      "node_group_instance_tag_failures",
+			Namespace: NAMESPACE,
+			Help:      "instances that failed to be tagged or untagged when their nodes were tainted or untainted",
+		},
+		[]string{"node_group", "action"},
+	)
+	// NodeGroupDaemonSetDrainTimeouts nodes deleted before their DaemonSet pods reported they had flushed
+	NodeGroupDaemonSetDrainTimeouts = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name:      "node_group_daemonset_drain_timeouts",
+			Namespace: NAMESPACE,
+			Help:      "nodes deleted before their DaemonSet pods reported they had flushed",
+		},
+		[]string{"node_group"},
+	)
+	// NodeGroupPreDeleteHookResults results of the pre delete hook calls for nodes about to be deleted
+	NodeGroupPreDeleteHookResults = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name:      "node_group_pre_delete_hook_results",
+			Namespace: NAMESPACE,
+			Help:      "results of the pre delete hook calls for nodes about to be deleted",
+		},
+		[]string{"node_group", "result"},
+	)
+	// NodeGroupQuotaRemainingNodes nodes of the node group that still fit in the cloud provider quota
+	NodeGroupQuotaRemainingNodes = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:      "node_group_quota_remaining_nodes",
+			Namespace: NAMESPACE,
+			Help:      "nodes of the node group that still fit in the cloud provider quota",
+		},
+		[]string{"node_group"},
+	)
+	// NodeGroupQuotaBlocked scale ups reduced or blocked by the cloud provider quota
+	NodeGroupQuotaBlocked = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name:      "node_group_quota_blocked",
+			Namespace: NAMESPACE,
+			Help:      "scale ups reduced or blocked by the cloud provider quota",
+		},
+		[]string{"node_group"},
+	)
 	// NodeGroupsMemPercent percentage of util of memory
 	NodeGroupsMemPercent = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
@@ -88,6 +425,24 @@ var (
 		},
 		[]string{"node_group"},
 	)
+	// PoolMemPercent percentage of util of memory summed across the node groups of a pool
+	PoolMemPercent = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:      "pool_mem_percent",
+			Namespace: NAMESPACE,
+			Help:      "percentage of util of memory summed across the node groups of a pool",
+		},
+		[]string{"pool"},
+	)
+	// PoolCPUPercent percentage of util of cpu summed across the node groups of a pool
+	PoolCPUPercent = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:      "pool_cpu_percent",
+			Namespace: NAMESPACE,
+			Help:      "percentage of util of cpu summed across the node groups of a pool",
+		},
+		[]string{"pool"},
+	)
 	// NodeGroupMemRequest byte value of node request mem
 	NodeGroupMemRequest = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
@@ -106,6 +461,60 @@ var (
 		},
 		[]string{"node_group"},
 	)
+	// NodeGroupPodsByQOSClass pods considered by specific node groups by pod QoS class
+	NodeGroupPodsByQOSClass = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:      "node_group_pods_by_qos_class",
+			Namespace: NAMESPACE,
+			Help:      "pods considered by specific node groups by pod QoS class",
+		},
+		[]string{"node_group", "qos_class"},
+	)
+	// NodeGroupMemRequestByQOSClass byte value of node request mem by pod QoS class
+	NodeGroupMemRequestByQOSClass = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:      "node_group_mem_request_by_qos_class",
+			Namespace: NAMESPACE,
+			Help:      "byte value of node request mem by pod QoS class",
+		},
+		[]string{"node_group", "qos_class"},
+	)
+	// NodeGroupCPURequestByQOSClass milli value of node request cpu by pod QoS class
+	NodeGroupCPURequestByQOSClass = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:      "node_group_cpu_request_by_qos_class",
+			Namespace: NAMESPACE,
+			Help:      "milli value of node request cpu by pod QoS class",
+		},
+		[]string{"node_group", "qos_class"},
+	)
+	// NodeGroupQueuedWorkloads workloads waiting in the batch job queue of specific node groups
+	NodeGroupQueuedWorkloads = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:      "node_group_queued_workloads",
+			Namespace: NAMESPACE,
+			Help:      "workloads waiting in the batch job queue of specific node groups",
+		},
+		[]string{"node_group"},
+	)
+	// NodeGroupQueuedMemRequest byte value of mem requested by workloads waiting in the batch job queue
+	NodeGroupQueuedMemRequest = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:      "node_group_queued_mem_request",
+			Namespace: NAMESPACE,
+			Help:      "byte value of mem requested by workloads waiting in the batch job queue",
+		},
+		[]string{"node_group"},
+	)
+	// NodeGroupQueuedCPURequest milli value of cpu requested by workloads waiting in the batch job queue
+	NodeGroupQueuedCPURequest = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:      "node_group_queued_cpu_request",
+			Namespace: NAMESPACE,
+			Help:      "milli value of cpu requested by workloads waiting in the batch job queue",
+		},
+		[]string{"node_group"},
+	)
 	// NodeGroupMemCapacity byte value of node capacity mem
 	NodeGroupMemCapacity = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
@@ -161,6 +570,44 @@ var (
 		},
 		[]string{"node_group"},
 	)
+	// NodeGroupScaleUpNodeArrival indicates how long after a scale up the requested nodes register
+	NodeGroupScaleUpNodeArrival = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:      "node_group_scale_up_node_arrival",
+			Namespace: NAMESPACE,
+			Help:      "indicates how long after a scale up the requested nodes register",
+			Buckets:   []float64{60, 120, 180, 240, 300, 360, 420, 480, 540, 600, 660, 720, 780, 840, 900, 960, 1020, 1080, 1140, 1200, 1260, 1320, 1380, 1440, 1500, 1560, 1620, 1680, 1740},
+		},
+		[]string{"node_group"},
+	)
+	// NodeGroupNodeBootDuration indicates how long after a scale up the requested nodes become ready
+	NodeGroupNodeBootDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:      "node_group_node_boot_duration",
+			Namespace: NAMESPACE,
+			Help:      "indicates how long after a scale up the requested nodes become ready",
+			Buckets:   []float64{60, 120, 180, 240, 300, 360, 420, 480, 540, 600, 660, 720, 780, 840, 900, 960, 1020, 1080, 1140, 1200, 1260, 1320, 1380, 1440, 1500, 1560, 1620, 1680, 1740},
+		},
+		[]string{"node_group"},
+	)
+	// NodeGroupNodeBootDurationPercentile percentiles of the latest boot durations of the nodes of specific node groups in seconds
+	NodeGroupNodeBootDurationPercentile = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:      "node_group_node_boot_duration_percentile",
+			Namespace: NAMESPACE,
+			Help:      "percentiles of the latest boot durations of the nodes of specific node groups in seconds",
+		},
+		[]string{"node_group", "percentile"},
+	)
+	// NodeGroupIncomingNodes nodes requested by the last scale up that haven't registered yet
+	NodeGroupIncomingNodes = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:      "node_group_incoming_nodes",
+			Namespace: NAMESPACE,
+			Help:      "nodes requested by the last scale up that haven't registered yet",
+		},
+		[]string{"node_group"},
+	)
 	// NodeGroupScaleLockCheckWasLocked indicates how many scale lock `locked()` checks were conduced whilst the lock was held
 	NodeGroupScaleLockCheckWasLocked = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
@@ -189,6 +636,28 @@ var (
 		},
 		[]string{"node_group"},
 	)
+	// NodeGroupNodeLifetime indicates how long the nodes deleted by escalator lived, from registration to deletion
+	NodeGroupNodeLifetime = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:      "node_group_node_lifetime",
+			Namespace: NAMESPACE,
+			Help:      "indicates how long the nodes deleted by escalator lived, from registration to deletion",
+			// 10 minutes to 30 days
+			Buckets: []float64{600, 1800, 3600, 7200, 14400, 28800, 43200, 86400, 172800, 259200, 604800, 1209600, 2592000},
+		},
+		[]string{"node_group"},
+	)
+	// NodeGroupNodeTaintedDuration indicates how long the nodes deleted by escalator were tainted before deletion
+	NodeGroupNodeTaintedDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:      "node_group_node_tainted_duration",
+			Namespace: NAMESPACE,
+			Help:      "indicates how long the nodes deleted by escalator were tainted before deletion",
+			// 1 minute to 1 day
+			Buckets: []float64{60, 120, 300, 600, 900, 1200, 1800, 2700, 3600, 7200, 14400, 28800, 86400},
+		},
+		[]string{"node_group"},
+	)
 	// CloudProviderMinSize indicates the current cloud provider minimum size
 	CloudProviderMinSize = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
@@ -225,37 +694,147 @@ var (
 		},
 		[]string{"cloud_provider", "id"},
 	)
+	// CloudProviderAPIRetries counts the retries of cloud provider api calls
+	CloudProviderAPIRetries = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name:      "cloud_provider_api_retries",
+			Namespace: NAMESPACE,
+			Help:      "number of retries of cloud provider api calls",
+		},
+		[]string{"cloud_provider", "service", "operation"},
+	)
+	// CloudProviderAPIThrottles counts the cloud provider api calls that were throttled
+	CloudProviderAPIThrottles = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name:      "cloud_provider_api_throttles",
+			Namespace: NAMESPACE,
+			Help:      "number of cloud provider api calls that were throttled",
+		},
+		[]string{"cloud_provider", "service", "operation"},
+	)
 )
 
 func init() {
 	prometheus.MustRegister(RunCount)
+	prometheus.MustRegister(ScanIntervalSeconds)
+	prometheus.MustRegister(HeartbeatFailures)
+	prometheus.MustRegister(SlowCycleProfiles)
+	prometheus.MustRegister(UnmatchedNodes)
+	prometheus.MustRegister(NodeGroupEmpty)
+	prometheus.MustRegister(NodeGroupMembershipTransitions)
+	prometheus.MustRegister(NodeGroupFollowUpRuns)
+	prometheus.MustRegister(NodeGroupErrors)
+	prometheus.MustRegister(NodeGroupState)
 	prometheus.MustRegister(NodeGroupNodes)
+	prometheus.MustRegister(NodeGroupSimulatedNodes)
+	prometheus.MustRegister(NodeGroupSimulatedNodesUntainted)
 	prometheus.MustRegister(NodeGroupNodesCordoned)
+	prometheus.MustRegister(NodeGroupNodesShuttingDown)
+	prometheus.MustRegister(NodeGroupNodesThirdPartyTainted)
+	prometheus.MustRegister(NodeGroupNodesOutdated)
+	prometheus.MustRegister(NodeGroupNodesStarting)
+	prometheus.MustRegister(NodeGroupNodesStaticPods)
+	prometheus.MustRegister(NodeGroupAllocatableHeterogeneous)
+	prometheus.MustRegister(NodeGroupNodesScheduledMaintenance)
+	prometheus.MustRegister(NodeGroupMaintenanceReplacements)
+	prometheus.MustRegister(NodeGroupSpotInterruptions)
+	prometheus.MustRegister(NodeGroupSchedulingFailures)
+	prometheus.MustRegister(NodeGroupHeldMinNodes)
+	prometheus.MustRegister(NodeGroupReservedNodes)
+	prometheus.MustRegister(NodeGroupGuardrailActivations)
+	prometheus.MustRegister(NodeGroupProfileCPUPercent)
+	prometheus.MustRegister(NodeGroupProfileMemPercent)
 	prometheus.MustRegister(NodeGroupNodesUntainted)
 	prometheus.MustRegister(NodeGroupNodesTainted)
 	prometheus.MustRegister(NodeGroupPods)
 	prometheus.MustRegister(NodeGroupPodsEvicted)
+	prometheus.MustRegister(NodeGroupDeletionsDeferred)
+	prometheus.MustRegister(NodeGroupDeletionsBlockedByPDB)
+	prometheus.MustRegister(NodeGroupDeletionFailures)
+	prometheus.MustRegister(NodeGroupStuckDeletions)
+	prometheus.MustRegister(NodeGroupNodesCordonedForDeletion)
+	prometheus.MustRegister(NodeGroupTerminationVerifications)
+	prometheus.MustRegister(NodeGroupScalingActivityFailures)
+	prometheus.MustRegister(NodeGroupInstanceTagFailures)
+	prometheus.MustRegister(NodeGroupDaemonSetDrainTimeouts)
+	prometheus.MustRegister(NodeGroupPreDeleteHookResults)
+	prometheus.MustRegister(NodeGroupQuotaRemainingNodes)
+	prometheus.MustRegister(NodeGroupQuotaBlocked)
 	prometheus.MustRegister(NodeGroupsMemPercent)
 	prometheus.MustRegister(NodeGroupsCPUPercent)
+	prometheus.MustRegister(PoolMemPercent)
+	prometheus.MustRegister(PoolCPUPercent)
 	prometheus.MustRegister(NodeGroupCPURequest)
 	prometheus.MustRegister(NodeGroupMemRequest)
+	prometheus.MustRegister(NodeGroupPodsByQOSClass)
+	prometheus.MustRegister(NodeGroupCPURequestByQOSClass)
+	prometheus.MustRegister(NodeGroupMemRequestByQOSClass)
+	prometheus.MustRegister(NodeGroupQueuedWorkloads)
+	prometheus.MustRegister(NodeGroupQueuedCPURequest)
+	prometheus.MustRegister(NodeGroupQueuedMemRequest)
 	prometheus.MustRegister(NodeGroupCPUCapacity)
 	prometheus.MustRegister(NodeGroupMemCapacity)
 	prometheus.MustRegister(NodeGroupTaintEvent)
 	prometheus.MustRegister(NodeGroupUntaintEvent)
 	prometheus.MustRegister(NodeGroupScaleLock)
 	prometheus.MustRegister(NodeGroupScaleLockDuration)
+	prometheus.MustRegister(NodeGroupScaleUpNodeArrival)
+	prometheus.MustRegister(NodeGroupNodeBootDuration)
+	prometheus.MustRegister(NodeGroupNodeBootDurationPercentile)
+	prometheus.MustRegister(NodeGroupIncomingNodes)
 	prometheus.MustRegister(NodeGroupScaleLockCheckWasLocked)
 	prometheus.MustRegister(NodeGroupScaleDelta)
 	prometheus.MustRegister(NodeGroupNodeRegistrationLag)
+	prometheus.MustRegister(NodeGroupNodeLifetime)
+	prometheus.MustRegister(NodeGroupNodeTaintedDuration)
 	prometheus.MustRegister(CloudProviderMinSize)
 	prometheus.MustRegister(CloudProviderMaxSize)
 	prometheus.MustRegister(CloudProviderTargetSize)
 	prometheus.MustRegister(CloudProviderSize)
+	prometheus.MustRegister(CloudProviderAPIRetries)
+	prometheus.MustRegister(CloudProviderAPIThrottles)
+}
+
+// NodeGroupStateLabel is the label of the states of NodeGroupState. OpenMetrics state sets are labelled by the name
+// of the metric
+const NodeGroupStateLabel = NAMESPACE + "_node_group_state"
+
+// NodeLabelDomainLabel is the label added to every metric of an escalator instance segregated by a node label domain
+const NodeLabelDomainLabel = "node_label_domain"
+
+// Gatherer gathers the metrics served on /metrics
+var Gatherer prometheus.Gatherer = prometheus.DefaultGatherer
+
+// SetNodeLabelDomain labels every metric served with the node label domain of the escalator instance, so the
+// metrics of instances sharing a cluster can be told apart
+func SetNodeLabelDomain(domain string) {
+	if len(domain) == 0 {
+		Gatherer = prometheus.DefaultGatherer
+		return
+	}
+	Gatherer = labelledGatherer{prometheus.DefaultGatherer, NodeLabelDomainLabel, domain}
+}
+
+// labelledGatherer adds a label to every metric gathered
+type labelledGatherer struct {
+	prometheus.Gatherer
+	name  string
+	value string
+}
+
+// Gather gathers the metrics with the added label
+func (g labelledGatherer) Gather() ([]*dto.MetricFamily, error) {
+	families, err := g.Gatherer.Gather()
+	for _, family := range families {
+		for _, metric := range family.Metric {
+			metric.Label = append(metric.Label, &dto.LabelPair{Name: &g.name, Value: &g.value})
+		}
+	}
+	return families, err
 }
 
 // Start starts the metrics endpoint on a new thread
 func Start(addr string) {
-	http.Handle("/metrics", promhttp.Handler())
+	http.Handle("/metrics", promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer, promhttp.HandlerFor(Gatherer, promhttp.HandlerOpts{})))
 	go http.ListenAndServe(addr, nil)
 }
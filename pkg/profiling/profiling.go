@@ -0,0 +1,148 @@
+package profiling
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"runtime/pprof"
+	"sync"
+	"time"
+
+	"github.com/atlassian/escalator/pkg/metrics"
+	log "github.com/sirupsen/logrus"
+)
+
+// SlowCycleProfiler captures a cpu profile of the runs that take longer than the threshold, from the time they cross
+// it until they finish, and a heap profile when they finish. Captures are rate limited to one per min interval.
+// The profiles are written to the sink in the background, so a slow sink doesn't hold up the run
+type SlowCycleProfiler struct {
+	Threshold   time.Duration
+	MinInterval time.Duration
+	Sink        Sink
+	// WriteTimeout bounds how long writing the profiles of a run to the sink can take, DefaultWriteTimeout if 0
+	WriteTimeout time.Duration
+
+	lock        sync.Mutex
+	lastCapture time.Time
+	// writes tracks the profiles being written, so the tests can wait for them
+	writes sync.WaitGroup
+}
+
+// DefaultWriteTimeout is how long writing the profiles of a run can take when the profiler has no WriteTimeout
+const DefaultWriteTimeout = time.Minute
+
+// profile is a captured profile to write to the sink
+type profile struct {
+	name string
+	data []byte
+}
+
+// capture is the state of the profiling of a single run
+type capture struct {
+	lock      sync.Mutex
+	start     time.Time
+	cpu       bytes.Buffer
+	profiling bool
+	ended     bool
+}
+
+// Begin starts watching a run that started at start. The returned function must be called when the run ends, which
+// stops the cpu profile and writes the profiles to the sink if the run was slow. A nil profiler does nothing
+func (p *SlowCycleProfiler) Begin(start time.Time) (end func()) {
+	if p == nil || p.Threshold <= 0 || p.Sink == nil {
+		return func() {}
+	}
+
+	c := &capture{start: start}
+	timer := time.AfterFunc(p.Threshold, func() { p.startCPUProfile(c) })
+	return func() {
+		timer.Stop()
+		p.finish(c)
+	}
+}
+
+// startCPUProfile starts the cpu profile of the run once it crossed the threshold, unless a profile was captured too
+// recently or the run already ended
+func (p *SlowCycleProfiler) startCPUProfile(c *capture) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.ended {
+		return
+	}
+	if !p.allowCapture(time.Now()) {
+		log.Warnf("Run has taken longer than %v. Not profiling it, the last profile was captured less than %v ago", p.Threshold, p.MinInterval)
+		metrics.SlowCycleProfiles.WithLabelValues("rate_limited").Add(1.0)
+		return
+	}
+	// only one cpu profile can run at a time, e.g. when escalator is also profiled through other means
+	if err := pprof.StartCPUProfile(&c.cpu); err != nil {
+		log.WithError(err).Warnf("Run has taken longer than %v. Failed to start the cpu profile", p.Threshold)
+		metrics.SlowCycleProfiles.WithLabelValues("failed").Add(1.0)
+		return
+	}
+	log.Warnf("Run has taken longer than %v. Profiling it until it finishes", p.Threshold)
+	c.profiling = true
+}
+
+// allowCapture returns whether a profile can be captured now and records it as the last capture if so
+func (p *SlowCycleProfiler) allowCapture(now time.Time) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if !p.lastCapture.IsZero() && now.Sub(p.lastCapture) < p.MinInterval {
+		return false
+	}
+	p.lastCapture = now
+	return true
+}
+
+// finish stops the cpu profile of the run and captures a heap profile, then hands both to a goroutine that writes them
+// to the sink
+func (p *SlowCycleProfiler) finish(c *capture) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.ended = true
+	if !c.profiling {
+		return
+	}
+	pprof.StopCPUProfile()
+
+	var heap bytes.Buffer
+	if err := pprof.Lookup("heap").WriteTo(&heap, 0); err != nil {
+		log.WithError(err).Warn("Failed to capture the heap profile of the slow run")
+	}
+
+	duration := time.Since(c.start)
+	prefix := fmt.Sprintf("escalator-%v", c.start.UTC().Format("20060102T150405Z"))
+	profiles := []profile{
+		{prefix + "-cpu.pprof", c.cpu.Bytes()},
+		{prefix + "-heap.pprof", heap.Bytes()},
+	}
+	p.writes.Add(1)
+	go func() {
+		defer p.writes.Done()
+		p.write(prefix, duration, profiles)
+	}()
+}
+
+// write makes a best effort to write the profiles of the run to the sink within the write timeout
+func (p *SlowCycleProfiler) write(prefix string, duration time.Duration, profiles []profile) {
+	timeout := p.WriteTimeout
+	if timeout <= 0 {
+		timeout = DefaultWriteTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	for _, profile := range profiles {
+		if len(profile.data) == 0 {
+			continue
+		}
+		if err := p.Sink.Write(ctx, profile.name, profile.data); err != nil {
+			log.WithError(err).Errorf("Failed to write the profile %v of the slow run", profile.name)
+			metrics.SlowCycleProfiles.WithLabelValues("failed").Add(1.0)
+			return
+		}
+	}
+	log.Warnf("Run took %v. Wrote its profiles %v-*.pprof to %v", duration, prefix, p.Sink)
+	metrics.SlowCycleProfiles.WithLabelValues("captured").Add(1.0)
+}
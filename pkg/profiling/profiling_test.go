@@ -0,0 +1,140 @@
+package profiling
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeSink struct {
+	lock     sync.Mutex
+	profiles map[string][]byte
+	// block makes the writes wait for their context to be done
+	block bool
+}
+
+func (s *fakeSink) Write(ctx context.Context, name string, data []byte) error {
+	if s.block {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.profiles == nil {
+		s.profiles = make(map[string][]byte)
+	}
+	s.profiles[name] = data
+	return nil
+}
+
+func (s *fakeSink) names() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var names []string
+	for name := range s.profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// run simulates a run taking the duration and waits for its profiles to be written
+func run(p *SlowCycleProfiler, start time.Time, duration time.Duration) {
+	end := p.Begin(start)
+	time.Sleep(duration)
+	end()
+	if p != nil {
+		p.writes.Wait()
+	}
+}
+
+func TestSlowCycleProfiler(t *testing.T) {
+	start := time.Date(2019, 3, 1, 10, 30, 0, 0, time.UTC)
+
+	t.Run("slow run", func(t *testing.T) {
+		sink := &fakeSink{}
+		p := &SlowCycleProfiler{Threshold: 10 * time.Millisecond, MinInterval: time.Hour, Sink: sink}
+		run(p, start, 100*time.Millisecond)
+		assert.Equal(t, []string{"escalator-20190301T103000Z-cpu.pprof", "escalator-20190301T103000Z-heap.pprof"}, sink.names())
+	})
+
+	t.Run("fast run", func(t *testing.T) {
+		sink := &fakeSink{}
+		p := &SlowCycleProfiler{Threshold: time.Second, MinInterval: time.Hour, Sink: sink}
+		run(p, start, time.Millisecond)
+		assert.Empty(t, sink.names())
+	})
+
+	t.Run("rate limited", func(t *testing.T) {
+		sink := &fakeSink{}
+		p := &SlowCycleProfiler{Threshold: 10 * time.Millisecond, MinInterval: time.Hour, Sink: sink}
+		run(p, start, 100*time.Millisecond)
+		run(p, start.Add(time.Minute), 100*time.Millisecond)
+		assert.Len(t, sink.names(), 2)
+	})
+
+	t.Run("slow sink", func(t *testing.T) {
+		sink := &fakeSink{block: true}
+		p := &SlowCycleProfiler{Threshold: 10 * time.Millisecond, MinInterval: time.Hour, Sink: sink, WriteTimeout: 50 * time.Millisecond}
+		end := p.Begin(start)
+		time.Sleep(100 * time.Millisecond)
+
+		// the run ends without waiting for the sink, and the write gives up after the timeout
+		ended := time.Now()
+		end()
+		assert.True(t, time.Since(ended) < 50*time.Millisecond, "the run waited for the sink")
+		p.writes.Wait()
+		assert.Empty(t, sink.names())
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		var p *SlowCycleProfiler
+		run(p, start, time.Millisecond)
+	})
+}
+
+func TestNewSink(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		want    Sink
+		wantErr bool
+	}{
+		{"path", "/tmp/profiles", &FileSink{Dir: "/tmp/profiles"}, false},
+		{"file url", "file:///tmp/profiles", &FileSink{Dir: "/tmp/profiles"}, false},
+		{"s3 without bucket", "s3:///profiles", nil, true},
+		{"unknown scheme", "gs://bucket/profiles", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewSink(tt.url)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestFileSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "escalator-profiles")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	sink := &FileSink{Dir: filepath.Join(dir, "nested")}
+	require.NoError(t, sink.Write(context.Background(), "cpu.pprof", []byte("profile")))
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "nested", "cpu.pprof"))
+	require.NoError(t, err)
+	assert.Equal(t, "profile", string(data))
+}
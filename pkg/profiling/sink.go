@@ -0,0 +1,90 @@
+package profiling
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/pkg/errors"
+)
+
+// Sink stores the captured profiles. Write gives up when the context is done
+type Sink interface {
+	Write(ctx context.Context, name string, data []byte) error
+}
+
+// NewSink builds the sink from its url: a directory path, file:///path or s3://bucket/prefix
+func NewSink(sinkURL string) (Sink, error) {
+	parsed, err := url.Parse(sinkURL)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid profile sink %q", sinkURL)
+	}
+	switch parsed.Scheme {
+	case "", "file":
+		if len(parsed.Path) == 0 {
+			return nil, errors.Errorf("profile sink %q must have a path", sinkURL)
+		}
+		return &FileSink{Dir: parsed.Path}, nil
+	case "s3":
+		if len(parsed.Host) == 0 {
+			return nil, errors.Errorf("profile sink %q must have a bucket", sinkURL)
+		}
+		sess, err := session.NewSession()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create the aws session of the profile sink")
+		}
+		return &S3Sink{Bucket: parsed.Host, Prefix: strings.TrimPrefix(parsed.Path, "/"), Client: s3.New(sess)}, nil
+	default:
+		return nil, errors.Errorf("profile sink %q must be a path, file:// or s3:// url", sinkURL)
+	}
+}
+
+// FileSink writes the profiles to files in a directory
+type FileSink struct {
+	Dir string
+}
+
+// Write writes the profile to a file in the directory, creating the directory if needed
+func (s *FileSink) Write(ctx context.Context, name string, data []byte) error {
+	if err := ctx.Err(); err != nil {
+		return errors.Wrapf(err, "failed to write profile %v", name)
+	}
+	if err := os.MkdirAll(s.Dir, 0755); err != nil {
+		return errors.Wrapf(err, "failed to create profile directory %v", s.Dir)
+	}
+	return ioutil.WriteFile(filepath.Join(s.Dir, name), data, 0644)
+}
+
+func (s *FileSink) String() string {
+	return s.Dir
+}
+
+// S3Sink uploads the profiles to an S3 bucket
+type S3Sink struct {
+	Bucket string
+	Prefix string
+	Client s3iface.S3API
+}
+
+// Write uploads the profile to the bucket under the prefix
+func (s *S3Sink) Write(ctx context.Context, name string, data []byte) error {
+	_, err := s.Client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(path.Join(s.Prefix, name)),
+		Body:   bytes.NewReader(data),
+	})
+	return errors.Wrapf(err, "failed to upload profile %v to s3://%v/%v", name, s.Bucket, s.Prefix)
+}
+
+func (s *S3Sink) String() string {
+	return "s3://" + path.Join(s.Bucket, s.Prefix)
+}
@@ -1,11 +1,15 @@
 package test
 
 import (
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/autoscaling"
 	"github.com/aws/aws-sdk-go/service/autoscaling/autoscalingiface"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/aws/aws-sdk-go/service/eks"
+	"github.com/aws/aws-sdk-go/service/eks/eksiface"
 )
 
 type MockAutoscalingService struct {
@@ -20,6 +24,12 @@ type MockAutoscalingService struct {
 
 	TerminateInstanceInAutoScalingGroupOutput *autoscaling.TerminateInstanceInAutoScalingGroupOutput
 	TerminateInstanceInAutoScalingGroupErr    error
+
+	CreateOrUpdateTagsOutput *autoscaling.CreateOrUpdateTagsOutput
+	CreateOrUpdateTagsErr    error
+
+	DescribeScalingActivitiesOutput *autoscaling.DescribeScalingActivitiesOutput
+	DescribeScalingActivitiesErr    error
 }
 
 func (m MockAutoscalingService) DescribeAutoScalingGroups(*autoscaling.DescribeAutoScalingGroupsInput) (*autoscaling.DescribeAutoScalingGroupsOutput, error) {
@@ -34,14 +44,126 @@ func (m MockAutoscalingService) TerminateInstanceInAutoScalingGroup(*autoscaling
 	return m.TerminateInstanceInAutoScalingGroupOutput, m.TerminateInstanceInAutoScalingGroupErr
 }
 
+func (m MockAutoscalingService) CreateOrUpdateTags(*autoscaling.CreateOrUpdateTagsInput) (*autoscaling.CreateOrUpdateTagsOutput, error) {
+	return m.CreateOrUpdateTagsOutput, m.CreateOrUpdateTagsErr
+}
+
+func (m MockAutoscalingService) DescribeScalingActivities(*autoscaling.DescribeScalingActivitiesInput) (*autoscaling.DescribeScalingActivitiesOutput, error) {
+	return m.DescribeScalingActivitiesOutput, m.DescribeScalingActivitiesErr
+}
+
+func (m MockAutoscalingService) DescribeAutoScalingGroupsWithContext(_ aws.Context, input *autoscaling.DescribeAutoScalingGroupsInput, _ ...request.Option) (*autoscaling.DescribeAutoScalingGroupsOutput, error) {
+	return m.DescribeAutoScalingGroups(input)
+}
+
+func (m MockAutoscalingService) SetDesiredCapacityWithContext(_ aws.Context, input *autoscaling.SetDesiredCapacityInput, _ ...request.Option) (*autoscaling.SetDesiredCapacityOutput, error) {
+	return m.SetDesiredCapacity(input)
+}
+
+func (m MockAutoscalingService) TerminateInstanceInAutoScalingGroupWithContext(_ aws.Context, input *autoscaling.TerminateInstanceInAutoScalingGroupInput, _ ...request.Option) (*autoscaling.TerminateInstanceInAutoScalingGroupOutput, error) {
+	return m.TerminateInstanceInAutoScalingGroup(input)
+}
+
+func (m MockAutoscalingService) CreateOrUpdateTagsWithContext(_ aws.Context, input *autoscaling.CreateOrUpdateTagsInput, _ ...request.Option) (*autoscaling.CreateOrUpdateTagsOutput, error) {
+	return m.CreateOrUpdateTags(input)
+}
+
+func (m MockAutoscalingService) DescribeScalingActivitiesWithContext(_ aws.Context, input *autoscaling.DescribeScalingActivitiesInput, _ ...request.Option) (*autoscaling.DescribeScalingActivitiesOutput, error) {
+	return m.DescribeScalingActivities(input)
+}
+
 type MockEc2Service struct {
 	ec2iface.EC2API
 	*client.Client
 
 	DescribeInstancesOutput *ec2.DescribeInstancesOutput
 	DescribeInstancesErr    error
+
+	TerminateInstancesOutput *ec2.TerminateInstancesOutput
+	TerminateInstancesErr    error
+
+	CreateTagsOutput *ec2.CreateTagsOutput
+	CreateTagsErr    error
+
+	DeleteTagsOutput *ec2.DeleteTagsOutput
+	DeleteTagsErr    error
+
+	DescribeInstanceStatusOutput *ec2.DescribeInstanceStatusOutput
+	DescribeInstanceStatusErr    error
 }
 
 func (m MockEc2Service) DescribeInstances(*ec2.DescribeInstancesInput) (*ec2.DescribeInstancesOutput, error) {
 	return m.DescribeInstancesOutput, m.DescribeInstancesErr
 }
+
+func (m MockEc2Service) TerminateInstances(*ec2.TerminateInstancesInput) (*ec2.TerminateInstancesOutput, error) {
+	return m.TerminateInstancesOutput, m.TerminateInstancesErr
+}
+
+func (m MockEc2Service) DescribeInstancesWithContext(_ aws.Context, input *ec2.DescribeInstancesInput, _ ...request.Option) (*ec2.DescribeInstancesOutput, error) {
+	return m.DescribeInstances(input)
+}
+
+func (m MockEc2Service) TerminateInstancesWithContext(_ aws.Context, input *ec2.TerminateInstancesInput, _ ...request.Option) (*ec2.TerminateInstancesOutput, error) {
+	return m.TerminateInstances(input)
+}
+
+func (m MockEc2Service) CreateTags(*ec2.CreateTagsInput) (*ec2.CreateTagsOutput, error) {
+	return m.CreateTagsOutput, m.CreateTagsErr
+}
+
+func (m MockEc2Service) DeleteTags(*ec2.DeleteTagsInput) (*ec2.DeleteTagsOutput, error) {
+	return m.DeleteTagsOutput, m.DeleteTagsErr
+}
+
+func (m MockEc2Service) CreateTagsWithContext(_ aws.Context, input *ec2.CreateTagsInput, _ ...request.Option) (*ec2.CreateTagsOutput, error) {
+	return m.CreateTags(input)
+}
+
+func (m MockEc2Service) DeleteTagsWithContext(_ aws.Context, input *ec2.DeleteTagsInput, _ ...request.Option) (*ec2.DeleteTagsOutput, error) {
+	return m.DeleteTags(input)
+}
+
+func (m MockEc2Service) DescribeInstanceStatus(*ec2.DescribeInstanceStatusInput) (*ec2.DescribeInstanceStatusOutput, error) {
+	return m.DescribeInstanceStatusOutput, m.DescribeInstanceStatusErr
+}
+
+func (m MockEc2Service) DescribeInstanceStatusWithContext(_ aws.Context, input *ec2.DescribeInstanceStatusInput, _ ...request.Option) (*ec2.DescribeInstanceStatusOutput, error) {
+	return m.DescribeInstanceStatus(input)
+}
+
+func (m MockEc2Service) DescribeInstanceStatusPagesWithContext(_ aws.Context, input *ec2.DescribeInstanceStatusInput, fn func(*ec2.DescribeInstanceStatusOutput, bool) bool, _ ...request.Option) error {
+	output, err := m.DescribeInstanceStatus(input)
+	if err != nil || output == nil {
+		return err
+	}
+	fn(output, true)
+	return nil
+}
+
+type MockEKSService struct {
+	eksiface.EKSAPI
+	*client.Client
+
+	DescribeNodegroupOutput *eks.DescribeNodegroupOutput
+	DescribeNodegroupErr    error
+
+	UpdateNodegroupConfigOutput *eks.UpdateNodegroupConfigOutput
+	UpdateNodegroupConfigErr    error
+}
+
+func (m MockEKSService) DescribeNodegroup(*eks.DescribeNodegroupInput) (*eks.DescribeNodegroupOutput, error) {
+	return m.DescribeNodegroupOutput, m.DescribeNodegroupErr
+}
+
+func (m MockEKSService) UpdateNodegroupConfig(*eks.UpdateNodegroupConfigInput) (*eks.UpdateNodegroupConfigOutput, error) {
+	return m.UpdateNodegroupConfigOutput, m.UpdateNodegroupConfigErr
+}
+
+func (m MockEKSService) DescribeNodegroupWithContext(_ aws.Context, input *eks.DescribeNodegroupInput, _ ...request.Option) (*eks.DescribeNodegroupOutput, error) {
+	return m.DescribeNodegroup(input)
+}
+
+func (m MockEKSService) UpdateNodegroupConfigWithContext(_ aws.Context, input *eks.UpdateNodegroupConfigInput, _ ...request.Option) (*eks.UpdateNodegroupConfigOutput, error) {
+	return m.UpdateNodegroupConfig(input)
+}
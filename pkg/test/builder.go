@@ -168,6 +168,7 @@ type PodOpts struct {
 	NodeAffinityKey   string
 	NodeAffinityValue string
 	NodeName          string
+	SchedulerName     string
 }
 
 // BuildTestPod builds a pod for testing
@@ -235,6 +236,10 @@ func BuildTestPod(opts PodOpts) *apiv1.Pod {
 		pod.Spec.NodeName = opts.NodeName
 	}
 
+	if len(opts.SchedulerName) > 0 {
+		pod.Spec.SchedulerName = opts.SchedulerName
+	}
+
 	for i := range containers {
 		if opts.CPU[i] >= 0 {
 			pod.Spec.Containers[i].Resources.Requests[apiv1.ResourceCPU] = *resource.NewMilliQuantity(opts.CPU[i], resource.DecimalSI)
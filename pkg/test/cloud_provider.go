@@ -1,6 +1,7 @@
 package test
 
 import (
+	"context"
 	"time"
 
 	"github.com/atlassian/escalator/pkg/cloudprovider"
@@ -37,11 +38,11 @@ func (c *CloudProvider) GetNodeGroup(id string) (cloudprovider.NodeGroup, bool)
 	return ng, ok
 }
 
-func (c *CloudProvider) RegisterNodeGroups(groups ...cloudprovider.NodeGroupConfig) error {
+func (c *CloudProvider) RegisterNodeGroups(ctx context.Context, groups ...cloudprovider.NodeGroupConfig) error {
 	return nil
 }
 
-func (c *CloudProvider) Refresh() error {
+func (c *CloudProvider) Refresh(ctx context.Context) error {
 	return nil
 }
 
@@ -49,7 +50,7 @@ func (c *CloudProvider) RegisterNodeGroup(nodeGroup *NodeGroup) {
 	c.nodeGroups[nodeGroup.id] = nodeGroup
 }
 
-func (c *CloudProvider) GetInstance(node *v1.Node) (cloudprovider.Instance, error) {
+func (c *CloudProvider) GetInstance(ctx context.Context, node *v1.Node) (cloudprovider.Instance, error) {
 	return Instance{}, nil
 }
 
@@ -71,6 +72,8 @@ type NodeGroup struct {
 	maxSize    int64
 	actualSize int64
 	targetSize int64
+	// nodes are the names of the nodes that belong to the node group
+	nodes []string
 }
 
 func NewNodeGroup(id string, minSize int64, maxSize int64, targetSize int64) *NodeGroup {
@@ -80,9 +83,16 @@ func NewNodeGroup(id string, minSize int64, maxSize int64, targetSize int64) *No
 		maxSize,
 		targetSize,
 		targetSize,
+		nil,
 	}
 }
 
+// WithNodes makes the nodes with the names belong to the node group
+func (n *NodeGroup) WithNodes(names ...string) *NodeGroup {
+	n.nodes = append(n.nodes, names...)
+	return n
+}
+
 func (n *NodeGroup) String() string {
 	return n.id
 }
@@ -107,11 +117,11 @@ func (n *NodeGroup) Size() int64 {
 	return n.actualSize
 }
 
-func (n *NodeGroup) IncreaseSize(delta int64) error {
+func (n *NodeGroup) IncreaseSize(ctx context.Context, delta int64) error {
 	return n.setDesiredSize(n.targetSize + delta)
 }
 
-func (n *NodeGroup) DeleteNodes(nodes ...*v1.Node) error {
+func (n *NodeGroup) DeleteNodes(ctx context.Context, nodes ...*v1.Node) error {
 	for range nodes {
 		// Here we would normally tell the actual provider (AWS etc.) to terminate the instance and also decrement the
 		// desired capacity, but we just decrement the internal size to reflect the remote change
@@ -121,15 +131,20 @@ func (n *NodeGroup) DeleteNodes(nodes ...*v1.Node) error {
 }
 
 func (n *NodeGroup) Belongs(node *v1.Node) bool {
+	for _, name := range n.nodes {
+		if name == node.Name {
+			return true
+		}
+	}
 	return false
 }
 
-func (n *NodeGroup) DecreaseTargetSize(delta int64) error {
+func (n *NodeGroup) DecreaseTargetSize(ctx context.Context, delta int64) error {
 	return n.setDesiredSize(n.targetSize + delta)
 }
 
 func (n *NodeGroup) Nodes() []string {
-	return nil
+	return n.nodes
 }
 
 func (n *NodeGroup) setDesiredSize(newSize int64) error {
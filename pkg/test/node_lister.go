@@ -3,6 +3,7 @@ package test
 import (
 	"github.com/pkg/errors"
 	v1 "k8s.io/api/core/v1"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/labels"
 	listerv1 "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/tools/cache"
@@ -36,7 +37,14 @@ func (lister *nodeLister) List(selector labels.Selector) (ret []*v1.Node, err er
 }
 
 func (lister *nodeLister) Get(name string) (*v1.Node, error) {
-	return nil, nil
+	obj, exists, err := lister.store.GetByKey(name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, apiErrors.NewNotFound(v1.Resource("node"), name)
+	}
+	return obj.(*v1.Node), nil
 }
 
 func (lister *nodeLister) ListWithPredicate(predicate listerv1.NodeConditionPredicate) ([]*v1.Node, error) {